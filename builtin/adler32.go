@@ -5,7 +5,6 @@ package builtin
 
 import (
 	"hash"
-	"io"
 
 	"hash/adler32"
 
@@ -22,7 +21,7 @@ var _ = builtinRaw("Adler32(@args)",
 			return sa
 		}
 		for ; k == nil && v != nil; k, v = iter() {
-			io.WriteString(sa.hash, ToStr(v))
+			WriteStr(sa.hash, v)
 		}
 		return IntVal(int(int32(sa.hash.Sum32())))
 	})
@@ -89,7 +88,7 @@ func (*suAdler32) Lookup(_ *Thread, method string) Callable {
 
 var adler32Methods = Methods{
 	"Update": method1("(string)", func(this, arg Value) Value {
-		io.WriteString(this.(*suAdler32).hash, ToStr(arg))
+		WriteStr(this.(*suAdler32).hash, arg)
 		return this
 	}),
 	"Value": method0(func(this Value) Value {
@@ -0,0 +1,42 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package builtin
+
+import (
+	"encoding/hex"
+	"strings"
+
+	. "github.com/apmckinlay/gsuneido/runtime"
+)
+
+var _ = builtin1("Buffer(string = '')",
+	func(arg Value) Value {
+		if b, ok := arg.(SuBuffer); ok {
+			return b
+		}
+		return NewSuBuffer(AsStr(arg))
+	})
+
+func init() {
+	BufferMethods = Methods{
+		"Find": method2("(string, pos=0)", func(this, arg1, arg2 Value) Value {
+			s := this.(SuBuffer).Bytes()
+			pos := position(arg2, len(s))
+			i := strings.Index(s[pos:], AsStr(arg1))
+			if i == -1 {
+				return IntVal(len(s))
+			}
+			return IntVal(pos + i)
+		}),
+		"Hex": method0(func(this Value) Value {
+			return SuStr(hex.EncodeToString([]byte(this.(SuBuffer).Bytes())))
+		}),
+		"Size": method0(func(this Value) Value {
+			return IntVal(this.(SuBuffer).Len())
+		}),
+		"ToString": method0(func(this Value) Value {
+			return SuStr(this.(SuBuffer).Bytes())
+		}),
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package builtin
+
+import (
+	"time"
+
+	. "github.com/apmckinlay/gsuneido/runtime"
+)
+
+type suChannelGlobal struct {
+	SuBuiltin
+}
+
+func init() {
+	name, ps := paramSplit("Channel(size = 0)")
+	Global.Builtin(name, &suChannelGlobal{
+		SuBuiltin{Fn: channelCallClass,
+			BuiltinParams: BuiltinParams{ParamSpec: *ps}}})
+	ChannelMethods = Methods{
+		"Close": method0(func(this Value) Value {
+			this.(*SuChannel).Close()
+			return nil
+		}),
+		"Receive": method1("(timeout = 0)", func(this, arg Value) Value {
+			ms := time.Duration(ToInt(arg)) * time.Millisecond
+			return this.(*SuChannel).Receive(ms)
+		}),
+		"Send": method1("(value)", func(this, arg Value) Value {
+			this.(*SuChannel).Send(arg)
+			return nil
+		}),
+	}
+}
+
+func channelCallClass(_ *Thread, args []Value) Value {
+	return NewSuChannel(ToInt(args[0]))
+}
+
+var channelGlobalMethods = Methods{
+	"Select": method2("(channels, timeout = 0)", func(_, arg1, arg2 Value) Value {
+		ob := ToContainer(arg1)
+		chans := make([]*SuChannel, ob.ListSize())
+		for i := range chans {
+			c, ok := ob.ListGet(i).(*SuChannel)
+			if !ok {
+				panic("Channel.Select requires a list of channels")
+			}
+			chans[i] = c
+		}
+		c, x := Select(chans, time.Duration(ToInt(arg2))*time.Millisecond)
+		if c == nil {
+			return False // timeout
+		}
+		return NewSuObject([]Value{c, x})
+	}),
+}
+
+func (d *suChannelGlobal) Lookup(t *Thread, method string) Callable {
+	if f, ok := channelGlobalMethods[method]; ok {
+		return f
+	}
+	return d.SuBuiltin.Lookup(t, method) // for Params
+}
+
+func (d *suChannelGlobal) String() string {
+	return "Channel /* builtin class */"
+}
@@ -30,6 +30,9 @@ var databaseMethods = Methods{
 	"Check": method("()", func(t *Thread, this Value, args []Value) Value {
 		return SuStr(t.Dbms().Check())
 	}),
+	"Compact": method("()", func(t *Thread, this Value, args []Value) Value {
+		return SuStr(t.Dbms().Compact())
+	}),
 	"Connections": method("()", func(t *Thread, this Value, args []Value) Value {
 		return t.Dbms().Connections()
 	}),
@@ -57,15 +60,28 @@ var databaseMethods = Methods{
 	"Nonce": method("()", func(t *Thread, this Value, args []Value) Value {
 		return SuStr(t.Dbms().Nonce())
 	}),
+	"ReleaseSnapshot": method("(name)", func(t *Thread, this Value, args []Value) Value {
+		return SuBool(t.Dbms().ReleaseSnapshot(ToStr(args[0])))
+	}),
 	"SessionId": method("(id = '')", func(t *Thread, this Value, args []Value) Value {
 		return SuStr(t.Dbms().SessionId(ToStr(args[0])))
 	}),
+	"Snapshot": method("(name)", func(t *Thread, this Value, args []Value) Value {
+		return SuStr(t.Dbms().Snapshot(ToStr(args[0])))
+	}),
 	"TempDest": method0(func(Value) Value {
 		return Zero
 	}),
 	"Token": method("()", func(t *Thread, this Value, args []Value) Value {
 		return SuStr(t.Dbms().Token())
 	}),
+	"TranLimits": method("(limits = false)", func(t *Thread, this Value, args []Value) Value {
+		var limits *SuObject
+		if args[0] != False {
+			limits = ToContainer(args[0]).ToObject()
+		}
+		return t.Dbms().TranLimits(limits)
+	}),
 	"Transactions": method("()", func(t *Thread, this Value, args []Value) Value {
 		return t.Dbms().Transactions()
 	}),
@@ -19,7 +19,7 @@ type suDateGlobal struct {
 func init() {
 	name, ps := paramSplit(`Date(string=false, pattern=false,
 		year=nil, month=nil, day=nil,
-		hour=nil, minute=nil, second=nil, millisecond=nil)`)
+		hour=nil, minute=nil, second=nil, millisecond=nil, zone=nil)`)
 	Global.Builtin(name, &suDateGlobal{SuBuiltin{Fn: dateCallClass,
 		BuiltinParams: BuiltinParams{ParamSpec: *ps}}})
 }
@@ -29,6 +29,18 @@ func dateCallClass(_ *Thread, args []Value) Value {
 		panic("usage: Date() or Date(string [, pattern]) or " +
 			"Date(year:, month:, day:, hour:, minute:, second:)")
 	}
+	result := dateCallClass2(args)
+	if d, ok := result.(SuDate); ok && args[9] != nil {
+		// zone is the offset from UTC in minutes
+		if args[0] == False && !hasFields(args) {
+			return d.ToZone(ToInt(args[9])) // Now in zone
+		}
+		return d.WithZone(ToInt(args[9]))
+	}
+	return result
+}
+
+func dateCallClass2(args []Value) Value {
 	if args[0] != False {
 		if _, ok := args[0].(SuDate); ok {
 			return args[0]
@@ -38,7 +50,7 @@ func dateCallClass(_ *Thread, args []Value) Value {
 		if args[1] == False {
 			if strings.HasPrefix(s, "#") {
 				d = DateFromLiteral(s)
-			} else {
+			} else if d = DateFromISO(s); d == NilDate {
 				d = ParseDate(s, "yMd")
 			}
 		} else {
@@ -139,6 +151,17 @@ func init() {
 		"FormatEn": method1("(format)", func(this, arg Value) Value {
 			return SuStr(this.(SuDate).Format(ToStr(arg)))
 		}),
+		"FormatLocale": method2("(locale, format = '')", func(this, arg1, arg2 Value) Value {
+			loc := toLocale(arg1)
+			fmt := ToStr(arg2)
+			if fmt == "" {
+				fmt = loc.ShortDate
+			}
+			return SuStr(this.(SuDate).FormatLocale(fmt, loc))
+		}),
+		"FormatISO": method0(func(this Value) Value {
+			return SuStr(this.(SuDate).FormatISO())
+		}),
 		"GetLocalGMTBias": method0(func(this Value) Value { // should be static
 			_, offset := time.Now().Zone()
 			return IntVal(-offset / 60)
@@ -150,6 +173,12 @@ func init() {
 					ToInt(args[2]), ToInt(args[3]), ToInt(args[4]),
 					ToInt(args[5]), ToInt(args[6]))
 			}),
+		"ToLocal": method1("(tz = '')", func(this, arg Value) Value {
+			return this.(SuDate).ToLocal(ToStr(arg))
+		}),
+		"ToUTC": method0(func(this Value) Value {
+			return this.(SuDate).ToUTC()
+		}),
 		"WeekDay": method1("(firstDay='Sun')", func(this, arg Value) Value {
 			i := dayOfWeek(arg)
 			return IntVal(((this.(SuDate).WeekDay() - i) + 7) % 7)
@@ -176,6 +205,12 @@ func init() {
 		"Millisecond": method0(func(this Value) Value {
 			return IntVal(this.(SuDate).Millisecond())
 		}),
+		"Zone": method0(func(this Value) Value {
+			if offset, ok := this.(SuDate).Zone(); ok {
+				return IntVal(offset)
+			}
+			return False
+		}),
 	}
 }
 
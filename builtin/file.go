@@ -183,23 +183,16 @@ var suFileMethods = Methods{
 		return nil
 	}),
 	"Read": method1("(nbytes=false)", func(this, arg Value) Value {
-		sf := sfOpenRead(this)
-		n := int(sf.size() - sf.tell) // remaining
-		if n == 0 {                   // at end
-			return False
-		}
-		if arg != False {
-			if m := ToInt(arg); m < n {
-				n = m
-			}
+		if s, ok := sfRead(this, arg); ok {
+			return SuStr(s)
 		}
-		buf := make([]byte, n)
-		_, err := io.ReadFull(sf.r, buf)
-		sf.tell += int64(n)
-		if err != nil {
-			panic("file.Read " + err.Error())
+		return False
+	}),
+	"ReadBuffer": method1("(nbytes=false)", func(this, arg Value) Value {
+		if s, ok := sfRead(this, arg); ok {
+			return NewSuBuffer(s)
 		}
-		return SuStr(string(buf))
+		return False
 	}),
 	"Readline": method0(func(this Value) Value {
 		sf := sfOpenRead(this)
@@ -261,6 +254,28 @@ var suFileMethods = Methods{
 	}),
 }
 
+// sfRead is used by Read and ReadBuffer.
+// It returns false if at the end of the file.
+func sfRead(this, arg Value) (string, bool) {
+	sf := sfOpenRead(this)
+	n := int(sf.size() - sf.tell) // remaining
+	if n == 0 {                   // at end
+		return "", false
+	}
+	if arg != False {
+		if m := ToInt(arg); m < n {
+			n = m
+		}
+	}
+	buf := make([]byte, n)
+	_, err := io.ReadFull(sf.r, buf)
+	sf.tell += int64(n)
+	if err != nil {
+		panic("file.Read " + err.Error())
+	}
+	return string(buf), true
+}
+
 func sfOpen(this Value) *suFile {
 	sf := this.(*suFile)
 	if sf.f == nil {
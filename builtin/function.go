@@ -4,12 +4,20 @@
 package builtin
 
 import (
+	"sort"
 	"sync/atomic"
+	"time"
 
 	"github.com/apmckinlay/gsuneido/options"
 	. "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/dnum"
 )
 
+// CoverageEnable makes subsequent compiles include Cover ops
+// (at the start of each statement).
+// fn.StartCoverage(count) or class.StartCoverage(count) starts recording
+// (boolean or counts) and StopCoverage returns the results and resets them.
+// NOTE: This is statement coverage, branch coverage is not supported.
 var _ = builtin1("CoverageEnable(enable)", func(a Value) Value {
 	if ToBool(a) {
 		atomic.StoreInt64(&options.Coverage, 1)
@@ -43,6 +51,20 @@ func init() {
 	}
 }
 
+var _ = builtin1("Disasm(fn)", func(a Value) Value {
+	switch f := a.(type) {
+	case *SuFunc:
+		return SuStr(DisasmDetail(f))
+	case *SuClosure:
+		return SuStr(DisasmDetail(&f.SuFunc))
+	case *SuMethod:
+		if fn, ok := f.GetFn().(*SuFunc); ok {
+			return SuStr(DisasmDetail(fn))
+		}
+	}
+	panic("Disasm requires a function, method, or block")
+})
+
 var _ = builtin("ProfileEnable(enable)", func(t *Thread, args []Value) Value {
 	if ToBool(args[0]) {
 		t.Profile = make(map[*SuFunc]int)
@@ -52,6 +74,47 @@ var _ = builtin("ProfileEnable(enable)", func(t *Thread, args []Value) Value {
 	return nil
 })
 
+var _ = builtin("ProfileCallsEnable(enable)",
+	func(t *Thread, args []Value) Value {
+		if ToBool(args[0]) {
+			t.CallProfile = make(map[*SuFunc]*CallStats)
+		} else {
+			t.CallProfile = nil
+		}
+		return nil
+	})
+
+// Profile returns the functions with the most cumulative time
+// (from ProfileCallsEnable) as a list of #(name:, calls:, time: ms)
+var _ = builtin("Profile(limit = 20)", func(t *Thread, args []Value) Value {
+	type fnStats struct {
+		fn *SuFunc
+		*CallStats
+	}
+	list := make([]fnStats, 0, len(t.CallProfile))
+	for f, cs := range t.CallProfile {
+		list = append(list, fnStats{f, cs})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Time > list[j].Time })
+	if limit := ToInt(args[0]); len(list) > limit {
+		list = list[:limit]
+	}
+	ob := &SuObject{}
+	for _, x := range list {
+		name := x.fn.Name
+		if x.fn.ClassName != "" {
+			name = x.fn.ClassName + "." + name
+		}
+		ms := float64(x.Time) / float64(time.Millisecond)
+		fs := &SuObject{}
+		fs.Set(SuStr("name"), SuStr(name))
+		fs.Set(SuStr("calls"), IntVal(x.Calls))
+		fs.Set(SuStr("time"), SuDnum{Dnum: dnum.FromFloat(ms)})
+		ob.Add(fs)
+	}
+	return ob
+})
+
 var _ = builtin("ProfileData()", func(t *Thread, _ []Value) Value {
 	ob := &SuObject{}
 	for f, n := range t.Profile {
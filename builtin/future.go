@@ -0,0 +1,25 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package builtin
+
+import (
+	"time"
+
+	. "github.com/apmckinlay/gsuneido/runtime"
+)
+
+func init() {
+	FutureMethods = Methods{
+		"Done?": method0(func(this Value) Value {
+			return SuBool(this.(*SuFuture).Done())
+		}),
+		"Then": method1("(block)", func(this, arg Value) Value {
+			return this.(*SuFuture).Then(arg)
+		}),
+		"Wait": method1("(timeout = 0)", func(this, arg Value) Value {
+			ms := time.Duration(ToInt(arg)) * time.Millisecond
+			return this.(*SuFuture).Wait(ms)
+		}),
+	}
+}
@@ -5,9 +5,15 @@ package builtin
 
 import (
 	. "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/hash"
 )
 
+// Hash uses the unseeded string hash so results are stable across runs.
+// The seeded hash is only for internal hash tables.
 var _ = builtin1("Hash(value)",
 	func(arg Value) Value {
+		if s, ok := arg.ToStr(); ok {
+			return IntVal(int(hash.HashString(s)))
+		}
 		return IntVal(int(arg.Hash()))
 	})
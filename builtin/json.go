@@ -0,0 +1,44 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package builtin
+
+import (
+	. "github.com/apmckinlay/gsuneido/runtime"
+)
+
+type suJsonGlobal struct {
+	SuBuiltin
+}
+
+func init() {
+	name, ps := paramSplit("Json(value)")
+	Global.Builtin(name, &suJsonGlobal{
+		SuBuiltin{Fn: jsonCallClass,
+			BuiltinParams: BuiltinParams{ParamSpec: *ps}}})
+}
+
+// jsonCallClass makes Json(value) the same as Json.Encode(value)
+func jsonCallClass(_ *Thread, args []Value) Value {
+	return SuStr(JSONEncode(args[0]))
+}
+
+var jsonMethods = Methods{
+	"Decode": method1("(string)", func(_, arg Value) Value {
+		return JSONDecode(ToStr(arg))
+	}),
+	"Encode": method1("(value)", func(_, arg Value) Value {
+		return SuStr(JSONEncode(arg))
+	}),
+}
+
+func (d *suJsonGlobal) Lookup(t *Thread, method string) Callable {
+	if f, ok := jsonMethods[method]; ok {
+		return f
+	}
+	return d.SuBuiltin.Lookup(t, method) // for Params
+}
+
+func (d *suJsonGlobal) String() string {
+	return "Json /* builtin class */"
+}
@@ -9,16 +9,26 @@ var _ = builtin("Libraries()", func(t *Thread, args []Value) Value {
 	return t.Dbms().Libraries()
 })
 
+// Use and Unuse only unload if the libraries in use actually change.
+// Changes to individual records should use Unload(name)
+// which only unloads that name and its dependents (see Global.Depend)
+
 var _ = builtin("Use(library)",
 	func(t *Thread, args []Value) Value {
+		if !t.Dbms().Use(ToStr(args[0])) {
+			return False
+		}
 		Global.UnloadAll()
-		return SuBool(t.Dbms().Use(ToStr(args[0])))
+		return True
 	})
 
 var _ = builtin("Unuse(library)",
 	func(t *Thread, args []Value) Value {
+		if !t.Dbms().Unuse(ToStr(args[0])) {
+			return False
+		}
 		Global.UnloadAll()
-		return SuBool(t.Dbms().Unuse(ToStr(args[0])))
+		return True
 	})
 
 var _ = builtin1("Unload(name = false)",
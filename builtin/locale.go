@@ -0,0 +1,49 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package builtin
+
+import (
+	. "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/locale"
+)
+
+// Locale returns a read-only object with the conventions for a locale
+// e.g. Date(s, Locale("en-GB").DateOrder)
+var _ = builtin1("Locale(name)", func(arg Value) Value {
+	loc := toLocale(arg)
+	ob := &SuObject{}
+	ob.Set(SuStr("Name"), SuStr(loc.Name))
+	ob.Set(SuStr("Decimal"), SuStr(loc.Decimal))
+	ob.Set(SuStr("Thousands"), SuStr(loc.Thousands))
+	ob.Set(SuStr("DateOrder"), SuStr(loc.DateOrder))
+	ob.Set(SuStr("ShortDate"), SuStr(loc.ShortDate))
+	ob.Set(SuStr("Months"), strList(loc.Months[:]))
+	ob.Set(SuStr("MonthAbbrs"), strList(loc.MonthAbbrs[:]))
+	ob.Set(SuStr("Days"), strList(loc.Days[:]))
+	ob.Set(SuStr("DayAbbrs"), strList(loc.DayAbbrs[:]))
+	ob.SetReadOnly()
+	return ob
+})
+
+func strList(list []string) Value {
+	ob := &SuObject{}
+	for _, s := range list {
+		ob.Add(SuStr(s))
+	}
+	ob.SetReadOnly()
+	return ob
+}
+
+// toLocale converts a locale name to a Locale.
+// False gives the default locale.
+func toLocale(x Value) *locale.Locale {
+	if x == False {
+		return locale.Default
+	}
+	name := ToStr(x)
+	if loc, ok := locale.Get(name); ok {
+		return loc
+	}
+	panic("unknown locale: " + name)
+}
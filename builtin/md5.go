@@ -5,7 +5,6 @@ package builtin
 
 import (
 	"hash"
-	"io"
 
 	"crypto/md5"
 
@@ -27,7 +26,7 @@ var _ = builtinRaw("Md5(@args)",
 			return sa
 		}
 		for ; k == nil && v != nil; k, v = iter() {
-			io.WriteString(sa.hash, ToStr(v))
+			WriteStr(sa.hash, v)
 		}
 		return sa.value()
 	})
@@ -89,7 +88,7 @@ func (*suMd5) Lookup(_ *Thread, method string) Callable {
 
 var md5Methods = Methods{
 	"Update": method1("(string)", func(this, arg Value) Value {
-		io.WriteString(this.(*suMd5).hash, ToStr(arg))
+		WriteStr(this.(*suMd5).hash, arg)
 		return this
 	}),
 	"Value": method0(func(this Value) Value {
@@ -0,0 +1,50 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package builtin
+
+import (
+	. "github.com/apmckinlay/gsuneido/runtime"
+)
+
+var _ = builtin0("Mutex()", func() Value {
+	return NewSuMutex()
+})
+
+var _ = builtin1("Condition(mutex)", func(arg Value) Value {
+	m, ok := arg.(*SuMutex)
+	if !ok {
+		panic("usage: Condition(mutex)")
+	}
+	return NewSuCond(m)
+})
+
+func init() {
+	MutexMethods = Methods{
+		"Lock": method("()", func(t *Thread, this Value, _ []Value) Value {
+			this.(*SuMutex).Lock(t)
+			return nil
+		}),
+		"Unlock": method("()", func(t *Thread, this Value, _ []Value) Value {
+			this.(*SuMutex).Unlock(t)
+			return nil
+		}),
+		"With": method("(block)", func(t *Thread, this Value, args []Value) Value {
+			return this.(*SuMutex).With(t, args[0])
+		}),
+	}
+	CondMethods = Methods{
+		"Broadcast": method0(func(this Value) Value {
+			this.(*SuCond).Broadcast()
+			return nil
+		}),
+		"Signal": method0(func(this Value) Value {
+			this.(*SuCond).Signal()
+			return nil
+		}),
+		"Wait": method("()", func(t *Thread, this Value, _ []Value) Value {
+			this.(*SuCond).Wait(t)
+			return nil
+		}),
+	}
+}
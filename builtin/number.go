@@ -28,10 +28,10 @@ func init() {
 			}
 			return SuDnum{Dnum: dn}
 		}),
-		"Format": method1("(mask)", func(this, arg Value) Value {
+		"Format": method2("(mask, locale = false)", func(this, arg1, arg2 Value) Value {
 			x := ToDnum(this)
-			mask := ToStr(arg)
-			return SuStr(x.Format(mask))
+			mask := ToStr(arg1)
+			return SuStr(toLocale(arg2).FormatNumber(x.Format(mask)))
 		}),
 		"Frac": method0(func(this Value) Value {
 			dn := ToDnum(this).Frac()
@@ -145,7 +145,7 @@ func fromFloat(f float64) Value {
 // but that's normally what they're used for
 
 var _ = builtinRaw("Max(@args)",
-	func(_ *Thread, as *ArgSpec, args []Value) Value {
+	func(t *Thread, as *ArgSpec, args []Value) Value {
 		if as.Nargs == 0 {
 			panic("Max requires at least one value")
 		}
@@ -158,11 +158,7 @@ var _ = builtinRaw("Max(@args)",
 			}
 			return max
 		}
-		iterable, ok := args[0].(interface{ Iter() Iter })
-		if !ok {
-			panic("can't iterate " + args[0].Type().String())
-		}
-		it := iterable.Iter()
+		it := ToIter(t, args[0])
 		max := it.Next()
 		if as.Each == EACH1 && max != nil {
 			max = it.Next()
@@ -179,7 +175,7 @@ var _ = builtinRaw("Max(@args)",
 	})
 
 var _ = builtinRaw("Min(@args)",
-	func(_ *Thread, as *ArgSpec, args []Value) Value {
+	func(t *Thread, as *ArgSpec, args []Value) Value {
 		if as.Nargs == 0 {
 			panic("Min requires at least one value")
 		}
@@ -192,11 +188,7 @@ var _ = builtinRaw("Min(@args)",
 			}
 			return min
 		}
-		iterable, ok := args[0].(interface{ Iter() Iter })
-		if !ok {
-			panic("can't iterate " + args[0].Type().String())
-		}
-		it := iterable.Iter()
+		it := ToIter(t, args[0])
 		min := it.Next()
 		if as.Each == EACH1 && min != nil {
 			min = it.Next()
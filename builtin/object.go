@@ -20,6 +20,17 @@ var _ = builtin("Object(@args)",
 		return args[0]
 	})
 
+// SortBy returns a native comparator for Sort! e.g. list.Sort!(SortBy(#name))
+var _ = builtin1("SortBy(@members)",
+	func(arg Value) Value {
+		ob := ToContainer(arg)
+		members := make([]Value, ob.ListSize())
+		for i := range members {
+			members[i] = ob.ListGet(i)
+		}
+		return NewSortBy(members)
+	})
+
 // NOTE: ObjectMethods are shared with SuRecord
 
 func init() {
@@ -27,6 +38,7 @@ func init() {
 		"Add": methodRaw("(@args)",
 			func(t *Thread, as *ArgSpec, this Value, args []Value) Value {
 				ob := ToContainer(this)
+				t.Alloc(MemberSize * len(args))
 				iter := NewArgsIter(as, args)
 				if at := getNamed(as, args, SuStr("at")); at != nil {
 					if i, ok := at.IfInt(); ok {
@@ -55,8 +67,15 @@ func init() {
 		"Copy": method0(func(this Value) Value {
 			return ToContainer(this).Copy()
 		}),
+		"DeepCopy": method1("(maxDepth = 0)", func(this, arg Value) Value {
+			return DeepCopy(this, ToInt(arg))
+		}),
 		"Delete": methodRaw("(@args)",
 			obDelete),
+		"Difference": method1("(object)", func(this Value, arg Value) Value {
+			return ToContainer(this).ToObject().Difference(
+				ToContainer(arg).ToObject())
+		}),
 		"Erase": methodRaw("(@args)",
 			func(t *Thread, as *ArgSpec, this Value, args []Value) Value {
 				ob := ToContainer(this)
@@ -82,6 +101,10 @@ func init() {
 				}
 				return ob
 			}),
+		"Finalize": method1("(block)", func(this, arg Value) Value {
+			Finalize(ToContainer(this), arg)
+			return nil
+		}),
 		"Find": method1("(value)", func(this Value, val Value) Value {
 			return ToContainer(this).ToObject().Find(val)
 		}),
@@ -96,9 +119,27 @@ func init() {
 				}
 				return args[1]
 			}),
+		"GetInit": method("(member, block)",
+			func(t *Thread, this Value, args []Value) Value {
+				ob := ToContainer(this)
+				if x := ob.GetIfPresent(t, args[0]); x != nil {
+					return x
+				}
+				x := args[1]
+				if x.Type() == types.Block {
+					// call the block without holding the lock,
+					// if another thread initialized it first, use theirs
+					x = t.Call(x)
+				}
+				return ob.GetInit(t, args[0], x)
+			}),
 		"Has?": method1("(value)", func(this Value, val Value) Value {
 			return SuBool(ToContainer(this).ToObject().Find(val) != False)
 		}),
+		"Intersect": method1("(object)", func(this Value, arg Value) Value {
+			return ToContainer(this).ToObject().Intersect(
+				ToContainer(arg).ToObject())
+		}),
 		"Iter": method0(func(this Value) Value {
 			return SuIter{Iter: IterValues(ToContainer(this), true, true)}
 		}),
@@ -140,6 +181,10 @@ func init() {
 		"Member?": method1("(member)", func(this Value, val Value) Value {
 			return SuBool(ToContainer(this).HasKey(val))
 		}),
+		"MergeUnion": method1("(object)", func(this Value, arg Value) Value {
+			return ToContainer(this).ToObject().MergeUnion(
+				ToContainer(arg).ToObject())
+		}),
 		"Min": method0(func(this Value) Value {
 			iter := ToContainer(this).Iter2(true, true)
 			_, min := iter()
@@ -197,11 +242,18 @@ func init() {
 				}
 				return IntVal(n)
 			}),
+		"Snapshot": method0(func(this Value) Value {
+			return ToContainer(this).ToObject().Snapshot()
+		}),
 		"Sort!": method("(block = false)",
 			func(t *Thread, this Value, args []Value) Value {
 				ToContainer(this).ToObject().Sort(t, args[0])
 				return this
 			}),
+		"Union": method1("(object)", func(this Value, arg Value) Value {
+			return ToContainer(this).ToObject().Union(
+				ToContainer(arg).ToObject())
+		}),
 		"Unique!": method0(func(this Value) Value {
 			ToContainer(this).ToObject().Unique()
 			return this
@@ -13,12 +13,25 @@ var _ = builtin("Record(@args)",
 		return newRecord(args)
 	})
 
+// MemoizeRule marks a rule as depending only on the fields it uses
+// so its results can be reused for records from queries
+var _ = builtin2("MemoizeRule(field, memoize = true)",
+	func(field, memoize Value) Value {
+		MemoizeRule(ToStr(field), ToBool(memoize))
+		return nil
+	})
+
 func newRecord(args []Value) *SuRecord {
 	return SuRecordFromObject(args[0].(*SuObject))
 }
 
 func init() {
 	RecordMethods = Methods{
+		"AttachAsyncRule": method2("(key,callable)",
+			func(this, arg1, arg2 Value) Value {
+				this.(*SuRecord).AttachAsyncRule(arg1, arg2)
+				return nil
+			}),
 		"AttachRule": method2("(key,callable)", func(this, arg1, arg2 Value) Value {
 			this.(*SuRecord).AttachRule(arg1, arg2)
 			return nil
@@ -30,6 +43,9 @@ func init() {
 		"GetDeps": method1("(field)", func(this, arg Value) Value {
 			return this.(*SuRecord).GetDeps(ToStr(arg))
 		}),
+		"GetOriginal": method1("(field)", func(this, arg Value) Value {
+			return this.(*SuRecord).GetOriginal(ToStr(arg))
+		}),
 		"Delete": methodRaw("()",
 			func(t *Thread, as *ArgSpec, this Value, args []Value) Value {
 				k, v := NewArgsIter(as, args)()
@@ -53,12 +69,27 @@ func init() {
 				}
 				return nil
 			}),
+		"Modified?": method0(func(this Value) Value {
+			return SuBool(this.(*SuRecord).IsModified())
+		}),
+		"ModifiedFields": method0(func(this Value) Value {
+			fields := this.(*SuRecord).ModifiedFields()
+			list := make([]Value, len(fields))
+			for i, f := range fields {
+				list[i] = SuStr(f)
+			}
+			return NewSuObject(list)
+		}),
 		"New?": method0(func(this Value) Value {
 			return SuBool(this.(*SuRecord).IsNew())
 		}),
-		"Observer": method1("(observer)", func(this, arg Value) Value {
-			this.(*SuRecord).Observer(arg)
-			return nil
+		"Observer": method2("(observer, priority = 0)",
+			func(this, arg1, arg2 Value) Value {
+				this.(*SuRecord).Observer(arg1, ToInt(arg2))
+				return nil
+			}),
+		"Pending?": method1("(field)", func(this, arg Value) Value {
+			return SuBool(this.(*SuRecord).IsPending(ToStr(arg)))
 		}),
 		"PreSet": method2("(field,value)", func(this, arg1, arg2 Value) Value {
 			this.(*SuRecord).PreSet(arg1, arg2)
@@ -84,5 +115,20 @@ func init() {
 				this.(*SuRecord).DbUpdate(t, args[0])
 				return nil
 			}),
+		"Validate": method("(fields = false)",
+			func(t *Thread, this Value, args []Value) Value {
+				var fields []string
+				if args[0] != False {
+					ob := ToContainer(args[0])
+					for i := 0; i < ob.ListSize(); i++ {
+						fields = append(fields, ToStr(ob.ListGet(i)))
+					}
+				}
+				return this.(*SuRecord).Validate(t, fields)
+			}),
+		"WithoutObservers": method("(block)",
+			func(t *Thread, this Value, args []Value) Value {
+				return this.(*SuRecord).WithoutObservers(t, args[0])
+			}),
 	}
 }
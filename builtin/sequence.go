@@ -11,61 +11,9 @@ import (
 
 var _ = builtin("Sequence(iter)",
 	func(t *Thread, args []Value) Value {
-		return NewSuSequence(&wrapIter{it: args[0], t: t})
+		return NewSuSequence(NewWrapIter(t, args[0]))
 	})
 
-// wrapIter adapts a Suneido iterator (a class with Next,Dup,Infinite)
-// to the runtime.Iter interface. For the reverse see SuIter.
-// No locking since not mutable.
-type wrapIter struct {
-	it Value
-	// t is nil when concurrent.
-	// When not concurrent we use the creating thread.
-	t *Thread
-}
-
-func (wi *wrapIter) Next() Value {
-	x := wi.call("Next")
-	if x == wi.it {
-		return nil
-	}
-	return x
-}
-
-func (wi *wrapIter) Infinite() (result bool) {
-	return wi.call("Infinite?") == True
-}
-
-func (wi *wrapIter) Dup() Iter {
-	it := wi.call("Dup")
-	return &wrapIter{it: it, t: wi.t}
-}
-
-func (wi *wrapIter) SetConcurrent() {
-	wi.t = nil
-	wi.it.SetConcurrent()
-}
-
-func (wi *wrapIter) IsConcurrent() Value {
-	return SuBool(wi.t == nil)
-}
-
-func (wi *wrapIter) call(method string) Value {
-	t := wi.t
-	if t == nil {
-		t = &Thread{}
-		t.Name = "*internal*"
-		defer t.Close()
-	}
-	return t.CallLookup(wi.it, method)
-}
-
-func (wi *wrapIter) Instantiate() *SuObject {
-	return InstantiateIter(wi)
-}
-
-var _ Iter = (*wrapIter)(nil)
-
 // for SuSequence
 
 func init() {
@@ -81,8 +29,8 @@ func init() {
 		}),
 		"Iter": method0(func(this Value) Value {
 			iter := this.(*SuSequence).Iter()
-			if wi, ok := iter.(*wrapIter); ok {
-				return wi.it
+			if wi, ok := iter.(*WrapIter); ok {
+				return wi.Unwrap()
 			}
 			return SuIter{Iter: iter}
 		}),
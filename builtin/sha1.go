@@ -5,7 +5,6 @@ package builtin
 
 import (
 	"hash"
-	"io"
 
 	"crypto/sha1"
 
@@ -27,7 +26,7 @@ var _ = builtinRaw("Sha1(@args)",
 			return sa
 		}
 		for ; k == nil && v != nil; k, v = iter() {
-			io.WriteString(sa.hash, ToStr(v))
+			WriteStr(sa.hash, v)
 		}
 		return sa.value()
 	})
@@ -89,7 +88,7 @@ func (*suSha1) Lookup(_ *Thread, method string) Callable {
 
 var sha1Methods = Methods{
 	"Update": method1("(string)", func(this, arg Value) Value {
-		io.WriteString(this.(*suSha1).hash, ToStr(arg))
+		WriteStr(this.(*suSha1).hash, arg)
 		return this
 	}),
 	"Value": method0(func(this Value) Value {
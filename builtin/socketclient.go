@@ -113,16 +113,10 @@ var suSocketClientMethods = Methods{
 		return nil
 	}),
 	"Read": method1("(n)", func(this, arg Value) Value {
-		sc := scOpen(this)
-		n := ToInt(arg)
-		buf := make([]byte, n)
-		sc.conn.SetDeadline(time.Now().Add(sc.timeout))
-		defer sc.conn.SetDeadline(noDeadline)
-		n, e := io.ReadFull(sc.rdr, buf)
-		if e != nil && e != io.ErrUnexpectedEOF {
-			panic("socketClient.Read: " + e.Error())
-		}
-		return SuStr(string(buf[:n]))
+		return SuStr(scRead(this, arg))
+	}),
+	"ReadBuffer": method1("(n)", func(this, arg Value) Value {
+		return NewSuBuffer(scRead(this, arg))
 	}),
 	"Readline": method0(func(this Value) Value {
 		sc := scOpen(this)
@@ -171,6 +165,20 @@ func (sc *suSocketClient) close() {
 	sc.conn = nil
 }
 
+// scRead is used by Read and ReadBuffer
+func scRead(this, arg Value) string {
+	sc := scOpen(this)
+	n := ToInt(arg)
+	buf := make([]byte, n)
+	sc.conn.SetDeadline(time.Now().Add(sc.timeout))
+	defer sc.conn.SetDeadline(noDeadline)
+	n, e := io.ReadFull(sc.rdr, buf)
+	if e != nil && e != io.ErrUnexpectedEOF {
+		panic("socketClient.Read: " + e.Error())
+	}
+	return string(buf[:n])
+}
+
 func scOpen(this Value) *suSocketClient {
 	sc, ok := this.(*suSocketClient)
 	if !ok {
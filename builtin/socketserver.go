@@ -11,6 +11,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/apmckinlay/gsuneido/options"
 	. "github.com/apmckinlay/gsuneido/runtime"
 	"github.com/apmckinlay/gsuneido/util/str"
 )
@@ -51,7 +52,7 @@ func ssArgs(t *Thread, as *ArgSpec, this Value, args []Value) (
 	}
 	n := len(as.Spec)
 	names := make([]Value, 0, n)
-	spec := make([]byte, 0, n)
+	spec := make([]uint16, 0, n)
 	nargs := byte(0)
 	for ; v != nil; k, v = ai() { // copy remaining args, extracting name & port
 		if SuStr("name").Equal(k) {
@@ -62,7 +63,7 @@ func ssArgs(t *Thread, as *ArgSpec, this Value, args []Value) (
 			nargs++
 			t.Push(v)
 			if k != nil {
-				spec = append(spec, byte(len(names)))
+				spec = append(spec, uint16(len(names)))
 				names = append(names, k)
 			}
 		}
@@ -70,13 +71,13 @@ func ssArgs(t *Thread, as *ArgSpec, this Value, args []Value) (
 	if name != nil {
 		nargs++
 		t.Push(name)
-		spec = append(spec, byte(len(names)))
+		spec = append(spec, uint16(len(names)))
 		names = append(names, SuStr("Name"))
 	}
 	if port != nil {
 		nargs++
 		t.Push(port)
-		spec = append(spec, byte(len(names)))
+		spec = append(spec, uint16(len(names)))
 		names = append(names, SuStr("Port"))
 	}
 	as2 = &ArgSpec{Nargs: nargs, Names: names, Spec: spec}
@@ -135,6 +136,7 @@ func (sm *suServerMaster) connect(name string, conn net.Conn) {
 	}
 	t := NewThread()
 	t.Name = str.BeforeFirst(t.Name, " ") + " " + name
+	t.LimitMemory(options.ThreadMemLimit)
 	if f := sc.Lookup(t, "Run"); f != nil {
 		defer func() {
 			if e := recover(); e != nil {
@@ -6,6 +6,7 @@ package builtin
 import (
 	"math"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/apmckinlay/gsuneido/util/ints"
 	"github.com/apmckinlay/gsuneido/util/regex"
@@ -53,13 +54,17 @@ func init() {
 			}
 			return SuInt(int(s[0]))
 		}),
-		"Compile": method("(errob = false)",
+		"Compile": method("(errob = false, strict = false)",
 			func(t *Thread, this Value, args []Value) Value {
 				if args[0] == False {
 					return compile.Constant(ToStr(this))
 				}
 				ob := ToContainer(args[0])
-				val, checks := compile.Checked(t, ToStr(this))
+				checked := compile.Checked
+				if ToBool(args[1]) {
+					checked = compile.StrictChecked
+				}
+				val, checks := checked(t, ToStr(this))
 				for _, w := range checks {
 					ob.Add(SuStr(w))
 				}
@@ -274,6 +279,13 @@ func init() {
 		"Number?": method0(func(this Value) Value {
 			return SuBool(numberPat.Matches(ToStr(this)))
 		}),
+		"NumberLocale": method1("(locale = false)", func(this, arg Value) Value {
+			s := toLocale(arg).ParseNumber(ToStr(this))
+			if !numberPat.Matches(s) {
+				return False
+			}
+			return NumFromString(s)
+		}),
 		"Numeric?": method0(func(this Value) Value {
 			s := ToStr(this)
 			if len(s) == 0 {
@@ -381,6 +393,27 @@ func init() {
 			}
 			return SuBool(result)
 		}),
+		// The Utf8 methods work with codepoints rather than bytes.
+		// The other methods are byte oriented (and ascii only for case)
+		"Utf8?": method0(func(this Value) Value {
+			return SuBool(utf8.ValidString(ToStr(this)))
+		}),
+		"Utf8Lower": method0(func(this Value) Value {
+			return SuStr(str.Utf8ToLower(ToStr(this)))
+		}),
+		"Utf8Size": method0(func(this Value) Value {
+			return IntVal(str.Utf8Len(ToStr(this)))
+		}),
+		"Utf8Substr": method2("(i, n = false)", func(this, arg1, arg2 Value) Value {
+			n := math.MaxInt32
+			if arg2 != False {
+				n = ToInt(arg2)
+			}
+			return SuStr(str.Utf8Subn(ToStr(this), ToInt(arg1), n))
+		}),
+		"Utf8Upper": method0(func(this Value) Value {
+			return SuStr(str.Utf8ToUpper(ToStr(this)))
+		}),
 	}
 }
 
@@ -10,24 +10,44 @@ import (
 
 func init() {
 	SuneidoObjectMethods = Methods{
-		"Compile": method("(source, errob = false)",
+		"Compile": method("(source, errob = false, strict = false)",
 			func(t *Thread, _ Value, args []Value) Value {
 				src := ToStr(args[0])
 				if args[1] == False {
 					return compile.Constant(src)
 				}
 				ob := ToContainer(args[1])
-				val, checks := compile.Checked(t, src)
+				checked := compile.Checked
+				if ToBool(args[2]) {
+					checked = compile.StrictChecked
+				}
+				val, checks := checked(t, src)
 				for _, w := range checks {
 					ob.Add(SuStr(w))
 				}
 				return val
 			}),
+		"CompileStrict": method("(source)",
+			func(t *Thread, _ Value, args []Value) Value {
+				return compile.CompileStrict(t, ToStr(args[0]))
+			}),
 		"Parse": method("(source)",
 			func(t *Thread, _ Value, args []Value) Value {
 				src := ToStr(args[0])
 				p := compile.AstParser(src)
 				return p.Const()
 			}),
+		"Format": method("(source)",
+			func(t *Thread, _ Value, args []Value) Value {
+				return SuStr(compile.Format(ToStr(args[0])))
+			}),
+		"SyntaxErrors": method("(source)",
+			func(t *Thread, _ Value, args []Value) Value {
+				ob := &SuObject{}
+				for _, e := range compile.SyntaxErrors(ToStr(args[0])) {
+					ob.Add(SuStr(e))
+				}
+				return ob
+			}),
 	}
 }
@@ -46,6 +46,22 @@ func (ts *threadList) remove(num int32) {
 	delete(ts.list, num)
 }
 
+// cancel cancels the thread(s) with the given name (as set by Thread.Name)
+// returning whether any were found
+func (ts *threadList) cancel(name string) bool {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	found := false
+	for _, t := range ts.list {
+		// Name is "Thread-# name"
+		if i := strings.IndexByte(t.Name, ' '); i != -1 && t.Name[i+1:] == name {
+			t.Cancel()
+			found = true
+		}
+	}
+	return found
+}
+
 func (ts *threadList) count() int {
 	ts.lock.Lock()
 	defer ts.lock.Unlock()
@@ -116,6 +132,55 @@ var threadMethods = Methods{
 		time.Sleep(time.Duration(ToInt(ms)) * time.Millisecond)
 		return nil
 	}),
+	"Cancel": method1("(name)", func(this, name Value) Value {
+		return SuBool(threads.cancel(ToStr(name)))
+	}),
+	"Deadline": method("(ms, block)",
+		func(t *Thread, this Value, args []Value) Value {
+			d := time.Now().Add(time.Duration(ToInt(args[0])) * time.Millisecond)
+			prev := t.Deadline()
+			if !prev.IsZero() && prev.Before(d) {
+				d = prev // don't extend an outer deadline
+			}
+			t.SetDeadline(d)
+			defer t.SetDeadline(prev)
+			return t.Call(args[1])
+		}),
+	"Spawn": method1("(callable)", func(this, fn Value) Value {
+		return Spawn(fn)
+	}),
+	"WaitAll": method1("(futures)", func(this, arg Value) Value {
+		ob := ToContainer(arg)
+		futures := make([]*SuFuture, ob.ListSize())
+		for i := range futures {
+			f, ok := ob.ListGet(i).(*SuFuture)
+			if !ok {
+				panic("Thread.WaitAll requires a list of futures")
+			}
+			futures[i] = f
+		}
+		results := WaitAll(futures)
+		for i, x := range results {
+			if x == nil {
+				results[i] = EmptyStr // no return value
+			}
+		}
+		return NewSuObject(results)
+	}),
+	"Allocated": method("()", func(t *Thread, this Value, args []Value) Value {
+		return Int64Val(t.Allocated())
+	}),
+	"MemoryLimit": method("(bytes, block)",
+		func(t *Thread, this Value, args []Value) Value {
+			prev := t.MemLimit
+			limit := t.Allocated() + int64(ToInt(args[0]))
+			if prev > 0 && prev < limit {
+				limit = prev // don't extend an outer limit
+			}
+			t.MemLimit = limit
+			defer func() { t.MemLimit = prev }()
+			return t.Call(args[1])
+		}),
 }
 
 func (d *suThreadGlobal) Lookup(t *Thread, method string) Callable {
@@ -0,0 +1,34 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package builtin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/compile"
+	. "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestThreadCancel(t *testing.T) {
+	assert := assert.T(t)
+	named := NewThread()
+	other := NewThread()
+	for _, th := range []*Thread{named, other} {
+		threads.add(th.Num, th)
+		defer threads.remove(th.Num)
+	}
+	named.Push(SuStr("worker"))
+	threadMethods["Name"].Call(named, nil, &ArgSpec1)
+	assert.That(strings.HasSuffix(named.Name, " worker"))
+
+	assert.False(threads.cancel(other.Name))
+	assert.False(threads.cancel(named.Name))
+	assert.True(threads.cancel("worker"))
+	loop := "for (i = 0; i < 10000; ++i) {}"
+	assert.This(func() { compile.EvalString(named, loop) }).
+		Panics("thread cancelled")
+	compile.EvalString(other, loop)
+}
@@ -8,21 +8,91 @@ import (
 	"github.com/apmckinlay/gsuneido/runtime/trace"
 )
 
-var _ = builtin("Trace(value, block = false)",
-	func(t *Thread, args []Value) Value {
-		if s, ok := args[0].ToStr(); ok {
-			if args[1] != False {
-				panic("usage: Trace(string) or Trace(flags, block)")
+type suTraceGlobal struct {
+	SuBuiltin
+}
+
+func init() {
+	name, ps := paramSplit("Trace(value, block = false)")
+	Global.Builtin(name, &suTraceGlobal{
+		SuBuiltin{Fn: traceCallClass,
+			BuiltinParams: BuiltinParams{ParamSpec: *ps}}})
+}
+
+func traceCallClass(t *Thread, args []Value) Value {
+	if s, ok := args[0].ToStr(); ok {
+		if args[1] != False {
+			panic("usage: Trace(string) or Trace(flags, block)")
+		}
+		trace.Print(s + "\n")
+	} else {
+		prev := trace.Set(ToInt(args[0]))
+		if args[1] != False {
+			defer func() {
+				trace.Set(prev)
+			}()
+			return t.Call(args[1])
+		}
+	}
+	return nil
+}
+
+var traceMethods = Methods{
+	"Disable": method("(@flags)", func(_ *Thread, _ Value, args []Value) Value {
+		return IntVal(trace.Disable(traceFlags(args[0])))
+	}),
+	"Enable": method("(@flags)", func(_ *Thread, _ Value, args []Value) Value {
+		return IntVal(trace.Enable(traceFlags(args[0])))
+	}),
+	"Flags": method0(func(Value) Value {
+		return IntVal(trace.Flags())
+	}),
+	"LogFile": method1("(filename)", func(_, arg Value) Value {
+		trace.SetLogFile(ToStr(arg))
+		return nil
+	}),
+	"Recent": method0(func(Value) Value {
+		list := trace.Recent()
+		vals := make([]Value, len(list))
+		for i, s := range list {
+			vals[i] = SuStr(s)
+		}
+		return NewSuObject(vals)
+	}),
+	"Socket": method1("(address = '')", func(_, arg Value) Value {
+		if err := trace.SetSocket(ToStr(arg)); err != nil {
+			panic("Trace.Socket: " + err.Error())
+		}
+		return nil
+	}),
+}
+
+// traceFlags converts a list of names (e.g. "Records") or numbers to flags
+func traceFlags(arg Value) int {
+	ob := ToContainer(arg)
+	flags := 0
+	for i := 0; i < ob.ListSize(); i++ {
+		x := ob.ListGet(i)
+		if s, ok := x.ToStr(); ok {
+			f, ok := trace.Flag(s)
+			if !ok {
+				panic("Trace: unknown flag " + s)
 			}
-			trace.Print(s + "\n")
+			flags |= f
 		} else {
-			prev := trace.Set(ToInt(args[0]))
-			if args[1] != False {
-				defer func() {
-					trace.Set(prev)
-				}()
-				return t.Call(args[1])
-			}
+			flags |= ToInt(x)
 		}
-		return nil
-	})
+	}
+	return flags
+}
+
+func (d *suTraceGlobal) Lookup(t *Thread, method string) Callable {
+	if f, ok := traceMethods[method]; ok {
+		return f
+	}
+	return d.SuBuiltin.Lookup(t, method) // for Params
+}
+
+func (d *suTraceGlobal) String() string {
+	return "Trace /* builtin class */"
+}
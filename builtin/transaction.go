@@ -8,7 +8,7 @@ import (
 	"github.com/apmckinlay/gsuneido/util/regex"
 )
 
-var _ = builtin("Transaction(read=nil, update=nil, block=false)",
+var _ = builtin("Transaction(read=nil, update=nil, block=false, snapshot=false, limits=false)",
 	func(th *Thread, args []Value) Value {
 		if (args[0] == nil) == (args[1] == nil) {
 			panic("usage: Transaction(read:) or Transaction(update:)")
@@ -19,10 +19,28 @@ var _ = builtin("Transaction(read=nil, update=nil, block=false)",
 		} else {
 			update = !ToBool(args[0])
 		}
-		itran := th.Dbms().Transaction(update)
+		if args[4] != False && !update {
+			panic("usage: Transaction(update:, limits: object)")
+		}
+		var itran ITran
+		if args[3] != False {
+			if update {
+				panic("usage: Transaction(read:, snapshot: name)")
+			}
+			name := ToStr(args[3])
+			itran = th.Dbms().SnapshotTran(name)
+			if itran == nil {
+				panic("Transaction: snapshot not found: " + name)
+			}
+		} else {
+			itran = th.Dbms().Transaction(update)
+		}
 		if itran == nil {
 			panic("too many active transactions")
 		}
+		if args[4] != False {
+			itran.SetTranLimits(ToContainer(args[4]).ToObject())
+		}
 		st := NewSuTran(itran, update)
 		if args[2] == False {
 			return st
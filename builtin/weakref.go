@@ -0,0 +1,24 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package builtin
+
+import (
+	. "github.com/apmckinlay/gsuneido/runtime"
+)
+
+var _ = builtin1("WeakRef(value)", func(arg Value) Value {
+	return NewSuWeakRef(arg)
+})
+
+func init() {
+	WeakRefMethods = Methods{
+		// Get returns false if the value has been released
+		"Get": method0(func(this Value) Value {
+			if x := this.(*SuWeakRef).Target(); x != nil {
+				return x
+			}
+			return False
+		}),
+	}
+}
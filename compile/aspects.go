@@ -4,6 +4,8 @@
 package compile
 
 import (
+	"strings"
+
 	"github.com/apmckinlay/gsuneido/compile/ast"
 	"github.com/apmckinlay/gsuneido/compile/check"
 	"github.com/apmckinlay/gsuneido/runtime"
@@ -65,6 +67,9 @@ type cgckAspects struct {
 type gogenAspects struct {
 	cgAspectsBase
 	nilChecker
+	// next and init are shared by all the functions in a constant
+	next int
+	init strings.Builder
 }
 
 // codegen defined in gogen.go
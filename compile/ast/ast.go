@@ -451,6 +451,9 @@ func childStmt(fn func(Node) Node, pstmt *Statement) {
 }
 
 func (a *Function) Children(fn func(Node) Node) {
+	for i := range a.Params {
+		childExpr(fn, &a.Params[i].DefExpr)
+	}
 	for i := range a.Body {
 		childStmt(fn, &a.Body[i])
 	}
@@ -459,13 +462,18 @@ func (a *Function) Children(fn func(Node) Node) {
 type Param struct {
 	Name   Ident // including prefix @ . _
 	DefVal Value // may be nil
+	// DefExpr is set for non-constant defaults, DefVal is then its source.
+	// It is evaluated by the function prologue if there is no argument.
+	DefExpr Expr
 	// Unused is set if the parameter was followed by /*unused*/
 	Unused bool
 }
 
 func (p *Param) String() string {
 	s := p.Name.Name
-	if p.DefVal != nil {
+	if p.DefExpr != nil {
+		s += "=" + p.DefExpr.String()
+	} else if p.DefVal != nil {
 		s += "=" + p.DefVal.String()
 	}
 	return s
@@ -626,11 +634,12 @@ func (x *TryCatch) Children(fn func(Node) Node) {
 
 type Forever struct {
 	stmtNodeT
+	Label string
 	Body Statement
 }
 
 func (x *Forever) String() string {
-	return "Forever(" + x.Body.String() + ")"
+	return labeled(x.Label, "Forever("+x.Body.String()+")")
 }
 
 func (x *Forever) Children(fn func(Node) Node) {
@@ -639,13 +648,15 @@ func (x *Forever) Children(fn func(Node) Node) {
 
 type ForIn struct {
 	stmtNodeT
+	Label string
 	Var  Ident
 	E    Expr
 	Body Statement
 }
 
 func (x *ForIn) String() string {
-	return "ForIn(" + x.Var.Name + " " + x.E.String() + "\n" + x.Body.String() + ")"
+	return labeled(x.Label,
+		"ForIn("+x.Var.Name+" "+x.E.String()+"\n"+x.Body.String()+")")
 }
 
 func (x *ForIn) Children(fn func(Node) Node) {
@@ -655,6 +666,7 @@ func (x *ForIn) Children(fn func(Node) Node) {
 
 type For struct {
 	stmtNodeT
+	Label string
 	Init []Expr
 	Cond Expr
 	Inc  []Expr
@@ -678,7 +690,7 @@ func (x *For) String() string {
 		s += sep + e.String()
 		sep = ","
 	}
-	return s + "\n" + x.Body.String() + ")"
+	return labeled(x.Label, s+"\n"+x.Body.String()+")")
 }
 
 func (x *For) Children(fn func(Node) Node) {
@@ -694,12 +706,13 @@ func (x *For) Children(fn func(Node) Node) {
 
 type While struct {
 	stmtNodeT
+	Label string
 	Cond Expr
 	Body Statement
 }
 
 func (x *While) String() string {
-	return "While(" + x.Cond.String() + " " + x.Body.String() + ")"
+	return labeled(x.Label, "While("+x.Cond.String()+" "+x.Body.String()+")")
 }
 
 func (x *While) Children(fn func(Node) Node) {
@@ -709,12 +722,13 @@ func (x *While) Children(fn func(Node) Node) {
 
 type DoWhile struct {
 	stmtNodeT
+	Label string
 	Body Statement
 	Cond Expr
 }
 
 func (x *DoWhile) String() string {
-	return "DoWhile(" + x.Body.String() + " " + x.Cond.String() + ")"
+	return labeled(x.Label, "DoWhile("+x.Body.String()+" "+x.Cond.String()+")")
 }
 
 func (x *DoWhile) Children(fn func(Node) Node) {
@@ -722,20 +736,32 @@ func (x *DoWhile) Children(fn func(Node) Node) {
 	childExpr(fn, &x.Cond)
 }
 
+// Break and Continue have an optional Label
+// to exit or continue an enclosing labeled loop
 type Break struct {
 	stmtNodeT
+	Label string
 }
 
-func (*Break) String() string {
-	return "Break"
+func (x *Break) String() string {
+	return "Break" + str.Opt(" ", x.Label)
 }
 
 type Continue struct {
 	stmtNodeT
+	Label string
+}
+
+func (x *Continue) String() string {
+	return "Continue" + str.Opt(" ", x.Label)
 }
 
-func (*Continue) String() string {
-	return "Continue"
+// labeled prefixes a loop's string with its label, if any
+func labeled(label, s string) string {
+	if label == "" {
+		return s
+	}
+	return label + ": " + s
 }
 
 type ExprStmt struct {
@@ -205,10 +205,12 @@ func (a *Function) Get(_ *Thread, m Value) Value {
 			if p.Unused && strings.TrimLeft(name, "@") != "unused" {
 				name += "/*unused*/"
 			}
-			if p.DefVal == nil {
-				ob.Add(NewSuObject([]Value{SuStr(name)}))
-			} else {
+			if p.DefExpr != nil {
+				ob.Add(NewSuObject([]Value{SuStr(name), p.DefExpr.(Value)}))
+			} else if p.DefVal != nil {
 				ob.Add(NewSuObject([]Value{SuStr(name), p.DefVal}))
+			} else {
+				ob.Add(NewSuObject([]Value{SuStr(name)}))
 			}
 		}
 		return ob
@@ -18,6 +18,7 @@ type Builder interface {
 	Trinary(cond Expr, t Expr, f Expr) Expr
 	Nary(tok Token, exprs []Expr) Expr
 	In(e Expr, exprs []Expr) Expr
+	Call(fn Expr, args []Arg) Expr
 }
 
 // Factory is a simple pass through Builder
@@ -49,3 +50,6 @@ func (Factory) Nary(tok Token, exprs []Expr) Expr {
 func (Factory) In(e Expr, exprs []Expr) Expr {
 	return &In{E: e, Exprs: exprs}
 }
+func (Factory) Call(fn Expr, args []Arg) Expr {
+	return &Call{Fn: fn, Args: args}
+}
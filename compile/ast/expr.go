@@ -399,7 +399,7 @@ func argspec(args []Arg) *ArgSpec {
 	as := ArgSpec{Nargs: byte(len(args))}
 	for _, arg := range args {
 		if arg.Name != nil {
-			as.Spec = append(as.Spec, byte(len(as.Names)))
+			as.Spec = append(as.Spec, uint16(len(as.Names)))
 			as.Names = append(as.Names, arg.Name)
 		}
 	}
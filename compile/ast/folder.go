@@ -6,6 +6,7 @@ package ast
 import (
 	tok "github.com/apmckinlay/gsuneido/compile/tokens"
 	. "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/runtime/types"
 	"github.com/apmckinlay/gsuneido/util/dnum"
 )
 
@@ -95,6 +96,95 @@ func (f Folder) foldIn(in *In) Expr {
 	return f.Constant(False)
 }
 
+func (f Folder) Call(fn Expr, args []Arg) Expr {
+	return f.foldCall(&Call{Fn: fn, Args: args})
+}
+
+// foldCall evaluates calls of pure string and number methods
+// with constant receivers and arguments e.g. "abc".Size() or 255.Hex()
+func (f Folder) foldCall(call *Call) Expr {
+	mem, ok := call.Fn.(*Mem)
+	if !ok {
+		return call
+	}
+	ob, ok := mem.E.(*Constant)
+	if !ok {
+		return call
+	}
+	m, ok := mem.M.(*Constant)
+	if !ok {
+		return call
+	}
+	meth, ok := m.Val.(SuStr)
+	if !ok {
+		return call
+	}
+	var fn Callable
+	switch ob.Val.Type() {
+	case types.String:
+		if pureStringMethods[string(meth)] {
+			fn = StringMethods[string(meth)]
+		}
+	case types.Number:
+		if pureNumberMethods[string(meth)] {
+			fn = NumMethods[string(meth)]
+		}
+	}
+	if fn == nil {
+		return call
+	}
+	args := make([]Value, len(call.Args))
+	for i, arg := range call.Args {
+		c, ok := arg.E.(*Constant)
+		if !ok || arg.Name != nil {
+			return call
+		}
+		args[i] = c.Val
+	}
+	if x := evalCall(fn, ob.Val, args); x != nil {
+		return f.Constant(x)
+	}
+	return call
+}
+
+// pureStringMethods are the builtin string methods
+// that only depend on their receiver and arguments.
+// Repeat is excluded so a large result is not built into the code.
+var pureStringMethods = map[string]bool{"Alpha?": true, "AlphaNum?": true,
+	"Asc": true, "Count": true, "Detab": true, "Entab": true, "Find": true,
+	"FindLast": true, "Has?": true, "Lower": true, "Lower?": true,
+	"NthLine": true, "Number?": true, "Numeric?": true, "Prefix?": true,
+	"Reverse": true, "Size": true, "Suffix?": true,
+	"Unescape": true, "Upper": true, "Upper?": true}
+
+// pureNumberMethods are the builtin number methods
+// that only depend on their receiver and arguments
+var pureNumberMethods = map[string]bool{"Chr": true, "Frac": true,
+	"Hex": true, "Int": true, "Round": true, "RoundDown": true,
+	"RoundUp": true}
+
+// evalCall calls a method at compile time.
+// It returns nil if the call fails, so the error happens at run time,
+// or if the result is not a simple constant.
+func evalCall(fn Callable, this Value, args []Value) (result Value) {
+	defer func() {
+		if e := recover(); e != nil {
+			result = nil
+		}
+	}()
+	if len(args) >= AsEach {
+		return nil
+	}
+	result = NewThread().CallThis(fn, this, args...)
+	if result != nil {
+		switch result.Type() {
+		case types.Boolean, types.Number, types.String:
+			return result
+		}
+	}
+	return nil
+}
+
 var allones Value = SuDnum{Dnum: dnum.FromInt(0xffffffff)}
 
 func (f Folder) Nary(token tok.Token, exprs []Expr) Expr {
@@ -176,6 +176,8 @@ func (f *fold) fold(node Node) Node { // NOT recursive
 		return f.foldIn(node)
 	case *Nary:
 		return f.foldNary(node)
+	case *Call:
+		return f.foldCall(node)
 	case *If:
 		return f.ifStmt(node)
 	}
@@ -0,0 +1,33 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package ast
+
+// Walk calls fn for node and then, if fn returns true,
+// recursively for each of its children (depth first, pre-order).
+// It is intended for tools that analyze code without modifying it.
+func Walk(node Node, fn func(Node) bool) {
+	if node == nil || !fn(node) {
+		return
+	}
+	node.Children(func(child Node) Node {
+		Walk(child, fn)
+		return child
+	})
+}
+
+// Rewrite calls fn for each child of node (bottom up, post-order)
+// and then for node itself, replacing each node with the result of fn.
+// fn should return its argument to leave a node unchanged.
+// Expressions must be replaced with expressions
+// and statements with statements (or nil to remove them).
+// Nodes are modified in place, construct new nodes with Factory.
+func Rewrite(node Node, fn func(Node) Node) Node {
+	if node == nil {
+		return nil
+	}
+	node.Children(func(child Node) Node {
+		return Rewrite(child, fn)
+	})
+	return fn(node)
+}
@@ -0,0 +1,62 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/compile"
+	"github.com/apmckinlay/gsuneido/compile/ast"
+	tok "github.com/apmckinlay/gsuneido/compile/tokens"
+	. "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestWalk(t *testing.T) {
+	f := compile.NewParser("function () { a = b + 1; if c { d } }").Function()
+	var ids []string
+	ast.Walk(f, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok {
+			ids = append(ids, id.Name)
+		}
+		return true
+	})
+	assert.T(t).This(ids).Is([]string{"a", "b", "c", "d"})
+
+	// returning false skips children
+	n := 0
+	ast.Walk(f, func(node ast.Node) bool {
+		n++
+		_, ok := node.(*ast.If)
+		return !ok
+	})
+	assert.T(t).This(n).Is(8)
+}
+
+func TestRewrite(t *testing.T) {
+	f := compile.NewParser("function () { a = b + 1; Print(b) }").Function()
+	var factory ast.Factory
+	ast.Rewrite(f, func(node ast.Node) ast.Node {
+		if id, ok := node.(*ast.Ident); ok && id.Name == "b" {
+			return factory.Binary(factory.Constant(IntVal(2)), tok.Mul,
+				factory.Constant(IntVal(3)))
+		}
+		return node
+	})
+	assert.T(t).This(f.String()).Like(`Function(
+		Binary(Eq a Nary(Add Binary(Mul 2 3) 1))
+		Call(Print Binary(Mul 2 3)))`)
+
+	// returning nil removes statements
+	ast.Rewrite(f, func(node ast.Node) ast.Node {
+		if es, ok := node.(*ast.ExprStmt); ok {
+			if _, ok := es.E.(*ast.Call); ok {
+				return nil
+			}
+		}
+		return node
+	})
+	assert.T(t).This(f.String()).Like(`Function(
+		Binary(Eq a Nary(Add Binary(Mul 2 3) 1)))`)
+}
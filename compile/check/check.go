@@ -24,6 +24,10 @@ import (
 
 type Check struct {
 	t *Thread
+	// Strict enables additional warnings
+	// e.g. block parameters that shadow outer variables
+	// and errors for legacy features e.g. dynamic variables
+	Strict bool
 	// pos is used to store the position of the current statement
 	pos int
 	// AllInit is the set of variables assigned to, including conditionally
@@ -83,8 +87,12 @@ func (ck *Check) params(params []ast.Param, init set) set {
 			name = str.UnCapitalize(name[1:])
 			ck.AllUsed[name] = struct{}{}
 		} else if name[0] == '@' || name[0] == '_' {
+			ck.dynamic(name, int(p.Name.Pos))
 			name = name[1:]
 		}
+		if p.DefExpr != nil {
+			init, _ = ck.expr(p.DefExpr, init)
+		}
 		if !p.Unused {
 			init = append(init, name)
 		}
@@ -92,6 +100,13 @@ func (ck *Check) params(params []ast.Param, init set) set {
 	return init
 }
 
+// dynamic reports dynamic variables (and parameters) when Strict
+func (ck *Check) dynamic(name string, pos int) {
+	if ck.Strict && name[0] == '_' {
+		ck.CheckResult(pos, "ERROR: dynamic variable: "+name)
+	}
+}
+
 func (ck *Check) used(id string) bool {
 	_, ok := ck.AllUsed[id]
 	return ok
@@ -304,6 +319,7 @@ func (ck *Check) expr(expr ast.Expr, init set) (initOut set, effects bool) {
 		if ascii.IsUpper(expr.Name[0]) {
 			ck.CheckGlobal(expr.Name, int(expr.Pos))
 		}
+		ck.dynamic(expr.Name, int(expr.Pos))
 	case *ast.Trinary:
 		initTrue, initFalse := ck.cond(expr.Cond, init)
 		tInit, ef1 := ck.expr(expr.T, initTrue)
@@ -326,6 +342,7 @@ func (ck *Check) expr(expr ast.Expr, init set) (initOut set, effects bool) {
 		}
 	case *ast.Call:
 		effects = true
+		ck.call(expr)
 		expr.Children(func(e ast.Node) ast.Node {
 			init, _ = ck.expr(e.(ast.Expr), init)
 			return e
@@ -342,6 +359,72 @@ func (ck *Check) expr(expr ast.Expr, init set) (initOut set, effects bool) {
 	return init, effects
 }
 
+// call checks the arguments of a call to a global function
+// against its parameters, to catch errors that would panic at run time
+func (ck *Check) call(call *ast.Call) {
+	id, ok := call.Fn.(*ast.Ident)
+	if !ok || !ascii.IsUpper(id.Name[0]) {
+		return
+	}
+	ps := paramSpec(Global.FindName(ck.t, id.Name))
+	if ps == nil ||
+		(ps.Nparams == 1 && ps.Flags[0] == AtParam) { // accepts anything
+		return
+	}
+	unnamed := 0
+	named := map[string]bool{}
+	for _, arg := range call.Args {
+		if arg.Name == nil {
+			unnamed++
+		} else if s, ok := arg.Name.(SuStr); ok {
+			if s == "@" || s == "@+1" {
+				return // can't check at compile time
+			}
+			named[string(s)] = true
+		}
+	}
+	pos := int(id.Pos)
+	if unnamed > int(ps.Nparams) {
+		ck.CheckResult(pos, "ERROR: too many arguments to "+id.Name)
+		return
+	}
+	for i := unnamed; i < int(ps.Nparams-ps.Ndefaults); i++ {
+		name := ps.ParamName(i)
+		if !named[name] && ps.Flags[i]&DynParam == 0 {
+			ck.CheckResult(pos,
+				"ERROR: missing argument "+name+" to "+id.Name)
+		}
+	}
+}
+
+// paramSpec returns the ParamSpec of a function, or nil
+// Raw builtins are excluded since they handle their own arguments
+func paramSpec(fn Value) *ParamSpec {
+	switch f := fn.(type) {
+	case *SuFunc:
+		return &f.ParamSpec
+	case *SuBuiltin:
+		return &f.ParamSpec
+	case *SuBuiltin0:
+		return &f.ParamSpec
+	case *SuBuiltin1:
+		return &f.ParamSpec
+	case *SuBuiltin2:
+		return &f.ParamSpec
+	case *SuBuiltin3:
+		return &f.ParamSpec
+	case *SuBuiltin4:
+		return &f.ParamSpec
+	case *SuBuiltin5:
+		return &f.ParamSpec
+	case *SuBuiltin6:
+		return &f.ParamSpec
+	case *SuBuiltin7:
+		return &f.ParamSpec
+	}
+	return nil
+}
+
 func (ck *Check) block(b *ast.Block, init set) set {
 	// save & remove variables shadowed by params
 	allInit := map[string]int{}
@@ -352,6 +435,10 @@ func (ck *Check) block(b *ast.Block, init set) set {
 		if !p.Unused {
 			nUsedParams++
 		}
+		if ck.Strict && id != "it" && init.has(id) {
+			ck.CheckResult(int(p.Name.Pos),
+				"WARNING: block parameter shadows: "+id)
+		}
 		if n, ok := ck.AllInit[id]; ok {
 			allInit[id] = n
 			delete(ck.AllInit, id)
@@ -400,7 +487,11 @@ func (ck *Check) block(b *ast.Block, init set) set {
 }
 
 func (ck *Check) initVar(init set, id string, pos int) set {
-	if strings.HasPrefix(id, "_") || id == "unused" {
+	if strings.HasPrefix(id, "_") {
+		ck.dynamic(id, pos)
+		return init
+	}
+	if id == "unused" {
 		return init
 	}
 	ck.AllInit[id] = pos
@@ -195,6 +195,11 @@ func TestCheckResults(t *testing.T) {
 		"ERROR: useless expression @33")
 	test("function (x) { try x+0 catch x=0 }")
 
+	// destructuring
+	test("function (ob) { [a, b] = ob; a $ b }")
+	test("function (ob) { [x: a, y: b] = ob; a }",
+		"WARNING: initialized but not used: b @26")
+
 	// guard clause
 	test("function (f) { if (f()) { x=f } else { return } x  }")
 	test("function (f) { if (f()) { return } else { x=f } x  }")
@@ -238,3 +243,55 @@ func TestCheckResults(t *testing.T) {
 	test(`function (f, x) { f(x
 		-1) }`, "ERROR: missing comma @24")
 }
+
+func TestCheckCall(t *testing.T) {
+	runtime.Global.TestDef("Ck_F", compile.Constant("function (a, b = 1) { }"))
+	runtime.Global.TestDef("Ck_G", compile.Constant("function (@args) { }"))
+	runtime.Global.TestDef("Ck_D", compile.Constant("function (_x) { }"))
+	test := func(src string, expected ...string) {
+		t.Helper()
+		_, results := compile.Checked(nil, src)
+		assert.T(t).This(results).Is(expected)
+	}
+	test("function () { Ck_F(1) }")
+	test("function () { Ck_F(1, 2) }")
+	test("function () { Ck_F(a: 1) }")
+	test("function () { Ck_F(b: 2, a: 1) }")
+	test("function (x) { Ck_F(@x) }")
+	test("function () { Ck_F(1, 2, 3) }",
+		"ERROR: too many arguments to Ck_F @14")
+	test("function () { Ck_F() }",
+		"ERROR: missing argument a to Ck_F @14")
+	test("function () { Ck_F(b: 2) }",
+		"ERROR: missing argument a to Ck_F @14")
+	test("function () { Ck_G(1, 2, 3, a: 4) }")
+	test("function () { Ck_D() }")
+}
+
+func TestCheckStrict(t *testing.T) {
+	test := func(src string, expected ...string) {
+		t.Helper()
+		_, results := compile.StrictChecked(nil, src)
+		assert.T(t).This(results).Is(expected)
+	}
+	test("function (f) { f({|x| x }) }")
+	test("function (f) { x=1; f({|x| x }); x }",
+		"WARNING: block parameter shadows: x @24")
+	test("function (f, x) { f({|x| x }); x }",
+		"WARNING: block parameter shadows: x @22")
+	test("function (f) { it=1; f({ it }); it }")
+	test("function () { _x = 1; _x }",
+		"ERROR: dynamic variable: _x @14",
+		"ERROR: dynamic variable: _x @22")
+	test("function (_x) { x }",
+		"ERROR: dynamic variable: _x @10")
+}
+
+func TestCompileStrict(t *testing.T) {
+	fn := compile.CompileStrict(nil, "function (f) { x=1; f({|x| x }); x }")
+	assert.T(t).That(fn != nil)
+	assert.T(t).This(func() { compile.CompileStrict(nil, "function () { _x }") }).
+		Panics("compile error: dynamic variable: _x @14")
+	assert.T(t).This(func() { compile.CompileStrict(nil, "function () { Ck_Nonexistent }") }).
+		Panics("compile error: can't find: Ck_Nonexistent @14")
+}
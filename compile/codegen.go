@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"sync/atomic"
 
 	"github.com/apmckinlay/gsuneido/compile/ast"
@@ -16,6 +17,7 @@ import (
 	"github.com/apmckinlay/gsuneido/options"
 	. "github.com/apmckinlay/gsuneido/runtime"
 	op "github.com/apmckinlay/gsuneido/runtime/opcodes"
+	"github.com/apmckinlay/gsuneido/runtime/types"
 	"github.com/apmckinlay/gsuneido/util/assert"
 	"github.com/apmckinlay/gsuneido/util/hacks"
 	"github.com/apmckinlay/gsuneido/util/ints"
@@ -41,6 +43,14 @@ type cgen struct {
 	codePrev  int
 	cover     bool
 	coverEmit bool
+	// loops is the stack of enclosing loops, for labeled break and continue
+	loops []loopLabel
+}
+
+type loopLabel struct {
+	label  string
+	labels *Labels
+	forIn  bool
 }
 
 type calltype int
@@ -86,7 +96,7 @@ func (cg *cgen) codegen(fn *ast.Function) *SuFunc {
 
 	return &SuFunc{
 		Code:      hacks.BStoS(cg.code),
-		Nlocals:   uint8(len(cg.Names)),
+		Nlocals:   uint16(len(cg.Names)),
 		ParamSpec: cg.ParamSpec,
 		ArgSpecs:  cg.argspecs, //TODO shrink to fit
 		SrcPos:    hacks.BStoS(cg.srcPos),
@@ -107,8 +117,8 @@ func codegenClosureBlock(ast *ast.Function, outercg *cgen) (*SuFunc, []string) {
 	// hide parameters from outer function
 	outerNames := f.Names
 	f.Names = make([]string, len(outerNames))
-	assert.That(base <= math.MaxUint8)
-	f.Offset = uint8(base)
+	assert.That(base <= math.MaxUint16)
+	f.Offset = uint16(base)
 	copy(f.Names, outerNames)
 	for i := 0; i < int(f.Nparams); i++ {
 		outerNames[base+i] += "|" + strconv.Itoa(base+i)
@@ -177,6 +187,7 @@ var tok2op = [tok.Ntokens]op.Opcode{
 func (cg *cgen) function(fn *ast.Function) {
 	cg.params(fn.Params)
 	cg.chainNew(fn)
+	cg.paramDefaults(fn.Params)
 	stmts := fn.Body
 	cg.firstStatement = true
 	for si, stmt := range stmts {
@@ -186,12 +197,18 @@ func (cg *cgen) function(fn *ast.Function) {
 }
 
 func (cg *cgen) params(params []ast.Param) {
+	if len(params) > math.MaxUint8 {
+		panic("too many parameters (>255)")
+	}
 	cg.Nparams = uint8(len(params))
 	for _, p := range params {
 		name, flags := param(p.Name.Name)
 		if flags == AtParam && len(params) != 1 {
 			panic("@param must be the only parameter")
 		}
+		if p.DefExpr != nil {
+			flags |= ExprParam
+		}
 		cg.Names = append(cg.Names, name) // no duplicate reuse
 		cg.Flags = append(cg.Flags, flags)
 		if p.DefVal != nil {
@@ -201,6 +218,22 @@ func (cg *cgen) params(params []ast.Param) {
 	}
 }
 
+// paramDefaults is the prologue that evaluates
+// non-constant parameter defaults for missing arguments
+func (cg *cgen) paramDefaults(params []ast.Param) {
+	for i, p := range params {
+		if p.DefExpr != nil {
+			cg.savePos(int(p.Name.Pos))
+			set := cg.emitJump(op.JumpSet, -1)
+			cg.emitMore(byte(i)) // params() limits the number of params
+			cg.expr(p.DefExpr)
+			cg.store(i)
+			cg.emit(op.Pop)
+			cg.placeLabel(set)
+		}
+	}
+}
+
 func (cg *cgen) chainNew(fn *ast.Function) {
 	if !fn.IsNewMethod || hasSuperCall(fn.Body) || cg.base <= 0 {
 		return
@@ -304,9 +337,9 @@ func (cg *cgen) statement(node ast.Statement, labels *Labels, lastStmt bool) {
 	case *ast.TryCatch:
 		cg.tryCatchStmt(node, labels)
 	case *ast.Break:
-		cg.breakStmt(labels)
+		cg.breakStmt(cg.loopLabels(node.Label, labels, "break"))
 	case *ast.Continue:
-		cg.continueStmt(labels)
+		cg.continueStmt(cg.loopLabels(node.Label, labels, "continue"))
 	case *ast.ExprStmt:
 		cg.exprStmt(node.E, lastStmt)
 	default:
@@ -351,6 +384,24 @@ func (cg *cgen) breakStmt(labels *Labels) {
 	}
 }
 
+// loopLabels returns the Labels of the loop with the given label.
+// It pops the iterators of any for-in loops that will be exited.
+// If label is "" it returns the labels of the innermost loop.
+func (cg *cgen) loopLabels(label string, labels *Labels, which string) *Labels {
+	if label == "" {
+		return labels
+	}
+	for i := len(cg.loops) - 1; i >= 0; i-- {
+		if cg.loops[i].label == label {
+			return cg.loops[i].labels
+		}
+		if cg.loops[i].forIn {
+			cg.emit(op.Pop)
+		}
+	}
+	panic(which + ": label not found: " + label)
+}
+
 func (cg *cgen) continueStmt(labels *Labels) {
 	if labels != nil {
 		if labels.cont != -1 && labels.cont < len(cg.code) {
@@ -381,6 +432,10 @@ func (cg *cgen) ifStmt(node *ast.If, labels *Labels) {
 
 func (cg *cgen) switchStmt(node *ast.Switch, labels *Labels) {
 	cg.expr(node.E)
+	if lo, n := jumpTableRange(node); n > 0 {
+		cg.switchTable(node, labels, lo, n)
+		return
+	}
 	end := -1
 	for _, c := range node.Cases {
 		caseBody, afterCase := -1, -1
@@ -407,9 +462,77 @@ func (cg *cgen) switchStmt(node *ast.Switch, labels *Labels) {
 	cg.placeLabel(end)
 }
 
+// minJumpTable is the minimum number of case values to use a JumpTable
+const minJumpTable = 4
+
+// jumpTableRange returns the range of the case values
+// if they are all integer constants dense enough for a JumpTable,
+// otherwise it returns n = 0
+func jumpTableRange(node *ast.Switch) (lo, n int) {
+	lo, hi, count := math.MaxInt, math.MinInt, 0
+	for _, c := range node.Cases {
+		for _, e := range c.Exprs {
+			i, ok := caseInt(e)
+			if !ok {
+				return 0, 0
+			}
+			lo = ints.Min(lo, i)
+			hi = ints.Max(hi, i)
+			count++
+		}
+	}
+	n = hi - lo + 1
+	if count < minJumpTable || n > 2*count || n > math.MaxUint8 ||
+		lo < math.MinInt16 || lo > math.MaxInt16 {
+		return 0, 0
+	}
+	return lo, n
+}
+
+func caseInt(e ast.Expr) (int, bool) {
+	if c, ok := e.(*ast.Constant); ok && c.Val.Type() == types.Number {
+		return c.Val.IfInt()
+	}
+	return 0, false
+}
+
+// switchTable generates a JumpTable followed by the default
+// and then the case bodies.
+// Table entries that do not match a case jump to the default (offset 0).
+// If a value occurs in more than one case, the first one is used.
+func (cg *cgen) switchTable(node *ast.Switch, labels *Labels, lo, n int) {
+	cg.emit(op.JumpTable, byte(lo>>8), byte(lo), byte(n))
+	table := len(cg.code)
+	cg.code = append(cg.code, make([]byte, 2*n)...)
+	base := len(cg.code)
+	if node.Default != nil { // specifically nil and not len 0
+		cg.statements(node.Default, labels)
+	} else {
+		cg.emitValue(SuStr("unhandled switch value"))
+		cg.emit(op.Throw)
+	}
+	end := cg.emitJump(op.Jump, -1)
+	done := make([]bool, n)
+	for _, c := range node.Cases {
+		body := cg.label() - base
+		assert.That(body <= math.MaxInt16)
+		for _, e := range c.Exprs {
+			i, _ := caseInt(e)
+			if i -= lo; !done[i] {
+				done[i] = true
+				cg.code[table+2*i] = byte(body >> 8)
+				cg.code[table+2*i+1] = byte(body)
+			}
+		}
+		cg.statements(c.Body, labels)
+		end = cg.emitJump(op.Jump, end)
+	}
+	cg.placeLabel(end)
+}
+
 func (cg *cgen) foreverStmt(node *ast.Forever) {
 	labels := cg.newLabels()
-	cg.statement(node.Body, labels, false)
+	cg.loopBody(node.Body, loopLabel{label: node.Label, labels: labels})
 	cg.emitJump(op.Jump, labels.cont-len(cg.code)-3)
 	cg.placeLabel(labels.brk)
 }
@@ -418,7 +541,7 @@ func (cg *cgen) whileStmt(node *ast.While) {
 	labels := cg.newLabels()
 	cond := cg.emitJump(op.Jump, -1)
 	loop := cg.label()
-	cg.statement(node.Body, labels, false)
+	cg.loopBody(node.Body, loopLabel{label: node.Label, labels: labels})
 	cg.placeLabel(cond)
 	cg.expr(node.Cond)
 	cg.emitBwdJump(op.JumpTrue, loop)
@@ -428,7 +551,7 @@ func (cg *cgen) whileStmt(node *ast.While) {
 func (cg *cgen) dowhileStmt(node *ast.DoWhile) {
 	labels := &Labels{brk: -1, cont: -1}
 	loop := cg.label()
-	cg.statement(node.Body, labels, false)
+	cg.loopBody(node.Body, loopLabel{label: node.Label, labels: labels})
 	cg.placeLabel(labels.cont)
 	cg.expr(node.Cond)
 	cg.emitBwdJump(op.JumpTrue, loop)
@@ -443,7 +566,7 @@ func (cg *cgen) forStmt(node *ast.For) {
 		cond = cg.emitJump(op.Jump, -1)
 	}
 	loop := cg.label()
-	cg.statement(node.Body, labels, false)
+	cg.loopBody(node.Body, loopLabel{label: node.Label, labels: labels})
 	cg.placeLabel(labels.cont)
 	cg.exprList(node.Inc) // increment
 	if node.Cond == nil {
@@ -461,23 +584,43 @@ func (cg *cgen) forInStmt(node *ast.ForIn) {
 	cg.emit(op.Iter)
 	labels := cg.newLabels()
 	cg.emitForIn(node.Var.Name, labels)
-	cg.statement(node.Body, labels, false)
+	cg.loopBody(node.Body,
+		loopLabel{label: node.Label, labels: labels, forIn: true})
 	cg.emitBwdJump(op.Jump, labels.cont)
 	cg.placeLabel(labels.brk)
 	cg.emit(op.Pop)
 }
 
+// loopBody generates the body of a loop,
+// tracking it so labeled break and continue can find it
+func (cg *cgen) loopBody(body ast.Statement, lp loopLabel) {
+	cg.loops = append(cg.loops, lp)
+	cg.statement(body, lp.labels, false)
+	cg.loops = cg.loops[:len(cg.loops)-1]
+}
+
 func (cg *cgen) emitForIn(name string, labels *Labels) {
 	i := cg.name(name)
 	adr := len(cg.code)
-	cg.emit(op.ForIn, byte(labels.brk>>8), byte(labels.brk), byte(i))
+	if i <= math.MaxUint8 {
+		cg.emit(op.ForIn, byte(labels.brk>>8), byte(labels.brk), byte(i))
+	} else {
+		cg.emit(op.ForIn16, byte(labels.brk>>8), byte(labels.brk),
+			byte(i>>8), byte(i))
+	}
 	labels.brk = adr
 }
 
 func (cg *cgen) tryCatchStmt(node *ast.TryCatch, labels *Labels) {
 	cg.coverEmit = false
-	catch := cg.emitJump(op.Try, -1)
-	cg.emitMore(byte(cg.value(SuStr(node.CatchFilter))))
+	var catch int
+	if i := cg.value(SuStr(node.CatchFilter)); i <= math.MaxUint8 {
+		catch = cg.emitJump(op.Try, -1)
+		cg.emitMore(byte(i))
+	} else {
+		catch = cg.emitJump(op.Try16, -1)
+		cg.emitMore(byte(i>>8), byte(i))
+	}
 	cg.statement(node.Try, labels, false)
 	after := cg.emitJump(op.Catch, -1)
 	cg.placeLabel(catch)
@@ -485,7 +628,7 @@ func (cg *cgen) tryCatchStmt(node *ast.TryCatch, labels *Labels) {
 		cg.savePos(node.CatchPos)
 	}
 	if node.CatchVar.Name != "" {
-		cg.emit(op.Store, byte(cg.name(node.CatchVar.Name)))
+		cg.store(cg.name(node.CatchVar.Name))
 	}
 	cg.emit(op.Pop)
 	if node.Catch != nil {
@@ -536,6 +679,15 @@ func (cg *cgen) expr2(node ast.Expr, ct calltype) {
 		cg.trinary(node, ct)
 	case *ast.Mem:
 		cg.expr(node.E)
+		// GetMem is the only superinstruction. Fusing Load with GetMem
+		// did not measurably help (OpGet dominates), Value+CallFunc is rare
+		// (globals use op.Global), and Load+Load+Add saves little
+		if c, ok := node.M.(*ast.Constant); ok {
+			if i := cg.value(c.Val); i <= math.MaxUint8 {
+				cg.emitUint8(op.GetMem, i)
+				break
+			}
+		}
 		cg.expr(node.M)
 		cg.emit(op.Get)
 	case *ast.RangeTo:
@@ -573,9 +725,9 @@ func (cg *cgen) identifier(node *ast.Ident) {
 	} else if isLocal(node.Name) {
 		i := cg.name(node.Name)
 		if node.Name[0] == '_' {
-			cg.emitUint8(op.Dyload, i)
+			cg.emitWide(op.Dyload, op.Dyload16, i)
 		} else {
-			cg.emitUint8(op.Load, i)
+			cg.emitWide(op.Load, op.Load16, i)
 		}
 	} else if node.Name[0] == '_' {
 		val := Global.GetIfPresent(node.Name[1:])
@@ -583,11 +735,53 @@ func (cg *cgen) identifier(node *ast.Ident) {
 			panic("can't find " + node.Name)
 		}
 		cg.emitValue(val)
+	} else if val := cg.constGlobal(node.Name); val != nil {
+		cg.emitValue(val)
 	} else {
 		cg.emitUint16(op.Global, Global.Num(node.Name))
 	}
 }
 
+// constGlobal returns the value of a global if it is already loaded
+// and is a simple immutable constant that can be inlined, otherwise nil.
+// The library record being compiled is recorded as depending on it
+// so that it will be unloaded (and recompiled) if the constant is unloaded.
+// Code that is not from a library is not inlined
+// since it could not be invalidated.
+func (cg *cgen) constGlobal(name string) Value {
+	if cg.Lib == "" {
+		return nil
+	}
+	top := topName(cg.Name)
+	if top == "" || name == top {
+		return nil
+	}
+	val := Global.GetIfPresent(name)
+	if val == nil {
+		return nil
+	}
+	switch val.Type() {
+	case types.Boolean, types.Number, types.String, types.Date:
+	case types.Object:
+		if ob, ok := val.(*SuObject); !ok || ob.IsConcurrent() == False {
+			return nil // mutable
+		}
+	default:
+		return nil
+	}
+	Global.Depend(name, top)
+	return val
+}
+
+// topName returns the library record name from a function name
+// which may be e.g. Name.Method or Name assignName
+func topName(name string) string {
+	if i := strings.IndexAny(name, ". "); i != -1 {
+		return name[:i]
+	}
+	return name
+}
+
 // includes dynamic
 func isLocal(s string) bool {
 	if s[0] == '_' && len(s) > 1 {
@@ -605,8 +799,8 @@ func (cg *cgen) name(s string) int {
 		}
 	}
 	i := len(cg.Names)
-	if i > math.MaxUint8 {
-		panic("too many local variables (>255)")
+	if i > math.MaxUint16 {
+		panic("too many local variables (>65535)")
 	}
 	cg.Names = append(cg.Names, s)
 	return i
@@ -638,7 +832,7 @@ func (cg *cgen) unary(node *ast.Unary, ct calltype) {
 			cg.emit(op.GetPut, i)
 		} else { // local
 			cg.handleDynamic(ref)
-			cg.emitUint8(op.LoadStore, ref)
+			cg.emitWide(op.LoadStore, op.LoadStore16, ref)
 			cg.emitMore(i)
 		}
 	} else {
@@ -674,7 +868,7 @@ func (cg *cgen) binary(node *ast.Binary) {
 			cg.emit(op.GetPut, i)
 		} else { // local
 			cg.handleDynamic(ref)
-			cg.emitUint8(op.LoadStore, ref)
+			cg.emitWide(op.LoadStore, op.LoadStore16, ref)
 			cg.emitMore(i)
 		}
 	case tok.Match, tok.MatchNot:
@@ -696,7 +890,7 @@ func (cg *cgen) binary(node *ast.Binary) {
 
 func (cg *cgen) handleDynamic(ref int) {
 	if cg.Names[ref][0] == '_' {
-		cg.emitUint8(op.Dyload, ref)
+		cg.emitWide(op.Dyload, op.Dyload16, ref)
 		cg.emit(op.Pop)
 	}
 }
@@ -815,7 +1009,7 @@ func (cg *cgen) emitValue(val Value) {
 	} else if i, ok := SuIntToInt(val); ok {
 		cg.emitInt16(op.Int, i)
 	} else {
-		cg.emitUint8(op.Value, cg.value(val))
+		cg.emitWide(op.Value, op.Value16, cg.value(val))
 	}
 }
 
@@ -829,8 +1023,8 @@ func (cg *cgen) value(v Value) int {
 		}
 	}
 	i := len(cg.Values)
-	if i > math.MaxUint8 {
-		panic("too many constants (>255)")
+	if i > math.MaxUint16 {
+		panic("too many constants (>65535)")
 	}
 	cg.Values = append(cg.Values, v)
 	return i
@@ -858,9 +1052,9 @@ func (cg *cgen) load(ref int) {
 		cg.emit(op.Get)
 	} else {
 		if cg.Names[ref][0] == '_' {
-			cg.emitUint8(op.Dyload, ref)
+			cg.emitWide(op.Dyload, op.Dyload16, ref)
 		} else {
-			cg.emitUint8(op.Load, ref)
+			cg.emitWide(op.Load, op.Load16, ref)
 		}
 	}
 }
@@ -869,7 +1063,7 @@ func (cg *cgen) store(ref int) {
 	if ref == memRef {
 		cg.emit(op.Put)
 	} else {
-		cg.emitUint8(op.Store, ref)
+		cg.emitWide(op.Store, op.Store16, ref)
 	}
 }
 
@@ -934,11 +1128,10 @@ func (cg *cgen) args(args []ast.Arg) int {
 			return AsEach1
 		}
 	}
-	var spec []byte
+	var spec []uint16
 	for _, arg := range args {
 		if arg.Name != nil {
-			i := cg.value(arg.Name)
-			spec = append(spec, byte(i))
+			spec = append(spec, uint16(cg.value(arg.Name)))
 		}
 		cg.expr(arg.E)
 	}
@@ -987,8 +1180,7 @@ func (cg *cgen) block(b *ast.Block) {
 	} else {
 		// closure
 		fn, cg.Names = codegenClosureBlock(f, cg)
-		i := cg.value(fn)
-		cg.emitUint8(op.Closure, i)
+		cg.emitWide(op.Closure, op.Closure16, cg.value(fn))
 	}
 	fn.IsBlock = true
 }
@@ -1013,17 +1205,26 @@ func (cg *cgen) emitMore(b ...byte) {
 }
 
 func (cg *cgen) emitUint8(op op.Opcode, i int) {
-	assert.That(0 <= i && i < math.MaxUint8)
+	assert.That(0 <= i && i <= math.MaxUint8)
 	cg.emit(op, byte(i))
 }
 
+// emitWide emits op with a uint8 index if possible, else wide with a uint16
+func (cg *cgen) emitWide(op, wide op.Opcode, i int) {
+	if i <= math.MaxUint8 {
+		cg.emit(op, byte(i))
+	} else {
+		cg.emitUint16(wide, i)
+	}
+}
+
 func (cg *cgen) emitInt16(op op.Opcode, i int) {
 	assert.That(math.MinInt16 <= i && i <= math.MaxInt16)
 	cg.emit(op, byte(i>>8), byte(i))
 }
 
 func (cg *cgen) emitUint16(op op.Opcode, i int) {
-	assert.That(0 <= i && i < math.MaxUint16)
+	assert.That(0 <= i && i <= math.MaxUint16)
 	cg.emit(op, byte(i>>8), byte(i))
 }
 
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/apmckinlay/gsuneido/compile/ast"
+	"github.com/apmckinlay/gsuneido/options"
 	. "github.com/apmckinlay/gsuneido/runtime"
 	"github.com/apmckinlay/gsuneido/util/assert"
 	"github.com/apmckinlay/gsuneido/util/strs"
@@ -67,8 +68,10 @@ func TestCodegen(t *testing.T) {
 	test("s =~ '^a'", "Load s, Value SuRegex, Match")
 	test("++n", "One, LoadStore n AddEq")
 	test("n--", "One, LoadStore n SubEq retOrig")
-	test("a.b", "Load a, Value 'b', Get")
-	test("a[2]", "Load a, Int 2, Get")
+	test("a.b", "Load a, GetMem 'b'")
+	test("a[2]", "Load a, GetMem 2")
+	test("a.b.c", "Load a, GetMem 'b', GetMem 'c'")
+	test("a[b]", "Load a, Load b, Get")
 	test("a.b = 123", "Load a, Value 'b', Int 123, Put")
 	test("a[2] = false", "Load a, Int 2, False, Put")
 	test("a.b += 5", "Load a, Value 'b', Int 5, GetPut AddEq")
@@ -127,6 +130,15 @@ func TestCodegen(t *testing.T) {
 	test("new c", "Load c, Value '*new*', CallMethNilOk ()")
 	test("new c()", "Load c, Value '*new*', CallMethNilOk ()")
 	test("new c(1)", "Load c, One, Value '*new*', CallMethNilOk (?)")
+
+	// constant folding
+	test("60 * 60 * 24", "Value 86400")
+	test("x = 60 * 60; x * 24", "Value 86400") // with propagation
+	test("'a' $ 'b' $ 'c'", "Value 'abc'")
+	test("a $ 'b' $ 'c'", "Load a, Value 'bc', Cat")
+	test("not (1 < 2)", "False")
+	test("true and 2 > 1", "True")
+	test("f(1 + 2, 'x' $ 'y')", "Int 3, Value 'xy', Load f, CallFuncNilOk (?, ?)")
 }
 
 func TestCodegenSuper(t *testing.T) {
@@ -384,6 +396,21 @@ func TestControl(t *testing.T) {
         20: Pop
         21: Jump 25
         24: Pop`)
+	test("switch a { case 1,2: b case 4: c case 5,1: d default: e }", `
+		0: Load a
+		2: JumpTable 1 22 22 16 28 34
+		16: Load e
+		18: Pop
+		19: Jump 40
+		22: Load b
+		24: Pop
+		25: Jump 40
+		28: Load c
+		30: Pop
+		31: Jump 40
+		34: Load d
+		36: Pop
+		37: Jump 40`)
 
 	test("forever { break }", `
 		0: Jump 6
@@ -500,3 +527,45 @@ func parseFunction(src string) *ast.Function {
 	p := NewParser(src)
 	return p.Function()
 }
+
+func TestInterpolateCodegen(t *testing.T) {
+	options.LangVersion = 2
+	defer func() { options.LangVersion = 1 }()
+	test := func(src, manual string) {
+		t.Helper()
+		fn := func(src string) string {
+			return disasm(Constant("function (a, b) {\n" + src + "\n}").(*SuFunc))
+		}
+		assert.T(t).This(fn(src)).Is(fn(manual))
+	}
+	test(`"a $(b) c"`, `"a " $ (b) $ " c"`)
+	test(`"$(a + b)$(b)"`, `"" $ (a + b) $ (b)`)
+	test(`"$('x')y"`, `"xy"`)
+}
+
+func TestCodegenConstGlobal(t *testing.T) {
+	Global.TestDef("Cg_Pi", NumFromString("3.14"))
+	Global.TestDef("Cg_Ob", &SuObject{})
+	test := func(name, src, expected string) *SuFunc {
+		t.Helper()
+		fn := NamedConstant("lib", name, src).(*SuFunc)
+		assert.T(t).This(disasm(fn)).Is(expected)
+		return fn
+	}
+	test("", "function () { Cg_Pi }", "Global Cg_Pi")
+	test("Cg_Pi", "function () { Cg_Pi }", "Global Cg_Pi")
+	Global.Set(Global.Num("Cg_Fn"),
+		test("Cg_Fn", "function () { Cg_Pi }", "Value 3.14"))
+	Global.Set(Global.Num("Cg_Fn2"),
+		test("Cg_Fn2", "function () { Cg_Ob }", "Global Cg_Ob")) // mutable
+	cls := NamedConstant("lib", "Cg_Cls",
+		"class { F() { x = function () { Cg_Pi }; Cg_Pi } }")
+	Global.Set(Global.Num("Cg_Cls"), cls)
+	Global.Unload("Cg_Pi")
+	assert.T(t).That(Global.GetIfPresent("Cg_Fn") == nil)
+	assert.T(t).That(Global.GetIfPresent("Cg_Fn2") != nil)
+	assert.T(t).That(Global.GetIfPresent("Cg_Cls") == nil)
+	Global.TestDef("Cg_Pi", NumFromString("3.14"))
+	fn := Constant("function () { Cg_Pi }").(*SuFunc) // not a library record
+	assert.T(t).This(disasm(fn)).Is("Global Cg_Pi")
+}
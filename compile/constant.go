@@ -33,7 +33,31 @@ func NamedConstant(lib, name, src string) Value {
 
 // can't do AST check after compile because that would miss nested functions
 func Checked(t *Thread, src string) (Value, []string) {
+	return checked(CheckParser(src, t))
+}
+
+// StrictChecked is like Checked but with additional warnings
+func StrictChecked(t *Thread, src string) (Value, []string) {
 	p := CheckParser(src, t)
+	p.Aspects.(*cgckAspects).Strict = true
+	return checked(p)
+}
+
+// CompileStrict is like Constant but it does strict checking
+// and panics if there are any errors (warnings are ignored).
+// It is intended to help migrate code away from legacy features
+// like dynamic variables and references to undefined globals.
+func CompileStrict(t *Thread, src string) Value {
+	v, results := StrictChecked(t, src)
+	for _, r := range results {
+		if strings.HasPrefix(r, "ERROR: ") {
+			panic("compile error: " + r[len("ERROR: "):])
+		}
+	}
+	return v
+}
+
+func checked(p *Parser) (Value, []string) {
 	v := p.constant()
 	if p.Token != tok.Eof {
 		p.Error("did not parse all input")
@@ -41,6 +65,29 @@ func Checked(t *Thread, src string) (Value, []string) {
 	return v, p.CheckResults()
 }
 
+// SyntaxErrors parses src (without generating code)
+// and returns all the syntax errors, or nil if there are none.
+// Within functions it recovers from errors at statement boundaries.
+// Errors outside of function bodies stop parsing.
+func SyntaxErrors(src string) (errs []string) {
+	p := AstParser(src)
+	p.recovering = true
+	defer func() {
+		if e := recover(); e != nil {
+			s, ok := e.(string)
+			if !ok || !strings.HasPrefix(s, "syntax error") {
+				panic(e)
+			}
+			errs = append(p.errors, s)
+		}
+	}()
+	p.constant()
+	if p.Token != tok.Eof {
+		p.Error("did not parse all input")
+	}
+	return p.errors
+}
+
 func (p *Parser) Const() Value {
 	return p.constant()
 }
@@ -105,7 +152,15 @@ func (p *Parser) functionValue() Value {
 	ast := p.Function()
 	p.className = prevClassName
 	p.CheckFunc(ast)
-	return p.codegen(p.lib, p.name, ast)
+	return p.setLines(p.codegen(p.lib, p.name, ast))
+}
+
+// setLines records line number information in compiled functions
+func (p *Parser) setLines(v Value) Value {
+	if fn, ok := v.(*SuFunc); ok {
+		fn.SetLines(p.Lxr.Source())
+	}
+	return v
 }
 
 // string handles compile time concatenation
@@ -200,7 +255,7 @@ func (p *Parser) member(ob container, closing tok.Token, base Gnum) {
 			ast.IsNewMethod = true
 		}
 		p.CheckFunc(ast)
-		fn := p.codegen(p.lib, p.name, ast)
+		fn := p.setLines(p.codegen(p.lib, p.name, ast))
 		p.name = prevName
 		if f, ok := fn.(*SuFunc); ok {
 			f.ClassName = p.className
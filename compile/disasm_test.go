@@ -124,3 +124,61 @@ func TestDisasm(t *testing.T) {
 			   13: Load e
 			   15: Return`)
 }
+
+func TestDisasmDetail(t *testing.T) {
+	DefaultSingleQuotes = true
+	defer func() { DefaultSingleQuotes = false }()
+	fn := Constant(`function (a, b = 5)
+		{
+		for x in a
+			{
+			if x > b
+				break
+			F(x, y: b)
+			}
+		return { it + b }
+		}`).(*SuFunc)
+	assert.T(t).This(DisasmDetail(fn)).Like(`
+		/* function */
+		params: (a,b=5)
+		locals: a, b, x, it|3
+		values:
+			0: 5
+			1: 'y'
+			2: /* block */
+		argspecs:
+			8: (?, y:)
+		code:
+		line 3
+			0: Load a
+			2: Iter
+		L1:
+			3: ForIn x L3
+		line 5
+			7: Load x
+			9: Load b
+			11: Gt
+			12: JumpFalse L2
+		line 6
+			15: Jump L3
+		L2:
+		line 7
+			18: Load x
+			20: Load b
+			22: Global F
+			25: CallFuncDiscard (?, y:)
+			27: Jump L1
+		L3:
+			30: Pop
+		line 9
+			31: Closure
+
+		value 2: /* block */
+		params: (it)
+		locals: a, b, x, it
+		code:
+		line 9
+			0: Load it
+			2: Load b
+			4: Add`)
+}
@@ -0,0 +1,490 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package compile
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/options"
+	. "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+// exec compiles and runs the body of a function
+// (without builtins, so only the core language is available)
+func exec(src string) Value {
+	fn := Constant("function () {\n" + src + "\n}").(*SuFunc)
+	return NewThread().Invoke(fn, nil)
+}
+
+func TestForIn(t *testing.T) {
+	test := func(src string, expected Value) {
+		t.Helper()
+		assert.T(t).This(exec(src)).Is(expected)
+	}
+	test(`s = ''; for x in #(a, b, c) { s $= x }; s`, SuStr("abc"))
+	test(`s = ''; for (x in #(a, b, c)) s $= x; s`, SuStr("abc"))
+	test(`n = 0; for x in #() { ++n }; n`, Zero)
+	test(`s = ''; for c in 'hello' { s = c $ s }; s`, SuStr("olleh"))
+	test(`s = ''
+		for x in #(1, 2, 3, 4, 5)
+			{
+			if x is 2
+				continue
+			if x is 4
+				break
+			s $= x
+			}
+		s`, SuStr("13"))
+	// nested loops with break and continue in the inner loop
+	test(`s = ''
+		for x in #(a, b)
+			for y in #(1, 2, 3)
+				{
+				if y is 2
+					continue
+				s $= x $ y
+				}
+		s`, SuStr("a1a3b1b3"))
+	test(`s = ''
+		for x in #(a, b)
+			{
+			for y in #(1, 2, 3)
+				{
+				if y is 2
+					break
+				s $= x $ y
+				}
+			s $= '.'
+			}
+		s`, SuStr("a1.b1."))
+	// return from inside the loop
+	test(`for x in #(1, 2, 3)
+			if x is 2
+				return x
+		return false`, SuInt(2))
+}
+
+func TestForInUserIter(t *testing.T) {
+	test := func(src string, expected Value) {
+		t.Helper()
+		assert.T(t).This(exec(src)).Is(expected)
+	}
+	// an instance that is an iterator
+	test(`c = class
+			{
+			New(.n) { .i = 0 }
+			Next() { return .i >= .n ? this : ++.i }
+			}
+		s = ''; for x in c(3) { s $= x }; s`, SuStr("123"))
+	// an instance with an Iter method returning an iterator
+	test(`it = class
+			{
+			New(.n) { .i = 0 }
+			Next() { return .i >= .n ? this : ++.i }
+			}
+		c = class
+			{
+			New(.it) { }
+			Iter() { return (.it)(2) }
+			}
+		s = ''; for x in c(it) { s $= x }; s`, SuStr("12"))
+	// an Iter method returning a builtin iterable
+	test(`c = class { Iter() { return #(a, b) } }
+		s = ''; for x in c() { s $= x }; s`, SuStr("ab"))
+	assert.T(t).This(func() { exec(`for x in class{}() {}`) }).
+		Panics("can't iterate")
+}
+
+func TestTryCatch(t *testing.T) {
+	test := func(src string, expected Value) {
+		t.Helper()
+		assert.T(t).This(exec(src)).Is(expected)
+	}
+	test(`try throw "x"; 123`, SuInt(123))
+	test(`try throw "x" catch (e) return e`, SuStr("x"))
+	test(`try { throw "x" } catch { return "caught" }`, SuStr("caught"))
+	test(`try return "ok" catch (e) return e`, SuStr("ok"))
+	test(`try throw "uninit" catch (e, "uninit") return e`, SuStr("uninit"))
+	test(`try throw "abcdef" catch (e, "*def") return e`, SuStr("abcdef"))
+	test(`f = function () { throw "deep" }
+		try f() catch (e) return e`, SuStr("deep"))
+	// stack is unwound when the exception is part way through an expression
+	test(`f = function () { throw "x" }
+		try a = 1 + f() catch (e) return 2 + 3`, SuInt(5))
+	// filter that doesn't match propagates
+	test(`f = function () { try throw "foo" catch (e, "bar") return 1 }
+		try f() catch (e) return e`, SuStr("foo"))
+	// nested
+	test(`try
+			{
+			try throw "a" catch (e) { }
+			throw "b"
+			}
+		catch (e2)
+			return e2`, SuStr("b"))
+	test(`try
+			{
+			try throw "a" catch (e, "x") { }
+			}
+		catch (e2)
+			return "outer " $ e2`, SuStr("outer a"))
+	test(`try
+			{
+			try throw "a" catch (e) throw "re" $ e
+			}
+		catch (e2)
+			return e2`, SuStr("rea"))
+	// leaving a try body with break or continue
+	test(`for x in #(1, 2)
+			try break catch { }
+		try throw "after" catch (e) return e`, SuStr("after"))
+	test(`f = function () { throw "f" }
+		try
+			{
+			for x in #(1, 2)
+				try break catch (e, "none") { }
+			f()
+			}
+		catch (e)
+			return "outer " $ e`, SuStr("outer f"))
+	test(`n = 0
+		for x in #(1, 2, 3)
+			try { ++n; continue } catch { }
+		n`, SuInt(3))
+	// re-entering a try from a loop
+	test(`n = 0
+		for (i = 0; i < 1000; ++i)
+			try { if i < 999 continue; throw "x" $ i } catch (e) n = e
+		n`, SuStr("x999"))
+}
+
+func TestBlocks(t *testing.T) {
+	test := func(src string, expected Value) {
+		t.Helper()
+		assert.T(t).This(exec(src)).Is(expected)
+	}
+	test(`b = { 123 }; b()`, SuInt(123))
+	test(`b = {|x| x * 2 }; b(21)`, SuInt(42))
+	test(`b = { it $ "!" }; b("hi")`, SuStr("hi!"))
+	// closure captures enclosing locals
+	test(`n = 5; b = { n + 1 }; b()`, SuInt(6))
+	test(`n = 5; b = { n = n * 2 }; b(); b(); n`, SuInt(20))
+	test(`n = 0
+		inc = { ++n }
+		for x in #(1, 2, 3)
+			inc()
+		n`, SuInt(3))
+	// blocks that don't share variables can be compiled as functions
+	test(`b = {|x| y = x + 1; y }; b(1)`, SuInt(2))
+	// nested closures
+	test(`a = 1; b = { c = { a + 10 }; c() }; b()`, SuInt(11))
+	// closures keep their own copy of the frame after the function returns
+	test(`mk = function (n) { return { n * 3 } }
+		b = mk(4)
+		b()`, SuInt(12))
+	// return in a block returns from the enclosing function
+	test(`call = function (b) { b(); return "not reached" }
+		call({ return "block" })
+		return "after"`, SuStr("block"))
+	test(`call = function (b) { return b() }
+		x = call({ 10 })
+		x + 1`, SuInt(11))
+	// a block called from another function can return nil
+	test(`call = function (b) { b(); return "called" }
+		call({ return })`, nil)
+}
+
+func TestLines(t *testing.T) {
+	src := `function ()
+		{
+		a = 1
+		b = { |x|
+			throw "oops" $ x
+			}
+		try
+			b(a)
+		catch (e)
+			return e
+		}`
+	fn := Constant(src).(*SuFunc)
+	e := NewThread().Invoke(fn, nil).(*SuExcept)
+	line := func(i int) Value {
+		return e.Callstack.ListGet(i).Get(nil, SuStr("line"))
+	}
+	assert.T(t).This(line(0)).Is(SuInt(5)) // in the block
+	assert.T(t).This(line(1)).Is(SuInt(8)) // the call to the block
+
+	fn = Constant("\n\nfunction ()\n{\nx = F()\n\ny = G()\n}").(*SuFunc)
+	assert.T(t).This(fn.LineBase).Is(5)
+	assert.T(t).This(fn.CodeToLine(0)).Is(5)
+	assert.T(t).This(fn.CodeToLine(len(fn.Code) - 1)).Is(7)
+}
+
+func TestWide(t *testing.T) {
+	// more than 255 local variables and constants
+	// (assigned twice so they aren't constant folded)
+	var sb strings.Builder
+	sb.WriteString("try throw 'x' catch (e) { }\n")
+	sb.WriteString("b = { '!' }\n")
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&sb, "x%d = 0\nx%d = 's%d'\n", i, i, i)
+	}
+	sb.WriteString("e $ x0 $ x299 $ b()")
+	src := sb.String()
+	assert.T(t).This(exec(src)).Is(SuStr("xs0s299!"))
+	da := disasm(Constant("function () {\n" + src + "\n}").(*SuFunc))
+	assert.T(t).That(strings.Contains(da, `Value16 "s299"`))
+	assert.T(t).That(strings.Contains(da, "Store16 x299"))
+	assert.T(t).That(strings.Contains(da, "Load16 x299"))
+}
+
+func TestWideForms(t *testing.T) {
+	// more than 255 local variables and constants before the code
+	var sb strings.Builder
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&sb, "x%d = 0\nx%d = 's%d'\n", i, i, i)
+	}
+	many := sb.String()
+	test := func(src string, expected Value, wide string) {
+		t.Helper()
+		src = many + src
+		assert.T(t).This(exec(src)).Is(expected)
+		fn := Constant("function () {\n" + src + "\n}").(*SuFunc)
+		assert.T(t).This(DecodeFunc(EncodeFunc(fn)).Code).Is(fn.Code)
+		da := disasm(fn)
+		assert.T(t).Msg(wide).That(strings.Contains(da, wide))
+	}
+	test("x299 = 5; x299 += 2; ++x299; x299", SuInt(8), "LoadStore16 x299")
+	test("s = ''; for x299 in #(a, b) s $= x299; s", SuStr("ab"), "ForIn16 x299")
+	test(`try throw "abc" catch (e, "ab") return e`, SuStr("abc"), "Try16")
+	test("x299 = 1; b = { x299 + 1 }; b()", SuInt(2), "Closure16")
+	test("f = 0; f = function (zz) { zz }; f(zz: 'named')", SuStr("named"),
+		"(zz:)")
+	fn := Constant("function () {\n" + many + "_dyn }").(*SuFunc)
+	assert.T(t).That(strings.Contains(disasm(fn), "Dyload16 _dyn"))
+	assert.T(t).This(exec("_dyn = 'dynamic'; f = 0\n" +
+		"f = function () {\n" + many + "_dyn }; f()")).Is(SuStr("dynamic"))
+
+	var params strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&params, "p%d, ", i)
+	}
+	assert.T(t).This(func() { Constant("function (" + params.String() + "z = 1 + 1) { }") }).
+		Panics("too many parameters")
+}
+
+func TestEncodeFunc(t *testing.T) {
+	test := func(src string, expected Value) {
+		t.Helper()
+		fn := Constant("function () {\n" + src + "\n}").(*SuFunc)
+		fn2 := DecodeFunc(EncodeFunc(fn))
+		assert.T(t).This(disasm(fn2)).Is(disasm(fn))
+		assert.T(t).This(NewThread().Invoke(fn2, nil)).Is(expected)
+	}
+	test(`123`, SuInt(123))
+	test(`x = #(a, b: 'z'); x.b $ x[0]`, SuStr("za"))
+	test(`f = function (a, b = 2) { a * b }; f(b: 3, a: 4)`, SuInt(12))
+	test(`n = 1; b = {|x| n += x }; b(2); b(3); n`, SuInt(6))
+	test(`try throw "x" catch (e, "x") return e`, SuStr("x"))
+
+	fn := Constant("function () { Foo(); Bar }").(*SuFunc)
+	s := EncodeFunc(fn)
+	assert.T(t).This(DecodeFunc(s).Code).Is(fn.Code)
+	assert.T(t).This(func() { DecodeFunc("xyz") }).Panics("invalid data")
+	s = s[:len("SuFunc")] + "\x09" + s[len("SuFunc")+1:]
+	assert.T(t).This(func() { DecodeFunc(s) }).Panics("version 9 does not")
+	fn = Constant("function (s) { s =~ 'x' }").(*SuFunc)
+	assert.T(t).This(func() { EncodeFunc(fn) }).Panics("can't encode")
+}
+
+func TestInterpolate(t *testing.T) {
+	options.LangVersion = 2
+	defer func() { options.LangVersion = 1 }()
+	test := func(src string, expected Value) {
+		t.Helper()
+		assert.T(t).This(exec(src)).Is(expected)
+	}
+	test(`x = 5; "count = $(x + 1)"`, SuStr("count = 6"))
+	test(`x = 5; "$(x)"`, SuStr("5"))
+	test(`a = 'A'; b = 'B'; "<$(a)$(b)>"`, SuStr("<AB>"))
+	test(`f = function (x) { x * 2 }; "$(f((1 + 2)))!"`, SuStr("6!"))
+	test(`"$('(')"`, SuStr("("))
+	test(`'$(x)'`, SuStr("$(x)"))
+	test("`$(x)`", SuStr("$(x)"))
+	test(`"no interpolation"`, SuStr("no interpolation"))
+	test(`b = {|x| "<$(x)>" }; b(1)`, SuStr("<1>"))
+	assert.T(t).This(func() { exec(`"$(x"`) }).Panics("missing closing")
+
+	options.LangVersion = 1
+	test(`"$(x)"`, SuStr("$(x)"))
+}
+
+func TestDestructure(t *testing.T) {
+	test := func(src string, expected Value) {
+		t.Helper()
+		assert.T(t).This(exec(src)).Is(expected)
+	}
+	test(`[a, b, c] = #(1, 2, 3); a $ b $ c`, SuStr("123"))
+	test(`[a, b] = #(1, 2, 3); a $ b`, SuStr("12"))
+	test(`[x: a, y: b] = #(y: 1, x: 2); a $ b`, SuStr("21"))
+	test(`[:x, :y] = #(x: 'a', y: 'b'); x $ y`, SuStr("ab"))
+	test(`[a, y: b] = #(1, y: 2); a $ b`, SuStr("12"))
+	// the value is only evaluated once
+	test(`n = 0
+		f = { ++n; #(a, b) }
+		[x, y] = f()
+		n $ x $ y`, SuStr("1ab"))
+	// targets can be used in the value
+	test(`a = #(1, 2); [a, b] = a; a $ b`, SuStr("12"))
+	assert.T(t).This(func() { exec(`[a, b] = #(1); b`) }).
+		Panics("uninitialized member")
+	assert.T(t).This(func() { exec(`[a, 5] = #(1, 2)`) }).
+		Panics("lvalue required")
+}
+
+func TestParamDefaultExpr(t *testing.T) {
+	fn := Constant(`function (a, b = a * 2, c = b + 1) { a * 100 + b * 10 + c }`).(*SuFunc)
+	assert.T(t).This(fn.Params()).Is("(a,b=a * 2,c=b + 1)")
+	th := NewThread()
+	assert.T(t).This(th.Call(fn, One)).Is(SuInt(123))
+	assert.T(t).This(th.Call(fn, One, Zero)).Is(SuInt(101))
+	assert.T(t).This(th.Call(fn, One, Zero, Zero)).Is(SuInt(100))
+	assert.T(t).This(strings.Contains(disasm(fn), "JumpSet b")).Is(true)
+
+	test := func(def string, expected string) {
+		t.Helper()
+		fn := Constant("function (x = " + def + ") { x }").(*SuFunc)
+		assert.T(t).This(th.Call(fn).String()).Is(expected)
+	}
+	test("()", "#()")
+	test("(a, b)", "#('a', 'b')")
+	test("(a: 1)", "#(a: 1)")
+	test("(1)", "#(1)")
+	test("#(1)", "#(1)")
+	test("[a: 1]", "[a: 1]")
+	test("-1", "-1")
+	test("true", "true")
+	test("(1 + 2)", "#(1, 2)") // constant syntax, commas are optional
+	test("1 + 2", "3")
+	test(`"a" $ "b"`, `"ab"`)
+	fn = Constant(`function (x = [a: 1]) { x }`).(*SuFunc)
+	assert.T(t).This(strings.Contains(disasm(fn), "Record")).Is(false)
+}
+
+func TestLabeledBreak(t *testing.T) {
+	test := func(src string, expected Value) {
+		t.Helper()
+		assert.T(t).This(exec(src)).Is(expected)
+	}
+	test(`s = ''
+		outer: for x in #(a, b, c)
+			for y in #(1, 2, 3)
+				{
+				if y is 2
+					continue outer
+				if x is 'c'
+					break outer
+				s $= x $ y
+				}
+		s`, SuStr("a1b1"))
+	test(`s = ''
+		outer: for (i = 0; i < 3; ++i)
+			for y in #(1, 2, 3)
+				for z in #(4, 5)
+					{
+					if i is 1
+						break outer
+					s $= y $ z
+					}
+		s`, SuStr("141524253435"))
+	test(`n = 0
+		outer: forever
+			{
+			while true
+				{
+				if ++n > 5
+					break outer
+				continue outer
+				}
+			}
+		n`, SuInt(6))
+	test(`n = 0
+		outer: do
+			{
+			do
+				{
+				++n
+				continue outer
+				} while false
+			} while n < 3
+		n`, SuInt(3))
+	assert.T(t).This(func() { exec("forever { break nope }") }).
+		Panics("break: label not found: nope")
+	assert.T(t).This(func() { exec("x: a = 1") }).
+		Panics("label must be followed by a loop")
+}
+
+func TestSwitchTable(t *testing.T) {
+	fn := Constant(`function (x) {
+		switch x {
+		case 1, 2: return "a"
+		case 4: return "b"
+		case 5, 1: return "c"
+		default: return "d"
+		}}`).(*SuFunc)
+	assert.T(t).That(strings.Contains(disasm(fn), "JumpTable"))
+	test := func(x Value, expected string) {
+		t.Helper()
+		th := NewThread()
+		assert.T(t).This(th.Call(fn, x)).Is(SuStr(expected))
+	}
+	test(Zero, "d")
+	test(One, "a")
+	test(SuInt(2), "a")
+	test(SuInt(3), "d")
+	test(SuInt(4), "b")
+	test(SuInt(5), "c")
+	test(SuInt(6), "d")
+	test(SuInt(-1), "d")
+	test(NumFromString("4.0"), "b")
+	test(NumFromString("4.5"), "d")
+	test(SuStr("4"), "d")
+
+	e := assert.Catch(func() {
+		exec("switch 3 { case 1: 1 case 2: 2 case 4: 4 case 5: 5 }")
+	})
+	assert.T(t).This(e).Is(SuStr("unhandled switch value"))
+}
+
+func TestCallProfile(t *testing.T) {
+	fn := Constant("function (n) { for (i = 0; i < n; ++i) {} }").(*SuFunc)
+	th := NewThread()
+	th.Call(fn, One)
+	assert.T(t).That(th.CallProfile == nil)
+	th.CallProfile = make(map[*SuFunc]*CallStats)
+	th.Call(fn, One)
+	th.Call(fn, SuInt(100))
+	cs := th.CallProfile[fn]
+	assert.T(t).This(cs.Calls).Is(2)
+	assert.T(t).That(cs.Time > 0)
+}
+
+func BenchmarkGetMem(b *testing.B) {
+	fn := Constant(`function (ob) {
+		sum = 0
+		for (i = 0; i < 100; ++i)
+			sum += ob.a + ob.b
+		return sum
+		}`).(*SuFunc)
+	ob := &SuObject{}
+	ob.Set(SuStr("a"), One)
+	ob.Set(SuStr("b"), One)
+	th := NewThread()
+	for n := 0; n < b.N; n++ {
+		th.Call(fn, ob)
+	}
+}
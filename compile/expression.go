@@ -9,6 +9,7 @@ import (
 	"github.com/apmckinlay/gsuneido/compile/ast"
 	. "github.com/apmckinlay/gsuneido/compile/lexer"
 	tok "github.com/apmckinlay/gsuneido/compile/tokens"
+	"github.com/apmckinlay/gsuneido/options"
 	. "github.com/apmckinlay/gsuneido/runtime"
 	"github.com/apmckinlay/gsuneido/util/ascii"
 	. "github.com/apmckinlay/gsuneido/util/ascii"
@@ -19,13 +20,29 @@ func (p *Parser) Expression() ast.Expr {
 	return p.pcExpr(1)
 }
 
+// AndOperand parses an expression up to a top level and, or, or ?
+// It is used by query where to find the and terms.
+func (p *Parser) AndOperand() ast.Expr {
+	return p.pcExpr(precedence[tok.And] + 1)
+}
+
+// ExpressionFrom continues parsing an expression
+// after its first operand has been parsed (e.g. by AndOperand)
+func (p *Parser) ExpressionFrom(e ast.Expr) ast.Expr {
+	return p.pcExprFrom(e, 1)
+}
+
 // ------------------------------------------------------------------
 // pcExpr implements precedence climbing
 // each call processes at least one atom
 // a given call processes everything >= minprec
 // it recurses to process the right hand side of each operator
 func (p *Parser) pcExpr(minprec int8) ast.Expr {
-	e := p.atom()
+	return p.pcExprFrom(p.atom(), minprec)
+}
+
+// pcExprFrom is pcExpr after the first atom
+func (p *Parser) pcExprFrom(e ast.Expr, minprec int8) ast.Expr {
 	// fmt.Println("pcExpr minprec", minprec, "atom", e)
 	for p.Token != tok.Eof {
 		token := p.Token
@@ -45,7 +62,7 @@ func (p *Parser) pcExpr(minprec int8) ast.Expr {
 				e = p.Ident("this")
 				id = p.privatizeRef(id)
 			}
-			e = &ast.Mem{E: e, M: p.Constant(SuStr(id))}
+			e = &ast.Mem{E: e, M: p.Constant(Intern(id))}
 			if p.Token == tok.LCurly && !p.expectingCompound { // a.F { }
 				e = &ast.Call{Fn: e, Args: p.arguments(p.Token)}
 			}
@@ -114,7 +131,7 @@ func (p *Parser) pcExpr(minprec int8) ast.Expr {
 			f := p.Expression()
 			e = p.Trinary(e, t, f)
 		case token == tok.LParen: // function call
-			e = &ast.Call{Fn: e, Args: p.arguments(token)}
+			e = p.Call(e, p.arguments(token))
 		case tok.AssocStart < token && token < tok.AssocEnd:
 			// for associative operators, collect a list of contiguous
 			es := []ast.Expr{e}
@@ -212,6 +229,10 @@ func (p *Parser) same(listtype tok.Token, next tok.Token) bool {
 func (p *Parser) atom() ast.Expr {
 	switch token := p.Token; token {
 	case tok.String:
+		if options.LangVersion >= 2 && p.Lxr.Source()[p.Pos] == '"' &&
+			strings.Contains(p.Text, "$(") {
+			return p.interpolate()
+		}
 		// don't call p.constant() because it allows concatenation
 		s := SuStr(p.Text)
 		p.Next()
@@ -509,3 +530,69 @@ func localVar(node ast.Node) string {
 	}
 	return ""
 }
+
+// interpolate handles "...$(expr)..." in double quoted strings.
+// It is desugared to concatenation i.e. "..." $ (expr) $ "..."
+func (p *Parser) interpolate() ast.Expr {
+	s := p.Text
+	p.Next()
+	exprs := []ast.Expr{}
+	for {
+		i := strings.Index(s, "$(")
+		if i == -1 {
+			break
+		}
+		if i > 0 || len(exprs) == 0 {
+			exprs = append(exprs, p.Constant(SuStr(s[:i])))
+		}
+		s = s[i+2:]
+		j := matchParen(s)
+		if j == -1 {
+			p.Error("string interpolation missing closing )")
+		}
+		exprs = append(exprs, p.Unary(tok.LParen, p.subExpr(s[:j])))
+		s = s[j+1:]
+	}
+	if s != "" {
+		exprs = append(exprs, p.Constant(SuStr(s)))
+	}
+	return p.Nary(tok.Cat, exprs)
+}
+
+// matchParen returns the index of the closing parenthesis, or -1 if none.
+// It skips over single quoted strings.
+func matchParen(s string) int {
+	nest := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			nest++
+		case ')':
+			if nest == 0 {
+				return i
+			}
+			nest--
+		case '\'':
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j == -1 {
+				return -1
+			}
+			i += j + 1
+		}
+	}
+	return -1
+}
+
+// subExpr parses an expression from a string within the current function
+func (p *Parser) subExpr(src string) ast.Expr {
+	sub := *p
+	sub.ParserBase = ParserBase{Lxr: NewLexer(src), Aspects: p.Aspects}
+	sub.Next()
+	e := sub.Expression()
+	if sub.Token != tok.Eof {
+		p.Error("invalid string interpolation: " + src)
+	}
+	p.funcInfo = sub.funcInfo
+	p.itUsed = sub.itUsed
+	return e
+}
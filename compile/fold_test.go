@@ -215,3 +215,46 @@ func TestPropFold(t *testing.T) {
 
 	test("a in ()", "false")
 }
+
+func TestFoldCall(t *testing.T) {
+	rt.DefaultSingleQuotes = true
+	defer func(sm, nm rt.Methods) {
+		rt.DefaultSingleQuotes = false
+		rt.StringMethods, rt.NumMethods = sm, nm
+	}(rt.StringMethods, rt.NumMethods)
+	rt.StringMethods = rt.Methods{
+		"Size": &rt.SuBuiltinMethod0{SuBuiltin1: rt.SuBuiltin1{
+			Fn: func(this rt.Value) rt.Value {
+				return rt.IntVal(len(rt.ToStr(this)))
+			}}},
+		"Split": &rt.SuBuiltinMethod0{SuBuiltin1: rt.SuBuiltin1{
+			Fn: func(this rt.Value) rt.Value {
+				return &rt.SuObject{}
+			}}},
+	}
+	rt.NumMethods = rt.Methods{
+		"Chr": &rt.SuBuiltinMethod0{SuBuiltin1: rt.SuBuiltin1{
+			Fn: func(this rt.Value) rt.Value {
+				return rt.SuStr(string(rune(rt.ToInt(this))))
+			}}},
+	}
+	test := func(src string, expected string) {
+		t.Helper()
+		p := NewParser(src)
+		p.InitFuncInfo()
+		assert.T(t).This(p.Expression().String()).Is(expected)
+	}
+	test("'abc'.Size()", "3")
+	test("'ab' $ 'c'.Size()", "'ab1'")
+	test("65.Chr()", "'A'")
+	test("x.Size()", "Call(Mem(x 'Size'))")
+	test("'abc'.Size(x)", "Call(Mem('abc' 'Size') x)")
+	test("'abc'.Size(1)", "Call(Mem('abc' 'Size') 1)")   // error at runtime
+	test("'abc'.Split()", "Call(Mem('abc' 'Split'))")    // not pure
+	test("'ab'.Repeat(3)", "Call(Mem('ab' 'Repeat') 3)") // could be large
+	test("'abc'.Upper()", "Call(Mem('abc' 'Upper'))")    // not builtin
+
+	p := NewParser("function () {\n s = 'abc'; s.Size() \n}")
+	f := ast.PropFold(p.Function())
+	assert.T(t).This(f.Body[1].String()).Is("3")
+}
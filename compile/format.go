@@ -0,0 +1,450 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package compile
+
+import (
+	"strings"
+
+	. "github.com/apmckinlay/gsuneido/compile/lexer"
+	tok "github.com/apmckinlay/gsuneido/compile/tokens"
+)
+
+// Format returns src (a constant e.g. a function or class)
+// reformatted in the standard Suneido style
+// i.e. tab indentation, braces on their own lines
+// indented with their contents, and consistent operator spacing.
+// It panics with the first syntax error if src does not parse.
+//
+// The source is parsed (with the AST parser) to validate it,
+// but formatting is done from the lexical tokens
+// so that comments and line breaks are preserved.
+func Format(src string) string {
+	if errs := SyntaxErrors(src); errs != nil {
+		panic(errs[0])
+	}
+	f := formatter{toks: fmtTokens(src), pending: -1}
+	f.format()
+	return f.result()
+}
+
+type fmtToken struct {
+	// text is the source text of the token
+	text  string
+	token tok.Token
+	// nl is the number of newlines before the token
+	nl int
+	// multiline is set on LCurly if the source has a newline
+	// before the matching RCurly
+	multiline bool
+}
+
+// fmtTokens returns the tokens from src, excluding whitespace
+func fmtTokens(src string) []fmtToken {
+	lxr := NewLexer(src)
+	toks := []fmtToken{}
+	curlies := []int{}
+	lines := []int{} // the line of each open curly
+	line := 0
+	nl := 0
+	for {
+		item := lxr.Next()
+		switch item.Token {
+		case tok.Eof:
+			toks = append(toks, fmtToken{token: tok.Eof, nl: nl})
+			return toks
+		case tok.Whitespace:
+			continue
+		case tok.Newline:
+			n := strings.Count(item.Text, "\n")
+			nl += n
+			line += n
+			continue
+		case tok.LCurly:
+			curlies = append(curlies, len(toks))
+			lines = append(lines, line)
+		case tok.RCurly:
+			if n := len(curlies); n > 0 {
+				toks[curlies[n-1]].multiline = lines[n-1] != line
+				curlies = curlies[:n-1]
+				lines = lines[:n-1]
+			}
+		}
+		text := src[item.Pos:lxr.Position()]
+		toks = append(toks, fmtToken{text: text, token: item.Token, nl: nl})
+		nl = 0
+	}
+}
+
+type formatter struct {
+	toks  []fmtToken
+	lines []string
+	// line is the current line (without indentation)
+	line strings.Builder
+	// indent is the indentation of the current line
+	indent  int
+	levels  []fmtLevel
+	headers []fmtHeader
+	// pending is the indentation for the next line
+	// when a control statement body is on the following line, else -1
+	pending int
+	// prev is the previous significant (non-comment) token
+	prev tok.Token
+	// noSpace suppresses the space before the next token
+	noSpace bool
+	// ternary counts the unmatched ? to handle the matching :
+	ternary int
+	// blockParams is set inside |...| block parameters
+	blockParams bool
+	// afterDo is set after the body of a do-while
+	afterDo bool
+}
+
+// fmtLevel is an open curly brace, parenthesis, or square bracket
+type fmtLevel struct {
+	token tok.Token
+	// indent is the indentation for the contents
+	indent int
+	// header is the control statement (or function or class)
+	// that the curly braces are the body of, else Nil
+	header tok.Token
+	// multiline is set for curly braces with contents on multiple lines
+	multiline bool
+}
+
+// fmtHeader is a control statement, function or class
+// that has not reached its body yet
+type fmtHeader struct {
+	token  tok.Token
+	depth  int
+	indent int
+	// paren is set when a condition in parenthesis has been closed
+	paren bool
+}
+
+func (f *formatter) format() {
+	for i := 0; i < len(f.toks); i++ {
+		t := &f.toks[i]
+		if t.token == tok.Eof {
+			break
+		}
+		if t.nl > 0 {
+			if f.line.Len() > 0 {
+				f.newline(t)
+			}
+			if n := len(f.lines); t.nl > 1 && n > 0 && f.lines[n-1] != "" {
+				f.lines = append(f.lines, "") // preserve one blank line
+			}
+		}
+		if t.token == tok.Comment {
+			f.comment(t)
+			continue
+		}
+		f.token(i)
+		f.prev = t.token
+	}
+	f.endLine()
+}
+
+func (f *formatter) comment(t *fmtToken) {
+	if f.line.Len() == 0 {
+		f.startLine(t)
+	} else {
+		f.line.WriteByte(' ')
+	}
+	f.line.WriteString(t.text)
+	f.noSpace = false
+}
+
+func (f *formatter) token(i int) {
+	t := &f.toks[i]
+	next := f.toks[i+1].token
+	header := f.header()
+
+	// body is the header that curly braces are the body of
+	body := tok.Nil
+	if t.token == tok.LCurly {
+		if header != nil && f.prev != tok.Hash {
+			body = header.token
+		} else if len(f.levels) == 0 && f.prev == tok.Identifier {
+			body = tok.Class // e.g. Base { ... }
+		}
+	}
+
+	// line breaks for braces on their own lines
+	if body != tok.Nil && f.line.Len() > 0 && t.multiline {
+		f.newline(t)
+	} else if t.token == tok.RCurly && f.line.Len() > 0 &&
+		len(f.levels) > 0 && f.top().multiline && f.top().header != tok.Nil {
+		f.newline(t)
+	}
+
+	if f.line.Len() == 0 {
+		f.startLine(t)
+	} else if f.space(t) {
+		f.line.WriteByte(' ')
+	}
+	f.line.WriteString(t.text)
+	f.noSpace = false
+
+	switch t.token {
+	case tok.LCurly:
+		lev := fmtLevel{token: tok.LCurly, header: body,
+			multiline: t.multiline}
+		if body != tok.Nil && header != nil {
+			f.headers = f.headers[:len(f.headers)-1]
+		}
+		lev.indent = f.indent
+		if f.line.Len() > len(t.text) {
+			lev.indent++
+		}
+		f.levels = append(f.levels, lev)
+		if next == tok.BitOr {
+			f.blockParams = true
+			f.noSpace = true
+		} else if next == tok.RCurly {
+			f.noSpace = true
+		} else if lev.header != tok.Nil && t.multiline &&
+			f.toks[i+1].nl == 0 && next != tok.Comment {
+			f.newline(&f.toks[i+1])
+		}
+		return
+	case tok.LParen, tok.LBracket:
+		f.levels = append(f.levels,
+			fmtLevel{token: t.token, indent: f.indent + 1})
+		f.noSpace = true
+		return
+	case tok.RCurly, tok.RParen, tok.RBracket:
+		f.close(t.token, next)
+		return
+	case tok.BitOr:
+		if f.blockParams && f.prev != tok.LCurly {
+			f.blockParams = false
+		} else if f.blockParams {
+			f.noSpace = true
+		}
+	case tok.Hash, tok.At, tok.Dot, tok.BitNot, tok.RangeTo, tok.RangeLen:
+		f.noSpace = true
+	case tok.Not:
+		f.noSpace = t.text == "!"
+	case tok.Add, tok.Sub, tok.Inc, tok.Dec:
+		// unary or prefix
+		f.noSpace = !valueEnd(f.prev) || f.line.Len() == len(t.text)
+	case tok.QMark:
+		f.ternary++
+	case tok.Colon:
+		if f.ternary > 0 {
+			f.ternary--
+		} else if f.prev == tok.Comma || f.prev == tok.LParen {
+			f.noSpace = true // e.g. F(:name)
+		}
+	case tok.Return, tok.Break, tok.Continue, tok.Throw:
+		// body on the same line as the header
+		if header != nil {
+			f.headers = f.headers[:len(f.headers)-1]
+			header = nil
+		}
+	}
+	f.headerToken(i, header)
+}
+
+// headerToken handles the start and end of control statement headers
+func (f *formatter) headerToken(i int, header *fmtHeader) {
+	t := &f.toks[i]
+	switch t.token {
+	case tok.If, tok.While, tok.For, tok.Switch, tok.Catch,
+		tok.Else, tok.Try, tok.Do, tok.Forever, tok.Function, tok.Class:
+		if t.token == tok.While && f.afterDo {
+			f.afterDo = false
+			return
+		}
+		if header != nil {
+			// e.g. else if, or try ... catch
+			f.headers = f.headers[:len(f.headers)-1]
+		}
+		f.headers = append(f.headers,
+			fmtHeader{token: t.token, depth: len(f.levels), indent: f.indent})
+		return
+	case tok.Identifier:
+		if f.isMethod(i) {
+			f.headers = append(f.headers, fmtHeader{token: tok.Function,
+				depth: len(f.levels), indent: f.indent})
+			return
+		}
+	}
+	if header != nil {
+		switch header.token {
+		case tok.Else, tok.Try, tok.Do, tok.Forever:
+			// body on the same line as the header
+			f.headers = f.headers[:len(f.headers)-1]
+		case tok.If, tok.While, tok.For, tok.Switch, tok.Catch:
+			if header.paren && (t.token == tok.Identifier ||
+				t.token == tok.This || t.token == tok.Super) {
+				// body on the same line after (condition)
+				f.headers = f.headers[:len(f.headers)-1]
+			}
+		}
+	}
+}
+
+// isMethod returns whether the identifier at i
+// starts a method definition in a class
+func (f *formatter) isMethod(i int) bool {
+	if len(f.levels) == 0 || f.top().header != tok.Class ||
+		f.toks[i+1].token != tok.LParen {
+		return false
+	}
+	switch f.prev {
+	case tok.LCurly, tok.RCurly, tok.Comma, tok.Semicolon:
+		return true
+	case tok.Colon, tok.Dot:
+		return false
+	}
+	return f.toks[i].nl > 0 && valueEnd(f.prev)
+}
+
+func (f *formatter) close(token, next tok.Token) {
+	var lev fmtLevel
+	if n := len(f.levels); n > 0 {
+		lev = f.levels[n-1]
+		f.levels = f.levels[:n-1]
+	}
+	for n := len(f.headers); n > 0 && f.headers[n-1].depth > len(f.levels); n-- {
+		f.headers = f.headers[:n-1]
+	}
+	if token == tok.RParen {
+		if h := f.header(); h != nil {
+			h.paren = true
+		}
+	}
+	if token != tok.RCurly {
+		return
+	}
+	f.afterDo = lev.header == tok.Do
+	if lev.header != tok.Nil && lev.multiline {
+		switch next {
+		case tok.RParen, tok.RBracket, tok.Comma, tok.Semicolon,
+			tok.Comment, tok.Eof:
+		default:
+			f.newline(nil)
+		}
+	}
+}
+
+// header returns the unfinished header at the current depth, or nil
+func (f *formatter) header() *fmtHeader {
+	if n := len(f.headers); n > 0 && f.headers[n-1].depth == len(f.levels) {
+		return &f.headers[n-1]
+	}
+	return nil
+}
+
+func (f *formatter) top() *fmtLevel {
+	return &f.levels[len(f.levels)-1]
+}
+
+// space returns whether a space is required before t
+func (f *formatter) space(t *fmtToken) bool {
+	if f.noSpace {
+		return false
+	}
+	switch t.token {
+	case tok.RParen, tok.RBracket, tok.Comma, tok.Semicolon,
+		tok.RangeTo, tok.RangeLen:
+		return false
+	case tok.Inc, tok.Dec:
+		return !valueEnd(f.prev)
+	case tok.LParen:
+		switch f.prev {
+		case tok.Identifier, tok.RParen, tok.RBracket, tok.Super, tok.This:
+			return false
+		}
+	case tok.LBracket, tok.Dot:
+		return !valueEnd(f.prev)
+	case tok.Colon:
+		return f.ternary > 0 || f.prev == tok.Class || f.prev == tok.Comma
+	case tok.BitOr:
+		return !f.blockParams
+	}
+	return true
+}
+
+// valueEnd returns whether a token can end an operand.
+// It is used to distinguish unary and binary (or prefix and postfix) operators
+func valueEnd(token tok.Token) bool {
+	switch token {
+	case tok.Identifier, tok.Number, tok.String, tok.Symbol,
+		tok.RParen, tok.RBracket, tok.RCurly,
+		tok.True, tok.False, tok.This, tok.Super, tok.Inc, tok.Dec:
+		return true
+	}
+	return false
+}
+
+// newline ends the current line.
+// If there is an unfinished header and the next token (t)
+// is not its body, then the body is on the next line.
+func (f *formatter) newline(t *fmtToken) {
+	if h := f.header(); h != nil &&
+		(t == nil || (t.token != tok.LCurly && t.token != tok.Comment)) {
+		switch h.token {
+		case tok.Function, tok.Class:
+		default:
+			f.pending = h.indent + 1
+			f.headers = f.headers[:len(f.headers)-1]
+		}
+	}
+	f.endLine()
+}
+
+func (f *formatter) endLine() {
+	if f.line.Len() == 0 {
+		return
+	}
+	f.lines = append(f.lines,
+		strings.Repeat("\t", f.indent)+strings.TrimRight(f.line.String(), " "))
+	f.line.Reset()
+}
+
+// startLine determines the indentation for a line starting with t
+func (f *formatter) startLine(t *fmtToken) {
+	indent := 0
+	if len(f.levels) > 0 {
+		indent = f.top().indent
+	}
+	switch t.token {
+	case tok.LCurly:
+		if h := f.header(); h != nil {
+			indent = h.indent + 1
+		} else if f.pending >= 0 {
+			indent = f.pending
+		} else {
+			indent++
+		}
+	case tok.RCurly, tok.RParen, tok.RBracket:
+	case tok.Comment:
+		if h := f.header(); h != nil {
+			indent = h.indent + 1
+		} else if f.pending >= 0 {
+			indent = f.pending
+		}
+	case tok.Case, tok.Default:
+		if len(f.levels) > 0 && f.top().header == tok.Switch {
+			indent--
+		}
+		fallthrough
+	default:
+		if f.pending >= 0 {
+			indent = f.pending
+		}
+	}
+	f.pending = -1
+	f.indent = indent
+}
+
+func (f *formatter) result() string {
+	for len(f.lines) > 0 && f.lines[len(f.lines)-1] == "" {
+		f.lines = f.lines[:len(f.lines)-1]
+	}
+	return strings.Join(f.lines, "\n")
+}
@@ -0,0 +1,106 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package compile
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestFormat(t *testing.T) {
+	test := func(src, expected string) {
+		t.Helper()
+		result := Format(src)
+		assert.T(t).This(result).Is(expected)
+		assert.T(t).Msg("idempotent").This(Format(result)).Is(expected)
+	}
+	test("123", "123")
+	test("#(a:1,b: 'x' ,c:#foo)", "#(a: 1, b: 'x', c: #foo)")
+	test("function(a,b){x=a+b*2;if (x>1) {return -x} else {Print(x) }\n"+
+		"// done\n"+
+		"return x}",
+		"function (a, b)\n"+
+			"\t{\n"+
+			"\tx = a + b * 2; if (x > 1) { return -x } else { Print(x) }\n"+
+			"\t// done\n"+
+			"\treturn x\n"+
+			"\t}")
+	test("function (ob) {\n"+
+		"  ob.Each({|x|\n"+
+		" Print(x[1..], ++x, x--) /* span */ })\n"+
+		" for (i=0;i<10;i++)\n"+
+		"\tPrint(i)\n"+
+		"\n\n\n"+
+		" x = a ? b : c\n"+
+		"switch x {\n"+
+		"case 1:\n"+
+		"  y = .F(a: 1, :b)\n"+
+		"default:\n"+
+		" z++\n"+
+		" }\n"+
+		"}",
+		"function (ob)\n"+
+			"\t{\n"+
+			"\tob.Each({|x|\n"+
+			"\t\tPrint(x[1..], ++x, x--) /* span */ })\n"+
+			"\tfor (i = 0; i < 10; i++)\n"+
+			"\t\tPrint(i)\n"+
+			"\n"+
+			"\tx = a ? b : c\n"+
+			"\tswitch x\n"+
+			"\t\t{\n"+
+			"\tcase 1:\n"+
+			"\t\ty = .F(a: 1, :b)\n"+
+			"\tdefault:\n"+
+			"\t\tz++\n"+
+			"\t\t}\n"+
+			"\t}")
+	test("class : Base {\n"+
+		" Name: 'x'\n"+
+		" New(a) {\n"+
+		" super.New(a) }\n"+
+		" Get(){ return .name } }",
+		"class : Base\n"+
+			"\t{\n"+
+			"\tName: 'x'\n"+
+			"\tNew(a)\n"+
+			"\t\t{\n"+
+			"\t\tsuper.New(a)\n"+
+			"\t\t}\n"+
+			"\tGet() { return .name }\n"+
+			"\t}")
+	test("function () {\n"+
+		" try { Foo() } catch (e) {\n"+
+		" Print(e) }\n"+
+		" do {\n"+
+		" x-- } while (x > 0)\n"+
+		" if not a and ~b\n"+
+		" return\n"+
+		" else if c\n"+
+		" { d() }\n"+
+		" forever\n"+
+		" Sleep(1)\n"+
+		"}",
+		"function ()\n"+
+			"\t{\n"+
+			"\ttry { Foo() } catch (e)\n"+
+			"\t\t{\n"+
+			"\t\tPrint(e)\n"+
+			"\t\t}\n"+
+			"\tdo\n"+
+			"\t\t{\n"+
+			"\t\tx--\n"+
+			"\t\t}\n"+
+			"\twhile (x > 0)\n"+
+			"\tif not a and ~b\n"+
+			"\t\treturn\n"+
+			"\telse if c\n"+
+			"\t\t{ d() }\n"+
+			"\tforever\n"+
+			"\t\tSleep(1)\n"+
+			"\t}")
+	assert.T(t).This(func() { Format("function () { a = }") }).
+		Panics("syntax error")
+}
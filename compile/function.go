@@ -4,6 +4,9 @@
 package compile
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/apmckinlay/gsuneido/compile/ast"
 	. "github.com/apmckinlay/gsuneido/compile/lexer"
 	tok "github.com/apmckinlay/gsuneido/compile/tokens"
@@ -74,13 +77,8 @@ func (p *Parser) params(inClass bool) []ast.Param {
 			p.MatchIdent()
 			p.checkForDupParam(params, name)
 			if p.MatchIf(tok.Eq) {
-				was_string := p.Token == tok.String || p.Token == tok.Symbol
 				defs = true
-				def := p.constant()
-				if _, ok := def.(SuStr); ok && !was_string {
-					p.Error("parameter defaults must be constants")
-				}
-				params = append(params, mkParam(name, pos, unused, def))
+				params = append(params, p.paramDefault(name, pos, unused))
 			} else {
 				if defs {
 					p.Error("default parameters must come last")
@@ -94,6 +92,53 @@ func (p *Parser) params(inClass bool) []ast.Param {
 	return params
 }
 
+// paramDefault handles a parameter default value.
+// Constants are stored in the ParamSpec,
+// other expressions are evaluated by the function prologue.
+// Constant syntax is tried first since it differs from expressions
+// e.g. (a, b) is an object constant and [a: 1] is a record constant.
+func (p *Parser) paramDefault(name string, pos int32, unused bool) ast.Param {
+	if def := p.paramConstant(); def != nil {
+		return mkParam(name, pos, unused, def)
+	}
+	start := p.Pos
+	e := p.Expression()
+	if c, ok := e.(*ast.Constant); ok {
+		return mkParam(name, pos, unused, c.Val)
+	}
+	if name[0] == '.' {
+		p.Error("dot parameter defaults must be constants")
+	}
+	src := strings.TrimSpace(p.Lxr.Source()[start:p.Pos])
+	param := mkParam(name, pos, unused, SuStr(src))
+	param.DefExpr = e
+	return param
+}
+
+// paramConstant parses a constant parameter default.
+// If the default is not a complete constant it returns nil
+// with the parser restored, so it can be parsed as an expression.
+// Unquoted names are not constants here, they are global references.
+func (p *Parser) paramConstant() (def Value) {
+	if p.Token == tok.Identifier &&
+		!(okBase(p.Text) && p.Lxr.AheadSkip(0).Token == tok.LCurly) {
+		return nil
+	}
+	lxr, item, newline := p.Lxr.Save(), p.Item, p.newline
+	defer func() {
+		if e := recover(); e != nil || def == nil {
+			p.Lxr.Restore(lxr)
+			p.Item, p.newline = item, newline
+			def = nil
+		}
+	}()
+	def = p.constant()
+	if p.Token != tok.Comma && p.Token != tok.RParen {
+		def = nil
+	}
+	return def
+}
+
 func mkParam(name string, pos int32, unused bool, def Value) ast.Param {
 	if name == "unused" || name == "@unused" {
 		unused = true
@@ -136,12 +181,66 @@ func (p *Parser) compound() []ast.Statement {
 func (p *Parser) statements() []ast.Statement {
 	list := []ast.Statement{}
 	for p.Token != tok.RCurly {
+		if p.recovering {
+			if p.Token == tok.Eof {
+				break
+			}
+			if stmt := p.recoverStatement(); stmt != nil {
+				list = append(list, stmt)
+			}
+			continue
+		}
 		stmt := p.statement()
 		list = append(list, stmt)
 	}
 	return list
 }
 
+// recoverStatement parses a statement, recording a syntax error
+// and skipping to the end of the statement instead of panicking.
+// It returns nil if there was an error.
+func (p *Parser) recoverStatement() (stmt ast.Statement) {
+	pos := p.Pos
+	defer func() {
+		if e := recover(); e != nil {
+			s, ok := e.(string)
+			if !ok || !strings.HasPrefix(s, "syntax error") {
+				panic(e)
+			}
+			p.errors = append(p.errors, s)
+			p.expectingCompound = false
+			p.skipStatement(pos)
+			stmt = nil
+		}
+	}()
+	return p.statement()
+}
+
+// skipStatement advances to the start of the next statement.
+// That is after a semicolon or before a newline
+// or before the closing curly brace of the enclosing compound.
+// Nested curly braces are skipped.
+func (p *Parser) skipStatement(pos int32) {
+	nest := 0
+	for p.Token != tok.Eof {
+		switch {
+		case p.Token == tok.LCurly:
+			nest++
+		case p.Token == tok.RCurly:
+			if nest == 0 {
+				return
+			}
+			nest--
+		case nest == 0 && p.Token == tok.Semicolon:
+			p.Next()
+			return
+		case nest == 0 && p.newline && p.Pos > pos:
+			return
+		}
+		p.Next()
+	}
+}
+
 var code = Item{Token: tok.LCurly, Text: "STMTS"}
 
 func (p *Parser) statement() ast.Statement {
@@ -188,13 +287,70 @@ func (p *Parser) statement2() ast.Statement {
 		return p.tryStmt()
 	case tok.Break:
 		p.Next()
-		return p.semi(&ast.Break{})
+		return p.semi(&ast.Break{Label: p.optLabel()})
 	case tok.Continue:
 		p.Next()
-		return p.semi(&ast.Continue{})
-	default:
-		return &ast.ExprStmt{E: p.trailingExpr()}
+		return p.semi(&ast.Continue{Label: p.optLabel()})
+	case tok.Identifier:
+		if p.Lxr.AheadSkip(0).Token == tok.Colon {
+			return p.labeled()
+		}
+	case tok.LBracket:
+		if p.isDestructure() {
+			return p.destructure()
+		}
+	}
+	return &ast.ExprStmt{E: p.trailingExpr()}
+}
+
+// isDestructure looks ahead for [...] =
+func (p *Parser) isDestructure() bool {
+	nest := 0
+	for i := 0; ; i++ {
+		switch p.Lxr.AheadSkip(i).Token {
+		case tok.LBracket:
+			nest++
+		case tok.RBracket:
+			if nest == 0 {
+				return p.Lxr.AheadSkip(i+1).Token == tok.Eq
+			}
+			nest--
+		case tok.Eof:
+			return false
+		}
+	}
+}
+
+// destructure handles [a, b] = ob and [x: a, y: b] = ob
+// by converting to: tmp = ob; a = tmp[0]; b = tmp[1]
+func (p *Parser) destructure() ast.Statement {
+	pos := int(p.Pos)
+	p.Match(tok.LBracket)
+	args := p.argumentList(tok.RBracket)
+	p.Match(tok.Eq)
+	tmp := "destructure|" + strconv.Itoa(pos)
+	stmts := []ast.Statement{
+		&ast.ExprStmt{E: p.Binary(p.Ident(tmp), tok.Eq, p.trailingExpr())}}
+	i := 0
+	for _, arg := range args {
+		p.ckLvalue(arg.E)
+		if id := localVar(arg.E); id != "" {
+			p.final[id] = disqualified
+		}
+		key := arg.Name
+		if key == nil {
+			key = IntVal(i)
+			i++
+		} else if key == SuStr("@") || key == SuStr("@+1") {
+			p.Error("invalid destructuring")
+		}
+		mem := &ast.Mem{E: p.Ident(tmp), M: p.Constant(key)}
+		stmts = append(stmts, &ast.ExprStmt{E: p.Binary(arg.E, tok.Eq, mem)})
 	}
+	for _, stmt := range stmts {
+		stmt.SetPos(pos)
+	}
+	return &ast.Compound{Body: stmts}
 }
 
 // trailingExpr gives a syntax error for two expressions side by side
@@ -275,6 +431,41 @@ func (p *Parser) switchBody() []ast.Statement {
 	return stmts
 }
 
+// labeled handles label: loop
+// for use with labeled break and continue
+func (p *Parser) labeled() ast.Statement {
+	label := p.Text
+	p.Next()
+	p.Match(tok.Colon)
+	stmt := p.statement2()
+	switch loop := stmt.(type) {
+	case *ast.Forever:
+		loop.Label = label
+	case *ast.While:
+		loop.Label = label
+	case *ast.DoWhile:
+		loop.Label = label
+	case *ast.For:
+		loop.Label = label
+	case *ast.ForIn:
+		loop.Label = label
+	default:
+		p.Error("label must be followed by a loop")
+	}
+	return stmt
+}
+
+// optLabel handles the optional label after break or continue.
+// It must be on the same line.
+func (p *Parser) optLabel() string {
+	if p.Token != tok.Identifier || p.newline {
+		return ""
+	}
+	label := p.Text
+	p.Next()
+	return label
+}
+
 func (p *Parser) foreverStmt() *ast.Forever {
 	body := p.statement()
 	return &ast.Forever{Body: body}
@@ -6,6 +6,7 @@ package compile
 import (
 	"encoding/base64"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/apmckinlay/gsuneido/compile/ast"
@@ -16,29 +17,68 @@ import (
 	"github.com/apmckinlay/gsuneido/util/str"
 )
 
+// GoGen transpiles a function or class constant to Go source code
+//
+// NOTE: The output is not yet self-contained Go.
+// t and this are free variables and functions are not wrapped as Values,
+// so it can not be compiled ahead of time (e.g. as a plugin).
+// Go plugins are also not supported on Windows.
 func GoGen(src string) string {
 	p := GogenParser(src)
-	f := p.constant().(*SuFunc)
+	v := p.constant()
 	if p.Token != tok.Eof {
 		p.Error("did not consume all input")
 	}
-	return f.Code
+	a := p.Aspects.(*gogenAspects)
+	switch v := v.(type) {
+	case *SuFunc:
+		return a.init.String() + v.Code
+	case *SuClass:
+		g := ggen{next: a.next}
+		g.class(v)
+		return a.init.String() + g.init.String() + g.String()
+	}
+	panic("gogen: unhandled constant " + ErrType(v))
 }
 
 // gogen compiles an ast.Function to Go source code placed in SuFunc.Code.
 // Using SuFunc for output is for compatibility with byte code codegen.
-func (*gogenAspects) codegen(_, _ string, f *ast.Function) Value {
+// The constant initialization is accumulated in gogenAspects
+// so nested functions and class methods don't have conflicting names.
+func (a *gogenAspects) codegen(_, _ string, f *ast.Function) Value {
 	if len(f.Final) > 0 {
 		ast.PropFold(f)
 	}
 	var g ggen
+	g.next = a.next
 	g.locals = make(map[string]struct{})
+	g.base = f.Base
 	g.function(f)
-	init := ""
-	if g.init.Len() > 0 {
-		init = g.init.String()
+	a.next = g.next
+	a.init.WriteString(g.init.String())
+	return &SuFunc{Code: g.String()}
+}
+
+// class generates a class as a map literal of its members,
+// preceded by a comment giving its base.
+// Methods refer to "this" the same way functions refer to "t".
+func (g *ggen) class(c *SuClass) {
+	base := "class"
+	if c.Base > 0 {
+		base = Global.Name(c.Base)
+	}
+	names := make([]string, 0, len(c.Data))
+	for name := range c.Data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	g.Adds("// class : ", base, "\nmap[string]Value{\n")
+	for _, name := range names {
+		g.Add(fmt.Sprintf("%q: ", name))
+		g.value(c.Data[name])
+		g.Add(",\n")
 	}
-	return &SuFunc{Code: init + g.String()}
+	g.Add("}")
 }
 
 type ggen struct {
@@ -46,6 +86,12 @@ type ggen struct {
 	next   int
 	init   strings.Builder
 	locals map[string]struct{}
+	// base is the base class of a method, used for super calls
+	base Gnum
+	// inBlock is true while generating the body of a block
+	inBlock bool
+	// loops is the loop nesting within the current function or block
+	loops int
 }
 
 func (g *ggen) function(fn *ast.Function) {
@@ -102,10 +148,20 @@ func (g *ggen) statement(node ast.Statement, lastStmt bool) {
 		g.forStmt(node)
 	case *ast.ForIn:
 		g.forinStmt(node)
+	case *ast.Switch:
+		g.switchStmt(node)
 	case *ast.Break:
-		g.Add("break")
+		if g.inBlock && g.loops == 0 {
+			g.Add("panic(BlockBreak)")
+		} else {
+			g.Add("break" + str.Opt(" ", node.Label))
+		}
 	case *ast.Continue:
-		g.Add("continue")
+		if g.inBlock && g.loops == 0 {
+			g.Add("panic(BlockContinue)")
+		} else {
+			g.Add("continue" + str.Opt(" ", node.Label))
+		}
 	case *ast.Throw:
 		g.throwStmt(node)
 		g.Add("\n")
@@ -132,6 +188,9 @@ func (g *ggen) statements(stmts []ast.Statement) {
 }
 
 func (g *ggen) returnStmt(expr ast.Expr) {
+	if g.inBlock {
+		panic("gogen: return from block not supported")
+	}
 	g.Add("return ")
 	if expr == nil {
 		g.Add("nil")
@@ -154,23 +213,74 @@ func (g *ggen) ifStmt(node *ast.If) {
 	}
 }
 
+// loopBody tracks loop nesting so break and continue
+// inside a block (but outside a loop) can be handled
+func (g *ggen) loopBody(body ast.Statement) {
+	g.loops++
+	g.statement(body, false)
+	g.loops--
+}
+
+func (g *ggen) switchStmt(node *ast.Switch) {
+	// use if-else rather than a Go switch
+	// because break in a Suneido switch applies to the enclosing loop
+	g.Add("if _sw_ := ")
+	g.expr(node.E, suvalue)
+	g.Add("; ")
+	for ci, c := range node.Cases {
+		if ci > 0 {
+			g.Add("} else if ")
+		}
+		for i, e := range c.Exprs {
+			if i > 0 {
+				g.Add(" || ")
+			}
+			g.Add("_sw_.Equal(")
+			g.expr(e, suvalue)
+			g.Add(")")
+		}
+		g.Add(" {\n")
+		g.statements(c.Body)
+	}
+	if len(node.Cases) == 0 {
+		g.Add("_sw_ == nil {\n")
+	}
+	g.Add("} else {\n")
+	if node.Default != nil { // specifically nil and not len 0
+		g.statements(node.Default)
+	} else {
+		g.Add("panic(\"unhandled switch value\")\n")
+	}
+	g.Add("}")
+}
+
+// label outputs a Go label for a labeled loop
+func (g *ggen) label(label string) {
+	if label != "" {
+		g.Add(label + ":\n")
+	}
+}
+
 func (g *ggen) foreverStmt(node *ast.Forever) {
+	g.label(node.Label)
 	g.Add("for {\n")
-	g.statement(node.Body, false)
+	g.loopBody(node.Body)
 	g.Add("}")
 }
 
 func (g *ggen) whileStmt(node *ast.While) {
+	g.label(node.Label)
 	g.Add("for ")
 	g.expr(node.Cond, gobool)
 	g.Add(" {\n")
-	g.statement(node.Body, false)
+	g.loopBody(node.Body)
 	g.Add("}")
 }
 
 func (g *ggen) dowhileStmt(node *ast.DoWhile) {
+	g.label(node.Label)
 	g.Add("for {\n")
-	g.statement(node.Body, false)
+	g.loopBody(node.Body)
 	g.Add("if !")
 	g.expr(node.Cond, gobool)
 	g.Add(" { break }\n}")
@@ -182,6 +292,7 @@ func (g *ggen) forStmt(node *ast.For) {
 		g.expr(e, void)
 		g.Add("\n")
 	}
+	g.label(node.Label)
 	g.Add("for ; ")
 	if node.Cond != nil {
 		g.expr(node.Cond, gobool)
@@ -201,7 +312,7 @@ func (g *ggen) forStmt(node *ast.For) {
 		g.Add(" }()")
 	}
 	g.Add(" {\n")
-	g.statement(node.Body, false)
+	g.loopBody(node.Body)
 	g.Add("}")
 }
 
@@ -210,12 +321,13 @@ func (g *ggen) forinStmt(node *ast.ForIn) {
 	if _, ok := g.locals[v]; !ok {
 		g.Adds("var ", v, " Value\n")
 	}
-	g.Add("for _it_ := OpIter(")
+	g.label(node.Label)
+	g.Add("for _it_ := OpIter(t, ")
 	g.expr(node.E, suvalue)
 	g.Adds("); ; {\n",
 		v, " = _it_.Next()\n"+
 			"if ", v, " == nil { break }\n")
-	g.statement(node.Body, false)
+	g.loopBody(node.Body)
 	g.Add("}")
 }
 
@@ -226,11 +338,17 @@ func (g *ggen) throwStmt(node *ast.Throw) {
 }
 
 func (g *ggen) trycatchStmt(node *ast.TryCatch) {
+	pos := g.Len()
 	g.Add("func() {\n" +
 		"defer func() {\n" +
 		"if _e_ := recover(); _e_ != nil {\n")
-	if node.CatchVar.Name != "" {
-		g.Adds(node.CatchVar.Name, " = ") //TODO := declare var ?
+	v := node.CatchVar.Name
+	if v != "" {
+		if _, ok := g.locals[v]; !ok {
+			g.Insert(pos, "var "+v+" Value\n")
+			g.locals[v] = struct{}{}
+		}
+		g.Adds(v, " = ")
 	}
 	g.Add(fmt.Sprintf("OpCatch(t, _e_, %q)\n", node.CatchFilter))
 	g.statement(node.Catch, false)
@@ -276,7 +394,17 @@ func (g *ggen) expr(node ast.Expr, want resultType) {
 	case *ast.Nary:
 		result = g.nary(node, want)
 	case *ast.Trinary:
-		g.trinary(node, want)
+		result = g.trinary(node, want)
+	case *ast.Mem:
+		g.Add("OpGet(t, ")
+		g.expr(node.E, suvalue)
+		g.Add(", ")
+		g.expr(node.M, suvalue)
+		g.Add(")")
+	case *ast.Call:
+		g.call(node)
+	case *ast.Block:
+		g.block(node)
 	default:
 		panic("unhandled expression: " + node.String())
 	}
@@ -292,9 +420,14 @@ func (g *ggen) expr(node ast.Expr, want resultType) {
 }
 
 func (g *ggen) ident(node *ast.Ident) {
-	c := node.Name[0]
-	if ascii.IsLower(c) {
-		g.Add(node.Name) // local
+	name := node.Name
+	if ascii.IsLower(name[0]) {
+		g.Add(name) // local (including this)
+	} else if okBase(name) {
+		if name[0] == '_' {
+			name = name[1:]
+		}
+		g.Adds("Global.GetName(t, ", fmt.Sprintf("%q", name), ")")
 	} else {
 		panic("unhandled identifier " + node.Name)
 	}
@@ -315,6 +448,8 @@ func (g *ggen) value(val Value) {
 		g.Add("EmptyStr")
 	} else if f, ok := val.(*SuFunc); ok {
 		g.Add(f.Code) //TODO needs to be SuBuiltin with ParamSpec
+	} else if c, ok := val.(*SuClass); ok {
+		g.class(c)
 	} else {
 		g.Add(g.pack64(val))
 	}
@@ -480,7 +615,7 @@ func (g *ggen) localVar(node ast.Expr) string {
 var opfn = map[tok.Token]string{
 	tok.Add:      "OpAdd(",
 	tok.Sub:      "OpSub(",
-	tok.Cat:      "OpCat(",
+	tok.Cat:      "OpCat(t, ",
 	tok.BitAnd:   "OpBitAnd(",
 	tok.BitOr:    "OpBitOr(",
 	tok.BitXor:   "OpBitXor(",
@@ -553,7 +688,9 @@ func (g *ggen) nary(node *ast.Nary, want resultType) resultType {
 	// else Add, Sub, Cat, BitOr, BitAnd, BitXor
 	// left associative, so work backwards to generate operations
 	for i := len(node.Exprs) - 1; i > 0; i-- {
-		if isUnary(node.Exprs[i], tok.Sub) {
+		if node.Tok != tok.Add {
+			g.Add(opfn[node.Tok])
+		} else if isUnary(node.Exprs[i], tok.Sub) {
 			g.Add("OpSub(")
 		} else {
 			g.Add("OpAdd(")
@@ -563,7 +700,7 @@ func (g *ggen) nary(node *ast.Nary, want resultType) resultType {
 	g.expr(node.Exprs[0], suvalue)
 	for _, e := range node.Exprs[1:] {
 		g.Add(", ")
-		if u, ok := e.(*ast.Unary); ok && u.Tok == tok.Sub {
+		if u, ok := e.(*ast.Unary); ok && u.Tok == tok.Sub && node.Tok == tok.Add {
 			e = u.E
 		}
 		g.expr(e, suvalue)
@@ -640,3 +777,130 @@ func (g *ggen) trinary(node *ast.Trinary, want resultType) resultType {
 	g.Add(" } }()")
 	return suvalue
 }
+
+var superNewCall = &ast.Mem{
+	E: &ast.Ident{Name: "super"},
+	M: &ast.Constant{Val: SuStr("New")}}
+
+func (g *ggen) call(node *ast.Call) {
+	fn := node.Fn
+	if id, ok := fn.(*ast.Ident); ok && id.Name == "super" {
+		fn = superNewCall // super(...) => super.New(...)
+	}
+	mem, ok := fn.(*ast.Mem)
+	if !ok {
+		if stdArgs(node.Args) {
+			g.Add("t.Call(")
+			g.expr(fn, suvalue)
+		} else {
+			g.Add("t.PushCall(")
+			g.expr(fn, suvalue)
+			g.Add(", nil, ")
+			g.argspec(node.Args)
+		}
+		g.args(node.Args)
+		g.Add(")")
+		return
+	}
+	if x, ok := mem.E.(*ast.Ident); ok && x.Name == "super" {
+		if g.base <= 0 {
+			panic("super requires parent")
+		}
+		g.Add("t.PushCall(t.Lookup(")
+		g.ident(&ast.Ident{Name: Global.Name(g.base)})
+		g.Add(", ")
+		g.method(mem.M)
+		g.Add("), this, ")
+		g.argspec(node.Args)
+	} else if stdArgs(node.Args) {
+		g.Add("t.CallLookup(")
+		g.expr(mem.E, suvalue)
+		g.Add(", ")
+		g.method(mem.M)
+	} else {
+		// the object is needed twice, for the lookup and as this
+		g.Add("func() Value { _ob_ := ")
+		g.expr(mem.E, suvalue)
+		g.Add("; return t.PushCall(t.Lookup(_ob_, ")
+		g.method(mem.M)
+		g.Add("), _ob_, ")
+		g.argspec(node.Args)
+		g.args(node.Args)
+		g.Add(") }()")
+		return
+	}
+	g.args(node.Args)
+	g.Add(")")
+}
+
+// stdArgs returns whether the arguments are simple (unnamed) values
+func stdArgs(args []ast.Arg) bool {
+	for _, arg := range args {
+		if arg.Name != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// method generates the method name for a Lookup
+func (g *ggen) method(m ast.Expr) {
+	if c, ok := m.(*ast.Constant); ok {
+		if s, ok := c.Val.(SuStr); ok {
+			g.Add(fmt.Sprintf("%q", string(s)))
+			return
+		}
+	}
+	g.Add("ToStr(")
+	g.expr(m, suvalue)
+	g.Add(")")
+}
+
+// argspec generates a reference to the ArgSpec for the arguments
+func (g *ggen) argspec(args []ast.Arg) {
+	if stdArgs(args) && len(args) <= 4 {
+		g.Add(fmt.Sprintf("&ArgSpec%d", len(args)))
+		return
+	}
+	if len(args) == 1 && args[0].Name == SuStr("@") {
+		g.Add("&ArgSpecEach0")
+		return
+	}
+	if len(args) == 1 && args[0].Name == SuStr("@+1") {
+		g.Add("&ArgSpecEach1")
+		return
+	}
+	var spec, names []string
+	for _, arg := range args {
+		if arg.Name != nil {
+			spec = append(spec, fmt.Sprint(len(names)))
+			names = append(names, g.pack64(arg.Name))
+		}
+	}
+	g.Add(fmt.Sprintf("&ArgSpec{Nargs: %d, Spec: []uint16{%s}, Names: []Value{%s}}",
+		len(args), strings.Join(spec, ", "), strings.Join(names, ", ")))
+}
+
+// args generates the argument values, each preceded by a comma
+func (g *ggen) args(args []ast.Arg) {
+	for _, arg := range args {
+		g.Add(", ")
+		g.expr(arg.E, suvalue)
+	}
+}
+
+// block generates a Go closure.
+// Go closures share the enclosing variables like Suneido blocks,
+// but the block's own variables are local to it.
+func (g *ggen) block(b *ast.Block) {
+	outer := g.locals
+	g.locals = make(map[string]struct{}, len(outer))
+	for v := range outer {
+		g.locals[v] = struct{}{}
+	}
+	inBlock, loops := g.inBlock, g.loops
+	g.inBlock, g.loops = true, 0
+	g.function(&b.Function)
+	g.inBlock, g.loops = inBlock, loops
+	g.locals = outer
+}
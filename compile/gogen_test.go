@@ -100,6 +100,8 @@ func TestGoGen(t *testing.T) {
 	test("a + b + c", "return OpAdd(OpAdd(a, b), c)")
 	test("a + b - c", "return OpSub(OpAdd(a, b), c)")
 	test("a - b + c", "return OpAdd(OpSub(a, b), c)")
+	test("a $ b $ c", "return OpCat(t, OpCat(t, a, b), c)")
+	test("a | b;;", "OpBitOr(a, b)")
 	test("a and b and c;;", "(OpBool(a) && OpBool(b) && OpBool(c))")
 	test("a or b or c", "return SuBool(OpBool(a) || OpBool(b) || OpBool(c))")
 	test("a / b * c / d;;", "OpDiv(OpMul(a, c), OpMul(b, d))")
@@ -171,7 +173,7 @@ func TestGoGen(t *testing.T) {
         d
 		}`)
 	test("for x in a { b }", `var x Value
-        for _it_ := OpIter(a); ; {
+        for _it_ := OpIter(t, a); ; {
         x = _it_.Next()
         if x == nil { break }
         b
@@ -186,7 +188,8 @@ func TestGoGen(t *testing.T) {
 		}()
 		a
 		}()`)
-	test("try a catch(x, 'uninit') b", `func() {
+	test("try a catch(x, 'uninit') b", `var x Value
+		func() {
 		defer func() {
 			if _e_ := recover(); _e_ != nil {
 				x = OpCatch(t, _e_, "uninit")
@@ -196,4 +199,90 @@ func TestGoGen(t *testing.T) {
 		a
 		}()`)
 	test("'hello'", "return _c0_")
+	test("try a catch(b) c", `func() {
+		defer func() {
+			if _e_ := recover(); _e_ != nil {
+				b = OpCatch(t, _e_, "")
+				c
+			}
+		}()
+		a
+		}()`)
+
+	test("a.b", "return OpGet(t, a, _c0_)")
+	test("Foo", `return Global.GetName(t, "Foo")`)
+	test("a(b, c)", "return t.Call(a, b, c)")
+	test("Foo(a);;", `t.Call(Global.GetName(t, "Foo"), a)`)
+	test("a(@b)", "return t.PushCall(a, nil, &ArgSpecEach0, b)")
+	test("a(b, c: d)", "return t.PushCall(a, nil, "+
+		"&ArgSpec{Nargs: 2, Spec: []uint16{0}, Names: []Value{_c0_}}, b, d)")
+	test("a.Size()", `return t.CallLookup(a, "Size")`)
+	test("a[b](c)", "return t.CallLookup(a, ToStr(b), c)")
+	test("a.F(b: c)", `return func() Value { _ob_ := a; `+
+		`return t.PushCall(t.Lookup(_ob_, "F"), _ob_, `+
+		`&ArgSpec{Nargs: 1, Spec: []uint16{0}, Names: []Value{_c0_}}, c) }()`)
+
+	test("switch (a) { case b, c: d case 1: break }", `
+		if _sw_ := a; _sw_.Equal(b) || _sw_.Equal(c) {
+		d
+		} else if _sw_.Equal(One) {
+		break
+		} else {
+		panic("unhandled switch value")
+		}`)
+	test("switch a { default: b }", `
+		if _sw_ := a; _sw_ == nil {
+		} else {
+		b
+		}`)
+
+	test("x = {|y| a + y };;", `(x := func(y Value) Value {
+		return OpAdd(a, y)
+		})`)
+	test("x = { y = a; y };;", `(x := func() Value {
+		(y := a)
+		return y
+		})`)
+	test("x = { break };;", `(x := func() Value {
+		panic(BlockBreak)
+		return nil
+		})`)
+	test("x = { forever { continue } };;", `(x := func() Value {
+		for {
+		continue
+		}
+		return nil
+		})`)
+	assert.T(t).This(func() { GoGen("function () { b = { return 1 } }") }).
+		Panics("return from block not supported")
+}
+
+func TestGoGenClass(t *testing.T) {
+	code := GoGen(`Base
+		{
+		Size: 123
+		New(a)
+			{
+			super(a)
+			}
+		Get(a)
+			{
+			return super.Get(a) $ .Name
+			}
+		}`)
+	assert.T(t).This(code).Like(`
+		var _c0_ = Unpack64(` + "`BE5hbWU=`" + `)
+		var _c1_ = Unpack64(` + "`A4MMHg==`" + `)
+		// class : Base
+		map[string]Value{
+		"Get": func(a Value) Value {
+		return OpCat(t, t.PushCall(t.Lookup(Global.GetName(t, "Base"), "Get"), ` +
+		`this, &ArgSpec1, a), OpGet(t, this, _c0_))
+		},
+		"New": func(a Value) Value {
+		return t.PushCall(t.Lookup(Global.GetName(t, "Base"), "New"), ` +
+		`this, &ArgSpec1, a)
+		},
+		"Size": _c1_,
+		}`)
 }
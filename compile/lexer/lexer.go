@@ -96,6 +96,20 @@ func (lxr *Lexer) AheadSkip(i int) Item {
 	}
 }
 
+// Save returns a copy of the lexer state so it can be restored
+// e.g. to backtrack after a failed parse
+func (lxr *Lexer) Save() Lexer {
+	save := *lxr
+	save.ahead = append([]Item(nil), lxr.ahead...)
+	return save
+}
+
+// Restore sets the lexer state from Save
+func (lxr *Lexer) Restore(save Lexer) {
+	*lxr = save
+	lxr.ahead = append([]Item(nil), save.ahead...)
+}
+
 // Remainder is used by ParseAdmin to get view definitions
 func (lxr *Lexer) Remainder() string {
 	assert.That(len(lxr.ahead) == 0)
@@ -89,6 +89,13 @@ type Parser struct {
 	// itUsed records whether an "it" variable is used
 	// to know whether to add an automatic "it" parameter to blocks
 	itUsed bool
+
+	// recovering is set by SyntaxErrors to resynchronize
+	// at statement boundaries after a syntax error
+	recovering bool
+
+	// errors collects the syntax errors when recovering
+	errors []string
 }
 
 type funcInfo struct {
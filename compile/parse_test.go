@@ -229,6 +229,10 @@ func TestParseParams(t *testing.T) {
 	test("(a,b=1)")
 	test("(_a,_b=1)")
 	test("(.a,._b=1)")
+	test("(a,b=-1)")
+	test("(a,b=c)")
+	assert.T(t).This(func() { NewParser("(.a = b) {}").function(true) }).
+		Panics("dot parameter defaults must be constants")
 }
 
 func TestParseStatements(t *testing.T) {
@@ -293,6 +297,13 @@ func TestParseStatements(t *testing.T) {
 
 	test("continue", "Continue")
 
+	// labeled loops
+	test("outer: forever { break outer }", "outer: Forever(Break outer)")
+	test("outer: while a { continue outer }", "outer: While(a Continue outer)")
+	test("a: for x in y { b: for (;;) { break a } }",
+		"a: ForIn(x y\nb: For(; ; \nBreak a))")
+	test("forever { break \n x }", "Forever({ \n Break \n x \n })")
+
 	// do-while
 	test("do stmt while e", "DoWhile(stmt e)")
 
@@ -339,3 +350,25 @@ func TestParseStatements(t *testing.T) {
 	xtest("return 1+2 3+4", "syntax error")
 	xtest("throw 1+2 3+4", "syntax error")
 }
+
+func TestSyntaxErrors(t *testing.T) {
+	test := func(src string, expected ...string) {
+		t.Helper()
+		assert.T(t).This(SyntaxErrors(src)).Is(expected)
+	}
+	test("function () { a = 1 }", []string(nil)...)
+	test("123", []string(nil)...)
+	test("function () { a = * 1; b = 2 }",
+		"syntax error @18 unexpected '*'")
+	test("function () {\n a = * 1 \n b = \n c = ) \n d = 1 }",
+		"syntax error @19 unexpected '*'",
+		"syntax error @35 unexpected ')'")
+	test("function () {\n if a { b = * } \n foo bar \n }",
+		"syntax error @26 unexpected '*'",
+		"syntax error @36 ")
+	test("function () {\n a = ( \n",
+		"syntax error @22 unexpected Eof",
+		"syntax error @22 expecting RCurly")
+	test("function (a,,b) { }",
+		"syntax error @12 expecting identifier")
+}
@@ -0,0 +1,141 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"sync"
+
+	"github.com/apmckinlay/gsuneido/db19/index"
+	"github.com/apmckinlay/gsuneido/db19/meta"
+	rt "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/ints"
+	"github.com/apmckinlay/gsuneido/util/strs"
+)
+
+// AutoNumberBlock is how many numbers a sequence reserves at a time
+var AutoNumberBlock = 100
+
+// sequences are the in memory state of the Schema.AutoNumber sequences.
+//
+// Numbers are handed out from a reserved block.
+// The transaction that reserves a block records its limit in Info.Seq
+// so it is persisted when the transaction commits.
+// This means concurrent transactions only contend for a short lock
+// and the meta data is only updated once per block.
+//
+// A sequence (e.g. after a restart or a crash) starts after
+// the larger of Info.Seq and the largest existing value,
+// so numbers in committed records are never reused
+// even if the commit was only recovered from the wal.
+// Numbers used by aborted transactions and unused blocks are skipped.
+type sequences struct {
+	lock sync.Mutex
+	seqs map[string]*sequence
+}
+
+type sequence struct {
+	// ts is the schema the block was reserved with.
+	// If the table is changed (e.g. loaded) a new block is reserved.
+	ts    *meta.Schema
+	next  int
+	limit int
+}
+
+// autoNumber returns the record with the AutoNumber column set
+// to the next number if it is empty, otherwise it returns the record as is
+func (t *UpdateTran) autoNumber(ts *meta.Schema, rec rt.Record) rt.Record {
+	if ts.AutoNumber == "" || t.replay {
+		return rec
+	}
+	fld := strs.Index(ts.Columns, ts.AutoNumber)
+	if fld < 0 {
+		return rec
+	}
+	if raw := rec.GetRaw(fld); raw != "" {
+		if n, ok := rt.Unpack(raw).IfInt(); ok {
+			t.db.usedNumber(ts, n)
+		}
+		return rec
+	}
+	n := t.db.nextNumber(t, ts)
+	var b rt.RecordBuilder
+	for i := 0; i < rec.Count() || i <= fld; i++ {
+		if i == fld {
+			b.Add(rt.IntVal(n).(rt.Packable))
+		} else {
+			b.AddRaw(rec.GetRaw(i))
+		}
+	}
+	return b.Build()
+}
+
+func (sq *sequences) nextNumber(t *UpdateTran, ts *meta.Schema) int {
+	sq.lock.Lock()
+	defer sq.lock.Unlock()
+	s := sq.get(ts)
+	if s.next >= s.limit {
+		ti := t.getInfo(ts.Table)
+		s.next = ints.Max(s.limit, ints.Max(ti.Seq, maxNumber(t, ts)+1))
+		s.limit = s.next + AutoNumberBlock
+		ti.Seq = s.limit
+	}
+	n := s.next
+	s.next++
+	return n
+}
+
+// usedNumber skips numbers that were given explicitly
+func (sq *sequences) usedNumber(ts *meta.Schema, n int) {
+	sq.lock.Lock()
+	defer sq.lock.Unlock()
+	if s := sq.get(ts); n >= s.next {
+		s.next = n + 1
+		if s.next > s.limit {
+			s.limit = s.next // so the next block starts after it
+		}
+	}
+}
+
+func (sq *sequences) get(ts *meta.Schema) *sequence {
+	if sq.seqs == nil {
+		sq.seqs = make(map[string]*sequence)
+	}
+	s := sq.seqs[ts.Table]
+	if s == nil {
+		s = &sequence{}
+		sq.seqs[ts.Table] = s
+	}
+	if s.ts != ts {
+		s.ts = ts
+		s.next = s.limit // reserve a new block
+	}
+	return s
+}
+
+// maxNumber returns the largest number in the AutoNumber column, or 0.
+// It does not track the reads, so it does not conflict
+// with other transactions outputting numbers.
+func maxNumber(t *UpdateTran, ts *meta.Schema) int {
+	for i := range ts.Indexes {
+		if strs.Equal(ts.Indexes[i].Columns, []string{ts.AutoNumber}) {
+			iter := index.NewOverIter(ts.Table, i)
+			ut := untracked{t}
+			for iter.Prev(ut); !iter.Eof(); iter.Prev(ut) {
+				key, _ := iter.Cur()
+				if n, ok := rt.Unpack(key).IfInt(); ok {
+					return n
+				}
+			}
+			break
+		}
+	}
+	return 0
+}
+
+type untracked struct {
+	*UpdateTran
+}
+
+func (untracked) Read(string, int, string, string) {
+}
@@ -0,0 +1,222 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/apmckinlay/gsuneido/db19/stor"
+)
+
+// Incremental backup takes advantage of the database file being append only.
+// Each persist writes a state at the end of the file, so the file up to the
+// end of a persisted state is a complete database.
+// A backup file holds the bytes of the database file from one persisted state
+// (or the start of the file) to a later persisted state.
+// A full backup starts from 0, an incremental backup from the end of the
+// previous backup. Restore concatenates a full backup and its chain of
+// incremental backups.
+//
+// Compact rewrites the database to a new file so it invalidates the chain,
+// the next backup must be a full backup.
+//
+// Backup file format:
+//	magic		8 bytes
+//	from		8 bytes
+//	to			8 bytes
+//	data		to - from bytes
+//	crc32		4 bytes (of the data)
+
+const backupMagic = "gsbak001"
+
+const backupHdrLen = len(backupMagic) + 8 + 8
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Backup writes the database file from offset from
+// up to the end of the last persisted state to a backup file.
+// from should be 0 for a full backup,
+// or the result of the previous Backup for an incremental backup.
+// It returns the offset to use as from for the next incremental backup.
+// Backup runs concurrently with reads and writes.
+func (db *Database) Backup(filename string, from uint64) (uint64, error) {
+	state := db.Persist()
+	if state.stateOff == 0 {
+		return 0, errors.New("backup: no persisted state")
+	}
+	store := state.store
+	to := state.stateOff + uint64(stateLen)
+	if from > to {
+		return 0, errors.New("backup: previous backup is past the end")
+	}
+	if from != 0 && !isStateEnd(store, from) {
+		return 0, errors.New("backup: previous backup is not from this " +
+			"database file (compacted?), a full backup is required")
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return 0, err
+	}
+	err = writeBackup(f, store, from, to)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(filename)
+		return 0, fmt.Errorf("backup: %w", err)
+	}
+	return to, nil
+}
+
+// isStateEnd returns whether off is the end of a state
+func isStateEnd(store *stor.Stor, off uint64) (result bool) {
+	if off < uint64(stateLen) {
+		return false
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			result = false
+		}
+	}()
+	readState(store, off-uint64(stateLen))
+	return true
+}
+
+// writeBackup writes the header, the data from the store, and the checksum.
+// It is also used to send replication deltas.
+func writeBackup(f io.Writer, store *stor.Stor, from, to uint64) error {
+	w := bufio.NewWriter(f)
+	hdr := make([]byte, backupHdrLen)
+	copy(hdr, backupMagic)
+	binary.BigEndian.PutUint64(hdr[len(backupMagic):], from)
+	binary.BigEndian.PutUint64(hdr[len(backupMagic)+8:], to)
+	w.Write(hdr)
+	crc := uint32(0)
+	for off := from; off < to; {
+		buf := store.Data(off)
+		if n := to - off; uint64(len(buf)) > n {
+			buf = buf[:n]
+		}
+		crc = crc32.Update(crc, crcTable, buf)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		off += uint64(len(buf))
+	}
+	binary.BigEndian.PutUint32(hdr, crc)
+	w.Write(hdr[:4])
+	return w.Flush()
+}
+
+// BackupRange returns the range of the database file in a backup file.
+// The to of the last backup in a chain is the from for the next one.
+func BackupRange(filename string) (from, to uint64, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	return readBackupHdr(f)
+}
+
+func readBackupHdr(r io.Reader) (from, to uint64, err error) {
+	hdr := make([]byte, backupHdrLen)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return 0, 0, err
+	}
+	if string(hdr[:len(backupMagic)]) != backupMagic {
+		return 0, 0, errors.New("not a backup file")
+	}
+	from = binary.BigEndian.Uint64(hdr[len(backupMagic):])
+	to = binary.BigEndian.Uint64(hdr[len(backupMagic)+8:])
+	return from, to, nil
+}
+
+// Restore creates a database file from a full backup
+// followed by its incremental backups, in order.
+// An existing database file is renamed to .bak
+func Restore(dbfile string, backups ...string) (err error) {
+	if len(backups) == 0 {
+		return errors.New("restore: no backup files")
+	}
+	dst, err := ioutil.TempFile(filepath.Dir(dbfile), "gs*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpfile := dst.Name()
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(tmpfile)
+		}
+	}()
+	w := bufio.NewWriter(dst)
+	size := uint64(0)
+	for _, bf := range backups {
+		if size, err = restore1(w, bf, size); err != nil {
+			return fmt.Errorf("restore: %s: %w", bf, err)
+		}
+	}
+	// the size in the backed up file header is only valid after Close
+	// so set it from the last backup
+	hdr := make([]byte, len(magic)+stor.SmallOffsetLen)
+	copy(hdr, magic)
+	stor.WriteSmallOffset(hdr[len(magic):], size)
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if _, err = dst.WriteAt(hdr, 0); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	if err = CheckDatabase(tmpfile); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	return RenameBak(tmpfile, dbfile)
+}
+
+// restore1 appends the data from one backup file.
+// size is the current size of the restored file,
+// it must match the start of the backup.
+func restore1(w io.Writer, filename string, size uint64) (uint64, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	from, to, err := readBackupHdr(r)
+	if err != nil {
+		return 0, err
+	}
+	if from != size {
+		if from == 0 {
+			return 0, errors.New("full backup must be first")
+		}
+		return 0, fmt.Errorf("out of sequence, starts at %d, expected %d",
+			from, size)
+	}
+	h := crc32.New(crcTable)
+	if _, err = io.CopyN(io.MultiWriter(w, h), r, int64(to-from)); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 4)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	if binary.BigEndian.Uint32(buf) != h.Sum32() {
+		return 0, errors.New("checksum error")
+	}
+	return to, nil
+}
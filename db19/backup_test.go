@@ -0,0 +1,64 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"os"
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestBackup(t *testing.T) {
+	assert := assert.T(t)
+	files := []string{"tmp.db", "tmp.bk0", "tmp.bk1", "tmp.bk2",
+		"tmp2.db", "tmp2.db.bak"}
+	defer func() {
+		for _, f := range files {
+			os.Remove(f)
+		}
+	}()
+	db := createDb()
+	db.CheckerSync()
+	_, err := db.Backup("tmp.bk0", 0)
+	assert.This(err).Isnt(nil) // nothing persisted yet
+
+	output := func(n int) {
+		for i := 0; i < n; i++ {
+			db.CommitMerge(output1(db))
+		}
+		db.persist(&execPersistSingle{}, false)
+	}
+	output(100)
+	end0, err := db.Backup("tmp.bk0", 0)
+	ck(err)
+	output(100)
+	end1, err := db.Backup("tmp.bk1", end0)
+	ck(err)
+	from, to, err := BackupRange("tmp.bk1")
+	ck(err)
+	assert.This(from).Is(end0)
+	assert.This(to).Is(end1)
+	output(100)
+	_, err = db.Backup("tmp.bk2", end1)
+	ck(err)
+	_, err = db.Backup("tmp.bk2", end1+1)
+	assert.This(err).Isnt(nil) // not the end of a state
+	db.Close()
+
+	ck(Restore("tmp2.db", "tmp.bk0", "tmp.bk1"))
+	db, err = OpenDatabaseRead("tmp2.db")
+	ck(err)
+	assert.This(db.NewReadTran().GetInfo("mytable").Nrows).Is(200)
+	db.Close()
+
+	ck(Restore("tmp2.db", "tmp.bk0", "tmp.bk1", "tmp.bk2"))
+	db, err = OpenDatabaseRead("tmp2.db")
+	ck(err)
+	assert.This(db.NewReadTran().GetInfo("mytable").Nrows).Is(300)
+	db.Close()
+
+	assert.This(Restore("tmp2.db", "tmp.bk1")).Isnt(nil)
+	assert.This(Restore("tmp2.db", "tmp.bk0", "tmp.bk2")).Isnt(nil)
+}
@@ -4,15 +4,16 @@
 package db19
 
 import (
-	"log"
 	"math"
 	"math/rand"
 	"strconv"
 	"sync/atomic"
+	"time"
 
 	"github.com/apmckinlay/gsuneido/util/assert"
 	"github.com/apmckinlay/gsuneido/util/ordset"
 	"github.com/apmckinlay/gsuneido/util/ranges"
+	"github.com/apmckinlay/gsuneido/util/strs"
 )
 
 const maxTrans = 200
@@ -29,7 +30,8 @@ type Ranges = ranges.Ranges
 // Actions are checked as they are done, incrementally.
 // A conflict with a completed transaction aborts the current transaction.
 // A conflict with an outstanding (not completed) transaction
-// randomly aborts one of the two transactions.
+// randomly aborts one of the two transactions,
+// or waits for it, depending on Conflicts.
 // The checker serializes transaction commits.
 // A single sequence counter is used to assign unique start and end values.
 // See CheckCo for the concurrent channel based interface to Check.
@@ -44,6 +46,9 @@ type Check struct {
 	trans map[int]*CkTran
 	// exclusive controls access to tables
 	exclusive map[string]int
+	// waits is the waits-for graph, from a waiting transaction
+	// to the transaction it is waiting for (see Conflicts)
+	waits map[int]int
 }
 
 type CkTran struct {
@@ -53,6 +58,13 @@ type CkTran struct {
 	tables   map[string]*cktbl
 	state    *DbState
 	conflict atomic.Value // string
+	// done is closed when the transaction commits or aborts
+	// for transactions waiting on it (see Conflicts)
+	done chan void
+	// bulk is the tables that a bulk transaction has exclusive access to
+	bulk []string
+	// limits is the TranLimits for the transaction
+	limits atomic.Value
 }
 
 type cktbl struct {
@@ -74,7 +86,7 @@ func (t *CkTran) String() string {
 
 func NewCheck(db *Database) *Check {
 	return &Check{db: db, trans: make(map[int]*CkTran), oldest: math.MaxInt,
-		exclusive: make(map[string]int)}
+		exclusive: make(map[string]int), waits: make(map[int]int)}
 }
 
 func (ck *Check) Run(fn func() error) error {
@@ -91,7 +103,8 @@ func (ck *Check) StartTran() *CkTran {
 		state = ck.db.GetState()
 	}
 	t := &CkTran{start: start, end: math.MaxInt, birth: ck.clock,
-		tables: make(map[string]*cktbl), state: state}
+		tables: make(map[string]*cktbl), state: state, done: make(chan void)}
+	t.limits.Store(ck.tranLimits())
 	ck.trans[start] = t
 	return t
 }
@@ -116,6 +129,33 @@ func (ck *Check) AddExclusive(tables ...string) bool {
 	return true
 }
 
+// StartBulk starts a bulk transaction with exclusive access to tables.
+// Its writes are not recorded since no other transaction can write
+// these tables until it ends, which also ends the exclusive access.
+// Instead, reads of these tables by overlapping transactions conflict
+// (see Read), as if the bulk transaction wrote the whole table.
+// It returns nil if the tables have outstanding reads or writes.
+func (ck *Check) StartBulk(tables ...string) *CkTran {
+	for _, table := range tables {
+		for _, t2 := range ck.trans {
+			if tbl, ok := t2.tables[table]; ok && !t2.isEnded() &&
+				len(tbl.reads) > 0 {
+				return nil
+			}
+		}
+	}
+	if !ck.AddExclusive(tables...) {
+		return nil
+	}
+	t := ck.StartTran()
+	if t == nil {
+		ck.EndExclusive(tables...)
+		return nil
+	}
+	t.bulk = tables
+	return t
+}
+
 func (ck *Check) EndExclusive(tables ...string) {
 	end := ck.next()
 	for _, table := range tables {
@@ -143,6 +183,11 @@ func (ck *Check) Read(t *CkTran, table string, index int, from, to string) bool
 	// check against overlapping transactions
 	for _, t2 := range ck.trans {
 		if t2 != t && overlap(t, t2) {
+			if t2.bulk != nil && strs.Contains(t2.bulk, table) {
+				ck.abort(t.start, "read in this transaction conflicted with "+
+					"bulk write in another transaction")
+				return false
+			}
 			if tbl, ok := t2.tables[table]; ok {
 				if tbl.writes.anyInRange(index, from, to) {
 					if ck.abort1of(t, t2, "read", "write") {
@@ -185,16 +230,32 @@ func (cr ckreads) contains(index int, key string) bool {
 // The keys are parallel with the indexes i.e. keys[i] is for indexes[i].
 // Updates require two calls, one with the old keys, another with the new keys.
 // NOTE: Even if an update doesn't change a key, it still has to register it.
+//
+// Check is synchronous so it can not wait.
+// If Conflicts says to wait, the transaction is aborted instead.
 func (ck *Check) Write(t *CkTran, table string, keys []string) bool {
+	ok, wait := ck.write(t, table, keys)
+	if wait != nil {
+		ck.abort(t.start, "write in this transaction conflicted with "+
+			"write in another transaction")
+		return false
+	}
+	return ok
+}
+
+// write returns a channel to wait on if the transaction should wait
+// for a conflicting transaction to finish and then retry.
+func (ck *Check) write(t *CkTran, table string, keys []string) (bool, chan void) {
 	traceln("T", t.start, "write", table, "keys", keys)
 	t, ok := ck.trans[t.start]
 	if !ok {
-		return false // it's gone, presumably aborted
+		return false, nil // it's gone, presumably aborted
 	}
 	assert.That(!t.isEnded())
+	delete(ck.waits, t.start) // no longer waiting
 	if t.start < ck.exclusive[table] {
 		ck.abort(t.start, "conflict with index creation ("+table+")")
-		return false
+		return false, nil
 	}
 	// check against overlapping transactions
 	for _, t2 := range ck.trans {
@@ -210,8 +271,16 @@ func (ck *Check) Write(t *CkTran, table string, keys []string) bool {
 						} else {
 							continue
 						}
+						if act2 == "write" && Conflicts != ConflictAbort &&
+							!t2.isEnded() {
+							aborted, wait := ck.waitOrAbort(t, t2)
+							if aborted || wait != nil {
+								return false, wait
+							}
+							break // t2 was aborted
+						}
 						if ck.abort1of(t, t2, "write", act2) {
-							return false // this transaction got aborted
+							return false, nil // this transaction got aborted
 						}
 					}
 				}
@@ -219,7 +288,7 @@ func (ck *Check) Write(t *CkTran, table string, keys []string) bool {
 		}
 	}
 	t.saveWrite(table, keys)
-	return true
+	return true, nil
 }
 
 func (t *CkTran) saveWrite(table string, keys []string) {
@@ -255,6 +324,93 @@ func (cw ckwrites) with(index int, key string) ckwrites {
 	return cw
 }
 
+// ConflictPolicy determines how a write-write conflict
+// with an outstanding (not completed) transaction is handled.
+type ConflictPolicy int
+
+const (
+	// ConflictAbort randomly aborts one of the two transactions
+	ConflictAbort ConflictPolicy = iota
+	// ConflictWaitDie makes an older transaction wait for a younger one
+	// and aborts a younger transaction that conflicts with an older one
+	ConflictWaitDie
+	// ConflictWoundWait aborts a younger transaction that conflicts
+	// with an older one and makes a younger transaction wait for an older one
+	ConflictWoundWait
+	// ConflictBlock makes a transaction wait for any conflicting transaction.
+	// Deadlocks are detected and the youngest transaction is aborted.
+	ConflictBlock
+)
+
+// Conflicts is the policy for write-write conflicts.
+// With wait-die or wound-wait, waiting is only in one direction by age
+// so there can not be deadlocks.
+// If the waited for transaction commits, the waiting transaction
+// still conflicts with it and is aborted.
+// If it aborts, the waiting transaction continues.
+var Conflicts = ConflictAbort
+
+// ConflictWait is the maximum time that a transaction will wait
+// before it is aborted
+var ConflictWait = 5 * time.Second
+
+// waitOrAbort applies the Conflicts policy to a write-write conflict
+// between t and an outstanding t2.
+// It returns true if t was aborted,
+// or a channel to wait on if t should wait for t2.
+func (ck *Check) waitOrAbort(t, t2 *CkTran) (bool, chan void) {
+	older := t.start < t2.start
+	switch {
+	case Conflicts == ConflictBlock,
+		Conflicts == ConflictWaitDie && older,
+		Conflicts == ConflictWoundWait && !older:
+		return ck.wait(t, t2)
+	case Conflicts == ConflictWaitDie:
+		ck.abort(t.start, "write in this transaction conflicted with "+
+			"write in an older transaction")
+		return true, nil
+	default: // wound-wait and older
+		ck.abort(t2.start, "write in this transaction conflicted with "+
+			"write in an older transaction")
+		return false, nil
+	}
+}
+
+// wait adds t waiting for t2 to the waits-for graph
+// and returns a channel to wait on.
+// If this creates a cycle (a deadlock)
+// the youngest transaction in the cycle is aborted.
+// It returns true if that was t.
+func (ck *Check) wait(t, t2 *CkTran) (bool, chan void) {
+	traceln("wait for", t2)
+	ck.waits[t.start] = t2.start
+	if victim := ck.deadlock(t.start); victim != 0 {
+		traceln("deadlock, abort", victim)
+		ck.abort(victim, "deadlock - transactions waiting for each other")
+		if victim == t.start {
+			return true, nil
+		}
+	}
+	return false, t2.done
+}
+
+// deadlock returns the youngest transaction in a cycle
+// of waiting transactions that includes tn, or 0 if there is no cycle.
+// Since every new wait is checked, there can't be other cycles.
+func (ck *Check) deadlock(tn int) int {
+	youngest := tn
+	for t := ck.waits[tn]; t != tn; t = ck.waits[t] {
+		t2, ok := ck.trans[t]
+		if !ok || t2.isEnded() {
+			return 0 // not waiting (includes t == 0)
+		}
+		if t > youngest {
+			youngest = t
+		}
+	}
+	return youngest
+}
+
 // checkerAbortT1 is used by tests to avoid randomness
 var checkerAbortT1 = false
 
@@ -290,6 +446,13 @@ func (ck *Check) abort(tn int, reason string) bool {
 		return false
 	}
 	t.conflict.Store(reason)
+	delete(ck.waits, tn)
+	if !t.isEnded() {
+		close(t.done)
+		if t.bulk != nil {
+			ck.EndExclusive(t.bulk...)
+		}
+	}
 	delete(ck.trans, tn)
 	if tn == ck.oldest {
 		ck.oldest = math.MaxInt // need to find the new oldest
@@ -312,12 +475,22 @@ func (ck *Check) commit(ut *UpdateTran) []string {
 	if !ok {
 		return nil // it's gone, presumably aborted
 	}
+	tw := t.tablesWritten()
+	if reason := ck.overQuota(ut, tw); reason != "" {
+		ck.abort(tn, reason)
+		return nil
+	}
 	t.end = ck.next()
+	close(t.done)
+	delete(ck.waits, tn)
+	if t.bulk != nil {
+		ck.EndExclusive(t.bulk...)
+	}
 	if t.start == ck.oldest {
 		ck.oldest = math.MaxInt // need to find the new oldest
 	}
 	ck.cleanEnded()
-	return t.tablesWritten()
+	return tw
 }
 
 func (t *CkTran) tablesWritten() []string {
@@ -327,7 +500,7 @@ func (t *CkTran) tablesWritten() []string {
 			tw = append(tw, table)
 		}
 	}
-	return tw
+	return append(tw, t.bulk...)
 }
 
 func overlap(t1, t2 *CkTran) bool {
@@ -355,24 +528,21 @@ func (ck *Check) cleanEnded() {
 	}
 }
 
-// MaxAge is the maximum number of ticks that a transaction can be outstanding.
-// Transactions are aborted if they exceed this limit.
-var MaxAge = 20
-
 // tick should be called regularly e.g. once per second
-// to abort transactions older than MaxAge.
+// to abort transactions older than their MaxAge (see TranLimits).
 func (ck *Check) tick() {
 	ck.clock++
 	traceln("tick", ck.clock)
 	for tn, t := range ck.trans {
-		if ck.clock-t.birth >= MaxAge {
-			traceln("abort", tn, "age", ck.clock-t.birth)
-			log.Println("aborted", t, "update transaction longer than", MaxAge, "seconds")
-			ck.abort(tn, "transaction exceeded max age")
-		}
+		ck.checkAge(tn, t)
 	}
 }
 
+// Serial just runs fn since Check is not concurrent (for tests)
+func (ck *Check) Serial(fn func()) {
+	fn()
+}
+
 func (ck *Check) Stop() { // to satisfy Checker interface
 	ck.db.persist(&execPersistSingle{}, true) // for tests
 }
@@ -137,3 +137,46 @@ func script(t *testing.T, s string) {
 // 	}
 // 	return strings.TrimSpace(b.String())
 // }
+
+func TestCheckDeadlock(t *testing.T) {
+	assert := assert.T(t)
+	defer func(c ConflictPolicy) { Conflicts = c }(Conflicts)
+	Conflicts = ConflictBlock
+	ck := NewCheck(nil)
+	write := func(t *CkTran, key string) (bool, chan void) {
+		return ck.write(t, "mytable", []string{key})
+	}
+	t1 := ck.StartTran()
+	t2 := ck.StartTran()
+	t3 := ck.StartTran()
+	assert.True(ck.Write(t1, "mytable", []string{"a"}))
+	assert.True(ck.Write(t2, "mytable", []string{"b"}))
+	assert.True(ck.Write(t3, "mytable", []string{"c"}))
+	ok, wait := write(t1, "b")
+	assert.False(ok)
+	assert.This(wait).Is(t2.done)
+	ok, wait = write(t2, "c")
+	assert.False(ok)
+	assert.This(wait).Is(t3.done)
+	// t3 is the youngest so it is aborted and t2 can continue
+	ok, wait = write(t3, "a")
+	assert.False(ok)
+	assert.True(wait == nil)
+	assert.This(t3.conflict.Load()).
+		Is("deadlock - transactions waiting for each other")
+	assert.True(ck.Write(t2, "mytable", []string{"c"}))
+	assert.This(len(ck.waits)).Is(1) // t1 still waiting for t2
+
+	// the youngest is not necessarily the one that completes the cycle
+	t4 := ck.StartTran()
+	assert.True(ck.Write(t4, "mytable", []string{"d"}))
+	ok, wait = write(t4, "a")
+	assert.False(ok)
+	assert.This(wait).Is(t1.done)
+	ok, wait = write(t2, "d")
+	assert.False(ok)
+	assert.This(wait).Is(t4.done)
+	assert.True(t4.Aborted())
+	assert.False(t1.Aborted())
+	assert.False(t2.Aborted())
+}
@@ -23,6 +23,11 @@ type ckStart struct {
 	ret chan *CkTran
 }
 
+type ckStartBulk struct {
+	tables []string
+	ret    chan *CkTran
+}
+
 type ckRead struct {
 	t        *CkTran
 	table    string
@@ -34,7 +39,7 @@ type ckWrite struct {
 	t     *CkTran
 	table string
 	keys  []string
-	ret   chan bool
+	ret   chan ckResult
 }
 
 type ckCommit struct {
@@ -43,6 +48,9 @@ type ckCommit struct {
 }
 
 type ckResult struct {
+	ok bool
+	// wait is set if the transaction should wait and retry
+	wait chan void
 }
 
 type ckAbort struct {
@@ -63,12 +71,23 @@ type ckPersist struct {
 	ret chan *DbState
 }
 
+type ckSerial struct {
+	fn  func()
+	ret chan void
+}
+
 func (ck *CheckCo) StartTran() *CkTran {
 	ret := make(chan *CkTran, 1)
 	ck.c <- &ckStart{ret: ret}
 	return <-ret
 }
 
+func (ck *CheckCo) StartBulk(tables ...string) *CkTran {
+	ret := make(chan *CkTran, 1)
+	ck.c <- &ckStartBulk{tables: tables, ret: ret}
+	return <-ret
+}
+
 func (ck *CheckCo) Read(t *CkTran, table string, index int, from, to string) bool {
 	if t.Aborted() {
 		return false
@@ -77,13 +96,34 @@ func (ck *CheckCo) Read(t *CkTran, table string, index int, from, to string) boo
 	return true
 }
 
+// Write waits (outside the checker) if required by Conflicts
 func (ck *CheckCo) Write(t *CkTran, table string, keys []string) bool {
-	if t.Aborted() {
-		return false
+	var timeout <-chan time.Time
+	for {
+		if t.Aborted() {
+			return false
+		}
+		ret := make(chan ckResult, 1)
+		ck.c <- &ckWrite{t: t, table: table, keys: keys, ret: ret}
+		result := <-ret
+		if result.wait == nil {
+			return result.ok
+		}
+		if timeout == nil {
+			timer := time.NewTimer(ConflictWait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-result.wait:
+			// retry
+		case <-t.done:
+			return false // aborted while waiting (e.g. wounded)
+		case <-timeout:
+			ck.Abort(t, "timed out waiting for a conflicting transaction")
+			return false
+		}
 	}
-	ret := make(chan bool, 1)
-	ck.c <- &ckWrite{t: t, table: table, keys: keys, ret: ret}
-	return <-ret
 }
 
 func (ck *CheckCo) Commit(ut *UpdateTran) bool {
@@ -121,6 +161,15 @@ func (ck *CheckCo) Persist() *DbState {
 	return <-ret
 }
 
+// Serial runs fn in the merger, after the pending merges,
+// with commits blocked until it returns.
+// fn must not use the checker.
+func (ck *CheckCo) Serial(fn func()) {
+	ret := make(chan void, 1)
+	ck.c <- &ckSerial{fn: fn, ret: ret}
+	<-ret
+}
+
 //-------------------------------------------------------------------
 
 func StartCheckCo(db *Database, mergeChan chan todo, allDone chan void) *CheckCo {
@@ -166,10 +215,13 @@ func (ck *Check) dispatch(msg interface{}, mergeChan chan todo) {
 	switch msg := msg.(type) {
 	case *ckStart:
 		msg.ret <- ck.StartTran()
+	case *ckStartBulk:
+		msg.ret <- ck.StartBulk(msg.tables...)
 	case *ckRead:
 		ck.Read(msg.t, msg.table, msg.index, msg.from, msg.to)
 	case *ckWrite:
-		msg.ret <- ck.Write(msg.t, msg.table, msg.keys)
+		ok, wait := ck.write(msg.t, msg.table, msg.keys)
+		msg.ret <- ckResult{ok: ok, wait: wait}
 	case *ckAbort:
 		ck.Abort(msg.t, msg.reason)
 	case *ckCommit:
@@ -178,12 +230,13 @@ func (ck *Check) dispatch(msg interface{}, mergeChan chan todo) {
 			msg.ret <- false
 			return
 		}
-		msg.t.commit()
+		result = msg.t.commit(result)
 		msg.ret <- true
-		mergeChan <- todo{tables: result, meta: msg.t.meta}
+		mergeChan <- todo{tables: result, meta: msg.t.meta, walSeq: msg.t.walSeq}
 	case *ckAddExcl:
 		if !ck.AddExclusive(msg.tables...) {
 			msg.ret <- false
+			break
 		}
 		// ensure pending merges are all complete
 		ret := make(chan *DbState)
@@ -197,6 +250,11 @@ func (ck *Check) dispatch(msg interface{}, mergeChan chan todo) {
 		mergeChan <- todo{meta: persist, ret: ret}
 		state := <- ret
 		msg.ret <- state
+	case *ckSerial:
+		ret := make(chan *DbState)
+		mergeChan <- todo{run: msg.fn, ret: ret}
+		<-ret
+		msg.ret <- void{}
 	default:
 		panic("checker unknown message type")
 	}
@@ -208,6 +266,7 @@ var persist = &meta.Meta{}
 // Checker is the interface for Check and CheckCo
 type Checker interface {
 	StartTran() *CkTran
+	StartBulk(tables ...string) *CkTran
 	Read(t *CkTran, table string, index int, from, to string) bool
 	Write(t *CkTran, table string, keys []string) bool
 	Abort(t *CkTran, reason string) bool
@@ -215,6 +274,7 @@ type Checker interface {
 	AddExclusive(tables ...string) bool
 	EndExclusive(tables ...string)
 	Persist() *DbState
+	Serial(fn func())
 	Stop()
 }
 
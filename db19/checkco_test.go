@@ -19,8 +19,8 @@ func TestCheckCoTimeout(t *testing.T) {
 	if testing.Short() {
 		return
 	}
-	defer func(ma int) { MaxAge = ma }(MaxAge)
-	MaxAge = 1
+	defer func(lim TranLimits) { DefaultTranLimits = lim }(DefaultTranLimits)
+	DefaultTranLimits = TranLimits{MaxAge: 1}
 	ck := StartCheckCo(nil, nil, nil)
 	tran := ck.StartTran()
 	assert.T(t).False(tran.Aborted())
@@ -29,6 +29,46 @@ func TestCheckCoTimeout(t *testing.T) {
 	close(ck.c)
 }
 
+func TestCheckCoWait(t *testing.T) {
+	assert := assert.T(t)
+	defer func(c ConflictPolicy) { Conflicts = c }(Conflicts)
+	defer func(d time.Duration) { ConflictWait = d }(ConflictWait)
+	ConflictWait = 100 * time.Millisecond
+	ck := StartCheckCo(nil, nil, nil)
+	defer close(ck.c)
+	write := func(tran *CkTran, key string) chan bool {
+		ret := make(chan bool, 1)
+		go func() { ret <- ck.Write(tran, "mytable", []string{key}) }()
+		return ret
+	}
+
+	Conflicts = ConflictWaitDie
+	t1 := ck.StartTran()
+	t2 := ck.StartTran()
+	assert.True(ck.Write(t2, "mytable", []string{"a"}))
+	ret := write(t1, "a") // older waits
+	time.Sleep(10 * time.Millisecond)
+	assert.This(len(ret)).Is(0)
+	ck.Abort(t2, "aborted")
+	assert.True(<-ret)
+	t3 := ck.StartTran()
+	assert.False(ck.Write(t3, "mytable", []string{"a"})) // younger dies
+	assert.True(t3.Aborted())
+
+	Conflicts = ConflictWoundWait
+	t4 := ck.StartTran()
+	t5 := ck.StartTran()
+	assert.True(ck.Write(t4, "mytable", []string{"b"}))
+	assert.False(<-write(t5, "b")) // younger waits, times out
+	t6 := ck.StartTran()           // round trip so the abort is processed
+	assert.This(t5.conflict.Load()).
+		Is("timed out waiting for a conflicting transaction")
+	t7 := ck.StartTran()
+	assert.True(ck.Write(t7, "mytable", []string{"c"}))
+	assert.True(ck.Write(t6, "mytable", []string{"c"})) // older wounds
+	assert.True(t7.Aborted())
+}
+
 func TestCheckCoRandom(*testing.T) {
 	db, err := CreateDb(stor.HeapStor(8192))
 	assert.That(err == nil)
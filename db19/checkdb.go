@@ -73,8 +73,13 @@ func checkTable(state *DbState, table string) {
 	if count != info.Nrows {
 		panic("count != nrows " + fmt.Sprint(count, info.Nrows))
 	}
+	ts := state.Meta.GetRoSchema(table)
 	for i := 1; i < len(info.Indexes); i++ {
 		ix := info.Indexes[i]
+		if ts.Indexes[i].Mode == 't' {
+			ix.Check(func(uint64) {}) // not one entry per record
+			continue
+		}
 		CheckOtherIndex(ix, count, sum)
 	}
 }
@@ -0,0 +1,250 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/apmckinlay/gsuneido/db19/index"
+	"github.com/apmckinlay/gsuneido/db19/index/btree"
+	"github.com/apmckinlay/gsuneido/db19/meta"
+	"github.com/apmckinlay/gsuneido/db19/stor"
+	rt "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/cksum"
+	"github.com/apmckinlay/gsuneido/util/sortlist"
+	"github.com/apmckinlay/gsuneido/util/strs"
+)
+
+// CompactRatio is the ratio of the file size to the estimated live size
+// at which persist starts a background Compact.
+// Zero (the default) disables this.
+var CompactRatio = 0
+
+// compactMinSize avoids automatic compaction of small databases
+const compactMinSize = 256 * 1024 * 1024
+
+// Compact rewrites the live data (records and indexes) to a new file,
+// reclaiming the space used by old index nodes, deleted records,
+// and dropped tables. Unlike tools.Compact it runs while the database is open.
+//
+// The bulk of the copying is done from a snapshot of the state,
+// concurrently with reads and writes.
+// It then gets exclusive access to all the tables (so writes will conflict)
+// and copies any tables that were modified in the meantime.
+// Finally, in the merger (so it is serialized with merge and persist),
+// it renames the old file to .bak and the new file to the database file
+// and switches the database to the new store.
+// If the rename fails, the database continues to use the old file.
+// Transactions that started earlier continue to read the old store,
+// which is kept open until the database is closed,
+// but they can not write (because of the exclusive access).
+func (db *Database) Compact() (ntables int, err error) {
+	if db.filename == "" || db.mode == stor.READ {
+		return 0, errors.New("compact: requires an updateable database file")
+	}
+	if !atomic.CompareAndSwapInt32(&db.compacting, 0, 1) {
+		return 0, errors.New("compact: already running")
+	}
+	defer atomic.StoreInt32(&db.compacting, 0)
+	if !atomic.CompareAndSwapInt64(&db.schemaLock, 0, 1) {
+		return 0, errors.New("compact: schema modification in progress")
+	}
+	defer db.unlockSchema()
+
+	f, err := ioutil.TempFile(filepath.Dir(db.filename), "gs*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	tmpfile := f.Name()
+	f.Close()
+	dst, err := openStor(tmpfile, stor.CREATE)
+	if err != nil {
+		return 0, err
+	}
+	done := false
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("compact failed: %v", e)
+		}
+		if !done {
+			dst.Close()
+			os.Remove(tmpfile)
+		}
+	}()
+	dstdb, _ := CreateDb(dst) // writes the header
+
+	// phase 1 - copy from a snapshot, concurrent with reads and writes
+	snap := db.NewReadTran()
+	infos := make(map[string]*meta.Info)
+	snap.meta.ForEachSchema(func(ts *meta.Schema) {
+		infos[ts.Table] = compactTable(snap, ts, dst)
+	})
+
+	// phase 2 - exclusive, copy tables modified since the snapshot
+	tables := make([]string, 0, len(infos))
+	snap.meta.ForEachSchema(func(ts *meta.Schema) {
+		tables = append(tables, ts.Table)
+	})
+	if db.ck == nil {
+		ntables, err = db.compactSwitch(snap, infos, dstdb, tmpfile)
+	} else {
+		if !db.addExclusiveRetry(tables) {
+			return 0, errors.New("compact: can't get exclusive access")
+		}
+		defer db.ck.EndExclusive(tables...)
+		// in the merger so it is serialized with merge and persist
+		db.ck.Serial(func() {
+			ntables, err = db.compactSwitch(snap, infos, dstdb, tmpfile)
+		})
+	}
+	done = err == nil
+	return ntables, err
+}
+
+// compactSwitch copies the tables that were modified since the snapshot
+// and then switches the database to the new store.
+// It must not run concurrently with merge or persist.
+func (db *Database) compactSwitch(snap *ReadTran, infos map[string]*meta.Info,
+	dstdb *Database, tmpfile string) (ntables int, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("compact failed: %v", e)
+		}
+	}()
+	db.persistLock.Lock()
+	defer db.persistLock.Unlock()
+	dst := dstdb.Store
+	cur := db.NewReadTran()
+	m := dstdb.GetState().Meta
+	cur.meta.ForEachSchema(func(ts *meta.Schema) {
+		ti := infos[ts.Table]
+		if ti == nil || cur.meta.GetRoInfo(ts.Table) !=
+			snap.meta.GetRoInfo(ts.Table) {
+			ti = compactTable(cur, ts, dst)
+		}
+		tsCopy := *ts // Put sets lastmod
+		m = m.Put(&tsCopy, ti)
+		ntables++
+	})
+	cur.meta.ForEachView(func(name, def string) {
+		m = m.AddView(name, def)
+	})
+	newState := &DbState{store: dst, Meta: m}
+	seq := db.wal.mergedSeq()
+	db.wal.prePersist(seq)
+	stateOff := newState.Write(true)
+	dst.Flush()
+
+	// rename before switching so later writes are not lost on restart
+	if err := RenameBak(tmpfile, db.filename); err != nil {
+		if _, e := os.Stat(db.filename); os.IsNotExist(e) {
+			os.Rename(db.filename+".bak", db.filename) // restore
+		}
+		return 0, fmt.Errorf("compact failed: %v", err)
+	}
+
+	// switch to the new store
+	db.UpdateState(func(state *DbState) {
+		state.store = dst
+		state.Meta = m
+		state.stateOff = stateOff
+	})
+	db.oldStores = append(db.oldStores, db.Store)
+	db.Store = dst
+	db.wal.checkpoint(stateOff, seq, true)
+	db.repl.notify()
+	return ntables, nil
+}
+
+// addExclusiveRetry waits a limited time for outstanding writes to finish
+func (db *Database) addExclusiveRetry(tables []string) bool {
+	for i := 0; i < 100; i++ {
+		if db.ck.AddExclusive(tables...) {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+// compactTable copies the records of a table to dst
+// and builds new indexes for them
+func compactTable(t *ReadTran, ts *meta.Schema, dst *stor.Stor) *meta.Info {
+	src := t.meta.GetRoInfo(ts.Table)
+	list := sortlist.NewUnsorted()
+	count := 0
+	size := uint64(0)
+	iter := index.NewOverIter(ts.Table, 0)
+	for iter.Next(t); !iter.Eof(); iter.Next(t) {
+		_, off := iter.Cur()
+		buf := t.store.Data(off)
+		n := rt.RecLen(buf)
+		off2, buf2 := dst.Alloc(n + cksum.Len)
+		copy(buf2, buf[:n+cksum.Len])
+		list.Add(off2)
+		count++
+		size += uint64(len(bufToRec(buf[:n]))) // uncompressed size
+	}
+	list.Finish()
+	return &meta.Info{Table: ts.Table, Nrows: count, Size: size,
+		Indexes: buildIndexes(dst, ts, list), Stats: src.Stats, Seq: src.Seq}
+}
+
+// buildIndexes builds the indexes for a table from a list of record offsets.
+// The list must be finished.
+func buildIndexes(dst *stor.Stor, ts *meta.Schema,
+	list *sortlist.Builder) []*index.Overlay {
+	ov := make([]*index.Overlay, len(ts.Indexes))
+	for i := range ts.Indexes {
+		ix := &ts.Indexes[i]
+		var bt *btree.T
+		if ix.Mode == 't' {
+			fld := strs.Index(ts.Columns, ix.Columns[0])
+			bt = BuildTextIndex(dst, fld, list.Iter())
+		} else {
+			list.Sort(MakeLess(dst, &ix.Ixspec))
+			bldr := btree.Builder(dst)
+			iter := list.Iter()
+			for off := iter(); off != 0; off = iter() {
+				bldr.Add(ix.Ixspec.Key(OffToRec(dst, off)), off)
+			}
+			bt = bldr.Finish()
+		}
+		bt.SetIxspec(&ix.Ixspec)
+		ov[i] = index.OverlayFor(bt)
+	}
+	return ov
+}
+
+// autoCompact is called after persist.
+// It starts a background Compact if the file is much larger than the data.
+func (db *Database) autoCompact() {
+	if CompactRatio <= 0 || db.filename == "" || db.mode == stor.READ ||
+		atomic.LoadInt32(&db.compacting) != 0 {
+		return
+	}
+	size := db.Store.Size()
+	if size < compactMinSize {
+		return
+	}
+	live := uint64(0)
+	db.GetState().Meta.ForEachInfo(func(ti *meta.Info) {
+		live += ti.Size +
+			uint64(ti.Nrows*btree.EntrySize*len(ti.Indexes))
+	})
+	if size > uint64(CompactRatio)*live {
+		go func() {
+			if _, err := db.Compact(); err != nil {
+				log.Println("ERROR:", err)
+			}
+		}()
+	}
+}
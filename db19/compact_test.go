@@ -0,0 +1,141 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apmckinlay/gsuneido/db19/index"
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestCompact(t *testing.T) {
+	assert := assert.T(t)
+	db := createDb()
+	defer func() { os.Remove("tmp.db"); os.Remove("tmp.db.bak") }()
+	db.CheckerSync()
+	const nout = 1000
+	for i := 0; i < nout; i++ {
+		db.CommitMerge(output1(db))
+		if i%100 == 50 {
+			db.persist(&execPersistSingle{}, false)
+		}
+	}
+	// delete half the records
+	ut := db.NewUpdateTran()
+	iter := index.NewOverIter("mytable", 0)
+	n := 0
+	for iter.Next(ut); !iter.Eof(); iter.Next(ut) {
+		if n++; n%2 == 0 {
+			_, off := iter.Cur()
+			ut.Delete("mytable", off)
+		}
+	}
+	db.CommitMerge(ut)
+	db.persist(&execPersistSingle{}, false)
+	before := db.Size()
+
+	rt := db.NewReadTran() // started before compact
+	ntables, err := db.Compact()
+	assert.This(err).Is(nil)
+	assert.This(ntables).Is(1)
+	assert.That(db.Size() < before/2)
+	assert.This(count(rt, "mytable")).Is(nout / 2)
+
+	rt = db.NewReadTran()
+	assert.This(count(rt, "mytable")).Is(nout / 2)
+	assert.This(rt.GetInfo("mytable").Nrows).Is(nout / 2)
+	db.CommitMerge(output1(db))
+	db.persist(&execPersistSingle{}, true)
+	ck(db.Check())
+	db.Close()
+
+	db, err = OpenDatabase("tmp.db")
+	ck(err)
+	assert.This(db.NewReadTran().GetInfo("mytable").Nrows).Is(nout/2 + 1)
+	ck(db.Check())
+	db.Close()
+	ck(CheckDatabase("tmp.db"))
+}
+
+func count(rt *ReadTran, table string) int {
+	n := 0
+	iter := index.NewOverIter(table, 0)
+	for iter.Next(rt); !iter.Eof(); iter.Next(rt) {
+		_, off := iter.Cur()
+		rt.GetRecord(off)
+		n++
+	}
+	return n
+}
+
+func TestCompactConcurrent(t *testing.T) {
+	assert := assert.T(t)
+	db := createDb()
+	defer func() { os.Remove("tmp.db"); os.Remove("tmp.db.bak") }()
+	StartConcur(db, 50*time.Millisecond)
+	const nout = 50 // ended transactions are kept while old is outstanding
+	for i := 0; i < nout; i++ {
+		assert.This(output1(db).Complete()).Is("")
+	}
+	old := db.NewUpdateTran() // started before compact
+	var wg sync.WaitGroup
+	var nerr int32
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < nout; i++ {
+			func() {
+				defer func() {
+					if e := recover(); e != nil {
+						atomic.AddInt32(&nerr, 1) // exclusive
+					}
+				}()
+				if output1(db).Complete() != "" {
+					atomic.AddInt32(&nerr, 1)
+				}
+			}()
+		}
+	}()
+	_, err := db.Compact()
+	assert.This(err).Is(nil)
+	wg.Wait()
+	assert.This(func() { old.Output("mytable", mkrec("old", "data")) }).
+		Panics("conflict")
+	n := count(db.NewReadTran(), "mytable")
+	assert.This(n).Is(2*nout - int(nerr))
+	db.Close()
+
+	db, err = OpenDatabase("tmp.db")
+	ck(err)
+	assert.This(db.NewReadTran().GetInfo("mytable").Nrows).Is(n)
+	ck(db.Check())
+	db.Close()
+}
+
+func TestCompactRenameFails(t *testing.T) {
+	assert := assert.T(t)
+	db := createDb()
+	defer func() { os.Remove("tmp.db"); os.RemoveAll("tmp.db.bak") }()
+	db.CheckerSync()
+	db.CommitMerge(output1(db))
+	// a non-empty directory can't be removed so RenameBak fails
+	ck(os.MkdirAll("tmp.db.bak/x", 0755))
+	_, err := db.Compact()
+	assert.That(strings.HasPrefix(err.Error(), "compact failed"))
+	db.CommitMerge(output1(db))
+	db.persist(&execPersistSingle{}, true)
+	db.Close()
+
+	db, err = OpenDatabase("tmp.db")
+	ck(err)
+	assert.This(db.NewReadTran().GetInfo("mytable").Nrows).Is(2)
+	db.Close()
+	ck(CheckDatabase("tmp.db"))
+}
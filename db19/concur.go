@@ -64,7 +64,10 @@ loop:
 			}
 			for {
 				if m.ret != nil {
-					if m.meta == nil {
+					if m.run != nil {
+						m.run()
+						m.ret <- nil
+					} else if m.meta == nil {
 						// sync
 						m.ret <- nil
 					} else {
@@ -79,6 +82,7 @@ loop:
 				mt.merges.start(m)
 				m = mt.merges.drain(mt.mergeChan)
 				mt.db.Merge(mt.merges.meta, mt.em.merge, mt.merges)
+				db.wal.setMerged(mt.merges.walSeq)
 				// mt.db.Merge(mergeSingle, merges)
 				if m.isZero() {
 					break
@@ -87,6 +91,7 @@ loop:
 		case <-ticker.C:
 			if db.GetState() != prevState {
 				prevState = db.persist(ep, false)
+				db.autoCompact()
 			}
 		}
 	}
@@ -101,6 +106,10 @@ type todo struct {
 	tables []string
 	meta   *meta.Meta
 	ret    chan *DbState
+	// walSeq is the wal seq of the commit, 0 if not logged
+	walSeq uint64
+	// run is a function to run in the merger (see CheckCo.Serial)
+	run func()
 }
 
 func (td todo) isZero() bool {
@@ -183,6 +192,8 @@ type mergeList struct {
 	meta    *meta.Meta
 	tn      []tableCount
 	results []meta.MergeUpdate
+	// walSeq is the highest wal seq of the merged commits
+	walSeq uint64
 }
 
 type tableCount struct {
@@ -194,7 +205,15 @@ func (ml *mergeList) start(m todo) {
 	ml.meta = m.meta
 	ml.tn = ml.tn[:0]
 	ml.results = ml.results[:0]
+	ml.walSeq = 0
 	ml.add(m.tables)
+	ml.addSeq(m.walSeq)
+}
+
+func (ml *mergeList) addSeq(seq uint64) {
+	if seq > ml.walSeq {
+		ml.walSeq = seq
+	}
 }
 
 func (ml *mergeList) add(tables []string) {
@@ -221,6 +240,7 @@ func (ml *mergeList) drain(mergeChan chan todo) todo {
 			}
 			if m.ret == nil && ml.meta.SameSchemaAs(m.meta) {
 				ml.add(m.tables)
+				ml.addSeq(m.walSeq)
 			} else {
 				return m // not added to merge (sync or persist)
 			}
@@ -0,0 +1,186 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"github.com/apmckinlay/gsuneido/compile"
+	"github.com/apmckinlay/gsuneido/compile/ast"
+	"github.com/apmckinlay/gsuneido/compile/lexer"
+	tok "github.com/apmckinlay/gsuneido/compile/tokens"
+	"github.com/apmckinlay/gsuneido/db19/meta"
+	"github.com/apmckinlay/gsuneido/db19/meta/schema"
+	rt "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/sset"
+	"github.com/apmckinlay/gsuneido/util/str"
+	"github.com/apmckinlay/gsuneido/util/strs"
+)
+
+// Check constraints (Schema.Checks) are query expressions
+// that must be true for every record that is output or updated.
+// They are restricted to the physical columns of the table
+// and can not reference globals (e.g. functions or rules)
+// so the result only depends on the record.
+// Adding a check does not validate the existing records.
+//
+// Defaults (Schema.Defaults) are constants that are used
+// for empty fields when a record is output.
+// They are not applied to updates or to existing records.
+
+// CheckFailed is the prefix of the error for a write
+// that violates a check constraint.
+// It is followed by the name of the check and the table.
+const CheckFailed = "check constraint failed: "
+
+// parseCheck parses and validates a check expression
+func parseCheck(sc *schema.Schema, c *schema.Check) ast.Expr {
+	name := c.Name
+	if name == "" { // not named yet
+		name = "(" + c.Expr + ")"
+	}
+	lxr := lexer.NewQueryLexer(c.Expr)
+	for it := lxr.Next(); it.Token != tok.Eof; it = lxr.Next() {
+		if it.Token == tok.Identifier && str.Capitalized(it.Text) {
+			panic("check " + name + ": can't use " + it.Text)
+		}
+	}
+	p := compile.QueryParser(c.Expr)
+	p.EqToIs = true
+	expr := p.Expression()
+	if p.Token != tok.Eof {
+		p.Error("check " + name + ": did not parse all input")
+	}
+	if cols := sset.Difference(expr.Columns(), sc.Columns); len(cols) > 0 {
+		panic("check " + name + ": nonexistent column(s): " +
+			strs.Join(", ", cols))
+	}
+	return expr
+}
+
+// checkChecks validates the check constraints of a schema
+func checkChecks(sc *schema.Schema) {
+	for i := range sc.Checks {
+		parseCheck(sc, &sc.Checks[i])
+	}
+}
+
+// ensureChecks validates the checks for Ensure or AlterCreate
+// using the existing and the new columns
+func ensureChecks(sch *schema.Schema, ts *meta.Schema) {
+	if len(sch.Checks) > 0 && ts != nil {
+		checkChecks(&schema.Schema{Checks: sch.Checks,
+			Columns: sset.Union(ts.Columns, sch.Columns)})
+	}
+}
+
+// checkDefaults validates the defaults of a schema
+func checkDefaults(sc *schema.Schema) {
+	for _, col := range sc.Columns {
+		if def, ok := sc.Defaults[col]; ok {
+			parseDefault(col, def)
+		}
+	}
+}
+
+// parseDefault compiles a default and returns it packed
+func parseDefault(col, def string) string {
+	p, ok := compile.Constant(def).(rt.Packable)
+	if !ok {
+		panic("default " + col + ": must be a constant value")
+	}
+	return rt.Pack(p)
+}
+
+// checkColumns panics if a check constraint uses one of the columns
+// e.g. when they are being renamed or dropped
+func checkColumns(ts *meta.Schema, cols []string) {
+	for i := range ts.Checks {
+		c := &ts.Checks[i]
+		if used := sset.Intersect(parseCheck(&ts.Schema, c).Columns(),
+			cols); len(used) > 0 {
+			panic("can't modify column(s) used by check " + c.Name + ": " +
+				strs.Join(", ", used))
+		}
+	}
+}
+
+// constraints panics if the record does not satisfy
+// the check constraints of the table.
+// The parsed expressions are cached per transaction.
+func (t *UpdateTran) constraints(ts *meta.Schema, rec rt.Record) {
+	if len(ts.Checks) == 0 || t.replay || t.ct.bulk != nil {
+		return
+	}
+	cc := t.tblChecks(ts)
+	context := &ast.Context{Th: t.thread(), Hdr: cc.hdr,
+		Row: rt.Row{rt.DbRec{Record: rec}}}
+	for i, expr := range cc.exprs {
+		if expr.Eval(context) != rt.True {
+			panic(CheckFailed + ts.Checks[i].Name + " in " + ts.Table)
+		}
+	}
+}
+
+// defaults returns the record with empty fields set to their defaults
+func (t *UpdateTran) defaults(ts *meta.Schema, rec rt.Record) rt.Record {
+	if len(ts.Defaults) == 0 || t.replay || t.ct.bulk != nil {
+		return rec
+	}
+	cc := t.tblChecks(ts)
+	n := rec.Count()
+	missing := false
+	for i, def := range cc.defs {
+		if def != "" && (i >= n || rec.GetRaw(i) == "") {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return rec
+	}
+	var b rt.RecordBuilder
+	for i := 0; i < n || i < len(cc.defs); i++ {
+		var raw string
+		if i < n {
+			raw = rec.GetRaw(i)
+		}
+		if raw == "" && i < len(cc.defs) {
+			raw = cc.defs[i]
+		}
+		b.AddRaw(raw)
+	}
+	return b.Trim().Build()
+}
+
+// tblChecks returns the cached check expressions and packed defaults
+func (t *UpdateTran) tblChecks(ts *meta.Schema) *tblChecks {
+	if cc, ok := t.checks[ts.Table]; ok && cc.ts == ts {
+		return cc
+	}
+	cc := &tblChecks{ts: ts, hdr: rt.SimpleHeader(ts.Columns),
+		exprs: make([]ast.Expr, len(ts.Checks))}
+	for i := range ts.Checks {
+		cc.exprs[i] = parseCheck(&ts.Schema, &ts.Checks[i])
+	}
+	if len(ts.Defaults) > 0 {
+		cc.defs = make([]string, len(ts.Columns))
+		for i, col := range ts.Columns {
+			if def, ok := ts.Defaults[col]; ok {
+				cc.defs[i] = parseDefault(col, def)
+			}
+		}
+	}
+	if t.checks == nil {
+		t.checks = make(map[string]*tblChecks)
+	}
+	t.checks[ts.Table] = cc
+	return cc
+}
+
+type tblChecks struct {
+	ts    *meta.Schema
+	hdr   *rt.Header
+	exprs []ast.Expr
+	// defs are the packed defaults by field, "" if none
+	defs []string
+}
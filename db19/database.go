@@ -5,6 +5,7 @@ package db19
 
 import (
 	"errors"
+	"sync"
 	"sync/atomic"
 
 	"github.com/apmckinlay/gsuneido/db19/index"
@@ -13,16 +14,31 @@ import (
 	"github.com/apmckinlay/gsuneido/db19/meta"
 	"github.com/apmckinlay/gsuneido/db19/meta/schema"
 	"github.com/apmckinlay/gsuneido/db19/stor"
+	"github.com/apmckinlay/gsuneido/options"
 	rt "github.com/apmckinlay/gsuneido/runtime"
 	"github.com/apmckinlay/gsuneido/util/cksum"
 	"github.com/apmckinlay/gsuneido/util/hacks"
 	"github.com/apmckinlay/gsuneido/util/sortlist"
 	"github.com/apmckinlay/gsuneido/util/sset"
+	"github.com/apmckinlay/gsuneido/util/strs"
 )
 
 type Database struct {
 	mode  stor.Mode
 	Store *stor.Stor
+	// filename is the database file, "" for an in-memory store
+	filename string
+	// oldStores are stores replaced by Compact.
+	// They are kept open for transactions that started before.
+	oldStores []*stor.Stor
+	// compacting is set (atomically) while Compact is running
+	compacting int32
+	// persistLock prevents persist from running while Compact switches stores
+	persistLock sync.Mutex
+	// wal is the optional write ahead log, see OpenWal
+	wal *wal
+	// repl notifies replica senders, see ServeReplicas
+	repl replicator
 
 	// state is the central immutable state of the database.
 	// It must be accessed atomically and only updated via UpdateState.
@@ -30,18 +46,35 @@ type Database struct {
 
 	ck Checker
 	triggers
+	sequences
+	snapshots
 	// schemaLock is used to prevent concurrent schema modification
 	schemaLock int64
+	// limits is the TranLimits for new transactions, see SetTranLimits
+	limits atomic.Value
 }
 
 const magic = "gsndo001"
 
+// openStor opens a database file by memory mapping it,
+// or with reads and writes if options.StorPread
+func openStor(filename string, mode stor.Mode) (*stor.Stor, error) {
+	if options.StorPread {
+		return stor.PreadStor(filename, mode)
+	}
+	return stor.MmapStor(filename, mode)
+}
+
 func CreateDatabase(filename string) (*Database, error) {
-	store, err := stor.MmapStor(filename, stor.CREATE)
+	store, err := openStor(filename, stor.CREATE)
 	if err != nil {
 		return nil, err
 	}
-	return CreateDb(store)
+	db, err := CreateDb(store)
+	if err == nil {
+		db.filename = filename
+	}
+	return db, err
 }
 
 func CreateDb(store *stor.Stor) (*Database, error) {
@@ -66,11 +99,15 @@ func OpenDatabaseRead(filename string) (*Database, error) {
 }
 
 func OpenDb(filename string, mode stor.Mode, check bool) (db *Database, err error) {
-	store, err := stor.MmapStor(filename, mode)
+	store, err := openStor(filename, mode)
 	if err != nil {
 		return nil, err
 	}
-	return OpenDbStor(store, mode, check)
+	db, err = OpenDbStor(store, mode, check)
+	if err == nil {
+		db.filename = filename
+	}
+	return db, err
 }
 
 func OpenDbStor(store *stor.Stor, mode stor.Mode, check bool) (db *Database, err error) {
@@ -136,14 +173,23 @@ func (db *Database) loadedTable(ts *meta.Schema, ti *meta.Info) error {
 // to ensure that merge sees a state consistent with the transaction.
 
 func (db *Database) Create(schema *schema.Schema) {
+	checkChecks(schema)
+	checkDefaults(schema)
 	db.lockSchema()
 	defer db.unlockSchema()
 	ts, ti := db.create(schema)
+	hs, hts, hti := db.createHistory(schema)
 	db.UpdateState(func(state *DbState) {
 		if state.Meta.GetRoSchema(ts.Table) != nil {
 			panic("can't create existing table: " + ts.Table)
 		}
+		if hs != nil && state.Meta.GetRoSchema(hs.Table) != nil {
+			panic("can't create existing table: " + hs.Table)
+		}
 		state.Meta = state.Meta.PutNew(ts, ti, schema)
+		if hs != nil {
+			state.Meta = state.Meta.PutNew(hts, hti, hs)
+		}
 	})
 }
 
@@ -159,6 +205,7 @@ func (db *Database) unlockSchema() {
 
 func (db *Database) create(schema *schema.Schema) (*meta.Schema, *meta.Info) {
 	ts := &meta.Schema{Schema: *schema}
+	ts.NameChecks()
 	ts.Ixspecs(ts.Indexes)
 	ov := db.createIndexes(ts.Indexes)
 	ti := &meta.Info{Table: schema.Table, Indexes: ov}
@@ -184,6 +231,8 @@ func (db *Database) Ensure(sch *schema.Schema) {
 		if ts == nil { // table doesn't exist
 			// TODO check if schema is "full" (see parseadmin.go)
 			// else you could get assertion failures
+			checkChecks(sch)
+			checkDefaults(sch)
 			ts, ti := db.create(sch)
 			state.Meta = state.Meta.Put(ts, ti)
 			handled = true
@@ -191,10 +240,12 @@ func (db *Database) Ensure(sch *schema.Schema) {
 		} else if schemaSubset(sch, ts) {
 			handled = true // nothing to do, common fast case
 		} else {
+			ensureChecks(sch, ts)
+			checkDefaults(sch)
 			var meta *meta.Meta
 			newIdxs, meta = state.Meta.Ensure(sch, db.Store)
 			if len(newIdxs) == 0 {
-				state.Meta = meta
+				state.Meta = ensureHistory(meta, sch)
 				handled = true
 			}
 			// else discard meta and just use newIdxs, run Ensure again later
@@ -211,6 +262,10 @@ func schemaSubset(schema *schema.Schema, ts *meta.Schema) bool {
 	if !sset.Subset(ts.Columns, schema.Columns) {
 		return false
 	}
+	if (schema.MaxRows > 0 && schema.MaxRows != ts.MaxRows) ||
+		(schema.MaxSize > 0 && schema.MaxSize != ts.MaxSize) {
+		return false
+	}
 	for i := range schema.Indexes {
 		ix := ts.FindIndex(schema.Indexes[i].Columns)
 		if ix == nil {
@@ -220,6 +275,15 @@ func schemaSubset(schema *schema.Schema, ts *meta.Schema) bool {
 			panic("Ensure: index exists but is different")
 		}
 	}
+outer:
+	for _, c := range schema.Checks {
+		for _, x := range ts.Checks {
+			if x.Expr == c.Expr {
+				continue outer
+			}
+		}
+		return false
+	}
 	return true
 }
 
@@ -238,13 +302,31 @@ func (db *Database) ensure(sch *schema.Schema, newIdxs []schema.Index) {
 			i := len(ti.Indexes) - len(ov)
 			copy(ti.Indexes[i:], ov)
 		}
-		state.Meta = meta
+		state.Meta = ensureHistory(meta, sch)
 	})
 }
 
 func (db *Database) addExclusive(table string) {
 	if !db.ck.AddExclusive(table) {
-		panic("index creation: can't get exclusive access to " + table)
+		panic("schema change: can't get exclusive access to " + table)
+	}
+}
+
+// checkMerged panics if the table has commits that have not been merged.
+// A merge after a rename would not find the table (or columns)
+// under the name it was committed with.
+// CheckCo.AddExclusive waits for the pending merges
+// and exclusive access prevents new ones,
+// but Check (synchronous, without a merger) does not wait.
+func (db *Database) checkMerged(table string) {
+	ti := db.GetState().Meta.GetRoInfo(table)
+	if ti == nil {
+		return
+	}
+	for _, ov := range ti.Indexes {
+		if ov.Nlayers() > 1 {
+			panic("schema change: " + table + " has pending merges")
+		}
 	}
 }
 
@@ -266,9 +348,17 @@ func (db *Database) buildIndexes(table string, newIdxs []schema.Index) []*index.
 		_, off := iter.Cur()
 		list.Add(off)
 	}
+	list.Finish()
 	ov := make([]*index.Overlay, len(newIdxs))
 	for i := range newIdxs {
 		ix := &newIdxs[i]
+		if ix.Mode == 't' {
+			fld := strs.Index(rt.meta.GetRoSchema(table).Columns, ix.Columns[0])
+			bt := BuildTextIndex(db.Store, fld, list.Iter())
+			bt.SetIxspec(&ix.Ixspec)
+			ov[i] = index.OverlayForN(bt, nlayers)
+			continue
+		}
 		fk := &ix.Fk
 		list.Sort(MakeLess(db.Store, &ix.Ixspec))
 		bldr := btree.Builder(db.Store)
@@ -301,12 +391,23 @@ func MakeLess(store *stor.Stor, is *ixkey.Spec) func(x, y uint64) bool {
 	}
 }
 
+// RenameTable renames a table, without copying its data,
+// and updates the foreign keys that point to it.
+// The history table (if any) is also renamed.
+// It gets exclusive access so transactions that started earlier
+// can not write to the table under its old name.
 func (db *Database) RenameTable(from, to string) bool {
 	db.lockSchema()
 	defer db.unlockSchema()
+	db.addExclusive(from)
+	defer db.ck.EndExclusive(from)
+	db.checkMerged(from)
 	result := false
 	db.UpdateState(func(state *DbState) {
 		if m := state.Meta.RenameTable(from, to); m != nil {
+			if hist := schema.HistoryTable(from); isHistory(m, to, hist) {
+				m = m.RenameTable(hist, schema.HistoryTable(to))
+			}
 			state.Meta = m
 			result = true
 		}
@@ -314,13 +415,18 @@ func (db *Database) RenameTable(from, to string) bool {
 	return result
 }
 
-// Drop removes a table or view
+// Drop removes a table or view, and the table's history table (if any)
 func (db *Database) Drop(table string) error {
 	db.lockSchema()
 	defer db.unlockSchema()
 	var err error
 	db.UpdateState(func(state *DbState) {
+		hist := schema.HistoryTable(table)
+		drophist := isHistory(state.Meta, table, hist)
 		if m := state.Meta.Drop(table); m != nil {
+			if drophist {
+				m = m.Drop(hist)
+			}
 			state.Meta = m
 		} else {
 			err = errors.New("can't drop nonexistent table: " + table)
@@ -329,12 +435,20 @@ func (db *Database) Drop(table string) error {
 	return err
 }
 
-// AlterRename renames columns
+// AlterRename renames columns, updating indexes and foreign keys.
+// Like RenameTable it gets exclusive access
+// and requires that there are no pending merges.
 func (db *Database) AlterRename(table string, from, to []string) bool {
 	db.lockSchema()
 	defer db.unlockSchema()
+	db.addExclusive(table)
+	defer db.ck.EndExclusive(table)
+	db.checkMerged(table)
 	result := false
 	db.UpdateState(func(state *DbState) {
+		if ts := state.Meta.GetRoSchema(table); ts != nil {
+			checkColumns(ts, from)
+		}
 		if m := state.Meta.AlterRename(table, from, to); m != nil {
 			state.Meta = m
 			result = true
@@ -349,6 +463,8 @@ func (db *Database) AlterCreate(sch *schema.Schema) {
 	defer db.unlockSchema()
 	db.addExclusive(sch.Table)
 	defer db.ck.EndExclusive(sch.Table)
+	ensureChecks(sch, db.GetState().Meta.GetRoSchema(sch.Table))
+	checkDefaults(sch)
 
 	ov := db.buildIndexes(sch.Table, sch.Indexes)
 	db.UpdateState(func(state *DbState) {
@@ -360,7 +476,7 @@ func (db *Database) AlterCreate(sch *schema.Schema) {
 			i := len(ti.Indexes) - len(ov)
 			copy(ti.Indexes[i:], ov)
 		}
-		state.Meta = meta
+		state.Meta = ensureHistory(meta, sch)
 	})
 }
 
@@ -370,6 +486,9 @@ func (db *Database) AlterDrop(schema *schema.Schema) bool {
 	defer db.unlockSchema()
 	result := false
 	db.UpdateState(func(state *DbState) {
+		if ts := state.Meta.GetRoSchema(schema.Table); ts != nil {
+			checkColumns(ts, schema.Columns)
+		}
 		if m := state.Meta.AlterDrop(schema); m != nil {
 			state.Meta = m
 			result = true
@@ -410,6 +529,11 @@ func (db *Database) Size() uint64 {
 
 // Close closes the database store, writing the current size to the start.
 // NOTE: The state must already be written.
+// ReadOnly returns whether the database was opened read-only, e.g. a replica
+func (db *Database) ReadOnly() bool {
+	return db.mode == stor.READ
+}
+
 func (db *Database) Close() {
 	if db.Store == nil {
 		return // already closed
@@ -422,8 +546,15 @@ func (db *Database) Close() {
 	if db.mode != stor.READ {
 		db.writeSize()
 	}
+	db.repl.close()
+	db.wal.close()
+	db.wal = nil
 	db.Store.Close()
 	db.Store = nil
+	for _, st := range db.oldStores {
+		st.Close()
+	}
+	db.oldStores = nil
 }
 
 func (db *Database) writeSize() {
@@ -443,10 +574,11 @@ func getLeafKey(store *stor.Stor, is *ixkey.Spec, off uint64) string {
 	return is.Key(OffToRec(store, off))
 }
 
+// OffToRec returns the record stored at off, decompressing it if necessary
 func OffToRec(store *stor.Stor, off uint64) rt.Record {
 	buf := store.Data(off)
 	size := rt.RecLen(buf)
-	return rt.Record(hacks.BStoS(buf[:size]))
+	return bufToRec(buf[:size])
 }
 
 // OffToRecCk verifies the checksum following the record
@@ -454,7 +586,35 @@ func OffToRecCk(store *stor.Stor, off uint64) rt.Record {
 	buf := store.Data(off)
 	size := rt.RecLen(buf)
 	cksum.MustCheck(buf[:size+cksum.Len])
-	return rt.Record(hacks.BStoS(buf[:size]))
+	return bufToRec(buf[:size])
+}
+
+func bufToRec(buf []byte) rt.Record {
+	if rt.IsCompressed(buf) {
+		return rt.DecompressRecord(buf)
+	}
+	return rt.Record(hacks.BStoS(buf))
+}
+
+// StoreRecord writes a record, followed by a checksum, and returns its offset.
+// If compress is true, the record is compressed if it reduces the size.
+func StoreRecord(store *stor.Stor, rec rt.Record, compress bool) uint64 {
+	var data []byte
+	if compress {
+		data = rt.CompressRecord(rec)
+	}
+	n := len(data)
+	if data == nil {
+		n = len(rec)
+	}
+	off, buf := store.Alloc(n + cksum.Len)
+	if data != nil {
+		copy(buf, data)
+	} else {
+		copy(buf, rec)
+	}
+	cksum.Update(buf)
+	return off
 }
 
 func (db *Database) MakeLess(is *ixkey.Spec) func(x, y uint64) bool {
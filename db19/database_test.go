@@ -7,6 +7,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/apmckinlay/gsuneido/options"
 	"github.com/apmckinlay/gsuneido/util/assert"
 )
 
@@ -17,3 +18,22 @@ func TestDatabaseDropTable(t *testing.T) {
 	assert.T(t).That(db.Drop("mytable") == nil)
 	assert.T(t).That(db.Drop("mytable") != nil)
 }
+
+func TestPreadDatabase(t *testing.T) {
+	defer func(b bool) { options.StorPread = b }(options.StorPread)
+	options.StorPread = true
+	defer os.Remove("tmp.db")
+	db := createDb()
+	db.CheckerSync()
+	for i := 0; i < 100; i++ {
+		db.CommitMerge(output1(db))
+	}
+	db.Close()
+	ck(CheckDatabase("tmp.db"))
+
+	options.StorPread = false // same file format
+	db, err := OpenDatabaseRead("tmp.db")
+	ck(err)
+	assert.T(t).This(count(db.NewReadTran(), "mytable")).Is(100)
+	db.Close()
+}
@@ -0,0 +1,76 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"sort"
+
+	"github.com/apmckinlay/gsuneido/db19/index/btree"
+	"github.com/apmckinlay/gsuneido/db19/index/fulltext"
+	"github.com/apmckinlay/gsuneido/db19/meta"
+	"github.com/apmckinlay/gsuneido/db19/stor"
+	rt "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/strs"
+)
+
+// Full text indexes (Mode 't') have one entry per distinct word per record.
+// The entries point to posting records, see fulltext.
+// They are skipped by the usual one key per record index maintenance.
+
+// textField returns the record field for a full text index
+func textField(ts *meta.Schema, i int) int {
+	return strs.Index(ts.Columns, ts.Indexes[i].Columns[0])
+}
+
+func (t *UpdateTran) textOutput(table string, ts *meta.Schema, ti *meta.Info,
+	i int, rec rt.Record, off uint64) {
+	for _, word := range fulltext.RecWords(rec, textField(ts, i)) {
+		key, poff := fulltext.Output(t.store, word, off)
+		ti.Indexes[i].Insert(key, poff)
+		t.textWrite(table, len(ts.Indexes), i, key)
+	}
+}
+
+func (t *UpdateTran) textDelete(table string, ts *meta.Schema, ti *meta.Info,
+	i int, rec rt.Record, off uint64) {
+	ix := ti.Indexes[i]
+	for _, word := range fulltext.RecWords(rec, textField(ts, i)) {
+		key := fulltext.Key(word, off)
+		ix.Delete(key, ix.Lookup(key))
+		t.textWrite(table, len(ts.Indexes), i, key)
+	}
+}
+
+// textWrite records a write of a single full text index key.
+// The checker skips empty keys so the other indexes are unaffected.
+func (t *UpdateTran) textWrite(table string, nindexes, i int, key string) {
+	keys := make([]string, nindexes)
+	keys[i] = key
+	t.write(table, keys)
+}
+
+// BuildTextIndex builds a full text index btree on record field fld
+// from the data record offsets returned by iter (terminated by 0).
+// It is used when creating an index on an existing table, and by load.
+func BuildTextIndex(store *stor.Stor, fld int, iter func() uint64) *btree.T {
+	type entry struct {
+		key string
+		off uint64
+	}
+	var entries []entry
+	for off := iter(); off != 0; off = iter() {
+		rec := OffToRec(store, off)
+		for _, word := range fulltext.RecWords(rec, fld) {
+			key, poff := fulltext.Output(store, word, off)
+			entries = append(entries, entry{key: key, off: poff})
+		}
+	}
+	sort.Slice(entries,
+		func(i, j int) bool { return entries[i].key < entries[j].key })
+	bldr := btree.Builder(store)
+	for _, e := range entries {
+		bldr.Add(e.key, e.off)
+	}
+	return bldr.Finish()
+}
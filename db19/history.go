@@ -0,0 +1,183 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"github.com/apmckinlay/gsuneido/db19/meta"
+	"github.com/apmckinlay/gsuneido/db19/meta/schema"
+	rt "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/strs"
+)
+
+// Tables with Schema.History keep every version of their records
+// in a separate table (schema.HistoryTable) that is created with them.
+// Each version has the timestamps of the transactions
+// that created it (HistoryFrom) and replaced or deleted it (HistoryTo).
+// The current version has an empty HistoryTo.
+// This allows reading the table as of a point in time
+// without affecting the performance of reading the current data.
+//
+// All the changes by a transaction use the same timestamp.
+// Versions that are created and replaced by the same transaction
+// are not kept, since no one else could see them.
+//
+// The history table is maintained by output, update, and delete.
+// It is not maintained when replaying the wal (the writes were logged)
+// or by bulk transactions (e.g. load) so it can be loaded separately.
+// Columns are matched by name, so columns that are added to the table
+// are also added to the history table, but renamed or dropped columns
+// are left as they were for the existing versions.
+
+// historySchema returns the schema for the history table of a table.
+// Its key is the first key of the table plus HistoryTo.
+func historySchema(sc *schema.Schema) *schema.Schema {
+	cols := make([]string, 0, len(sc.Columns)+2)
+	for _, col := range sc.Columns {
+		if col != "-" {
+			cols = append(cols, col)
+		}
+	}
+	cols = append(cols, schema.HistoryFrom, schema.HistoryTo)
+	var key []string
+	for i := range sc.Indexes {
+		if sc.Indexes[i].Mode == 'k' {
+			key = sc.Indexes[i].Columns
+			break
+		}
+	}
+	key = append(key[:len(key):len(key)], schema.HistoryTo)
+	return &schema.Schema{Table: schema.HistoryTable(sc.Table), Columns: cols,
+		Indexes: []schema.Index{{Mode: 'k', Columns: key}}}
+}
+
+// createHistory returns the schema, meta schema, and info
+// for the history table of a new table, or nils if it does not have History
+func (db *Database) createHistory(sc *schema.Schema) (*schema.Schema,
+	*meta.Schema, *meta.Info) {
+	if !sc.History {
+		return nil, nil, nil
+	}
+	hs := historySchema(sc)
+	ts, ti := db.create(hs)
+	return hs, ts, ti
+}
+
+// isHistory returns whether table has History and its history table exists
+func isHistory(m *meta.Meta, table, hist string) bool {
+	ts := m.GetRoSchema(table)
+	return ts != nil && ts.History && m.GetRoSchema(hist) != nil
+}
+
+// ensureHistory adds new columns to the history table
+func ensureHistory(m *meta.Meta, sch *schema.Schema) *meta.Meta {
+	hist := schema.HistoryTable(sch.Table)
+	if len(sch.Columns) == 0 || !isHistory(m, sch.Table, hist) {
+		return m
+	}
+	_, m = m.Ensure(&schema.Schema{Table: hist, Columns: sch.Columns}, nil)
+	return m
+}
+
+// historyOutput adds a current version for an output record
+func (t *UpdateTran) historyOutput(ts *meta.Schema, rec rt.Record) {
+	if hs := t.historySchema(ts); hs != nil {
+		t.Output(hs.Table, historyRec(ts, hs, rec, t.historyTime(), ""))
+	}
+}
+
+// historyUpdate replaces the current version of an updated record
+func (t *UpdateTran) historyUpdate(ts *meta.Schema, oldrec, newrec rt.Record) {
+	hs := t.historySchema(ts)
+	if hs == nil {
+		return
+	}
+	now := t.historyTime()
+	newver := historyRec(ts, hs, newrec, now, "")
+	if cur := t.historyCurrent(ts, hs, oldrec); cur == nil {
+		t.Output(hs.Table, newver) // e.g. loaded without history
+	} else if cur.GetRaw(strs.Index(hs.Columns, schema.HistoryFrom)) == now {
+		t.Update(hs.Table, cur.Off, newver) // created by this transaction
+	} else {
+		t.Update(hs.Table, cur.Off,
+			setField(hs, cur.Record, schema.HistoryTo, now))
+		t.Output(hs.Table, newver)
+	}
+}
+
+// historyDelete ends the current version of a deleted record
+func (t *UpdateTran) historyDelete(ts *meta.Schema, rec rt.Record) {
+	hs := t.historySchema(ts)
+	if hs == nil {
+		return
+	}
+	now := t.historyTime()
+	if cur := t.historyCurrent(ts, hs, rec); cur == nil {
+		return
+	} else if cur.GetRaw(strs.Index(hs.Columns, schema.HistoryFrom)) == now {
+		t.Delete(hs.Table, cur.Off) // created by this transaction
+	} else {
+		t.Update(hs.Table, cur.Off,
+			setField(hs, cur.Record, schema.HistoryTo, now))
+	}
+}
+
+// historySchema returns the schema of the history table
+// or nil if the table does not keep history
+func (t *UpdateTran) historySchema(ts *meta.Schema) *meta.Schema {
+	if !ts.History || t.replay || t.ct.bulk != nil {
+		return nil
+	}
+	return t.meta.GetRoSchema(schema.HistoryTable(ts.Table))
+}
+
+// historyCurrent returns the current version of a record, or nil
+func (t *UpdateTran) historyCurrent(ts *meta.Schema, hs *meta.Schema,
+	rec rt.Record) *rt.DbRec {
+	key := hs.Indexes[0].Ixspec.Key(historyRec(ts, hs, rec, "", ""))
+	return t.Lookup(hs.Table, 0, key)
+}
+
+// historyTime returns the packed timestamp for the transaction
+func (t *UpdateTran) historyTime() string {
+	if t.histTime == "" {
+		t.histTime = rt.Pack(Timestamp())
+	}
+	return t.histTime
+}
+
+// historyRec returns a history version of a record.
+// from and to are packed timestamps (or empty)
+func historyRec(ts *meta.Schema, hs *meta.Schema, rec rt.Record,
+	from, to string) rt.Record {
+	var b rt.RecordBuilder
+	for _, col := range hs.Columns {
+		switch col {
+		case schema.HistoryFrom:
+			b.AddRaw(from)
+		case schema.HistoryTo:
+			b.AddRaw(to)
+		default:
+			if fld := strs.Index(ts.Columns, col); fld >= 0 {
+				b.AddRaw(rec.GetRaw(fld))
+			} else {
+				b.AddRaw("")
+			}
+		}
+	}
+	return b.Trim().Build()
+}
+
+// setField returns a copy of the record with one field replaced
+func setField(ts *meta.Schema, rec rt.Record, col, raw string) rt.Record {
+	fld := strs.Index(ts.Columns, col)
+	var b rt.RecordBuilder
+	for i := 0; i < rec.Count() || i <= fld; i++ {
+		if i == fld {
+			b.AddRaw(raw)
+		} else {
+			b.AddRaw(rec.GetRaw(i))
+		}
+	}
+	return b.Trim().Build()
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/apmckinlay/gsuneido/db19/index/ixkey"
 	"github.com/apmckinlay/gsuneido/db19/stor"
+	"github.com/apmckinlay/gsuneido/options"
 	"github.com/apmckinlay/gsuneido/runtime"
 	"github.com/apmckinlay/gsuneido/util/cksum"
 )
@@ -35,6 +36,8 @@ type btree struct {
 	// ixspec is an opaque value passed to GetLeafKey.
 	// It specifies which fields make up the key, based on the schema.
 	ixspec *ixkey.Spec
+	// written is the number of bytes written by MergeAndSave, see Written
+	written uint64
 }
 
 const maxlevels = 8
@@ -117,9 +120,14 @@ func (nd node) putNode(st *stor.Stor) uint64 {
 	return off
 }
 
-// getNode returns the node for a given offset
+// getNode returns the node for a given offset.
+// It verifies the checksum if options.VerifyNodes is set.
 func (bt *btree) getNode(off uint64) node {
-	return readNode(bt.stor, off)
+	nd := readNode(bt.stor, off)
+	if options.VerifyNodes != options.VerifyNone {
+		verifyNode(bt.stor, off, nd)
+	}
+	return nd
 }
 
 func (bt *btree) getNodeCk(off uint64, check bool) node {
@@ -136,6 +144,22 @@ func readNode(st *stor.Stor, off uint64) node {
 	return node(buf[2 : 2+n])
 }
 
+// verifyNode panics if the checksum of a node is wrong.
+// For VerifyFirst, nodes are immutable so they only need to be verified once
+// per Stor, or once each time their chunk is read for a pager (-pread).
+func verifyNode(st *stor.Stor, off uint64, nd node) {
+	first := options.VerifyNodes == options.VerifyFirst
+	if first && st.Verified(off) {
+		return
+	}
+	if !cksum.Check(nd[:len(nd)+cksum.Len]) {
+		panic("btree node checksum error at " + strconv.FormatUint(off, 10))
+	}
+	if first {
+		st.SetVerified(off)
+	}
+}
+
 //-------------------------------------------------------------------
 // Quick check is used when opening a database. It should be fast.
 // To be fast it should only look at the end (recent) part of the file.
@@ -215,6 +239,31 @@ func (bt *btree) check1(depth int, offset uint64, key *string,
 	return
 }
 
+// Salvage calls fn with the offsets in the leaf nodes that can be read,
+// skipping damaged nodes (and their children).
+// It returns the number of damaged nodes.
+// Unlike Check, it does not verify keys or order.
+func (bt *btree) Salvage(fn func(uint64)) int {
+	return bt.salvage1(0, bt.root, fn)
+}
+
+func (bt *btree) salvage1(depth int, offset uint64, fn func(uint64)) (nbad int) {
+	defer func() {
+		if e := recover(); e != nil {
+			nbad++
+		}
+	}()
+	nd := bt.getNodeCk(offset, true)
+	for it := nd.iter(); it.next(); {
+		if depth < bt.treeLevels {
+			nbad += bt.salvage1(depth+1, it.offset, fn) // RECURSE
+		} else {
+			fn(it.offset)
+		}
+	}
+	return nbad
+}
+
 // print ------------------------------------------------------------
 
 func (bt *btree) Print() {
@@ -362,6 +411,39 @@ func (bt *btree) fracPos(key string) float32 {
 	return frac
 }
 
+// Separators returns the separator keys from the tree nodes, in order,
+// from the first level with at least min of them (or the lowest tree level).
+// It does not read leaf nodes or data records
+// so it is much faster than iterating through the keys.
+// The separators divide the keys approximately evenly.
+// They are prefixes of keys, not necessarily complete keys.
+func (bt *btree) Separators(min int) []string {
+	type sepOff struct {
+		sep string
+		off uint64
+	}
+	level := []sepOff{{off: bt.root}}
+	for depth := 0; depth < bt.treeLevels && len(level)-1 < min; depth++ {
+		next := make([]sepOff, 0, len(level)*Fanout)
+		for _, so := range level {
+			it := bt.getNode(so.off).iter()
+			for first := true; it.next(); first = false {
+				sep := so.sep // the first entry in a node is ""
+				if !first {
+					sep = string(it.known)
+				}
+				next = append(next, sepOff{sep: sep, off: it.offset})
+			}
+		}
+		level = next
+	}
+	seps := make([]string, 0, len(level))
+	for _, so := range level[1:] { // first is ""
+		seps = append(seps, so.sep)
+	}
+	return seps
+}
+
 // trace ------------------------------------------------------------
 
 const t = false // set to true to enable tracing
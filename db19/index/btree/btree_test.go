@@ -5,6 +5,7 @@ package btree
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
@@ -12,7 +13,7 @@ import (
 	"github.com/apmckinlay/gsuneido/db19/index/ixbuf"
 	"github.com/apmckinlay/gsuneido/db19/index/ixkey"
 	"github.com/apmckinlay/gsuneido/db19/stor"
-
+	"github.com/apmckinlay/gsuneido/options"
 	"github.com/apmckinlay/gsuneido/util/assert"
 	"github.com/apmckinlay/gsuneido/util/cksum"
 )
@@ -26,6 +27,38 @@ func TestBtreeIO(t *testing.T) {
 	assert.T(t).This(string(after)).Is(string(before))
 }
 
+func TestVerifyNodes(t *testing.T) {
+	defer func(v int) { options.VerifyNodes = v }(options.VerifyNodes)
+	st := stor.HeapStor(128)
+	bt := &btree{stor: st}
+	off := node([]byte("helloworld")).putNode(st)
+	off2 := node([]byte("goodbye")).putNode(st)
+	st.Data(off)[3] = 'x' // corrupt
+	st.Data(off2)[3] = 'x'
+
+	options.VerifyNodes = options.VerifyNone
+	assert.T(t).This(string(bt.getNode(off))).Is("hxlloworld")
+
+	options.VerifyNodes = options.VerifyAlways
+	assert.T(t).This(func() { bt.getNode(off) }).Panics("checksum")
+
+	options.VerifyNodes = options.VerifyFirst
+	assert.T(t).This(func() { bt.getNode(off) }).Panics("checksum")
+	st.Data(off2)[3] = 'o' // repair
+	bt.getNode(off2)
+	st.Data(off2)[3] = 'x'
+	bt.getNode(off2) // already verified
+	// a different Stor with the same offsets is not verified
+	st2 := stor.HeapStor(128)
+	node([]byte("helloworld")).putNode(st2)
+	assert.T(t).This(node([]byte("goodbye")).putNode(st2)).Is(off2)
+	st2.Data(off2)[3] = 'x'
+	bt2 := &btree{stor: st2}
+	assert.T(t).This(func() { bt2.getNode(off2) }).Panics("checksum")
+	options.VerifyNodes = options.VerifyAlways
+	assert.T(t).This(func() { bt.getNode(off2) }).Panics("checksum")
+}
+
 func TestBtreeBuilder(t *testing.T) {
 	assert := assert.T(t)
 	GetLeafKey = func(_ *stor.Stor, _ *ixkey.Spec, i uint64) string {
@@ -157,3 +190,36 @@ func TestBtreeFracPos(t *testing.T) {
 	}
 	test(ixkey.Max, 1)
 }
+
+func TestBtreeSeparators(t *testing.T) {
+	defer func(mns int) { MaxNodeSize = mns }(MaxNodeSize)
+	MaxNodeSize = 256
+	key := func(i int) string {
+		return fmt.Sprintf("%05d", i)
+	}
+	b := Builder(stor.HeapStor(8192))
+	const n = 9500
+	for i := 0; i < n; i++ {
+		b.Add(key(i), 1)
+	}
+	bt := b.Finish()
+	assert.Msg("tree levels").This(bt.treeLevels).Is(2)
+	root := bt.Separators(1)
+	seps := bt.Separators(n)
+	assert.That(len(root) > 1)
+	assert.That(len(seps) > len(root))
+	assert.That(sort.StringsAreSorted(seps))
+	// the lowest level includes the separators from above
+	for _, sep := range root {
+		i := sort.SearchStrings(seps, sep)
+		assert.That(i < len(seps) && seps[i] == sep)
+	}
+	// the separators divide the keys approximately evenly
+	step := n / (len(seps) + 1)
+	for i, sep := range seps {
+		k, _ := strconv.Atoi(sep + strings.Repeat("0", 5-len(sep)))
+		diff := k - (i+1)*n/(len(seps)+1)
+		assert.That(-2*step < diff && diff < 2*step)
+	}
+	assert.This(len(CreateBtree(stor.HeapStor(8192), nil).Separators(1))).Is(0)
+}
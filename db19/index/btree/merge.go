@@ -8,6 +8,7 @@ import (
 
 	"github.com/apmckinlay/gsuneido/db19/index/ixbuf"
 	"github.com/apmckinlay/gsuneido/util/assert"
+	"github.com/apmckinlay/gsuneido/util/cksum"
 )
 
 // merge is one node on the current path.
@@ -34,6 +35,7 @@ type state struct {
 // Normally iter will be small relative to the btree.
 func (bt *btree) MergeAndSave(iter ixbuf.Iter) *btree {
 	bt2 := *bt // copy
+	bt2.written = 0
 	st := state{bt: &bt2}
 	for {
 		key, off, ok := iter()
@@ -50,6 +52,18 @@ func (bt *btree) MergeAndSave(iter ixbuf.Iter) *btree {
 	return st.bt
 }
 
+// putNode stores a node and adds its size to written
+func (bt *btree) putNode(nd node) uint64 {
+	bt.written += uint64(2 + len(nd) + cksum.Len)
+	return nd.putNode(bt.stor)
+}
+
+// Written returns the number of bytes of nodes that were written
+// by the MergeAndSave that returned this btree
+func (bt *btree) Written() uint64 {
+	return bt.written
+}
+
 func offstr(off uint64) string {
 	pre := ""
 	if off&ixbuf.Delete != 0 {
@@ -133,9 +147,9 @@ func (st *state) ascend() {
 		left, right, splitKey := m.split()
 		m.node = left
 		insertKey = splitKey
-		insertOff = right.putNode(bt.stor)
+		insertOff = bt.putNode(right)
 	}
-	off := m.node.putNode(bt.stor)
+	off := bt.putNode(m.node)
 	if len(st.path) > 0 {
 		parent := st.last()
 		parent.getMutableNode()
@@ -161,7 +175,7 @@ func (st *state) ascend() {
 			newRoot := make(node, 0, 24)
 			newRoot = newRoot.append(uint64(off), 0, "")
 			newRoot = newRoot.append(uint64(insertOff), 0, insertKey)
-			off = newRoot.putNode(bt.stor)
+			off = bt.putNode(newRoot)
 			st.push(off, newRoot, "")
 			bt.treeLevels++
 		}
@@ -224,12 +238,15 @@ func (m *merge) getMutableNode() node {
 	return m.node
 }
 
+// split divides the node in two.
+// Knowns are already the minimal distinguishing prefix of each key,
+// but to further shrink the tree nodes (suffix truncation)
+// it chooses the split point near the middle with the shortest known.
 func (m *merge) split() (left, right node, splitKey string) {
 	nd := m.getMutableNode()
-	size := len(nd)
-	splitSize := size / 2
+	splitPos := splitPoint(nd)
 	it := nd.iter()
-	for it.next() && it.pos < splitSize {
+	for it.next() && it.pos < splitPos {
 	}
 	splitKey = string(it.known)
 
@@ -253,3 +270,37 @@ func (m *merge) split() (left, right node, splitKey string) {
 	}
 	return
 }
+
+// splitPoint returns the position of the entry to split at.
+// It chooses the shortest known within the middle quarter of the node,
+// ties go to the closest to the middle.
+func splitPoint(nd node) int {
+	size := len(nd)
+	mid := size / 2
+	lo, hi := mid-size/8, mid+size/8
+	best, bestLen := -1, 0
+	it := nd.iter()
+	for it.next() {
+		if it.pos < lo || it.pos == 0 {
+			continue
+		}
+		if it.pos > hi && best != -1 {
+			break
+		}
+		if best == -1 || len(it.known) < bestLen ||
+			(len(it.known) == bestLen && dist(it.pos, mid) < dist(best, mid)) {
+			best, bestLen = it.pos, len(it.known)
+		}
+	}
+	if best == -1 {
+		return mid
+	}
+	return best
+}
+
+func dist(x, y int) int {
+	if x < y {
+		return y - x
+	}
+	return x - y
+}
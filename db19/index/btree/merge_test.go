@@ -138,3 +138,20 @@ func (m *merge) String() string {
 	}
 	return fmt.Sprint("off ", m.off, " pos ", m.pos, " limit ", limit, mod)
 }
+
+func TestMergeSplitShortest(t *testing.T) {
+	var data []string
+	for i := 0; i < 11; i++ {
+		data = append(data, fmt.Sprintf("a%03d", i))
+	}
+	data = append(data, "b")
+	for i := 0; i < 9; i++ {
+		data = append(data, fmt.Sprintf("b%03d", i))
+	}
+	get := func(i uint64) string { return data[i] }
+	m := merge{node: build(data)}
+	left, right, splitKey := m.split()
+	assert.T(t).This(splitKey).Is("b")
+	left.checkData(data[:11], get)
+	right.checkData(data[11:], get)
+}
@@ -27,6 +27,12 @@ import (
 //		- one byte prefix length (npre)
 //		- one byte key part length (len diff)
 //		- key part bytes (variable length) (diff)
+//
+// npre is relative to the previous entry's known (partial) key,
+// so a common prefix shared by the keys in a node (e.g. composite keys)
+// is only stored once, each entry only stores its distinguishing bytes.
+// This is why there is no separate node level common prefix,
+// it would not reduce the size any further.
 type node []byte
 
 const embedAll = 255
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/apmckinlay/gsuneido/db19/index/ixkey"
 	"github.com/apmckinlay/gsuneido/db19/stor"
 	"github.com/apmckinlay/gsuneido/util/assert"
 	"github.com/apmckinlay/gsuneido/util/str"
@@ -514,3 +515,19 @@ func Example_merge_split() {
 	// 1300 ''
 	// 1305 1305
 }
+
+func TestNodeCommonPrefix(t *testing.T) {
+	// the common prefix should only be stored once (in the second entry)
+	size := func(pre string) int {
+		var data []string
+		for i := 0; i < 50; i++ {
+			data = append(data, pre+fmt.Sprintf("%04d", i*7)+ixkey.Sep+"x")
+		}
+		return len(build(data))
+	}
+	pre := strings.Repeat("customer"+ixkey.Sep, 10)
+	short := size("")
+	long := size(pre)
+	assert.T(t).That(long <= short+len(pre)+10)
+	assert.T(t).That(short < 50*10)
+}
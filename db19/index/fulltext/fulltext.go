@@ -0,0 +1,95 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+/*
+Package fulltext implements inverted (full text) indexes.
+
+A full text index has one entry per distinct word per record.
+Btree leaves only store offsets and get their keys from the record,
+so each entry points to a small posting record (word, data offset)
+rather than to the data record itself.
+The key is the encoded posting record i.e. word + Sep + data offset
+so all the entries for a word are contiguous and ordered by offset.
+*/
+package fulltext
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/apmckinlay/gsuneido/db19/index/ixkey"
+	"github.com/apmckinlay/gsuneido/db19/stor"
+	rt "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/cksum"
+)
+
+// Spec is the ixkey.Spec for a full text index.
+// It extracts the key from a posting record, not from a data record.
+var Spec = ixkey.Spec{Fields: []int{0, 1}}
+
+// Words splits text into its distinct lower case words, sorted.
+// Words are sequences of letters and digits.
+func Words(text string) []string {
+	fields := strings.FieldsFunc(text, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	if len(fields) == 0 {
+		return nil
+	}
+	for i, f := range fields {
+		fields[i] = strings.ToLower(f)
+	}
+	sort.Strings(fields)
+	words := fields[:1]
+	for _, f := range fields[1:] {
+		if f != words[len(words)-1] {
+			words = append(words, f)
+		}
+	}
+	return words
+}
+
+// RecWords returns the Words of field fld of a data record
+func RecWords(rec rt.Record, fld int) []string {
+	raw := rec.GetRaw(fld)
+	if raw == "" {
+		return nil
+	}
+	return Words(rt.ToStrOrString(rt.Unpack(raw)))
+}
+
+// Posting returns the posting record for a word in the record at off
+func Posting(word string, off uint64) rt.Record {
+	var rb rt.RecordBuilder
+	rb.Add(rt.SuStr(word))
+	rb.Add(rt.Int64Val(int64(off)).(rt.Packable))
+	return rb.Build()
+}
+
+// Output stores the posting record for a word in the record at off
+// and returns its key and offset
+func Output(st *stor.Stor, word string, off uint64) (string, uint64) {
+	rec := Posting(word, off)
+	poff, buf := st.Alloc(len(rec) + cksum.Len)
+	copy(buf, rec)
+	cksum.Update(buf)
+	return Spec.Key(rec), poff
+}
+
+// Key returns the index key for a word in the record at off
+func Key(word string, off uint64) string {
+	return Spec.Key(Posting(word, off))
+}
+
+// Range returns the range of keys for the entries for a word
+func Range(word string) (org, end string) {
+	org = rt.Pack(rt.SuStr(word)) + ixkey.Sep
+	return org, org + ixkey.Max
+}
+
+// DataOff returns the data record offset from an index key
+func DataOff(key string) uint64 {
+	parts := ixkey.Decode(key)
+	return uint64(rt.ToInt64(rt.Unpack(parts[1])))
+}
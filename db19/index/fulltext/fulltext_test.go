@@ -0,0 +1,42 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package fulltext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestWords(t *testing.T) {
+	test := func(text string, expected ...string) {
+		t.Helper()
+		assert.T(t).This(Words(text)).Is(expected)
+	}
+	test("")
+	test("  ,. ")
+	test("hello", "hello")
+	test("Hello, World! hello", "hello", "world")
+	test("one-two_3 4b", "3", "4b", "one", "two")
+	test("Grüße aus Köln", "aus", "grüße", "köln")
+}
+
+func TestKeys(*testing.T) {
+	offs := []uint64{0, 1, 255, 256, 123456789, 1 << 40}
+	prev := ""
+	for _, off := range offs {
+		key := Key("fred", off)
+		assert.That(key > prev)
+		prev = key
+		assert.This(DataOff(key)).Is(off)
+		org, end := Range("fred")
+		assert.That(org <= key && key < end)
+		org, end = Range("fre")
+		assert.That(!(org <= key && key < end))
+		org, end = Range("freda")
+		assert.That(!(org <= key && key < end))
+	}
+	assert.That(strings.HasPrefix(Key("a", 5), "\x04a"))
+}
@@ -36,12 +36,32 @@ type Spec struct {
 	// Fields2 is used for unique indexes (that allow multiple empty keys).
 	// It will only be used if all of the Fields value are empty.
 	Fields2 []int
+	// Desc specifies which of Fields are in descending order.
+	// It may be shorter than Fields, missing values are ascending.
+	Desc []bool
 }
 
 func (spec *Spec) String() string {
+	if spec.hasDesc() {
+		return fmt.Sprint("ixspec ", spec.Fields, ",", spec.Fields2,
+			" desc ", spec.Desc)
+	}
 	return fmt.Sprint("ixspec ", spec.Fields, ",", spec.Fields2)
 }
 
+func (spec *Spec) desc(i int) bool {
+	return i < len(spec.Desc) && spec.Desc[i]
+}
+
+func (spec *Spec) hasDesc() bool {
+	for _, d := range spec.Desc {
+		if d {
+			return true
+		}
+	}
+	return false
+}
+
 // Encoder builds keys incrementally.
 // Note: Do not use this for single field keys - they should not be encoded.
 type Encoder struct {
@@ -58,6 +78,16 @@ func (e *Encoder) Add(fld string) {
 	e.buf = encode(e.buf, fld)
 }
 
+// AddDesc appends a field value for a descending field
+func (e *Encoder) AddDesc(fld string) {
+	if e.buf == nil {
+		e.buf = make([]byte, 0, 2*(len(fld)+4))
+	} else {
+		e.buf = append(e.buf, 0, 0) // separator
+	}
+	e.buf = encodeDesc(e.buf, fld)
+}
+
 // String returns the key and resets the Encoder to be empty.
 // Trailing field separators (empty fields) are trimmed.
 func (e *Encoder) String() string {
@@ -83,46 +113,79 @@ func (spec *Spec) Key(rec Record) string {
 	if len(fields) == 0 {
 		return ""
 	}
-	if len(fields) == 1 && len(spec.Fields2) == 0 {
+	if spec.raw() {
 		return getRaw(rec, fields[0]) // don't need to encode single field keys
 	}
 	n := 0
-	lastNonEmpty := -1
+	allEmpty := true
+	// descending fields are never trimmed because empty sorts last
+	lastKeep := -1
 	for i, field := range fields {
 		fldlen := len(rec.GetRaw(field))
 		if fldlen > 0 {
-			lastNonEmpty = i
+			allEmpty = false
+		}
+		if fldlen > 0 || spec.desc(i) {
+			lastKeep = i
 		}
 		n += fldlen
 	}
-	if lastNonEmpty == -1 { // fields all empty
-		if len(spec.Fields2) == 0 {
-			return ""
-		}
+	useFields2 := allEmpty && len(spec.Fields2) > 0
+	if useFields2 {
 		for _, field := range spec.Fields2 {
 			n += fieldLen(rec, field)
 		}
+	} else if lastKeep == -1 {
+		return ""
 	} else {
-		fields = fields[:lastNonEmpty+1]
+		fields = fields[:lastKeep+1]
 	}
 	n += 2 * len(fields) // for separators (2 bytes extra)
 	n += n / 16          // allow for some escapes
 	buf := make([]byte, 0, n)
-	if lastNonEmpty == -1 {
-		for range fields {
+	if useFields2 {
+		for i := range fields {
+			buf = spec.encodeField(buf, i, "")
 			buf = append(buf, 0, 0) // separator
 		}
-		fields = spec.Fields2
+		for i, f := range spec.Fields2 {
+			if i > 0 {
+				buf = append(buf, 0, 0) // separator
+			}
+			buf = encode(buf, getRaw(rec, f))
+		}
+		return hacks.BStoS(buf)
 	}
 	for i, f := range fields {
 		if i > 0 {
 			buf = append(buf, 0, 0) // separator
 		}
-		buf = encode(buf, getRaw(rec, f))
+		buf = spec.encodeField(buf, i, getRaw(rec, f))
 	}
 	return hacks.BStoS(buf)
 }
 
+func (spec *Spec) encodeField(buf []byte, i int, b string) []byte {
+	if spec.desc(i) {
+		return encodeDesc(buf, b)
+	}
+	return encode(buf, b)
+}
+
+// encodeDesc appends a descending field.
+// The escaped value is complemented so it compares in reverse,
+// and then escaped again since complementing can produce zero bytes.
+// The 0xff,0xff terminator makes a value sort after its extensions
+// (it can not occur in the complemented value since encode has no 0,0)
+func encodeDesc(buf []byte, b string) []byte {
+	enc := encode(nil, b)
+	for i := range enc {
+		enc[i] = ^enc[i]
+	}
+	buf = encode(buf, hacks.BStoS(enc))
+	return append(buf, 0xff, 0xff)
+}
+
 func encode(buf []byte, b string) []byte {
 	for len(b) > 0 {
 		i := strings.IndexByte(b, 0)
@@ -158,7 +221,7 @@ func getRaw(rec Record, field int) string {
 // without building keys for them
 func (spec *Spec) Compare(r1, r2 Record) int {
 	empty := true
-	for _, f := range spec.Fields {
+	for i, f := range spec.Fields {
 		var x1, x2 string
 		var cmp int
 		if f < 0 { // _lower!
@@ -172,6 +235,9 @@ func (spec *Spec) Compare(r1, r2 Record) int {
 			cmp = strings.Compare(x1, x2)
 		}
 		if cmp != 0 {
+			if spec.desc(i) {
+				return -cmp
+			}
 			return cmp
 		}
 		if x1 != "" || x2 != "" {
@@ -199,11 +265,14 @@ func (spec *Spec) Increment(key string) string {
 
 func (spec *Spec) raw() bool {
 	return len(spec.Fields) == 0 ||
-		(len(spec.Fields) == 1 && len(spec.Fields2) == 0)
+		(len(spec.Fields) == 1 && len(spec.Fields2) == 0 && !spec.desc(0))
 }
 
 func (spec *Spec) Trunc(n int) *Spec {
-	return &Spec{Fields: spec.Fields[:n]}
+	if len(spec.Desc) > n {
+		return &Spec{Fields: spec.Fields[:n], Desc: spec.Desc[:n]}
+	}
+	return &Spec{Fields: spec.Fields[:n], Desc: spec.Desc}
 }
 
 // Decode is for tests and debugging
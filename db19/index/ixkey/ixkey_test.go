@@ -173,3 +173,60 @@ func TestHasPrefix(t *testing.T) {
 	assert.T(t).True(HasPrefix("foo\x00\x00bar", "foo\x00\x00bar"))
 	assert.T(t).False(HasPrefix("foo\x00\x00bar", "foo\x00\x00ba"))
 }
+
+func TestDesc(t *testing.T) {
+	assert := assert.T(t).This
+	spec := Spec{Fields: []int{0}, Desc: []bool{true}}
+	assert(spec.Key(mkrec("ab"))).Is("\x9e\x9d\xff\xff")
+	assert(spec.Key(mkrec(""))).Is("\xff\xff")
+	assert(spec.Key(mkrec("ab")) > spec.Key(mkrec("abc"))).Is(true)
+	assert(spec.Key(mkrec("a")) > spec.Key(mkrec("b"))).Is(true)
+	assert(spec.Key(mkrec("")) > spec.Key(mkrec("\xff"))).Is(true)
+
+	// descending trailing fields are not trimmed
+	spec = Spec{Fields: []int{0, 1}, Desc: []bool{false, true}}
+	assert(spec.Key(mkrec("a", ""))).Is("a\x00\x00\xff\xff")
+	assert(spec.Key(mkrec("a", "")) > spec.Key(mkrec("a", "b"))).Is(true)
+	assert(spec.Trunc(1).Key(mkrec("a", "b"))).Is("a")
+
+	enc := Encoder{}
+	enc.Add("a")
+	enc.AddDesc("b")
+	assert(enc.String()).Is(spec.Key(mkrec("a", "b")))
+}
+
+func TestDescRandom(t *testing.T) {
+	var n = 100000
+	if testing.Short() {
+		n = 10000
+	}
+	fields := []int{0, 1, 2}
+	specs := []Spec{
+		{Fields: fields, Desc: []bool{true}},
+		{Fields: fields, Desc: []bool{false, true}},
+		{Fields: fields, Desc: []bool{true, false, true}},
+		{Fields: fields, Desc: []bool{true, true, true}},
+		{Fields: fields, Fields2: []int{3}, Desc: []bool{false, true}},
+	}
+	for i := 0; i < n; i++ {
+		spec := &specs[i%len(specs)]
+		x := genEmpties()
+		y := genEmpties()
+		cmp := strings.Compare(spec.Key(x), spec.Key(y))
+		assert.T(t).This(cmp).Is(spec.Compare(x, y))
+	}
+}
+
+// genEmpties is like gen but with some empty fields
+// and a fourth field for Fields2
+func genEmpties() Record {
+	var b RecordBuilder
+	for i := 0; i < m+1; i++ {
+		x := make([]byte, rand.Intn(4))
+		for j := range x {
+			x[j] = []byte{0, 1, 0xfe, 0xff}[rand.Intn(4)]
+		}
+		b.AddRaw(string(x))
+	}
+	return b.Build()
+}
@@ -33,6 +33,8 @@ type OverIter struct {
 	rng     Range
 	tran    oiTran
 	overlay *Overlay
+	// seekKey is the key from the last Seek, used for read tracking
+	seekKey string
 }
 
 type state byte
@@ -41,6 +43,8 @@ const (
 	rewound state = iota
 	front
 	back
+	// within is after a Seek, reads are tracked relative to seekKey
+	within
 	eof
 )
 
@@ -108,6 +112,12 @@ func (mi *OverIter) Next(t oiTran) {
 		} else {
 			mi.tran.Read(mi.table, mi.iIndex, mi.rng.Org, mi.curKey)
 		}
+	} else if lastState == within {
+		if mi.state == eof {
+			mi.tran.Read(mi.table, mi.iIndex, mi.seekKey, mi.rng.End)
+		} else {
+			mi.tran.Read(mi.table, mi.iIndex, mi.seekKey, mi.curKey)
+		}
 	}
 }
 
@@ -244,6 +254,12 @@ func (mi *OverIter) Prev(t oiTran) {
 		} else {
 			mi.tran.Read(mi.table, mi.iIndex, mi.curKey, mi.rng.End)
 		}
+	} else if lastState == within {
+		if mi.state == eof {
+			mi.tran.Read(mi.table, mi.iIndex, mi.rng.Org, mi.seekKey)
+		} else {
+			mi.tran.Read(mi.table, mi.iIndex, mi.curKey, mi.seekKey)
+		}
 	}
 }
 
@@ -312,6 +328,53 @@ outer:
 	}
 }
 
+// Seek positions the iterator at the given key.
+// It returns true if the resulting current key is equal to key,
+// false if it is positioned at a greater key or is at eof.
+// Next and Prev continue from the resulting position.
+func (mi *OverIter) Seek(t oiTran, key string) bool {
+	mi.SeekGE(t, key)
+	return mi.state != eof && mi.curKey == key
+}
+
+// SeekGE positions the iterator at the first key >= the given key
+// that is within the range, or at eof if there is none.
+// It allows restarting an iteration from a key
+// without re-walking from the start of the range.
+func (mi *OverIter) SeekGE(t oiTran, key string) {
+	if key < mi.rng.Org {
+		key = mi.rng.Org
+	}
+	if t != mi.tran || mi.iters == nil {
+		ov := t.GetIndexI(mi.table, mi.iIndex)
+		mi.newIters(ov)
+		mi.tran = t
+	}
+	mi.seekKey = key
+	mi.lastDir = next
+	if key >= mi.rng.End {
+		mi.all(iterT.Rewind)
+		mi.state = eof
+		return
+	}
+	for _, it := range mi.iters {
+		it.Seek(key)
+		if !it.Eof() {
+			if k, _ := it.Cur(); k < key {
+				it.Next() // Seek may leave us on the last key < key
+			}
+		}
+	}
+	mi.curIter, mi.curKey, mi.curOff = mi.minIter()
+	if mi.curIter == -1 {
+		mi.state = eof
+		mi.tran.Read(mi.table, mi.iIndex, key, mi.rng.End)
+	} else {
+		mi.state = within
+		mi.tran.Read(mi.table, mi.iIndex, key, mi.curKey)
+	}
+}
+
 func (mi *OverIter) Rewind() {
 	mi.all(iterT.Rewind)
 	mi.state = rewound
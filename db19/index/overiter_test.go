@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/apmckinlay/gsuneido/db19/index/btree"
+	"github.com/apmckinlay/gsuneido/db19/index/iterator"
 	"github.com/apmckinlay/gsuneido/db19/index/ixbuf"
 	"github.com/apmckinlay/gsuneido/db19/index/ixkey"
 	"github.com/apmckinlay/gsuneido/db19/stor"
@@ -578,3 +579,79 @@ func (it *dumIter) Prev() {
 	}
 	it.state = eof
 }
+
+func TestOverIterSeek(*testing.T) {
+	from := func(args ...int) *ixbuf.T {
+		ib := &ixbuf.T{}
+		for _, n := range args {
+			ib.Insert(strconv.Itoa(n), uint64(n))
+		}
+		return ib
+	}
+	even := from(0, 2, 4, 6, 8)
+	odd := from(1, 3, 5, 7, 9)
+	bt := btree.CreateBtree(stor.HeapStor(8192), nil)
+	t := &testTran{getIndex: func() *Overlay {
+		return &Overlay{bt: bt, layers: []*ixbuf.T{even, odd}}
+	}}
+	it := NewOverIter("", 0)
+	test := func(expected int) {
+		if expected == -1 {
+			assert.Msg("expected Eof").That(it.Eof())
+		} else {
+			assert.That(!it.Eof())
+			key, off := it.Cur()
+			assert.This(key).Is(strconv.Itoa(expected))
+			assert.This(off).Is(uint64(expected))
+		}
+	}
+
+	assert.That(it.Seek(t, "5"))
+	test(5)
+	assert.This(t.reads.String()).Is("5->5")
+	it.Next(t)
+	test(6)
+	it.Next(t)
+	test(7)
+	assert.This(t.reads.String()).Is("5->7")
+	it.Prev(t)
+	test(6)
+	it.Prev(t)
+	test(5)
+	it.Prev(t)
+	test(4)
+	assert.This(t.reads.String()).Is("4->7")
+
+	assert.That(!it.Seek(t, "55"))
+	test(6)
+	it.SeekGE(t, "99")
+	test(-1)
+	it.Prev(t)
+	test(-1) // stick at eof
+
+	it.Range(Range{Org: "3", End: "6"})
+	it.SeekGE(t, "1")
+	test(3)
+	it.Next(t)
+	test(4)
+	it.SeekGE(t, "5")
+	test(5)
+	it.Next(t)
+	test(-1)
+	it.SeekGE(t, "6")
+	test(-1)
+	it.Rewind()
+	it.Next(t)
+	test(3)
+
+	// deletes in a later layer are skipped
+	odd.Delete("5", 5)
+	t = &testTran{getIndex: t.getIndex}
+	it.SeekGE(t, "5")
+	test(-1)
+	it.Range(iterator.All)
+	it.SeekGE(t, "5")
+	test(6)
+	it.Prev(t)
+	test(4)
+}
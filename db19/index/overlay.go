@@ -48,6 +48,25 @@ func (ov *Overlay) Nlayers() int {
 	return len(ov.layers)
 }
 
+// BufLen returns the number of entries in the base ixbuf
+// (merged but not persisted) and in the transaction ixbufs (not merged)
+func (ov *Overlay) BufLen() (merged, unmerged int) {
+	for _, ib := range ov.layers[1:] {
+		unmerged += ib.Len()
+	}
+	return ov.layers[0].Len(), unmerged
+}
+
+// MergeLen returns the number of entries in the transaction ixbufs
+// that will be merged by Merge(nmerge)
+func (ov *Overlay) MergeLen(nmerge int) int {
+	n := 0
+	for _, ib := range ov.layers[1 : nmerge+1] {
+		n += ib.Len()
+	}
+	return n
+}
+
 // Mutable returns a modifiable copy of an Overlay
 func (ov *Overlay) Mutable() *Overlay {
 	assert.That(ov.mut == nil)
@@ -148,6 +167,12 @@ func (ov *Overlay) Check(fn func(uint64)) int {
 	return n
 }
 
+// Salvage calls fn with the readable offsets in the base btree.
+// It returns the number of damaged nodes.
+func (ov *Overlay) Salvage(fn func(uint64)) int {
+	return ov.bt.Salvage(fn)
+}
+
 func (ov *Overlay) QuickCheck() {
 	ov.bt.QuickCheck()
 }
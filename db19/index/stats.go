@@ -0,0 +1,194 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package index
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/apmckinlay/gsuneido/db19/index/btree"
+	"github.com/apmckinlay/gsuneido/db19/index/ixkey"
+	"github.com/apmckinlay/gsuneido/db19/stor"
+)
+
+// Stats are statistics about the keys of an index for the query optimizer.
+// They are collected from the btree by persist and stored in meta.
+type Stats struct {
+	// Nrows is the number of keys when the stats were collected
+	Nrows int
+	// Distinct is the number of distinct values of the index columns
+	Distinct int
+	// Fields is the number of index columns, 0 if the keys are not encoded
+	Fields int
+	// Hist is an equi-depth histogram.
+	// The keys are the bucket boundaries, truncated to the index columns.
+	Hist []string
+}
+
+// HistSize is the number of histogram boundaries (one less than buckets)
+const HistSize = 31
+
+// maxHistKey limits the size of histogram keys to keep meta small
+const maxHistKey = 32
+
+// CollectStats determines the Stats for a btree with nrows keys.
+// fields is the number of index columns, 0 if the keys are not encoded.
+// The histogram comes from the separators in the tree nodes
+// and Distinct is estimated from a sample of the leaves
+// so it only reads a limited number of keys,
+// since it is called by persist.
+func CollectStats(bt *btree.T, nrows, fields int) *Stats {
+	seps := bt.Separators(4 * (HistSize + 1))
+	if len(seps) == 0 {
+		return scanStats(bt, fields) // a single leaf node
+	}
+	st := &Stats{Nrows: nrows, Fields: fields,
+		Hist: make([]string, 0, HistSize)}
+	if len(seps) <= HistSize {
+		for _, sep := range seps {
+			st.Hist = append(st.Hist, histKey(sep, fields))
+		}
+	} else {
+		for i := 1; i <= HistSize; i++ {
+			sep := seps[i*(len(seps)+1)/(HistSize+1)-1]
+			st.Hist = append(st.Hist, histKey(sep, fields))
+		}
+	}
+	st.Distinct = sampleDistinct(bt, seps, nrows, fields)
+	return st
+}
+
+// scanStats determines the Stats by iterating through all the keys
+func scanStats(bt *btree.T, fields int) *Stats {
+	var keys []string
+	it := bt.Iterator()
+	for it.Next(); !it.Eof(); it.Next() {
+		key, _ := it.Cur()
+		keys = append(keys, fieldsPrefix(key, fields))
+	}
+	st := &Stats{Nrows: len(keys), Fields: fields,
+		Hist: make([]string, 0, HistSize)}
+	step := len(keys)/(HistSize+1) + 1
+	for i, key := range keys {
+		if i == 0 || key != keys[i-1] {
+			st.Distinct++
+		}
+		if (i+1)%step == 0 && len(st.Hist) < HistSize {
+			st.Hist = append(st.Hist, histKey(key, 0))
+		}
+	}
+	return st
+}
+
+func histKey(key string, fields int) string {
+	key = fieldsPrefix(key, fields)
+	if len(key) > maxHistKey {
+		key = key[:maxHistKey]
+	}
+	return key
+}
+
+// distinctSamples is the number of places sampleDistinct reads from
+const distinctSamples = 8
+
+// distinctSampleSize is the number of keys sampleDistinct reads at each place
+const distinctSampleSize = 64
+
+// sampleDistinct estimates the number of distinct values
+// from how often the value changes between adjacent keys
+// in samples starting at evenly spaced separators
+func sampleDistinct(bt *btree.T, seps []string, nrows, fields int) int {
+	pairs, changes := 0, 0
+	it := bt.Iterator()
+	for i := 0; i < distinctSamples; i++ {
+		it.Seek(seps[i*len(seps)/distinctSamples])
+		prev := ""
+		for j := 0; j < distinctSampleSize && !it.Eof(); j++ {
+			key, _ := it.Cur()
+			key = fieldsPrefix(key, fields)
+			if j > 0 {
+				pairs++
+				if key != prev {
+					changes++
+				}
+			}
+			prev = key
+			it.Next()
+		}
+	}
+	if pairs == 0 || changes == 0 {
+		return 1
+	}
+	return int(float64(nrows)*float64(changes)/float64(pairs) + .5)
+}
+
+// fieldsPrefix returns the first n fields of an encoded key,
+// or the entire key if n is 0
+func fieldsPrefix(key string, n int) string {
+	if n == 0 {
+		return key
+	}
+	i := 0
+	for ; n > 0; n-- {
+		j := strings.Index(key[i:], ixkey.Sep)
+		if j == -1 {
+			return key
+		}
+		i += j + len(ixkey.Sep)
+	}
+	return key[:i-len(ixkey.Sep)]
+}
+
+// Stale returns whether the stats should be collected again
+// because the number of rows has changed by more than 10%
+func (st *Stats) Stale(nrows int) bool {
+	if st == nil {
+		return true
+	}
+	diff := nrows - st.Nrows
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff*10 > st.Nrows
+}
+
+// RangeFrac estimates the fraction of the keys (0 to 1)
+// in the range org to end.
+// A point on all the index columns is estimated using Distinct.
+// It returns false if the range is within a single bucket
+// since the histogram can't estimate narrower ranges.
+func (st *Stats) RangeFrac(org, end string) (float64, bool) {
+	if st.isPoint(org, end) && st.Distinct > 0 {
+		return 1 / float64(st.Distinct), true
+	}
+	// the range is at most within the buckets from lo to hi
+	lo := sort.SearchStrings(st.Hist, org)
+	hi := sort.SearchStrings(st.Hist, end)
+	if lo == hi {
+		return 0, false
+	}
+	nb := len(st.Hist) + 1
+	return float64(hi-lo+1) / float64(nb), true
+}
+
+func (st *Stats) isPoint(org, end string) bool {
+	if st.Fields == 0 {
+		return end == org+"\x00"
+	}
+	return end == org+ixkey.Sep+ixkey.Max &&
+		strings.Count(org, ixkey.Sep) == st.Fields-1
+}
+
+func (st *Stats) StorSize() int {
+	return 4 + 4 + 1 + stor.LenStrs(st.Hist)
+}
+
+func (st *Stats) Write(w *stor.Writer) {
+	w.Put4(st.Nrows).Put4(st.Distinct).Put1(st.Fields).PutStrs(st.Hist)
+}
+
+func ReadStats(r *stor.Reader) *Stats {
+	return &Stats{Nrows: r.Get4(), Distinct: r.Get4(), Fields: r.Get1(),
+		Hist: r.GetStrs()}
+}
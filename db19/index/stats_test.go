@@ -0,0 +1,76 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package index
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/db19/index/btree"
+	"github.com/apmckinlay/gsuneido/db19/index/ixkey"
+	"github.com/apmckinlay/gsuneido/db19/stor"
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestStats(t *testing.T) {
+	assert := assert.T(t).This
+	build := func(n int) *btree.T {
+		keys := make([]string, n)
+		enc := ixkey.Encoder{}
+		for i := range keys {
+			enc.Add(fmt.Sprintf("%04d", i/4))
+			enc.Add(fmt.Sprintf("%05d", i))
+			keys[i] = enc.String()
+		}
+		btree.GetLeafKey = func(_ *stor.Stor, _ *ixkey.Spec, i uint64) string {
+			return keys[i-1]
+		}
+		b := btree.Builder(stor.HeapStor(8192))
+		for i, k := range keys {
+			b.Add(k, uint64(i+1))
+		}
+		return b.Finish()
+	}
+
+	// a single leaf is scanned
+	st := CollectStats(build(20), 20, 1)
+	assert(st.Nrows).Is(20)
+	assert(st.Distinct).Is(5)
+	assert(len(st.Hist)).Is(20)
+
+	// larger indexes use the tree separators and sample the leaves
+	const n = 10000
+	st = CollectStats(build(n), n, 1)
+	assert(st.Nrows).Is(n)
+	assert(n/5 < st.Distinct && st.Distinct < n/3).Is(true)
+	assert(len(st.Hist)).Is(HistSize)
+	assert(sort.StringsAreSorted(st.Hist)).Is(true)
+	frac, ok := st.RangeFrac(ixkey.Min, ixkey.Max)
+	assert(ok).Is(true)
+	assert(frac).Is(1.0)
+	frac, ok = st.RangeFrac("1000", "1000"+ixkey.Sep+ixkey.Max)
+	assert(ok).Is(true)
+	assert(frac).Is(1.0 / float64(st.Distinct))
+	frac, ok = st.RangeFrac("0500", "1000")
+	assert(ok).Is(true)
+	assert(.15 < frac && frac < .3).Is(true)
+	// narrow ranges are left to the btree
+	_, ok = st.RangeFrac("0500", "0501")
+	assert(ok).Is(false)
+
+	assert(st.Stale(n + n/20)).Is(false)
+	assert(st.Stale(n - n/5)).Is(true)
+	assert((*Stats)(nil).Stale(0)).Is(true)
+
+	buf := make([]byte, st.StorSize())
+	w := stor.NewWriter(buf)
+	st.Write(w)
+	assert(w.Len()).Is(st.StorSize())
+	assert(ReadStats(stor.NewReader(buf))).Is(st)
+
+	assert(fieldsPrefix("a\x00\x00b\x00\x00c", 2)).Is("a\x00\x00b")
+	assert(fieldsPrefix("a\x00\x01b", 1)).Is("a\x00\x01b")
+	assert(fieldsPrefix("abc", 0)).Is("abc")
+}
@@ -0,0 +1,117 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"log"
+	"strconv"
+)
+
+// TranLimits are the limits on update transactions.
+// Zero means no limit or no warning.
+type TranLimits struct {
+	// MaxAge is the maximum number of seconds (checker ticks)
+	// that a transaction can be outstanding before it is aborted
+	MaxAge int
+	// MaxWrites is the maximum number of outputs, updates, and deletes
+	MaxWrites int
+	// WarnAge and WarnWrites are when LimitWarning is called
+	WarnAge    int
+	WarnWrites int
+}
+
+// DefaultTranLimits are the limits for a database
+// unless they are set with SetTranLimits
+var DefaultTranLimits = TranLimits{MaxAge: 20}
+
+// LimitWarning is called once when a transaction reaches
+// WarnAge or WarnWrites.
+// Age warnings are called from the checker so it must not block.
+var LimitWarning = func(tran, warning string) {
+	log.Println("WARNING:", tran, warning)
+}
+
+// SetTranLimits sets the limits for transactions started after this.
+// Individual transactions can override them with UpdateTran.SetLimits
+// The limits are not persisted,
+// they revert to DefaultTranLimits when the database is reopened.
+func (db *Database) SetTranLimits(lim TranLimits) {
+	db.limits.Store(lim)
+}
+
+// TranLimits returns the limits for new transactions
+func (db *Database) TranLimits() TranLimits {
+	if lim := db.limits.Load(); lim != nil {
+		return lim.(TranLimits)
+	}
+	return DefaultTranLimits
+}
+
+func (ck *Check) tranLimits() TranLimits {
+	if ck.db == nil {
+		return DefaultTranLimits
+	}
+	return ck.db.TranLimits()
+}
+
+func (t *CkTran) getLimits() TranLimits {
+	return t.limits.Load().(TranLimits)
+}
+
+// SetLimits overrides the database limits for this transaction,
+// e.g. to allow a batch job to run longer or do more writes.
+func (t *UpdateTran) SetLimits(lim TranLimits) {
+	t.ct.limits.Store(lim)
+}
+
+func (t *UpdateTran) Limits() TranLimits {
+	return t.ct.getLimits()
+}
+
+// WriteCount returns the number of outputs, updates, and deletes
+func (t *UpdateTran) WriteCount() int {
+	return t.nwrites
+}
+
+// addWrite is called before each output, update, and delete.
+// It panics if the transaction has reached MaxWrites.
+// Bulk transactions are not limited.
+func (t *UpdateTran) addWrite() {
+	if t.ct.bulk != nil || t.replay {
+		t.nwrites++
+		return
+	}
+	lim := t.Limits()
+	if lim.MaxWrites > 0 && t.nwrites >= lim.MaxWrites {
+		panic("transaction exceeded max writes (" +
+			strconv.Itoa(lim.MaxWrites) + ")")
+	}
+	t.nwrites++
+	if t.nwrites == lim.WarnWrites {
+		LimitWarning(t.String(), "update transaction has done "+
+			strconv.Itoa(t.nwrites)+" writes")
+	}
+}
+
+// checkAge is called by tick.
+// It returns true if the transaction exceeded MaxAge and was aborted.
+func (ck *Check) checkAge(tn int, t *CkTran) bool {
+	if t.bulk != nil || t.isEnded() {
+		return false
+	}
+	lim := t.getLimits()
+	age := ck.clock - t.birth
+	if lim.MaxAge > 0 && age >= lim.MaxAge {
+		traceln("abort", tn, "age", age)
+		log.Println("aborted", t, "update transaction longer than",
+			lim.MaxAge, "seconds")
+		ck.abort(tn, "transaction exceeded max age")
+		return true
+	}
+	if lim.WarnAge > 0 && age == lim.WarnAge {
+		LimitWarning(t.String(), "update transaction has been outstanding for "+
+			strconv.Itoa(age)+" seconds")
+	}
+	return false
+}
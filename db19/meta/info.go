@@ -5,8 +5,10 @@ package meta
 
 import (
 	"github.com/apmckinlay/gsuneido/db19/index"
+	"github.com/apmckinlay/gsuneido/db19/meta/schema"
 	"github.com/apmckinlay/gsuneido/db19/stor"
 	"github.com/apmckinlay/gsuneido/util/hash"
+	"github.com/apmckinlay/gsuneido/util/strs"
 )
 
 type Info struct {
@@ -16,10 +18,43 @@ type Info struct {
 	origNrows int
 	origSize  uint64
 	Indexes   []*index.Overlay
+	// Stats are the optimizer statistics for Indexes, collected by persist.
+	// It may be shorter than Indexes and may contain nil.
+	Stats []*index.Stats
+	// Seq is the limit of the numbers reserved for Schema.AutoNumber,
+	// numbers below it may have been used. See Database.autoNumber
+	Seq int
+	// Metrics are counters of the background work for the table.
+	// They are not persisted, so they are since the database was opened.
+	Metrics Metrics
 	// lastmod is used for persist chaining/flattening
 	lastmod int
 }
 
+// Metrics are the counters for a table, see Info.Metrics
+type Metrics struct {
+	// Merges is the number of merges of transaction ixbufs
+	Merges int
+	// KeysMerged is the total number of index entries merged
+	KeysMerged int
+	// Persists is the number of times the indexes were saved
+	Persists int
+	// PersistBytes is the total size of the index nodes saved
+	PersistBytes uint64
+}
+
+// BufLen returns the total number of index entries
+// that have been merged but not persisted,
+// and that are from transactions that have not been merged
+func (ti *Info) BufLen() (merged, unmerged int) {
+	for _, ov := range ti.Indexes {
+		m, u := ov.BufLen()
+		merged += m
+		unmerged += u
+	}
+	return
+}
+
 //go:generate genny -in ../../genny/hamt/hamt.go -out infohamt.go -pkg meta gen "Item=*Info KeyType=string"
 
 func InfoKey(ti *Info) string {
@@ -32,20 +67,77 @@ func InfoHash(key string) uint32 {
 
 func (ti *Info) storSize() int {
 	size := 2 + len(ti.Table) + 4 + 5 + 1
+	if ti.Seq != 0 {
+		size += 5
+	}
 	for i := range ti.Indexes {
 		size += ti.Indexes[i].StorSize()
 	}
+	if ti.hasStats() {
+		for i := range ti.Indexes {
+			size++
+			if st := ti.IndexStats(i); st != nil {
+				size += st.StorSize()
+			}
+		}
+	}
 	return size
 }
 
+// statsFlag is set on the stored number of indexes
+// if the index stats follow the indexes.
+// It can not occur in older databases since there are fewer indexes.
+const statsFlag = 0x80
+
+// seqFlag is set on the stored number of indexes if Seq follows
+const seqFlag = 0x40
+
 func (ti *Info) Write(w *stor.Writer) {
+	n := len(ti.Indexes)
+	hasStats := ti.hasStats()
+	if hasStats {
+		n |= statsFlag
+	}
+	if ti.Seq != 0 {
+		n |= seqFlag
+	}
 	w.PutStr(ti.Table).
 		Put4(ti.Nrows).
 		Put5(ti.Size).
-		Put1(len(ti.Indexes))
+		Put1(n)
 	for i := range ti.Indexes {
 		ti.Indexes[i].Write(w)
 	}
+	if hasStats {
+		for i := range ti.Indexes {
+			if st := ti.IndexStats(i); st != nil {
+				w.Put1(1)
+				st.Write(w)
+			} else {
+				w.Put1(0)
+			}
+		}
+	}
+	if ti.Seq != 0 {
+		w.Put5(uint64(ti.Seq))
+	}
+}
+
+func (ti *Info) hasStats() bool {
+	for i := range ti.Indexes {
+		if ti.IndexStats(i) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// IndexStats returns the stats for the i'th index, or nil if none
+func (ti *Info) IndexStats(i int) *index.Stats {
+	if i < len(ti.Stats) {
+		return ti.Stats[i]
+	}
+	return nil
 }
 
 func ReadInfo(st *stor.Stor, r *stor.Reader) *Info {
@@ -53,11 +145,26 @@ func ReadInfo(st *stor.Stor, r *stor.Reader) *Info {
 	ti.Table = r.GetStr()
 	ti.Nrows = r.Get4()
 	ti.Size = r.Get5()
-	if ni := r.Get1(); ni > 0 {
+	ni := r.Get1()
+	hasStats := ni&statsFlag != 0
+	hasSeq := ni&seqFlag != 0
+	ni &^= statsFlag | seqFlag
+	if ni > 0 {
 		ti.Indexes = make([]*index.Overlay, ni)
 		for i := 0; i < ni; i++ {
 			ti.Indexes[i] = index.ReadOverlay(st, r)
 		}
+		if hasStats {
+			ti.Stats = make([]*index.Stats, ni)
+			for i := 0; i < ni; i++ {
+				if r.Get1() != 0 {
+					ti.Stats[i] = index.ReadStats(r)
+				}
+			}
+		}
+	}
+	if hasSeq {
+		ti.Seq = int(r.Get5())
 	}
 	return &ti
 }
@@ -66,6 +173,12 @@ func (m *Meta) newInfoTomb(table string) *Info {
 	return &Info{Table: table}
 }
 
+// Growth returns the change in Nrows and Size made by an update transaction
+// i.e. for an Info from GetRwInfo
+func (ti *Info) Growth() (nrows int, size int64) {
+	return ti.Nrows - ti.origNrows, int64(ti.Size - ti.origSize)
+}
+
 func (ti *Info) isTomb() bool {
 	return ti.Indexes == nil
 }
@@ -86,6 +199,7 @@ type MergeResult = index.MergeResult
 type MergeUpdate struct {
 	table   string
 	nmerged int
+	nkeys   int
 	results []MergeResult // per index
 }
 
@@ -100,12 +214,15 @@ func (m *Meta) Merge(metaWas *Meta, table string, nmerge int) MergeUpdate {
 	was := metaWas.schema.MustGet(table)
 	ti := m.info.MustGet(table)
 	results := make([]MergeResult, len(ti.Indexes))
+	nkeys := 0
 	for i, ov := range ti.Indexes {
 		if !skipIndex(was, cur, i) {
+			nkeys += ov.MergeLen(nmerge)
 			results[i] = ov.Merge(nmerge)
 		}
 	}
-	return MergeUpdate{table: table, nmerged: nmerge, results: results}
+	return MergeUpdate{table: table, nmerged: nmerge, nkeys: nkeys,
+		results: results}
 }
 
 func skipIndex(was, cur *Schema, i int) bool {
@@ -118,7 +235,23 @@ func skipIndex(was, cur *Schema, i int) bool {
 		return true // index dropped
 	}
 	wasIdx := was.FindIndex(cols)
-	return curIdx != wasIdx // index modified
+	return curIdx != wasIdx && !sameIndex(wasIdx, curIdx) // index modified
+}
+
+// sameIndex returns whether two versions of an index (with the same columns)
+// have the same entries. Foreign keys (Fk and FkToHere) are ignored
+// since creating a foreign key modifies the schema of the target table.
+func sameIndex(x, y *schema.Index) bool {
+	if x.Mode != y.Mode || !strs.Equal(x.Storing, y.Storing) ||
+		len(x.Desc) != len(y.Desc) {
+		return false
+	}
+	for i := range x.Desc {
+		if x.Desc[i] != y.Desc[i] {
+			return false
+		}
+	}
+	return true
 }
 
 func (mu *MergeUpdate) Skip() bool {
@@ -135,6 +268,8 @@ func (m *Meta) ApplyMerge(updates []MergeUpdate) {
 		for i, ov := range ti.Indexes {
 			ti.Indexes[i] = ov.WithMerged(up.results[i], up.nmerged)
 		}
+		ti.Metrics.Merges++
+		ti.Metrics.KeysMerged += up.nkeys
 		t2.Put(&ti)
 	}
 	m.info = t2.Freeze()
@@ -147,25 +282,50 @@ type SaveResult = index.SaveResult
 type PersistUpdate struct {
 	table   string
 	results []SaveResult // per index
+	stats   []*index.Stats
 }
 
 // Persist is called by state.Persist to write the state to the database.
 // It collects the new btree roots which are then applied by ApplyPersist.
+// It also collects index stats if they are missing or stale.
 // WARNING: must not modify meta.
 func (m *Meta) Persist(exec func(func() PersistUpdate)) {
 	m.info.ForEach(func(ti *Info) {
 		if len(ti.Indexes) >= 1 && ti.Indexes[0].Modified() {
+			ts := m.schema.MustGet(ti.Table)
 			exec(func() PersistUpdate {
 				results := make([]SaveResult, len(ti.Indexes))
+				var stats []*index.Stats
 				for i, ov := range ti.Indexes {
 					results[i] = ov.Save()
+					if st := ti.collectStats(ts, i, results[i]); st != nil {
+						if stats == nil {
+							stats = make([]*index.Stats, len(ti.Indexes))
+						}
+						stats[i] = st
+					}
 				}
-				return PersistUpdate{table: ti.Table, results: results}
+				return PersistUpdate{table: ti.Table, results: results,
+					stats: stats}
 			})
 		}
 	})
 }
 
+// collectStats returns new stats for an index if they are stale, else nil
+func (ti *Info) collectStats(ts *Schema, i int, bt SaveResult) *index.Stats {
+	ix := &ts.Indexes[i]
+	if ix.Mode == 't' || !ti.IndexStats(i).Stale(ti.Nrows) {
+		return nil
+	}
+	fields := len(ix.Columns)
+	if len(ix.Ixspec.Fields) == 1 && len(ix.Ixspec.Fields2) == 0 &&
+		!ix.HasDesc() {
+		fields = 0 // not encoded
+	}
+	return index.CollectStats(bt, ti.Nrows, fields)
+}
+
 // ApplyPersist takes the new btree roots from Persist
 // and updates the state with them.
 func (m *Meta) ApplyPersist(updates []PersistUpdate) {
@@ -176,7 +336,19 @@ func (m *Meta) ApplyPersist(updates []PersistUpdate) {
 		for i, ov := range ti.Indexes {
 			if up.results[i] != nil {
 				ti.Indexes[i] = ov.WithSaved(up.results[i])
+				ti.Metrics.PersistBytes += up.results[i].Written()
+			}
+		}
+		ti.Metrics.Persists++
+		if up.stats != nil {
+			stats := make([]*index.Stats, len(ti.Indexes))
+			for i := range stats {
+				stats[i] = ti.IndexStats(i)
+				if up.stats[i] != nil {
+					stats[i] = up.stats[i]
+				}
 			}
+			ti.Stats = stats
 		}
 		t2.Put(&ti)
 	}
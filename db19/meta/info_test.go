@@ -24,6 +24,7 @@ func TestInfo(t *testing.T) {
 		Table: "two",
 		Nrows: 200,
 		Size:  2000,
+		Seq:   300,
 	}
 	tbl := InfoHamt{}.Mutable()
 	tbl.Put(one)
@@ -7,6 +7,7 @@ import (
 	"log"
 	"math"
 	"math/bits"
+	"strconv"
 
 	"github.com/apmckinlay/gsuneido/db19/index"
 	"github.com/apmckinlay/gsuneido/db19/index/btree"
@@ -203,7 +204,10 @@ func (m *Meta) Ensure(a *schema.Schema, store *stor.Stor) ([]schema.Index, *Meta
 		}
 	}
 	createColumns(ts, newCols)
+	createDefaults(ts, a.Defaults, newCols)
 	createIndexes(ts, ti, newIdxs, store)
+	createChecks(ts, a.Checks, true)
+	setLimits(ts, a)
 	ac := &schema.Schema{Table: a.Table, Indexes: newIdxs}
 	return newIdxs, m.PutNew(ts, ti, ac)
 }
@@ -218,6 +222,9 @@ func (m *Meta) RenameTable(from, to string) *Meta {
 	if tmp, ok := m.schema.Get(to); ok && !tmp.isTomb() {
 		panic("can't rename to existing table: " + to)
 	}
+	tsNew.Indexes = selfFkeys(ts.Indexes, from, to, func(cols []string) []string {
+		return cols
+	})
 	ti, ok := m.info.Get(from)
 	assert.That(ok && ti != nil)
 	tiNew := *ti // copy
@@ -230,6 +237,7 @@ func (m *Meta) RenameTable(from, to string) *Meta {
 	mu.putInfo(&tiNew)
 	m.dropFkeys(mu, &ts.Schema)
 	m.createFkeys(mu, &tsNew.Schema, &tsNew.Schema)
+	updateFkeysToHere(mu, &ts.Schema, &tsNew.Schema)
 	return mu.freeze()
 }
 
@@ -283,30 +291,122 @@ func (m *Meta) AlterRename(table string, from, to []string) *Meta {
 	if len(existing) > 0 {
 		panic("can't rename to existing column(s): " + strs.Join(", ", existing))
 	}
+	rename := func(cols []string) []string {
+		return renameCols(cols, from, to)
+	}
 	tsNew := *ts // copy
 	tsNew.Columns = strs.Replace(ts.Columns, from, to)
-	tsNew.Derived = strs.Replace(ts.Derived, from, to)
-	tsNew.Indexes = make([]schema.Index, len(ts.Indexes))
-	copy(tsNew.Indexes, ts.Indexes)
+	tsNew.Derived = rename(ts.Derived)
+	if ts.AutoNumber != "" {
+		tsNew.AutoNumber = rename([]string{ts.AutoNumber})[0]
+	}
+	if ts.Defaults != nil {
+		tsNew.Defaults = make(map[string]string, len(ts.Defaults))
+		for col, def := range ts.Defaults {
+			tsNew.Defaults[strs.Replace([]string{col}, from, to)[0]] = def
+		}
+	}
+	tsNew.Indexes = selfFkeys(ts.Indexes, table, table, rename)
 	for i := range tsNew.Indexes {
 		ix := &tsNew.Indexes[i]
-		ix.Columns = strs.Replace(ix.Columns, from, to)
+		ix.Columns = rename(ix.Columns)
 	}
 	// ixspecs are ok since they are field indexes, not names
 	mu := newMetaUpdate(m)
 	mu.putSchema(&tsNew)
 	m.dropFkeys(mu, &ts.Schema)
 	m.createFkeys(mu, &tsNew.Schema, &tsNew.Schema)
+	updateFkeysToHere(mu, &ts.Schema, &tsNew.Schema)
 	return mu.freeze()
 }
 
+// renameCols is like strs.Replace but also renames _lower! columns
+func renameCols(cols, from, to []string) []string {
+	if cols == nil {
+		return nil
+	}
+	lower := func(list []string) []string {
+		list2 := make([]string, len(list))
+		for i, s := range list {
+			list2[i] = s + "_lower!"
+		}
+		return list2
+	}
+	return strs.Replace(strs.Replace(cols, from, to), lower(from), lower(to))
+}
+
+// selfFkeys returns a copy of the indexes with foreign keys
+// from the table to itself renamed.
+// The self FkToHere entries are removed since createFkeys recreates them.
+func selfFkeys(idxs []schema.Index, from, to string,
+	rename func([]string) []string) []schema.Index {
+	idxs = append(idxs[:0:0], idxs...) // copy
+	for i := range idxs {
+		ix := &idxs[i]
+		if ix.Fk.Table == from {
+			ix.Fk.Table = to
+			ix.Fk.Columns = rename(ix.Fk.Columns)
+		}
+		fkToHere := make([]Fkey, 0, len(ix.FkToHere))
+		for _, fk := range ix.FkToHere {
+			if fk.Table != from {
+				fkToHere = append(fkToHere, fk)
+			}
+		}
+		ix.FkToHere = fkToHere
+	}
+	return idxs
+}
+
+// updateFkeysToHere updates the foreign keys in other tables
+// that point to a table that was renamed or had columns renamed.
+func updateFkeysToHere(mu *metaUpdate, ts, tsNew *schema.Schema) {
+	for i := range ts.Indexes {
+		cols := ts.Indexes[i].Columns
+		newCols := tsNew.Indexes[i].Columns
+		for _, fk := range ts.Indexes[i].FkToHere {
+			if fk.Table == ts.Table {
+				continue // handled by selfFkeys
+			}
+			other := mu.getSchema(fk.Table)
+			if other == nil {
+				continue
+			}
+			other.Indexes = append(other.Indexes[:0:0], other.Indexes...) // copy
+			for j := range other.Indexes {
+				ix := &other.Indexes[j]
+				if ix.Fk.Table == ts.Table && strs.Equal(ix.Columns, fk.Columns) {
+					ix.Fk.Table = tsNew.Table
+					if !strs.Equal(cols, newCols) {
+						ix.Fk.Columns = newCols
+					}
+				}
+			}
+			mu.putSchema(other)
+		}
+	}
+}
+
 func (m *Meta) AlterCreate(ac *schema.Schema, store *stor.Stor) *Meta {
 	ts, ti := m.alterGet(ac.Table)
 	createColumns(ts, ac.Columns)
+	createDefaults(ts, ac.Defaults, ac.Columns)
 	createIndexes(ts, ti, ac.Indexes, store)
+	createChecks(ts, ac.Checks, false)
+	setLimits(ts, ac)
 	return m.PutNew(ts, ti, ac)
 }
 
+// setLimits sets the table's MaxRows and MaxSize if they are specified
+func setLimits(ts *Schema, a *schema.Schema) {
+	if a.MaxRows > 0 {
+		ts.MaxRows = a.MaxRows
+	}
+	if a.MaxSize > 0 {
+		ts.MaxSize = a.MaxSize
+	}
+}
+
 // PutNew puts the schema & info and creates Fkeys
 func (m *Meta) PutNew(ts *Schema, ti *Info, ac *schema.Schema) *Meta {
 	mu := newMetaUpdate(m)
@@ -336,6 +436,109 @@ func createColumns(ts *Schema, cols []string) {
 	ts.Columns = append(strs.Cow(ts.Columns), cols...)
 }
 
+// createDefaults adds the defaults for new columns.
+// Existing columns are not changed.
+func createDefaults(ts *Schema, defaults map[string]string, cols []string) {
+	var defs map[string]string
+	for _, col := range cols {
+		if def, ok := defaults[col]; ok {
+			if defs == nil {
+				defs = copyDefaults(ts.Defaults)
+			}
+			defs[col] = def
+		}
+	}
+	if defs != nil {
+		ts.Defaults = defs
+	}
+}
+
+func copyDefaults(defaults map[string]string) map[string]string {
+	defs := make(map[string]string, len(defaults)+1)
+	for col, def := range defaults {
+		defs[col] = def
+	}
+	return defs
+}
+
+// NameChecks gives unnamed checks (from create) the first unused checkN name
+func (ts *Schema) NameChecks() {
+	checks := ts.Checks
+	ts.Checks = append([]schema.Check(nil), checks...) // copy on write
+	for i := range ts.Checks {
+		if ts.Checks[i].Name == "" {
+			ts.Checks[i].Name = checkName(ts.Checks)
+		}
+	}
+}
+
+// checkName returns the first checkN name that is not used in the lists
+func checkName(lists ...[]schema.Check) string {
+outer:
+	for n := 1; ; n++ {
+		name := "check" + strconv.Itoa(n)
+		for _, list := range lists {
+			if findCheck(list, name) != nil {
+				continue outer
+			}
+		}
+		return name
+	}
+}
+
+// createChecks adds check constraints.
+// Unnamed checks are given the first unused checkN name.
+// For ensure, existing checks with the same expression are ignored.
+func createChecks(ts *Schema, checks []schema.Check, ensure bool) {
+	if len(checks) == 0 {
+		return
+	}
+	list := append([]schema.Check(nil), ts.Checks...) // copy on write
+outer:
+	for _, c := range checks {
+		if ensure {
+			for _, x := range list {
+				if x.Expr == c.Expr {
+					continue outer
+				}
+			}
+		}
+		if c.Name == "" {
+			c.Name = checkName(list, checks)
+		} else if findCheck(list, c.Name) != nil {
+			panic("can't create existing check: " + c.Name)
+		}
+		list = append(list, c)
+	}
+	ts.Checks = list
+}
+
+func dropChecks(ts *Schema, checks []schema.Check) {
+	list := make([]schema.Check, 0, len(ts.Checks))
+	for _, c := range ts.Checks {
+		if findCheck(checks, c.Name) == nil {
+			list = append(list, c)
+		}
+	}
+	if len(ts.Checks)-len(list) != len(checks) {
+		for _, c := range checks {
+			if findCheck(ts.Checks, c.Name) == nil {
+				panic("can't drop nonexistent check: " + c.Name)
+			}
+		}
+	}
+	ts.Checks = list
+}
+
+func findCheck(checks []schema.Check, name string) *schema.Check {
+	for i := range checks {
+		if checks[i].Name == name {
+			return &checks[i]
+		}
+	}
+	return nil
+}
+
 func createIndexes(ts *Schema, ti *Info, idxs []schema.Index, store *stor.Stor) {
 	if len(idxs) == 0 {
 		return
@@ -452,6 +655,9 @@ func (m *Meta) AlterDrop(ad *schema.Schema) *Meta {
 			return nil
 		}
 	}
+	if len(ad.Checks) > 0 {
+		dropChecks(ts, ad.Checks)
+	}
 	mu := newMetaUpdate(m)
 	mu.putSchema(ts)
 	mu.putInfo(ti)
@@ -487,6 +693,7 @@ outer:
 	}
 	ts.Indexes = tsIdxs
 	ti.Indexes = tiIdxs
+	ti.Stats = nil // positions have changed
 }
 
 func dropColumns(ts *Schema, cols []string) bool {
@@ -502,6 +709,12 @@ func dropColumns(ts *Schema, cols []string) bool {
 	for _, col := range cols {
 		ts.Columns = strs.Replace1(ts.Columns, col, "-")
 	}
+	if ts.Defaults != nil {
+		ts.Defaults = copyDefaults(ts.Defaults)
+		for _, col := range cols {
+			delete(ts.Defaults, col)
+		}
+	}
 	return true
 }
 
@@ -577,11 +790,22 @@ func (m *Meta) TouchIndexes(table string) *Meta {
 // Also, the nrows and size deltas are applied.
 // Note: this does not merge the ixbuf's, that is done later by merge.
 // Nor does it save the changes to disk, that is done later by persist.
-func (m *Meta) LayeredOnto(latest *Meta) *Meta {
+//
+// Only the tables that were written (according to the checker) are layered,
+// since each layer must have a corresponding merge.
+// Other tables may have been modified by a write that failed part way,
+// e.g. blocked by a foreign key.
+// It returns the tables that were layered.
+func (m *Meta) LayeredOnto(latest *Meta, tables []string) (*Meta, []string) {
 	// start with a snapshot of the latest hash table because it may have more
 	assert.That(latest.difInfo.IsNil())
 	info := latest.info.Mutable()
+	layered := make([]string, 0, len(tables))
 	m.difInfo.ForEach(func(ti *Info) {
+		if !strs.Contains(tables, ti.Table) {
+			return
+		}
+		layered = append(layered, ti.Table)
 		lti, ok := info.Get(ti.Table)
 		if !ok || lti.isTomb() {
 			return
@@ -591,6 +815,10 @@ func (m *Meta) LayeredOnto(latest *Meta) *Meta {
 		ti.Size = lti.Size + (ti.Size - ti.origSize)
 		ti.origNrows = 0
 		ti.origSize = 0
+		if lti.Seq > ti.Seq {
+			ti.Seq = lti.Seq // reserved by a later transaction
+		}
+		ti.Metrics = lti.Metrics // updated by merge and persist
 		for i := range ti.Indexes {
 			ti.Indexes[i].UpdateWith(lti.Indexes[i])
 		}
@@ -599,11 +827,23 @@ func (m *Meta) LayeredOnto(latest *Meta) *Meta {
 	})
 	result := *latest // copy
 	result.info = info.Freeze()
-	return &result
+	return &result, layered
 }
 
 //-------------------------------------------------------------------
 
+// Write persists the schema and info that have changed
+// and returns the offsets of the new heads of the chains.
+//
+// Each persist writes a chunk with the items that changed
+// since the chunk it links to (the previous chunk, or an older one).
+// Like a binary counter, the clock determines how many of the newest chunks
+// are merged into the new one (see mergeSize),
+// so the chain stays short (at most about log2(delayMerge) + 1 chunks)
+// without rewriting everything on every persist.
+// When all the chunks are merged (or flatten is true)
+// a full base is written without tombstones and the chain restarts.
+// Superseded chunks are not referenced and are reclaimed by Compact.
 func (m *Meta) Write(store *stor.Stor, flatten bool) (offSchema, offInfo uint64) {
 	assert.That(m.difInfo.IsNil())
 
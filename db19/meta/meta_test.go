@@ -5,8 +5,11 @@ package meta
 
 import (
 	"math/rand"
+	"strconv"
 	"testing"
 
+	"github.com/apmckinlay/gsuneido/db19/index"
+	"github.com/apmckinlay/gsuneido/db19/index/ixkey"
 	"github.com/apmckinlay/gsuneido/db19/stor"
 	"github.com/apmckinlay/gsuneido/util/assert"
 	"github.com/apmckinlay/gsuneido/util/str"
@@ -28,12 +31,15 @@ func TestMeta(t *testing.T) {
 
 	for i := 0; i < 4; i++ {
 		m := meta.Mutable()
+		var tables []string
 		for i := 0; i < 5; i++ {
 			m.GetRoInfo(data[rand.Intn(100)])
-			m.GetRwInfo(data[rand.Intn(100)])
+			table := data[rand.Intn(100)]
+			m.GetRwInfo(table)
+			tables = append(tables, table)
 		}
 		// end of transaction, merge back to global
-		meta = m.LayeredOnto(meta)
+		meta, _ = m.LayeredOnto(meta, tables)
 	}
 
 	// persist state
@@ -46,6 +52,33 @@ func TestMeta(t *testing.T) {
 	assert.T(t).This(st.Size()).Is(size)
 }
 
+func TestWriteChain(t *testing.T) {
+	st := stor.HeapStor(8192)
+	tbl := InfoHamt{}.Mutable()
+	const ntables = 10
+	for i := 0; i < ntables; i++ {
+		tbl.Put(&Info{Table: strconv.Itoa(i), Indexes: []*index.Overlay{
+			index.NewOverlay(st, &ixkey.Spec{Fields: []int{0}})}})
+	}
+	meta := &Meta{info: tbl.Freeze()}
+	const npersists = 1000
+	for i := 0; i < npersists; i++ {
+		m := meta.Mutable()
+		table := strconv.Itoa(rand.Intn(ntables))
+		m.GetRwInfo(table).Nrows++
+		meta, _ = m.LayeredOnto(meta, []string{table})
+		_, off := meta.Write(st, false)
+		info, offs := ReadInfoChain(st, off)
+		assert.T(t).That(len(offs) <= 8)
+		nrows := 0
+		for j := 0; j < ntables; j++ {
+			ti, _ := info.Get(strconv.Itoa(j))
+			nrows += ti.Nrows
+		}
+		assert.T(t).This(nrows).Is(i + 1)
+	}
+}
+
 func TestMergeSize(t *testing.T) {
 	assert := assert.T(t)
 	test := func (clock, expected_npersists, expected_timespan int) {
@@ -4,8 +4,10 @@
 package meta
 
 import (
+	"strconv"
 	"strings"
 
+	"github.com/apmckinlay/gsuneido/db19/index/fulltext"
 	"github.com/apmckinlay/gsuneido/db19/meta/schema"
 	"github.com/apmckinlay/gsuneido/db19/stor"
 	"github.com/apmckinlay/gsuneido/util/assert"
@@ -47,6 +49,12 @@ func (ts *Schema) storSize() int {
 		idx := ts.Indexes[i]
 		size += 1 + stor.LenStrs(idx.Columns) +
 			stor.LenStr(idx.Fk.Table) + 1 + stor.LenStrs(idx.Fk.Columns)
+		if ext := ixExt(&idx); ext != nil {
+			size += stor.LenStrs(ext)
+		}
+	}
+	if ext := tblExt(&ts.Schema); ext != nil {
+		size += stor.LenStrs(ext)
 	}
 	return size
 }
@@ -55,10 +63,133 @@ func (ts *Schema) Write(w *stor.Writer) {
 	w.PutStr(ts.Table)
 	w.PutStrs(ts.Columns)
 	w.PutStrs(ts.Derived)
-	w.Put1(len(ts.Indexes))
-	for _, ix := range ts.Indexes {
-		w.Put1(ix.Mode).PutStrs(ix.Columns)
+	text := tblExt(&ts.Schema)
+	nidx := len(ts.Indexes)
+	if text != nil {
+		nidx |= tblExtFlag
+	}
+	w.Put1(nidx)
+	for i := range ts.Indexes {
+		ix := &ts.Indexes[i]
+		ext := ixExt(ix)
+		mode := ix.Mode
+		if ext != nil {
+			mode |= ixExtFlag
+		}
+		w.Put1(mode).PutStrs(ix.Columns)
 		w.PutStr(ix.Fk.Table).Put1(ix.Fk.Mode).PutStrs(ix.Fk.Columns)
+		if ext != nil {
+			w.PutStrs(ext)
+		}
+	}
+	if text != nil {
+		w.PutStrs(text)
+	}
+}
+
+// tblExtFlag is set on the stored index count
+// if the table has extended attributes following the indexes.
+const tblExtFlag = 0x80
+
+// tblExt returns the extended attributes of a table, or nil if none.
+// Like ixExt, each attribute is a string starting with a tag byte.
+//
+//	'c' - the data records are compressed
+//	'r' number - the maximum number of rows
+//	'z' number - the maximum data size
+//	'a' column - the column is auto numbered
+//	'k' name=expr - a check constraint
+//	'e' column=constant - a default value
+//	'h' - prior versions of the records are kept
+func tblExt(sc *schema.Schema) []string {
+	var ext []string
+	if sc.Compressed {
+		ext = append(ext, "c")
+	}
+	if sc.MaxRows > 0 {
+		ext = append(ext, "r"+strconv.Itoa(sc.MaxRows))
+	}
+	if sc.MaxSize > 0 {
+		ext = append(ext, "z"+strconv.FormatUint(sc.MaxSize, 10))
+	}
+	if sc.AutoNumber != "" {
+		ext = append(ext, "a"+sc.AutoNumber)
+	}
+	for _, c := range sc.Checks {
+		ext = append(ext, "k"+c.Name+"="+c.Expr)
+	}
+	if sc.History {
+		ext = append(ext, "h")
+	}
+	for _, col := range sc.Columns { // in order so it is deterministic
+		if def, ok := sc.Defaults[col]; ok {
+			ext = append(ext, "e"+col+"="+def)
+		}
+	}
+	return ext
+}
+
+func setTblExt(sc *schema.Schema, ext []string) {
+	for _, x := range ext {
+		switch x[0] {
+		case 'c':
+			sc.Compressed = true
+		case 'r':
+			sc.MaxRows, _ = strconv.Atoi(x[1:])
+		case 'z':
+			sc.MaxSize, _ = strconv.ParseUint(x[1:], 10, 64)
+		case 'a':
+			sc.AutoNumber = x[1:]
+		case 'k':
+			i := strings.IndexByte(x, '=')
+			sc.Checks = append(sc.Checks,
+				schema.Check{Name: x[1:i], Expr: x[i+1:]})
+		case 'h':
+			sc.History = true
+		case 'e':
+			i := strings.IndexByte(x, '=')
+			if sc.Defaults == nil {
+				sc.Defaults = make(map[string]string)
+			}
+			sc.Defaults[x[1:i]] = x[i+1:]
+		}
+	}
+}
+
+// ixExtFlag is set on the stored index mode
+// if the index has extended attributes following it.
+// Index modes are ascii so the flag can not occur in older databases.
+const ixExtFlag = 0x80
+
+// ixExt returns the extended attributes of an index, or nil if none.
+// Each attribute is a string starting with a tag byte.
+//
+//	'd' column - the column is descending
+//	's' column - the column is stored in the index entries
+func ixExt(ix *schema.Index) []string {
+	var ext []string
+	for i, col := range ix.Columns {
+		if ix.IsDesc(i) {
+			ext = append(ext, "d"+col)
+		}
+	}
+	for _, col := range ix.Storing {
+		ext = append(ext, "s"+col)
+	}
+	return ext
+}
+
+func setIxExt(ix *schema.Index, ext []string) {
+	for _, x := range ext {
+		switch x[0] {
+		case 'd':
+			if ix.Desc == nil {
+				ix.Desc = make([]bool, len(ix.Columns))
+			}
+			ix.Desc[strs.Index(ix.Columns, x[1:])] = true
+		case 's':
+			ix.Storing = append(ix.Storing, x[1:])
+		}
 	}
 }
 
@@ -67,10 +198,14 @@ func ReadSchema(_ *stor.Stor, r *stor.Reader) *Schema {
 	ts.Table = r.GetStr()
 	ts.Columns = r.GetStrs()
 	ts.Derived = r.GetStrs()
-	if n := r.Get1(); n > 0 {
+	n := r.Get1()
+	hasExt := n&tblExtFlag != 0
+	n &^= tblExtFlag
+	if n > 0 {
 		ts.Indexes = make([]schema.Index, n)
 		for i := 0; i < n; i++ {
-			ts.Indexes[i] = schema.Index{
+			ix := &ts.Indexes[i]
+			*ix = schema.Index{
 				Mode:    r.Get1(),
 				Columns: r.GetStrs(),
 				Fk: schema.Fkey{
@@ -78,9 +213,16 @@ func ReadSchema(_ *stor.Stor, r *stor.Reader) *Schema {
 					Mode:    r.Get1(),
 					Columns: r.GetStrs()},
 			}
+			if ix.Mode&ixExtFlag != 0 {
+				ix.Mode &^= ixExtFlag
+				setIxExt(ix, r.GetStrs())
+			}
 		}
 		ts.Ixspecs(ts.Indexes)
 	}
+	if hasExt {
+		setTblExt(&ts.Schema, r.GetStrs())
+	}
 	return &ts
 }
 
@@ -96,9 +238,17 @@ func (ts *Schema) Ixspecs(idxs []schema.Index) {
 			fallthrough
 		case 'k':
 			ix.Ixspec.Fields = ts.colsToFlds(ix.Columns)
+			ix.Ixspec.Desc = ix.Desc
 		case 'i':
+			// the key columns are added after the index columns
+			// followed by any stored columns
 			cols := sset.Union(ix.Columns, key)
+			cols = sset.Union(cols, ix.Storing)
 			ix.Ixspec.Fields = ts.colsToFlds(cols)
+			ix.Ixspec.Desc = ix.Desc
+		case 't':
+			// keys come from posting records, not data records
+			ix.Ixspec = fulltext.Spec
 		default:
 			panic("Ixspecs invalid mode")
 		}
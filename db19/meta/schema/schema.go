@@ -6,6 +6,7 @@ package schema
 
 import (
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/apmckinlay/gsuneido/db19/index/ixkey"
@@ -19,19 +20,64 @@ type Schema struct {
 	Columns []string
 	// Derived are the rules (capitalized) and _lower!
 	Derived []string
-	Indexes []Index
+	// Defaults are optional constants (source code) for Columns
+	// that outputs use if the field is empty
+	Defaults map[string]string
+	Indexes  []Index
+	// Compressed is set if the data records are stored compressed
+	Compressed bool
+	// MaxRows and MaxSize are optional limits (zero means no limit)
+	// enforced when update transactions commit
+	MaxRows int
+	MaxSize uint64
+	// AutoNumber is an optional column that outputs assign
+	// the next number in the table's sequence if it is empty
+	AutoNumber string
+	// Checks are constraints that outputs and updates must satisfy
+	Checks []Check
+	// History is set if the prior versions of the records are kept
+	// in the HistoryTable
+	History bool
 }
 
+// HistoryTable returns the name of the table that holds
+// the record versions of a table with History.
+// It has the columns of the table plus HistoryFrom and HistoryTo.
+func HistoryTable(table string) string {
+	return table + "_history"
+}
+
+// HistoryFrom and HistoryTo are the timestamps of the transactions
+// that created and replaced (or deleted) a version.
+// HistoryTo is empty for the current version.
+const (
+	HistoryFrom = "history_from"
+	HistoryTo   = "history_to"
+)
+
 type Index struct {
 	Columns []string
 	Ixspec  ixkey.Spec
-	// Mode is 'k' for key, 'i' for index, 'u' for unique index
+	// Mode is 'k' for key, 'i' for index, 'u' for unique index,
+	// 't' for full text index
 	Mode int
-	Fk   Fkey
+	// Desc specifies which Columns are descending, nil if all ascending
+	Desc []bool
+	// Storing are extra columns included in the index entries (Mode 'i')
+	// so queries only needing these columns do not need to read the data
+	Storing []string
+	Fk      Fkey
 	// FkToHere is other foreign keys that reference this index
 	FkToHere []Fkey // filled in by meta
 }
 
+// Check is a named constraint.
+// Expr is a query expression on the columns of the table.
+type Check struct {
+	Name string
+	Expr string
+}
+
 type Fkey struct {
 	Table   string
 	Columns []string
@@ -54,6 +100,9 @@ func (sc *Schema) String() string {
 	if sc.Columns != nil || sc.Derived != nil {
 		var cb str.CommaBuilder
 		for _, col := range sc.Columns {
+			if def, ok := sc.Defaults[col]; ok {
+				col += "=" + def
+			}
 			cb.Add(col)
 		}
 		for _, col := range sc.Derived {
@@ -69,12 +118,50 @@ func (sc *Schema) String() string {
 		sb.WriteString(sc.Indexes[i].String())
 		sep = " "
 	}
+	// options need a separator unless there are only columns
+	opt := func(s string) {
+		if !strings.HasSuffix(sb.String(), " ") {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(s)
+	}
+	if sc.Compressed {
+		opt("compressed")
+	}
+	if sc.MaxRows > 0 {
+		opt("maxrows(" + strconv.Itoa(sc.MaxRows) + ")")
+	}
+	if sc.MaxSize > 0 {
+		opt("maxsize(" + strconv.FormatUint(sc.MaxSize, 10) + ")")
+	}
+	if sc.AutoNumber != "" {
+		opt("autonumber(" + sc.AutoNumber + ")")
+	}
+	if sc.History {
+		opt("history")
+	}
+	for _, c := range sc.Checks {
+		if !strings.HasSuffix(sb.String(), " ") {
+			sb.WriteString(" ")
+		}
+		sb.WriteString("check")
+		if c.Name != "" {
+			sb.WriteString(" " + c.Name)
+		}
+		if c.Expr != "" { // alter drop only has the name
+			sb.WriteString("(" + c.Expr + ")")
+		}
+	}
 	return sb.String()
 }
 
 func (ix *Index) String() string {
-	s := map[int]string{'k': "key", 'i': "index", 'u': "index unique"}[ix.Mode]
-	s += strs.Join("(,)", ix.Columns)
+	s := map[int]string{'k': "key", 'i': "index", 'u': "index unique",
+		't': "index text"}[ix.Mode]
+	s += ix.columnsString()
+	if len(ix.Storing) > 0 {
+		s += " storing" + strs.Join("(,)", ix.Storing)
+	}
 	if ix.Fk.Table != "" {
 		s += " in " + ix.Fk.Table
 		if !strs.Equal(ix.Fk.Columns, ix.Columns) {
@@ -98,6 +185,45 @@ func (ix *Index) String() string {
 	return s
 }
 
+func (ix *Index) columnsString() string {
+	if !ix.HasDesc() {
+		return strs.Join("(,)", ix.Columns)
+	}
+	cols := make([]string, len(ix.Columns))
+	for i, col := range ix.Columns {
+		cols[i] = col
+		if ix.IsDesc(i) {
+			cols[i] += " desc"
+		}
+	}
+	return strs.Join("(,)", cols)
+}
+
+// IsDesc returns whether the i'th column of the index is descending
+func (ix *Index) IsDesc(i int) bool {
+	return i < len(ix.Desc) && ix.Desc[i]
+}
+
+// HasDesc returns whether any of the index columns are descending
+func (ix *Index) HasDesc() bool {
+	for i := range ix.Desc {
+		if ix.Desc[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// AllDesc returns whether all of the index columns are descending
+func (ix *Index) AllDesc() bool {
+	for i := range ix.Columns {
+		if !ix.IsDesc(i) {
+			return false
+		}
+	}
+	return len(ix.Columns) > 0
+}
+
 // FindIndex returns a pointer to the Index with the given columns
 // or else nil if not found
 func (sc *Schema) FindIndex(cols []string) *Index {
@@ -125,7 +251,18 @@ func (sc *Schema) IIndex(cols []string) int {
 func (ix *Index) Equal(iy *Index) bool {
 	return strs.Equal(ix.Columns, iy.Columns) &&
 		ix.Mode == iy.Mode &&
+		descEqual(ix, iy) &&
+		strs.Equal(ix.Storing, iy.Storing) &&
 		ix.Fk.Table == iy.Fk.Table &&
 		ix.Fk.Mode == iy.Fk.Mode &&
 		strs.Equal(ix.Fk.Columns, iy.Fk.Columns)
 }
+
+func descEqual(ix, iy *Index) bool {
+	for i := range ix.Columns {
+		if ix.IsDesc(i) != iy.IsDesc(i) {
+			return false
+		}
+	}
+	return true
+}
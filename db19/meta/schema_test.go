@@ -46,3 +46,38 @@ func TestSchema(t *testing.T) {
 		test(i, table, tbl.MustGet(table))
 	}
 }
+
+func TestSchemaIndexExt(t *testing.T) {
+	tbl := SchemaHamt{}.Mutable()
+	tbl.Put(&Schema{Schema: schema.Schema{
+		Table:   "tbl",
+		Columns: []string{"one", "two", "three", "four"},
+		Indexes: []schema.Index{
+			{Mode: 'k', Columns: []string{"one"}},
+			{Mode: 'i', Columns: []string{"two", "three"},
+				Desc: []bool{false, true}},
+			{Mode: 'i', Columns: []string{"three"},
+				Storing: []string{"four", "two"}},
+		},
+		Compressed: true,
+		MaxRows:    1000,
+		MaxSize:    50000,
+		AutoNumber: "one",
+		Checks:     []schema.Check{{Name: "pos", Expr: "one > 0"}},
+		Defaults:   map[string]string{"two": "123", "four": `"x"`},
+		History:    true,
+	}})
+	st := stor.HeapStor(8192)
+	st.Alloc(1) // avoid offset 0
+	off := tbl.Write(st, 0, allSchema)
+	tbl, _ = ReadSchemaChain(st, off)
+	ts := tbl.MustGet("tbl")
+	assert.T(t).This(ts.String()).
+		Is(`tbl (one,two=123,three,four="x") key(one) ` +
+			"index(two,three desc) " +
+			"index(three) storing(four,two) compressed " +
+			"maxrows(1000) maxsize(50000) autonumber(one) history check pos(one > 0)")
+	assert.T(t).This(ts.Indexes[0].Mode).Is('k')
+	assert.T(t).This(ts.Indexes[1].Ixspec.Desc).Is([]bool{false, true})
+	assert.T(t).This(ts.Indexes[2].Ixspec.Fields).Is([]int{2, 0, 3, 1})
+}
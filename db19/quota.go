@@ -0,0 +1,57 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"strconv"
+)
+
+// MaxDbSize is the maximum size of the database file.
+// Once the file is larger, commits that add rows or data are aborted.
+// Deletes are still allowed, the space is reclaimed by compaction.
+// Zero means no limit.
+var MaxDbSize uint64
+
+// QuotaExceeded is the prefix of the abort reason
+// for commits that exceed MaxDbSize or a table's MaxRows or MaxSize.
+// It lets applications distinguish this from conflicts.
+const QuotaExceeded = "quota exceeded: "
+
+// overQuota is called by Check.commit.
+// It returns an abort reason if committing would exceed a limit,
+// otherwise "".
+// The limits are checked against the latest state, which includes
+// the previous commits since commits are serialized by the checker.
+func (ck *Check) overQuota(ut *UpdateTran, tables []string) string {
+	if ck.db == nil || ut.meta == nil {
+		return ""
+	}
+	state := ck.db.GetState()
+	grows := false
+	for _, table := range tables {
+		ti := ut.meta.GetRoInfo(table)
+		ts := state.Meta.GetRoSchema(table)
+		lti := state.Meta.GetRoInfo(table)
+		if ti == nil || ts == nil || lti == nil {
+			continue // e.g. dropped, commit will ignore it
+		}
+		nrows, size := ti.Growth()
+		if nrows > 0 || size > 0 {
+			grows = true
+		}
+		if ts.MaxRows > 0 && nrows > 0 && lti.Nrows+nrows > ts.MaxRows {
+			return QuotaExceeded + table + " would have more than " +
+				strconv.Itoa(ts.MaxRows) + " rows"
+		}
+		if ts.MaxSize > 0 && size > 0 && lti.Size+uint64(size) > ts.MaxSize {
+			return QuotaExceeded + table + " would be larger than " +
+				strconv.FormatUint(ts.MaxSize, 10) + " bytes"
+		}
+	}
+	if grows && MaxDbSize > 0 && ck.db.Store.Size() > MaxDbSize {
+		return QuotaExceeded + "database file is larger than " +
+			strconv.FormatUint(MaxDbSize, 10) + " bytes"
+	}
+	return ""
+}
@@ -20,7 +20,7 @@ const dtfmt = "20060102.150405"
 func Repair(dbfile string, err error) error {
 	ec, _ := err.(*ErrCorrupt)
 	fmt.Println("repair:", err, ec.Table())
-	store, err := stor.MmapStor(dbfile, stor.READ)
+	store, err := openStor(dbfile, stor.READ)
 	if err != nil {
 		return err
 	}
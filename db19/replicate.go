@@ -0,0 +1,294 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/apmckinlay/gsuneido/db19/stor"
+)
+
+// Replication ships persist deltas from a primary to replicas.
+// Like incremental backup, it relies on the database file being append only.
+// After each persist the primary sends each replica the bytes
+// from the end of the previous state it sent to the end of the new state,
+// in the same format as a backup file.
+// The replica appends them to its own store (at the same offsets)
+// and switches to the new state. So replicas lag by up to a persist interval.
+//
+// A replica connects with the size of its database file, 0 for a new replica,
+// and its chunk size. The primary replies with its chunk size.
+// They must be the same since the replica reproduces the primary's layout.
+// It catches up with a delta from that point, or a full copy if it is new.
+// If the primary has been compacted, the replica's file is no longer valid,
+// the primary sends a full copy and the replica stops with ErrResync.
+//
+// Replicas are read-only, they do not have a checker
+// so NewUpdateTran returns nil.
+
+const replMagic = "gsrep002"
+
+// replHdrLen is the length of the header a replica sends,
+// the magic, its size, and its chunk size
+const replHdrLen = len(replMagic) + 8 + 8
+
+// ErrResync is returned by a Replica if its file is no longer valid
+// (e.g. because the primary was compacted).
+// The replica file must be removed and the replica restarted.
+var ErrResync = errors.New("replica: primary has changed, resync required")
+
+var errChunkSize = errors.New("replica: chunk size does not match the primary")
+
+// replicator is used by the primary to notify the replica senders
+type replicator struct {
+	lock sync.Mutex
+	// persisted is closed (and replaced) after each persist
+	persisted chan void
+	// stop is closed by Database.Close
+	stop chan void
+}
+
+func (r *replicator) init() {
+	if r.persisted == nil {
+		r.persisted = make(chan void)
+	}
+	if r.stop == nil {
+		r.stop = make(chan void)
+	}
+}
+
+func (r *replicator) wait() (persisted, stop chan void) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.init()
+	return r.persisted, r.stop
+}
+
+func (r *replicator) notify() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.persisted != nil {
+		close(r.persisted)
+		r.persisted = make(chan void)
+	}
+}
+
+func (r *replicator) close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.stop != nil {
+		close(r.stop)
+		r.persisted = nil
+	}
+}
+
+// ServeReplicas accepts replica connections until the listener is closed
+func (db *Database) ServeReplicas(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := db.serveReplica(conn); err != nil {
+				log.Println("replication:", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func (db *Database) serveReplica(conn net.Conn) error {
+	hdr := make([]byte, replHdrLen)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return err
+	}
+	if string(hdr[:len(replMagic)]) != replMagic {
+		return errors.New("bad replica connection")
+	}
+	from := binary.BigEndian.Uint64(hdr[len(replMagic):])
+	chunksize := db.Store.ChunkSize()
+	var reply [8]byte
+	binary.BigEndian.PutUint64(reply[:], chunksize)
+	if _, err := conn.Write(reply[:]); err != nil {
+		return err
+	}
+	if binary.BigEndian.Uint64(hdr[len(replMagic)+8:]) != chunksize {
+		return errChunkSize
+	}
+	var store *stor.Stor
+	for {
+		persisted, stop := db.repl.wait() // before GetState so we don't miss one
+		state := db.GetState()
+		if state.stateOff != 0 {
+			if state.store != store {
+				// first time, or compacted
+				if store != nil || !isStateEnd(state.store, from) {
+					from = 0
+				}
+				store = state.store
+			}
+			to := state.stateOff + uint64(stateLen)
+			if from < to {
+				if err := writeBackup(conn, store, from, to); err != nil {
+					return err
+				}
+				from = to
+			}
+		}
+		select {
+		case <-persisted:
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+//-------------------------------------------------------------------
+
+// Replica is a read-only copy of a primary database
+type Replica struct {
+	*Database
+	conn net.Conn
+	done chan void
+	// err is set when replication stops, it is valid after done is closed
+	err error
+}
+
+// StartReplica opens or creates a replica database file
+// and starts replicating from the primary at addr.
+// A new replica waits to receive the initial copy.
+func StartReplica(dbfile, addr string) (*Replica, error) {
+	var db *Database
+	store, err := openStor(dbfile, stor.UPDATE)
+	if os.IsNotExist(err) {
+		store, err = openStor(dbfile, stor.CREATE)
+	} else if err == nil && store.Size() > 0 {
+		db, err = OpenDbStor(store, stor.READ, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	hdr := make([]byte, replHdrLen)
+	copy(hdr, replMagic)
+	binary.BigEndian.PutUint64(hdr[len(replMagic):], store.Size())
+	binary.BigEndian.PutUint64(hdr[len(replMagic)+8:], store.ChunkSize())
+	if _, err = conn.Write(hdr); err != nil {
+		conn.Close()
+		store.Close()
+		return nil, err
+	}
+	r := &Replica{conn: conn, done: make(chan void)}
+	br := bufio.NewReader(conn)
+	var reply [8]byte
+	if _, err = io.ReadFull(br, reply[:]); err == nil &&
+		binary.BigEndian.Uint64(reply[:]) != store.ChunkSize() {
+		err = errChunkSize
+	}
+	if err != nil {
+		conn.Close()
+		store.Close()
+		return nil, err
+	}
+	if db == nil {
+		// new replica, wait for the initial copy
+		if err = r.receive(br, store); err != nil {
+			conn.Close()
+			store.Close()
+			return nil, err
+		}
+		db = &Database{Store: store, mode: stor.READ}
+		state, _ := ReadState(store, store.Size()-uint64(stateLen))
+		db.state.set(state)
+	}
+	r.Database = db
+	go r.run(br)
+	return r, nil
+}
+
+func (r *Replica) run(br *bufio.Reader) {
+	defer close(r.done)
+	for {
+		err := r.receive(br, r.Store)
+		if err != nil {
+			r.err = err
+			return
+		}
+		state, _ := ReadState(r.Store, r.Store.Size()-uint64(stateLen))
+		r.UpdateState(func(st *DbState) {
+			st.Meta = state.Meta
+			st.stateOff = state.stateOff
+		})
+	}
+}
+
+// receive appends one delta from the primary to the store
+func (r *Replica) receive(br *bufio.Reader, store *stor.Stor) error {
+	from, to, err := readBackupHdr(br)
+	if err != nil {
+		return err
+	}
+	if from != store.Size() {
+		return ErrResync
+	}
+	crc := uint32(0)
+	buf := make([]byte, 64*1024)
+	for n := to - from; n > 0; {
+		b := buf
+		if n < uint64(len(b)) {
+			b = b[:n]
+		}
+		if _, err := io.ReadFull(br, b); err != nil {
+			return err
+		}
+		crc = crc32.Update(crc, crcTable, b)
+		store.Extend(b)
+		n -= uint64(len(b))
+	}
+	if _, err := io.ReadFull(br, buf[:4]); err != nil {
+		return err
+	}
+	if binary.BigEndian.Uint32(buf[:4]) != crc {
+		return errors.New("replica: checksum error")
+	}
+	return nil
+}
+
+// Err returns the error that stopped replication, or nil if it is running
+func (r *Replica) Err() error {
+	select {
+	case <-r.done:
+		return r.err
+	default:
+		return nil
+	}
+}
+
+// Close stops replication and closes the replica database,
+// writing the size so it can be reopened and catch up.
+func (r *Replica) Close() {
+	if r.Store == nil {
+		return // already closed
+	}
+	r.conn.Close()
+	<-r.done
+	r.writeSize()
+	r.Database.Close()
+}
@@ -0,0 +1,89 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestReplicate(t *testing.T) {
+	assert := assert.T(t)
+	defer func() {
+		for _, f := range []string{"tmp.db", "tmp.db.bak", "tmp2.db"} {
+			os.Remove(f)
+		}
+	}()
+	db := createDb()
+	db.CheckerSync()
+	output := func(n int) {
+		for i := 0; i < n; i++ {
+			db.CommitMerge(output1(db))
+		}
+		db.persist(&execPersistSingle{}, false)
+	}
+	output(10)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	ck(err)
+	defer ln.Close()
+	go db.ServeReplicas(ln)
+	addr := ln.Addr().String()
+
+	waitFor := func(r *Replica, n int) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if r.NewReadTran().GetInfo("mytable").Nrows == n {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("replica did not get", n, "rows")
+	}
+	r, err := StartReplica("tmp2.db", addr)
+	ck(err)
+	assert.This(count(r.NewReadTran(), "mytable")).Is(10)
+	assert.This(r.NewUpdateTran()).Is(nil)
+	output(10)
+	waitFor(r, 20)
+	assert.This(count(r.NewReadTran(), "mytable")).Is(20)
+	r.Close()
+
+	// catch up
+	output(10)
+	r, err = StartReplica("tmp2.db", addr)
+	ck(err)
+	waitFor(r, 30)
+	assert.This(count(r.NewReadTran(), "mytable")).Is(30)
+	assert.This(r.Err()).Is(nil)
+
+	// compaction requires a resync
+	_, err = db.Compact()
+	ck(err)
+	for i := 0; i < 100 && r.Err() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.This(r.Err()).Is(ErrResync)
+	r.Close()
+
+	// the chunk size must match
+	conn, err := net.Dial("tcp", addr)
+	ck(err)
+	hdr := make([]byte, replHdrLen)
+	copy(hdr, replMagic)
+	binary.BigEndian.PutUint64(hdr[len(replMagic)+8:], 1024)
+	_, err = conn.Write(hdr)
+	ck(err)
+	reply, err := io.ReadAll(conn)
+	ck(err)
+	assert.This(len(reply)).Is(8) // then closed
+	assert.This(binary.BigEndian.Uint64(reply)).Is(db.Store.ChunkSize())
+	conn.Close()
+	db.Close()
+}
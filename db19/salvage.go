@@ -0,0 +1,288 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apmckinlay/gsuneido/db19/meta"
+	"github.com/apmckinlay/gsuneido/db19/meta/schema"
+	"github.com/apmckinlay/gsuneido/db19/stor"
+	rt "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/cksum"
+	"github.com/apmckinlay/gsuneido/util/sortlist"
+)
+
+// Salvage is an alternative to Repair.
+// Repair goes back to the last state that checks ok,
+// losing all the changes after it.
+// Salvage keeps the last readable state and repairs the damaged tables.
+//
+// Anything after the last readable state (a torn tail) is truncated.
+// For each table that fails checking, the readable records are collected
+// from all of the table's indexes, skipping damaged index nodes
+// and records with bad checksums, and the indexes are rebuilt from them.
+// If records conflict on a key (newest first),
+// the older ones are moved to a recovery table
+// (table_recovered) with an extra recovered_from column
+// containing their original offset.
+//
+// The result reports exactly what was lost.
+// Records that are not referenced by any readable index entry
+// are also lost but can only be counted.
+
+// SalvageReport describes the result of Salvage
+type SalvageReport struct {
+	// Truncated is the number of bytes after the last readable state
+	// that were removed.
+	Truncated uint64
+	// Tables are the tables that failed checking
+	Tables []*TableSalvage
+}
+
+// TableSalvage describes what happened to one damaged table
+type TableSalvage struct {
+	Table string
+	// Problem is the error from checking the table
+	Problem string
+	// Nrows is the number of rows in the table before salvage
+	Nrows int
+	// Kept is the number of rows in the table after salvage
+	Kept int
+	// Recovered is the number of rows moved to RecoveryTable
+	Recovered     int
+	RecoveryTable string
+	// BadNodes is the number of index nodes that could not be read
+	BadNodes int
+	// Lost are the offsets of referenced records that could not be read
+	Lost []uint64
+}
+
+// Missing returns the number of rows that were lost
+func (ts *TableSalvage) Missing() int {
+	if n := ts.Nrows - ts.Kept - ts.Recovered; n > 0 {
+		return n
+	}
+	return 0
+}
+
+func (r *SalvageReport) String() string {
+	var sb strings.Builder
+	if r.Truncated > 0 {
+		fmt.Fprintln(&sb, "truncated", r.Truncated, "bytes after last state")
+	}
+	if len(r.Tables) == 0 {
+		sb.WriteString("no damaged tables\n")
+	}
+	for _, ts := range r.Tables {
+		fmt.Fprintln(&sb, ts.Table+":", ts.Problem)
+		fmt.Fprintln(&sb, "   had", ts.Nrows, "rows, kept", ts.Kept,
+			"lost", ts.Missing())
+		if ts.Recovered > 0 {
+			fmt.Fprintln(&sb, "   moved", ts.Recovered, "rows to",
+				ts.RecoveryTable)
+		}
+		if ts.BadNodes > 0 {
+			fmt.Fprintln(&sb, "   skipped", ts.BadNodes, "damaged index nodes")
+		}
+		if len(ts.Lost) > 0 {
+			fmt.Fprintln(&sb, "   unreadable records at", ts.Lost)
+		}
+	}
+	return sb.String()
+}
+
+// Salvage repairs the damaged tables in a database file.
+// An existing database file is kept as .bak if it has to be truncated.
+func Salvage(dbfile string) (*SalvageReport, error) {
+	store, err := openStor(dbfile, stor.READ)
+	if err != nil {
+		return nil, err
+	}
+	off := store.Size()
+	for {
+		var state *DbState
+		off, state, _ = prevState(store, off)
+		if off == 0 {
+			store.Close()
+			return nil, errors.New("salvage failed - no valid states found")
+		}
+		if state != nil {
+			break
+		}
+	}
+	report := &SalvageReport{Truncated: store.Size() - (off + uint64(stateLen))}
+	if err := truncate(dbfile, store, off); err != nil {
+		return nil, err
+	}
+	db, err := OpenDb(dbfile, stor.UPDATE, false)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close() // persists the new state
+	state := db.GetState()
+	m := state.Meta
+	state.Meta.ForEachSchema(func(ts *meta.Schema) {
+		ec := checkTable1(state, ts.Table)
+		if ec == nil {
+			return
+		}
+		tr := &TableSalvage{Table: ts.Table, Problem: fmt.Sprint(ec.err)}
+		m = salvageTable(state.store, m, ts, tr)
+		report.Tables = append(report.Tables, tr)
+	})
+	db.UpdateState(func(state *DbState) {
+		state.Meta = m
+	})
+	return report, nil
+}
+
+func checkTable1(state *DbState, table string) (ec *ErrCorrupt) {
+	defer func() {
+		if e := recover(); e != nil {
+			ec = &ErrCorrupt{err: e, table: table}
+		}
+	}()
+	checkTable(state, table)
+	return nil
+}
+
+// salvageTable rebuilds a table from its readable records
+// and returns the updated meta
+func salvageTable(store *stor.Stor, m *meta.Meta, ts *meta.Schema,
+	tr *TableSalvage) *meta.Meta {
+	ti := m.GetRoInfo(ts.Table)
+	tr.Nrows = ti.Nrows
+	keep, dups := salvageRecords(store, ts, ti, tr)
+
+	list := sortlist.NewUnsorted()
+	size := uint64(0)
+	for _, off := range keep {
+		list.Add(off)
+		size += uint64(len(OffToRec(store, off)))
+	}
+	list.Finish()
+	tr.Kept = len(keep)
+	ti2 := &meta.Info{Table: ts.Table, Nrows: len(keep), Size: size,
+		Indexes: buildIndexes(store, ts, list), Stats: ti.Stats, Seq: ti.Seq}
+	tsCopy := *ts // Put sets lastmod
+	m = m.Put(&tsCopy, ti2)
+
+	if len(dups) > 0 {
+		m = recoveryTable(store, m, ts, dups, tr)
+	}
+	return m
+}
+
+// salvageRecords returns the offsets of the readable records
+// referenced by the indexes of a table.
+// keep are the newest records with unique keys,
+// dups are older records that conflict with them.
+// Unreadable records are added to tr.Lost
+func salvageRecords(store *stor.Stor, ts *meta.Schema, ti *meta.Info,
+	tr *TableSalvage) (keep, dups []uint64) {
+	offs := make(map[uint64]bool)
+	for i, ov := range ti.Indexes {
+		if ts.Indexes[i].Mode == 't' {
+			continue // entries are not data records
+		}
+		tr.BadNodes += ov.Salvage(func(off uint64) { offs[off] = true })
+	}
+	sorted := make([]uint64, 0, len(offs))
+	for off := range offs {
+		sorted = append(sorted, off)
+	}
+	// newest first, since the file is append only
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+	keys := make([]map[string]bool, len(ts.Indexes))
+	for i := range keys {
+		keys[i] = make(map[string]bool)
+	}
+	for _, off := range sorted {
+		rec, ok := salvageRecord(store, off)
+		if !ok {
+			tr.Lost = append(tr.Lost, off)
+		} else if addKeys(ts, keys, rec) {
+			keep = append(keep, off)
+		} else {
+			dups = append(dups, off)
+		}
+	}
+	sort.Slice(tr.Lost, func(i, j int) bool { return tr.Lost[i] < tr.Lost[j] })
+	return keep, dups
+}
+
+// salvageRecord returns the record at off if it is readable
+func salvageRecord(store *stor.Stor, off uint64) (rec rt.Record, ok bool) {
+	defer func() {
+		if e := recover(); e != nil {
+			ok = false
+		}
+	}()
+	if off >= store.Size() {
+		return "", false
+	}
+	buf := store.Data(off)
+	n := rt.RecLen(buf)
+	if !cksum.Check(buf[:n+cksum.Len]) {
+		return "", false
+	}
+	return bufToRec(buf[:n]), true
+}
+
+// addKeys returns false if the record conflicts with a previous record
+// on a key or unique index, otherwise it adds the record's keys.
+func addKeys(ts *meta.Schema, keys []map[string]bool, rec rt.Record) bool {
+	for i := range ts.Indexes {
+		ix := &ts.Indexes[i]
+		if (ix.Mode == 'k' || ix.Mode == 'u') && keys[i][ix.Ixspec.Key(rec)] {
+			return false
+		}
+	}
+	for i := range ts.Indexes {
+		if ix := &ts.Indexes[i]; ix.Mode == 'k' || ix.Mode == 'u' {
+			keys[i][ix.Ixspec.Key(rec)] = true
+		}
+	}
+	return true
+}
+
+// recoveryTable creates a table with the conflicting records
+func recoveryTable(store *stor.Stor, m *meta.Meta, ts *meta.Schema,
+	dups []uint64, tr *TableSalvage) *meta.Meta {
+	name := ts.Table + "_recovered"
+	for i := 2; m.GetRoSchema(name) != nil; i++ {
+		name = ts.Table + "_recovered" + strconv.Itoa(i)
+	}
+	sch := schema.Schema{Table: name,
+		Columns: append(ts.Columns[:len(ts.Columns):len(ts.Columns)],
+			"recovered_from"),
+		Indexes: []schema.Index{
+			{Mode: 'k', Columns: []string{"recovered_from"}}}}
+	rts := &meta.Schema{Schema: sch}
+	rts.Ixspecs(rts.Indexes)
+	list := sortlist.NewUnsorted()
+	size := uint64(0)
+	for _, off := range dups {
+		rec := OffToRec(store, off)
+		var b rt.RecordBuilder
+		for i := range ts.Columns {
+			b.AddRaw(rec.GetRaw(i))
+		}
+		b.Add(rt.IntVal(int(off)).(rt.Packable))
+		rec2 := b.Build()
+		list.Add(StoreRecord(store, rec2, false))
+		size += uint64(len(rec2))
+	}
+	list.Finish()
+	rti := &meta.Info{Table: name, Nrows: len(dups), Size: size,
+		Indexes: buildIndexes(store, rts, list)}
+	tr.Recovered = len(dups)
+	tr.RecoveryTable = name
+	return m.PutNew(rts, rti, &sch)
+}
@@ -0,0 +1,109 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/db19/index"
+	"github.com/apmckinlay/gsuneido/db19/meta"
+	"github.com/apmckinlay/gsuneido/db19/meta/schema"
+	"github.com/apmckinlay/gsuneido/db19/stor"
+	"github.com/apmckinlay/gsuneido/util/assert"
+	"github.com/apmckinlay/gsuneido/util/sortlist"
+)
+
+func TestSalvage(t *testing.T) {
+	assert := assert.T(t)
+	defer os.Remove("tmp.db")
+	defer os.Remove("tmp.db.bak")
+	db := createDb()
+	db.CheckerSync()
+	for i := 0; i < 100; i++ {
+		db.CommitMerge(output1(db))
+	}
+	db.persist(&execPersistSingle{}, false)
+	rt := db.NewReadTran()
+	iter := index.NewOverIter("mytable", 0)
+	iter.Next(rt)
+	iter.Next(rt)
+	_, off := iter.Cur()
+	db.Close()
+
+	// damage a record and add a torn tail
+	f, err := os.OpenFile("tmp.db", os.O_RDWR, 0)
+	ck(err)
+	buf := []byte{0}
+	_, err = f.ReadAt(buf, int64(off)+3)
+	ck(err)
+	buf[0] ^= 0xff
+	_, err = f.WriteAt(buf, int64(off)+3)
+	ck(err)
+	fi, err := f.Stat()
+	ck(err)
+	_, err = f.WriteAt(bytes.Repeat([]byte{0xff}, 100), fi.Size())
+	ck(err)
+	f.Close()
+	assert.This(CheckDatabase("tmp.db")).Isnt(nil)
+
+	report, err := Salvage("tmp.db")
+	ck(err)
+	assert.This(report.Truncated).Is(100)
+	assert.This(len(report.Tables)).Is(1)
+	tr := report.Tables[0]
+	assert.This(tr.Table).Is("mytable")
+	assert.This(tr.Nrows).Is(100)
+	assert.This(tr.Kept).Is(99)
+	assert.This(tr.Missing()).Is(1)
+	assert.This(tr.Lost).Is([]uint64{off})
+
+	ck(CheckDatabase("tmp.db"))
+	db, err = OpenDatabaseRead("tmp.db")
+	ck(err)
+	assert.This(db.NewReadTran().GetInfo("mytable").Nrows).Is(99)
+	assert.This(count(db.NewReadTran(), "mytable")).Is(99)
+	db.Close()
+}
+
+func TestSalvageRecords(t *testing.T) {
+	assert := assert.T(t)
+	store := stor.HeapStor(8192)
+	store.Alloc(1) // avoid offset 0
+	ts := &meta.Schema{Schema: schema.Schema{Table: "tbl",
+		Columns: []string{"one", "two"},
+		Indexes: []schema.Index{
+			{Mode: 'k', Columns: []string{"one"}},
+			{Mode: 'i', Columns: []string{"two"}}}}}
+	ts.Ixspecs(ts.Indexes)
+	off1 := StoreRecord(store, mkrec("1", "a"), false)
+	off2 := StoreRecord(store, mkrec("1", "b"), false) // newer version
+	off3 := StoreRecord(store, mkrec("2", "c"), false)
+	build := func(i int, offs ...uint64) *index.Overlay {
+		list := sortlist.NewUnsorted()
+		for _, off := range offs {
+			list.Add(off)
+		}
+		list.Finish()
+		ts1 := &meta.Schema{Schema: schema.Schema{Columns: ts.Columns,
+			Indexes: ts.Indexes[i : i+1]}}
+		return buildIndexes(store, ts1, list)[0]
+	}
+	ti := &meta.Info{Table: "tbl", Indexes: []*index.Overlay{
+		build(0, off2, off3), build(1, off1, off2, off3)}}
+	store.Data(off3)[3] ^= 0xff // damage
+
+	tr := &TableSalvage{}
+	keep, dups := salvageRecords(store, ts, ti, tr)
+	assert.This(keep).Is([]uint64{off2})
+	assert.This(dups).Is([]uint64{off1})
+	assert.This(tr.Lost).Is([]uint64{off3})
+
+	m := recoveryTable(store, (&meta.Meta{}), ts, dups, tr)
+	assert.This(tr.RecoveryTable).Is("tbl_recovered")
+	rts := m.GetRoSchema("tbl_recovered")
+	assert.This(rts.Columns).Is([]string{"one", "two", "recovered_from"})
+	assert.This(m.GetRoInfo("tbl_recovered").Nrows).Is(1)
+}
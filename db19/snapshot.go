@@ -0,0 +1,74 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// snapshots are named states pinned by Snapshot.
+// Since the state is immutable, read transactions started later
+// from a snapshot see the database as of when the snapshot was taken,
+// regardless of later updates (or compaction).
+type snapshots struct {
+	snapLock sync.Mutex
+	snaps    map[string]*DbState
+}
+
+// Snapshot pins the current state under a name
+// until it is released by ReleaseSnapshot.
+func (db *Database) Snapshot(name string) error {
+	if name == "" {
+		return errors.New("snapshot: name required")
+	}
+	db.snapLock.Lock()
+	defer db.snapLock.Unlock()
+	if _, ok := db.snaps[name]; ok {
+		return errors.New("snapshot: " + name + " already exists")
+	}
+	if db.snaps == nil {
+		db.snaps = make(map[string]*DbState)
+	}
+	db.snaps[name] = db.GetState()
+	return nil
+}
+
+// NewSnapshotTran returns a read transaction for a named snapshot,
+// or nil if there is no snapshot with that name.
+func (db *Database) NewSnapshotTran(name string) *ReadTran {
+	db.snapLock.Lock()
+	state := db.snaps[name]
+	db.snapLock.Unlock()
+	if state == nil {
+		return nil
+	}
+	return db.newReadTran(state)
+}
+
+// ReleaseSnapshot removes a named snapshot.
+// Transactions already started from it are not affected.
+// It returns false if there is no snapshot with that name.
+func (db *Database) ReleaseSnapshot(name string) bool {
+	db.snapLock.Lock()
+	defer db.snapLock.Unlock()
+	if _, ok := db.snaps[name]; !ok {
+		return false
+	}
+	delete(db.snaps, name)
+	return true
+}
+
+// Snapshots returns the names of the current snapshots, sorted
+func (db *Database) Snapshots() []string {
+	db.snapLock.Lock()
+	defer db.snapLock.Unlock()
+	names := make([]string, 0, len(db.snaps))
+	for name := range db.snaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
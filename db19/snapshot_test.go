@@ -0,0 +1,40 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/db19/stor"
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	assert := assert.T(t)
+	db, err := CreateDb(stor.HeapStor(8192))
+	ck(err)
+	db.CheckerSync()
+	createTbl(db)
+	assert.This(db.NewSnapshotTran("before")).Is(nil)
+	ck(db.Snapshot("before"))
+	assert.This(db.Snapshot("before")).Isnt(nil)
+	assert.This(db.Snapshot("")).Isnt(nil)
+	for i := 0; i < 10; i++ {
+		db.CommitMerge(output1(db))
+	}
+	ck(db.Snapshot("after"))
+	db.CommitMerge(output1(db))
+	assert.This(db.Snapshots()).Is([]string{"after", "before"})
+
+	assert.This(count(db.NewSnapshotTran("before"), "mytable")).Is(0)
+	rt := db.NewSnapshotTran("after")
+	assert.This(count(rt, "mytable")).Is(10)
+	assert.This(count(db.NewReadTran(), "mytable")).Is(11)
+
+	assert.That(db.ReleaseSnapshot("after"))
+	assert.That(!db.ReleaseSnapshot("after"))
+	assert.This(db.NewSnapshotTran("after")).Is(nil)
+	assert.This(count(rt, "mytable")).Is(10) // still usable
+	assert.This(db.Snapshots()).Is([]string{"before"})
+}
@@ -19,6 +19,9 @@ import (
 type DbState struct {
 	store *stor.Stor
 	Meta  *meta.Meta
+	// stateOff is the offset of the most recently written state,
+	// 0 if none has been written. It is used by Backup.
+	stateOff uint64
 }
 
 type stateHolder struct {
@@ -96,10 +99,11 @@ func (db *Database) Merge(metaWas *meta.Meta, fn mergefn, merges *mergeList) {
 // It merges synchronously after each commit.
 func (db *Database) CommitMerge(ut *UpdateTran) {
 	tables := db.ck.(*Check).commit(ut)
-	ut.commit()
+	tables = ut.commit(tables)
 	merges := &mergeList{}
 	merges.add(tables)
 	db.Merge(ut.meta, mergeSingle, merges)
+	db.wal.setMerged(ut.walSeq)
 }
 
 //-------------------------------------------------------------------
@@ -111,16 +115,23 @@ type persistfn func(*DbState) []meta.PersistUpdate
 // flatten applies to the schema and info chains.
 func (db *Database) persist(exec execPersist, flatten bool) *DbState {
 	// fmt.Println("persist", flatten)
+	db.persistLock.Lock()
+	defer db.persistLock.Unlock()
 	var newState *DbState
+	seq := db.wal.mergedSeq()
+	db.wal.prePersist(seq)
 	db.GetState().Meta.Persist(exec.Submit) // outside UpdateState
 	updates := exec.Results()
 	db.UpdateState(func(state *DbState) {
 		meta := *state.Meta // copy
 		meta.ApplyPersist(updates)
 		state.Meta = &meta
-		state.Write(flatten)
+		state.stateOff = state.Write(flatten)
 		newState = state
 	})
+	newState.store.Flush() // before the state is treated as durable
+	db.wal.checkpoint(newState.stateOff, seq, false)
+	db.repl.notify()
 	return newState
 }
 
@@ -158,7 +169,8 @@ func writeState(store *stor.Stor, offSchema, offInfo uint64) uint64 {
 
 func ReadState(st *stor.Stor, off uint64) (*DbState, time.Time) {
 	offSchema, offInfo, t := readState(st, off)
-	return &DbState{store: st, Meta: meta.ReadMeta(st, offSchema, offInfo)}, t
+	return &DbState{store: st, Meta: meta.ReadMeta(st, offSchema, offInfo),
+		stateOff: off}, t
 }
 
 func readState(st *stor.Stor, off uint64) (offSchema, offInfo uint64, t time.Time) {
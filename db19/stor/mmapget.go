@@ -17,7 +17,7 @@ func (ms *mmapStor) Get(chunk int) []byte {
 	prot := syscall.PROT_READ
 	if ms.mode != READ {
 		prot |= syscall.PROT_WRITE
-		ms.file.Truncate(int64(chunk+1) * MMAP_CHUNKSIZE) // extend file
+		ms.extend(int64(chunk+1) * MMAP_CHUNKSIZE)
 	}
 	mmap, err := syscall.Mmap(int(ms.file.Fd()),
 		int64(chunk)*MMAP_CHUNKSIZE, MMAP_CHUNKSIZE,
@@ -15,7 +15,9 @@ func (ms *mmapStor) Get(chunk int) []byte {
 		prot = syscall.PAGE_READONLY
 	}
 	end := int64((chunk + 1) * MMAP_CHUNKSIZE)
-	if ms.mode == READ {
+	if ms.mode != READ {
+		ms.extend(end)
+	} else {
 		fi, err := ms.file.Stat()
 		if err != nil {
 			panic(err)
@@ -17,10 +17,34 @@ type mmapStor struct {
 	file *os.File
 	mode Mode
 	ptrs []uintptr // needed on windows
+	// fileSize is the current length of the file, it may be ahead of Stor size
+	fileSize int64
+	// grow is the number of chunks to extend the file by next time
+	grow int
 }
 
+// MMAP_CHUNKSIZE is the allocation chunk size.
+// It is not configurable because allocations may not straddle chunks
+// so it determines the layout of the file.
 const MMAP_CHUNKSIZE = 64 * 1024 * 1024 // 64 mb
 
+// GrowPolicy controls how updateable memory mapped files are extended.
+type GrowPolicy struct {
+	// MaxChunks is the maximum number of chunks to extend the file by.
+	// The file is extended by one chunk at first,
+	// doubling each time it is extended, up to MaxChunks.
+	// Close truncates any unused space.
+	MaxChunks int
+	// Prealloc allocates the disk space for the extension
+	// (with fallocate where supported) to reduce fragmentation.
+	// Otherwise the file is extended sparsely.
+	Prealloc bool
+}
+
+// Grow is the policy for extending files, it may be changed before opening.
+// It is set from the -grow command line option.
+var Grow = GrowPolicy{MaxChunks: 4}
+
 // MmapStor returns a memory mapped file stor.
 func MmapStor(filename string, mode Mode) (*Stor, error) {
 	var perm os.FileMode
@@ -41,7 +65,7 @@ func MmapStor(filename string, mode Mode) (*Stor, error) {
 	}
 	size := fi.Size()
 	nchunks := int(((size + MMAP_CHUNKSIZE - 1) / MMAP_CHUNKSIZE))
-	impl := &mmapStor{file, mode, nil}
+	impl := &mmapStor{file: file, mode: mode, fileSize: size, grow: 1}
 	chunks := make([][]byte, nchunks)
 
 	for i := 0; i < nchunks; i++ {
@@ -59,14 +83,17 @@ func MmapStor(filename string, mode Mode) (*Stor, error) {
 			chunks[last] = chunks[last][:remainder] // last chunk not full
 		}
 	}
-	// trim trailing zero bytes (from memory mapping)
-	if size > 0 {
+	// trim trailing zero bytes (from memory mapping and growing)
+	for ; size > 0 && last >= 0; last-- {
 		buf := chunks[last]
 		r := (size - 1) % MMAP_CHUNKSIZE
 		b := r
 		for ; b >= 0 && buf[b] == 0; b-- {
 		}
 		size -= int64(r - b)
+		if b >= 0 {
+			break
+		}
 	}
 
 	ms := NewStor(impl, MMAP_CHUNKSIZE, uint64(size))
@@ -78,3 +105,24 @@ func MmapStor(filename string, mode Mode) (*Stor, error) {
 func (ms *mmapStor) Write(off uint64, data []byte) {
 	ms.file.WriteAt(data, int64(off))
 }
+
+// extend ensures the file is at least end bytes long,
+// growing it according to the Grow policy.
+// It is called by Get, with Stor.lock held.
+func (ms *mmapStor) extend(end int64) {
+	if end <= ms.fileSize {
+		return
+	}
+	newSize := end + int64(ms.grow-1)*MMAP_CHUNKSIZE
+	if !Grow.Prealloc ||
+		preallocate(ms.file, ms.fileSize, newSize-ms.fileSize) != nil {
+		ms.file.Truncate(newSize) // sparse
+	}
+	ms.fileSize = newSize
+	if ms.grow *= 2; ms.grow > Grow.MaxChunks {
+		ms.grow = Grow.MaxChunks
+	}
+	if ms.grow < 1 {
+		ms.grow = 1
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package stor
+
+import (
+	"io"
+	"os"
+	"sort"
+	"sync/atomic"
+)
+
+// PreadCacheChunks is the maximum number of chunks
+// that a PreadStor keeps in memory (plus the pinned last two).
+// Each chunk is MMAP_CHUNKSIZE (64mb).
+// It is set from options.PreadCache.
+var PreadCacheChunks = 8
+
+// preadStor accesses a file with ReadAt and WriteAt
+// instead of memory mapping it, so the file size is not limited
+// by the address space (e.g. 32 bit builds).
+// Chunks are read on demand and the least recently used are dropped.
+// It uses the same chunk size as mmapStor so the files are interchangeable.
+//
+// Data is only appended, so chunks entirely before the original size
+// are never modified and can be dropped without writing.
+// Later chunks are written when they are dropped, by Flush, and on Close.
+type preadStor struct {
+	file      *os.File
+	mode      Mode
+	chunksize int64
+	// dirty is the first chunk that may have been modified
+	dirty int
+	// flushed is where the next Flush starts.
+	// It lags one Flush behind because allocations
+	// may not have been filled in when they were written.
+	flushed, prevFlushed uint64
+	// clock and used implement least recently used.
+	// used is replaced (under Stor.lock) when it needs to grow.
+	clock uint64
+	used  atomic.Value // []uint64
+	// loaded is the set of chunks in memory, it is guarded by Stor.lock
+	loaded map[int]bool
+}
+
+// PreadStor returns a file stor that uses ReadAt/WriteAt
+// and a limited cache of chunks.
+func PreadStor(filename string, mode Mode) (*Stor, error) {
+	return preadStorSize(filename, mode, MMAP_CHUNKSIZE)
+}
+
+// preadStorSize allows a smaller chunk size for tests
+func preadStorSize(filename string, mode Mode, chunksize int64) (*Stor, error) {
+	var perm os.FileMode
+	flags := os.O_RDONLY
+	if mode == UPDATE {
+		flags = os.O_RDWR
+	} else if mode == CREATE {
+		perm = 0666
+		flags = os.O_CREATE | os.O_TRUNC | os.O_RDWR
+	}
+	file, err := os.OpenFile(filename, flags, perm)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	nchunks := int(((size + chunksize - 1) / chunksize))
+	impl := &preadStor{file: file, mode: mode, chunksize: chunksize,
+		dirty: int(size / chunksize), loaded: make(map[int]bool),
+		flushed: uint64(size), prevFlushed: uint64(size)}
+	impl.used.Store([]uint64{})
+	ps := NewStor(impl, uint64(chunksize), uint64(size))
+	if nchunks == 0 {
+		ps.chunks.Store([][]byte{impl.Get(0)})
+		return ps, nil
+	}
+	ps.chunks.Store(make([][]byte, nchunks))
+	// trim trailing zero bytes (e.g. from a crash while using mmapStor)
+	last := nchunks - 1
+	buf := ps.chunk(last)
+	r := (size - 1) % chunksize
+	b := r
+	for ; b >= 0 && buf[b] == 0; b-- {
+	}
+	ps.size = uint64(size - (r - b))
+	return ps, nil
+}
+
+// Get reads a chunk from the file.
+// In READ mode the last chunk is only as long as the file.
+func (ps *preadStor) Get(chunk int) []byte {
+	off := int64(chunk) * ps.chunksize
+	n := ps.chunksize
+	if ps.mode == READ {
+		if fi, err := ps.file.Stat(); err == nil && fi.Size()-off < n {
+			n = fi.Size() - off
+		}
+	}
+	buf := make([]byte, n)
+	if _, err := ps.file.ReadAt(buf, off); err != nil && err != io.EOF {
+		panic(err)
+	}
+	ps.loaded[chunk] = true
+	used := ps.used.Load().([]uint64)
+	if chunk >= len(used) {
+		used = append(used[:len(used):len(used)],
+			make([]uint64, chunk+1-len(used))...)
+		ps.used.Store(used)
+	}
+	return buf
+}
+
+func (ps *preadStor) Used(chunk int) {
+	if used := ps.used.Load().([]uint64); chunk < len(used) {
+		atomic.StoreUint64(&used[chunk], atomic.AddUint64(&ps.clock, 1))
+	}
+}
+
+func (ps *preadStor) Evict(pinned int) []int {
+	if len(ps.loaded) <= PreadCacheChunks {
+		return nil
+	}
+	used := ps.used.Load().([]uint64)
+	list := make([]int, 0, len(ps.loaded))
+	for i := range ps.loaded {
+		if i < pinned {
+			list = append(list, i)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return atomic.LoadUint64(&used[list[i]]) <
+			atomic.LoadUint64(&used[list[j]])
+	})
+	n := len(ps.loaded) - PreadCacheChunks
+	if n > len(list) {
+		n = len(list)
+	}
+	return list[:n]
+}
+
+func (ps *preadStor) Unload(chunk int, data []byte, size uint64) {
+	delete(ps.loaded, chunk)
+	if ps.mode == READ || chunk < ps.dirty {
+		return
+	}
+	off := uint64(chunk) * uint64(ps.chunksize)
+	if off >= size {
+		return
+	}
+	if n := size - off; n < uint64(len(data)) {
+		data = data[:n]
+	}
+	if _, err := ps.file.WriteAt(data, int64(off)); err != nil {
+		panic(err)
+	}
+}
+
+// Flush writes the loaded data from flushed to size and syncs the file.
+// Unloaded chunks were written by Unload.
+func (ps *preadStor) Flush(chunks [][]byte, size uint64) {
+	if ps.mode == READ {
+		return
+	}
+	cs := uint64(ps.chunksize)
+	for off := ps.flushed; off < size; {
+		chunk := int(off / cs)
+		org := uint64(chunk) * cs
+		end := org + cs
+		if end > size {
+			end = size
+		}
+		if chunk < len(chunks) && chunks[chunk] != nil {
+			if _, err := ps.file.WriteAt(chunks[chunk][off-org:end-org],
+				int64(off)); err != nil {
+				panic(err)
+			}
+		}
+		off = end
+	}
+	if err := ps.file.Sync(); err != nil {
+		panic(err)
+	}
+	ps.flushed, ps.prevFlushed = ps.prevFlushed, size
+	if ps.flushed > size {
+		ps.flushed = size
+	}
+}
+
+// Write writes directly to the file
+func (ps *preadStor) Write(off uint64, data []byte) {
+	ps.file.WriteAt(data, int64(off))
+}
+
+func (ps *preadStor) Close(size int64) {
+	if ps.mode != READ {
+		ps.file.Truncate(size)
+	}
+	ps.file.Close()
+}
@@ -0,0 +1,15 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package stor
+
+import (
+	"os"
+	"syscall"
+)
+
+// preallocate allocates disk space for the file from off for n bytes,
+// extending the file size.
+func preallocate(f *os.File, off, n int64) error {
+	return syscall.Fallocate(int(f.Fd()), 0, off, n)
+}
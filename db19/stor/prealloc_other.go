@@ -0,0 +1,17 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package stor
+
+import (
+	"errors"
+	"os"
+)
+
+// preallocate is not supported, the caller extends the file instead
+func preallocate(*os.File, int64, int64) error {
+	return errors.New("preallocate not supported")
+}
@@ -3,7 +3,8 @@
 
 /*
 Package stor is used to access physical storage,
-normally by memory mapped file access.
+normally by memory mapped file access,
+or by file reads and writes with a limited cache of chunks (see PreadStor).
 
 Storage is chunked. Allocations may not straddle chunks.
 */
@@ -16,6 +17,7 @@ import (
 	"sync/atomic"
 
 	"github.com/apmckinlay/gsuneido/util/assert"
+	"github.com/apmckinlay/gsuneido/util/ints"
 )
 
 // Offset is an offset within storage
@@ -31,6 +33,25 @@ type storage interface {
 	Close(size int64)
 }
 
+// pager is implemented by storage that does not keep all of the chunks
+// in memory. Stor loads chunks on demand and asks the pager which to drop.
+// Load, Evict, and Unload are called with Stor.lock held.
+type pager interface {
+	storage
+	// Used records an access to a chunk, for least recently used eviction.
+	// It is called concurrently.
+	Used(chunk int)
+	// Evict returns the loaded chunks to drop to stay within the cache limit,
+	// excluding pinned and later chunks which may still be written
+	Evict(pinned int) []int
+	// Unload is called before a chunk is dropped (or on Close)
+	// so modified data can be written. size is the Stor size.
+	Unload(chunk int, data []byte, size uint64)
+	// Flush writes the modified data in the loaded chunks
+	// and syncs the file. chunks may contain nil for unloaded chunks.
+	Flush(chunks [][]byte, size uint64)
+}
+
 // Stor is the externally visible storage
 type Stor struct {
 	impl storage
@@ -47,14 +68,20 @@ type Stor struct {
 	// with at least one chunk if size is 0
 	chunks atomic.Value // [][]byte
 	lock   sync.Mutex
+	// pager is set if impl is a pager, then chunks may contain nil
+	pager pager
+	// verified has a bitmap per chunk of the offsets that have been verified,
+	// see Verified and SetVerified. It is copy on write, guarded by lock.
+	verified atomic.Value // [][]uint32
 }
 
 func NewStor(impl storage, chunksize uint64, size uint64) *Stor {
 	shift := bits.TrailingZeros(uint(chunksize))
 	assert.That(1<<shift == chunksize) // chunksize must be power of 2
 	threshold := chunksize * 3 / 4     // ???
+	pg, _ := impl.(pager)
 	return &Stor{impl: impl, chunksize: chunksize, threshold: threshold,
-		shift: shift, size: size}
+		shift: shift, size: size, pager: pg}
 }
 
 // Alloc allocates n bytes of storage and returns its Offset and byte slice
@@ -97,6 +124,9 @@ func (s *Stor) getChunk(chunk int) {
 	if chunk >= len(chunks) {
 		// no one else beat us to it
 		chunks = append(chunks, s.impl.Get(chunk))
+		if s.pager != nil {
+			chunks = s.evict(chunks)
+		}
 		s.chunks.Store(chunks)
 	}
 	s.lock.Unlock()
@@ -106,12 +136,59 @@ func (s *Stor) getChunk(chunk int) {
 // and extending to the end of the chunk
 // since we don't know the size of the original alloc.
 func (s *Stor) Data(offset Offset) []byte {
+	chunk := s.offsetToChunk(offset)
+	return s.chunk(chunk)[offset&(s.chunksize-1):]
+}
+
+func (s *Stor) chunk(i int) []byte {
 	// The existing chunks must be mapped initially
 	// since lazily mapping would require locking.
-	chunk := s.offsetToChunk(offset)
+	// Except for a pager, where locking is unavoidable.
+	chunks := s.chunks.Load().([][]byte)
+	c := chunks[i]
+	if s.pager != nil {
+		if c == nil {
+			c = s.load(i)
+		}
+		s.pager.Used(i)
+	}
+	return c
+}
+
+// load reads a chunk for a pager
+func (s *Stor) load(i int) []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	chunks := s.chunks.Load().([][]byte)
-	c := chunks[chunk]
-	return c[offset&(s.chunksize-1):]
+	if c := chunks[i]; c != nil {
+		return c // another thread beat us to it
+	}
+	c := s.impl.Get(i)
+	chunks = append(chunks[:0:0], chunks...) // copy, readers may have old
+	chunks[i] = c
+	s.chunks.Store(s.evict(chunks))
+	return c
+}
+
+// evict drops chunks as requested by the pager.
+// The last two chunks are pinned since they may still be written.
+// It must be called with the lock held.
+func (s *Stor) evict(chunks [][]byte) [][]byte {
+	pinned := s.offsetToChunk(atomic.LoadUint64(&s.size)) - 1
+	copied := false
+	for _, i := range s.pager.Evict(pinned) {
+		if i >= len(chunks) || chunks[i] == nil {
+			continue
+		}
+		if !copied {
+			chunks = append(chunks[:0:0], chunks...)
+			copied = true
+		}
+		s.pager.Unload(i, chunks[i], s.Size())
+		chunks[i] = nil // readers may still reference it, gc will handle
+		s.unverify(i)
+	}
+	return chunks
 }
 
 func (s *Stor) offsetToChunk(offset Offset) int {
@@ -132,11 +209,10 @@ func (s *Stor) Size() uint64 {
 // and returns the offset, or 0 if not found
 func (s *Stor) LastOffset(off uint64, str string) uint64 {
 	b := []byte(str)
-	chunks := s.chunks.Load().([][]byte)
 	c := s.offsetToChunk(off)
 	n := off & (s.chunksize - 1)
 	for ; c >= 0; c-- {
-		buf := chunks[c][:n]
+		buf := s.chunk(c)[:n]
 		if i := bytes.LastIndex(buf, b); i != -1 {
 			return uint64(c)*s.chunksize + uint64(i)
 		}
@@ -145,6 +221,30 @@ func (s *Stor) LastOffset(off uint64, str string) uint64 {
 	return 0
 }
 
+// ChunkSize returns the size of the chunks, see Extend
+func (s *Stor) ChunkSize() uint64 {
+	return s.chunksize
+}
+
+// Extend appends data at the end of the storage.
+// Unlike Alloc, the data may cross chunk boundaries.
+// It is used for replication, to reproduce the contents of another store
+// at the same offsets. The other store must have the same chunk size
+// because allocations do not straddle chunks, so the layout depends on it.
+// It must not be used concurrently with Alloc.
+func (s *Stor) Extend(data []byte) {
+	for len(data) > 0 {
+		off := atomic.LoadUint64(&s.size)
+		chunk := s.offsetToChunk(off)
+		if chunk >= len(s.chunks.Load().([][]byte)) {
+			s.getChunk(chunk)
+		}
+		n := copy(s.Data(off), data)
+		atomic.AddUint64(&s.size, uint64(n))
+		data = data[n:]
+	}
+}
+
 type writable interface {
 	Write(off uint64, data []byte)
 }
@@ -152,11 +252,97 @@ type writable interface {
 func (s *Stor) Write(off uint64, data []byte) {
 	if w, ok := s.impl.(writable); ok {
 		w.Write(off, data)
+		if s.pager != nil {
+			copy(s.Data(off), data) // keep the cached chunk the same
+		}
 	} else {
 		copy(s.Data(off), data)
 	}
 }
 
+// Flush makes the data durable (for a pager) e.g. before a persist.
+// Memory mapped data is written by the operating system.
+func (s *Stor) Flush() {
+	if s.pager != nil {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.pager.Flush(s.chunks.Load().([][]byte), s.Size())
+	}
+}
+
+// verifyUnit is the granularity of Verified and SetVerified.
+// The verified data (e.g. btree nodes) must be at least this size.
+const verifyUnit = 4
+
+// Verified returns whether SetVerified has been called for the offset.
+// It is used to only verify immutable data (e.g. btree nodes) once.
+func (s *Stor) Verified(off Offset) bool {
+	chunk := s.offsetToChunk(off)
+	vs, _ := s.verified.Load().([][]uint32)
+	if chunk >= len(vs) || vs[chunk] == nil {
+		return false
+	}
+	i := (off & (s.chunksize - 1)) / verifyUnit
+	return atomic.LoadUint32(&vs[chunk][i/32])&(1<<(i%32)) != 0
+}
+
+// SetVerified records that the data at the offset has been verified.
+// It uses a bitmap per chunk (one bit per verifyUnit bytes)
+// so memory is bounded by the size of the data.
+// For a pager, a chunk's bitmap is dropped when the chunk is unloaded
+// so the data is verified again when it is read back in.
+func (s *Stor) SetVerified(off Offset) {
+	chunk := s.offsetToChunk(off)
+	vs, _ := s.verified.Load().([][]uint32)
+	if chunk >= len(vs) || vs[chunk] == nil {
+		vs = s.addVerified(chunk)
+	}
+	i := (off & (s.chunksize - 1)) / verifyUnit
+	p := &vs[chunk][i/32]
+	bit := uint32(1) << (i % 32)
+	for {
+		x := atomic.LoadUint32(p)
+		if x&bit != 0 || atomic.CompareAndSwapUint32(p, x, x|bit) {
+			return
+		}
+	}
+}
+
+// addVerified adds the verified bitmap for a chunk
+func (s *Stor) addVerified(chunk int) [][]uint32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	vs, _ := s.verified.Load().([][]uint32)
+	if chunk < len(vs) && vs[chunk] != nil {
+		return vs // another thread beat us to it
+	}
+	vs2 := make([][]uint32, ints.Max(len(vs), chunk+1))
+	copy(vs2, vs)
+	vs2[chunk] = make([]uint32, (s.chunksize/verifyUnit+31)/32)
+	s.verified.Store(vs2)
+	return vs2
+}
+
+// unverify drops the verified bitmap for a chunk.
+// It must be called with the lock held.
+func (s *Stor) unverify(chunk int) {
+	vs, _ := s.verified.Load().([][]uint32)
+	if chunk < len(vs) && vs[chunk] != nil {
+		vs = append(vs[:0:0], vs...) // copy, readers may have old
+		vs[chunk] = nil
+		s.verified.Store(vs)
+	}
+}
+
 func (s *Stor) Close() {
+	if s.pager != nil {
+		s.lock.Lock()
+		for i, c := range s.chunks.Load().([][]byte) {
+			if c != nil {
+				s.pager.Unload(i, c, s.Size())
+			}
+		}
+		s.lock.Unlock()
+	}
 	s.impl.Close(int64(s.size))
 }
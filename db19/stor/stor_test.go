@@ -4,6 +4,7 @@
 package stor
 
 import (
+	"bytes"
 	"math/rand"
 	"os"
 	"sync"
@@ -37,6 +38,20 @@ func TestData(t *testing.T) {
 	}
 }
 
+func TestExtend(t *testing.T) {
+	assert := assert.T(t).This
+	hs := HeapStor(64)
+	hs.Alloc(12)
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	hs.Extend(data) // crosses a chunk boundary
+	assert(hs.Size()).Is(Offset(112))
+	assert(hs.Data(12)[:52]).Is(data[:52])
+	assert(hs.Data(64)[:48]).Is(data[52:])
+}
+
 func TestMmapRead(t *testing.T) {
 	ms, _ := MmapStor("stor_test.go", READ) // use code as test file
 	buf := ms.Data(0)
@@ -121,3 +136,137 @@ func TestStress(*testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestPreadStor(t *testing.T) {
+	assert := assert.T(t).This
+	defer func(n int) { PreadCacheChunks = n }(PreadCacheChunks)
+	PreadCacheChunks = 2
+	defer os.Remove("stor.tmp")
+	ps, err := preadStorSize("stor.tmp", CREATE, 64)
+	assert(err).Is(nil)
+	offs := make([]Offset, 0, 40)
+	for i := 0; i < 40; i++ {
+		off, buf := ps.Alloc(20)
+		for j := range buf {
+			buf[j] = byte(i + 1)
+		}
+		offs = append(offs, off)
+	}
+	impl := ps.impl.(*preadStor)
+	assert(len(impl.loaded) <= PreadCacheChunks+2).Is(true)
+	for i, off := range offs {
+		assert(ps.Data(off)[:20]).Is(bytes.Repeat([]byte{byte(i + 1)}, 20))
+	}
+	ps.Write(0, []byte{99})
+	size := ps.Size()
+	ps.Close()
+
+	ps, err = preadStorSize("stor.tmp", READ, 64)
+	assert(err).Is(nil)
+	assert(ps.Size()).Is(size)
+	assert(ps.Data(0)[0]).Is(byte(99))
+	for i, off := range offs[1:] {
+		assert(ps.Data(off)[:20]).Is(bytes.Repeat([]byte{byte(i + 2)}, 20))
+	}
+	assert(ps.LastOffset(size, "\x05\x05")).Is(offs[4] + 18)
+	ps.Close()
+}
+
+func TestVerified(t *testing.T) {
+	assert := assert.T(t).This
+	s := HeapStor(64)
+	off, _ := s.Alloc(8)
+	off2, _ := s.Alloc(8)
+	assert(s.Verified(off)).Is(false)
+	s.SetVerified(off)
+	assert(s.Verified(off)).Is(true)
+	assert(s.Verified(off2)).Is(false)
+	s.SetVerified(off2)
+	assert(s.Verified(off2)).Is(true)
+
+	// unloaded chunks must be verified again
+	defer func(n int) { PreadCacheChunks = n }(PreadCacheChunks)
+	PreadCacheChunks = 1
+	defer os.Remove("stor.tmp")
+	ps, err := preadStorSize("stor.tmp", CREATE, 64)
+	assert(err).Is(nil)
+	defer ps.Close()
+	off, _ = ps.Alloc(20)
+	ps.SetVerified(off)
+	assert(ps.Verified(off)).Is(true)
+	for i := 0; i < 20; i++ {
+		ps.Alloc(20)
+	}
+	impl := ps.impl.(*preadStor)
+	assert(impl.loaded[0]).Is(false)
+	assert(ps.Verified(off)).Is(false)
+}
+
+func TestPreadStorFlush(t *testing.T) {
+	assert := assert.T(t).This
+	defer os.Remove("stor.tmp")
+	ps, err := preadStorSize("stor.tmp", CREATE, 64)
+	assert(err).Is(nil)
+	defer ps.Close()
+	fill := func(n int, b byte) {
+		for i := 0; i < n; i++ {
+			_, buf := ps.Alloc(20)
+			for j := range buf {
+				buf[j] = b
+			}
+		}
+	}
+	fill(5, 1)
+	ps.Flush()
+	data, err := os.ReadFile("stor.tmp") // without Close
+	assert(err).Is(nil)
+	assert(data[64:84]).Is(bytes.Repeat([]byte{1}, 20))
+
+	off, buf := ps.Alloc(20) // allocated but not filled in when flushed
+	ps.Flush()
+	copy(buf, bytes.Repeat([]byte{2}, 20))
+	fill(3, 3)
+	ps.Flush()
+	data, err = os.ReadFile("stor.tmp")
+	assert(err).Is(nil)
+	assert(data[off : off+20]).Is(bytes.Repeat([]byte{2}, 20))
+	assert(uint64(len(data)) >= ps.Size()).Is(true)
+}
+
+func TestMmapGrow(t *testing.T) {
+	assert := assert.T(t).This
+	defer func(g GrowPolicy) { Grow = g }(Grow)
+	Grow = GrowPolicy{MaxChunks: 2} // sparse to avoid using disk space
+	defer os.Remove("stor_test.tmp")
+	fileSize := func() int64 {
+		fi, err := os.Stat("stor_test.tmp")
+		assert(err).Is(nil)
+		return fi.Size()
+	}
+	ms, _ := MmapStor("stor_test.tmp", CREATE)
+	ms.Alloc(100)
+	assert(fileSize()).Is(int64(MMAP_CHUNKSIZE))
+	ms.Alloc(MMAP_CHUNKSIZE - 200) // gets the next chunk, grows by two
+	assert(fileSize()).Is(int64(3 * MMAP_CHUNKSIZE))
+	off, buf := ms.Alloc(300)
+	assert(off).Is(uint64(MMAP_CHUNKSIZE))
+	buf[299] = 1
+	assert(fileSize()).Is(int64(3 * MMAP_CHUNKSIZE))
+	ms.Close()
+	assert(fileSize()).Is(int64(MMAP_CHUNKSIZE + 300))
+
+	// simulate a crash, leaving the extension
+	f, _ := os.OpenFile("stor_test.tmp", os.O_RDWR, 0)
+	f.Truncate(3 * MMAP_CHUNKSIZE)
+	f.Close()
+	ms, _ = MmapStor("stor_test.tmp", READ)
+	assert(ms.Size()).Is(uint64(MMAP_CHUNKSIZE + 300))
+	ms.Close()
+
+	// preallocate (if supported) extends the file
+	f, _ = os.OpenFile("stor_test.tmp", os.O_RDWR|os.O_TRUNC, 0)
+	if preallocate(f, 0, 8192) == nil {
+		assert(fileSize()).Is(int64(8192))
+	}
+	f.Close()
+}
@@ -109,6 +109,9 @@ func compactTable(state *DbState, src *Database, ts *meta.Schema, dst *Database)
 	list.Finish()
 	assert.This(count).Is(info.Nrows)
 	for i := 1; i < len(info.Indexes); i++ {
+		if ts.Indexes[i].Mode == 't' {
+			continue // rebuilt from the data records
+		}
 		func() {
 			defer func() {
 				if e := recover(); e != nil {
@@ -120,6 +123,7 @@ func compactTable(state *DbState, src *Database, ts *meta.Schema, dst *Database)
 	}
 	dataSize := dst.Store.Size() - before
 	ov := buildIndexes(ts, list, dst.Store, count) // same as load
-	ti := &meta.Info{Table: ts.Table, Nrows: count, Size: dataSize, Indexes: ov}
+	ti := &meta.Info{Table: ts.Table, Nrows: count, Size: dataSize, Indexes: ov,
+		Seq: info.Seq}
 	dst.LoadedTable(ts, ti)
 }
@@ -50,7 +50,7 @@ func Dump(db *Database, to string) (ntables int, err error) {
 		dumpTable2(db, sc, true, w, ics)
 		ntables++
 	})
-	ck(w.Flush())
+	w.finish()
 	f.Close()
 	ics.finish()
 	ck(RenameBak(tmpfile, to))
@@ -84,7 +84,7 @@ func DumpDbTable(db *Database, table, to string) (nrecs int, err error) {
 		return 0, errors.New("dump failed: can't find " + table)
 	}
 	nrecs = dumpTable2(db, schema, false, w, ics)
-	ck(w.Flush())
+	w.finish()
 	f.Close()
 	ics.finish()
 	ck(RenameBak(tmpfile, to))
@@ -92,23 +92,20 @@ func DumpDbTable(db *Database, table, to string) (nrecs int, err error) {
 
 }
 
-func dumpOpen() (*os.File, *bufio.Writer) {
+func dumpOpen() (*os.File, *dumpWriter) {
 	f, err := ioutil.TempFile(".", "gs*.tmp")
 	ck(err)
-	w := bufio.NewWriter(f)
-	w.WriteString("Suneido dump 2\n")
-	return f, w
+	return f, newDumpWriter(f)
 }
 
-func dumpTable2(db *Database, schema *meta.Schema, multi bool, w *bufio.Writer,
+func dumpTable2(db *Database, schema *meta.Schema, multi bool, dw *dumpWriter,
 	ics *indexCheckers) int {
 	state := db.GetState()
-	w.WriteString("====== ")
 	s := schema.String()
 	if !multi {
 		s = str.AfterFirst(s, " ")
 	}
-	w.WriteString(s + "\n")
+	w := dw.table(s)
 	info := state.Meta.GetRoInfo(schema.Table)
 	sum := uint64(0)
 	count := info.Indexes[0].Check(func(off uint64) {
@@ -117,7 +114,7 @@ func dumpTable2(db *Database, schema *meta.Schema, multi bool, w *bufio.Writer,
 		writeInt(w, len(rec))
 		w.WriteString(string(rec))
 	})
-	writeInt(w, 0) // end of table records
+	dw.endTable(count)
 	assert.This(count).Is(info.Nrows)
 	ics.checkOtherIndexes(info, count, sum) // concurrent
 	return count
@@ -131,8 +128,8 @@ func writeInt(w *bufio.Writer, n int) {
 	w.WriteByte(byte(n))
 }
 
-func dumpViews(state *DbState, w *bufio.Writer) int {
-	w.WriteString("====== views (view_name,view_definition) key(view_name)\n")
+func dumpViews(state *DbState, dw *dumpWriter) int {
+	w := dw.table("views (view_name,view_definition) key(view_name)")
 	nrecs := 0
 	state.Meta.ForEachView(func(name, def string) {
 		var b rt.RecordBuilder
@@ -143,7 +140,7 @@ func dumpViews(state *DbState, w *bufio.Writer) int {
 		w.WriteString(string(rec))
 		nrecs++
 	})
-	writeInt(w, 0) // end of table records
+	dw.endTable(nrecs)
 	return nrecs
 }
 
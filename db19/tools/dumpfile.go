@@ -0,0 +1,204 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package tools
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Dump files start with a line giving the version of the format.
+//
+// In version 2 (the legacy format) each table is a line
+// with "====== " followed by the schema, and then the records,
+// each preceded by its length (4 bytes, big endian)
+// and terminated by a zero length.
+//
+// Version 3 compresses (deflate) the records of each table
+// in the same format, followed by the number of records
+// and a checksum (crc32) of the uncompressed records.
+// It ends with a "====== end" line followed by the number of tables
+// and a checksum of the schema lines and table trailers.
+// This detects damaged files and truncated files.
+// Load accepts either version, Dump writes version 3.
+const (
+	dumpHeader2 = "Suneido dump 2\n"
+	dumpHeader3 = "Suneido dump 3\n"
+	dumpPrefix  = "====== "
+	dumpEnd     = "end\n" // can't be a schema, they have columns
+)
+
+// dumpWriter writes a version 3 dump file
+type dumpWriter struct {
+	w       *bufio.Writer
+	crc     hash.Hash32 // of the schema lines and table trailers
+	ntables int
+	// for the current table
+	zw   *flate.Writer
+	tcrc hash.Hash32
+	tw   *bufio.Writer
+}
+
+func newDumpWriter(f io.Writer) *dumpWriter {
+	dw := &dumpWriter{w: bufio.NewWriter(f), crc: crc32.NewIEEE(),
+		tcrc: crc32.NewIEEE()}
+	// records are often already compressed, so favor speed
+	zw, err := flate.NewWriter(dw.w, flate.BestSpeed)
+	ck(err)
+	dw.zw = zw
+	dw.tw = bufio.NewWriter(io.MultiWriter(zw, dw.tcrc))
+	dw.w.WriteString(dumpHeader3)
+	return dw
+}
+
+// table starts a table and returns the writer for its records
+func (dw *dumpWriter) table(schema string) *bufio.Writer {
+	line := dumpPrefix + schema + "\n"
+	dw.w.WriteString(line)
+	dw.crc.Write([]byte(line))
+	dw.zw.Reset(dw.w)
+	dw.tcrc.Reset()
+	return dw.tw
+}
+
+// endTable finishes the records and writes the table trailer
+func (dw *dumpWriter) endTable(nrecs int) {
+	writeInt(dw.tw, 0) // end of table records
+	ck(dw.tw.Flush())
+	ck(dw.zw.Close())
+	dw.trailer(nrecs, dw.tcrc.Sum32())
+	dw.ntables++
+}
+
+func (dw *dumpWriter) trailer(n int, sum uint32) {
+	var buf [8]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(n))
+	binary.BigEndian.PutUint32(buf[4:], sum)
+	dw.w.Write(buf[:])
+	dw.crc.Write(buf[:])
+}
+
+// finish writes the file trailer and flushes
+func (dw *dumpWriter) finish() {
+	dw.w.WriteString(dumpPrefix + dumpEnd)
+	dw.trailer(dw.ntables, dw.crc.Sum32())
+	ck(dw.w.Flush())
+}
+
+//-------------------------------------------------------------------
+
+// dumpReader reads either version of dump file
+type dumpReader struct {
+	r       *bufio.Reader
+	version int
+	crc     hash.Hash32
+	ntables int
+	// for the current table (version 3)
+	zr   io.ReadCloser
+	tcrc hash.Hash32
+}
+
+func open(filename string) (*os.File, *dumpReader) {
+	f, err := os.Open(filename)
+	if err != nil {
+		panic(err)
+	}
+	dr := &dumpReader{r: bufio.NewReader(f), crc: crc32.NewIEEE(),
+		tcrc: crc32.NewIEEE()}
+	s, err := dr.r.ReadString('\n')
+	switch {
+	case s == dumpHeader2:
+		dr.version = 2
+	case s == dumpHeader3:
+		dr.version = 3
+	case strings.HasPrefix(s, "Suneido dump "):
+		panic("unsupported dump file version: " + strings.TrimSpace(s))
+	default:
+		panic("not a valid dump file")
+	}
+	return f, dr
+}
+
+// table returns the schema line of the next table (without the prefix)
+// or "" at the end of the file
+func (dr *dumpReader) table() string {
+	line, err := dr.r.ReadString('\n')
+	if err == io.EOF && line == "" {
+		if dr.version == 3 {
+			panic("dump file is truncated")
+		}
+		return ""
+	}
+	ck(err)
+	if !strings.HasPrefix(line, dumpPrefix) {
+		panic("not a valid dump file")
+	}
+	schema := line[len(dumpPrefix):]
+	if dr.version == 2 {
+		return schema
+	}
+	if schema == dumpEnd {
+		want := dr.crc.Sum32()
+		n, sum := dr.trailer()
+		if n != dr.ntables || sum != want {
+			panic("dump file is damaged")
+		}
+		return ""
+	}
+	dr.crc.Write([]byte(line))
+	return schema
+}
+
+// records returns the reader for the records of the current table
+func (dr *dumpReader) records() *bufio.Reader {
+	if dr.version == 2 {
+		return dr.r
+	}
+	// flate does not read past the end of the compressed data
+	// since bufio.Reader is an io.ByteReader
+	dr.zr = flate.NewReader(dr.r)
+	dr.tcrc.Reset()
+	return bufio.NewReader(io.TeeReader(dr.zr, dr.tcrc))
+}
+
+// endTable verifies the records against the table trailer
+func (dr *dumpReader) endTable(rr *bufio.Reader, nrecs int) {
+	if dr.version == 2 {
+		return
+	}
+	n, err := io.Copy(ioutil.Discard, rr)
+	ck(err)
+	ck(dr.zr.Close())
+	count, sum := dr.trailer()
+	if n != 0 || count != nrecs || sum != dr.tcrc.Sum32() {
+		panic("dump file is damaged")
+	}
+	dr.ntables++
+}
+
+func (dr *dumpReader) trailer() (int, uint32) {
+	var buf [8]byte
+	_, err := io.ReadFull(dr.r, buf[:])
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		panic("dump file is truncated")
+	}
+	ck(err)
+	dr.crc.Write(buf[:])
+	return int(binary.BigEndian.Uint32(buf[:])),
+		binary.BigEndian.Uint32(buf[4:])
+}
+
+// finish verifies the end of a single table dump
+func (dr *dumpReader) finish() {
+	if dr.table() != "" {
+		panic("dump file has more than one table")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+const testDumpFile = "tmp_dump.su"
+
+func TestDumpFile(t *testing.T) {
+	assert := assert.T(t)
+	defer os.Remove(testDumpFile)
+	var buf bytes.Buffer
+	dw := newDumpWriter(&buf)
+	for _, table := range []string{"one", "two"} {
+		w := dw.table(table + " (a) key(a)")
+		for i := 0; i < 100; i++ {
+			rec := table + strconv.Itoa(i)
+			writeInt(w, len(rec))
+			w.WriteString(rec)
+		}
+		dw.endTable(100)
+	}
+	dw.finish()
+	data := buf.Bytes()
+
+	read := func(data []byte) (recs []string) {
+		ck(ioutil.WriteFile(testDumpFile, data, 0644))
+		f, dr := open(testDumpFile)
+		defer f.Close()
+		for schema := dr.table(); schema != ""; schema = dr.table() {
+			rr := dr.records()
+			nrecs := readTestRecords(rr, &recs)
+			dr.endTable(rr, nrecs)
+		}
+		return recs
+	}
+	recs := read(data)
+	assert.This(len(recs)).Is(200)
+	assert.This(recs[0]).Is("one0")
+	assert.This(recs[199]).Is("two99")
+
+	for _, n := range []int{len(data) - 1, len(data) - 8, len(data) / 2} {
+		assert.This(func() { read(data[:n]) }).Panics("")
+	}
+	damaged := append([]byte(nil), data...)
+	damaged[len(damaged)-1] ^= 1
+	assert.This(func() { read(damaged) }).Panics("dump file is damaged")
+
+	var legacy bytes.Buffer
+	w := bufio.NewWriter(&legacy)
+	w.WriteString(dumpHeader2)
+	w.WriteString(dumpPrefix + "one (a) key(a)\n")
+	writeInt(w, 3)
+	w.WriteString("abc")
+	writeInt(w, 0)
+	w.Flush()
+	assert.This(read(legacy.Bytes())).Is([]string{"abc"})
+}
+
+func readTestRecords(rr *bufio.Reader, recs *[]string) int {
+	var b [4]byte
+	nrecs := 0
+	for {
+		_, err := io.ReadFull(rr, b[:])
+		ck(err)
+		n := int(binary.BigEndian.Uint32(b[:]))
+		if n == 0 {
+			return nrecs
+		}
+		rec := make([]byte, n)
+		_, err = io.ReadFull(rr, rec)
+		ck(err)
+		*recs = append(*recs, string(rec))
+		nrecs++
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/apmckinlay/gsuneido/util/assert"
 	"github.com/apmckinlay/gsuneido/util/cksum"
 	"github.com/apmckinlay/gsuneido/util/sortlist"
+	"github.com/apmckinlay/gsuneido/util/strs"
 )
 
 type loadJob struct {
@@ -42,7 +43,7 @@ func LoadDatabase(from, dbfile string) int {
 			panic("load failed: " + fmt.Sprint(e))
 		}
 	}()
-	f, r := open(from)
+	f, dr := open(from)
 	defer f.Close()
 	db, tmpfile := tmpdb()
 	defer func() { db.Close(); os.Remove(tmpfile) }()
@@ -59,11 +60,11 @@ func LoadDatabase(from, dbfile string) int {
 	}
 	nTables := 0
 	for ; ; nTables++ {
-		schema := readLinePrefixed(r, "====== ")
+		schema := dr.table()
 		if schema == "" {
 			break
 		}
-		loadTable(db, r, schema, channel)
+		loadTable(db, dr, schema, channel)
 		trace()
 	}
 	close(channel)
@@ -94,33 +95,57 @@ func LoadTable(table, dbfile string) int {
 	}
 	ck(err)
 	defer db.Close()
-	f, r := open(table + ".su")
+	f, dr := open(table + ".su")
 	defer f.Close()
-	schema := table + " " + readLinePrefixed(r, "====== ")
-	nrecs := loadTable(db, r, schema, nil)
+	schema := table + " " + dr.table()
+	nrecs := loadTable(db, dr, schema, nil)
+	dr.finish()
 	db.GetState().Write(true)
 	return nrecs
 }
 
-func open(filename string) (*os.File, *bufio.Reader) {
-	f, err := os.Open(filename)
-	if err != nil {
+// LoadDbTable imports a dumped table from a file into an open database
+// using a bulk transaction. It will replace an already existing table.
+// It returns the number of records loaded or panics on error.
+func LoadDbTable(db *Database, table string) int {
+	defer func() {
+		if e := recover(); e != nil {
+			panic("load failed: " + table + " " + fmt.Sprint(e))
+		}
+	}()
+	f, dr := open(table + ".su")
+	defer f.Close()
+	schema := table + " " + dr.table()
+	sch := query.NewAdminParser(schema).Schema()
+	db.Drop(table)
+	db.Create(&sch)
+	t := db.NewBulkTran(table)
+	if t == nil {
+		panic("can't get exclusive access")
+	}
+	rr := dr.records()
+	nrecs := outputRecords(rr, t, table)
+	dr.endTable(rr, nrecs)
+	dr.finish()
+	if err := t.Complete(); err != "" {
 		panic(err)
 	}
-	r := bufio.NewReader(f)
-	readLinePrefixed(r, "Suneido dump 2")
-	return f, r
+	return nrecs
 }
 
-func loadTable(db *Database, r *bufio.Reader, schema string, channel chan loadJob) int {
+func loadTable(db *Database, dr *dumpReader, schema string, channel chan loadJob) int {
 	trace(schema)
+	rr := dr.records()
 	if strings.HasPrefix(schema, "views") {
-		return loadViews(db, r, schema)
+		nrecs := loadViews(db, rr, schema)
+		dr.endTable(rr, nrecs)
+		return nrecs
 	}
 	sch := query.NewAdminParser(schema).Schema()
 	store := db.Store
 	list := sortlist.NewUnsorted()
-	nrecs, size := readRecords(r, store, list)
+	nrecs, size := readRecords(rr, store, list, sch.Compressed)
+	dr.endTable(rr, nrecs)
 	trace("nrecs", nrecs, "data size", size)
 	list.Finish()
 	if channel == nil { // not concurrent
@@ -143,20 +168,40 @@ func loadTable2(sch schema.Schema, list *sortlist.Builder, nrecs int, size uint6
 	db.LoadedTable(ts, ti)
 }
 
-func readLinePrefixed(r *bufio.Reader, pre string) string {
-	s, err := r.ReadString('\n') // file header
-	if err == io.EOF {
-		return ""
-	}
-	ck(err)
-	if !strings.HasPrefix(s, pre) {
-		panic("not a valid dump file")
+func readRecords(in *bufio.Reader, store *stor.Stor, list *sortlist.Builder,
+	compress bool) (nrecs int, size uint64) {
+	intbuf := make([]byte, 4)
+	for { // each record
+		_, err := io.ReadFull(in, intbuf)
+		if err == io.EOF {
+			break
+		}
+		ck(err)
+		n := int(binary.BigEndian.Uint32(intbuf))
+		if n == 0 {
+			break
+		}
+		var off uint64
+		if compress {
+			buf := make([]byte, n)
+			_, err = io.ReadFull(in, buf)
+			ck(err)
+			off = StoreRecord(store, rt.Record(buf), true)
+		} else {
+			var buf []byte
+			off, buf = store.Alloc(n + cksum.Len)
+			_, err = io.ReadFull(in, buf[:n])
+			ck(err)
+			cksum.Update(buf)
+		}
+		list.Add(off)
+		nrecs++
+		size += uint64(n)
 	}
-	return s[len(pre):]
+	return nrecs, size
 }
 
-func readRecords(in *bufio.Reader, store *stor.Stor, list *sortlist.Builder) (
-	nrecs int, size uint64) {
+func outputRecords(in *bufio.Reader, t *UpdateTran, table string) (nrecs int) {
 	intbuf := make([]byte, 4)
 	for { // each record
 		_, err := io.ReadFull(in, intbuf)
@@ -168,15 +213,13 @@ func readRecords(in *bufio.Reader, store *stor.Stor, list *sortlist.Builder) (
 		if n == 0 {
 			break
 		}
-		off, buf := store.Alloc(n + cksum.Len)
-		_, err = io.ReadFull(in, buf[:n])
+		buf := make([]byte, n)
+		_, err = io.ReadFull(in, buf)
 		ck(err)
-		cksum.Update(buf)
-		list.Add(off)
+		t.Output(table, rt.Record(buf))
 		nrecs++
-		size += uint64(n)
 	}
-	return nrecs, size
+	return nrecs
 }
 
 func buildIndexes(ts *meta.Schema, list *sortlist.Builder, store *stor.Stor, nrecs int) []*index.Overlay {
@@ -185,6 +228,11 @@ func buildIndexes(ts *meta.Schema, list *sortlist.Builder, store *stor.Stor, nre
 	for i := range ts.Indexes {
 		ix := ts.Indexes[i]
 		trace(ix)
+		if ix.Mode == 't' {
+			fld := strs.Index(ts.Columns, ix.Columns[0])
+			ov[i] = index.OverlayFor(BuildTextIndex(store, fld, list.Iter()))
+			continue
+		}
 		if i > 0 || ix.Mode != 'k' {
 			list.Sort(MakeLess(store, &ix.Ixspec))
 		}
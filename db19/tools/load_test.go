@@ -10,6 +10,10 @@ import (
 	"time"
 
 	. "github.com/apmckinlay/gsuneido/db19"
+	"github.com/apmckinlay/gsuneido/db19/meta/schema"
+	"github.com/apmckinlay/gsuneido/db19/stor"
+	rt "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/assert"
 )
 
 func TestLoadTable(*testing.T) {
@@ -34,3 +38,35 @@ func TestLoadDatabase(*testing.T) {
 	fmt.Println("loaded", n, "tables in", time.Since(t).Round(time.Millisecond))
 	ck(CheckDatabase("tmp.db"))
 }
+
+func TestLoadDbTable(t *testing.T) {
+	assert := assert.T(t)
+	MakeSuTran = func(ut *UpdateTran) *rt.SuTran {
+		return rt.NewSuTran(nil, true)
+	}
+	db, err := CreateDb(stor.HeapStor(64 * 1024))
+	ck(err)
+	StartConcur(db, time.Hour)
+	defer db.Close()
+	db.Create(&schema.Schema{
+		Table:   "tmp_load",
+		Columns: []string{"one", "two"},
+		Indexes: []schema.Index{{Mode: 'k', Columns: []string{"one"}}},
+	})
+	ut := db.NewUpdateTran()
+	for i := 0; i < 100; i++ {
+		var b rt.RecordBuilder
+		b.Add(rt.IntVal(i).(rt.Packable)).Add(rt.SuStr("data"))
+		ut.Output("tmp_load", b.Build())
+	}
+	assert.This(ut.Complete()).Is("")
+	defer os.Remove("tmp_load.su")
+	n, err := DumpDbTable(db, "tmp_load", "tmp_load.su")
+	ck(err)
+	assert.This(n).Is(100)
+
+	assert.This(LoadDbTable(db, "tmp_load")).Is(100)
+	assert.This(db.NewReadTran().GetInfo("tmp_load").Nrows).Is(100)
+	assert.This(db.Schema("tmp_load")).
+		Is("tmp_load (one,two) key(one)")
+}
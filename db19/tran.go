@@ -14,16 +14,19 @@ import (
 	"github.com/apmckinlay/gsuneido/db19/index/ixkey"
 	"github.com/apmckinlay/gsuneido/db19/meta"
 	"github.com/apmckinlay/gsuneido/db19/meta/schema"
+	"github.com/apmckinlay/gsuneido/db19/stor"
 	rt "github.com/apmckinlay/gsuneido/runtime"
 	"github.com/apmckinlay/gsuneido/util/assert"
 	"github.com/apmckinlay/gsuneido/util/cksum"
-	"github.com/apmckinlay/gsuneido/util/hacks"
 	"github.com/apmckinlay/gsuneido/util/strs"
 )
 
 type tran struct {
-	db    *Database
-	meta  *meta.Meta
+	db   *Database
+	meta *meta.Meta
+	// store is the store for meta.
+	// It is captured so compaction does not affect active transactions.
+	store *stor.Stor
 	state tstate
 }
 
@@ -80,8 +83,9 @@ func (t *tran) GetAllViews() []string {
 	return defs
 }
 
+// GetView returns the definition of a view as of the start of the transaction
 func (t *tran) GetView(name string) string {
-	return t.db.GetView(name)
+	return t.meta.GetView(name)
 }
 
 func (t *tran) Ended() bool {
@@ -98,8 +102,11 @@ type ReadTran struct {
 var nextReadTran int32
 
 func (db *Database) NewReadTran() *ReadTran {
-	state := db.GetState()
-	return &ReadTran{tran: tran{db: db, meta: state.Meta},
+	return db.newReadTran(db.GetState())
+}
+
+func (db *Database) newReadTran(state *DbState) *ReadTran {
+	return &ReadTran{tran: tran{db: db, meta: state.Meta, store: state.store},
 		num: int(atomic.AddInt32(&nextReadTran, 1))}
 }
 
@@ -129,9 +136,7 @@ func (t *ReadTran) GetIndexI(table string, iIndex int) *index.Overlay {
 }
 
 func (t *ReadTran) GetRecord(off uint64) rt.Record {
-	buf := t.db.Store.Data(off)
-	size := rt.RecLen(buf)
-	return rt.Record(hacks.BStoS(buf[:size]))
+	return OffToRec(t.store, off)
 }
 
 func (t *ReadTran) ColToFld(table, col string) int {
@@ -141,8 +146,13 @@ func (t *ReadTran) ColToFld(table, col string) int {
 
 func (t *ReadTran) RangeFrac(table string, iIndex int, org, end string) float64 {
 	info := t.meta.GetRoInfo(table)
-	idx := info.Indexes[iIndex]
-	f := float64(idx.RangeFrac(org, end))
+	f, ok := 0.0, false
+	if st := info.IndexStats(iIndex); st != nil && st.Nrows > 0 {
+		f, ok = st.RangeFrac(org, end)
+	}
+	if !ok { // no stats or a narrow range
+		f = float64(info.Indexes[iIndex].RangeFrac(org, end))
+	}
 	if info.Nrows > 0 {
 		f = math.Max(f, 1/float64(info.Nrows))
 	}
@@ -212,16 +222,53 @@ type UpdateTran struct {
 	ct       *CkTran
 	conflict string
 	th       *rt.Thread // for triggers
+	// walData is the actions to log if there is a wal
+	walData []byte
+	// walSeq is the wal seq assigned by commit, 0 if not logged
+	walSeq uint64
+	// replay is set when replaying the wal
+	replay bool
+	// nwrites is the number of outputs, updates, and deletes
+	nwrites int
+	// checks caches the parsed check constraints and defaults,
+	// see constraints and defaults
+	checks map[string]*tblChecks
+	// histTime is the packed timestamp for history versions,
+	// see historyTime
+	histTime string
 }
 
 func (db *Database) NewUpdateTran() *UpdateTran {
+	if db.ck == nil { // replica
+		return nil
+	}
 	ct := db.ck.StartTran()
 	if ct == nil {
 		return nil
 	}
 	meta := ct.state.Meta.Mutable()
-	return &UpdateTran{ct: ct,
-		ReadTran: ReadTran{tran: tran{db: db, meta: meta}}}
+	return &UpdateTran{ct: ct, ReadTran: ReadTran{
+		tran: tran{db: db, meta: meta, store: ct.state.store}}}
+}
+
+// NewBulkTran returns an update transaction for initial loads
+// and large imports. It has exclusive access to the tables
+// so its writes are not recorded by the checker.
+// Index changes stay in the transaction's ixbuf layers
+// and are merged once, after it commits.
+// It can only write to the given tables and it does not call triggers.
+// It returns nil if the tables have outstanding writes.
+func (db *Database) NewBulkTran(tables ...string) *UpdateTran {
+	if db.ck == nil { // replica
+		return nil
+	}
+	ct := db.ck.StartBulk(tables...)
+	if ct == nil {
+		return nil
+	}
+	meta := ct.state.Meta.Mutable()
+	return &UpdateTran{ct: ct, ReadTran: ReadTran{
+		tran: tran{db: db, meta: meta, store: ct.state.store}}}
 }
 
 func (t *UpdateTran) String() string {
@@ -235,6 +282,7 @@ func (t *UpdateTran) Complete() string {
 	}
 	if t.db.ck.Commit(t) {
 		t.state = completed
+		t.db.wal.sync(t.walSeq)
 	} else {
 		t.state = commitFailed
 		conflict := t.ct.conflict.Load()
@@ -256,12 +304,15 @@ func (t *UpdateTran) Commit() {
 	t.ck(t.db.ck.Commit(t))
 }
 
-// commit is internal, called by checkco (to serialize)
-func (t *UpdateTran) commit() int {
+// commit is internal, called by checkco (to serialize).
+// tables are the tables written, from Check.commit.
+// It returns the tables that need to be merged.
+func (t *UpdateTran) commit(tables []string) []string {
+	t.walSeq = t.db.wal.append(t.walData)
 	t.db.UpdateState(func(state *DbState) {
-		state.Meta = t.meta.LayeredOnto(state.Meta)
+		state.Meta, tables = t.meta.LayeredOnto(state.Meta, tables)
 	})
-	return t.num()
+	return tables
 }
 
 func (t *UpdateTran) Abort() string {
@@ -288,20 +339,69 @@ func (t *UpdateTran) Lookup(table string, iIndex int, key string) *rt.DbRec {
 	return t.ReadTran.Lookup(table, iIndex, key)
 }
 
-// Read adds a transaction read event to the checker
+// Read adds a transaction read event to the checker.
+// Reads by a bulk transaction of its own tables can't conflict.
 func (t *UpdateTran) Read(table string, iIndex int, from, to string) {
+	if t.bulk(table) {
+		return
+	}
 	t.ck(t.db.ck.Read(t.ct, table, iIndex, from, to))
 }
 
+// write adds a transaction write event to the checker.
+// A bulk transaction only checks that it has exclusive access.
+func (t *UpdateTran) write(table string, keys []string) {
+	if t.ct.bulk != nil {
+		if !t.bulk(table) {
+			panic("bulk transaction can't write to " + table)
+		}
+		return
+	}
+	t.ck(t.db.ck.Write(t.ct, table, keys))
+}
+
+func (t *UpdateTran) bulk(table string) bool {
+	return strs.Contains(t.ct.bulk, table)
+}
+
 func (t *UpdateTran) Output(table string, rec rt.Record) {
 	ts := t.getSchema(table)
-	ti := t.getInfo(table)
-	n := rec.Len()
-	off, buf := t.db.Store.Alloc(n + cksum.Len)
-	copy(buf, rec[:n])
+	rec = t.defaults(ts, rec[:rec.Len()])
+	rec = t.autoNumber(ts, rec)
+	off := StoreRecord(t.store, rec, ts.Compressed)
+	t.output(table, off, rec)
+}
+
+// OutputBuilder is like Output but it builds the record
+// directly in the database store rather than building and then copying it.
+// It is intended for bulk output.
+func (t *UpdateTran) OutputBuilder(table string, rb *rt.RecordBuilder) {
+	if ts := t.getSchema(table); ts.Compressed || ts.AutoNumber != "" ||
+		len(ts.Defaults) > 0 {
+		t.Output(table, rb.Build())
+		return
+	}
+	var off uint64
+	var buf []byte
+	rec := rb.BuildInto(func(n int) []byte {
+		off, buf = t.store.Alloc(n + cksum.Len)
+		return buf[:n]
+	})
 	cksum.Update(buf)
+	t.output(table, off, rec)
+}
+
+func (t *UpdateTran) output(table string, off uint64, rec rt.Record) {
+	t.addWrite()
+	ts := t.getSchema(table)
+	t.constraints(ts, rec)
+	ti := t.getInfo(table)
+	n := len(rec)
 	keys := make([]string, len(ts.Indexes))
 	for i := range ts.Indexes {
+		if ts.Indexes[i].Mode == 't' {
+			continue
+		}
 		ix := ti.Indexes[i]
 		is := ts.Indexes[i].Ixspec
 		keys[i] = is.Key(rec)
@@ -312,12 +412,18 @@ func (t *UpdateTran) Output(table string, rec rt.Record) {
 		t.fkeyOutputBlock(ts, i, rec)
 	}
 	for i := range ts.Indexes {
+		if ts.Indexes[i].Mode == 't' {
+			t.textOutput(table, ts, ti, i, rec, off)
+			continue
+		}
 		ti.Indexes[i].Insert(keys[i], off)
 	}
-	t.ck(t.db.ck.Write(t.ct, table, keys))
+	t.write(table, keys)
 	ti.Nrows++
 	ti.Size += uint64(n)
-	t.db.CallTrigger(t.thread(), t, table, "", rec)
+	t.walAct(walOutput, table, rec)
+	t.historyOutput(ts, rec)
+	t.callTrigger(table, "", rec)
 }
 
 func (t *UpdateTran) fkeyOutputBlock(ts *meta.Schema, i int, rec rt.Record) {
@@ -342,6 +448,28 @@ func (t *ReadTran) fkeyOutputExists(table string, iIndex int, key string) bool {
 	return idx.Lookup(key) != 0
 }
 
+// callTrigger calls the trigger for a table, except when replaying the wal
+// since the trigger's actions were logged, and for bulk transactions.
+//
+// The write has already been done when the trigger is called,
+// so if the trigger fails the transaction is aborted.
+// Otherwise catching the exception would let the rejected write commit.
+func (t *UpdateTran) callTrigger(table string, oldrec, newrec rt.Record) {
+	if t.replay || t.ct.bulk != nil {
+		return
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			if t.state == active {
+				t.db.ck.Abort(t.ct, fmt.Sprint("trigger failed: ", e))
+				t.state = aborted
+			}
+			panic(e)
+		}
+	}()
+	t.db.CallTrigger(t.thread(), t, table, oldrec, newrec)
+}
+
 func (t *UpdateTran) thread() *rt.Thread {
 	if t.th == nil {
 		t.th = &rt.Thread{}
@@ -350,35 +478,49 @@ func (t *UpdateTran) thread() *rt.Thread {
 }
 
 func (t *UpdateTran) Delete(table string, off uint64) {
+	t.addWrite()
 	ts := t.getSchema(table)
 	ti := t.getInfo(table)
 	rec := t.GetRecord(off)
 	n := rec.Len()
 	keys := make([]string, len(ts.Indexes))
 	for i := range ts.Indexes {
+		if ts.Indexes[i].Mode == 't' {
+			continue
+		}
 		is := ts.Indexes[i].Ixspec
 		keys[i] = is.Key(rec)
-		t.fkeyDeleteBlock(ts.Indexes[i].FkToHere, keys[i])
+		t.fkeyDeleteBlock(ts.Indexes[i].FkToHere, keys[i], schema.CascadeDeletes)
 	}
 	for i := range ts.Indexes {
+		if ts.Indexes[i].Mode == 't' {
+			t.textDelete(table, ts, ti, i, rec, off)
+			continue
+		}
 		ti.Indexes[i].Delete(keys[i], off)
 		t.fkeyDeleteCascade(ts.Indexes[i].FkToHere, keys[i])
 	}
-	t.ck(t.db.ck.Write(t.ct, table, keys))
+	t.write(table, keys)
 	assert.Msg("Delete Nrows").That(ti.Nrows > 0)
 	ti.Nrows--
 	assert.Msg("Delete Size").That(ti.Size >= uint64(n))
 	ti.Size -= uint64(n)
-	t.db.CallTrigger(t.thread(), t, table, rec, "")
+	t.walAct(walDelete, table, rec)
+	t.historyDelete(ts, rec)
+	t.callTrigger(table, rec, "")
 }
 
-func (t *UpdateTran) fkeyDeleteBlock(fkToHere []schema.Fkey, key string) {
+// fkeyDeleteBlock panics if a key that is being deleted or updated
+// is referenced by foreign keys that do not cascade
+// i.e. cascade is CascadeDeletes or CascadeUpdates
+func (t *UpdateTran) fkeyDeleteBlock(fkToHere []schema.Fkey, key string,
+	cascade int) {
 	if key == "" {
 		return
 	}
 	for i := range fkToHere {
 		fk := &fkToHere[i]
-		if fk.Mode == schema.Block && t.fkeyDeleteExists(fk, key) {
+		if fk.Mode&cascade == 0 && t.fkeyDeleteExists(fk, key) {
 			panic("delete blocked by foreign key: " +
 				fk.Table + " " + strs.Join("(,)", fk.Columns))
 		}
@@ -416,7 +558,7 @@ func rangeEnd(key string, n int) string {
 }
 
 func (t *UpdateTran) fkeyDeleteCascade(fkToHere []schema.Fkey, key string) {
-	if key == "" {
+	if key == "" || t.replay { // cascades were logged
 		return
 	}
 	for i := range fkToHere {
@@ -433,21 +575,23 @@ func (t *UpdateTran) fkeyDeleteCascade(fkToHere []schema.Fkey, key string) {
 }
 
 func (t *UpdateTran) Update(table string, oldoff uint64, newrec rt.Record) uint64 {
+	t.addWrite()
 	ts := t.getSchema(table)
 	ti := t.getInfo(table)
 	n := newrec.Len()
 	newrec = newrec[:n]
+	t.constraints(ts, newrec)
 	oldrec := t.GetRecord(oldoff)
 	newoff := oldoff
 	if newrec != oldrec {
-		off, buf := t.db.Store.Alloc(n + cksum.Len)
-		copy(buf, newrec)
-		cksum.Update(buf)
-		newoff = off
+		newoff = StoreRecord(t.store, newrec, ts.Compressed)
 	}
 	oldkeys := make([]string, len(ts.Indexes))
 	newkeys := make([]string, len(ts.Indexes))
 	for i := range ts.Indexes {
+		if ts.Indexes[i].Mode == 't' {
+			continue
+		}
 		is := ts.Indexes[i].Ixspec
 		oldkeys[i] = is.Key(oldrec)
 		if newoff != oldoff {
@@ -458,7 +602,8 @@ func (t *UpdateTran) Update(table string, oldoff uint64, newrec rt.Record) uint6
 					panic(fmt.Sprint("duplicate key: ",
 						strs.Join(",", ts.Indexes[i].Columns), " in ", table))
 				}
-				t.fkeyDeleteBlock(ts.Indexes[i].FkToHere, oldkeys[i])
+				t.fkeyDeleteBlock(ts.Indexes[i].FkToHere, oldkeys[i],
+					schema.CascadeUpdates)
 				t.fkeyOutputBlock(ts, i, newrec)
 			}
 		}
@@ -466,6 +611,11 @@ func (t *UpdateTran) Update(table string, oldoff uint64, newrec rt.Record) uint6
 	if newoff != oldoff {
 		for i := range ts.Indexes {
 			ix := ti.Indexes[i]
+			if ts.Indexes[i].Mode == 't' {
+				t.textDelete(table, ts, ti, i, oldrec, oldoff)
+				t.textOutput(table, ts, ti, i, newrec, newoff)
+				continue
+			}
 			if oldkeys[i] == newkeys[i] {
 				ix.Update(oldkeys[i], newoff)
 			} else {
@@ -476,19 +626,24 @@ func (t *UpdateTran) Update(table string, oldoff uint64, newrec rt.Record) uint6
 			}
 		}
 	}
-	t.ck(t.db.ck.Write(t.ct, table, oldkeys))
+	t.write(table, oldkeys)
 	if newoff != oldoff {
-		t.ck(t.db.ck.Write(t.ct, table, newkeys))
+		t.write(table, newkeys)
 		d := int64(len(newrec) - len(oldrec))
 		assert.Msg("Update Size").That(int64(ti.Size)+d > 0)
 		ti.Size = uint64(int64(ti.Size) + d)
+		t.walAct(walUpdate, table, oldrec, newrec)
+		t.historyUpdate(ts, oldrec, newrec)
 	}
-	t.db.CallTrigger(t.thread(), t, table, oldrec, newrec)
+	t.callTrigger(table, oldrec, newrec)
 	return newoff
 }
 
 func (t *UpdateTran) fkeyUpdate(fkToHere []schema.Fkey,
 	rec rt.Record, key string, cols, ixcols []string) {
+	if key == "" || t.replay { // cascades were logged
+		return
+	}
 	for i := range fkToHere {
 		fk := &fkToHere[i]
 		if fk.Mode&schema.CascadeUpdates == 0 {
@@ -12,7 +12,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/apmckinlay/gsuneido/db19/index"
 	"github.com/apmckinlay/gsuneido/db19/index/ixkey"
+	"github.com/apmckinlay/gsuneido/db19/meta"
 	"github.com/apmckinlay/gsuneido/db19/meta/schema"
 	"github.com/apmckinlay/gsuneido/db19/stor"
 	rt "github.com/apmckinlay/gsuneido/runtime"
@@ -92,11 +94,42 @@ func TestTran(t *testing.T) {
 	ti := rt.meta.GetRoInfo("mytable")
 	assert.T(t).Msg("nrows").This(ti.Nrows).Is(nout)
 	assert.T(t).Msg("size").This(ti.Size).Is(nout * 23)
+	st := ti.IndexStats(0)
+	assert.T(t).That(st != nil && st.Nrows > nout*8/10)
+	assert.T(t).Msg("distinct").This(st.Distinct).Is(st.Nrows)
 	db.Close()
 	ck(CheckDatabase("tmp.db"))
 	os.Remove("tmp.db")
 }
 
+func TestMetrics(t *testing.T) {
+	assert := assert.T(t)
+	db, err := CreateDb(stor.HeapStor(8192))
+	ck(err)
+	db.CheckerSync()
+	createTbl(db)
+	for i := 0; i < 10; i++ {
+		db.CommitMerge(output1(db))
+	}
+	ti := db.GetState().Meta.GetRoInfo("mytable")
+	assert.This(ti.Metrics).Is(meta.Metrics{Merges: 10, KeysMerged: 10})
+	merged, unmerged := ti.BufLen()
+	assert.This(merged).Is(10)
+	assert.This(unmerged).Is(0)
+	ut := output1(db) // started before the persist
+	db.persist(&execPersistSingle{}, false)
+	ti = db.GetState().Meta.GetRoInfo("mytable")
+	assert.This(ti.Metrics.Persists).Is(1)
+	assert.That(ti.Metrics.PersistBytes > 0)
+	merged, _ = ti.BufLen()
+	assert.This(merged).Is(0)
+	db.CommitMerge(ut) // metrics are not from the transaction's copy
+	ti = db.GetState().Meta.GetRoInfo("mytable")
+	assert.This(ti.Metrics.Merges).Is(11)
+	assert.This(ti.Metrics.Persists).Is(1)
+	db.Close()
+}
+
 func createDb() *Database {
 	db, err := CreateDatabase("tmp.db")
 	ck(err)
@@ -123,6 +156,27 @@ func output1(db *Database) *UpdateTran {
 	// NOTE: does not commit
 }
 
+func TestOutputBuilder(t *testing.T) {
+	store := stor.HeapStor(8192)
+	db, err := CreateDb(store)
+	ck(err)
+	db.CheckerSync()
+	createTbl(db)
+	ut := db.NewUpdateTran()
+	var b rt.RecordBuilder
+	b.Add(rt.SuStr("hello")).Add(rt.SuStr("world"))
+	ut.OutputBuilder("mytable", &b)
+	db.CommitMerge(ut) // commit synchronously
+
+	rt := db.NewReadTran()
+	ti := rt.meta.GetRoInfo("mytable")
+	assert.T(t).This(ti.Nrows).Is(1)
+	assert.T(t).This(ti.Size).Is(uint64(len(b.Build())))
+	db.persist(&execPersistSingle{}, true)
+	ck(db.Check())
+	db.Close()
+}
+
 func mkrec(args ...string) rt.Record {
 	var b rt.RecordBuilder
 	for _, a := range args {
@@ -152,7 +206,7 @@ func TestSchemaChange(*testing.T) {
 		ut := output1(db)
 		// commit synchronously
 		tables := db.ck.(*Check).commit(ut)
-		ut.commit()
+		tables = ut.commit(tables)
 
 		fn()
 
@@ -191,6 +245,34 @@ func TestSchemaChange(*testing.T) {
 	})
 }
 
+func TestRenameBeforeMerge(t *testing.T) {
+	assert := assert.T(t)
+	db, err := CreateDb(stor.HeapStor(8192))
+	ck(err)
+	db.CheckerSync()
+	createTbl(db)
+	ut := output1(db)
+	// commit synchronously, without merging
+	tables := db.ck.(*Check).commit(ut)
+	tables = ut.commit(tables)
+
+	assert.This(func() { db.RenameTable("mytable", "newtable") }).
+		Panics("mytable has pending merges")
+	assert.This(func() {
+		db.AlterRename("mytable", []string{"two"}, []string{"dos"})
+	}).Panics("mytable has pending merges")
+
+	merges := &mergeList{}
+	merges.add(tables)
+	db.Merge(ut.meta, mergeSingle, merges)
+
+	assert.That(db.RenameTable("mytable", "newtable"))
+	assert.That(db.AlterRename("newtable", []string{"two"}, []string{"dos"}))
+	rt := db.NewReadTran()
+	assert.This(rt.GetInfo("newtable").Nrows).Is(1)
+	assert.This(rt.GetInfo("newtable").Indexes[0].Nlayers()).Is(1)
+}
+
 func TestTooMany(*testing.T) {
 	store := stor.HeapStor(8192)
 	db, err := CreateDb(store)
@@ -235,3 +317,336 @@ func TestRangeEnd(t *testing.T) {
 	assert(end(2, "foo")).Is("foo\x00\x00\x00\x00" + ixkey.Max)
 	assert(end(2, "foo", "bar")).Is("foo\x00\x00bar\x00\x00" + ixkey.Max)
 }
+
+func TestCompressed(t *testing.T) {
+	assert := assert.T(t)
+	store := stor.HeapStor(64 * 1024)
+	db, err := CreateDb(store)
+	ck(err)
+	db.CheckerSync()
+	db.Create(&schema.Schema{
+		Table:      "ztable",
+		Columns:    []string{"one", "two"},
+		Indexes:    []schema.Index{{Mode: 'k', Columns: []string{"one"}}},
+		Compressed: true,
+	})
+	big := strings.Repeat("helloworld", 100)
+	before := store.Size()
+	ut := db.NewUpdateTran()
+	for i := 0; i < 10; i++ {
+		ut.Output("ztable", mkrec(strconv.Itoa(i), big))
+	}
+	db.CommitMerge(ut)
+	assert.That(store.Size()-before < 10*uint64(len(big))/2)
+
+	tran := db.NewReadTran()
+	ti := tran.GetInfo("ztable")
+	assert.This(ti.Nrows).Is(10)
+	recsize := uint64(len(mkrec("0", big)))
+	assert.This(ti.Size).Is(10 * recsize)
+	dbrec := tran.Lookup("ztable", 0, mkrec("5").GetRaw(0))
+	assert.This(dbrec.Record.GetStr(1)).Is(big)
+
+	ut = db.NewUpdateTran()
+	off := ut.Update("ztable", dbrec.Off, mkrec("5", big+"!"))
+	ut.Delete("ztable", off)
+	db.CommitMerge(ut)
+	tran = db.NewReadTran()
+	assert.This(tran.GetInfo("ztable").Nrows).Is(9)
+	assert.This(tran.GetInfo("ztable").Size).Is(9 * recsize)
+	assert.This(count(tran, "ztable")).Is(9)
+}
+
+func TestQuota(t *testing.T) {
+	assert := assert.T(t)
+	store := stor.HeapStor(64 * 1024)
+	db, err := CreateDb(store)
+	ck(err)
+	StartConcur(db, time.Hour)
+	defer db.Close()
+	db.Create(&schema.Schema{
+		Table:   "qtable",
+		Columns: []string{"one", "two"},
+		Indexes: []schema.Index{{Mode: 'k', Columns: []string{"one"}}},
+		MaxRows: 3,
+	})
+	output := func(keys ...string) string {
+		ut := db.NewUpdateTran()
+		for _, k := range keys {
+			ut.Output("qtable", mkrec(k, "foo"))
+		}
+		return ut.Complete()
+	}
+	assert.This(output("1", "2")).Is("")
+	assert.This(output("3", "4")).
+		Is("quota exceeded: qtable would have more than 3 rows")
+	assert.This(output("3")).Is("")
+	assert.That(strings.HasPrefix(output("4"), QuotaExceeded))
+	assert.This(db.NewReadTran().GetInfo("qtable").Nrows).Is(3)
+
+	// deletes are allowed
+	ut := db.NewUpdateTran()
+	dbrec := ut.Lookup("qtable", 0, mkrec("1").GetRaw(0))
+	ut.Delete("qtable", dbrec.Off)
+	assert.This(ut.Complete()).Is("")
+	assert.This(output("4")).Is("")
+
+	defer func(n uint64) { MaxDbSize = n }(MaxDbSize)
+	MaxDbSize = store.Size() - 1
+	ut = db.NewUpdateTran()
+	dbrec = ut.Lookup("qtable", 0, mkrec("2").GetRaw(0))
+	ut.Delete("qtable", dbrec.Off)
+	assert.This(ut.Complete()).Is("")
+	assert.That(strings.HasPrefix(output("5"),
+		"quota exceeded: database file is larger"))
+}
+
+func TestBulkTran(t *testing.T) {
+	assert := assert.T(t)
+	db, err := CreateDb(stor.HeapStor(64 * 1024))
+	ck(err)
+	StartConcur(db, time.Hour)
+	defer db.Close()
+	createTbl(db)
+	db.Create(&schema.Schema{
+		Table:   "other",
+		Columns: []string{"one"},
+		Indexes: []schema.Index{{Mode: 'k', Columns: []string{"one"}}},
+	})
+
+	ut := db.NewUpdateTran()
+	ut.Output("mytable", mkrec("0"))
+	assert.This(db.NewBulkTran("mytable")).Is(nil) // outstanding write
+	assert.This(ut.Complete()).Is("")
+
+	bt := db.NewBulkTran("mytable")
+	ut = db.NewUpdateTran()
+	for i := 1; i <= 100; i++ {
+		bt.Output("mytable", mkrec(strconv.Itoa(i)))
+	}
+	assert.This(func() { bt.Output("other", mkrec("1")) }).
+		Panics("bulk transaction can't write to other")
+	assert.This(func() { ut.Output("mytable", mkrec("x")) }).
+		Panics("conflict with index creation")
+	ut = db.NewUpdateTran()
+	ut.Output("other", mkrec("1")) // other tables are not affected
+	assert.This(ut.Complete()).Is("")
+	assert.This(bt.Complete()).Is("")
+	assert.This(db.NewReadTran().GetInfo("mytable").Nrows).Is(101)
+	assert.This(count(db.NewReadTran(), "mytable")).Is(101)
+
+	// exclusive access ends with the bulk transaction
+	ut = db.NewUpdateTran()
+	ut.Output("mytable", mkrec("x"))
+	assert.This(ut.Complete()).Is("")
+	bt = db.NewBulkTran("mytable")
+	bt.Output("mytable", mkrec("y"))
+	assert.This(bt.Abort()).Is("")
+
+	// reads of bulk tables conflict
+	ut = db.NewUpdateTran()
+	ut.Read("mytable", 0, "", ixkey.Max)
+	assert.This(db.NewBulkTran("mytable")).Is(nil) // outstanding read
+	assert.This(ut.Complete()).Is("")
+	bt = db.NewBulkTran("mytable")
+	ut = db.NewUpdateTran()
+	ut.Read("mytable", 0, "", ixkey.Max)
+	assert.This(func() { ut.Output("other", mkrec("2")) }).
+		Panics("conflicted with bulk write")
+	bt.Output("mytable", mkrec("z"))
+	assert.This(bt.Complete()).Is("")
+	ut = db.NewUpdateTran()
+	ut.Output("mytable", mkrec("y"))
+	assert.This(ut.Complete()).Is("")
+	assert.This(db.NewReadTran().GetInfo("mytable").Nrows).Is(104)
+}
+
+func TestTranLimits(t *testing.T) {
+	assert := assert.T(t)
+	db, err := CreateDb(stor.HeapStor(8192))
+	ck(err)
+	db.CheckerSync()
+	createTbl(db)
+	var warnings []string
+	defer func(f func(string, string)) { LimitWarning = f }(LimitWarning)
+	LimitWarning = func(_, warning string) {
+		warnings = append(warnings, warning)
+	}
+	db.SetTranLimits(TranLimits{MaxAge: 3, MaxWrites: 3,
+		WarnAge: 2, WarnWrites: 2})
+
+	ut := db.NewUpdateTran()
+	for i := 0; i < 3; i++ {
+		ut.Output("mytable", mkrec(strconv.Itoa(i)))
+	}
+	assert.This(func() { ut.Output("mytable", mkrec("x")) }).
+		Panics("transaction exceeded max writes (3)")
+	assert.This(ut.WriteCount()).Is(3)
+	assert.This(warnings).Is([]string{"update transaction has done 2 writes"})
+
+	ut2 := db.NewUpdateTran()
+	ut2.SetLimits(TranLimits{}) // no limits
+	for i := 10; i < 20; i++ {
+		ut2.Output("mytable", mkrec(strconv.Itoa(i)))
+	}
+	assert.This(ut2.WriteCount()).Is(10)
+
+	check := db.ck.(*Check)
+	check.tick()
+	check.tick()
+	assert.This(warnings[1]).
+		Is("update transaction has been outstanding for 2 seconds")
+	check.tick()
+	assert.True(ut.ct.Aborted())
+	assert.False(ut2.ct.Aborted())
+	assert.This(len(warnings)).Is(2)
+}
+
+func TestRenameWithTrans(t *testing.T) {
+	assert := assert.T(t)
+	db, err := CreateDb(stor.HeapStor(8192))
+	ck(err)
+	StartConcur(db, time.Hour)
+	defer db.Close()
+	createTbl(db)
+
+	ut := db.NewUpdateTran()
+	ut.Output("mytable", mkrec("1"))
+	assert.This(func() { db.RenameTable("mytable", "newtable") }).
+		Panics("schema change: can't get exclusive access to mytable")
+	assert.This(ut.Complete()).Is("")
+
+	ut = db.NewUpdateTran() // started before the rename
+	assert.That(db.RenameTable("mytable", "newtable"))
+	assert.This(func() { ut.Output("mytable", mkrec("2")) }).
+		Panics("conflict with index creation")
+	ut = db.NewUpdateTran()
+	ut.Output("newtable", mkrec("2"))
+	assert.This(ut.Complete()).Is("")
+	assert.That(db.AlterRename("newtable", []string{"one"}, []string{"uno"}))
+	assert.This(db.Schema("newtable")).Is("newtable (uno,two) key(uno)")
+	assert.This(db.NewReadTran().GetInfo("newtable").Nrows).Is(2)
+}
+
+func TestTriggers(t *testing.T) {
+	assert := assert.T(t)
+	db, err := CreateDb(stor.HeapStor(8192))
+	ck(err)
+	StartConcur(db, time.Hour)
+	defer db.Close()
+	createTbl(db)
+	th := &rt.Thread{}
+	var calls []string
+	rt.Global.TestDef("Trigger_mytable", &rt.SuBuiltin3{
+		Fn: func(tran, oldrec, newrec rt.Value) rt.Value {
+			s := "nil"
+			if newrec != rt.False {
+				s = rt.ToStr(newrec.Get(th, rt.SuStr("one")))
+			}
+			if s == "bad" {
+				panic("rejected")
+			}
+			calls = append(calls, s)
+			return nil
+		},
+		BuiltinParams: rt.BuiltinParams{ParamSpec: rt.ParamSpec{
+			Nparams: 3, Flags: []rt.Flag{0, 0, 0}}}})
+	defer rt.Global.TestDef("Trigger_mytable", nil)
+
+	ut := db.NewUpdateTran()
+	ut.Output("mytable", mkrec("1"))
+	assert.This(ut.Complete()).Is("")
+	assert.This(calls).Is([]string{"1"})
+
+	// a failed trigger aborts the transaction
+	ut = db.NewUpdateTran()
+	ut.Output("mytable", mkrec("2"))
+	assert.This(func() { ut.Output("mytable", mkrec("bad")) }).
+		Panics("rejected (Trigger_mytable)")
+	assert.This(ut.Complete()).
+		Is("can't Complete a transaction after failure or Abort")
+	assert.This(db.NewReadTran().GetInfo("mytable").Nrows).Is(1)
+
+	// disabled triggers are counted
+	db.DisableTrigger("mytable")
+	db.DisableTrigger("mytable")
+	db.EnableTrigger("mytable")
+	ut = db.NewUpdateTran()
+	ut.Output("mytable", mkrec("bad"))
+	assert.This(ut.Complete()).Is("")
+	db.EnableTrigger("mytable")
+	ut = db.NewUpdateTran()
+	ut.Output("mytable", mkrec("3"))
+	assert.This(ut.Complete()).Is("")
+	assert.This(calls).Is([]string{"1", "2", "3"})
+	assert.This(func() { db.EnableTrigger("mytable") }).Panics("assert failed")
+}
+
+func TestAutoNumber(t *testing.T) {
+	assert := assert.T(t)
+	defer func(n int) { AutoNumberBlock = n }(AutoNumberBlock)
+	AutoNumberBlock = 5
+	db, err := CreateDb(stor.HeapStor(8192))
+	ck(err)
+	StartConcur(db, time.Hour)
+	defer db.Close()
+	db.Create(&schema.Schema{
+		Table:      "nums",
+		Columns:    []string{"num", "name"},
+		Indexes:    []schema.Index{{Mode: 'k', Columns: []string{"num"}}},
+		AutoNumber: "num",
+	})
+	output := func(ut *UpdateTran, num, name string) {
+		var b rt.RecordBuilder
+		if n, err := strconv.Atoi(num); err == nil {
+			b.Add(rt.IntVal(n).(rt.Packable))
+		} else {
+			b.AddRaw("")
+		}
+		b.Add(rt.SuStr(name))
+		ut.Output("nums", b.Build())
+	}
+	nums := func() []int {
+		var list []int
+		tran := db.NewReadTran()
+		iter := index.NewOverIter("nums", 0)
+		for iter.Next(tran); !iter.Eof(); iter.Next(tran) {
+			_, off := iter.Cur()
+			n, _ := tran.GetRecord(off).GetVal(0).IfInt()
+			list = append(list, n)
+		}
+		return list
+	}
+
+	ut := db.NewUpdateTran()
+	output(ut, "", "a")
+	output(ut, "", "b")
+	ut2 := db.NewUpdateTran()
+	output(ut2, "", "c") // concurrent transactions share the block
+	assert.This(ut.Complete()).Is("")
+	assert.This(ut2.Complete()).Is("")
+	assert.This(nums()).Is([]int{1, 2, 3})
+	assert.This(db.NewReadTran().GetInfo("nums").Seq).Is(6)
+
+	// explicit numbers are skipped
+	ut = db.NewUpdateTran()
+	output(ut, "4", "d")
+	output(ut, "", "e")
+	output(ut, "", "f") // reserves a new block
+	output(ut, "20", "g")
+	output(ut, "", "h")
+	assert.This(ut.Complete()).Is("")
+	assert.This(nums()).Is([]int{1, 2, 3, 4, 5, 6, 20, 21})
+
+	// aborted numbers are not reused
+	ut = db.NewUpdateTran()
+	output(ut, "", "i")
+	ut.Abort()
+
+	// e.g. restart
+	db.sequences = sequences{}
+	ut = db.NewUpdateTran()
+	output(ut, "", "j")
+	assert.This(ut.Complete()).Is("")
+	assert.This(nums()).Is([]int{1, 2, 3, 4, 5, 6, 20, 21, 26})
+}
@@ -18,6 +18,8 @@ type triggers struct {
 // MakeSuTran is injected by dbms to avoid import cycle
 var MakeSuTran func(tran *UpdateTran) *SuTran
 
+// DisableTrigger is used by DoWithoutTriggers.
+// It is counted so it can be nested.
 func (t *triggers) DisableTrigger(table string) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
@@ -30,8 +32,10 @@ func (t *triggers) DisableTrigger(table string) {
 func (t *triggers) EnableTrigger(table string) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
-	t.disabled[table]--
-	assert.That(t.disabled[table] >= 0)
+	assert.That(t.disabled[table] > 0)
+	if t.disabled[table]--; t.disabled[table] == 0 {
+		delete(t.disabled, table)
+	}
 }
 
 func (t *triggers) enabled(table string) bool {
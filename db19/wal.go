@@ -0,0 +1,429 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	rt "github.com/apmckinlay/gsuneido/runtime"
+)
+
+// The write ahead log (WAL) is optional.
+// Without it, a crash loses the commits since the last persist.
+// With it, each commit appends the transaction's actions
+// (output, delete, update with the full records) to the log,
+// and UpdateTran.Complete waits for the log to be synced to disk.
+// Concurrent commits share a single sync (group commit).
+//
+// Commits are numbered (seq) in commit order.
+// Persist writes a pre-persist record with the last merged seq
+// and then a checkpoint record with the offset of the persisted state.
+// On startup OpenWal finds the seq for the database state
+// and replays the commits after it.
+// If the database state is not in a checkpoint (crash during persist)
+// then the last record must be a pre-persist.
+//
+// Each checkpoint rewrites the log, keeping the previous checkpoint
+// and the commits after it, in case repair has to go back a state.
+//
+// NOTE: Schema changes are not logged, they are durable after the next persist.
+// Replay skips actions for tables that do not exist.
+//
+// Log record format:
+//	length		4 bytes (of type and data)
+//	type		1 byte
+//	data
+//	crc32		4 bytes (of type and data)
+
+const (
+	walCommit     = 'c' // seq, actions
+	walPrePersist = 'p' // seq
+	walCheckpoint = 's' // state offset, seq
+)
+
+// Action types
+const (
+	walOutput = 'o' // rec
+	walDelete = 'd' // oldrec
+	walUpdate = 'u' // oldrec, newrec
+)
+
+type wal struct {
+	filename string
+	// syncLock is held while syncing or rewriting the file.
+	// If both locks are needed, syncLock must be acquired first.
+	syncLock sync.Mutex
+	synced   uint64
+	// lock guards appending
+	lock sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	seq  uint64
+	// pending are the commits after the previous checkpoint
+	pending []walRec
+	prevCk  walCk
+	// merged is the seq of the last merged commit, accessed atomically
+	merged uint64
+}
+
+type walRec struct {
+	seq  uint64
+	data []byte
+}
+
+type walCk struct {
+	off uint64
+	seq uint64
+}
+
+// OpenWal enables the write ahead log.
+// If the log exists, the commits after the current state are replayed.
+// It should be called at startup, after StartConcur,
+// before any other update transactions.
+func (db *Database) OpenWal(filename string) error {
+	if db.wal != nil {
+		return errors.New("wal: already open")
+	}
+	n, err := db.replayWal(filename)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Println("wal: replayed", n, "commits")
+	}
+	state := db.Persist()
+	w := &wal{filename: filename}
+	// the persisted state includes everything, so start from seq 0
+	if err := w.rewrite(walCk{}, walCk{off: state.stateOff}); err != nil {
+		return err
+	}
+	db.wal = w
+	return nil
+}
+
+// replayWal applies the commits in the log after the current state
+func (db *Database) replayWal(filename string) (int, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	commits, base, err := readWal(f, db.GetState().stateOff)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, c := range commits {
+		if c.seq > base {
+			db.replayCommit(c.data)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// readWal returns the commits in the log
+// and the seq corresponding to the state at stateOff.
+// A partially written record at the end of the log is ignored.
+func readWal(r io.Reader, stateOff uint64) (commits []walRec, base uint64,
+	err error) {
+	br := bufio.NewReader(r)
+	found := false
+	last := byte(0)
+	var pre uint64
+	for {
+		typ, data := readWalRec(br)
+		if typ == 0 {
+			break
+		}
+		switch typ {
+		case walCommit:
+			seq, n := binary.Uvarint(data)
+			commits = append(commits, walRec{seq: seq, data: data[n:]})
+		case walPrePersist:
+			pre, _ = binary.Uvarint(data)
+			last = typ
+		case walCheckpoint:
+			off, n := binary.Uvarint(data)
+			seq, _ := binary.Uvarint(data[n:])
+			if off == stateOff {
+				base, found = seq, true
+			}
+			last = typ
+		}
+	}
+	if !found {
+		if last != walPrePersist {
+			return nil, 0,
+				errors.New("wal: database state not found in write ahead log")
+		}
+		base = pre // crashed during persist
+	}
+	return commits, base, nil
+}
+
+// readWalRec returns a type of 0 at the end or if the record is incomplete
+func readWalRec(br *bufio.Reader) (byte, []byte) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return 0, nil
+	}
+	buf := make([]byte, binary.BigEndian.Uint32(hdr[:])+4)
+	if _, err := io.ReadFull(br, buf); err != nil || len(buf) < 5 {
+		return 0, nil
+	}
+	n := len(buf) - 4
+	if crc32.Checksum(buf[:n], crcTable) != binary.BigEndian.Uint32(buf[n:]) {
+		return 0, nil
+	}
+	return buf[0], buf[1:n]
+}
+
+func (db *Database) replayCommit(data []byte) {
+	ut := db.NewUpdateTran()
+	ut.replay = true
+	for len(data) > 0 {
+		op := data[0]
+		data = data[1:]
+		var table, rec, rec2 string
+		table, data = walGet(data)
+		rec, data = walGet(data)
+		if op == walUpdate {
+			rec2, data = walGet(data)
+		}
+		if ut.meta.GetRoSchema(table) == nil {
+			log.Println("wal: replay skipped nonexistent table:", table)
+			continue
+		}
+		switch op {
+		case walOutput:
+			ut.Output(table, rt.Record(rec))
+		case walDelete:
+			ut.Delete(table, ut.walFind(table, rt.Record(rec)))
+		case walUpdate:
+			ut.Update(table, ut.walFind(table, rt.Record(rec)), rt.Record(rec2))
+		default:
+			panic("wal: replay unknown action")
+		}
+	}
+	ut.Commit()
+}
+
+// walFind returns the offset of the current version of a record
+// by looking it up with its first key
+func (t *UpdateTran) walFind(table string, rec rt.Record) uint64 {
+	ts := t.getSchema(table)
+	for i := range ts.Indexes {
+		if ts.Indexes[i].Mode == 'k' {
+			if dbrec := t.Lookup(table, i, ts.Indexes[i].Ixspec.Key(rec)); dbrec != nil {
+				return dbrec.Off
+			}
+			break
+		}
+	}
+	panic("wal: replay record not found in " + table)
+}
+
+func walGet(data []byte) (string, []byte) {
+	n, i := binary.Uvarint(data)
+	end := i + int(n)
+	return string(data[i:end]), data[end:]
+}
+
+// walAct adds an action to the transaction's log data
+func (t *UpdateTran) walAct(op byte, table string, recs ...rt.Record) {
+	if t.db.wal == nil || t.replay {
+		return
+	}
+	t.walData = append(t.walData, op)
+	t.walData = walPut(t.walData, table)
+	for _, rec := range recs {
+		t.walData = walPut(t.walData, string(rec))
+	}
+}
+
+func walPut(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendUvarint(buf []byte, n uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], n)]...)
+}
+
+//-------------------------------------------------------------------
+
+// append adds a commit to the log (buffered) and returns its seq.
+// It is called from UpdateTran.commit so commits are in order.
+func (w *wal) append(data []byte) uint64 {
+	if w == nil || len(data) == 0 {
+		return 0
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.seq++
+	buf := appendUvarint(make([]byte, 0, len(data)+10), w.seq)
+	buf = append(buf, data...)
+	w.pending = append(w.pending, walRec{seq: w.seq, data: buf})
+	w.write(walCommit, buf)
+	return w.seq
+}
+
+func (w *wal) write(typ byte, data []byte) {
+	var hdr [5]byte
+	binary.BigEndian.PutUint32(hdr[:], uint32(len(data)+1))
+	hdr[4] = typ
+	w.w.Write(hdr[:])
+	w.w.Write(data)
+	crc := crc32.Update(crc32.Checksum(hdr[4:], crcTable), crcTable, data)
+	binary.BigEndian.PutUint32(hdr[:], crc)
+	w.w.Write(hdr[:4])
+}
+
+// sync waits till the log is on disk up to seq
+func (w *wal) sync(seq uint64) {
+	if w == nil || seq == 0 {
+		return
+	}
+	w.syncLock.Lock()
+	defer w.syncLock.Unlock()
+	if w.synced >= seq {
+		return // another commit's sync included this one
+	}
+	w.lock.Lock()
+	n := w.seq
+	err := w.w.Flush()
+	w.lock.Unlock()
+	if err == nil {
+		err = w.file.Sync()
+	}
+	if err != nil {
+		panic("wal: " + err.Error())
+	}
+	w.synced = n
+}
+
+// setMerged is called after merging the commits up to seq
+func (w *wal) setMerged(seq uint64) {
+	if w != nil && seq > atomic.LoadUint64(&w.merged) {
+		atomic.StoreUint64(&w.merged, seq)
+	}
+}
+
+// mergedSeq returns the seq of the last merged commit,
+// all of which will be included in a persist.
+func (w *wal) mergedSeq() uint64 {
+	if w == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&w.merged)
+}
+
+// prePersist records the seq for a persist that is about to be written
+func (w *wal) prePersist(seq uint64) {
+	if w == nil {
+		return
+	}
+	w.syncLock.Lock()
+	defer w.syncLock.Unlock()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.write(walPrePersist, appendUvarint(nil, seq))
+	w.flush()
+}
+
+// checkpoint records a persisted state.
+// It rewrites the log without the commits before the previous checkpoint.
+// reset (for compaction) discards the previous checkpoint.
+func (w *wal) checkpoint(off, seq uint64, reset bool) {
+	if w == nil {
+		return
+	}
+	w.syncLock.Lock()
+	defer w.syncLock.Unlock()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	prev := w.prevCk
+	if reset {
+		prev = walCk{}
+	}
+	if err := w.rewrite(prev, walCk{off: off, seq: seq}); err != nil {
+		panic("wal: " + err.Error())
+	}
+}
+
+// rewrite writes a new log file with the given checkpoints
+// and the pending commits after prev,
+// and then replaces the old file with it.
+// The caller must hold syncLock and lock (if the wal is in use).
+func (w *wal) rewrite(prev, cur walCk) error {
+	tmpfile := w.filename + ".tmp"
+	f, err := os.Create(tmpfile)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	old := w.file
+	w.file, w.w = f, bw
+	ckData := func(ck walCk) []byte {
+		return appendUvarint(appendUvarint(nil, ck.off), ck.seq)
+	}
+	if prev.off != 0 {
+		w.write(walCheckpoint, ckData(prev))
+	}
+	w.write(walCheckpoint, ckData(cur))
+	pending := w.pending[:0]
+	for _, c := range w.pending {
+		if c.seq > prev.seq {
+			w.write(walCommit, c.data)
+			pending = append(pending, c)
+		}
+	}
+	w.pending = pending
+	w.prevCk = cur
+	if err = w.flush(); err == nil {
+		err = os.Rename(tmpfile, w.filename)
+	}
+	if old != nil {
+		old.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("rewrite: %w", err)
+	}
+	w.synced = w.seq
+	return nil
+}
+
+func (w *wal) flush() error {
+	err := w.w.Flush()
+	if err == nil {
+		err = w.file.Sync()
+	}
+	return err
+}
+
+func (w *wal) close() {
+	if w == nil {
+		return
+	}
+	w.syncLock.Lock()
+	defer w.syncLock.Unlock()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.flush()
+	w.file.Close()
+}
@@ -0,0 +1,113 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package db19
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/apmckinlay/gsuneido/db19/index"
+	rt "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestWal(t *testing.T) {
+	assert := assert.T(t)
+	files := []string{"tmp.db", "tmp.wal", "tmp.bk0", "tmp2.db", "tmp2.wal"}
+	defer func() {
+		for _, f := range files {
+			os.Remove(f)
+		}
+	}()
+	db := createDb()
+	StartConcur(db, time.Hour)
+	ck(db.OpenWal("tmp.wal"))
+	output := func(n int) {
+		for i := 0; i < n; i++ {
+			ut := output1(db)
+			assert.This(ut.Complete()).Is("")
+		}
+	}
+	output(10)
+	_, err := db.Backup("tmp.bk0", 0) // persists
+	ck(err)
+	output(5)
+	// delete one and update one
+	ut := db.NewUpdateTran()
+	iter := index.NewOverIter("mytable", 0)
+	iter.Next(ut)
+	_, off := iter.Cur()
+	ut.Delete("mytable", off)
+	iter.Next(ut)
+	_, off = iter.Cur()
+	ut.Update("mytable", off, mkrec("updated", "data"))
+	assert.This(ut.Complete()).Is("")
+	// simulate a crash by copying the log before Close persists
+	data, err := ioutil.ReadFile("tmp.wal")
+	ck(err)
+	ck(ioutil.WriteFile("tmp2.wal", data, 0666))
+	db.Close()
+
+	// the last persisted state plus the log
+	ck(Restore("tmp2.db", "tmp.bk0"))
+	db, err = OpenDatabase("tmp2.db")
+	ck(err)
+	assert.This(db.NewReadTran().GetInfo("mytable").Nrows).Is(10)
+	StartConcur(db, time.Hour)
+	ck(db.OpenWal("tmp2.wal"))
+	tran := db.NewReadTran()
+	assert.This(count(tran, "mytable")).Is(14)
+	assert.This(tran.GetInfo("mytable").Nrows).Is(14)
+	assert.That(tran.Lookup("mytable", 0, rt.Pack(rt.SuStr("updated"))) != nil)
+	// replaying again does nothing since OpenWal persists
+	db.Close()
+	db, err = OpenDatabase("tmp2.db")
+	ck(err)
+	StartConcur(db, time.Hour)
+	ck(db.OpenWal("tmp2.wal"))
+	assert.This(count(db.NewReadTran(), "mytable")).Is(14)
+	db.Close()
+	ck(CheckDatabase("tmp2.db"))
+}
+
+func TestReadWal(t *testing.T) {
+	assert := assert.T(t)
+	var buf bytes.Buffer
+	w := &wal{w: bufio.NewWriter(&buf)}
+	ckrec := func(off, seq uint64) {
+		w.write(walCheckpoint, appendUvarint(appendUvarint(nil, off), seq))
+	}
+	ckrec(100, 0)
+	w.append([]byte("one"))
+	w.append([]byte("two"))
+	w.write(walPrePersist, appendUvarint(nil, 1))
+	ckrec(200, 1)
+	w.append([]byte("three"))
+	w.write(walPrePersist, appendUvarint(nil, 3))
+	w.w.Flush()
+	data := buf.Bytes()
+
+	test := func(off uint64, expected uint64) {
+		t.Helper()
+		commits, base, err := readWal(bytes.NewReader(data), off)
+		ck(err)
+		assert.This(len(commits)).Is(3)
+		assert.This(base).Is(expected)
+	}
+	test(100, 0)
+	test(200, 1)
+	test(300, 3) // crashed during persist
+	// partial record at the end is ignored
+	commits, _, err := readWal(bytes.NewReader(data[:len(data)-3]), 200)
+	ck(err)
+	assert.This(len(commits)).Is(3)
+	assert.This(string(commits[2].data)).Is("three")
+	// state not found
+	_, _, err = readWal(bytes.NewReader(data[:len(data)-12]), 300)
+	assert.This(err).Isnt(nil)
+}
@@ -134,6 +134,10 @@ func (dc *dbmsClient) Close() {
 	dc.conn.Close()
 }
 
+func (dc *dbmsClient) Compact() string {
+	panic("Database.Compact can't be used by a client")
+}
+
 func (dc *dbmsClient) Connections() Value {
 	dc.PutCmd(commands.Connections).Request()
 	ob := dc.GetVal().(*SuObject)
@@ -273,6 +277,22 @@ func (dc *dbmsClient) Token() string {
 	return dc.GetStr()
 }
 
+func (dc *dbmsClient) Snapshot(string) string {
+	panic("Database.Snapshot can't be used by a client")
+}
+
+func (dc *dbmsClient) SnapshotTran(string) ITran {
+	panic("Transaction(snapshot:) can't be used by a client")
+}
+
+func (dc *dbmsClient) TranLimits(*SuObject) *SuObject {
+	panic("Database.TranLimits can't be used by a client")
+}
+
+func (dc *dbmsClient) ReleaseSnapshot(string) bool {
+	panic("Database.ReleaseSnapshot can't be used by a client")
+}
+
 func (dc *dbmsClient) Transaction(update bool) ITran {
 	dc.PutCmd(commands.Transaction).PutBool(update).Request()
 	tn := dc.GetInt()
@@ -388,6 +408,10 @@ func (tc *TranClient) Update(_ string, off uint64, rec Record) uint64 {
 	return uint64(tc.dc.GetInt())
 }
 
+func (tc *TranClient) SetTranLimits(*SuObject) {
+	panic("Transaction(limits:) can't be used by a client")
+}
+
 func (tc *TranClient) WriteCount() int {
 	tc.dc.PutCmd(commands.WriteCount).PutInt(tc.tn).Request()
 	return tc.dc.GetInt()
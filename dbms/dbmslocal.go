@@ -35,6 +35,9 @@ var _ IDbms = (*DbmsLocal)(nil)
 
 func (dbms *DbmsLocal) Admin(admin string) {
 	trace.Dbms.Println("Admin", admin)
+	if dbms.db.ReadOnly() {
+		panic("can't modify a read-only database (replica)")
+	}
 	qry.DoAdmin(dbms.db, admin)
 }
 
@@ -49,6 +52,13 @@ func (dbms *DbmsLocal) Check() string {
 	return ""
 }
 
+func (dbms *DbmsLocal) Compact() string {
+	if _, err := dbms.db.Compact(); err != nil {
+		return fmt.Sprint(err)
+	}
+	return ""
+}
+
 func (*DbmsLocal) Connections() Value {
 	return EmptyObject
 }
@@ -133,8 +143,8 @@ func (*DbmsLocal) Kill(string) int {
 	panic("DbmsLocal Kill not implemented")
 }
 
-func (*DbmsLocal) Load(string) int {
-	panic("DbmsLocal Load not implemented")
+func (dbms *DbmsLocal) Load(table string) int {
+	return tools.LoadDbTable(dbms.db, table)
 }
 
 func (dbms *DbmsLocal) LibGet(name string) (result []string) {
@@ -219,8 +229,61 @@ func (*DbmsLocal) Token() string {
 	return "1234567890123456" //TODO
 }
 
+func (dbms *DbmsLocal) Snapshot(name string) string {
+	if err := dbms.db.Snapshot(name); err != nil {
+		return fmt.Sprint(err)
+	}
+	return ""
+}
+
+func (dbms *DbmsLocal) SnapshotTran(name string) ITran {
+	if t := dbms.db.NewSnapshotTran(name); t != nil {
+		return &ReadTranLocal{t}
+	}
+	return nil
+}
+
+func (dbms *DbmsLocal) ReleaseSnapshot(name string) bool {
+	return dbms.db.ReleaseSnapshot(name)
+}
+
+func (dbms *DbmsLocal) TranLimits(limits *SuObject) *SuObject {
+	lim := dbms.db.TranLimits()
+	if limits != nil {
+		lim = toTranLimits(lim, limits)
+		dbms.db.SetTranLimits(lim)
+	}
+	return fromTranLimits(lim)
+}
+
+// toTranLimits returns lim with the values from the members of ob
+func toTranLimits(lim db19.TranLimits, ob *SuObject) db19.TranLimits {
+	get := func(mem string, n *int) {
+		if x := ob.GetIfPresent(nil, SuStr(mem)); x != nil {
+			*n = ToInt(x)
+		}
+	}
+	get("MaxAge", &lim.MaxAge)
+	get("MaxWrites", &lim.MaxWrites)
+	get("WarnAge", &lim.WarnAge)
+	get("WarnWrites", &lim.WarnWrites)
+	return lim
+}
+
+func fromTranLimits(lim db19.TranLimits) *SuObject {
+	ob := &SuObject{}
+	ob.Set(SuStr("MaxAge"), IntVal(lim.MaxAge))
+	ob.Set(SuStr("MaxWrites"), IntVal(lim.MaxWrites))
+	ob.Set(SuStr("WarnAge"), IntVal(lim.WarnAge))
+	ob.Set(SuStr("WarnWrites"), IntVal(lim.WarnWrites))
+	return ob
+}
+
 func (dbms *DbmsLocal) Transaction(update bool) ITran {
 	if update {
+		if dbms.db.ReadOnly() {
+			panic("can't update a read-only database (replica)")
+		}
 		if t := dbms.db.NewUpdateTran(); t != nil {
 			return &UpdateTranLocal{t}
 		}
@@ -295,6 +358,10 @@ func (t ReadTranLocal) Action(string) int {
 	panic("cannot do action in read-only transaction")
 }
 
+func (t ReadTranLocal) SetTranLimits(*SuObject) {
+	panic("cannot set limits on read-only transaction")
+}
+
 // UpdateTranLocal --------------------------------------------------------
 
 type UpdateTranLocal struct {
@@ -316,6 +383,10 @@ func (t UpdateTranLocal) Action(action string) int {
 	return qry.DoAction(t.UpdateTran, action)
 }
 
+func (t UpdateTranLocal) SetTranLimits(limits *SuObject) {
+	t.SetLimits(toTranLimits(t.Limits(), limits))
+}
+
 // queryLocal
 
 type queryLocal struct {
@@ -68,8 +68,7 @@ func (a *insertQueryAction) execute(ut *db19.UpdateTran) int {
 				rb.AddRaw(row.GetRaw(hdr, f))
 			}
 		}
-		rec := rb.Trim().Build()
-		ut.Output(a.table, rec)
+		ut.OutputBuilder(a.table, rb.Trim())
 		n++
 	}
 	return n
@@ -22,7 +22,7 @@ func checkForSystemTable(op, table string) {
 
 func isSystemTable(table string) bool {
 	switch table {
-	case "tables", "columns", "indexes", "views":
+	case "tables", "columns", "indexes", "views", "table_stats":
 		return true
 	}
 	return false
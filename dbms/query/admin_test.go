@@ -53,6 +53,10 @@ func TestAdminEnsure(t *testing.T) {
 	DoAdmin(db, "ensure tmp2 "+tmpschema)
 	assert.T(t).This(db.Schema("tmp2")).Is("tmp2 " + tmpschema)
 
+	// limits
+	DoAdmin(db, "ensure tmp2 (a) maxrows(100)")
+	assert.T(t).This(db.Schema("tmp2")).Is("tmp2 " + tmpschema + " maxrows(100)")
+
 	// existing index but different
 	assert.T(t).This(func() { DoAdmin(db, "ensure tmp index unique(b,c)")}).
 		Panics(("Ensure: index exists but is different"))
@@ -85,6 +89,10 @@ func TestAdminAlterCreate(t *testing.T) {
 	DoAdmin(db, "alter tmp create (x) index(x)")
 	assert.T(t).This(db.Schema("tmp")).
 		Is("tmp (a,b,c,d,x) key(a) index(b,c) index(x)")
+	DoAdmin(db, "alter tmp create maxrows(10) maxsize(1000)")
+	DoAdmin(db, "alter tmp create maxrows(20)")
+	assert.T(t).This(db.Schema("tmp")).
+		Is("tmp (a,b,c,d,x) key(a) index(b,c) index(x) maxrows(20) maxsize(1000)")
 }
 
 func TestAdminAlterRename(t *testing.T) {
@@ -227,6 +235,19 @@ func TestFkey(t *testing.T) {
 	schemas["lin"] = "lin (c,d) key(c) from newfour(hh) index(d) in hdr(a)"
 	check()
 
+	// rename a table and a column that foreign keys point to
+	DoAdmin(db, "rename lin to newlin")
+	schemas["newlin"] = "newlin (c,d) key(c) from newfour(hh) index(d) in hdr(a)"
+	schemas["lin"] = ""
+	schemas["newfour"] = "newfour (g,hh) key(g) index(hh) in newlin(c)"
+	schemas["hdr"] = "hdr (a,b) key(a) from newlin(d) from two(f)"
+	check()
+
+	DoAdmin(db, "alter newlin rename c to cc")
+	schemas["newlin"] = "newlin (cc,d) key(cc) from newfour(hh) index(d) in hdr(a)"
+	schemas["newfour"] = "newfour (g,hh) key(g) index(hh) in newlin(cc)"
+	check()
+
 	assert.T(t).This(func() { DoAdmin(db, "drop hdr") }).
 		Panics("can't drop table used by foreign keys")
 
@@ -234,6 +255,17 @@ func TestFkey(t *testing.T) {
 	DoAdmin(db, "create recur (a,b) key(a) index(b) in recur(a)")
 	schemas["recur"] = "recur (a,b) key(a) from recur(b) index(b) in recur(a)"
 	check()
+	DoAdmin(db, "rename recur to recur2")
+	schemas["recur2"] = "recur2 (a,b) key(a) from recur2(b) index(b) in recur2(a)"
+	delete(schemas, "recur")
+	check()
+	DoAdmin(db, "alter recur2 rename a to x, b to y")
+	schemas["recur2"] = "recur2 (x,y) key(x) from recur2(y) index(y) in recur2(x)"
+	check()
+	DoAdmin(db, "rename recur2 to recur")
+	delete(schemas, "recur2")
+	schemas["recur"] = "recur (x,y) key(x) from recur(y) index(y) in recur(x)"
+	check()
 	DoAdmin(db, "drop recur") // has FkToHere, but only to itself
 	delete(schemas, "recur")
 	check()
@@ -4,6 +4,7 @@
 package query
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/apmckinlay/gsuneido/compile"
@@ -28,6 +29,7 @@ func NewAdminParser(src string) *adminParser {
 
 type Schema = schema.Schema
 type Index = schema.Index
+type Check = schema.Check
 
 type Admin interface {
 	execute(db *db19.Database)
@@ -77,7 +79,11 @@ func (p *adminParser) alter() Admin {
 	case p.MatchIf(tok.Create):
 		return &alterCreateAdmin{p.schema2(table, false)}
 	case p.MatchIf(tok.Drop):
-		return &alterDropAdmin{p.schema2(table, false)}
+		sc := p.schema2(table, false)
+		if sc.MaxRows != 0 || sc.MaxSize != 0 {
+			p.Error("can't drop maxrows or maxsize, use alter create")
+		}
+		return &alterDropAdmin{sc}
 	case p.MatchIf(tok.Rename):
 		return p.alterRename(table)
 	default:
@@ -115,12 +121,137 @@ func (p *adminParser) schema(full bool) Schema {
 }
 
 func (p *adminParser) schema2(table string, full bool) Schema {
-	columns, derived := p.columns(full)
+	columns, derived, defaults := p.columns(full)
 	indexes := p.indexes(columns, derived, full)
-	return Schema{Table: table, Columns: columns, Derived: derived, Indexes: indexes}
+	sc := Schema{Table: table, Columns: columns, Derived: derived,
+		Indexes: indexes, Defaults: defaults}
+	if full {
+		p.tableOptions(&sc)
+	} else {
+		p.limits(&sc)
+	}
+	p.checks(&sc, full)
+	return sc
+}
+
+// tableOptions parses the optional table attributes following the indexes
+// e.g. compressed maxrows(1000) maxsize(1000000) autonumber(num) history
+func (p *adminParser) tableOptions(sc *Schema) {
+	for p.Token.IsIdent() {
+		switch p.Text {
+		case "compressed":
+			p.Next()
+			sc.Compressed = true
+		case "maxrows":
+			sc.MaxRows = int(p.limit())
+		case "maxsize":
+			sc.MaxSize = p.limit()
+		case "autonumber":
+			sc.AutoNumber = p.autoNumber(sc)
+		case "history":
+			p.Next()
+			sc.History = true
+		default:
+			return
+		}
+	}
+}
+
+// limits parses the table options allowed by ensure and alter create
+// i.e. maxrows(n) and maxsize(n)
+func (p *adminParser) limits(sc *Schema) {
+	for p.Token.IsIdent() {
+		switch p.Text {
+		case "maxrows":
+			sc.MaxRows = int(p.limit())
+		case "maxsize":
+			sc.MaxSize = p.limit()
+		default:
+			return
+		}
+	}
+}
+
+// limit parses (number) following maxrows or maxsize
+func (p *adminParser) limit() uint64 {
+	opt := p.Text
+	p.Next()
+	p.Match(tok.LParen)
+	n, err := strconv.ParseUint(p.Text, 10, 63)
+	if p.Token != tok.Number || err != nil || n == 0 {
+		p.Error("invalid " + opt)
+	}
+	p.Next()
+	p.Match(tok.RParen)
+	return n
+}
+
+// checks parses check constraints following the table options
+// e.g. check(n > 0) or check positive(n > 0)
+// Unnamed checks are named by the database (see meta NameChecks).
+// For alter drop only the name is required.
+func (p *adminParser) checks(sc *Schema, full bool) {
+	for p.Token.IsIdent() && p.Text == "check" {
+		p.Next()
+		name := ""
+		if p.Token.IsIdent() {
+			name = p.MatchIdent()
+		}
+		expr := ""
+		if full || p.Token == tok.LParen {
+			expr = p.checkExpr()
+		}
+		sc.Checks = append(sc.Checks, Check{Name: name, Expr: expr})
+	}
+}
+
+// checkExpr returns the source of a parenthesized check expression.
+// It is parsed and validated by the database.
+func (p *adminParser) checkExpr() string {
+	p.Match(tok.LParen)
+	start := p.Pos
+	for nest := 0; ; p.Next() {
+		if p.Token == tok.RParen {
+			if nest == 0 {
+				break
+			}
+			nest--
+		} else if p.Token == tok.LParen {
+			nest++
+		} else if p.Token == tok.Eof {
+			p.Error("check missing close paren")
+		}
+	}
+	expr := strings.TrimSpace(p.Lxr.Source()[start:p.Pos])
+	p.Match(tok.RParen)
+	if expr == "" {
+		p.Error("check expression must not be empty")
+	}
+	return expr
 }
 
-func (p *adminParser) columns(full bool) (columns, derived []string) {
+// autoNumber parses (column) following autonumber.
+// The column must be a key so the numbers are unique.
+func (p *adminParser) autoNumber(sc *Schema) string {
+	p.Next()
+	p.Match(tok.LParen)
+	col := p.MatchIdent()
+	p.Match(tok.RParen)
+	if !strs.Contains(sc.Columns, col) {
+		p.Error("autonumber nonexistent column: " + col)
+	}
+	for i := range sc.Indexes {
+		ix := &sc.Indexes[i]
+		if ix.Mode == 'k' && strs.Equal(ix.Columns, []string{col}) {
+			return col
+		}
+	}
+	p.Error("autonumber column must be a key: " + col)
+	return ""
+}
+
+func (p *adminParser) columns(full bool) (columns, derived []string,
+	defaults map[string]string) {
 	if !full && p.Token != tok.LParen {
 		return
 	}
@@ -142,19 +273,50 @@ func (p *adminParser) columns(full bool) (columns, derived []string) {
 				derived = append(derived, col)
 			} else {
 				columns = append(columns, col)
+				if p.MatchIf(tok.Eq) {
+					if defaults == nil {
+						defaults = make(map[string]string)
+					}
+					defaults[col] = p.defaultExpr(col)
+				}
 			}
 
 		}
 		p.MatchIf(tok.Comma)
 	}
 	p.Match(tok.RParen)
-	return columns, derived
+	return columns, derived, defaults
+}
+
+// defaultExpr returns the source of a column default
+// i.e. up to the next comma or close paren that is not nested
+func (p *adminParser) defaultExpr(col string) string {
+	start := p.Pos
+	for nest := 0; nest > 0 ||
+		(p.Token != tok.Comma && p.Token != tok.RParen); p.Next() {
+		switch p.Token {
+		case tok.LParen, tok.LBracket, tok.LCurly:
+			nest++
+		case tok.RParen, tok.RBracket, tok.RCurly:
+			nest--
+		case tok.Eof:
+			p.Error("default missing close paren")
+		}
+	}
+	def := strings.TrimSpace(p.Lxr.Source()[start:p.Pos])
+	if def == "" {
+		p.Error("default " + col + " must not be empty")
+	}
+	return def
 }
 
 func (p *adminParser) indexes(columns, derived []string, full bool) []Index {
 	hasKey := false
 	indexes := make([]Index, 0, 4)
 	for ix := p.index(columns, derived, full); ix != nil; ix = p.index(columns, derived, full) {
+		if full && len(indexes) == 0 && ix.Mode == 't' {
+			p.Error("text index must not be the first index")
+		}
 		indexes = append(indexes, *ix)
 		hasKey = hasKey || ix.Mode == 'k'
 	}
@@ -175,22 +337,45 @@ func (p *adminParser) index(columns, derived []string, full bool) *Index {
 	p.Next()
 	if mode != 'k' && p.MatchIf(tok.Unique) {
 		mode = 'u'
+	} else if mode != 'k' && p.Token.IsIdent() && p.Text == "text" {
+		p.Next()
+		mode = 't'
 	}
-	ixcols := p.indexColumns(columns, derived, full)
+	ixcols, desc := p.indexColumns(columns, derived, full)
 	if mode != 'k' && len(ixcols) == 0 {
 		p.Error("index columns must not be empty")
 	}
-	ix := &Index{Columns: ixcols, Mode: mode}
+	if desc != nil && mode != 'i' && mode != 'u' {
+		p.Error("only index columns can be descending")
+	}
+	storing := p.storing(columns, ixcols, full)
+	if storing != nil && mode != 'i' {
+		p.Error("only index can have storing columns")
+	}
+	if mode == 't' {
+		if len(ixcols) != 1 || strings.HasSuffix(ixcols[0], "_lower!") {
+			p.Error("text index must have a single column")
+		}
+		return &Index{Columns: ixcols, Mode: mode}
+	}
+	ix := &Index{Columns: ixcols, Mode: mode, Desc: desc, Storing: storing}
 	ix.Fk.Table, ix.Fk.Columns, ix.Fk.Mode = p.foreignKey()
 	if ix.Fk.Columns == nil {
 		ix.Fk.Columns = ixcols
 	}
+	if desc != nil && ix.Fk.Table != "" {
+		p.Error("foreign key index columns can not be descending")
+	}
 	return ix
 }
 
-func (p *adminParser) indexColumns(columns, derived []string, full bool) []string {
+// indexColumns parses the index column list.
+// A column may be followed by desc for descending order.
+// desc is nil if all the columns are ascending.
+func (p *adminParser) indexColumns(columns, derived []string, full bool) (
+	ixcols []string, desc []bool) {
 	p.Match(tok.LParen)
-	ixcols := make([]string, 0, 8)
+	ixcols = make([]string, 0, 8)
 	for p.Token != tok.RParen {
 		col := p.MatchIdent()
 		if full && !strs.Contains(columns, col) &&
@@ -198,10 +383,56 @@ func (p *adminParser) indexColumns(columns, derived []string, full bool) []strin
 			p.Error("invalid index column: " + col)
 		}
 		ixcols = append(ixcols, col)
+		if p.isDesc() {
+			p.Next()
+			if desc == nil {
+				desc = make([]bool, len(ixcols)-1, cap(ixcols))
+			}
+			desc = append(desc, true)
+		} else if desc != nil {
+			desc = append(desc, false)
+		}
 		p.MatchIf(tok.Comma)
 	}
 	p.Match(tok.RParen)
-	return ixcols
+	return ixcols, desc
+}
+
+// storing parses the optional storing (columns) following index columns
+func (p *adminParser) storing(columns, ixcols []string, full bool) []string {
+	if !p.Token.IsIdent() || p.Text != "storing" {
+		return nil
+	}
+	p.Next()
+	p.Match(tok.LParen)
+	var cols []string
+	for p.Token != tok.RParen {
+		col := p.MatchIdent()
+		if full && !strs.Contains(columns, col) {
+			p.Error("invalid storing column: " + col)
+		}
+		if strs.Contains(ixcols, col) || strs.Contains(cols, col) {
+			p.Error("duplicate storing column: " + col)
+		}
+		cols = append(cols, col)
+		p.MatchIf(tok.Comma)
+	}
+	p.Match(tok.RParen)
+	if len(cols) == 0 {
+		p.Error("storing columns must not be empty")
+	}
+	return cols
+}
+
+// isDesc returns whether the current token is desc following a column.
+// Since commas are optional it must be followed by a comma or paren
+// so it can still be used as a column name.
+func (p *adminParser) isDesc() bool {
+	if !p.Token.IsIdent() || p.Text != "desc" {
+		return false
+	}
+	next := p.Lxr.AheadSkip(0).Token
+	return next == tok.Comma || next == tok.RParen
 }
 
 func (p *adminParser) foreignKey() (table string, columns []string, mode int) {
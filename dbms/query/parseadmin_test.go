@@ -26,6 +26,27 @@ func TestParseAdmin(t *testing.T) {
 	test("ensure mytable index(one,two)")
 	test("ensure mytable (one,two,three) index(one,two)")
 	test("ensure mytable (one,two,three) index unique(one,two)")
+	test("create mytable (one,two,three) key(one) index text(two)")
+	test("create mytable (one,two,three) key(one) index(two desc)")
+	test("create mytable (one,two,three) key(one) index(two,three desc)")
+	test("create mytable (one,two,desc) key(one) index(two,desc)")
+	test("create mytable (one,two,three) key(one) index unique(two desc,three)")
+	test("create mytable (one,two,three) key(one) index(two) storing(three)")
+	test("create mytable (one,two,three) key(one) index(two) storing(three,one)")
+	test("create mytable (one,two) key(one) compressed")
+	test("create mytable (one,two) key(one) maxrows(1000)")
+	test("create mytable (one,two) key(one) compressed maxrows(10) maxsize(5000)")
+	test("create mytable (one,two) key(one) autonumber(one)")
+	test("create mytable (one,two) key(one) compressed history")
+	test("create mytable (one,two=0,three=#(1, 'a')) key(one)")
+	test("ensure mytable (four=true) index(four)")
+	test("create mytable (one,two) key(one) check positive(one > 0)")
+	test("create mytable (one,two) key(one) check(one > 0) check(two > 0)")
+	test("create mytable (one,two) key(one) compressed " +
+		"check a(one > (two + 1)) check b(two in (1, 2))")
+	test("ensure mytable (one) check positive(one > 0)")
+	test("alter mytable create check positive(one > 0)")
+	test("alter mytable drop check positive")
 
 	test("ensure mytable (one,two,three) index(two) in other")
 	test("ensure mytable (one,two,three) index(two) in other cascade")
@@ -39,6 +60,8 @@ func TestParseAdmin(t *testing.T) {
 
 	test("alter mytable drop (one,two,three) index(two)")
 	test("alter mytable create (one,two,three) index(two)")
+	test("alter mytable create maxrows(1000) maxsize(5000)")
+	test("ensure mytable (one) maxrows(1000)")
 	test("alter mytable rename one to two, three to four")
 
 	test("view tc = tables join columns")
@@ -53,4 +76,33 @@ func TestParseAdmin(t *testing.T) {
 	xtest("create mytable (one,two,three) key(bar)", "invalid index column: bar")
 	xtest("create mytable (one,two,three_lower!) key(one)",
 		"_lower! nonexistent column: three")
+	xtest("create mytable (one,two,three) key(one) index text(two,three)",
+		"text index must have a single column")
+	xtest("create mytable (one,two,three) key(one desc)",
+		"only index columns can be descending")
+	xtest("create mytable (one,two,three) key(one) storing(two)",
+		"only index can have storing columns")
+	xtest("create mytable (one,two,three) key(one) index(two) storing(four)",
+		"invalid storing column: four")
+	xtest("create mytable (one,two,three) key(one) index(two) storing(two)",
+		"duplicate storing column: two")
+	xtest("create mytable (one,two,three) key(one) index(two desc) in other",
+		"foreign key index columns can not be descending")
+	xtest("ensure mytable (one,two) key(one) compressed",
+		"did not parse all input")
+	xtest("create mytable (one,two) key(one) maxrows(0)", "invalid maxrows")
+	xtest("alter mytable drop maxrows(10)", "can't drop maxrows")
+	xtest("create mytable (one,two) key(one) maxsize(big)", "invalid maxsize")
+	xtest("create mytable (one,two) key(one) check(one > 0",
+		"check missing close paren")
+	xtest("create mytable (one,two) key(one) check()",
+		"check expression must not be empty")
+	xtest("create mytable (one,two=) key(one)",
+		"default two must not be empty")
+	xtest("create mytable (one,two=#(1 key(one)",
+		"default missing close paren")
+	xtest("create mytable (one,two) key(one) autonumber(three)",
+		"autonumber nonexistent column")
+	xtest("create mytable (one,two) key(one) autonumber(two)",
+		"autonumber column must be a key")
 }
@@ -7,6 +7,9 @@ import (
 	"github.com/apmckinlay/gsuneido/compile"
 	"github.com/apmckinlay/gsuneido/compile/ast"
 	tok "github.com/apmckinlay/gsuneido/compile/tokens"
+	"github.com/apmckinlay/gsuneido/db19/meta/schema"
+	"github.com/apmckinlay/gsuneido/runtime/types"
+	"github.com/apmckinlay/gsuneido/util/sset"
 	"github.com/apmckinlay/gsuneido/util/str"
 	"github.com/apmckinlay/gsuneido/util/strs"
 )
@@ -63,6 +66,9 @@ func (p *queryParser) source() Query {
 
 func (p *queryParser) table() Query {
 	table := p.MatchIdent()
+	if p.MatchIf(tok.At) {
+		return p.asOf(table)
+	}
 	if !strs.Contains(p.viewNest, table) {
 		if def := p.t.GetView(table); def != "" {
 			return parseQuery(def, p.t, append(p.viewNest, table))
@@ -71,6 +77,26 @@ func (p *queryParser) table() Query {
 	return NewTable(p.t, table)
 }
 
+// asOf handles table @ date by selecting the versions
+// from the table's history table that were current at that time
+func (p *queryParser) asOf(table string) Query {
+	c, ok := p.Expression().(*ast.Constant)
+	if !ok || c.Val.Type() != types.Date {
+		p.Error("@ requires a date")
+	}
+	sc := p.t.GetSchema(table)
+	if !sc.History {
+		p.Error("@ requires a table with history: " + table)
+	}
+	hist := schema.HistoryTable(table)
+	cols := sset.Intersect(p.t.GetSchema(hist).Columns, sc.Columns)
+	date := c.Val.String()
+	src := hist + " where " + schema.HistoryFrom + " <= " + date +
+		" and (" + schema.HistoryTo + ` is "" or ` +
+		schema.HistoryTo + " > " + date + ") project " + strs.Join(",", cols)
+	return parseQuery(src, p.t, p.viewNest)
+}
+
 func (p *queryParser) operation(pq *Query) bool {
 	switch {
 	case p.MatchIf(tok.Extend):
@@ -231,11 +257,57 @@ func (p *queryParser) union(q Query) Query {
 	return NewUnion(q, q2)
 }
 
+// where handles: where expr
+// Top level and terms of the form: col matches 'words' become TextMatch's
 func (p *queryParser) where(q Query) Query {
 	p.EqToIs = true
 	defer func() { p.EqToIs = false }()
-	expr := p.Expression()
-	return NewWhere(q, expr, p.t)
+	var exprs []ast.Expr
+	matched := false
+	for {
+		if p.Token.IsIdent() && p.Lxr.Ahead(1).Text == "matches" {
+			q = p.matches(q)
+			matched = true
+		} else {
+			exprs = append(exprs, p.AndOperand())
+		}
+		if !p.MatchIf(tok.And) {
+			break
+		}
+	}
+	if p.Token == tok.Or || p.Token == tok.QMark {
+		if matched {
+			p.Error("matches must be a top level and term")
+		}
+		exprs = []ast.Expr{p.ExpressionFrom(p.andExprs(exprs))}
+	}
+	if p.Token.IsIdent() && p.Text == "matches" {
+		p.Error("matches must be a top level and term")
+	}
+	if len(exprs) == 0 {
+		return q
+	}
+	return NewWhere(q, p.andExprs(exprs), p.t)
+}
+
+func (p *queryParser) andExprs(exprs []ast.Expr) ast.Expr {
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	return p.Nary(tok.And, exprs)
+}
+
+// matches handles: col matches 'words'
+// which uses a full text index on col if possible
+func (p *queryParser) matches(q Query) Query {
+	col := p.MatchIdent()
+	p.Next() // matches
+	if p.Token != tok.String {
+		p.Error("matches requires a string")
+	}
+	text := p.Text
+	p.Next()
+	return NewTextMatch(q, col, text, p.t)
 }
 
 func (p *queryParser) parenList() []string {
@@ -316,6 +316,10 @@ func (p *Project) Updateable() string {
 // optimize ---------------------------------------------------------
 
 func (p *Project) optimize(mode Mode, index []string) (Cost, interface{}) {
+	if tbl, ok := p.source.(*Table); ok {
+		// allow the table to use a covering index
+		tbl.needCols = p.columns
+	}
 	if p.unique {
 		approach := &projectApproach{strategy: projCopy, index: index}
 		return Optimize(p.source, mode, index), approach
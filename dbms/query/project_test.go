@@ -6,7 +6,11 @@ package query
 import (
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/apmckinlay/gsuneido/db19"
+	"github.com/apmckinlay/gsuneido/db19/stor"
+	rt "github.com/apmckinlay/gsuneido/runtime"
 	"github.com/apmckinlay/gsuneido/util/assert"
 )
 
@@ -31,3 +35,67 @@ func TestGrouped(t *testing.T) {
 	test("a f1 b f2", "a f1")
 	test("a f1 b f2", "f1 b f2 a")
 }
+
+func TestProjectCover(t *testing.T) {
+	MakeSuTran = func(qt QueryTran) *rt.SuTran { return nil }
+	store := stor.HeapStor(8192)
+	db, err := db19.CreateDb(store)
+	ck(err)
+	db19.StartConcur(db, 50*time.Millisecond)
+	defer db.Close()
+	db19.MakeSuTran = func(ut *db19.UpdateTran) *rt.SuTran {
+		return rt.NewSuTran(nil, true)
+	}
+	act := func(act string) {
+		ut := db.NewUpdateTran()
+		defer ut.Commit()
+		DoAction(ut, act)
+	}
+	DoAdmin(db, "create emp (id, dept, name, salary) key(id) "+
+		"index(dept) storing(name)")
+	act("insert { id: 1, dept: 'b', name: 'joe', salary: 10 } into emp")
+	act("insert { id: 2, dept: 'a', name: 'sue', salary: 20 } into emp")
+	act("insert { id: 3, dept: 'b', name: 'ann', salary: 30 } into emp")
+
+	test := func(query string, mode Mode, strategy, expected string) {
+		t.Helper()
+		var tran QueryTran = db.NewReadTran()
+		if mode == UpdateMode {
+			ut := db.NewUpdateTran()
+			defer ut.Commit()
+			tran = ut
+		}
+		q := ParseQuery(query, tran)
+		q, _ = Setup(q, mode, tran)
+		assert.T(t).This(q.String()).Is(strategy)
+		hdr := q.Header()
+		var rows []string
+		for row := q.Get(rt.Next); row != nil; row = q.Get(rt.Next) {
+			var vals []string
+			for _, col := range q.Columns() {
+				vals = append(vals, row.GetVal(hdr, col, nil, nil).String())
+			}
+			rows = append(rows, strings.Join(vals, " "))
+		}
+		assert.T(t).This(strings.Join(rows, ", ")).Is(expected)
+	}
+	test("emp project id, name", ReadMode,
+		"emp^(dept) COVER PROJECT-COPY id,name",
+		`2 "sue", 1 "joe", 3 "ann"`)
+	test("emp project dept, name", ReadMode,
+		"emp^(dept) COVER PROJECT-HASH dept,name",
+		`'a' "sue", 'b' "joe", 'b' "ann"`)
+	test("emp project id, salary", ReadMode,
+		"emp^(id) PROJECT-COPY id,salary",
+		"1 10, 2 20, 3 30")
+	test("emp project id, name", UpdateMode,
+		"emp^(id) PROJECT-COPY id,name",
+		`1 "joe", 2 "sue", 3 "ann"`)
+
+	// updating a stored column updates the index entry
+	act("update emp where id is 1 set name = 'bob'")
+	test("emp project id, name", ReadMode,
+		"emp^(dept) COVER PROJECT-COPY id,name",
+		`2 "sue", 1 "bob", 3 "ann"`)
+	assert.T(t).This(db.Check()).Is(nil)
+}
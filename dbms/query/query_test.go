@@ -139,9 +139,197 @@ func TestForeignKeys(*testing.T) {
 	assert.This(func() { DoAdmin(db, "alter two create index(a) in one") }).
 		Panics("blocked by foreign key")
 
+	// cascade update only, deletes are still blocked
+	DoAdmin(db, "create par (p) key(p)")
+	act("insert { p: 1 } into par")
+	act("insert { p: '' } into par")
+	DoAdmin(db, "create chi (p, c) key(c) index(p) in par cascade update")
+	act("insert { p: 1, c: 1 } into chi")
+	act("insert { p: '', c: 2 } into chi")
+	assert.This(func() { act("delete par where p = 1") }).
+		Panics("blocked by foreign key")
+	act("update par where p = 1 set p = 3")
+	assert.This(queryAll(db, "chi")).Is("p=3 c=1 | p=\"\" c=2")
+	// empty keys are not references
+	act("update par where p = '' set p = 4")
+	assert.This(queryAll(db, "chi")).Is("p=3 c=1 | p=\"\" c=2")
+	act("delete par where p = 4")
+
+	// multiple levels of cascading
+	DoAdmin(db, "create gchi (c, g) key(g) index(c) in chi cascade")
+	act("insert { c: 1, g: 1 } into gchi")
+	act("insert { c: 1, g: 2 } into gchi")
+	DoAdmin(db, "create par2 (p) key(p)")
+	act("insert { p: 1 } into par2")
+	DoAdmin(db, "create chi2 (p, c) key(c) index(p) in par2 cascade")
+	DoAdmin(db, "create gchi2 (c, g) key(g) index(c) in chi2 cascade")
+	act("insert { p: 1, c: 1 } into chi2")
+	act("insert { c: 1, g: 1 } into gchi2")
+	act("insert { c: 1, g: 2 } into gchi2")
+	act("delete par2")
+	assert.This(queryAll(db, "chi2")).Is("")
+	assert.This(queryAll(db, "gchi2")).Is("")
+
 	db.Check()
 }
 
+func TestCheckConstraints(t *testing.T) {
+	assert := assert.T(t)
+	db := testDb()
+	defer db.Close()
+	MakeSuTran = func(qt QueryTran) *rt.SuTran { return nil }
+	act := func(act string) {
+		ut := db.NewUpdateTran()
+		defer ut.Commit()
+		DoAction(ut, act)
+	}
+	DoAdmin(db, "create tbl (a, b) key(a) check positive(a > 0) check(b isnt '')")
+	assert.This(db.Schema("tbl")).
+		Is("tbl (a,b) key(a) check positive(a > 0) check check1(b isnt '')")
+	act("insert { a: 1, b: 1 } into tbl")
+	assert.This(func() { act("insert { a: 0, b: 1 } into tbl") }).
+		Panics("check constraint failed: positive in tbl")
+	assert.This(func() { act("insert { a: 2 } into tbl") }).
+		Panics("check constraint failed: check1 in tbl")
+	assert.This(func() { act("update tbl set a = -1") }).
+		Panics("check constraint failed: positive in tbl")
+	DoAdmin(db, "ensure tbl check(a < 100)")
+	DoAdmin(db, "ensure tbl check(a < 100)") // already exists
+	assert.This(func() { act("insert { a: 200, b: 1 } into tbl") }).
+		Panics("check constraint failed: check2 in tbl")
+	assert.This(queryAll(db, "tbl")).Is("a=1 b=1")
+
+	assert.This(func() { DoAdmin(db, "ensure tbl check(c > 0)") }).
+		Panics("check (c > 0): nonexistent column(s): c")
+	assert.This(func() { DoAdmin(db, "ensure tbl check(Foo(a))") }).
+		Panics("check (Foo(a)): can't use Foo")
+	assert.This(func() { DoAdmin(db, "alter tbl rename a to z") }).
+		Panics("can't modify column(s) used by check positive: a")
+	DoAdmin(db, "alter tbl drop check positive")
+	act("insert { a: 0, b: 1 } into tbl")
+	assert.This(db.Schema("tbl")).
+		Is("tbl (a,b) key(a) check check1(b isnt '') check check2(a < 100)")
+	DoAdmin(db, "alter tbl create check(b isnt 0) check check4(a < 50)")
+	assert.This(db.Schema("tbl")).
+		Is("tbl (a,b) key(a) check check1(b isnt '') check check2(a < 100) " +
+			"check check3(b isnt 0) check check4(a < 50)")
+
+	// unnamed checks are numbered after the existing ones
+	DoAdmin(db, "create tbl2 (a,b) key(a) check(a > 0)")
+	DoAdmin(db, "ensure tbl2 check(b > 0)")
+	assert.This(db.Schema("tbl2")).
+		Is("tbl2 (a,b) key(a) check check1(a > 0) check check2(b > 0)")
+}
+
+func TestDefaults(t *testing.T) {
+	assert := assert.T(t)
+	db := testDb()
+	defer db.Close()
+	MakeSuTran = func(qt QueryTran) *rt.SuTran { return nil }
+	act := func(act string) {
+		ut := db.NewUpdateTran()
+		defer ut.Commit()
+		DoAction(ut, act)
+	}
+	DoAdmin(db, "create tbl (a, b = 0, c = 'x') key(a)")
+	assert.This(db.Schema("tbl")).Is("tbl (a,b=0,c='x') key(a)")
+	act("insert { a: 1 } into tbl")
+	act("insert { a: 2, b: 5, c: 'y' } into tbl")
+	assert.This(queryAll(db, "tbl")).Is("a=1 b=0 c='x' | a=2 b=5 c='y'")
+	// defaults are not applied by updates
+	act("update tbl where a = 2 set c = ''")
+	assert.This(queryAll(db, "tbl where a = 2")).Is(`a=2 b=5 c=""`)
+	assert.This(queryAll(db, "columns where table = 'tbl' project column, default")).
+		Is(`column='a' default="" | column='b' default='0' | ` +
+			`column='c' default="'x'"`)
+
+	DoAdmin(db, "ensure tbl (d = true)")
+	act("insert { a: 3 } into tbl")
+	assert.This(queryAll(db, "tbl where a = 3")).Is("a=3 b=0 c='x' d=true")
+	DoAdmin(db, "alter tbl rename b to bb")
+	DoAdmin(db, "alter tbl drop (c)")
+	assert.This(db.Schema("tbl")).Is("tbl (a,bb=0,-,d=true) key(a)")
+	assert.This(func() { DoAdmin(db, "ensure tbl (e = function () {})") }).
+		Panics("default e: must be a constant value")
+}
+
+func TestHistory(t *testing.T) {
+	assert := assert.T(t)
+	db := testDb()
+	defer db.Close()
+	MakeSuTran = func(qt QueryTran) *rt.SuTran { return nil }
+	db19.StartTimestamps()
+	act := func(acts ...string) {
+		ut := db.NewUpdateTran()
+		defer ut.Commit()
+		for _, act := range acts {
+			DoAction(ut, act)
+		}
+	}
+	DoAdmin(db, "create tbl (a, b) key(a) history")
+	assert.This(db.Schema("tbl")).Is("tbl (a,b) key(a) history")
+	assert.This(db.Schema("tbl_history")).
+		Is("tbl_history (a,b,history_from,history_to) key(a,history_to)")
+	asof := func(q string) string {
+		return queryAll(db, "tbl @ "+db19.Timestamp().String()+q)
+	}
+	act("insert { a: 1, b: 1 } into tbl", "insert { a: 2, b: 2 } into tbl")
+	t1 := asof("")
+	// versions created and replaced by the same transaction are not kept
+	act("update tbl where a = 1 set b = 11", "update tbl where a = 1 set b = 12")
+	t2 := asof(" where a = 1")
+	act("delete tbl where a = 2", "insert { a: 3, b: 3 } into tbl")
+	t3 := asof(" sort a")
+	assert.This(queryAll(db, "tbl_history where history_to is '' project a")).
+		Is("a=1 | a=3")
+	assert.This(queryAll(db, "tbl_history where history_to isnt '' project a")).
+		Is("a=1 | a=2")
+	assert.This(t1).Is("a=1 b=1 | a=2 b=2")
+	assert.This(t2).Is("a=1 b=12")
+	assert.This(t3).Is("a=1 b=12 | a=3 b=3")
+
+	DoAdmin(db, "ensure tbl (c)")
+	assert.This(db.Schema("tbl_history")).
+		Is("tbl_history (a,b,history_from,history_to,c) key(a,history_to)")
+	DoAdmin(db, "rename tbl to tbl2")
+	assert.This(queryAll(db, "tbl2_history where history_to is '' project a")).
+		Is("a=1 | a=3")
+	assert.This(func() { queryAll(db, "customer @ #20200101") }).
+		Panics("@ requires a table with history: customer")
+	DoAdmin(db, "drop tbl2")
+	assert.This(db.Schema("tbl2_history")).Is("")
+}
+
+func TestTableStats(t *testing.T) {
+	db := testDb()
+	defer db.Close()
+	MakeSuTran = func(qt QueryTran) *rt.SuTran { return nil }
+	assert.T(t).This(queryAll(db,
+		"table_stats where table = 'customer' project table, ixbuf_unmerged")).
+		Is(`table="customer" ixbuf_unmerged=0`)
+}
+
+func TestViews(t *testing.T) {
+	assert := assert.T(t)
+	db := testDb()
+	defer db.Close()
+	MakeSuTran = func(qt QueryTran) *rt.SuTran { return nil }
+	DoAdmin(db, "view sask = customer where city = 'saskatoon'")
+	assert.This(queryAll(db, "sask project id")).Is("id='a' | id='i'")
+	DoAdmin(db, "view sask2 = sask where id = 'i'")
+	assert.This(queryAll(db, "sask2 project name")).Is(`name="intercon"`)
+	assert.This(queryAll(db, "views project view_name")).
+		Is(`view_name="sask" | view_name="sask2"`)
+	// a view can refer to a table with the same name
+	DoAdmin(db, "view customer = customer where city = 'calgary'")
+	assert.This(queryAll(db, "customer project id")).Is("id='c'")
+	// transactions see the views as of when they started
+	tran := db.NewReadTran()
+	DoAdmin(db, "drop sask2")
+	assert.This(tran.GetView("sask2")).Is("sask where id = 'i'")
+	assert.This(db.NewReadTran().GetView("sask2")).Is("")
+}
+
 func queryAll(db *db19.Database, query string) string {
 	tran := sizeTran{db.NewReadTran()}
 	q := ParseQuery(query, tran)
@@ -13,7 +13,8 @@ import (
 	"github.com/apmckinlay/gsuneido/util/strs"
 )
 
-// schema implements virtual tables for tables, columns, indexes, and views
+// schema implements virtual tables for tables, columns, indexes, views,
+// and table_stats
 
 type schemaTable struct {
 	cache
@@ -197,7 +198,7 @@ func (*Columns) Keys() [][]string {
 	return [][]string{{"table", "column"}}
 }
 
-var columnsFields = [][]string{{"table", "column", "field"}}
+var columnsFields = [][]string{{"table", "column", "field", "default"}}
 
 func (cs *Columns) Columns() []string {
 	return columnsFields[0]
@@ -268,6 +269,9 @@ func (cs *Columns) Get(dir Dir) Row {
 	rb.Add(SuStr(schema.Table))
 	rb.Add(SuStr(col))
 	rb.Add(IntVal(fld).(Packable))
+	if def, ok := schema.Defaults[col]; ok && fld >= 0 {
+		rb.Add(SuStr(def))
+	}
 	rec := rb.Build()
 	return Row{DbRec{Record: rec}}
 }
@@ -389,6 +393,8 @@ func (is *Indexes) Get(dir Dir) Row {
 		rb.Add(False.(Packable))
 	case 'u':
 		rb.Add(SuStr("u"))
+	case 't':
+		rb.Add(SuStr("t"))
 	default:
 		panic("shouldn't reach here")
 	}
@@ -498,3 +504,89 @@ func (vs *Views) Swap(i, j int) {
 	vs.views[i], vs.views[j] = vs.views[j], vs.views[i]
 	vs.views[i+1], vs.views[j+1] = vs.views[j+1], vs.views[i+1]
 }
+
+//-------------------------------------------------------------------
+
+// TableStats is the background work (merge and persist) for each table
+// since the database was opened, see meta.Metrics
+type TableStats struct {
+	schemaTable
+	info []*meta.Info
+	i    int
+}
+
+func (*TableStats) String() string {
+	return "table_stats"
+}
+
+func (ts *TableStats) Transform() Query {
+	return ts
+}
+
+func (*TableStats) Keys() [][]string {
+	return [][]string{{"table"}}
+}
+
+var tableStatsFields = [][]string{{"table", "merges", "keys_merged",
+	"persists", "persist_bytes", "ixbuf_merged", "ixbuf_unmerged"}}
+
+func (*TableStats) Columns() []string {
+	return tableStatsFields[0]
+}
+
+func (*TableStats) Header() *Header {
+	return NewHeader(tableStatsFields, tableStatsFields[0])
+}
+
+func (ts *TableStats) Nrows() int {
+	ts.ensure()
+	return len(ts.info)
+}
+
+func (ts *TableStats) Rewind() {
+	ts.i = -1
+	ts.state = rewound
+}
+
+func (ts *TableStats) Get(dir Dir) Row {
+	ts.ensure()
+	if ts.state == eof {
+		return nil
+	}
+	if dir == Next {
+		if ts.state == rewound {
+			ts.i = -1
+		}
+		ts.i++
+	} else { // Prev
+		if ts.state == rewound {
+			ts.i = len(ts.info)
+		}
+		ts.i--
+	}
+	if ts.i < 0 || len(ts.info) <= ts.i {
+		return nil
+	}
+	ts.state = within
+	info := ts.info[ts.i]
+	merged, unmerged := info.BufLen()
+	var rb RecordBuilder
+	rb.Add(SuStr(info.Table))
+	rb.Add(IntVal(info.Metrics.Merges).(Packable))
+	rb.Add(IntVal(info.Metrics.KeysMerged).(Packable))
+	rb.Add(IntVal(info.Metrics.Persists).(Packable))
+	rb.Add(Int64Val(int64(info.Metrics.PersistBytes)).(Packable))
+	rb.Add(IntVal(merged).(Packable))
+	rb.Add(IntVal(unmerged).(Packable))
+	rec := rb.Build()
+	return Row{DbRec{Record: rec}}
+}
+
+func (ts *TableStats) ensure() {
+	if ts.info != nil {
+		return
+	}
+	ts.info = ts.tran.GetAllInfo()
+	sort.Slice(ts.info,
+		func(i, j int) bool { return ts.info[i].Table < ts.info[j].Table })
+}
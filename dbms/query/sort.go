@@ -19,6 +19,9 @@ type Sort struct {
 
 type sortApproach struct {
 	index []string
+	// desc is true when reverse is satisfied by reading
+	// an all descending table index forwards
+	desc bool
 }
 
 func NewSort(src Query, reverse bool, cols []string) *Sort {
@@ -32,7 +35,7 @@ func NewSort(src Query, reverse bool, cols []string) *Sort {
 func (sort *Sort) String() string {
 	s := sort.source.String()
 	r := ""
-	if sort.reverse {
+	if sort.reverse && !sort.desc {
 		r = " reverse"
 	}
 	if sort.index != nil {
@@ -52,6 +55,17 @@ func (sort *Sort) optimize(mode Mode, index []string) (Cost, interface{}) {
 	cost := Optimize(src, mode, sort.columns)
 	best := sort.bestOrdered(src.Indexes(), sort.columns, mode)
 	trace("SORT", "cost", cost, "best", best.cost)
+	if sort.reverse {
+		if tbl, ok := src.(*Table); ok {
+			if ix := tbl.descIndexFor(sort.columns); ix != nil {
+				descCost := tbl.descCost()
+				trace("SORT", "desc", descCost)
+				if descCost < cost && descCost < best.cost {
+					return descCost, sortApproach{index: ix, desc: true}
+				}
+			}
+		}
+	}
 	if cost < best.cost {
 		return cost, sortApproach{index: sort.columns}
 	}
@@ -75,6 +89,10 @@ func (q1 *Query1) bestOrdered(indexes [][]string, order []string,
 
 func (sort *Sort) setApproach(_ []string, approach interface{}, tran QueryTran) {
 	sort.sortApproach = approach.(sortApproach)
+	if sort.desc {
+		sort.source.(*Table).setDescIndex(sort.index)
+		return
+	}
 	sort.source = SetApproach(sort.source, sort.index, tran)
 }
 
@@ -85,7 +103,7 @@ func (sort *Sort) Header() *runtime.Header {
 }
 
 func (sort *Sort) Get(dir runtime.Dir) runtime.Row {
-	if sort.reverse {
+	if sort.reverse && !sort.desc {
 		dir = dir.Reverse()
 	}
 	return sort.source.Get(dir)
@@ -0,0 +1,67 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package query
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apmckinlay/gsuneido/db19"
+	"github.com/apmckinlay/gsuneido/db19/stor"
+	rt "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestSortDescIndex(t *testing.T) {
+	MakeSuTran = func(qt QueryTran) *rt.SuTran { return nil }
+	store := stor.HeapStor(8192)
+	db, err := db19.CreateDb(store)
+	ck(err)
+	db19.StartConcur(db, 50*time.Millisecond)
+	defer db.Close()
+	db19.MakeSuTran = func(ut *db19.UpdateTran) *rt.SuTran {
+		return rt.NewSuTran(nil, true)
+	}
+	act := func(act string) {
+		ut := db.NewUpdateTran()
+		defer ut.Commit()
+		DoAction(ut, act)
+	}
+	DoAdmin(db, "create hist (id, date, amt) key(id) index(date desc, amt desc)")
+	act("insert { id: 1, date: 20, amt: 5 } into hist")
+	act("insert { id: 2, date: 10, amt: 7 } into hist")
+	act("insert { id: 3, date: 30 } into hist")
+	act("insert { id: 4, date: 20, amt: 9 } into hist")
+	act("insert { id: 5 } into hist")
+
+	test := func(query, strategy, expected string) {
+		t.Helper()
+		tran := db.NewReadTran()
+		q := ParseQuery(query, tran)
+		q, _ = Setup(q, ReadMode, tran)
+		assert.T(t).This(q.String()).Is(strategy)
+		hdr := q.Header()
+		var ids []string
+		for row := q.Get(rt.Next); row != nil; row = q.Get(rt.Next) {
+			ids = append(ids, row.GetVal(hdr, "id", nil, nil).String())
+		}
+		assert.T(t).This(strings.Join(ids, ",")).Is(expected)
+		ids = nil
+		q.Rewind()
+		for row := q.Get(rt.Prev); row != nil; row = q.Get(rt.Prev) {
+			ids = append([]string{row.GetVal(hdr, "id", nil, nil).String()}, ids...)
+		}
+		assert.T(t).This(strings.Join(ids, ",")).Is(expected)
+	}
+	test("hist sort reverse date",
+		"hist^(date desc,amt desc)", "3,4,1,2,5")
+	test("hist sort reverse date, amt",
+		"hist^(date desc,amt desc)", "3,4,1,2,5")
+	test("hist sort date",
+		"hist^(id) TEMPINDEX(date)", "5,2,1,4,3")
+	test("hist sort reverse id", "hist^(id) reverse", "5,4,3,2,1")
+
+	assert.T(t).This(db.Check()).Is(nil)
+}
@@ -11,10 +11,10 @@ import (
 	"github.com/apmckinlay/gsuneido/db19/meta"
 	"github.com/apmckinlay/gsuneido/runtime"
 	"github.com/apmckinlay/gsuneido/util/assert"
+	"github.com/apmckinlay/gsuneido/util/ints"
 	"github.com/apmckinlay/gsuneido/util/setset"
 	"github.com/apmckinlay/gsuneido/util/str"
 	"github.com/apmckinlay/gsuneido/util/strs"
-	"github.com/apmckinlay/gsuneido/util/strss"
 )
 
 func NewTable(t QueryTran, name string) Query {
@@ -28,6 +28,8 @@ func NewTable(t QueryTran, name string) Query {
 		tbl = &Indexes{}
 	case "views":
 		tbl = &Views{}
+	case "table_stats":
+		tbl = &TableStats{}
 	default:
 		tbl = &Table{name: name}
 	}
@@ -49,18 +51,40 @@ type Table struct {
 	index       []string
 	iIndex      int
 	indexEncode bool
-	iter        *index.OverIter
+	// desc is the direction of the index columns, nil if all ascending
+	desc []bool
+	// descIndexes are the all descending indexes,
+	// they are only used by Sort (reverse) and are not in indexes
+	descIndexes [][]string
+	// needCols are the only columns required, set by Project.
+	// They allow reading from a covering index without the data records.
+	needCols []string
+	// cover is whether rows are built from the index entries
+	cover bool
+	iter  *index.OverIter
 }
 
 type tableApproach struct {
 	index []string
+	cover bool
 }
 
 func (tbl *Table) String() string {
 	if tbl.index == nil {
 		return tbl.name
 	}
-	return tbl.name + "^" + strs.Join("(,)", tbl.index)
+	if tbl.desc != nil {
+		cols := make([]string, len(tbl.index))
+		for i, col := range tbl.index {
+			cols[i] = col + " desc"
+		}
+		return tbl.name + "^" + strs.Join("(,)", cols)
+	}
+	s := tbl.name + "^" + strs.Join("(,)", tbl.index)
+	if tbl.cover {
+		s += " COVER"
+	}
+	return s
 }
 
 func (tbl *Table) SetTran(t QueryTran) {
@@ -85,7 +109,17 @@ func (tbl *Table) SetTran(t QueryTran) {
 
 	idxs := make([][]string, 0, len(tbl.schema.Indexes)-1)
 	keys := make([][]string, 0, 1)
+	var descIdxs [][]string
 	for _, ix := range tbl.schema.Indexes {
+		if ix.Mode == 't' {
+			continue // full text indexes are only used by TextMatch
+		}
+		if ix.HasDesc() {
+			if ix.AllDesc() {
+				descIdxs = append(descIdxs, ix.Columns)
+			}
+			continue
+		}
 		idxs = append(idxs, ix.Columns)
 		if ix.Mode == 'k' {
 			keys = append(keys, ix.Columns)
@@ -96,6 +130,7 @@ func (tbl *Table) SetTran(t QueryTran) {
 	}
 	tbl.indexes = idxs
 	tbl.keys = keys
+	tbl.descIndexes = descIdxs
 }
 
 func (tbl *Table) Columns() []string {
@@ -145,19 +180,62 @@ func (tbl *Table) SingleTable() bool {
 	return true
 }
 
-func (tbl *Table) optimize(_ Mode, index []string) (Cost, interface{}) {
+func (tbl *Table) optimize(mode Mode, index []string) (Cost, interface{}) {
+	cover := false
 	if index == nil {
-		index = tbl.schema.Indexes[0].Columns
+		if ix := tbl.coverFor(mode, nil); ix != nil {
+			index, cover = ix, true
+		} else {
+			index = tbl.indexes[0]
+		}
 	} else if !tbl.singleton {
 		i := tbl.indexFor(index)
 		if i < 0 {
 			return impossible, nil
 		}
-		index = tbl.indexes[i]
+		if ix := tbl.coverFor(mode, index); ix != nil {
+			index, cover = ix, true
+		} else {
+			index = tbl.indexes[i]
+		}
 	}
 	indexReadCost := tbl.info.Nrows * btree.EntrySize
 	dataReadCost := int(tbl.info.Size)
-	return indexReadCost + dataReadCost, tableApproach{index: index}
+	if cover {
+		dataReadCost = 0
+	}
+	return indexReadCost + dataReadCost,
+		tableApproach{index: index, cover: cover}
+}
+
+// coverFor returns an index that satisfies the order (if any)
+// and includes all of needCols in its entries, or nil if none.
+// Covering is only used in ReadMode since the records are incomplete.
+func (tbl *Table) coverFor(mode Mode, order []string) []string {
+	if mode != ReadMode || tbl.needCols == nil {
+		return nil
+	}
+	for _, ix := range tbl.indexes {
+		if strs.HasPrefix(ix, order) && tbl.covers(ix) {
+			return ix
+		}
+	}
+	return nil
+}
+
+// covers returns whether the entries of an index include all of needCols
+func (tbl *Table) covers(idx []string) bool {
+	spec := &tbl.schema.Indexes[tbl.schemaIndex(idx)].Ixspec
+	if len(spec.Fields2) > 0 {
+		return false
+	}
+	for _, col := range tbl.needCols {
+		c := strs.Index(tbl.schema.Columns, col)
+		if c == -1 || ints.Index(spec.Fields, c) == -1 {
+			return false
+		}
+	}
+	return true
 }
 
 // find an index that satisfies the required order
@@ -171,13 +249,63 @@ func (tbl *Table) indexFor(order []string) int {
 }
 
 func (tbl *Table) setApproach(_ []string, approach interface{}, _ QueryTran) {
-	tbl.setIndex(approach.(tableApproach).index)
+	app := approach.(tableApproach)
+	tbl.setIndex(app.index)
+	tbl.cover = app.cover
 }
 
 func (tbl *Table) setIndex(index []string) {
 	tbl.index = index
-	tbl.iIndex = strss.Index(tbl.indexes, tbl.index)
+	tbl.iIndex = tbl.schemaIndex(tbl.index)
 	tbl.indexEncode = len(tbl.index) > 1 || !setset.Contains(tbl.keys, tbl.index)
+	tbl.desc = nil
+	tbl.cover = false
+}
+
+// schemaIndex returns the position in the schema of the index
+// with the given columns, skipping full text and descending indexes,
+// or -1 if not found.
+// This is the iIndex used by the database.
+func (tbl *Table) schemaIndex(idx []string) int {
+	for i, ix := range tbl.schema.Indexes {
+		if ix.Mode != 't' && !ix.HasDesc() && strs.Equal(ix.Columns, idx) {
+			return i
+		}
+	}
+	return -1
+}
+
+// descIndexFor returns an all descending index that satisfies
+// the reverse of the order when read forwards, or nil
+func (tbl *Table) descIndexFor(order []string) []string {
+	for _, ix := range tbl.descIndexes {
+		if strs.HasPrefix(ix, order) {
+			return ix
+		}
+	}
+	return nil
+}
+
+// descCost returns the cost of reading a descending index
+func (tbl *Table) descCost() Cost {
+	return tbl.info.Nrows*btree.EntrySize + int(tbl.info.Size)
+}
+
+// setDescIndex sets the table to read using an all descending index.
+// It is used by Sort instead of setApproach.
+func (tbl *Table) setDescIndex(index []string) {
+	tbl.index = index
+	tbl.iIndex = -1
+	for i, ix := range tbl.schema.Indexes {
+		if ix.AllDesc() && strs.Equal(ix.Columns, index) {
+			tbl.iIndex = i
+			tbl.desc = ix.Desc
+			break
+		}
+	}
+	assert.That(tbl.iIndex != -1)
+	tbl.indexEncode = true // descending fields are always encoded
+	tbl.cover = false
 }
 
 // lookupCost returns the cost of one lookup
@@ -194,7 +322,7 @@ func lookupCost(rowSize int) Cost {
 // execution --------------------------------------------------------
 
 func (tbl *Table) Lookup(cols, vals []string) runtime.Row {
-	key := selEncode(tbl.indexEncode, tbl.index, cols, vals)
+	key := tbl.selEncode(cols, vals)
 	return tbl.lookup(key)
 }
 
@@ -208,7 +336,7 @@ func (tbl *Table) lookup(key string) runtime.Row {
 
 func (tbl *Table) Header() *runtime.Header {
 	physical := [][]string{tbl.schema.Columns}
-	return runtime.NewHeader(physical, tbl.columns)
+	return runtime.SharedHeader(physical, tbl.columns)
 }
 
 func (tbl *Table) Output(rec runtime.Record) {
@@ -231,20 +359,69 @@ func (tbl *Table) Get(dir runtime.Dir) runtime.Row {
 	if tbl.iter.Eof() {
 		return nil
 	}
-	_, off := tbl.iter.Cur()
-	rec := tbl.tran.GetRecord(off)
+	key, off := tbl.iter.Cur()
+	var rec runtime.Record
+	if tbl.cover {
+		rec = tbl.coverRecord(key)
+	} else {
+		rec = tbl.tran.GetRecord(off)
+	}
 	return runtime.Row{runtime.DbRec{Record: rec, Off: off}}
 }
 
+// coverRecord builds a record from the fields of an index entry.
+// Fields that are not in the index are empty.
+func (tbl *Table) coverRecord(key string) runtime.Record {
+	spec := &tbl.schema.Indexes[tbl.iIndex].Ixspec
+	var vals []string
+	if len(spec.Fields) == 1 {
+		vals = []string{key} // single field keys are not encoded
+	} else {
+		vals = ixkey.Decode(key)
+	}
+	flds := make([]string, len(tbl.schema.Columns))
+	for i, f := range spec.Fields {
+		if f >= 0 && i < len(vals) {
+			flds[f] = vals[i]
+		}
+	}
+	var rb runtime.RecordBuilder
+	for _, fld := range flds {
+		rb.AddRaw(fld)
+	}
+	return rb.Trim().Build()
+}
+
 func (tbl *Table) Select(cols, vals []string) {
 	if cols == nil && vals == nil { // clear select
 		tbl.iter.Range(iterator.All)
 		return
 	}
-	org, end := selKeys(tbl.indexEncode, tbl.index, cols, vals)
+	org := tbl.selEncode(cols, vals)
+	end := org + ixkey.Sep + ixkey.Max
+	if !tbl.indexEncode {
+		end = org + "\x00"
+	}
 	tbl.SelectRaw(org, end)
 }
 
+// selEncode is like the selEncode function
+// but also handles descending indexes
+func (tbl *Table) selEncode(cols, vals []string) string {
+	if tbl.desc == nil {
+		return selEncode(tbl.indexEncode, tbl.index, cols, vals)
+	}
+	enc := ixkey.Encoder{}
+	for i, col := range tbl.index {
+		if tbl.desc[i] {
+			enc.AddDesc(selGet(col, cols, vals))
+		} else {
+			enc.Add(selGet(col, cols, vals))
+		}
+	}
+	return enc.String()
+}
+
 func selKeys(encode bool, dstCols, srcCols, vals []string) (string, string) {
 	org := selEncode(encode, dstCols, srcCols, vals)
 	var end string
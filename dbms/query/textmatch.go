@@ -0,0 +1,252 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package query
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/apmckinlay/gsuneido/db19/index"
+	"github.com/apmckinlay/gsuneido/db19/index/fulltext"
+	"github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/sset"
+)
+
+// TextMatch implements: table where col matches 'word1 word2'
+// using a full text index on col.
+// It selects the rows containing all the words.
+// The rows are produced in data record order, it does not supply an index,
+// so sort requires a temp index.
+//
+// If the source is not a table (e.g. a view or a previous where)
+// the index can not be used, instead it filters the source rows
+// like a Where.
+type TextMatch struct {
+	Query1
+	// tbl is nil if the source is not a table
+	tbl    *Table
+	col    string
+	words  []string
+	iIndex int
+	t      QueryTran
+	hdr    *runtime.Header
+	// offs are the data offsets of the matching records, set by ensure
+	offs []uint64
+	// i is the current position in offs, -1 when rewound
+	i       int
+	selCols []string
+	selVals []string
+}
+
+func NewTextMatch(src Query, col string, text string, t QueryTran) *TextMatch {
+	words := fulltext.Words(text)
+	if len(words) == 0 {
+		panic("matches: no words")
+	}
+	tm := &TextMatch{Query1: Query1{source: src}, col: col,
+		words: words, iIndex: -1, t: t, i: -1}
+	if tbl, ok := src.(*Table); ok {
+		for i, ix := range tbl.schema.Indexes {
+			if ix.Mode == 't' && ix.Columns[0] == col {
+				tm.tbl, tm.iIndex = tbl, i
+				break
+			}
+		}
+		if tm.tbl == nil {
+			panic("matches: no text index on " + col)
+		}
+	} else if !sset.Contains(src.Columns(), col) {
+		panic("matches: nonexistent column: " + col)
+	}
+	return tm
+}
+
+func (tm *TextMatch) String() string {
+	return tm.source.String() + " WHERE " + tm.col + " matches " +
+		runtime.SuStr(strings.Join(tm.words, " ")).String()
+}
+
+func (tm *TextMatch) SetTran(t QueryTran) {
+	tm.t = t
+	tm.source.SetTran(t)
+	tm.offs = nil
+}
+
+func (tm *TextMatch) Transform() Query {
+	if tm.tbl == nil {
+		tm.source = tm.source.Transform()
+	}
+	return tm
+}
+
+func (tm *TextMatch) Indexes() [][]string {
+	if tm.tbl == nil {
+		return tm.source.Indexes()
+	}
+	return nil
+}
+
+// Nrows estimates the number of matching rows.
+// A text index has one entry per distinct word per record,
+// so the entries for a word are the number of records containing it.
+// All the words must match, so the least common word is the limit.
+// Without stats for the number of entries it assumes avgWords per record.
+func (tm *TextMatch) Nrows() int {
+	nrows := tm.source.Nrows()
+	if tm.tbl == nil {
+		return nrows / 2 // same as Where
+	}
+	nkeys := float64(nrows * avgWords)
+	info := tm.t.GetInfo(tm.tbl.name)
+	if st := info.IndexStats(tm.iIndex); st != nil && st.Nrows > 0 {
+		nkeys = float64(st.Nrows)
+	}
+	for _, word := range tm.words {
+		org, end := fulltext.Range(word)
+		frac := tm.t.RangeFrac(tm.tbl.name, tm.iIndex, org, end)
+		if n := int(frac * nkeys); n < nrows {
+			nrows = n
+		}
+	}
+	return nrows
+}
+
+// avgWords is the assumed number of distinct words per record
+// for estimating without index stats
+const avgWords = 10
+
+func (tm *TextMatch) optimize(mode Mode, index []string) (Cost, interface{}) {
+	if tm.tbl == nil {
+		return Optimize(tm.source, mode, index), nil
+	}
+	if index != nil {
+		return impossible, nil
+	}
+	return tm.Nrows() * tm.tbl.lookupCost(), nil
+}
+
+func (tm *TextMatch) setApproach(index []string, _ interface{}, tran QueryTran) {
+	tm.t = tran
+	if tm.tbl == nil {
+		tm.source = SetApproach(tm.source, index, tran)
+	}
+}
+
+// execution --------------------------------------------------------
+
+func (tm *TextMatch) Rewind() {
+	if tm.tbl == nil {
+		tm.source.Rewind()
+		return
+	}
+	tm.i = -1
+	tm.offs = nil // may have been modified
+}
+
+func (tm *TextMatch) Get(dir runtime.Dir) runtime.Row {
+	if tm.tbl == nil {
+		for {
+			row := tm.source.Get(dir)
+			if row == nil || tm.hasWords(row) {
+				return row
+			}
+		}
+	}
+	tm.ensure()
+	for {
+		if dir == runtime.Prev {
+			if tm.i == -1 {
+				tm.i = len(tm.offs)
+			}
+			tm.i--
+		} else {
+			tm.i++
+		}
+		if tm.i < 0 || len(tm.offs) <= tm.i {
+			return nil
+		}
+		off := tm.offs[tm.i]
+		row := runtime.Row{runtime.DbRec{Record: tm.t.GetRecord(off), Off: off}}
+		if tm.selected(row) {
+			return row
+		}
+	}
+}
+
+// ensure finds the matching record offsets by intersecting
+// the full text index entries for each word
+func (tm *TextMatch) ensure() {
+	if tm.offs != nil {
+		return
+	}
+	var offs []uint64
+	for i, word := range tm.words {
+		org, end := fulltext.Range(word)
+		iter := index.NewOverIter(tm.tbl.name, tm.iIndex)
+		iter.Range(index.Range{Org: org, End: end})
+		var next []uint64
+		for iter.Next(tm.t); !iter.Eof(); iter.Next(tm.t) {
+			key, _ := iter.Cur()
+			off := fulltext.DataOff(key)
+			if i == 0 || containsOff(offs, off) {
+				next = append(next, off)
+			}
+		}
+		offs = next
+		if len(offs) == 0 {
+			break
+		}
+	}
+	if offs == nil {
+		offs = []uint64{}
+	}
+	tm.offs = offs
+}
+
+// containsOff returns whether off is in offs which must be sorted
+func containsOff(offs []uint64, off uint64) bool {
+	i := sort.Search(len(offs), func(i int) bool { return offs[i] >= off })
+	return i < len(offs) && offs[i] == off
+}
+
+// hasWords returns whether the column of row contains all the words.
+// It is used when the source is not a table.
+func (tm *TextMatch) hasWords(row runtime.Row) bool {
+	if tm.hdr == nil {
+		tm.hdr = tm.source.Header()
+	}
+	s, _ := row.GetVal(tm.hdr, tm.col, nil, nil).ToStr()
+	return sset.Subset(fulltext.Words(s), tm.words)
+}
+
+func (tm *TextMatch) Select(cols, vals []string) {
+	if tm.tbl == nil {
+		tm.source.Select(cols, vals)
+		return
+	}
+	tm.selCols, tm.selVals = cols, vals
+	tm.i = -1
+}
+
+func (tm *TextMatch) selected(row runtime.Row) bool {
+	if tm.selCols == nil {
+		return true
+	}
+	if tm.hdr == nil {
+		tm.hdr = tm.source.Header()
+	}
+	for i, col := range tm.selCols {
+		if row.GetRaw(tm.hdr, col) != tm.selVals[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (tm *TextMatch) Lookup(cols, vals []string) runtime.Row {
+	tm.Select(cols, vals)
+	row := tm.Get(runtime.Next)
+	tm.Select(nil, nil)
+	return row
+}
@@ -0,0 +1,96 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package query
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apmckinlay/gsuneido/db19"
+	"github.com/apmckinlay/gsuneido/db19/stor"
+	rt "github.com/apmckinlay/gsuneido/runtime"
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestTextMatch(t *testing.T) {
+	MakeSuTran = func(qt QueryTran) *rt.SuTran { return nil }
+	store := stor.HeapStor(8192)
+	db, err := db19.CreateDb(store)
+	ck(err)
+	db19.StartConcur(db, 50*time.Millisecond)
+	db19.MakeSuTran = func(ut *db19.UpdateTran) *rt.SuTran {
+		return rt.NewSuTran(nil, true)
+	}
+	act := func(act string) {
+		ut := db.NewUpdateTran()
+		defer ut.Commit()
+		DoAction(ut, act)
+	}
+	DoAdmin(db, "create notes (id, body) key(id) index text(body)")
+	act("insert { id: 1, body: 'The quick brown fox' } into notes")
+	act("insert { id: 2, body: 'a lazy brown dog' } into notes")
+	act("insert { id: 3, body: 'Quick, quick!' } into notes")
+	act("insert { id: 4 } into notes")
+
+	test := func(query string, expected string) {
+		t.Helper()
+		tran := db.NewReadTran()
+		q := ParseQuery(query, tran)
+		q, _ = Setup(q, ReadMode, tran)
+		hdr := q.Header()
+		var ids []string
+		for row := q.Get(rt.Next); row != nil; row = q.Get(rt.Next) {
+			ids = append(ids, row.GetVal(hdr, "id", nil, nil).String())
+		}
+		assert.T(t).This(strings.Join(ids, ",")).Is(expected)
+	}
+	test("notes where body matches 'brown'", "1,2")
+	test("notes where body matches 'QUICK'", "1,3")
+	test("notes where body matches 'quick brown'", "1")
+	test("notes where body matches 'quick dog'", "")
+	test("notes where body matches 'cat'", "")
+	test("notes where body matches 'brown' and id > 1", "2")
+	test("notes where body matches 'brown' sort reverse id", "2,1")
+	test("notes where id > 1 and body matches 'brown'", "2")
+	test("notes where body matches 'quick' and body matches 'fox'", "1")
+	test("notes where id < 3 where body matches 'brown'", "1,2")
+	test("notes extend x = 1 where body matches 'quick'", "1,3")
+	test("notes where id is 1 and id > 0 or id is 3", "1,3")
+	assert.T(t).This(func() {
+		ParseQuery("notes where id is 1 or body matches 'x'", db.NewReadTran())
+	}).Panics("matches must be a top level and term")
+	assert.T(t).This(func() {
+		ParseQuery("notes extend x = 1 where y matches 'x'", db.NewReadTran())
+	}).Panics("nonexistent column")
+	tran := db.NewReadTran()
+	q := ParseQuery("notes where body matches 'brown'", tran)
+	assert.T(t).That(q.Nrows() <= 4)
+
+	act("update notes where id is 1 set body = 'slow red fox'")
+	act("delete notes where id is 2")
+	test("notes where body matches 'brown'", "")
+	test("notes where body matches 'fox'", "1")
+
+	// close and reopen to force persist
+	db.Close()
+	db, err = db19.OpenDbStor(store, stor.READ, true)
+	ck(err)
+	db19.StartConcur(db, 50*time.Millisecond)
+	defer db.Close()
+	test("notes where body matches 'fox'", "1")
+	test("notes where body matches 'quick'", "3")
+	assert.T(t).This(db.Check()).Is(nil)
+
+	// create on existing data
+	DoAdmin(db, "create other (id, text) key(id)")
+	act("insert { id: 1, text: 'hello world' } into other")
+	act("insert { id: 2, text: 'goodbye world' } into other")
+	DoAdmin(db, "alter other create index text(text)")
+	test("other where text matches 'world'", "1,2")
+	test("other where text matches 'hello'", "1")
+
+	assert.T(t).This(func() { ParseQuery("notes where id matches 'x'", db.NewReadTran()) }).
+		Panics("no text index on id")
+}
@@ -18,7 +18,6 @@ import (
 	"github.com/apmckinlay/gsuneido/util/ints"
 	"github.com/apmckinlay/gsuneido/util/sset"
 	"github.com/apmckinlay/gsuneido/util/strs"
-	"github.com/apmckinlay/gsuneido/util/strss"
 )
 
 type Where struct {
@@ -707,6 +706,9 @@ func (w *Where) colSelsToIdxSels(colSels map[string]filter) []idxSel {
 	idxSels := make([]idxSel, 0, len(indexes)/2)
 	for i := range w.tbl.schema.Indexes {
 		schix := &w.tbl.schema.Indexes[i]
+		if schix.Mode == 't' || schix.HasDesc() {
+			continue
+		}
 		idx := schix.Columns
 		key := schix.Mode == 'k'
 		uniq := schix.Mode == 'u'
@@ -879,7 +881,7 @@ outer:
 }
 
 func (w *Where) idxFrac(idx []string, ptrngs []pointRange) float64 {
-	iIndex := strss.Index(w.tbl.indexes, idx)
+	iIndex := w.tbl.schemaIndex(idx)
 	if iIndex < 0 {
 		panic("index not found")
 	}
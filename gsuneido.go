@@ -7,7 +7,9 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"runtime"
@@ -18,6 +20,7 @@ import (
 	"github.com/apmckinlay/gsuneido/builtin"
 	"github.com/apmckinlay/gsuneido/compile"
 	"github.com/apmckinlay/gsuneido/db19"
+	"github.com/apmckinlay/gsuneido/db19/stor"
 	"github.com/apmckinlay/gsuneido/db19/tools"
 	"github.com/apmckinlay/gsuneido/dbms"
 	"github.com/apmckinlay/gsuneido/options"
@@ -31,16 +34,30 @@ var mode = ""                 // set by: go build -ldflags "-X main.mode=gui"
 var help = `options:
 	-check
 	-c[lient] [ipaddress] (default 127.0.0.1)
+	-conflicts abort|wait-die|wound-wait|block [seconds]
+	-disasm (with -repl)
 	-d[ump] [table]
+	-format file (writes to stdout)
+	-grow chunks [prealloc] (extend the database by up to chunks of 64mb)
 	-h[elp] or -?
 	-l[oad] [table]
+	-lang 1|2 (2 enables string interpolation)
+	-maxdbsize mb (limit the size of the database file)
+	-memlimit mb (limit the memory allocated per request)
 	-n[o]r[elaunch]
+	-pack2 (pack values so older versions can unpack them)
 	-p[ort] # (default 3147)
+	-pread [chunks] (file reads and writes, caching up to chunks of 64mb)
 	-repair
+	-replica address (read-only replica of the primary at host:port)
+	-replicas port (serve replicas on port)
 	-r[epl]
+	-salvage
 	-s[erver]
 	-u[nattended]
-	-v[ersion]`
+	-verify [always] (check btree node checksums when read)
+	-v[ersion]
+	-wal (write ahead log, suneido.wal)`
 
 // dbmsLocal is set if running with a local/standalone database.
 var dbmsLocal IDbms
@@ -120,6 +137,18 @@ func main() {
 			fmt.Println("repaired database in", time.Since(t).Round(time.Millisecond))
 		}
 		os.Exit(0)
+	case "salvage":
+		t := time.Now()
+		report, err := db19.Salvage("suneido.db")
+		ck(err)
+		fmt.Print(report)
+		fmt.Println("salvaged database in", time.Since(t).Round(time.Millisecond))
+		os.Exit(0)
+	case "format":
+		src, err := ioutil.ReadFile(options.Arg)
+		ck(err)
+		fmt.Println(compile.Format(string(src)))
+		os.Exit(0)
 	case "version":
 		Alert("gSuneido " + builtDate + " (" + runtime.Version() + " " +
 			runtime.GOARCH + " " + runtime.GOOS + ")")
@@ -248,9 +277,17 @@ func startServer() {
 }
 
 var db *db19.Database
+var replica *db19.Replica
 
 func openDbms() {
+	if options.ReplicaOf != "" {
+		openReplica()
+		return
+	}
 	var err error
+	stor.Grow = stor.GrowPolicy{MaxChunks: options.GrowChunks,
+		Prealloc: options.GrowPrealloc}
+	stor.PreadCacheChunks = options.PreadCache
 	db, err = db19.OpenDatabase("suneido.db")
 	if err != nil {
 		log.Println("ERROR:", err)
@@ -263,15 +300,70 @@ func openDbms() {
 			log.Fatalln(err)
 		}
 	}
+	db19.MaxDbSize = options.MaxDbSize
+	setConflicts()
 	db19.StartTimestamps()
 	db19.StartConcur(db, 10*time.Second) //1*time.Minute) //FIXME
+	if options.Wal {
+		if err := db.OpenWal("suneido.wal"); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if options.ReplicaPort != "" {
+		ln, err := net.Listen("tcp", ":"+options.ReplicaPort)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		go func() {
+			if err := db.ServeReplicas(ln); err != nil {
+				log.Println("replication:", err)
+			}
+		}()
+	}
 	dbmsLocal = dbms.NewDbmsLocal(db)
 	GetDbms = func() IDbms { return dbmsLocal }
 	exit.Add(dbmsLocal.Close)
 }
 
+// openReplica starts replicating from the primary
+// and serves (read-only) queries from the replica's database
+func openReplica() {
+	var err error
+	replica, err = db19.StartReplica("suneido.db", options.ReplicaOf)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	db = replica.Database
+	db19.StartTimestamps()
+	dbmsLocal = dbms.NewDbmsLocal(db)
+	GetDbms = func() IDbms { return dbmsLocal }
+	exit.Add(replica.Close)
+}
+
+// setConflicts sets the db19 conflict policy from the -conflicts option
+func setConflicts() {
+	switch options.Conflicts {
+	case "wait-die":
+		db19.Conflicts = db19.ConflictWaitDie
+	case "wound-wait":
+		db19.Conflicts = db19.ConflictWoundWait
+	case "block":
+		db19.Conflicts = db19.ConflictBlock
+	default:
+		db19.Conflicts = db19.ConflictAbort
+	}
+	if options.ConflictWait > 0 {
+		db19.ConflictWait = time.Duration(options.ConflictWait) * time.Second
+	}
+}
+
 func closeDbms() {
-	if db != nil {
+	if replica != nil {
+		replica.Close()
+		if err := replica.Err(); err != nil {
+			log.Println("replication:", err)
+		}
+	} else if db != nil {
 		db.Close()
 	}
 }
@@ -352,9 +444,12 @@ func eval(src string) {
 		fmt.Println("(" + s + ")")
 	}
 	fn := v.(*SuFunc)
-	// DisasmMixed(os.Stdout, fn, src)
+	if options.Disasm {
+		fmt.Print(DisasmDetail(fn))
+	}
 
 	mainThread.Reset()
+	mainThread.LimitMemory(options.ThreadMemLimit)
 	result := mainThread.Invoke(fn, nil)
 	if result != nil {
 		fmt.Println(WithType(result)) // NOTE: doesn't use ToString
@@ -362,6 +457,10 @@ func eval(src string) {
 }
 
 // libload loads a name from the dbms
+//
+// NOTE: Compiled code is not cached across runs (yet).
+// runtime.EncodeFunc can serialize functions,
+// but not classes or values that are not Packable (e.g. SuRegex)
 func libload(t *Thread, gn Gnum, name string) (result Value) {
 	defer func() {
 		if e := recover(); e != nil {
@@ -6,6 +6,7 @@
 package options
 
 import (
+	"math/bits"
 	"runtime"
 
 	"github.com/apmckinlay/gsuneido/util/ints"
@@ -22,6 +23,8 @@ var (
 	Port       string
 	Unattended bool
 	NoRelaunch bool
+	// Disasm makes the repl print the disassembled code before running it
+	Disasm bool
 )
 
 // CmdLine is the remaining command line arguments
@@ -39,6 +42,88 @@ const (
 	ClearCallbackDisabled = false
 )
 
+// LangVersion controls language features.
+// 2 enables "$(expr)" interpolation in double quoted strings.
+var LangVersion = 1
+
+// PackV2 restricts packing to version 2 of the format
+// so older clients and servers can unpack the results.
+// Buffers are packed as strings and dates lose their zone.
+// Set by the -pack2 command line option.
+var PackV2 = false
+
+// ThreadMemLimit is the limit in bytes on the approximate memory
+// allocated while handling a request
+// (a SocketServer connection or a repl expression). Zero means no limit.
+// Set by the -memlimit command line option (in megabytes)
+var ThreadMemLimit int64
+
+// MaxDbSize is the limit in bytes on the size of the database file,
+// beyond which commits that add data are aborted. Zero means no limit.
+// Set by the -maxdbsize command line option (in megabytes)
+var MaxDbSize uint64
+
+// Conflicts is the policy for write-write conflicts between
+// update transactions, one of abort (the default), wait-die,
+// wound-wait, or block.
+// ConflictWait is the maximum number of seconds a transaction waits.
+// Set by the -conflicts policy [seconds] command line option.
+var (
+	Conflicts    = "abort"
+	ConflictWait = 0
+)
+
+// StorPread makes the database access its file with reads and writes
+// and a limited cache, instead of memory mapping it,
+// so the database can be larger than the address space.
+// It is the default for 32 bit builds.
+// Set by the -pread command line option.
+var StorPread = bits.UintSize == 32
+
+// PreadCache is the number of chunks (64mb) that -pread keeps in memory,
+// not counting the last chunks which are pinned while they may be written.
+// The default is 2 for 32 bit builds, because of the address space, else 8.
+// Set by the -pread [chunks] command line option.
+var PreadCache = 2 + 6*(bits.UintSize/64)
+
+// VerifyNodes controls verifying the checksums of btree nodes
+// when they are read, to catch corruption near its source.
+// VerifyFirst verifies each node the first time it is read
+// (with -pread, each time its chunk is read from the file),
+// VerifyAlways verifies every read (slower, for debugging).
+// Set by the -verify [always] command line option.
+var VerifyNodes = VerifyNone
+
+// GrowChunks is the maximum number of chunks (64mb)
+// to extend the database file by at a time.
+// GrowPrealloc allocates the disk space for the extension
+// (where supported) instead of extending the file sparsely.
+// Set by the -grow chunks [prealloc] command line option.
+var (
+	GrowChunks   = 4
+	GrowPrealloc = false
+)
+
+// Wal enables the write ahead log (suneido.wal)
+// so a crash does not lose the commits since the last persist.
+// Set by the -wal command line option.
+var Wal = false
+
+// ReplicaPort is the port to serve replicas on (primary).
+// Set by the -replicas port command line option.
+var ReplicaPort string
+
+// ReplicaOf is the address (host:port) of the primary to replicate.
+// The database is then a read-only replica.
+// Set by the -replica address command line option.
+var ReplicaOf string
+
+const (
+	VerifyNone = iota
+	VerifyFirst
+	VerifyAlways
+)
+
 // Coverage controls whether Cover op codes are added by codegen.
 // Should be accessed atomically. Zero means disabled.
 var Coverage int64
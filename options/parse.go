@@ -4,6 +4,7 @@
 package options
 
 import (
+	"strconv"
 	"strings"
 )
 
@@ -29,9 +30,16 @@ loop:
 			args = optionalArg(args)
 		case match(&args, "-repair"):
 			setAction("repair")
+		case match(&args, "-salvage"):
+			setAction("salvage")
 		case match(&args, "-dump"), match(&args, "-d"):
 			setAction("dump")
 			args = optionalArg(args)
+		case match(&args, "-format"):
+			setAction("format")
+			if args = optionalArg(args); Arg == "" {
+				error("-format requires a file name")
+			}
 		case match(&args, "-load"), match(&args, "-l"):
 			setAction("load")
 			args = optionalArg(args)
@@ -58,6 +66,106 @@ loop:
 			// for compatibility with cSuneido
 		case match(&args, "-norelaunch"), match(&args, "-nr"):
 			NoRelaunch = true
+		case match(&args, "-disasm"):
+			Disasm = true
+		case match(&args, "-pack2"):
+			PackV2 = true
+		case match(&args, "-pread"):
+			StorPread = true
+			if len(args) > 0 && args[0][0] != '-' {
+				if n, err := strconv.Atoi(args[0]); err == nil && n >= 1 {
+					PreadCache = n
+					args = args[1:]
+				} else {
+					error("-pread requires a number of chunks")
+				}
+			}
+		case match(&args, "-verify"):
+			VerifyNodes = VerifyFirst
+			if len(args) > 0 && args[0] == "always" {
+				VerifyNodes = VerifyAlways
+				args = args[1:]
+			}
+		case match(&args, "-wal"):
+			Wal = true
+		case match(&args, "-replicas"):
+			if len(args) > 0 && args[0][0] != '-' {
+				ReplicaPort = args[0]
+				args = args[1:]
+			} else {
+				error("-replicas requires a port number")
+			}
+		case match(&args, "-replica"):
+			if len(args) > 0 && args[0][0] != '-' {
+				ReplicaOf = args[0]
+				args = args[1:]
+			} else {
+				error("-replica requires the address of the primary")
+			}
+		case match(&args, "-grow"):
+			n := 0
+			if len(args) > 0 {
+				if x, err := strconv.Atoi(args[0]); err == nil {
+					n = x
+					args = args[1:]
+				}
+			}
+			if n < 1 {
+				error("-grow requires a number of chunks")
+			} else {
+				GrowChunks = n
+				if len(args) > 0 && args[0] == "prealloc" {
+					GrowPrealloc = true
+					args = args[1:]
+				}
+			}
+		case match(&args, "-lang"):
+			if len(args) > 0 && (args[0] == "1" || args[0] == "2") {
+				LangVersion = int(args[0][0] - '0')
+				args = args[1:]
+			} else {
+				error("-lang requires 1 or 2")
+			}
+		case match(&args, "-memlimit"):
+			mb := -1
+			if len(args) > 0 {
+				if n, err := strconv.Atoi(args[0]); err == nil {
+					mb = n
+					args = args[1:]
+				}
+			}
+			if mb < 0 {
+				error("-memlimit requires a number of megabytes")
+			} else {
+				ThreadMemLimit = int64(mb) << 20
+			}
+		case match(&args, "-conflicts"):
+			if len(args) > 0 && (args[0] == "abort" || args[0] == "wait-die" ||
+				args[0] == "wound-wait" || args[0] == "block") {
+				Conflicts = args[0]
+				args = args[1:]
+				if len(args) > 0 {
+					if n, err := strconv.Atoi(args[0]); err == nil && n > 0 {
+						ConflictWait = n
+						args = args[1:]
+					}
+				}
+			} else {
+				error("-conflicts requires abort, wait-die, wound-wait, or block")
+			}
+		case match(&args, "-maxdbsize"):
+			mb := -1
+			if len(args) > 0 {
+				if n, err := strconv.Atoi(args[0]); err == nil {
+					mb = n
+					args = args[1:]
+				}
+			}
+			if mb < 0 {
+				error("-maxdbsize requires a number of megabytes")
+			} else {
+				MaxDbSize = uint64(mb) << 20
+			}
 		case match(&args, "--"):
 			break loop
 		default:
@@ -71,6 +179,9 @@ loop:
 		error("port should only be specifed with -server or -client, not " +
 			Action)
 	}
+	if ReplicaOf != "" && (ReplicaPort != "" || Wal) {
+		error("a replica can't have -replicas or -wal")
+	}
 	if Port == "" && (Action == "client" || Action == "server") {
 		Port = "3147"
 	}
@@ -58,11 +58,64 @@ func TestParse(t *testing.T) {
 	test("-client", "1.2.3.4", "foo", "bar")("client 1.2.3.4 | foo bar")
 	test("-load")("load")
 	test("-load", "stdlib")("load stdlib")
+	test("-format", "foo.txt")("format foo.txt")
+	test("-format")("error")
 	test("-dump")("dump")
 	test("-dump", "stdlib")("dump stdlib")
 	test("-server")("server")
 	test("-repair")("repair")
+	test("-salvage")("salvage")
 	test("-xyz")("error")
+	test("-memlimit")("error")
+	test("-memlimit", "x")("error")
+	test("-memlimit", "100", "-repl")("repl")
+	assert.T(t).This(ThreadMemLimit).Is(int64(100 << 20))
+	ThreadMemLimit = 0
+	test("-verify", "-server")("server")
+	assert.T(t).This(VerifyNodes).Is(VerifyFirst)
+	test("-verify", "always", "-server")("server")
+	assert.T(t).This(VerifyNodes).Is(VerifyAlways)
+	VerifyNodes = VerifyNone
+	test("-grow", "8", "prealloc", "-server")("server")
+	assert.T(t).This(GrowChunks).Is(8)
+	assert.T(t).True(GrowPrealloc)
+	test("-grow", "2", "-server")("server")
+	assert.T(t).This(GrowChunks).Is(2)
+	GrowChunks, GrowPrealloc = 4, false
+	test("-grow", "-server")("error")
+	test("-pread", "-server")("server")
+	assert.T(t).True(StorPread)
+	test("-pread", "3", "-server")("server")
+	assert.T(t).This(PreadCache).Is(3)
+	test("-pread", "x", "-server")("error")
+	StorPread, PreadCache = false, 8
+	test("-pack2", "-client")("client 127.0.0.1")
+	assert.T(t).True(PackV2)
+	PackV2 = false
+	test("-wal", "-server")("server")
+	assert.T(t).True(Wal)
+	Wal = false
+	test("-replicas", "3148", "-server")("server")
+	assert.T(t).This(ReplicaPort).Is("3148")
+	ReplicaPort = ""
+	test("-replica", "primary:3148", "-server")("server")
+	assert.T(t).This(ReplicaOf).Is("primary:3148")
+	test("-replica", "primary:3148", "-wal")("error")
+	ReplicaOf = ""
+	Wal = false
+	test("-replicas")("error")
+	test("-maxdbsize", "100", "-server")("server")
+	assert.T(t).This(MaxDbSize).Is(uint64(100 << 20))
+	MaxDbSize = 0
+	test("-maxdbsize", "-server")("error")
+	test("-conflicts", "wait-die", "-server")("server")
+	assert.T(t).This(Conflicts).Is("wait-die")
+	assert.T(t).This(ConflictWait).Is(0)
+	test("-conflicts", "block", "10", "-server")("server")
+	assert.T(t).This(Conflicts).Is("block")
+	assert.T(t).This(ConflictWait).Is(10)
+	Conflicts, ConflictWait = "abort", 0
+	test("-conflicts", "wait")("error")
 }
 
 func TestEscapeArg(t *testing.T) {
@@ -78,6 +78,7 @@ func (t *Thread) massage(ps *ParamSpec, as *ArgSpec, args []Value) {
 			return
 		}
 		// args => @param
+		t.Alloc(MemberSize * len(args))
 		ob := &SuObject{}
 		for i := 0; i < unnamed; i++ {
 			ob.Add(args[i])
@@ -137,9 +138,10 @@ func (t *Thread) massage(ps *ParamSpec, as *ArgSpec, args []Value) {
 	}
 
 	// fill in defaults
+	// (expression defaults are evaluated by the function)
 	noDefs := int(ps.Nparams - ps.Ndefaults)
 	for i := noDefs; i < int(ps.Nparams); i++ {
-		if args[i] == nil {
+		if args[i] == nil && ps.Flags[i]&ExprParam == 0 {
 			args[i] = ps.Values[i-noDefs]
 		}
 	}
@@ -88,7 +88,7 @@ func TestArgs(t *testing.T) {
 	f = &ParamSpec{Nparams: 3, Flags: []Flag{0, 0, 0},
 		Names: []string{"a", "b", "c"}}
 	as = &ArgSpec{Nargs: 4,
-		Names: vals("c", "b", "a", "d"), Spec: []byte{1, 0, 2, 3}} // b, c, a, d
+		Names: vals("c", "b", "a", "d"), Spec: []uint16{1, 0, 2, 3}} // b, c, a, d
 	setStack(22, 33, 11, 44)
 	th.Args(f, as)
 	ckStack(11, 22, 33)
@@ -97,7 +97,7 @@ func TestArgs(t *testing.T) {
 	f = &ParamSpec{Nparams: 4, Flags: []Flag{0, 0, 0},
 		Names: []string{"a", "b", "c", "d"}}
 	as = &ArgSpec{Nargs: 4,
-		Names: vals("c", "b", "a", "d"), Spec: []byte{3, 0}} // d, c
+		Names: vals("c", "b", "a", "d"), Spec: []uint16{3, 0}} // d, c
 	setStack(22, 33, 11, 44) // fn(22, 33, d: 11, c: 44)
 	th.Args(f, as)
 	ckStack(22, 33, 44, 11)
@@ -105,8 +105,8 @@ func TestArgs(t *testing.T) {
 	// args => @param
 	f = atParamSpec
 	as = &ArgSpec{Nargs: 4,
-		Names: vals("c", "b", "a", "d"), Spec: []byte{1, 2}} // b, a
-	setStack(11, 22, 44, 33)
+		Names: vals("c", "b", "a", "d"), Spec: []uint16{2, 1}} // a, b
+	setStack(11, 22, 33, 44)
 	th.Args(f, as)
 	assert(th.sp).Is(1)
 	assert(th.stack[0].String()).Is(makeOb().String())
@@ -25,7 +25,7 @@ type ArgSpec struct {
 	Signature byte
 
 	// Spec has one entry per named argument, indexing into Names
-	Spec []byte
+	Spec []uint16
 
 	// Names is the argument names from the calling function
 	Names []Value
@@ -53,7 +53,7 @@ var ArgSpec4 = ArgSpec{Nargs: 4, Signature: Sig4}
 var ArgSpecEach0 = ArgSpec{Nargs: 1, Each: EACH0}
 var ArgSpecEach1 = ArgSpec{Nargs: 1, Each: EACH1}
 var ArgSpecBlock = ArgSpec{Nargs: 1,
-	Spec: []byte{0}, Names: []Value{SuStr("block")}}
+	Spec: []uint16{0}, Names: []Value{SuStr("block")}}
 
 var StdArgSpecs = [...]ArgSpec{
 	ArgSpec0,
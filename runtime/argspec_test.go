@@ -19,9 +19,9 @@ func TestArgSpecString(t *testing.T) {
 	test(&ArgSpecEach0, "ArgSpec(@)")
 	test(&ArgSpecEach1, "ArgSpec(@+1)")
 	test(&ArgSpecBlock, "ArgSpec(block:)")
-	test(&ArgSpec{Nargs: 0, Spec: []byte{2, 0, 1}, Names: vals("a", "b", "c")},
+	test(&ArgSpec{Nargs: 0, Spec: []uint16{2, 0, 1}, Names: vals("a", "b", "c")},
 		"ArgSpec(c:, a:, b:)")
-	test(&ArgSpec{Nargs: 4, Spec: []byte{2, 1}, Names: vals("a", "b", "c", "d")},
+	test(&ArgSpec{Nargs: 4, Spec: []uint16{2, 1}, Names: vals("a", "b", "c", "d")},
 		"ArgSpec(?, ?, c:, b:)")
 }
 
@@ -32,8 +32,8 @@ func TestArgSpecEqual(t *testing.T) {
 		&ArgSpecEach0,
 		&ArgSpecEach1,
 		&ArgSpecBlock,
-		{Nargs: 2, Spec: []byte{0, 1}, Names: []Value{SuStr("foo"), SuStr("bar")}},
-		{Nargs: 2, Spec: []byte{0, 1}, Names: []Value{SuStr("foo"), SuStr("baz")}},
+		{Nargs: 2, Spec: []uint16{0, 1}, Names: []Value{SuStr("foo"), SuStr("bar")}},
+		{Nargs: 2, Spec: []uint16{0, 1}, Names: []Value{SuStr("foo"), SuStr("baz")}},
 	}
 	for i, x := range as {
 		for j, y := range as {
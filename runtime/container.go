@@ -25,6 +25,19 @@ type Container interface {
 	ToObject() *SuObject
 	ToRecord(t *Thread, hdr *Header) Record
 	IsConcurrent() Value
+
+	// These compound operations take the lock once so they are atomic,
+	// unlike e.g. GetIfPresent followed by Put
+
+	// GetInit returns the value of key if present,
+	// otherwise it sets key to val and returns val
+	GetInit(t *Thread, key, val Value) Value
+	// Increment adds by to the value of key (missing is treated as 0)
+	// and returns the new value
+	Increment(t *Thread, key, by Value) Value
+	// AddUnique adds val to the list unless it is already in the list.
+	// It returns whether val was added.
+	AddUnique(val Value) bool
 }
 
 // iterators
@@ -0,0 +1,96 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+// DeepCopy returns a copy of x with nested objects and records also copied.
+// Shared substructure and cycles are preserved in the copy
+// i.e. if an object is referenced twice, so is its copy.
+// If maxDepth is > 0, containers nested deeper than that are not copied
+// (they are shared with the original).
+// Other values (including instances) are not copied.
+//
+// It uses a work list rather than recursion
+// so it will not overflow the stack on deeply nested data.
+func DeepCopy(x Value, maxDepth int) Value {
+	if !isCopyable(x) {
+		return x
+	}
+	dc := deepCopier{copies: make(map[Value]Value), maxDepth: maxDepth}
+	cp := dc.copy(x, 0)
+	for len(dc.todo) > 0 {
+		item := dc.todo[len(dc.todo)-1]
+		dc.todo = dc.todo[:len(dc.todo)-1]
+		dc.members(item.ob, item.depth)
+	}
+	return cp
+}
+
+type deepCopier struct {
+	// copies maps the originals to their copies
+	copies   map[Value]Value
+	todo     []copyItem
+	maxDepth int
+}
+
+type copyItem struct {
+	ob    *SuObject
+	depth int
+}
+
+func isCopyable(x Value) bool {
+	switch x.(type) {
+	case *SuObject, *SuRecord:
+		return true
+	}
+	return false
+}
+
+// copy makes a shallow copy and adds it to the work list
+// so its members will be copied
+func (dc *deepCopier) copy(x Value, depth int) Value {
+	var cp Value
+	var ob *SuObject
+	switch x := x.(type) {
+	case *SuObject:
+		c := x.Copy().(*SuObject)
+		cp, ob = c, c
+	case *SuRecord:
+		// row values are unpacked when they are accessed so they aren't shared
+		c := x.Copy().(*SuRecord)
+		cp, ob = c, &c.ob
+	}
+	dc.copies[x] = cp
+	dc.todo = append(dc.todo, copyItem{ob: ob, depth: depth})
+	return cp
+}
+
+// members replaces the container members of ob (a new copy) with copies
+func (dc *deepCopier) members(ob *SuObject, depth int) {
+	if dc.maxDepth > 0 && depth >= dc.maxDepth {
+		return
+	}
+	for i, v := range ob.list {
+		ob.list[i] = dc.member(v, depth+1)
+	}
+	var keys []Value
+	iter := ob.named.Iter()
+	for k, v := iter(); k != nil; k, v = iter() {
+		if isCopyable(v) {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		ob.named.Put(k, dc.member(ob.named.Get(k), depth+1))
+	}
+}
+
+func (dc *deepCopier) member(v Value, depth int) Value {
+	if !isCopyable(v) {
+		return v
+	}
+	if cp, ok := dc.copies[v]; ok {
+		return cp
+	}
+	return dc.copy(v, depth)
+}
@@ -0,0 +1,67 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestDeepCopy(t *testing.T) {
+	assert := assert.T(t)
+	assert.This(DeepCopy(SuInt(123), 0)).Is(SuInt(123))
+
+	inner := SuObjectOf(SuInt(1), SuInt(2))
+	ob := SuObjectOf(inner, inner)
+	ob.Set(SuStr("named"), inner)
+	cp := DeepCopy(ob, 0).(*SuObject)
+	assert.This(cp).Is(ob)
+	in0 := cp.ListGet(0).(*SuObject)
+	assert.That(in0 != inner)
+	// aliasing is preserved
+	assert.That(cp.ListGet(1) == in0)
+	assert.That(cp.Get(nil, SuStr("named")) == in0)
+	in0.Add(SuInt(3))
+	assert.This(inner.ListSize()).Is(2)
+
+	// records
+	rec := NewSuRecord()
+	rec.Set(SuStr("ob"), inner)
+	cp2 := DeepCopy(SuObjectOf(rec), 0).(*SuObject)
+	rec2 := cp2.ListGet(0).(*SuRecord)
+	assert.That(rec2 != rec)
+	assert.That(rec2.Get(nil, SuStr("ob")) != inner)
+	assert.This(rec2.Get(nil, SuStr("ob"))).Is(inner)
+}
+
+func TestDeepCopyCycle(t *testing.T) {
+	assert := assert.T(t)
+	ob := &SuObject{}
+	ob.Add(ob)
+	cp := DeepCopy(ob, 0).(*SuObject)
+	assert.That(cp != ob)
+	assert.That(cp.ListGet(0) == cp)
+}
+
+func TestDeepCopyDepth(t *testing.T) {
+	assert := assert.T(t)
+	c := SuObjectOf(SuInt(1))
+	b := SuObjectOf(c)
+	a := SuObjectOf(b)
+	cp := DeepCopy(a, 1).(*SuObject)
+	b2 := cp.ListGet(0).(*SuObject)
+	assert.That(b2 != b)
+	assert.That(b2.ListGet(0) == c) // not copied
+
+	// deep nesting doesn't overflow the stack
+	deep := &SuObject{}
+	x := deep
+	for i := 0; i < 100000; i++ {
+		y := &SuObject{}
+		x.Add(y)
+		x = y
+	}
+	DeepCopy(deep, 0)
+}
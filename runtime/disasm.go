@@ -6,6 +6,8 @@ package runtime
 import (
 	"fmt"
 	"math"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/apmckinlay/gsuneido/compile/tokens"
@@ -89,6 +91,91 @@ func DisasmMixed(fn *SuFunc, src string) string {
 	return sb.String()
 }
 
+// DisasmDetail returns a complete listing of fn
+// including its parameters, locals, values, and argspecs.
+// Jump targets are shown as labels and line numbers are shown
+// (if known) when they change.
+// Nested functions and blocks are listed afterwards.
+func DisasmDetail(fn *SuFunc) string {
+	var sb strings.Builder
+	disasmDetail(&sb, fn, "")
+	return sb.String()
+}
+
+func disasmDetail(sb *strings.Builder, fn *SuFunc, prefix string) {
+	name := fn.String()
+	if prefix != "" {
+		name = prefix + " " + name
+	}
+	fmt.Fprintln(sb, name)
+	fmt.Fprintln(sb, "params:", fn.Params())
+	if len(fn.Names) > 0 {
+		fmt.Fprintln(sb, "locals:", strings.Join(fn.Names, ", "))
+	}
+	if len(fn.Values) > 0 {
+		fmt.Fprintln(sb, "values:")
+		for i, v := range fn.Values {
+			fmt.Fprintf(sb, "%5d: %s\n", i, disasmValue(v))
+		}
+	}
+	if len(fn.ArgSpecs) > 0 {
+		fmt.Fprintln(sb, "argspecs:")
+		for i := range fn.ArgSpecs {
+			fmt.Fprintf(sb, "%5d: %s\n", i+len(StdArgSpecs),
+				fn.ArgSpecs[i].String()[7:])
+		}
+	}
+	fmt.Fprintln(sb, "code:")
+	d := &dasm{fn: fn, flat: true, labels: map[int]string{}}
+	d.out = func(*SuFunc, int, int, string, int) {}
+	for d.i < len(fn.Code) {
+		d.next() // first pass to find the jump targets
+	}
+	targets := make([]int, 0, len(d.labels))
+	for t := range d.labels {
+		targets = append(targets, t)
+	}
+	sort.Ints(targets)
+	for i, t := range targets {
+		d.labels[t] = "L" + strconv.Itoa(i+1)
+	}
+	line := 0
+	d.i = 0
+	d.out = func(_ *SuFunc, _, i int, s string, _ int) {
+		if lbl, ok := d.labels[i]; ok {
+			fmt.Fprintf(sb, "  %s:\n", lbl)
+		}
+		if ln := fn.CodeToLine(i); ln != line {
+			line = ln
+			fmt.Fprintf(sb, "  line %d\n", ln)
+		}
+		fmt.Fprintf(sb, "%9d: %s\n", i, s)
+	}
+	for d.i < len(fn.Code) {
+		d.next()
+	}
+	if lbl, ok := d.labels[len(fn.Code)]; ok {
+		fmt.Fprintf(sb, "  %s:\n", lbl)
+	}
+	for i, v := range fn.Values {
+		if f, ok := v.(*SuFunc); ok && len(f.Code) > 0 {
+			sb.WriteString("\n")
+			disasmDetail(sb, f, fmt.Sprintf("%svalue %d:", prefix, i)) // RECURSE
+		}
+	}
+}
+
+func disasmValue(v Value) string {
+	if _, ok := v.(*SuFunc); ok {
+		return v.String()
+	}
+	s := fmt.Sprint(v)
+	if len(s) > 70 {
+		s = s[:70] + "..."
+	}
+	return s
+}
+
 type outfn func(fn *SuFunc, nest, i int, s string, srcLim int)
 
 // Disasm calls out for each disassembled byte code instruction in fn
@@ -108,6 +195,23 @@ type dasm struct {
 	i    int
 	nest int
 	out  outfn
+	// flat means don't disassemble nested functions
+	flat bool
+	// labels is used by DisasmDetail to show jump targets as labels.
+	// If it is not nil, jump targets are added to it.
+	labels map[int]string
+}
+
+// target formats a jump target
+func (d *dasm) target(t int) string {
+	if d.labels == nil {
+		return fmt.Sprint(" ", t)
+	}
+	lbl, ok := d.labels[t]
+	if !ok {
+		d.labels[t] = ""
+	}
+	return " " + lbl
 }
 
 func (d *dasm) next() {
@@ -133,20 +237,34 @@ func (d *dasm) next() {
 	case op.Int:
 		n := fetchInt16()
 		s += fmt.Sprint(" ", n)
-	case op.Value:
-		v := d.fn.Values[fetchUint8()]
+	case op.Value, op.Value16, op.GetMem:
+		var v Value
+		if oc == op.Value16 {
+			v = d.fn.Values[fetchUint16()]
+		} else {
+			v = d.fn.Values[fetchUint8()]
+		}
 		s += fmt.Sprintf(" %v", v)
 		if f, ok := v.(*SuFunc); ok {
 			nestedfn = f
 		}
 	case op.Closure:
-		f := d.fn.Values[fetchUint8()].(*SuFunc)
-		nestedfn = f
+		nestedfn = d.fn.Values[fetchUint8()].(*SuFunc)
+	case op.Closure16:
+		nestedfn = d.fn.Values[fetchUint16()].(*SuFunc)
 	case op.Load, op.Store, op.Dyload:
 		idx := fetchUint8()
 		s += " " + d.fn.Names[idx]
-	case op.LoadStore:
-		idx := fetchUint8()
+	case op.Load16, op.Store16, op.Dyload16:
+		idx := fetchUint16()
+		s += " " + d.fn.Names[idx]
+	case op.LoadStore, op.LoadStore16:
+		var idx int
+		if oc == op.LoadStore {
+			idx = int(fetchUint8())
+		} else {
+			idx = fetchUint16()
+		}
 		s += " " + d.fn.Names[idx]
 		fallthrough
 	case op.GetPut:
@@ -162,15 +280,31 @@ func (d *dasm) next() {
 	case op.Jump, op.JumpTrue, op.JumpFalse, op.And, op.Or, op.QMark, op.In, op.JumpIs,
 		op.JumpIsnt, op.Catch:
 		j := fetchInt16()
-		s += fmt.Sprint(" ", d.i+j)
-	case op.ForIn:
+		s += d.target(d.i + j)
+	case op.JumpTable:
+		lo := fetchInt16()
+		n := int(fetchUint8())
+		end := d.i + 2*n
+		s += fmt.Sprint(" ", lo)
+		for k := 0; k < n; k++ {
+			s += d.target(end + fetchInt16())
+		}
+	case op.ForIn, op.JumpSet:
 		j := fetchInt16()
 		idx := fetchUint8()
-		s += " " + d.fn.Names[idx] + fmt.Sprint(" ", d.i+j-1)
+		s += " " + d.fn.Names[idx] + d.target(d.i+j-1)
+	case op.ForIn16:
+		j := fetchInt16()
+		idx := fetchUint16()
+		s += " " + d.fn.Names[idx] + d.target(d.i+j-2)
 	case op.Try:
 		j := fetchInt16()
 		v := d.fn.Values[fetchUint8()]
-		s += fmt.Sprintf(" %d %v", d.i+j-1, v)
+		s += d.target(d.i+j-1) + fmt.Sprintf(" %v", v)
+	case op.Try16:
+		j := fetchInt16()
+		v := d.fn.Values[fetchUint16()]
+		s += d.target(d.i+j-2) + fmt.Sprintf(" %v", v)
 	case op.CallFuncDiscard, op.CallFuncNoNil, op.CallFuncNilOk,
 		op.CallMethDiscard, op.CallMethNoNil, op.CallMethNilOk:
 		ai := int(fetchUint8())
@@ -186,7 +320,7 @@ func (d *dasm) next() {
 		srcLim = nestedfn.SrcBase
 	}
 	d.out(d.fn, d.nest, ip, s, srcLim)
-	if nestedfn != nil && nestedfn.SrcBase > 0 {
+	if nestedfn != nil && nestedfn.SrcBase > 0 && !d.flat {
 		disasm(d.nest+1, nestedfn, d.out) // recursive
 	}
 }
@@ -195,16 +329,21 @@ func DisasmRaw(code string, fn func(i int)) {
 	for i := 0; i < len(code); i++ {
 		fn(i)
 		switch op.Opcode(code[i]) {
-		case op.Value, op.Closure, op.Load, op.Store, op.Dyload,
+		case op.Value, op.GetMem, op.Closure, op.Load, op.Store, op.Dyload,
 			op.GetPut, op.CallFuncDiscard, op.CallFuncNoNil, op.CallFuncNilOk,
 			op.CallMethDiscard, op.CallMethNoNil, op.CallMethNilOk:
 			i++
-		case op.Int, op.LoadStore, op.Global, op.Super,
+		case op.Int, op.Value16, op.Load16, op.Store16, op.Dyload16,
+			op.Closure16, op.LoadStore, op.Global, op.Super,
 			op.Jump, op.JumpTrue, op.JumpFalse, op.JumpIs, op.JumpIsnt,
 			op.And, op.Or, op.QMark, op.In, op.Catch:
 			i += 2
-		case op.ForIn, op.Try:
+		case op.ForIn, op.JumpSet, op.Try, op.LoadStore16:
 			i += 3
+		case op.ForIn16, op.Try16:
+			i += 4
+		case op.JumpTable:
+			i += 3 + 2*int(code[i+3])
 		}
 	}
 }
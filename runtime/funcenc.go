@@ -0,0 +1,253 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	op "github.com/apmckinlay/gsuneido/runtime/opcodes"
+	"github.com/apmckinlay/gsuneido/util/pack"
+)
+
+// funcMagic and funcVersion start an encoded function.
+// funcVersion must be incremented if the encoding or the op codes change.
+const funcMagic = "SuFunc"
+const funcVersion = 5
+
+// value tags
+const (
+	encNil = iota
+	encPacked
+	encFunc
+)
+
+// EncodeFunc returns a versioned binary encoding of a compiled function
+// including its Values and ArgSpecs and any nested functions.
+// Globals are encoded by name since global numbers vary between runs.
+// Coverage is not included.
+// It panics if a value is not Packable (e.g. a class or a regex)
+func EncodeFunc(fn *SuFunc) string {
+	e := &funcEncoder{buf: make([]byte, 0, 256)}
+	e.buf = append(e.buf, funcMagic...)
+	e.int(funcVersion)
+	e.fn(fn)
+	return string(e.buf)
+}
+
+type funcEncoder struct {
+	buf []byte
+}
+
+func (e *funcEncoder) int(n int) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, b[:binary.PutUvarint(b[:], uint64(n))]...)
+}
+
+func (e *funcEncoder) bool(b bool) {
+	if b {
+		e.int(1)
+	} else {
+		e.int(0)
+	}
+}
+
+func (e *funcEncoder) str(s string) {
+	e.int(len(s))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *funcEncoder) strs(list []string) {
+	e.int(len(list))
+	for _, s := range list {
+		e.str(s)
+	}
+}
+
+func (e *funcEncoder) fn(fn *SuFunc) {
+	e.str(fn.Lib)
+	e.str(fn.Name)
+	e.str(fn.ClassName)
+	e.int(int(fn.Nparams))
+	e.int(int(fn.Ndefaults))
+	e.int(int(fn.Offset))
+	e.int(int(fn.Signature))
+	e.int(int(fn.Nlocals))
+	e.bool(fn.IsBlock)
+	e.strs(fn.Names)
+	e.int(len(fn.Flags))
+	for _, f := range fn.Flags {
+		e.int(int(f))
+	}
+	e.int(len(fn.Values))
+	for _, v := range fn.Values {
+		e.value(v)
+	}
+	e.int(len(fn.ArgSpecs))
+	for _, as := range fn.ArgSpecs {
+		e.int(int(as.Nargs))
+		e.int(int(as.Each))
+		e.int(int(as.Signature))
+		e.int(len(as.Spec))
+		for _, i := range as.Spec {
+			e.int(int(i))
+		}
+	}
+	e.str(fn.Code)
+	e.globals(fn.Code)
+	e.str(fn.SrcPos)
+	e.int(fn.SrcBase)
+	e.str(fn.Lines)
+	e.int(fn.LineBase)
+}
+
+func (e *funcEncoder) value(v Value) {
+	switch v := v.(type) {
+	case nil:
+		e.int(encNil)
+	case *SuFunc:
+		e.int(encFunc)
+		e.fn(v)
+	case Packable:
+		e.int(encPacked)
+		e.str(Pack(v))
+	default:
+		panic("EncodeFunc: can't encode " + ErrType(v))
+	}
+}
+
+// globals encodes the code offset and name of each global reference
+func (e *funcEncoder) globals(code string) {
+	var refs []int
+	DisasmRaw(code, func(i int) {
+		switch op.Opcode(code[i]) {
+		case op.Global, op.Super:
+			refs = append(refs, i+1)
+		}
+	})
+	e.int(len(refs))
+	for _, i := range refs {
+		e.int(i)
+		e.str(Global.Name(int(code[i])<<8 + int(code[i+1])))
+	}
+}
+
+//-------------------------------------------------------------------
+
+// DecodeFunc returns the function encoded by EncodeFunc.
+// It panics if the data is not an encoded function
+// or was encoded by a different version.
+func DecodeFunc(s string) *SuFunc {
+	if !strings.HasPrefix(s, funcMagic) {
+		panic("DecodeFunc: invalid data")
+	}
+	d := &funcDecoder{Decoder: pack.NewDecoder(s[len(funcMagic):])}
+	if v := d.int(); v != funcVersion {
+		panic("DecodeFunc: version " + strconv.Itoa(v) +
+			" does not match " + strconv.Itoa(funcVersion))
+	}
+	fn := d.fn()
+	if d.Remaining() != 0 {
+		panic("DecodeFunc: invalid data")
+	}
+	return fn
+}
+
+type funcDecoder struct {
+	*pack.Decoder
+}
+
+func (d *funcDecoder) int() int {
+	return int(d.VarUint())
+}
+
+func (d *funcDecoder) str() string {
+	return d.Get(d.int())
+}
+
+func (d *funcDecoder) strs() []string {
+	n := d.int()
+	if n == 0 {
+		return nil
+	}
+	list := make([]string, n)
+	for i := range list {
+		list[i] = d.str()
+	}
+	return list
+}
+
+func (d *funcDecoder) fn() *SuFunc {
+	fn := &SuFunc{}
+	fn.Lib = d.str()
+	fn.Name = d.str()
+	fn.ClassName = d.str()
+	fn.Nparams = uint8(d.int())
+	fn.Ndefaults = uint8(d.int())
+	fn.Offset = uint16(d.int())
+	fn.Signature = byte(d.int())
+	fn.Nlocals = uint16(d.int())
+	fn.IsBlock = d.int() == 1
+	fn.Names = d.strs()
+	if n := d.int(); n > 0 {
+		fn.Flags = make([]Flag, n)
+		for i := range fn.Flags {
+			fn.Flags[i] = Flag(d.int())
+		}
+	}
+	if n := d.int(); n > 0 {
+		fn.Values = make([]Value, n)
+		for i := range fn.Values {
+			fn.Values[i] = d.value()
+		}
+	}
+	if n := d.int(); n > 0 {
+		fn.ArgSpecs = make([]ArgSpec, n)
+		for i := range fn.ArgSpecs {
+			as := &fn.ArgSpecs[i]
+			as.Nargs = byte(d.int())
+			as.Each = byte(d.int())
+			as.Signature = byte(d.int())
+			if n := d.int(); n > 0 {
+				as.Spec = make([]uint16, n)
+				for j := range as.Spec {
+					as.Spec[j] = uint16(d.int())
+				}
+			}
+			as.Names = fn.Values
+		}
+	}
+	fn.Code = d.code()
+	fn.SrcPos = d.str()
+	fn.SrcBase = d.int()
+	fn.Lines = d.str()
+	fn.LineBase = d.int()
+	return fn
+}
+
+func (d *funcDecoder) value() Value {
+	switch d.int() {
+	case encNil:
+		return nil
+	case encPacked:
+		return Unpack(d.str())
+	case encFunc:
+		return d.fn()
+	}
+	panic("DecodeFunc: invalid data")
+}
+
+// code returns the code with the global references
+// converted to the global numbers for this run
+func (d *funcDecoder) code() string {
+	code := []byte(d.str())
+	for n := d.int(); n > 0; n-- {
+		i := d.int()
+		gn := Global.Num(d.str())
+		code[i] = byte(gn >> 8)
+		code[i+1] = byte(gn)
+	}
+	return string(code)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 )
 
 // Gnum is a reference to a global name/value
@@ -28,16 +29,23 @@ type globals struct {
 	missing  Value
 	builtins map[Gnum]Value
 	errors   map[Gnum]interface{}
+	// dependents are the globals that have inlined the value of a global
+	// and must be unloaded when it is unloaded
+	dependents map[Gnum][]Gnum
+	// version is incremented when values are set or unloaded.
+	// It must be accessed atomically. See Global.Version
+	version int32
 }
 
 var g = globals{
 	name2num: make(map[string]Gnum),
 	// put ""/nil in first slot so we never use gnum of zero
-	names:    []string{""},
-	values:   []Value{nil},
-	missing:  &SuExcept{}, // type doesn't matter, just has to be unique
-	builtins: make(map[Gnum]Value, 100),
-	errors:   make(map[Gnum]interface{}),
+	names:      []string{""},
+	values:     []Value{nil},
+	missing:    &SuExcept{}, // type doesn't matter, just has to be unique
+	builtins:   make(map[Gnum]Value, 100),
+	errors:     make(map[Gnum]interface{}),
+	dependents: make(map[Gnum][]Gnum),
 }
 
 func (typeGlobal) Builtin(name string, value Value) Value {
@@ -86,6 +94,14 @@ func (typeGlobal) add(name string, val Value) Gnum {
 // TestDef sets a global for tests
 func (typeGlobal) TestDef(name string, val Value) {
 	g.values[Global.Num(name)] = val
+	atomic.AddInt32(&g.version, 1)
+}
+
+// Version returns a number that changes whenever global values
+// are set or unloaded (e.g. when libraries change)
+// so caches like SuClass method lookups can be invalidated.
+func (typeGlobal) Version() int32 {
+	return atomic.LoadInt32(&g.version)
 }
 
 // Num returns the global number for a name
@@ -207,12 +223,40 @@ func (typeGlobal) GetIfPresent(name string) (x Value) {
 	return
 }
 
+// Unload removes the value of a global (so it will be reloaded)
+// along with any globals that depend on it (see Depend)
 func (typeGlobal) Unload(name string) {
 	gnum := Global.Num(name)
 	g.lock.Lock()
+	unload(gnum)
+	g.lock.Unlock()
+}
+
+func unload(gnum Gnum) {
+	atomic.AddInt32(&g.version, 1)
 	g.values[gnum] = nil
 	delete(g.errors, gnum)
-	g.lock.Unlock()
+	deps := g.dependents[gnum]
+	delete(g.dependents, gnum) // before recursing, in case of cycles
+	for _, dep := range deps {
+		unload(dep)
+	}
+}
+
+// Depend records that the value of dependent was compiled
+// using the value of name (e.g. an inlined constant)
+// so dependent must be unloaded if name is unloaded.
+func (typeGlobal) Depend(name, dependent string) {
+	gnum := Global.Num(name)
+	dep := Global.Num(dependent)
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for _, d := range g.dependents[gnum] {
+		if d == dep {
+			return
+		}
+	}
+	g.dependents[gnum] = append(g.dependents[gnum], dep)
 }
 
 func (typeGlobal) UnloadAll() {
@@ -223,12 +267,18 @@ func (typeGlobal) UnloadAll() {
 	for k := range g.errors {
 		delete(g.errors, k)
 	}
+	g.dependents = make(map[Gnum][]Gnum)
+	atomic.AddInt32(&g.version, 1)
 	g.lock.Unlock()
 }
 
 // Set is used by LibLoad
 func (typeGlobal) Set(gn Gnum, val Value) {
 	g.lock.Lock()
+	if g.values[gn] != nil {
+		// nothing can depend on a value that wasn't loaded yet
+		atomic.AddInt32(&g.version, 1)
+	}
 	g.values[gn] = val
 	g.lock.Unlock()
 }
@@ -8,8 +8,13 @@ import "github.com/apmckinlay/gsuneido/util/assert"
 // Hmap implements a hash map for SuObject
 // based on: https://github.com/skarupke/flat_hash_map bytell_hash_map
 // Its zero value is a valid empty table.
+// Iteration is in insertion order (like a linked hash map)
+// so Display and Pack are deterministic.
 type Hmap struct {
-	blocks   []block
+	blocks []block
+	// keys is the keys in insertion order, deleted keys are nil.
+	// Each slot's ord is its index in keys.
+	keys     []Value
 	size     int32
 	version  uint16
 	capShift uint8
@@ -65,6 +70,7 @@ const (
 // This is to eliminate padding while still maintaining locality
 type block struct {
 	meta [blockSize]metaData
+	ord  [blockSize]int32
 	key  [blockSize]Value
 	val  [blockSize]Value
 }
@@ -132,6 +138,16 @@ func (h *Hmap) Get(key Value) Value {
 
 // Put adds or updates an entry
 func (h *Hmap) Put(key Value, val Value) {
+	n := h.size
+	h.put(key, val, int32(len(h.keys)))
+	if h.size > n {
+		h.keys = append(h.keys, key)
+	}
+}
+
+// put adds or updates an entry.
+// ord is only used for new entries.
+func (h *Hmap) put(key Value, val Value, ord int32) {
 	h.version++
 	if h.cap() == 0 {
 		h.grow()
@@ -141,6 +157,7 @@ func (h *Hmap) Put(key Value, val Value) {
 	ib := indexInBlock(index)
 	if b.meta[ib] == metaEmpty {
 		b.meta[ib] = metaDirect | noJump
+		b.ord[ib] = ord
 		b.key[ib] = key
 		b.val[ib] = val
 		h.size++
@@ -148,7 +165,7 @@ func (h *Hmap) Put(key Value, val Value) {
 	}
 	iter := chainIter{h, b, index, ib}
 	if iter.meta()&metaDirect != metaDirect {
-		h.putDirect(&iter, key, val)
+		h.putDirect(&iter, key, val, ord)
 		return
 	}
 	for {
@@ -158,7 +175,7 @@ func (h *Hmap) Put(key Value, val Value) {
 			return
 		}
 		if !iter.next() {
-			h.putChain(&iter, key, val)
+			h.putChain(&iter, key, val, ord)
 			return
 		}
 	}
@@ -182,7 +199,7 @@ func (h *Hmap) grow() {
 	for _, b := range oldblocks {
 		for ib := 0; ib < blockSize; ib++ {
 			if b.meta[ib] != metaEmpty {
-				h.Put(b.key[ib], b.val[ib])
+				h.put(b.key[ib], b.val[ib], b.ord[ib])
 			}
 		}
 	}
@@ -191,10 +208,10 @@ func (h *Hmap) grow() {
 
 // putDirect starts a new chain
 // i.e. it handles when this is the first entry with a certain hash index
-func (h *Hmap) putDirect(slot *chainIter, key Value, val Value) {
+func (h *Hmap) putDirect(slot *chainIter, key Value, val Value, ord int32) {
 	if h.isFull() {
 		h.grow()
-		h.Put(key, val) // recursive restart
+		h.put(key, val, ord) // recursive restart
 		return
 	}
 	if slot.meta() != metaEmpty {
@@ -205,14 +222,14 @@ func (h *Hmap) putDirect(slot *chainIter, key Value, val Value) {
 			ji, free := h.findEmpty(prev.index)
 			if ji == -1 {
 				h.grow()
-				h.Put(key, val) // recursive restart
+				h.put(key, val, ord) // recursive restart
 				return
 			}
 			prev.jumpSet(ji)
-			free.set(noJump, oldchain.key(), oldchain.val())
+			free.set(noJump, oldchain.ord(), oldchain.key(), oldchain.val())
 			jump := oldchain.jump()
 			if oldchain.index != slot.index { // keep direct slot from being used
-				oldchain.set(metaEmpty, nil, nil)
+				oldchain.set(metaEmpty, 0, nil, nil)
 			}
 			if !oldchain.nextWithJump(jump) {
 				break
@@ -220,7 +237,7 @@ func (h *Hmap) putDirect(slot *chainIter, key Value, val Value) {
 			prev = free
 		}
 	}
-	slot.set(metaDirect|noJump, key, val)
+	slot.set(metaDirect|noJump, ord, key, val)
 	h.size++
 }
 
@@ -249,20 +266,20 @@ func (h *Hmap) findEmpty(index int) (int, chainIter) {
 }
 
 // putChain adds to the end of a chain
-func (h *Hmap) putChain(iter *chainIter, key Value, val Value) {
+func (h *Hmap) putChain(iter *chainIter, key Value, val Value, ord int32) {
 	if h.isFull() {
 		h.grow()
-		h.Put(key, val) // recursive restart
+		h.put(key, val, ord) // recursive restart
 		return
 	}
 	ji, free := h.findEmpty(iter.index)
 	if ji == -1 {
 		h.grow()
-		h.Put(key, val) // recursive restart
+		h.put(key, val, ord) // recursive restart
 		return
 	}
 	iter.jumpSet(ji)
-	free.set(noJump, key, val)
+	free.set(noJump, ord, key, val)
 	h.size++
 }
 
@@ -284,6 +301,7 @@ func (h *Hmap) Del(key Value) Value {
 	}
 	h.version++
 	val := iter.val()
+	h.keys[iter.ord()] = nil
 	if iter.jump() != noJump {
 		// delete from within chain - move element at end of chain
 		slot := iter
@@ -291,40 +309,66 @@ func (h *Hmap) Del(key Value) Value {
 			prev = iter
 			iter.next()
 		}
+		slot.ordSet(iter.ord())
 		slot.keySet(iter.key())
 		slot.valSet(iter.val())
 	}
-	iter.set(metaEmpty, nil, nil)
+	iter.set(metaEmpty, 0, nil, nil)
 	if prev.b != nil {
 		prev.jumpSet(noJump)
 	}
 	h.size--
+	if len(h.keys) > 2*int(h.size)+8 {
+		h.compact()
+	}
 	return val
 }
 
+// compact removes the deleted (nil) entries from keys
+// and updates the slot ords to match
+func (h *Hmap) compact() {
+	ords := make([]int32, len(h.keys))
+	keys := make([]Value, 0, h.size)
+	for i, k := range h.keys {
+		if k != nil {
+			ords[i] = int32(len(keys))
+			keys = append(keys, k)
+		}
+	}
+	h.keys = keys
+	for bi := range h.blocks {
+		b := &h.blocks[bi]
+		for ib := 0; ib < blockSize; ib++ {
+			if b.meta[ib] != metaEmpty {
+				b.ord[ib] = ords[b.ord[ib]]
+			}
+		}
+	}
+}
+
 // Copy returns a shallow copy of the Hmap
 func (h *Hmap) Copy() *Hmap {
 	hnew := Hmap{size: h.size, capShift: h.capShift}
 	hnew.blocks = make([]block, len(h.blocks))
 	copy(hnew.blocks, h.blocks)
+	hnew.keys = append([]Value(nil), h.keys...)
 	return &hnew
 }
 
-// Iter returns a function (closure) that is called to get the next item.
-// It returns nil,nil at the end.
+// Iter returns a function (closure) that is called to get the next item
+// in insertion order. It returns nil,nil at the end.
 func (h *Hmap) Iter() func() (Value, Value) {
-	i := len(h.blocks) * blockSize
+	i := 0
 	ver := h.version
 	return func() (Value, Value) {
 		if ver != h.version {
 			panic("hmap modified during iteration")
 		}
-		for i > 0 {
-			i--
-			b := &h.blocks[whichBlock(i)]
-			ib := indexInBlock(i)
-			if b.meta[ib] != metaEmpty {
-				return b.key[ib], b.val[ib]
+		for i < len(h.keys) {
+			k := h.keys[i]
+			i++
+			if k != nil {
+				return k, h.Get(k)
 			}
 		}
 		return nil, nil // end
@@ -372,6 +416,16 @@ func (it *chainIter) meta() metaData {
 	return it.b.meta[it.ib]
 }
 
+// ord returns the insertion order for an iterator's slot
+func (it *chainIter) ord() int32 {
+	return it.b.ord[it.ib]
+}
+
+// ordSet sets the insertion order for an iterator's slot
+func (it *chainIter) ordSet(ord int32) {
+	it.b.ord[it.ib] = ord
+}
+
 // key returns the key for an iterator's slot
 func (it *chainIter) key() Value {
 	return it.b.key[it.ib]
@@ -404,8 +458,9 @@ func (it *chainIter) jumpSet(jump int) {
 }
 
 // set updates the contents of a slot
-func (it *chainIter) set(meta metaData, key Value, val Value) {
+func (it *chainIter) set(meta metaData, ord int32, key Value, val Value) {
 	it.b.meta[it.ib] = meta
+	it.b.ord[it.ib] = ord
 	it.b.key[it.ib] = key
 	it.b.val[it.ib] = val
 }
@@ -15,7 +15,7 @@ import (
 func TestHmap(t *testing.T) {
 	data := map[int]string{}
 	hmap := Hmap{}
-	assert.T(t).This(unsafe.Sizeof(hmap)).Is(uintptr(32))
+	assert.T(t).This(unsafe.Sizeof(hmap)).Is(uintptr(56))
 	check := func() {
 		check(t, data, &hmap)
 	}
@@ -225,3 +225,40 @@ func BenchmarkHmap_chainIter(b *testing.B) {
 	}
 	iter.next()
 }
+
+func TestHmap_order(t *testing.T) {
+	hm := Hmap{}
+	ck := func(expected []int) {
+		t.Helper()
+		var keys []int
+		iter := hm.Iter()
+		for k, v := iter(); k != nil; k, v = iter() {
+			assert.T(t).This(v).Is(k)
+			keys = append(keys, ToInt(k))
+		}
+		assert.T(t).This(keys).Is(expected)
+	}
+	var expected []int
+	for i := 100; i > 0; i-- { // reverse to not match hash order
+		hm.Put(SuInt(i), SuInt(i))
+		expected = append(expected, i)
+	}
+	ck(expected)
+	hm.Put(SuInt(50), SuInt(50)) // update doesn't change order
+	ck(expected)
+	for i := 1; i <= 100; i += 2 {
+		hm.Del(SuInt(i))
+	}
+	hm.Put(SuInt(1), SuInt(1)) // re-added goes at the end
+	expected = expected[:0]
+	for i := 100; i > 0; i -= 2 {
+		expected = append(expected, i)
+	}
+	expected = append(expected, 1)
+	ck(expected)
+	for i := 2; i <= 90; i += 2 {
+		hm.Del(SuInt(i)) // compacts
+	}
+	assert.T(t).That(len(hm.keys) < 20)
+	ck([]int{100, 98, 96, 94, 92, 1})
+}
@@ -19,6 +19,10 @@ type IDbms interface {
 	// Close ends a dbms connection
 	Close()
 
+	// Compact compacts the database while it is running.
+	// It returns "" or an error message.
+	Compact() string
+
 	// Connections returns a list of the current server connections
 	Connections() Value
 
@@ -70,6 +74,11 @@ type IDbms interface {
 	// Nonce returns a random string from the server
 	Nonce() string
 
+	// ReleaseSnapshot removes a named snapshot.
+	// It returns false if there is no snapshot with that name.
+	// Like Snapshot it is only available with a local database.
+	ReleaseSnapshot(name string) bool
+
 	// Run is used by the old style string.ServerEval()
 	Run(code string) Value
 
@@ -79,12 +88,37 @@ type IDbms interface {
 	// Size returns the current database size
 	Size() int64
 
+	// Snapshot pins the current state of the database under a name
+	// for later read transactions (see SnapshotTran).
+	// It returns "" or an error message.
+	// Snapshots are only kept in memory,
+	// they do not survive restarting the database.
+	// They are only available with a local database,
+	// the client/server protocol does not have commands for them
+	// so the client implementation panics.
+	Snapshot(name string) string
+
+	// SnapshotTran starts a read transaction on a named snapshot.
+	// It returns nil if there is no snapshot with that name.
+	// Like Snapshot it is only available with a local database.
+	SnapshotTran(name string) ITran
+
 	// Timestamp returns a guaranteed unique date/time
 	Timestamp() SuDate
 
 	// Token returns data to use with Auth
 	Token() string
 
+	// TranLimits sets the limits for new update transactions
+	// from the MaxAge, MaxWrites, WarnAge, and WarnWrites members
+	// of limits (if it is not nil) and returns the current limits.
+	// The limits are not persisted, they revert to the defaults
+	// when the database is restarted.
+	// They are only available with a local database,
+	// the client/server protocol does not have a command for them
+	// so the client implementation panics.
+	TranLimits(limits *SuObject) *SuObject
+
 	// Transaction starts a transaction
 	Transaction(update bool) ITran
 
@@ -134,6 +168,11 @@ type ITran interface {
 	// ReadCount returns the number of reads done by the transaction
 	ReadCount() int
 
+	// SetTranLimits overrides the limits for an update transaction
+	// with the members of limits (see IDbms.TranLimits).
+	// Like IDbms.TranLimits it is only available with a local database.
+	SetTranLimits(limits *SuObject)
+
 	// WriteCount returns the number of writes done by the transaction
 	WriteCount() int
 }
@@ -0,0 +1,39 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// symbols interns SuStr Values for member and field names.
+// Converting a string to a Value (interface) allocates.
+// Using the same Value for the same name avoids this allocation
+// in hot paths like record field access.
+// It also means the strings share their data
+// so comparing equal names is a pointer comparison.
+var symbols sync.Map // string => Value (SuStr)
+
+var nsymbols int32
+
+// maxSymbols limits the size of the symbol table
+// since names may come from data e.g. record fields.
+// Past this limit Intern just allocates.
+const maxSymbols = 20000
+
+// Intern returns an interned SuStr Value for s
+func Intern(s string) Value {
+	if v, ok := symbols.Load(s); ok {
+		return v.(Value)
+	}
+	if atomic.LoadInt32(&nsymbols) >= maxSymbols {
+		return SuStr(s)
+	}
+	v, loaded := symbols.LoadOrStore(s, SuStr(s))
+	if !loaded {
+		atomic.AddInt32(&nsymbols, 1)
+	}
+	return v.(Value)
+}
@@ -0,0 +1,21 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestIntern(t *testing.T) {
+	assert := assert.T(t)
+	x := Intern("intern_test")
+	assert.This(x).Is(SuStr("intern_test"))
+	y := Intern(string([]byte("intern_test"))) // different string data
+	assert.That(x.(SuStr).Equal(y))
+	assert.This(testing.AllocsPerRun(100, func() {
+		Intern("intern_test")
+	})).Is(0.0)
+}
@@ -4,6 +4,8 @@
 package runtime
 
 import (
+	"time"
+
 	op "github.com/apmckinlay/gsuneido/runtime/opcodes"
 )
 
@@ -19,7 +21,7 @@ var BlockReturn = BuiltinSuExcept("block return")
 // The stack must already be in the form required by the function (massaged)
 func (t *Thread) Invoke(fn *SuFunc, this Value) Value {
 	// reserve stack space for locals
-	for expand := fn.Nlocals - fn.Nparams; expand > 0; expand-- {
+	for expand := int(fn.Nlocals) - int(fn.Nparams); expand > 0; expand-- {
 		t.Push(nil)
 	}
 	if t.fp >= len(t.frames) {
@@ -27,6 +29,35 @@ func (t *Thread) Invoke(fn *SuFunc, this Value) Value {
 	}
 	t.frames[t.fp] = Frame{fn: fn, this: this,
 		locals: Locals{v: t.stack[t.sp-int(fn.Nlocals) : t.sp]}}
+	if t.CallProfile != nil {
+		return t.profileRun(fn)
+	}
+	return t.run()
+}
+
+// CallStats is the information accumulated by Thread.CallProfile
+type CallStats struct {
+	Calls int
+	// Time is cumulative i.e. it includes the time for nested calls
+	Time time.Duration
+}
+
+// profileRun is used by Invoke when CallProfile is enabled.
+// Calls that panic are included.
+func (t *Thread) profileRun(fn *SuFunc) Value {
+	start := time.Now()
+	defer func() {
+		if t.CallProfile == nil {
+			return // disabled during the call
+		}
+		cs := t.CallProfile[fn]
+		if cs == nil {
+			cs = &CallStats{}
+			t.CallProfile[fn] = cs
+		}
+		cs.Calls++
+		cs.Time += time.Since(start)
+	}()
 	return t.run()
 }
 
@@ -60,10 +91,9 @@ func (t *Thread) run() Value {
 		t.spMax = t.sp
 	}
 
-	catchJump := 0
-	catchSp := -1
+	var catches []catcher
 	for {
-		result := t.interp(&catchJump, &catchSp)
+		result := t.interp(&catches)
 		if result == nil {
 			// fmt.Println("<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<")
 			t.fp = fp - 1
@@ -73,25 +103,65 @@ func (t *Thread) run() Value {
 			return t.Top()
 		}
 		// try block threw
-		t.sp = catchSp
+		c := catches[len(catches)-1]
+		catches = catches[:len(catches)-1] // no longer catching
+		t.sp = c.sp
 		t.fp = fp
 		fr := &t.frames[t.fp-1]
-		fr.ip = catchJump
-		catchJump = 0 // no longer catching
-		catchSp = -1
+		fr.ip = c.jump
 		t.Push(result) // SuExcept
 		// loop and re-enter interp
 	}
 }
 
+// catcher is the information registered by op.Try
+// Try's may be nested within a function so run keeps a stack of them.
+type catcher struct {
+	// start is the code position of the start of the try body
+	start int
+	// jump is the code position of the catch code,
+	// which is also the end of the try body
+	jump int
+	// sp is the stack pointer to restore when catching
+	sp int
+	// pat is the catch pattern
+	pat string
+}
+
+// contains returns whether ip is within the try body
+func (c *catcher) contains(ip int) bool {
+	return c.start <= ip && ip < c.jump
+}
+
+// popStale removes catchers that no longer apply to ip.
+// Catchers can be left behind if a break or continue leaves a try body.
+func popStale(catches []catcher, ip int) []catcher {
+	for len(catches) > 0 && !catches[len(catches)-1].contains(ip) {
+		catches = catches[:len(catches)-1]
+	}
+	return catches
+}
+
+// pushCatch adds a catcher for a try starting at c.start.
+// A loop may re-enter a try (e.g. with continue) without leaving it,
+// so catchers for the same try, or tries nested within it,
+// are removed first to keep the stack from growing.
+func pushCatch(catches []catcher, c catcher) []catcher {
+	catches = popStale(catches, c.start)
+	for len(catches) > 0 && catches[len(catches)-1].start >= c.start {
+		catches = catches[:len(catches)-1]
+	}
+	return append(catches, c)
+}
+
 // interp is the main interpreter loop
 // It normally returns nil, with the return value (if any) on the stack
 // Returns *SuExcept if there was an exception/panic
-func (t *Thread) interp(catchJump, catchSp *int) (ret Value) {
+// in which case the matching catcher is left on the top of catches
+func (t *Thread) interp(catches *[]catcher) (ret Value) {
 	fr := &t.frames[t.fp-1]
 	code := fr.fn.Code
 	super := 0
-	catchPat := ""
 	var oc op.Opcode
 
 	fetchUint8 := func() int {
@@ -125,7 +195,7 @@ func (t *Thread) interp(catchJump, catchSp *int) (ret Value) {
 
 	defer func() {
 		// this is an optimization to avoid unnecessary recover/repanic
-		if *catchJump == 0 && t.blockReturnFrame == nil {
+		if len(*catches) == 0 && t.blockReturnFrame == nil {
 			return // this frame isn't catching
 		}
 		e := recover()
@@ -138,11 +208,11 @@ func (t *Thread) interp(catchJump, catchSp *int) (ret Value) {
 			}
 			return // normal return
 		}
-		if *catchJump == 0 {
+		if len(*catches) == 0 {
 			panic(e) // not catching
 		}
 		// return value (ret) tells run we're catching
-		ret = OpCatch(t, e, catchPat)
+		ret = t.catch(fr.ip, catches, e)
 	}()
 
 loop:
@@ -151,17 +221,17 @@ loop:
 		// _, da := Disasm1(fr.fn, fr.ip)
 		// fmt.Printf("%d: %d: %s\n", t.fp, fr.ip, da)
 		if t.OpCount == 0 {
+			t.OpCount = 1009 // poll periodically
 			if t.UIThread {
 				RunOnGoSide()
 				if Interrupt() {
 					panic("interrupt")
 				}
-				t.OpCount = 1009 // otherwise it won't trigger again
 			}
 			if t.Profile != nil {
 				t.Profile[fr.fn]++
-				t.OpCount = 1009 // otherwise it won't trigger again
 			}
+			t.checkCancel()
 		}
 		t.OpCount--
 		oc = op.Opcode(code[fr.ip])
@@ -196,6 +266,8 @@ loop:
 			t.Push(SuInt(fetchInt16()))
 		case op.Value:
 			t.Push(fr.fn.Values[fetchUint8()])
+		case op.Value16:
+			t.Push(fr.fn.Values[fetchUint16()])
 		case op.Load:
 			i := fetchUint8()
 			val := fr.locals.v[i]
@@ -203,10 +275,24 @@ loop:
 				panic("uninitialized variable: " + fr.fn.Names[i])
 			}
 			t.Push(val)
+		case op.Load16:
+			i := fetchUint16()
+			val := fr.locals.v[i]
+			if val == nil {
+				panic("uninitialized variable: " + fr.fn.Names[i])
+			}
+			t.Push(val)
 		case op.Store:
 			fr.locals.v[fetchUint8()] = t.Top()
-		case op.LoadStore:
-			i := fetchUint8()
+		case op.Store16:
+			fr.locals.v[fetchUint16()] = t.Top()
+		case op.LoadStore, op.LoadStore16:
+			var i int
+			if oc == op.LoadStore {
+				i = fetchUint8()
+			} else {
+				i = fetchUint16()
+			}
 			op := fetchUint8()
 			x := fr.locals.v[i]
 			y := t.stack[t.sp-1]
@@ -248,17 +334,23 @@ loop:
 				val = t.dyload(fr, i)
 			}
 			t.Push(val)
+		case op.Dyload16:
+			i := fetchUint16()
+			val := fr.locals.v[i]
+			if val == nil {
+				val = t.dyload(fr, i)
+			}
+			t.Push(val)
 		case op.Global:
 			gn := fetchUint16()
 			t.Push(Global.Get(t, gn))
 		case op.Get:
 			m := t.Pop()
 			ob := t.Pop()
-			val := ob.Get(t, m)
-			if val == nil {
-				panic("uninitialized member: " + m.String())
-			}
-			t.Push(val)
+			t.Push(OpGet(t, ob, m))
+		case op.GetMem:
+			m := fr.fn.Values[fetchUint8()]
+			t.stack[t.sp-1] = OpGet(t, t.stack[t.sp-1], m)
 		case op.Put:
 			val := t.Pop()
 			m := t.Pop()
@@ -413,36 +505,71 @@ loop:
 			} else {
 				fr.ip += 2
 			}
+		case op.JumpTable:
+			lo := fetchInt16()
+			n := fetchUint8()
+			end := fr.ip + 2*n
+			if i, ok := t.Pop().IfInt(); ok && lo <= i && i < lo+n {
+				fr.ip += 2 * (i - lo)
+				fr.ip = end + fetchInt16()
+			} else {
+				fr.ip = end
+			}
 		case op.Iter:
-			t.stack[t.sp-1] = OpIter(t.stack[t.sp-1])
-		case op.ForIn:
+			t.stack[t.sp-1] = OpIter(t, t.stack[t.sp-1])
+		case op.ForIn, op.ForIn16:
 			brk := fetchInt16()
-			local := fetchUint8()
+			end := fr.ip + brk // relative to after the jump offset
+			var local int
+			if oc == op.ForIn16 {
+				local = fetchUint16()
+			} else {
+				local = fetchUint8()
+			}
 			iter := t.Top()
 			nextable := iter.(interface{ Next() Value })
 			next := nextable.Next()
 			if next != nil {
 				fr.locals.v[local] = next
 			} else {
-				fr.ip += brk - 1 // break
+				fr.ip = end // break
+			}
+		case op.JumpSet:
+			j := fetchInt16()
+			if fr.locals.v[fetchUint8()] != nil {
+				fr.ip += j - 1
 			}
 		case op.ReturnNil:
 			t.Push(nil)
 			fallthrough
 		case op.Return:
 			break loop
-		case op.Try:
-			*catchJump = fr.ip + fetchInt16()
-			*catchSp = t.sp
-			catchPat = string(fr.fn.Values[fetchUint8()].(SuStr))
+		case op.Try, op.Try16:
+			start := fr.ip - 1
+			j := fetchInt16()
+			jump := fr.ip + j
+			var pat string
+			if oc == op.Try {
+				pat = string(fr.fn.Values[fetchUint8()].(SuStr))
+			} else {
+				pat = string(fr.fn.Values[fetchUint16()].(SuStr))
+			}
+			*catches = pushCatch(*catches,
+				catcher{start: start, jump: jump, sp: t.sp, pat: pat})
 		case op.Catch:
+			*catches = popStale(*catches, fr.ip-1)
+			*catches = (*catches)[:len(*catches)-1] // no longer catching
 			fr.ip += fetchInt16()
-			*catchJump = 0 // no longer catching
 		case op.Throw:
 			panic(t.Pop())
-		case op.Closure:
+		case op.Closure, op.Closure16:
 			fr.locals.moveToHeap()
-			fn := fr.fn.Values[fetchUint8()].(*SuFunc)
+			var fn *SuFunc
+			if oc == op.Closure {
+				fn = fr.fn.Values[fetchUint8()].(*SuFunc)
+			} else {
+				fn = fr.fn.Values[fetchUint16()].(*SuFunc)
+			}
 			parent := fr
 			if fr.blockParent != nil {
 				parent = fr.blockParent
@@ -522,6 +649,28 @@ loop:
 	return nil
 }
 
+// catch finds the innermost catcher that applies to ip
+// and whose pattern matches the exception.
+// Catchers that don't apply or don't match are removed.
+// If none match, the exception is propagated.
+func (t *Thread) catch(ip int, catches *[]catcher, e interface{}) *SuExcept {
+	se := ToSuExcept(t, e)
+	cs := *catches
+	for {
+		cs = popStale(cs, ip)
+		if len(cs) == 0 {
+			break
+		}
+		if catchMatch(string(se.SuStr), cs[len(cs)-1].pat) {
+			*catches = cs
+			return se
+		}
+		cs = cs[:len(cs)-1]
+	}
+	*catches = cs
+	panic(se) // propagate panic if not caught
+}
+
 // topbool return the top of the stack as bool, panicing if not True or False
 func (t *Thread) topbool() bool {
 	switch t.Top() {
@@ -50,6 +50,29 @@ func TestCatchMatch(t *testing.T) {
 	nomatch("foobar", "far|boo|x")
 }
 
+func TestPushCatch(t *testing.T) {
+	assert := assert.T(t)
+	var cs []catcher
+	outer := catcher{start: 0, jump: 100}
+	inner := catcher{start: 10, jump: 50}
+	cs = pushCatch(cs, outer)
+	for i := 0; i < 5; i++ {
+		// loop re-entering the inner try
+		cs = pushCatch(cs, inner)
+	}
+	assert.This(cs).Is([]catcher{outer, inner})
+	nested := catcher{start: 20, jump: 30}
+	cs = pushCatch(cs, nested)
+	assert.This(len(cs)).Is(3)
+	// re-entering the inner try removes the nested one
+	cs = pushCatch(cs, inner)
+	assert.This(cs).Is([]catcher{outer, inner})
+	// a try after the inner one removes it as stale
+	cs = pushCatch(cs, catcher{start: 60, jump: 70})
+	assert.This(len(cs)).Is(2)
+	assert.This(cs[0]).Is(outer)
+}
+
 // compare to BenchmarkInterp in execute_test.go
 
 func BenchmarkJit(b *testing.B) {
@@ -0,0 +1,166 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// JSONEncode converts a Value to JSON text.
+//
+// Objects (and records) with only list members become arrays,
+// otherwise they become JSON objects with the list members
+// given keys of "0", "1", etc. Other member names are converted to strings.
+// Dates become ISO 8601 strings, see SuDate.FormatISO.
+// Numbers are written exactly, without going through float64.
+// Recursive objects and values with no JSON equivalent (e.g. functions)
+// throw an exception.
+func JSONEncode(x Value) string {
+	var sb strings.Builder
+	jsonEncode(&sb, x, nil)
+	return sb.String()
+}
+
+func jsonEncode(sb *strings.Builder, x Value, inProgress []Container) {
+	switch x := x.(type) {
+	case SuBool:
+		sb.WriteString(x.String())
+	case SuDnum:
+		if x.Dnum.IsInf() {
+			panic("json.Encode: can't convert infinite number")
+		}
+		sb.WriteString(jsonNumber(x.String()))
+	case SuDate:
+		jsonString(sb, x.FormatISO())
+	case SuBuffer:
+		panic("json.Encode: can't convert Buffer")
+	default:
+		if _, ok := x.IfInt(); ok {
+			sb.WriteString(x.String())
+			return
+		}
+		if s, ok := x.AsStr(); ok {
+			jsonString(sb, s)
+			return
+		}
+		ob, ok := x.ToContainer()
+		if !ok {
+			panic("json.Encode: can't convert " + x.Type().String())
+		}
+		for _, c := range inProgress {
+			if c == ob {
+				panic("json.Encode: can't convert recursive object")
+			}
+		}
+		inProgress = append(inProgress, ob)
+		if ob.NamedSize() == 0 {
+			sb.WriteByte('[')
+			for i := 0; i < ob.ListSize(); i++ {
+				if i > 0 {
+					sb.WriteByte(',')
+				}
+				jsonEncode(sb, ob.ListGet(i), inProgress)
+			}
+			sb.WriteByte(']')
+			return
+		}
+		sb.WriteByte('{')
+		iter := ob.Iter2(true, true)
+		sep := ""
+		for k, v := iter(); k != nil; k, v = iter() {
+			sb.WriteString(sep)
+			sep = ","
+			jsonString(sb, jsonKey(k))
+			sb.WriteByte(':')
+			jsonEncode(sb, v, inProgress)
+		}
+		sb.WriteByte('}')
+	}
+}
+
+// jsonNumber converts Suneido number formats (e.g. .5 or 1e9)
+// to valid JSON (e.g. 0.5)
+func jsonNumber(s string) string {
+	if strings.HasPrefix(s, ".") {
+		return "0" + s
+	}
+	if strings.HasPrefix(s, "-.") {
+		return "-0" + s[1:]
+	}
+	return s
+}
+
+func jsonKey(k Value) string {
+	if s, ok := k.AsStr(); ok {
+		return s
+	}
+	if d, ok := k.(SuDate); ok {
+		return d.FormatISO()
+	}
+	return k.String()
+}
+
+func jsonString(sb *strings.Builder, s string) {
+	b, _ := json.Marshal(s) // can't fail for a string
+	sb.Write(b)
+}
+
+// JSONDecode converts JSON text to a Value.
+//
+// Arrays become list members and JSON objects become named members,
+// in their original order.
+// Numbers become integers or decimal numbers.
+// null becomes "" since Suneido has no null value.
+// Strings are not converted to dates.
+// Invalid JSON throws an exception.
+func JSONDecode(s string) Value {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	x := jsonDecode(dec, jsonToken(dec))
+	if _, err := dec.Token(); err != io.EOF {
+		panic("json.Decode: invalid json: extra data after value")
+	}
+	return x
+}
+
+func jsonDecode(dec *json.Decoder, tok json.Token) Value {
+	switch tok := tok.(type) {
+	case nil:
+		return EmptyStr
+	case bool:
+		return SuBool(tok)
+	case json.Number:
+		return NumFromString(string(tok))
+	case string:
+		return SuStr(tok)
+	case json.Delim:
+		ob := &SuObject{}
+		if tok == '[' {
+			for dec.More() {
+				ob.Add(jsonDecode(dec, jsonToken(dec)))
+			}
+		} else { // '{'
+			for dec.More() {
+				key := jsonToken(dec).(string) // decoder ensures keys are strings
+				ob.Set(SuStr(key), jsonDecode(dec, jsonToken(dec)))
+			}
+		}
+		jsonToken(dec) // closing delimiter
+		return ob
+	}
+	panic("json.Decode: unexpected token")
+}
+
+func jsonToken(dec *json.Decoder) json.Token {
+	tok, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			panic("json.Decode: unexpected end of input")
+		}
+		panic("json.Decode: invalid json: " + err.Error())
+	}
+	return tok
+}
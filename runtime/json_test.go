@@ -0,0 +1,62 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+	"github.com/apmckinlay/gsuneido/util/dnum"
+)
+
+func TestJSONEncode(t *testing.T) {
+	test := func(x Value, expected string) {
+		t.Helper()
+		assert.T(t).This(JSONEncode(x)).Is(expected)
+	}
+	test(True, "true")
+	test(False, "false")
+	test(SuInt(123), "123")
+	test(IntVal(-1234567), "-1234567")
+	test(SuDnum{Dnum: dnum.FromStr(".5")}, "0.5")
+	test(SuDnum{Dnum: dnum.FromStr("-.5")}, "-0.5")
+	test(SuDnum{Dnum: dnum.FromStr("1e30")}, "1e30")
+	test(SuStr(`a "quoted"\ string`), `"a \"quoted\"\\ string"`)
+	test(DateFromLiteral("#20210314.1520"), `"2021-03-14T15:20:00"`)
+	test(&SuObject{}, "[]")
+	test(NewSuObject([]Value{One, SuStr("x")}), `[1,"x"]`)
+	ob := &SuObject{}
+	ob.Set(SuStr("b"), One)
+	ob.Set(SuStr("a"), NewSuObject([]Value{True}))
+	test(ob, `{"b":1,"a":[true]}`)
+	ob = NewSuObject([]Value{SuStr("x")})
+	ob.Set(SuStr("a"), One)
+	ob.Set(SuInt(5), True)
+	test(ob, `{"0":"x","a":1,"5":true}`)
+
+	rec := NewSuObject(nil)
+	rec.Add(rec)
+	assert.T(t).This(func() { JSONEncode(rec) }).Panics("recursive")
+	assert.T(t).This(func() { JSONEncode(NewSuBuffer("x")) }).Panics("Buffer")
+}
+
+func TestJSONDecode(t *testing.T) {
+	test := func(s string, expected Value) {
+		t.Helper()
+		assert.T(t).This(JSONDecode(s)).Is(expected)
+	}
+	test("true", True)
+	test("null", EmptyStr)
+	test("123", SuInt(123))
+	test("1.5", SuDnum{Dnum: dnum.FromStr("1.5")})
+	test(`"a\nb"`, SuStr("a\nb"))
+	test(`[1, "x", []]`, NewSuObject([]Value{One, SuStr("x"), &SuObject{}}))
+	ob := JSONDecode(`{"b": 1, "a": {"c": null}}`).(*SuObject)
+	assert.T(t).This(ob.String()).Is(`#(b: 1, a: #(c: ""))`)
+	assert.T(t).This(func() { JSONDecode("[1,") }).Panics("json.Decode")
+	assert.T(t).This(func() { JSONDecode("1 2") }).Panics("extra data")
+
+	s := `{"x":[1,2.5,{"y":"z"}],"w":false}`
+	assert.T(t).This(JSONEncode(JSONDecode(s))).Is(s)
+}
@@ -14,82 +14,93 @@ func _() {
 	_ = x[Swap-3]
 	_ = x[Int-4]
 	_ = x[Value-5]
-	_ = x[True-6]
-	_ = x[False-7]
-	_ = x[Zero-8]
-	_ = x[One-9]
-	_ = x[MinusOne-10]
-	_ = x[MaxInt-11]
-	_ = x[EmptyStr-12]
-	_ = x[Load-13]
-	_ = x[Store-14]
-	_ = x[LoadStore-15]
-	_ = x[Dyload-16]
-	_ = x[Global-17]
-	_ = x[Get-18]
-	_ = x[Put-19]
-	_ = x[GetPut-20]
-	_ = x[RangeTo-21]
-	_ = x[RangeLen-22]
-	_ = x[This-23]
-	_ = x[Is-24]
-	_ = x[Isnt-25]
-	_ = x[Match-26]
-	_ = x[MatchNot-27]
-	_ = x[Lt-28]
-	_ = x[Lte-29]
-	_ = x[Gt-30]
-	_ = x[Gte-31]
-	_ = x[Add-32]
-	_ = x[Sub-33]
-	_ = x[Cat-34]
-	_ = x[Mul-35]
-	_ = x[Div-36]
-	_ = x[Mod-37]
-	_ = x[LeftShift-38]
-	_ = x[RightShift-39]
-	_ = x[BitOr-40]
-	_ = x[BitAnd-41]
-	_ = x[BitXor-42]
-	_ = x[BitNot-43]
-	_ = x[Not-44]
-	_ = x[UnaryPlus-45]
-	_ = x[UnaryMinus-46]
-	_ = x[Or-47]
-	_ = x[And-48]
-	_ = x[Bool-49]
-	_ = x[QMark-50]
-	_ = x[In-51]
-	_ = x[Cover-52]
-	_ = x[Jump-53]
-	_ = x[JumpTrue-54]
-	_ = x[JumpFalse-55]
-	_ = x[JumpIs-56]
-	_ = x[JumpIsnt-57]
-	_ = x[Iter-58]
-	_ = x[ForIn-59]
-	_ = x[Throw-60]
-	_ = x[Try-61]
-	_ = x[Catch-62]
-	_ = x[CallFuncDiscard-63]
-	_ = x[CallFuncNoNil-64]
-	_ = x[CallFuncNilOk-65]
-	_ = x[CallMethDiscard-66]
-	_ = x[CallMethNoNil-67]
-	_ = x[CallMethNilOk-68]
-	_ = x[Super-69]
-	_ = x[Return-70]
-	_ = x[ReturnNil-71]
-	_ = x[Closure-72]
-	_ = x[BlockBreak-73]
-	_ = x[BlockContinue-74]
-	_ = x[BlockReturn-75]
-	_ = x[BlockReturnNil-76]
+	_ = x[Value16-6]
+	_ = x[True-7]
+	_ = x[False-8]
+	_ = x[Zero-9]
+	_ = x[One-10]
+	_ = x[MinusOne-11]
+	_ = x[MaxInt-12]
+	_ = x[EmptyStr-13]
+	_ = x[Load-14]
+	_ = x[Store-15]
+	_ = x[Load16-16]
+	_ = x[Store16-17]
+	_ = x[LoadStore-18]
+	_ = x[LoadStore16-19]
+	_ = x[Dyload-20]
+	_ = x[Dyload16-21]
+	_ = x[Global-22]
+	_ = x[Get-23]
+	_ = x[GetMem-24]
+	_ = x[Put-25]
+	_ = x[GetPut-26]
+	_ = x[RangeTo-27]
+	_ = x[RangeLen-28]
+	_ = x[This-29]
+	_ = x[Is-30]
+	_ = x[Isnt-31]
+	_ = x[Match-32]
+	_ = x[MatchNot-33]
+	_ = x[Lt-34]
+	_ = x[Lte-35]
+	_ = x[Gt-36]
+	_ = x[Gte-37]
+	_ = x[Add-38]
+	_ = x[Sub-39]
+	_ = x[Cat-40]
+	_ = x[Mul-41]
+	_ = x[Div-42]
+	_ = x[Mod-43]
+	_ = x[LeftShift-44]
+	_ = x[RightShift-45]
+	_ = x[BitOr-46]
+	_ = x[BitAnd-47]
+	_ = x[BitXor-48]
+	_ = x[BitNot-49]
+	_ = x[Not-50]
+	_ = x[UnaryPlus-51]
+	_ = x[UnaryMinus-52]
+	_ = x[Or-53]
+	_ = x[And-54]
+	_ = x[Bool-55]
+	_ = x[QMark-56]
+	_ = x[In-57]
+	_ = x[Cover-58]
+	_ = x[Jump-59]
+	_ = x[JumpTrue-60]
+	_ = x[JumpFalse-61]
+	_ = x[JumpIs-62]
+	_ = x[JumpIsnt-63]
+	_ = x[JumpTable-64]
+	_ = x[Iter-65]
+	_ = x[ForIn-66]
+	_ = x[ForIn16-67]
+	_ = x[JumpSet-68]
+	_ = x[Throw-69]
+	_ = x[Try-70]
+	_ = x[Try16-71]
+	_ = x[Catch-72]
+	_ = x[CallFuncDiscard-73]
+	_ = x[CallFuncNoNil-74]
+	_ = x[CallFuncNilOk-75]
+	_ = x[CallMethDiscard-76]
+	_ = x[CallMethNoNil-77]
+	_ = x[CallMethNilOk-78]
+	_ = x[Super-79]
+	_ = x[Return-80]
+	_ = x[ReturnNil-81]
+	_ = x[Closure-82]
+	_ = x[Closure16-83]
+	_ = x[BlockBreak-84]
+	_ = x[BlockContinue-85]
+	_ = x[BlockReturn-86]
+	_ = x[BlockReturnNil-87]
 }
 
-const _Opcode_name = "NopPopDupSwapIntValueTrueFalseZeroOneMinusOneMaxIntEmptyStrLoadStoreLoadStoreDyloadGlobalGetPutGetPutRangeToRangeLenThisIsIsntMatchMatchNotLtLteGtGteAddSubCatMulDivModLeftShiftRightShiftBitOrBitAndBitXorBitNotNotUnaryPlusUnaryMinusOrAndBoolQMarkInCoverJumpJumpTrueJumpFalseJumpIsJumpIsntIterForInThrowTryCatchCallFuncDiscardCallFuncNoNilCallFuncNilOkCallMethDiscardCallMethNoNilCallMethNilOkSuperReturnReturnNilClosureBlockBreakBlockContinueBlockReturnBlockReturnNil"
+const _Opcode_name = "NopPopDupSwapIntValueValue16TrueFalseZeroOneMinusOneMaxIntEmptyStrLoadStoreLoad16Store16LoadStoreLoadStore16DyloadDyload16GlobalGetGetMemPutGetPutRangeToRangeLenThisIsIsntMatchMatchNotLtLteGtGteAddSubCatMulDivModLeftShiftRightShiftBitOrBitAndBitXorBitNotNotUnaryPlusUnaryMinusOrAndBoolQMarkInCoverJumpJumpTrueJumpFalseJumpIsJumpIsntJumpTableIterForInForIn16JumpSetThrowTryTry16CatchCallFuncDiscardCallFuncNoNilCallFuncNilOkCallMethDiscardCallMethNoNilCallMethNilOkSuperReturnReturnNilClosureClosure16BlockBreakBlockContinueBlockReturnBlockReturnNil"
 
-var _Opcode_index = [...]uint16{0, 3, 6, 9, 13, 16, 21, 25, 30, 34, 37, 45, 51, 59, 63, 68, 77, 83, 89, 92, 95, 101, 108, 116, 120, 122, 126, 131, 139, 141, 144, 146, 149, 152, 155, 158, 161, 164, 167, 176, 186, 191, 197, 203, 209, 212, 221, 231, 233, 236, 240, 245, 247, 252, 256, 264, 273, 279, 287, 291, 296, 301, 304, 309, 324, 337, 350, 365, 378, 391, 396, 402, 411, 418, 428, 441, 452, 466}
+var _Opcode_index = [...]uint16{0, 3, 6, 9, 13, 16, 21, 28, 32, 37, 41, 44, 52, 58, 66, 70, 75, 81, 88, 97, 108, 114, 122, 128, 131, 137, 140, 146, 153, 161, 165, 167, 171, 176, 184, 186, 189, 191, 194, 197, 200, 203, 206, 209, 212, 221, 231, 236, 242, 248, 254, 257, 266, 276, 278, 281, 285, 290, 292, 297, 301, 309, 318, 324, 332, 341, 345, 350, 357, 364, 369, 372, 377, 382, 397, 410, 423, 438, 451, 464, 469, 475, 484, 491, 500, 510, 523, 534, 548}
 
 func (i Opcode) String() string {
 	if i >= Opcode(len(_Opcode_index)-1) {
@@ -30,6 +30,8 @@ const (
 	Int
 	// Value <uint8> pushes a literal Value
 	Value
+	// Value16 <uint16> pushes a literal Value, used when there are >255
+	Value16
 	// True pushes True
 	True
 	// False pushes False
@@ -51,16 +53,27 @@ const (
 	Load
 	// Store <uint8> pops the top value off the stack into a local variable
 	Store
+	// Load16 <uint16> is Load for when there are >255 local variables
+	Load16
+	// Store16 <uint16> is Store for when there are >255 local variables
+	Store16
 	// LoadStore <local uint8> <op uint8> replaces the top value
 	// with ob[m] op= val
 	LoadStore
+	// LoadStore16 <local uint16> <op uint8> is LoadStore for >255 locals
+	LoadStore16
 	// Dyload <uint8> pushes a dynamic variable onto the stack
 	// It looks up the frame stack to find it, and copies it locally
 	Dyload
+	// Dyload16 <uint16> is Dyload for when there are >255 local variables
+	Dyload16
 	// Global <uint16> pushes the value of a global name
 	Global
 	// Get replaces the top two values (ob & mem) with ob.Get(mem)
 	Get
+	// GetMem <uint8> replaces the top value (ob) with ob.Get(literal)
+	// It is a combined Value and Get (a "superinstruction") e.g. for ob.mem
+	GetMem
 	// Put pops the top three values (ob, mem, val) and does ob.Put(mem, val)
 	Put
 	// GetPut <uint8> replaces the top 3 values (ob, mem, val)
@@ -159,12 +172,23 @@ const (
 	// else it pops the second value on the stack and continues
 	// panics if top is not True or False
 	JumpIsnt
+	// JumpTable <int16 lo> <uint8 n> <int16 * n> pops the top value
+	// and if it is an integer from lo to lo+n-1 it jumps by
+	// the corresponding offset (relative to the end of the table)
+	// else it continues after the table.
+	// It is used for switch statements with dense integer cases.
+	JumpTable
 	// Iter replaces the top with top.Iter()
 	Iter
 	// ForIn <int16> <uint8> calls top.Next()
 	// if the result is equal to top, it jumps
 	// else it continues
 	ForIn
+	// ForIn16 <int16> <uint16> is ForIn for when there are >255 locals
+	ForIn16
+	// JumpSet <int16> <uint8> jumps if the local variable is set (not nil)
+	// It is used by the prologue that evaluates parameter default expressions
+	JumpSet
 
 	// exceptions ---------------------------------------------------
 
@@ -173,12 +197,18 @@ const (
 	// Try <int16> <uint8> registers the catch jump and the catch pattern
 	// so we will start catching
 	Try
+	// Try16 <int16> <uint16> is Try for when there are >255 constants
+	Try16
 	// Catch <int16> clears the catch information to stop catching
 	// and jumps past the catch code
 	Catch
 
 	// call and return ----------------------------------------------
 
+	// NOTE: There are no specialized opcodes for simple positional calls.
+	// Their argspec byte is an index (0 to 4) into StdArgSpecs
+	// which the interpreter handles without looking at frame.fn.ArgSpecs
+
 	// CallFuncDiscard <uint8> calls the function popped from the stack
 	// with the specified StdArgSpecs or frame.fn.ArgsSpecs
 	// and discards the result
@@ -221,6 +251,8 @@ const (
 
 	// Closure <uint8> pushes a new closure block instance
 	Closure
+	// Closure16 <uint16> is Closure for when there are >255 constants
+	Closure16
 	// BlockBreak panics "block:break" (handled by application code)
 	BlockBreak
 	// BlockContinue panics "block:continue" (handled by application code)
@@ -157,6 +157,7 @@ func OpUnaryMinus(x Value) Value {
 func OpCat(t *Thread, x, y Value) Value {
 	if ssx, ok := x.(SuStr); ok {
 		if ssy, ok := y.(SuStr); ok {
+			t.Alloc(len(ssx) + len(ssy))
 			return cat2(string(ssx), string(ssy))
 		}
 	}
@@ -181,9 +182,13 @@ func cat2(xs, ys string) Value {
 func cat3(t *Thread, x, y Value) Value {
 	var result Value
 	if xc, ok := x.(SuConcat); ok {
-		result = xc.Add(catToStr(t, y))
+		ys := catToStr(t, y)
+		t.Alloc(len(ys)) // SuConcat shares its buffer
+		result = xc.Add(ys)
 	} else {
-		result = cat2(catToStr(t, x), catToStr(t, y))
+		xs, ys := catToStr(t, x), catToStr(t, y)
+		t.Alloc(len(xs) + len(ys))
+		result = cat2(xs, ys)
 	}
 	if xe, ok := x.(*SuExcept); ok {
 		return &SuExcept{SuStr: SuStr(AsStr(result)), Callstack: xe.Callstack}
@@ -257,12 +262,17 @@ func prepLen(len int, size int) int {
 	return len
 }
 
-func OpIter(x Value) SuIter {
-	iterable, ok := x.(interface{ Iter() Iter })
-	if !ok {
-		panic("can't iterate " + x.Type().String())
+// OpGet returns the value of a member, it panics if the member isn't found
+func OpGet(t *Thread, ob, m Value) Value {
+	val := ob.Get(t, m)
+	if val == nil {
+		panic("uninitialized member: " + m.String())
 	}
-	return SuIter{Iter: iterable.Iter()}
+	return val
+}
+
+func OpIter(t *Thread, x Value) SuIter {
+	return SuIter{Iter: ToIter(t, x)}
 }
 
 func OpCatch(t *Thread, e interface{}, catchPat string) *SuExcept {
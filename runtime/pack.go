@@ -40,8 +40,35 @@ const (
 	PackDate
 	PackObject
 	PackRecord
+	PackBuffer
 )
 
+// PackEnvelope is the tag for a value that requires a newer pack format.
+// It is followed by a version byte and then the packed value.
+// It is deliberately separate from the other tags (and sorts last)
+// so new plain tags can still be added.
+// Version 2 readers do not understand envelopes,
+// options.PackV2 (-pack2) avoids them when communicating with those.
+const PackEnvelope = 0xff
+
+// PackVersion is the version of the pack format we understand.
+// Version 2 is the original format (the tags above except PackBuffer).
+// Version 3 adds buffers (PackBuffer, in an envelope)
+// and dates with zones (PackDate followed by a two byte zone,
+// which version 2 readers ignore).
+// New types of values must be wrapped with Envelope
+// so that newer readers can still unpack them.
+const PackVersion = 3
+
+// envelopeSize is the size of the PackEnvelope tag and version
+const envelopeSize = 2
+
+// packEnvelope3 starts an envelope for a value that requires version 3.
+// It must be followed by packing the value.
+func packEnvelope3(buf *pack.Encoder) {
+	buf.Put1(PackEnvelope).Put1(3)
+}
+
 var packClock = int32(0)
 
 type packStack []Value
@@ -106,11 +133,34 @@ func Unpack(s string) Value {
 		return UnpackObject(s)
 	case PackRecord:
 		return UnpackRecord(s)
+	case PackBuffer:
+		return SuBuffer{s: s[1:]}
+	case PackEnvelope:
+		return unpackEnvelope(s)
 	default:
 		panic("invalid pack tag " + strconv.Itoa(int(s[0])))
 	}
 }
 
+// Envelope wraps a packed value that requires a newer version of the format
+func Envelope(version byte, packed string) string {
+	return string([]byte{PackEnvelope, version}) + packed
+}
+
+// unpackEnvelope unpacks the value if we understand its version.
+// Otherwise it degrades to a Buffer containing the packed value
+// rather than throwing an exception,
+// so e.g. an older client can still read objects containing newer values.
+func unpackEnvelope(s string) Value {
+	if len(s) < 2 {
+		panic("invalid pack envelope")
+	}
+	if s[1] > PackVersion {
+		return SuBuffer{s: s}
+	}
+	return Unpack(s[2:])
+}
+
 // PackedToLower applies str.ToLower to packed strings.
 // Other types of values are unchanged.
 func PackedToLower(s string) string {
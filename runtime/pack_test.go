@@ -4,8 +4,10 @@
 package runtime
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/apmckinlay/gsuneido/options"
 	"github.com/apmckinlay/gsuneido/util/assert"
 	"github.com/apmckinlay/gsuneido/util/dnum"
 	"github.com/apmckinlay/gsuneido/util/str"
@@ -21,6 +23,70 @@ func TestPack(t *testing.T) {
 	}
 }
 
+func TestPackEnvelope(t *testing.T) {
+	assert := assert.T(t)
+	// a version we understand unpacks normally
+	s := Envelope(PackVersion, Pack(SuStr("foo")))
+	assert.This(Unpack(s)).Is(SuStr("foo"))
+
+	// a newer version degrades to a buffer of the packed value
+	s = Envelope(PackVersion+1, "\x20xyz")
+	assert.This(Unpack(s)).Is(SuBuffer{s: s})
+
+	// nested inside an object
+	ob := &SuObject{}
+	ob.Add(SuInt(123))
+	ob.Add(SuBuffer{s: "placeholder"})
+	packed := Pack(ob)
+	newer := Envelope(PackVersion+1, "\x20xyzxyzxyzxy") // same size
+	packed = strings.Replace(packed, Pack(SuBuffer{s: "placeholder"}),
+		newer, 1)
+	x := Unpack(packed).(*SuObject)
+	assert.This(x.ListGet(0)).Is(SuInt(123))
+	assert.This(x.ListGet(1)).Is(SuBuffer{s: newer})
+
+	// buffers require version 3 so they are in an envelope
+	b := SuBuffer{s: "\x00abc"}
+	assert.This(Pack(b)).Is(Envelope(3, "\x08\x00abc"))
+	// the zone is a suffix on a plain date
+	d := DateFromISO("2024-01-02T03:04:05-05:00")
+	local := NewDate(2024, 1, 2, 3, 4, 5, 0)
+	assert.This(len(Pack(d))).Is(11)
+	assert.This(Pack(d)[:9]).Is(Pack(local))
+	ob = SuObjectOf(b, d, SuStr("x"))
+	assert.This(Unpack(Pack(ob))).Is(ob)
+	// which older readers degrade to a buffer and a plain date
+	assert.This(unpackAs(2, Pack(b))).Is(SuBuffer{s: Pack(b)})
+	assert.This(unpackAs(2, Pack(d))).Is(local)
+	// zoned dates sort with dates, not after objects
+	assert.That(Pack(local) < Pack(d))
+	assert.That(Pack(d) < Pack(SuObjectOf()))
+
+	// PackV2 avoids envelopes for version 2 readers
+	options.PackV2 = true
+	assert.This(Pack(b)).Is(Pack(SuStr("\x00abc")))
+	assert.This(Pack(SuBuffer{})).Is("")
+	assert.This(Pack(d)).Is(Pack(local))
+	assert.This(unpackAs(2, Pack(ob))).Is(SuObjectOf(SuStr("\x00abc"),
+		local, SuStr("x")))
+	options.PackV2 = false
+
+	// envelopes sort after all the original tags
+	assert.That(Envelope(PackVersion, "") > Pack(SuObjectOf(SuStr("\xff"))))
+	assert.This(func() { Unpack("\xff") }).Panics("invalid pack envelope")
+}
+
+// unpackAs simulates an older reader that only understands version
+func unpackAs(version byte, s string) Value {
+	if len(s) >= 2 && s[0] == PackEnvelope && s[1] > version {
+		return SuBuffer{s: s}
+	}
+	if version < 3 && len(s) > 0 && s[0] == PackDate {
+		return UnpackDate(s[:9]) // version 2 ignores the zone
+	}
+	return Unpack(s)
+}
+
 func TestPackSuInt(t *testing.T) {
 	test := func(n int, expected ...byte) {
 		t.Helper()
@@ -49,7 +49,7 @@ type ParamSpec struct {
 	// It is used for closure blocks, for normal functions it is 0
 	// Offset is only required for parameters (or arguments),
 	// it is not needed for local variables
-	Offset uint8
+	Offset uint16
 
 	// Signature is used for fast matching of simple Argspec to ParamSpec
 	Signature byte
@@ -63,6 +63,9 @@ const (
 	DynParam
 	DotParam
 	PubParam
+	// ExprParam means the default is an expression
+	// evaluated by the function, its Values entry is the source
+	ExprParam
 )
 
 var ParamSpec0 = ParamSpec{Nparams: 0, Signature: ^Sig0}
@@ -88,7 +91,11 @@ func (ps *ParamSpec) String() string {
 		buf.WriteString(flagsToName(ps.ParamName(i), ps.Flags[i]))
 		if i >= int(ps.Nparams-ps.Ndefaults) {
 			buf.WriteString("=")
-			buf.WriteString(fmt.Sprint(ps.Values[v]))
+			if ps.Flags[i]&ExprParam != 0 {
+				buf.WriteString(ToStr(ps.Values[v]))
+			} else {
+				buf.WriteString(fmt.Sprint(ps.Values[v]))
+			}
 			v++
 		}
 		sep = ","
@@ -0,0 +1,63 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io/ioutil"
+)
+
+/*
+Compressed records are only used in database storage,
+for tables with the compressed option.
+The stored form is a marker byte, the total stored length (uint32),
+and the record compressed with flate.
+The marker has the type bits (the high two bits) zero
+so it can not be confused with a normal record,
+and it is not zero so it is not confused with an empty record.
+*/
+const compressedRec = 1
+
+const compressedHdr = 5
+
+// minCompress is the minimum record size to compress
+const minCompress = 64
+
+// CompressRecord returns the stored form of a compressed record,
+// or nil if the record is small or compression does not reduce the size.
+func CompressRecord(r Record) []byte {
+	if len(r) < minCompress {
+		return nil
+	}
+	var buf bytes.Buffer
+	buf.Write(make([]byte, compressedHdr))
+	w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	w.Write([]byte(r))
+	w.Close()
+	if buf.Len() >= len(r) {
+		return nil
+	}
+	b := buf.Bytes()
+	b[0] = compressedRec
+	binary.BigEndian.PutUint32(b[1:], uint32(len(b)))
+	return b
+}
+
+// IsCompressed returns whether stored data is a compressed record
+func IsCompressed(buf []byte) bool {
+	return buf[0] == compressedRec
+}
+
+// DecompressRecord returns the record from its compressed stored form
+func DecompressRecord(buf []byte) Record {
+	n := RecLen(buf)
+	r := flate.NewReader(bytes.NewReader(buf[compressedHdr:n]))
+	rec, err := ioutil.ReadAll(r)
+	if err != nil {
+		panic("decompress record: " + err.Error())
+	}
+	return Record(rec)
+}
@@ -4,6 +4,7 @@
 package runtime
 
 import (
+	"encoding/binary"
 	"strings"
 	"sync/atomic"
 
@@ -66,10 +67,15 @@ func (r Record) Len() int {
 	}
 }
 
+// RecLen returns the length of a stored record,
+// which may be compressed (see CompressRecord)
 func RecLen(r []byte) int {
 	if r[0] == 0 {
 		return 1
 	}
+	if r[0] == compressedRec {
+		return int(binary.BigEndian.Uint32(r[1:]))
+	}
 	switch r[0] >> 6 {
 	case type8:
 		j := hdrlen
@@ -203,20 +209,38 @@ func (b *RecordBuilder) Trim() *RecordBuilder {
 // Build
 
 func (b *RecordBuilder) Build() Record {
+	if len(b.vals) == 0 {
+		return Record("\x00")
+	}
+	return b.encode(pack.NewEncoder)
+}
+
+// BuildInto is like Build but the record is encoded directly
+// into the (memory mapped) buffer returned by alloc,
+// which is called once with the exact size of the record.
+// This is used to build records directly in database storage
+// to avoid an allocation and copy for each record.
+func (b *RecordBuilder) BuildInto(alloc func(n int) []byte) Record {
+	return b.encode(func(n int) *pack.Encoder {
+		return pack.NewMmapEncoder(alloc(n)[:0:n])
+	})
+}
+
+func (b *RecordBuilder) encode(newEncoder func(n int) *pack.Encoder) Record {
 	clock := atomic.AddInt32(&packClock, 1)
 	stack := newPackStack()
 	if len(b.vals) > MaxValues {
 		panic("too many values for record")
 	}
 	if len(b.vals) == 0 {
-		return Record("\x00")
+		return Record(newEncoder(1).Put1(0).String())
 	}
 	sizes := make([]int, len(b.vals))
 	for i, v := range b.vals {
 		sizes[i] = v.PackSize2(clock, stack)
 	}
 	length := b.recSize(sizes)
-	buf := pack.NewEncoder(length)
+	buf := newEncoder(length)
 	b.build(clock, buf, length, sizes)
 	//assert.That(len(buf.String()) == length)
 	return Record(buf.String())
@@ -38,6 +38,30 @@ func TestBuilder(t *testing.T) {
 
 }
 
+func TestBuildInto(t *testing.T) {
+	assert := assert.T(t)
+	test := func(b *RecordBuilder) {
+		t.Helper()
+		expected := b.Build()
+		var buf []byte
+		rec := b.BuildInto(func(n int) []byte {
+			assert.This(n).Is(len(expected))
+			buf = make([]byte, n+4) // e.g. room for checksum
+			return buf[:n]
+		})
+		assert.This(rec).Is(expected)
+		assert.This(string(buf[:len(rec)])).Is(string(expected))
+	}
+	var b RecordBuilder
+	test(&b)
+	b.Add(SuInt(123)).Add(SuStr("foobar"))
+	test(&b)
+	for i := 0; i < 1000; i++ { // wide
+		b.Add(SuStr("helloworld"))
+	}
+	test(&b)
+}
+
 func TestLength(t *testing.T) {
 	assert := assert.T(t).This
 	assert(tblength(0, 0)).Is(1)
@@ -50,3 +74,20 @@ func TestLength(t *testing.T) {
 
 	assert(tblength(1, 0x10000)).Is(0x1000a)
 }
+
+func TestCompressRecord(t *testing.T) {
+	assert := assert.T(t)
+	var b RecordBuilder
+	b.Add(SuInt(123)).Add(SuStr("foobar"))
+	assert.This(CompressRecord(b.Build())).Is(nil) // too small
+	for i := 0; i < 100; i++ {
+		b.Add(SuStr("helloworld"))
+	}
+	rec := b.Build()
+	buf := CompressRecord(rec)
+	assert.That(len(buf) < len(rec))
+	assert.That(IsCompressed(buf))
+	assert.This(RecLen(append(buf, 0, 0))).Is(len(buf))
+	assert.This(DecompressRecord(buf)).Is(rec)
+	assert.That(!IsCompressed([]byte(rec)))
+}
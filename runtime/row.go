@@ -5,6 +5,7 @@ package runtime
 
 import (
 	"strings"
+	"sync"
 
 	"github.com/apmckinlay/gsuneido/util/ascii"
 	"github.com/apmckinlay/gsuneido/util/hacks"
@@ -43,7 +44,7 @@ func (row Row) GetVal(hdr *Header, fld string, th *Thread, tran *SuTran) Value {
 		return SuStr(str.ToLower(ToStr(val)))
 	}
 	// else construct SuRecord to handle rules
-	return SuRecordFromRow(row, hdr, "", tran).Get(th, SuStr(fld))
+	return SuRecordFromRow(row, hdr, "", tran).Get(th, Intern(fld))
 }
 
 // GetRaw does NOT handle rules
@@ -78,7 +79,10 @@ func lowerRaw(x string) string {
 
 func (row Row) getRaw2(hdr *Header, fld string) (string, bool) {
 	at, ok := hdr.find(fld)
-	if ok && row[at.Reci].Record != "" { // not empty side of union
+	if !ok {
+		return "", false
+	}
+	if row[at.Reci].Record != "" { // not empty side of union
 		return row[at.Reci].GetRaw(int(at.Fldi)), true
 	}
 	// handle nil records from Union
@@ -118,12 +122,17 @@ func (row Row) SameAs(row2 Row) bool {
 //-------------------------------------------------------------------
 
 // Header specifies the fields (physical) and columns (logical) for a query
+// Headers are immutable after creation so they can be shared
+// e.g. by all the rows of a query, or by cursors on the same table.
 type Header struct {
 	Fields  [][]string
 	Columns []string
-	// cache the location of fields.
-	// WARNING: assumed to not be concurrent (no locking)
-	cache map[string]rowAt
+	// fieldMap is the location of the first occurrence of each field.
+	// It is built once (by find) and then read only.
+	fieldMap map[string]rowAt
+	once     sync.Once
+	// rules caches the rules for records using this header
+	rules ruleCache
 }
 
 // rowAt specifies the position of a field within a Row
@@ -133,8 +142,29 @@ type rowAt struct {
 }
 
 func NewHeader(fields [][]string, columns []string) *Header {
-	return &Header{Fields: fields, Columns: columns,
-		cache: make(map[string]rowAt)}
+	return &Header{Fields: fields, Columns: columns}
+}
+
+// sharedHeaders is used by SharedHeader
+var sharedHeaders sync.Map // string => *Header
+
+// SharedHeader returns an existing Header with the same fields and columns
+// if there is one, otherwise it creates and saves a new one.
+// It should only be used for a limited set of schemas (e.g. tables)
+// since the headers are never removed.
+func SharedHeader(fields [][]string, columns []string) *Header {
+	var sb strings.Builder
+	for _, flds := range fields {
+		sb.WriteString(strings.Join(flds, ","))
+		sb.WriteByte(';')
+	}
+	sb.WriteString(strings.Join(columns, ","))
+	key := sb.String()
+	if hdr, ok := sharedHeaders.Load(key); ok {
+		return hdr.(*Header)
+	}
+	hdr, _ := sharedHeaders.LoadOrStore(key, NewHeader(fields, columns))
+	return hdr.(*Header)
 }
 
 func SimpleHeader(fields []string) *Header {
@@ -169,19 +199,22 @@ func (hdr *Header) hasField(col string) bool {
 }
 
 // find returns the location of the first occurence of fld in hdr.Fields
-// and caches the result. (Multiple occurrences come from union.)
+// (Multiple occurrences come from union.)
 func (hdr *Header) find(fld string) (rowAt, bool) {
-	if at, ok := hdr.cache[fld]; ok {
-		return at, true
-	}
+	hdr.once.Do(hdr.buildFieldMap)
+	at, ok := hdr.fieldMap[fld]
+	return at, ok
+}
+
+func (hdr *Header) buildFieldMap() {
+	hdr.fieldMap = make(map[string]rowAt)
 	for reci, fields := range hdr.Fields {
-		if fldi := strs.Index(fields, fld); fldi >= 0 {
-			at := rowAt{Reci: int16(reci), Fldi: int16(fldi)}
-			hdr.cache[fld] = at // cache
-			return at, true
+		for fldi, fld := range fields {
+			if _, ok := hdr.fieldMap[fld]; !ok {
+				hdr.fieldMap[fld] = rowAt{Reci: int16(reci), Fldi: int16(fldi)}
+			}
 		}
 	}
-	return rowAt{}, false
 }
 
 func (hdr *Header) GetFields() []string {
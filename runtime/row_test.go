@@ -4,6 +4,7 @@
 package runtime
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/apmckinlay/gsuneido/util/assert"
@@ -20,3 +21,45 @@ func TestGetRaw(*testing.T) {
 	assert.This(row.GetRaw(hdr, "two")).Is(Pack(SuStr("Hello World")))
 	assert.This(row.GetRaw(hdr, "two_lower!")).Is(Pack(SuStr("hello world")))
 }
+
+func TestHeaderUnion(t *testing.T) {
+	assert := assert.T(t)
+	hdr := NewHeader([][]string{{"a", "b"}, {"b", "c"}}, []string{"a", "b", "c"})
+	rec := func(vals ...string) DbRec {
+		var rb RecordBuilder
+		for _, v := range vals {
+			rb.Add(SuStr(v))
+		}
+		return DbRec{Record: rb.Build()}
+	}
+	row := Row{rec("a1", "b1"), rec("b2", "c2")}
+	assert.This(row.GetVal(hdr, "b", nil, nil)).Is(SuStr("b1"))
+	assert.This(row.GetVal(hdr, "c", nil, nil)).Is(SuStr("c2"))
+	assert.This(row.GetRaw(hdr, "x")).Is("")
+	// empty side of union
+	row = Row{DbRec{}, rec("b2", "c2")}
+	assert.This(row.GetVal(hdr, "b", nil, nil)).Is(SuStr("b2"))
+}
+
+func TestSharedHeader(t *testing.T) {
+	assert := assert.T(t)
+	h1 := SharedHeader([][]string{{"a", "b"}}, []string{"a", "b", "c"})
+	h2 := SharedHeader([][]string{{"a", "b"}}, []string{"a", "b", "c"})
+	h3 := SharedHeader([][]string{{"a"}, {"b"}}, []string{"a", "b", "c"})
+	assert.That(h1 == h2)
+	assert.That(h1 != h3)
+
+	var rb RecordBuilder
+	rb.Add(SuStr("x"))
+	rb.Add(SuStr("y"))
+	row := Row{DbRec{Record: rb.Build()}}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.This(row.GetRaw(h1, "b")).Is(Pack(SuStr("y")))
+		}()
+	}
+	wg.Wait()
+}
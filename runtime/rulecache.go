@@ -0,0 +1,225 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apmckinlay/gsuneido/util/strs"
+)
+
+// ruleCache is used by Header to cache the Rule_ lookups
+// for the records from a query
+// (so we don't have to look up the global for every record)
+// and, for rules marked with MemoizeRule,
+// the results of the rule for the values of its dependencies.
+// It is reset when the globals change (see Global.Version)
+type ruleCache struct {
+	lock    sync.RWMutex
+	version int32
+	// rules is the rule for each field, nil if there isn't one
+	rules map[string]Value
+	// memos is the results for memoized rules
+	memos map[string]*ruleMemo
+}
+
+// ruleMemo is the memoized results for one rule.
+// deps is the union of the fields that the rule has used.
+// The rule may use different fields depending on the data,
+// so when it uses a new field, the deps are extended
+// and the previous results are discarded.
+type ruleMemo struct {
+	deps []string
+	// known is whether deps has been determined
+	known bool
+	// gen is incremented when deps changes
+	gen    int
+	values map[string]Value
+}
+
+// maxMemo limits the number of results for a rule
+const maxMemo = 10000
+
+// memoRules is the set of rules (fields) that are memoized
+var memoRules = struct {
+	lock sync.RWMutex
+	set  map[string]bool
+}{set: map[string]bool{}}
+
+// MemoizeRule sets whether the results of the rule for a field
+// are memoized (for records from queries).
+// This should only be used for rules whose result depends solely
+// on the values of the fields they use.
+func MemoizeRule(field string, memoize bool) {
+	memoRules.lock.Lock()
+	defer memoRules.lock.Unlock()
+	if memoize {
+		memoRules.set[field] = true
+	} else {
+		delete(memoRules.set, field)
+	}
+}
+
+func isMemoized(field string) bool {
+	memoRules.lock.RLock()
+	defer memoRules.lock.RUnlock()
+	return memoRules.set[field]
+}
+
+// check resets the cache if the globals have changed.
+// It must be called with the write lock held.
+func (rc *ruleCache) check(version int32) {
+	if rc.rules == nil || rc.version != version {
+		rc.version = version
+		rc.rules = make(map[string]Value)
+		rc.memos = nil
+	}
+}
+
+// get returns the rule for a field, or nil if there isn't one
+func (rc *ruleCache) get(t *Thread, key string) Value {
+	version := Global.Version()
+	rc.lock.RLock()
+	rule, ok := rc.rules[key]
+	ok = ok && rc.version == version
+	rc.lock.RUnlock()
+	if ok {
+		return rule
+	}
+	// NOTE: can't hold lock during FindName since it may Libload
+	rule = Global.FindName(t, "Rule_"+key)
+	rc.lock.Lock()
+	rc.check(version)
+	rc.rules[key] = rule
+	rc.lock.Unlock()
+	return rule
+}
+
+// memo returns the memo for a rule, or nil if it is not memoized
+func (rc *ruleCache) memo(key string) *ruleMemo {
+	if !isMemoized(key) {
+		return nil
+	}
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	rc.check(Global.Version())
+	if rc.memos == nil {
+		rc.memos = make(map[string]*ruleMemo)
+	}
+	m := rc.memos[key]
+	if m == nil {
+		m = &ruleMemo{}
+		rc.memos[key] = m
+	}
+	return m
+}
+
+// lookup returns the memoized result if there is one for mkey
+func (rc *ruleCache) lookup(m *ruleMemo, mkey string) (Value, bool) {
+	rc.lock.RLock()
+	defer rc.lock.RUnlock()
+	val, ok := m.values[mkey]
+	return val, ok
+}
+
+// deps returns the current deps, whether they're known, and the generation
+func (rc *ruleCache) deps(m *ruleMemo) ([]string, bool, int) {
+	rc.lock.RLock()
+	defer rc.lock.RUnlock()
+	return m.deps, m.known, m.gen
+}
+
+// update records the fields used by the rule.
+// It returns false if the deps changed.
+func (rc *ruleCache) update(m *ruleMemo, gen int, used []string) bool {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	if m.gen != gen {
+		return false
+	}
+	deps := m.deps
+	for _, f := range used {
+		if !strs.Contains(deps, f) {
+			deps = append(deps[:len(deps):len(deps)], f)
+		}
+	}
+	if m.known && len(deps) == len(m.deps) {
+		return true
+	}
+	m.deps = deps
+	m.known = true
+	m.gen++
+	m.values = nil
+	return false
+}
+
+func (rc *ruleCache) store(m *ruleMemo, gen int, mkey string, val Value) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	if m.gen != gen {
+		return
+	}
+	if m.values == nil || len(m.values) >= maxMemo {
+		m.values = make(map[string]Value)
+	}
+	m.values[mkey] = val
+}
+
+// SuRecord ---------------------------------------------------------
+
+// callMemoRule is used by callRule for memoized rules.
+// The memo key is the packed values of the dependencies.
+func (r *SuRecord) callMemoRule(t *Thread, rule Value, key string,
+	m *ruleMemo) Value {
+	rc := &r.hdr.rules
+	deps, known, gen := rc.deps(m)
+	mkey := ""
+	if known {
+		mkey = r.memoKey(t, deps)
+		if val, ok := rc.lookup(m, mkey); ok {
+			r.trace("memoized rule", key)
+			for _, d := range deps {
+				r.addDependent(key, d)
+			}
+			return val
+		}
+	}
+	val := r.catchRule(t, rule, key)
+	if rc.update(m, gen, r.usedBy(key)) && val != nil {
+		if _, ok := val.(Container); !ok { // don't share mutable values
+			rc.store(m, gen, mkey, val)
+		}
+	}
+	return val
+}
+
+// memoKey returns the packed values of the fields
+func (r *SuRecord) memoKey(t *Thread, fields []string) string {
+	var sb strings.Builder
+	for _, f := range fields {
+		var s string
+		if x := r.getSpecial(f); x != nil {
+			s = PackValue(x)
+		} else {
+			s = r.getPacked(t, f)
+		}
+		sb.WriteString(strconv.Itoa(len(s)))
+		sb.WriteByte(':')
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
+
+// usedBy returns the fields that the rule for key depends on
+func (r *SuRecord) usedBy(key string) []string {
+	var used []string
+	for to, froms := range r.dependents {
+		if strs.Contains(froms, key) {
+			used = append(used, to)
+		}
+	}
+	return used
+}
@@ -0,0 +1,95 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestRuleCache(t *testing.T) {
+	assert := assert.T(t)
+	th := &Thread{}
+	calls := 0
+	rule := func(mul int) Value {
+		return &SuBuiltinMethod0{SuBuiltin1: SuBuiltin1{Fn: func(this Value) Value {
+			calls++
+			num := this.Get(th, SuStr("num"))
+			return OpMul(num, SuInt(mul))
+		}}}
+	}
+	Global.TestDef("Rule_rc_total", rule(10))
+	hdr := NewHeader([][]string{{"num"}}, []string{"num"})
+	rec := func(n int) *SuRecord {
+		b := RecordBuilder{}
+		b.Add(SuInt(n))
+		return SuRecordFromRow(Row{DbRec{Record: b.Build()}}, hdr, "", nil)
+	}
+	total := func(r *SuRecord) Value {
+		return r.Get(th, SuStr("rc_total"))
+	}
+	nums := []int{1, 2, 1, 1, 2}
+	for _, n := range nums {
+		assert.This(total(rec(n))).Is(SuInt(n * 10))
+	}
+	assert.This(calls).Is(len(nums))
+	assert.This(hdr.rules.rules["rc_total"]).Isnt(nil)
+	assert.This(hdr.rules.rules["num"]).Is(nil) // cached as no rule
+
+	// memoized
+	MemoizeRule("rc_total", true)
+	defer MemoizeRule("rc_total", false)
+	calls = 0
+	var r *SuRecord
+	for _, n := range nums {
+		r = rec(n)
+		assert.This(total(r)).Is(SuInt(n * 10))
+	}
+	assert.This(calls).Is(3)
+	// memoized result still has dependencies
+	r.Put(th, SuStr("num"), SuInt(5))
+	assert.This(total(r)).Is(SuInt(50))
+	assert.This(calls).Is(4)
+
+	// changing the rule resets the cache
+	Global.TestDef("Rule_rc_total", rule(100))
+	assert.This(total(rec(1))).Is(SuInt(100))
+}
+
+func TestRuleMemoDeps(t *testing.T) {
+	assert := assert.T(t)
+	th := &Thread{}
+	// uses a or b depending on flag
+	Global.TestDef("Rule_rc_choose", &SuBuiltinMethod0{SuBuiltin1: SuBuiltin1{
+		Fn: func(this Value) Value {
+			if this.Get(th, SuStr("flag")) == True {
+				return this.Get(th, SuStr("a"))
+			}
+			return this.Get(th, SuStr("b"))
+		}}})
+	MemoizeRule("rc_choose", true)
+	defer MemoizeRule("rc_choose", false)
+	hdr := NewHeader([][]string{{"flag", "a", "b"}}, []string{"flag", "a", "b"})
+	rec := func(flag bool, a, b int) *SuRecord {
+		rb := RecordBuilder{}
+		rb.Add(SuBool(flag))
+		rb.Add(SuInt(a))
+		rb.Add(SuInt(b))
+		return SuRecordFromRow(Row{DbRec{Record: rb.Build()}}, hdr, "", nil)
+	}
+	test := func(flag bool, a, b, expected int) {
+		t.Helper()
+		assert.This(rec(flag, a, b).Get(th, SuStr("rc_choose"))).
+			Is(SuInt(expected))
+	}
+	test(true, 1, 2, 1)
+	test(true, 1, 2, 1)
+	test(false, 1, 2, 2)
+	test(false, 1, 3, 3)
+	test(false, 1, 3, 3)
+	test(true, 1, 3, 1)
+	test(false, 1, 2, 2)
+	test(true, 4, 2, 4)
+}
@@ -0,0 +1,66 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+// Lesser is implemented by comparators that Sort can use directly
+// rather than calling them through the interpreter
+type Lesser interface {
+	Less(t *Thread, x, y Value) bool
+}
+
+// SortBy is a native comparator that compares members of its arguments
+// e.g. list.Sort!(SortBy(#name, #date))
+// It can also be called like a block i.e. SortBy(#name)(x, y)
+type SortBy struct {
+	SuBuiltin
+	members []Value
+}
+
+var _ Lesser = (*SortBy)(nil)
+
+func NewSortBy(members []Value) *SortBy {
+	if len(members) == 0 {
+		panic("usage: SortBy(member, ...)")
+	}
+	sb := &SortBy{members: members}
+	sb.SuBuiltin = SuBuiltin{
+		Fn: func(t *Thread, args []Value) Value {
+			return SuBool(sb.Less(t, args[0], args[1]))
+		},
+		BuiltinParams: BuiltinParams{ParamSpec: ParamSpec2}}
+	return sb
+}
+
+// Less compares the members in order, like an object Compare.
+// Missing members are treated as "" (the usual default).
+func (sb *SortBy) Less(t *Thread, x, y Value) bool {
+	for _, m := range sb.members {
+		if cmp := sortMember(t, x, m).Compare(sortMember(t, y, m)); cmp != 0 {
+			return cmp < 0
+		}
+	}
+	return false
+}
+
+func sortMember(t *Thread, x Value, m Value) Value {
+	if v := x.Get(t, m); v != nil {
+		return v
+	}
+	return EmptyStr
+}
+
+func (*SortBy) String() string {
+	return "SortBy /* builtin */"
+}
+
+func (sb *SortBy) Equal(other interface{}) bool {
+	sb2, ok := other.(*SortBy)
+	return ok && sb == sb2
+}
+
+func (sb *SortBy) SetConcurrent() {
+	for _, m := range sb.members {
+		m.SetConcurrent()
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"strings"
+
+	"github.com/apmckinlay/gsuneido/options"
+	"github.com/apmckinlay/gsuneido/runtime/types"
+	"github.com/apmckinlay/gsuneido/util/hash"
+	"github.com/apmckinlay/gsuneido/util/ints"
+	"github.com/apmckinlay/gsuneido/util/pack"
+)
+
+// SuBuffer is an immutable Value for binary data.
+// Unlike SuStr it is not treated as text,
+// indexing gives the byte values and string methods do not apply.
+// AsStr returns the raw bytes so it can be written and concatenated.
+type SuBuffer struct {
+	CantConvert
+	s string
+}
+
+func NewSuBuffer(s string) SuBuffer {
+	return SuBuffer{s: s}
+}
+
+// Bytes returns the contents as a string (not a copy)
+func (b SuBuffer) Bytes() string {
+	return b.s
+}
+
+func (b SuBuffer) Len() int {
+	return len(b.s)
+}
+
+// Value interface --------------------------------------------------
+
+var _ Value = SuBuffer{}
+
+func (b SuBuffer) AsStr() (string, bool) {
+	return b.s, true
+}
+
+func (b SuBuffer) String() string {
+	return "Buffer(" + escapeStr(b.s, 0) + ")"
+}
+
+// Get returns the byte at the given index as a number
+func (b SuBuffer) Get(_ *Thread, key Value) Value {
+	i := ToIndex(key)
+	n := len(b.s)
+	if i < 0 {
+		i += n
+	}
+	if i < 0 || n <= i {
+		return nil
+	}
+	return SuInt(int(b.s[i]))
+}
+
+func (SuBuffer) Put(*Thread, Value, Value) {
+	panic("buffer does not support put")
+}
+
+func (SuBuffer) GetPut(*Thread, Value, Value, func(x, y Value) Value, bool) Value {
+	panic("buffer does not support update")
+}
+
+func (b SuBuffer) RangeTo(from int, to int) Value {
+	size := len(b.s)
+	from = prepFrom(from, size)
+	to = prepTo(from, to, size)
+	return SuBuffer{s: b.s[from:to]}
+}
+
+func (b SuBuffer) RangeLen(from int, n int) Value {
+	size := len(b.s)
+	from = prepFrom(from, size)
+	n = prepLen(n, size-from)
+	return SuBuffer{s: b.s[from : from+n]}
+}
+
+func (b SuBuffer) Equal(other interface{}) bool {
+	b2, ok := other.(SuBuffer)
+	return ok && b.s == b2.s
+}
+
+func (b SuBuffer) Hash() uint32 {
+	return hash.SeededString(b.s)
+}
+
+func (b SuBuffer) Hash2() uint32 {
+	return b.Hash()
+}
+
+func (SuBuffer) Type() types.Type {
+	return types.Buffer
+}
+
+func (b SuBuffer) Compare(other Value) int {
+	if cmp := ints.Compare(ordBuffer, Order(other)); cmp != 0 {
+		return cmp
+	}
+	return strings.Compare(b.s, other.(SuBuffer).s)
+}
+
+func (SuBuffer) Call(*Thread, Value, *ArgSpec) Value {
+	panic("can't call Buffer")
+}
+
+// BufferMethods is initialized by the builtin package
+var BufferMethods Methods
+
+var gnBuffers = Global.Num("Buffers")
+
+func (SuBuffer) Lookup(t *Thread, method string) Callable {
+	return Lookup(t, BufferMethods, gnBuffers, method)
+}
+
+// Packable interface -----------------------------------------------
+
+var _ Packable = SuBuffer{}
+
+// PackSize includes the tag even if empty, to distinguish from "".
+// Buffers are packed in an envelope since they require version 3,
+// or as strings with options.PackV2
+func (b SuBuffer) PackSize(*int32) int {
+	if options.PackV2 {
+		return SuStr(b.s).PackSize(nil)
+	}
+	return envelopeSize + 1 + len(b.s)
+}
+
+func (b SuBuffer) PackSize2(int32, packStack) int {
+	return b.PackSize(nil)
+}
+
+func (b SuBuffer) PackSize3() int {
+	return b.PackSize(nil)
+}
+
+func (b SuBuffer) Pack(_ int32, buf *pack.Encoder) {
+	if options.PackV2 {
+		SuStr(b.s).Pack(0, buf)
+		return
+	}
+	packEnvelope3(buf)
+	buf.Put1(PackBuffer).PutStr(b.s)
+}
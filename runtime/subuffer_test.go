@@ -0,0 +1,31 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestSuBuffer(t *testing.T) {
+	assert := assert.T(t)
+	b := NewSuBuffer("\x00\xffabc")
+	assert.This(b.Get(nil, One)).Is(SuInt(255))
+	assert.This(b.Get(nil, SuInt(-1))).Is(SuInt('c'))
+	assert.This(b.Get(nil, SuInt(9))).Is(nil)
+	assert.This(b.RangeTo(2, 4)).Is(NewSuBuffer("ab"))
+	assert.This(b.RangeLen(-3, 2)).Is(NewSuBuffer("ab"))
+	assert.That(!b.Equal(SuStr("\x00\xffabc")))
+	assert.This(b.String()).Is(`Buffer("\x00\xffabc")`)
+	assert.This(NewSuBuffer("a").Compare(NewSuBuffer("b"))).Is(-1)
+	assert.This(NewSuBuffer("a").Compare(SuObjectOf())).Is(+1)
+
+	// pack
+	assert.This(Unpack(Pack(b))).Is(b)
+	empty := NewSuBuffer("")
+	assert.This(Pack(empty)).Is("\xff\x03\x08") // in an envelope
+	assert.This(Unpack(Pack(empty))).Is(empty)
+	assert.That(Pack(SuObjectOf()) < Pack(empty))
+}
@@ -0,0 +1,162 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/apmckinlay/gsuneido/runtime/types"
+)
+
+// SuChannel is a Value wrapping a Go channel
+// for communication between threads.
+// Values sent on a channel are SetConcurrent.
+// Like iterators, Receive returns the channel itself
+// when the channel is closed (and empty).
+type SuChannel struct {
+	CantConvert
+	ch chan Value
+}
+
+func NewSuChannel(size int) *SuChannel {
+	return &SuChannel{ch: make(chan Value, size)}
+}
+
+// Send sends a value, blocking if the channel is full
+func (c *SuChannel) Send(x Value) {
+	x.SetConcurrent()
+	defer func() {
+		if e := recover(); e != nil {
+			panic("Channel: send on closed channel")
+		}
+	}()
+	c.ch <- x
+}
+
+// Receive returns the next value, blocking until one is available.
+// If timeout is > 0 and no value is available by then
+// it throws "Channel.Receive: timeout".
+// If the channel is closed it returns the channel.
+func (c *SuChannel) Receive(timeout time.Duration) Value {
+	var x Value
+	var ok bool
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case x, ok = <-c.ch:
+		case <-timer.C:
+			panic("Channel.Receive: timeout")
+		}
+	} else {
+		x, ok = <-c.ch
+	}
+	if !ok {
+		return c
+	}
+	return x
+}
+
+// Close closes the channel. Receivers get any values already sent.
+func (c *SuChannel) Close() {
+	defer func() {
+		if e := recover(); e != nil {
+			panic("Channel: already closed")
+		}
+	}()
+	close(c.ch)
+}
+
+// Select waits until one of the channels has a value (or is closed)
+// and returns the channel and the value (or the channel if it is closed).
+// If timeout is > 0 and nothing is available by then it returns nil, nil.
+func Select(chans []*SuChannel, timeout time.Duration) (*SuChannel, Value) {
+	cases := make([]reflect.SelectCase, len(chans), len(chans)+1)
+	for i, c := range chans {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv,
+			Chan: reflect.ValueOf(c.ch)}
+	}
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv,
+			Chan: reflect.ValueOf(timer.C)})
+	}
+	i, x, ok := reflect.Select(cases)
+	if i >= len(chans) {
+		return nil, nil // timeout
+	}
+	c := chans[i]
+	if !ok {
+		return c, c
+	}
+	return c, x.Interface().(Value)
+}
+
+// Value interface --------------------------------------------------
+
+var _ Value = (*SuChannel)(nil)
+
+func (*SuChannel) Get(*Thread, Value) Value {
+	panic("Channel does not support get")
+}
+
+func (*SuChannel) Put(*Thread, Value, Value) {
+	panic("Channel does not support put")
+}
+
+func (*SuChannel) GetPut(*Thread, Value, Value, func(x, y Value) Value, bool) Value {
+	panic("Channel does not support update")
+}
+
+func (*SuChannel) RangeTo(int, int) Value {
+	panic("Channel does not support range")
+}
+
+func (*SuChannel) RangeLen(int, int) Value {
+	panic("Channel does not support range")
+}
+
+func (*SuChannel) Hash() uint32 {
+	panic("Channel hash not implemented")
+}
+
+func (*SuChannel) Hash2() uint32 {
+	panic("Channel hash not implemented")
+}
+
+func (*SuChannel) Compare(Value) int {
+	panic("Channel compare not implemented")
+}
+
+func (*SuChannel) Call(*Thread, Value, *ArgSpec) Value {
+	panic("can't call Channel")
+}
+
+func (*SuChannel) String() string {
+	return "aChannel"
+}
+
+func (*SuChannel) Type() types.Type {
+	return types.BuiltinInstance
+}
+
+func (c *SuChannel) Equal(other interface{}) bool {
+	c2, ok := other.(*SuChannel)
+	return ok && c == c2
+}
+
+// SetConcurrent - a channel is already thread safe
+func (*SuChannel) SetConcurrent() {
+}
+
+// ChannelMethods is initialized by the builtin package
+var ChannelMethods Methods
+
+var gnChannels = Global.Num("Channels")
+
+func (*SuChannel) Lookup(t *Thread, method string) Callable {
+	return Lookup(t, ChannelMethods, gnChannels, method)
+}
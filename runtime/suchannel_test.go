@@ -0,0 +1,50 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestSuChannel(t *testing.T) {
+	assert := assert.T(t)
+	c := NewSuChannel(0)
+	go func() {
+		for i := 0; i < 3; i++ {
+			c.Send(SuInt(i))
+		}
+		c.Close()
+	}()
+	for i := 0; i < 3; i++ {
+		assert.This(c.Receive(0)).Is(SuInt(i))
+	}
+	assert.This(c.Receive(0)).Is(c) // closed
+	assert.This(func() { c.Send(True) }).Panics("closed")
+	assert.This(func() { c.Close() }).Panics("already closed")
+
+	c = NewSuChannel(1)
+	assert.This(func() { c.Receive(time.Millisecond) }).Panics("timeout")
+	ob := &SuObject{}
+	c.Send(ob)
+	assert.That(ob.IsConcurrent() == True)
+	assert.This(c.Receive(0)).Is(ob)
+}
+
+func TestSelect(t *testing.T) {
+	assert := assert.T(t)
+	c1, c2 := NewSuChannel(1), NewSuChannel(1)
+	chans := []*SuChannel{c1, c2}
+	c, x := Select(chans, time.Millisecond)
+	assert.That(c == nil && x == nil)
+	c2.Send(SuStr("two"))
+	c, x = Select(chans, 0)
+	assert.That(c == c2)
+	assert.This(x).Is(SuStr("two"))
+	c1.Close()
+	c, x = Select(chans, 0)
+	assert.That(c == c1 && x == c1)
+}
@@ -6,6 +6,7 @@ package runtime
 import (
 	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/apmckinlay/gsuneido/runtime/types"
@@ -21,6 +22,7 @@ type SuClass struct {
 	Name         string
 	Base         Gnum
 	parentsCache atomic.Value // used by SuInstance getParents
+	methodCache  atomic.Value // *methodCache, see lookup
 	noGetter     bool
 }
 
@@ -212,6 +214,62 @@ func (c *SuClass) lookup(t *Thread, method string, parents []*SuClass) Callable
 	if f, ok := BaseMethods[method]; ok {
 		return f
 	}
+	mc := c.getMethodCache(t)
+	if mc == nil || (parents != nil && !sameParents(parents, mc.parents)) {
+		// chain couldn't be resolved,
+		// or an instance created from a previous version of the class chain
+		return c.lookup2(t, method, parents)
+	}
+	if x, ok := mc.methods.Load(method); ok {
+		return x.(Callable)
+	}
+	x := c.lookup2(t, method, mc.parents)
+	if x != nil {
+		mc.methods.Store(method, x)
+	}
+	return x
+}
+
+// methodCache caches the results of lookup so method calls
+// don't have to search the inheritance chain every time.
+// It is only valid for the Global.Version it was created with
+// and for instances with the same parents.
+type methodCache struct {
+	version int32
+	parents []*SuClass
+	methods sync.Map // string => Callable
+}
+
+// getMethodCache returns the current cache for the class,
+// or nil if the inheritance chain can't be resolved (e.g. a missing base)
+func (c *SuClass) getMethodCache(t *Thread) (mc *methodCache) {
+	version := Global.Version()
+	mc, _ = c.methodCache.Load().(*methodCache)
+	if mc == nil || mc.version != version {
+		defer func() {
+			if e := recover(); e != nil {
+				mc = nil // let the uncached lookup handle it
+			}
+		}()
+		mc = &methodCache{version: version, parents: getParents(t, c)}
+		c.methodCache.Store(mc)
+	}
+	return mc
+}
+
+func sameParents(p1, p2 []*SuClass) bool {
+	if len(p1) != len(p2) {
+		return false
+	}
+	for i := range p1 {
+		if p1[i] != p2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *SuClass) lookup2(t *Thread, method string, parents []*SuClass) Callable {
 	if x := c.get2(t, method, parents); x != nil {
 		return x
 	}
@@ -0,0 +1,33 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestMethodCache(t *testing.T) {
+	assert := assert.T(t)
+	th := &Thread{}
+	f1 := &SuBuiltin0{Fn: func() Value { return One }}
+	f2 := &SuBuiltin0{Fn: func() Value { return SuInt(2) }}
+	Global.TestDef("Mc_Base", &SuClass{MemBase: MemBase{
+		Data: map[string]Value{"F": f1}}})
+	derived := &SuClass{MemBase: NewMemBase(), Base: Global.Num("Mc_Base")}
+	assert.That(derived.Lookup(th, "F") == f1)
+	assert.That(derived.Lookup(th, "F") == f1) // cached
+	assert.That(derived.Lookup(th, "G") == nil)
+	inst := NewInstance(th, derived)
+	assert.That(inst.Lookup(th, "F") == f1)
+
+	// changing the base invalidates the cache
+	Global.TestDef("Mc_Base", &SuClass{MemBase: MemBase{
+		Data: map[string]Value{"F": f2}}})
+	assert.That(derived.Lookup(th, "F") == f2)
+	// but existing instances keep their original parents
+	assert.That(inst.Lookup(th, "F") == f1)
+	assert.That(NewInstance(th, derived).Lookup(th, "F") == f2)
+}
@@ -5,6 +5,7 @@ package runtime
 
 import (
 	"bytes"
+	"io"
 	"strings"
 
 	"github.com/apmckinlay/gsuneido/runtime/types"
@@ -17,6 +18,12 @@ import (
 
 // SuConcat is a Value used to optimize string concatenation
 // WARNING: zero value is not valid, use NewSuConcat
+//
+// It is an append only buffer rather than a tree (rope)
+// since building strings is almost always appending (e.g. s $= line).
+// Len is O(1), appending is amortized O(1),
+// and converting to a string does not copy.
+// WriteTo writes the contents without converting to a string.
 type SuConcat struct {
 	CantConvert
 	buf *scbuf
@@ -55,14 +62,36 @@ func (c SuConcat) Add(s string) SuConcat {
 	buf := c.buf
 	if buf.concurrent || // shared between threads
 		len(buf.bs) != c.n { // another SuConcat has appended their own stuff
-		// copy to our own new buffer
-		a := append(make([]byte, 0, c.n+len(s)), buf.bs[:c.n]...)
-		buf = &scbuf{bs: a}
+		// copy to our own new buffer,
+		// append leaves room to grow so further appends are still amortized
+		return SuConcat{buf: &scbuf{bs: append(buf.bs[:c.n:c.n], s...)},
+			n: c.n + len(s)}
 	}
 	buf.bs = append(buf.bs, s...)
 	return SuConcat{buf: buf, n: c.n + len(s)}
 }
 
+var _ io.WriterTo = SuConcat{}
+
+// WriteTo writes the contents directly from the buffer.
+// This is safe even if the buffer is shared
+// because the first n bytes are never modified.
+func (c SuConcat) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(c.buf.bs[:c.n])
+	return int64(n), err
+}
+
+// WriteStr writes x (converted as by ToStr) to w,
+// avoiding converting an SuConcat to a string and then back to bytes.
+func WriteStr(w io.Writer, x Value) error {
+	if c, ok := x.(SuConcat); ok {
+		_, err := c.WriteTo(w)
+		return err
+	}
+	_, err := io.WriteString(w, ToStr(x))
+	return err
+}
+
 func (c SuConcat) Iter() Iter {
 	return &stringIter{s: c.toStr()}
 }
@@ -124,7 +153,7 @@ func (c SuConcat) RangeLen(from int, n int) Value {
 }
 
 func (c SuConcat) Hash() uint32 {
-	return hash.HashBytes(c.buf.bs[:c.n])
+	return hash.SeededBytes(c.buf.bs[:c.n])
 }
 
 func (c SuConcat) Hash2() uint32 {
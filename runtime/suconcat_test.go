@@ -4,6 +4,7 @@
 package runtime
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/apmckinlay/gsuneido/util/assert"
@@ -44,6 +45,41 @@ func TestSuConcat_Equals(t *testing.T) {
 			if s.Equal(c) != expected || c.Equal(s) != expected {
 				t.Error(s, "vs", c)
 			}
+			if expected && s.Hash() != c.Hash() {
+				t.Error(s, "hash vs", c)
+			}
 		}
 	}
 }
+
+func TestSuConcat_Fork(t *testing.T) {
+	assert := assert.T(t)
+	a := NewSuConcat().Add("hello")
+	b := a.Add("world")
+	c := a.Add("there") // can't share, copies
+	assert.That(b.buf != c.buf)
+	assert.That(cap(c.buf.bs) > c.n) // room to grow
+	d := c.Add("!")
+	assert.That(d.buf == c.buf) // appends in place
+	assert.This(ToStr(b)).Is("helloworld")
+	assert.This(ToStr(d)).Is("hellothere!")
+	assert.This(d.Len()).Is(11)
+}
+
+func TestSuConcat_WriteTo(t *testing.T) {
+	assert := assert.T(t)
+	a := NewSuConcat().Add("hello")
+	b := a.Add("world")
+	a.Add("there")
+	var buf bytes.Buffer
+	n, err := a.WriteTo(&buf)
+	assert.This(err).Is(nil)
+	assert.This(n).Is(int64(5))
+	assert.This(buf.String()).Is("hello")
+
+	buf.Reset()
+	assert.This(WriteStr(&buf, b)).Is(nil)
+	assert.This(WriteStr(&buf, SuStr("!"))).Is(nil)
+	assert.This(buf.String()).Is("helloworld!")
+	assert.This(func() { WriteStr(&buf, SuInt(123)) }).Panics("can't convert")
+}
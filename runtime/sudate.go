@@ -9,10 +9,12 @@ import (
 	"strings"
 	gotime "time"
 
+	"github.com/apmckinlay/gsuneido/options"
 	"github.com/apmckinlay/gsuneido/runtime/types"
 	"github.com/apmckinlay/gsuneido/util/ascii"
 	"github.com/apmckinlay/gsuneido/util/assert"
 	"github.com/apmckinlay/gsuneido/util/ints"
+	"github.com/apmckinlay/gsuneido/util/locale"
 	"github.com/apmckinlay/gsuneido/util/pack"
 )
 
@@ -20,7 +22,8 @@ import (
 SuDate is a Suneido date/time Value
 
 Represents a readable "local" date and time.
-Does not take into account time zones or daylight savings.
+Normally it does not take into account time zones or daylight savings.
+It may optionally have a zone (offset from UTC), see sudatezone.go
 
 It is designed to be efficient to pack and unpack
 and to convert to human readable formats.
@@ -32,6 +35,10 @@ type SuDate struct {
 	date uint32
 	// 10 bits for hour, 6 bits for minute, 6 bits for second, 10 bits for ms
 	time uint32
+	// zone is the offset from UTC in minutes (east is positive)
+	// it is only used if zoned is true
+	zone  int16
+	zoned bool
 }
 
 var NilDate SuDate
@@ -169,6 +176,7 @@ func (d SuDate) Plus(yr int, mon int, day int, hr int, min int, sec int, ms int)
 	if nd == NilDate {
 		panic("bad date")
 	}
+	nd.zone, nd.zoned = d.zone, d.zoned
 	return nd
 }
 
@@ -218,6 +226,9 @@ func julianDayNumber(year, month, day int) int64 {
 //
 // WARNING: doing this around daylight savings changes may be problematic
 func (d SuDate) MinusMs(other SuDate) int64 {
+	if d.zoned && other.zoned && d.zone != other.zone {
+		d, other = d.ToUTC(), other.ToUTC()
+	}
 	if d.date == other.date {
 		return d.timeAsMs() - other.timeAsMs()
 	}
@@ -235,8 +246,12 @@ func (d SuDate) unix() int64 {
 }
 
 func (d SuDate) toGoTime() gotime.Time {
-	return goTime(d.Year(), d.Month(), d.Day(),
-		d.Hour(), d.Minute(), d.Second(), d.Millisecond())
+	loc := gotime.Local
+	if d.zoned {
+		loc = gotime.FixedZone("", int(d.zone)*60)
+	}
+	return gotime.Date(d.Year(), gotime.Month(d.Month()), d.Day(),
+		d.Hour(), d.Minute(), d.Second(), d.Millisecond()*1000000, loc)
 }
 
 func goTime(yr int, mon int, day int, hr int, min int, sec int, ms int) gotime.Time {
@@ -249,7 +264,14 @@ func fromGoTime(t gotime.Time) SuDate {
 }
 
 // Format converts the date to a string in the specified format
+// using English month and day names
 func (d SuDate) Format(fmt string) string {
+	return d.FormatLocale(fmt, locale.Default)
+}
+
+// FormatLocale converts the date to a string in the specified format
+// using the month and day names of the locale.
+func (d SuDate) FormatLocale(fmt string, loc *locale.Locale) string {
 	fmtlen := len(fmt)
 	var dst strings.Builder
 	add := func(i int) {
@@ -279,9 +301,9 @@ func (d SuDate) Format(fmt string) string {
 		case 'M':
 			mon := d.Month()
 			if n > 3 {
-				dst.WriteString(months[mon-1])
+				dst.WriteString(loc.Months[mon-1])
 			} else if n == 3 {
-				dst.WriteString(months[mon-1][0:3])
+				dst.WriteString(loc.MonthAbbrs[mon-1])
 			} else {
 				if n >= 2 || mon > 9 {
 					add(mon / 10)
@@ -290,9 +312,9 @@ func (d SuDate) Format(fmt string) string {
 			}
 		case 'd':
 			if n > 3 {
-				dst.WriteString(days[d.WeekDay()])
+				dst.WriteString(loc.Days[d.WeekDay()])
 			} else if n == 3 {
-				dst.WriteString(days[d.WeekDay()][0:3])
+				dst.WriteString(loc.DayAbbrs[d.WeekDay()])
 			} else {
 				if n >= 2 || d.Day() > 9 {
 					add(d.Day() / 10)
@@ -694,6 +716,9 @@ var (
 var _ Value = (*SuDate)(nil)
 
 func (d SuDate) String() string {
+	if d.zoned {
+		return "Date(" + escapeStr(d.FormatISO(), 0) + ")"
+	}
 	if d.time == 0 {
 		return fmt.Sprintf("#%04d%02d%02d", d.Year(), d.Month(), d.Day())
 	}
@@ -718,6 +743,9 @@ func (d SuDate) Hash() uint32 {
 	h := uint32(17)
 	h = 31*h + d.date
 	h = 31*h + d.time
+	if d.zoned {
+		h = 31*h + uint32(d.zone)
+	}
 	return h
 }
 
@@ -754,6 +782,7 @@ func (d SuDate) Compare(other Value) int {
 		return cmp
 	}
 	d2 := other.(SuDate)
+	// same order as packed, the zone is a suffix so unzoned sorts first
 	if d.date < d2.date {
 		return -1
 	} else if d.date > d2.date {
@@ -763,7 +792,7 @@ func (d SuDate) Compare(other Value) int {
 	} else if d.time > d2.time {
 		return +1
 	}
-	return 0
+	return ints.Compare(d.packedZone(), d2.packedZone())
 }
 
 func (SuDate) Call(*Thread, Value, *ArgSpec) Value {
@@ -784,21 +813,43 @@ func (SuDate) Lookup(t *Thread, method string) Callable {
 var _ Packable = SuDate{}
 
 // PackSize returns the packed size (Packable interface)
-func (SuDate) PackSize(*int32) int {
+func (d SuDate) PackSize(*int32) int {
+	if d.packZone() {
+		// zone is a suffix that older readers ignore,
+		// so unzoned dates are unchanged
+		return 11
+	}
 	return 9
 }
 
-func (SuDate) PackSize2(int32, packStack) int {
-	return 9
+func (d SuDate) PackSize2(int32, packStack) int {
+	return d.PackSize(nil)
 }
 
-func (SuDate) PackSize3() int {
-	return 9
+func (d SuDate) PackSize3() int {
+	return d.PackSize(nil)
 }
 
 // Pack packs into the supplied byte slice (Packable interface)
 func (d SuDate) Pack(_ int32, buf *pack.Encoder) {
 	buf.Put1(PackDate).Uint32(d.date).Uint32(d.time)
+	if d.packZone() {
+		buf.Uint16(uint16(d.packedZone()))
+	}
+}
+
+// packZone returns whether the zone is packed, not with options.PackV2
+func (d SuDate) packZone() bool {
+	return d.zoned && !options.PackV2
+}
+
+// packedZone biases the zone so it sorts correctly as unsigned.
+// Unzoned is 0, it sorts first.
+func (d SuDate) packedZone() int {
+	if !d.zoned {
+		return 0
+	}
+	return int(d.zone) + 0x8000
 }
 
 // UnpackDate unpacks a date from the supplied byte slice
@@ -806,5 +857,9 @@ func UnpackDate(s string) SuDate {
 	d := pack.NewDecoder(s[1:])
 	date := d.Uint32()
 	time := d.Uint32()
-	return SuDate{date: date, time: time}
+	if len(s) < 11 {
+		return SuDate{date: date, time: time}
+	}
+	zone := int16(int(d.Uint16()) - 0x8000)
+	return SuDate{date: date, time: time, zone: zone, zoned: true}
 }
@@ -4,9 +4,12 @@
 package runtime
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/apmckinlay/gsuneido/util/assert"
+	"github.com/apmckinlay/gsuneido/util/ints"
+	"github.com/apmckinlay/gsuneido/util/locale"
 )
 
 func TestOne(t *testing.T) {
@@ -253,3 +256,73 @@ func TestIncrement(t *testing.T) {
 	assert.T(t).This(d2.time).Is(0)
 	assert.T(t).This(d2.date).Is(d.date + 1)
 }
+
+func TestDateZone(t *testing.T) {
+	assert := assert.T(t).This
+	iso := func(s, expected string) {
+		t.Helper()
+		d := DateFromISO(s)
+		if expected == "" {
+			assert(d).Is(NilDate)
+		} else {
+			assert(d.FormatISO()).Is(expected)
+		}
+	}
+	iso("2024-01-02", "2024-01-02")
+	iso("2024-01-02T03:04", "2024-01-02T03:04:00")
+	iso("2024-01-02T03:04:05.6", "2024-01-02T03:04:05.600")
+	iso("2024-01-02T03:04:05Z", "2024-01-02T03:04:05Z")
+	iso("2024-01-02T03:04:05.123-0800", "2024-01-02T03:04:05.123-08:00")
+	iso("2024-01-02T03:04+05:30", "2024-01-02T03:04:00+05:30")
+	iso("2024-01-02T03:04:05+5", "")
+	iso("2024-13-02", "")
+	iso("20240102", "")
+
+	d := DateFromISO("2024-01-02T03:04:05-05:00")
+	offset, ok := d.Zone()
+	assert(offset).Is(-300)
+	assert(ok).Is(true)
+	_, ok = DateFromISO("2024-01-02").Zone()
+	assert(ok).Is(false)
+	assert(d.ToUTC().FormatISO()).Is("2024-01-02T08:04:05Z")
+	assert(d.ToLocal("+01:00").FormatISO()).Is("2024-01-02T09:04:05+01:00")
+	assert(d.ToUTC().MinusMs(d)).Is(int64(0))
+	assert(d.Plus(0, 0, 1, 0, 0, 0, 0).FormatISO()).
+		Is("2024-01-03T03:04:05-05:00")
+	assert(d.String()).Is(`Date("2024-01-02T03:04:05-05:00")`)
+
+	// packing is unchanged for dates without a zone
+	local := NewDate(2024, 1, 2, 3, 4, 5, 0)
+	assert(len(Pack(local))).Is(9)
+	assert(Unpack(Pack(d))).Is(d)
+	assert(Unpack(Pack(d)).Equal(local)).Is(false)
+	// ordering matches packed
+	for _, x := range []SuDate{local, d, d.WithZone(60), d.WithZone(-600)} {
+		for _, y := range []SuDate{local, d, d.WithZone(60), d.WithZone(-600)} {
+			assert(x.Compare(y)).Is(ints.Compare(
+				strings.Compare(Pack(x), Pack(y)), 0))
+		}
+	}
+}
+
+func TestDateFormatLocale(t *testing.T) {
+	assert := assert.T(t)
+	d := DateFromLiteral("#20240203.1504")
+	test := func(name, format, expected string) {
+		t.Helper()
+		loc, _ := locale.Get(name)
+		if format == "" {
+			format = loc.ShortDate
+		}
+		assert.This(d.FormatLocale(format, loc)).Is(expected)
+	}
+	assert.This(d.Format("")).Is("")
+	test("en-US", "", "2/3/2024")
+	test("en-GB", "", "03/02/2024")
+	test("fr-CA", "", "2024-02-03")
+	test("de-DE", "", "03.02.2024")
+	test("fr-FR", "dddd d MMMM yyyy", "samedi 3 février 2024")
+	test("fr-FR", "ddd d MMM", "sam. 3 févr.")
+	test("de", "MMM", "Feb.")
+	test("en-US", "ddd MMM d", "Sat Feb 3")
+}
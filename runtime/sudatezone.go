@@ -0,0 +1,153 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	gotime "time"
+)
+
+// Zone returns the offset from UTC in minutes (east is positive)
+// and false if the date does not have a zone (i.e. it is "local")
+func (d SuDate) Zone() (int, bool) {
+	return int(d.zone), d.zoned
+}
+
+// WithZone returns the same date and time with the given zone
+// (offset from UTC in minutes). It does not convert.
+func (d SuDate) WithZone(offset int) SuDate {
+	d.zone, d.zoned = int16(offset), true
+	return d
+}
+
+// ToUTC converts to UTC (zone 0).
+// A date without a zone is treated as system local time.
+func (d SuDate) ToUTC() SuDate {
+	return d.ToZone(0)
+}
+
+// ToZone converts to the given zone (offset from UTC in minutes).
+// A date without a zone is treated as system local time.
+func (d SuDate) ToZone(offset int) SuDate {
+	t := d.toGoTime().In(gotime.FixedZone("", offset*60))
+	return fromGoTime(t).WithZone(offset)
+}
+
+// ToLocal converts to the given time zone,
+// which may be an offset like "+05:00" or "Z"
+// or a location name like "America/Toronto".
+// If tz is "" it converts to system local time without a zone.
+// A date without a zone is treated as system local time.
+func (d SuDate) ToLocal(tz string) SuDate {
+	t := d.toGoTime()
+	if tz == "" {
+		return fromGoTime(t.In(gotime.Local))
+	}
+	if offset, ok := parseZone(tz); ok {
+		return d.ToZone(offset)
+	}
+	loc, err := gotime.LoadLocation(tz)
+	if err != nil {
+		panic("date.ToLocal: " + err.Error())
+	}
+	t = t.In(loc)
+	_, offset := t.Zone()
+	return fromGoTime(t).WithZone(offset / 60)
+}
+
+// FormatISO returns the date in ISO 8601 format e.g. 2006-01-02T15:04:05.999
+// with a zone designator (Z or +hh:mm) if the date has a zone.
+// The time is omitted if it is zero and there is no zone.
+func (d SuDate) FormatISO() string {
+	s := fmt.Sprintf("%04d-%02d-%02d", d.Year(), d.Month(), d.Day())
+	if d.time != 0 || d.zoned {
+		s += fmt.Sprintf("T%02d:%02d:%02d", d.Hour(), d.Minute(), d.Second())
+		if ms := d.Millisecond(); ms != 0 {
+			s += fmt.Sprintf(".%03d", ms)
+		}
+	}
+	if d.zoned {
+		s += formatZone(int(d.zone))
+	}
+	return s
+}
+
+func formatZone(offset int) string {
+	if offset == 0 {
+		return "Z"
+	}
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, offset/60, offset%60)
+}
+
+// DateFromISO parses an ISO 8601 date e.g. 2006-01-02T15:04:05.999+05:00
+// The time and the zone designator are optional.
+// If there is no zone designator the result does not have a zone.
+// It returns NilDate if the string is not valid.
+func DateFromISO(s string) SuDate {
+	if len(s) < 10 || s[4] != '-' || s[7] != '-' {
+		return NilDate
+	}
+	year, month, day := nsub(s, 0, 4), nsub(s, 5, 7), nsub(s, 8, 10)
+	s = s[10:]
+	hour, minute, second, ms := 0, 0, 0, 0
+	if s != "" && (s[0] == 'T' || s[0] == ' ') {
+		if len(s) < 6 || s[3] != ':' {
+			return NilDate
+		}
+		hour, minute = nsub(s, 1, 3), nsub(s, 4, 6)
+		s = s[6:]
+		if len(s) >= 3 && s[0] == ':' {
+			second = nsub(s, 1, 3)
+			s = s[3:]
+			if len(s) >= 2 && s[0] == '.' {
+				n := 1
+				for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+					n++
+				}
+				frac := (s[1:n] + "00")[:3] // milliseconds
+				ms = nsub(frac, 0, 3)
+				s = s[n:]
+			}
+		}
+	}
+	d := NewDate(year, month, day, hour, minute, second, ms)
+	if d == NilDate || s == "" {
+		return d
+	}
+	offset, ok := parseZone(s)
+	if !ok {
+		return NilDate
+	}
+	return d.WithZone(offset)
+}
+
+// parseZone parses Z, +hh, +hhmm, or +hh:mm (or -)
+// and returns the offset from UTC in minutes
+func parseZone(s string) (int, bool) {
+	if s == "Z" || s == "z" {
+		return 0, true
+	}
+	if len(s) < 3 || (s[0] != '+' && s[0] != '-') {
+		return 0, false
+	}
+	sign := 1
+	if s[0] == '-' {
+		sign = -1
+	}
+	s = strings.Replace(s[1:], ":", "", 1)
+	if len(s) != 2 && len(s) != 4 {
+		return 0, false
+	}
+	hr, min := nsub(s, 0, 2), nsub(s, 2, 4)
+	if hr < 0 || hr > 14 || min < 0 || min > 59 {
+		return 0, false
+	}
+	return sign * (hr*60 + min), true
+}
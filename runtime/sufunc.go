@@ -4,9 +4,13 @@
 package runtime
 
 import (
+	"strings"
+
 	"github.com/apmckinlay/gsuneido/runtime/opcodes"
 	"github.com/apmckinlay/gsuneido/runtime/types"
 	"github.com/apmckinlay/gsuneido/util/assert"
+	"github.com/apmckinlay/gsuneido/util/hacks"
+	"github.com/apmckinlay/gsuneido/util/ints"
 	"github.com/apmckinlay/gsuneido/util/str"
 )
 
@@ -32,8 +36,13 @@ type SuFunc struct {
 	// SrcBase is the starting point for the SrcPos source deltas
 	SrcBase int
 
+	// Lines contains the line number deltas corresponding to SrcPos
+	Lines string
+	// LineBase is the line number of SrcBase, 0 if unknown
+	LineBase int
+
 	// Nlocals is the number of parameters and local variables
-	Nlocals uint8
+	Nlocals uint16
 
 	IsBlock bool
 }
@@ -106,6 +115,51 @@ func (f *SuFunc) CodeToSrcPos(ip int) int {
 	return sp
 }
 
+// CodeToLine returns the source line number for a code position.
+// It returns 0 if the line information was not set.
+func (f *SuFunc) CodeToLine(ip int) int {
+	if f.LineBase == 0 {
+		return 0
+	}
+	line := f.LineBase
+	cp := 0
+	for i := 0; i < len(f.SrcPos); i += 2 {
+		prev := line
+		line += int(f.Lines[i>>1])
+		cp += int(f.SrcPos[i+1])
+		if cp > ip {
+			return prev
+		}
+	}
+	return line
+}
+
+// SetLines sets Lines and LineBase from SrcBase and SrcPos
+// so line numbers are available without keeping the source.
+// It also handles nested blocks.
+func (f *SuFunc) SetLines(src string) {
+	if f.LineBase != 0 {
+		return // already done
+	}
+	clamp := func(i int) int {
+		return ints.Max(0, ints.Min(len(src), i))
+	}
+	f.LineBase = 1 + strings.Count(src[:clamp(f.SrcBase)], "\n")
+	lines := make([]byte, len(f.SrcPos)>>1)
+	sp := f.SrcBase
+	for i := 0; i < len(f.SrcPos); i += 2 {
+		next := sp + int(f.SrcPos[i])
+		lines[i>>1] = byte(strings.Count(src[clamp(sp):clamp(next)], "\n"))
+		sp = next
+	}
+	f.Lines = hacks.BStoS(lines)
+	for _, v := range f.Values {
+		if g, ok := v.(*SuFunc); ok && g.IsBlock {
+			g.SetLines(src) // RECURSE
+		}
+	}
+}
+
 // coverage ---------------------------------------------------------
 
 func (f *SuFunc) StartCoverage(count bool) {
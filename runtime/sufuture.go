@@ -0,0 +1,227 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/apmckinlay/gsuneido/options"
+	"github.com/apmckinlay/gsuneido/runtime/types"
+)
+
+// SuFuture is the eventual result of a callable run by Spawn
+// on the pool of worker threads.
+type SuFuture struct {
+	CantConvert
+	done   chan struct{}
+	result Value
+	err    interface{} // the exception if the callable threw
+}
+
+// Spawn runs fn on a worker thread and returns a future for its result.
+// fn and args cross to another thread so they are SetConcurrent.
+//
+// NOTE: There are a fixed number of workers (options.Nworkers)
+// so a spawned callable that waits for other futures can deadlock
+// if all the workers are waiting.
+func Spawn(fn Value, args ...Value) *SuFuture {
+	fn.SetConcurrent()
+	for _, a := range args {
+		a.SetConcurrent()
+	}
+	f := &SuFuture{done: make(chan struct{})}
+	workers.run(func(t *Thread) {
+		f.complete(t, fn, args)
+	})
+	return f
+}
+
+// complete runs fn on t and records its result or exception
+func (f *SuFuture) complete(t *Thread, fn Value, args []Value) {
+	state := t.GetState()
+	defer func() {
+		if e := recover(); e != nil {
+			t.RestoreState(state)
+			f.err = e
+		}
+		close(f.done)
+	}()
+	f.result = t.Call(fn, args...)
+	if f.result != nil {
+		f.result.SetConcurrent()
+	}
+}
+
+// Wait blocks until the result is available and returns it.
+// If the callable threw, Wait throws the same exception.
+// If timeout is > 0 and the result isn't available by then
+// Wait throws "Future.Wait: timeout"
+func (f *SuFuture) Wait(timeout time.Duration) Value {
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-f.done:
+		case <-timer.C:
+			panic("Future.Wait: timeout")
+		}
+	} else {
+		<-f.done
+	}
+	if f.err != nil {
+		panic(f.err)
+	}
+	return f.result
+}
+
+// Done returns whether the result is available (without waiting)
+func (f *SuFuture) Done() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Then returns a future for the result of calling fn with the result of f.
+// If f throws, so does the returned future (and fn is not called).
+// fn runs on a worker thread after f is done.
+func (f *SuFuture) Then(fn Value) *SuFuture {
+	fn.SetConcurrent()
+	f2 := &SuFuture{done: make(chan struct{})}
+	go func() {
+		<-f.done
+		if f.err != nil {
+			f2.err = f.err
+			close(f2.done)
+			return
+		}
+		workers.run(func(t *Thread) {
+			args := []Value{}
+			if f.result != nil {
+				args = append(args, f.result)
+			}
+			f2.complete(t, fn, args)
+		})
+	}()
+	return f2
+}
+
+// WaitAll waits for all of the futures and returns their results.
+// If any of them threw, it throws the first exception.
+func WaitAll(futures []*SuFuture) []Value {
+	results := make([]Value, len(futures))
+	for i, f := range futures {
+		results[i] = f.Wait(0)
+	}
+	return results
+}
+
+// workers is the pool of threads used by Spawn
+
+type workerPool struct {
+	once sync.Once
+	jobs chan func(t *Thread)
+}
+
+var workers workerPool
+
+func (wp *workerPool) run(job func(t *Thread)) {
+	wp.once.Do(wp.start)
+	wp.jobs <- job
+}
+
+func (wp *workerPool) start() {
+	wp.jobs = make(chan func(t *Thread), 100)
+	for i := 0; i < options.Nworkers; i++ {
+		go wp.worker()
+	}
+}
+
+func (wp *workerPool) worker() {
+	t := NewThread()
+	t.Name = "Worker-" + t.Name
+	defer t.Close()
+	for job := range wp.jobs {
+		wp.runJob(t, job)
+	}
+}
+
+func (wp *workerPool) runJob(t *Thread, job func(t *Thread)) {
+	defer func() {
+		if e := recover(); e != nil {
+			log.Println("ERROR in worker:", e) // shouldn't happen
+		}
+	}()
+	job(t)
+}
+
+// Value interface --------------------------------------------------
+
+var _ Value = (*SuFuture)(nil)
+
+func (*SuFuture) Get(*Thread, Value) Value {
+	panic("Future does not support get")
+}
+
+func (*SuFuture) Put(*Thread, Value, Value) {
+	panic("Future does not support put")
+}
+
+func (*SuFuture) GetPut(*Thread, Value, Value, func(x, y Value) Value, bool) Value {
+	panic("Future does not support update")
+}
+
+func (*SuFuture) RangeTo(int, int) Value {
+	panic("Future does not support range")
+}
+
+func (*SuFuture) RangeLen(int, int) Value {
+	panic("Future does not support range")
+}
+
+func (*SuFuture) Hash() uint32 {
+	panic("Future hash not implemented")
+}
+
+func (*SuFuture) Hash2() uint32 {
+	panic("Future hash not implemented")
+}
+
+func (*SuFuture) Compare(Value) int {
+	panic("Future compare not implemented")
+}
+
+func (*SuFuture) Call(*Thread, Value, *ArgSpec) Value {
+	panic("can't call Future")
+}
+
+func (*SuFuture) String() string {
+	return "aFuture"
+}
+
+func (*SuFuture) Type() types.Type {
+	return types.BuiltinInstance
+}
+
+func (f *SuFuture) Equal(other interface{}) bool {
+	f2, ok := other.(*SuFuture)
+	return ok && f == f2
+}
+
+// SetConcurrent - a future is already thread safe
+func (*SuFuture) SetConcurrent() {
+}
+
+// FutureMethods is initialized by the builtin package
+var FutureMethods Methods
+
+var gnFutures = Global.Num("Futures")
+
+func (*SuFuture) Lookup(t *Thread, method string) Callable {
+	return Lookup(t, FutureMethods, gnFutures, method)
+}
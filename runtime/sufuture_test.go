@@ -0,0 +1,39 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestSpawn(t *testing.T) {
+	assert := assert.T(t)
+	five := &SuBuiltin0{Fn: func() Value { return SuInt(5) }}
+	f := Spawn(five)
+	assert.This(f.Wait(0)).Is(SuInt(5))
+	assert.That(f.Done())
+
+	double := &SuBuiltin1{Fn: func(x Value) Value { return OpMul(x, SuInt(2)) },
+		BuiltinParams: BuiltinParams{ParamSpec: ParamSpec{Nparams: 1,
+			Flags: []Flag{0}, Names: []string{"x"}}}}
+	assert.This(f.Then(double).Then(double).Wait(0)).Is(SuInt(20))
+
+	boom := &SuBuiltin0{Fn: func() Value { panic("boom") }}
+	f = Spawn(boom)
+	assert.This(func() { f.Wait(0) }).Panics("boom")
+	assert.This(func() { f.Then(double).Wait(0) }).Panics("boom")
+
+	block := make(chan struct{})
+	slow := &SuBuiltin0{Fn: func() Value { <-block; return True }}
+	f = Spawn(slow)
+	assert.This(func() { f.Wait(time.Millisecond) }).Panics("timeout")
+	assert.That(!f.Done())
+	close(block)
+
+	results := WaitAll([]*SuFuture{Spawn(five), f})
+	assert.This(results).Is([]Value{SuInt(5), True})
+}
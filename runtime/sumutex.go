@@ -0,0 +1,232 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"sync"
+
+	"github.com/apmckinlay/gsuneido/runtime/types"
+)
+
+// SuMutex is a Value wrapping a mutex for explicit synchronization
+// e.g. for compound updates to several concurrent objects.
+// Unlike sync.Mutex it tracks the owning thread
+// so locking twice from the same thread, or unlocking from another thread,
+// throws an exception rather than deadlocking.
+//
+// The per-object locks (see MayLock) are only held for single operations
+// and never while calling Suneido code,
+// so holding an SuMutex while accessing objects can not deadlock with them.
+type SuMutex struct {
+	CantConvert
+	mu sync.Mutex
+	// ownerLock guards owner
+	ownerLock sync.Mutex
+	// owner is the thread holding mu, nil if unlocked
+	owner *Thread
+}
+
+func NewSuMutex() *SuMutex {
+	return &SuMutex{}
+}
+
+func (m *SuMutex) Lock(t *Thread) {
+	if m.getOwner() == t {
+		panic("Mutex: already locked by this thread")
+	}
+	m.mu.Lock()
+	m.setOwner(t)
+}
+
+func (m *SuMutex) Unlock(t *Thread) {
+	if m.getOwner() != t {
+		panic("Mutex: not locked by this thread")
+	}
+	m.setOwner(nil)
+	m.mu.Unlock()
+}
+
+func (m *SuMutex) getOwner() *Thread {
+	m.ownerLock.Lock()
+	defer m.ownerLock.Unlock()
+	return m.owner
+}
+
+func (m *SuMutex) setOwner(t *Thread) {
+	m.ownerLock.Lock()
+	defer m.ownerLock.Unlock()
+	m.owner = t
+}
+
+// With calls fn while holding the lock,
+// releasing it even if fn throws
+func (m *SuMutex) With(t *Thread, fn Value) Value {
+	m.Lock(t)
+	defer m.Unlock(t)
+	return t.Call(fn)
+}
+
+// SuCond is a Value for a condition variable associated with an SuMutex
+type SuCond struct {
+	CantConvert
+	m    *SuMutex
+	cond *sync.Cond
+}
+
+func NewSuCond(m *SuMutex) *SuCond {
+	return &SuCond{m: m, cond: sync.NewCond(&m.mu)}
+}
+
+// Wait releases the mutex, waits for Signal or Broadcast,
+// and then reacquires the mutex before returning.
+// The mutex must be locked by this thread.
+// As with sync.Cond, callers should wait in a loop checking their condition.
+func (c *SuCond) Wait(t *Thread) {
+	if c.m.getOwner() != t {
+		panic("Condition: Wait requires the mutex to be locked by this thread")
+	}
+	c.m.setOwner(nil)
+	c.cond.Wait()
+	c.m.setOwner(t)
+}
+
+// Signal wakes one waiting thread (if any)
+func (c *SuCond) Signal() {
+	c.cond.Signal()
+}
+
+// Broadcast wakes all the waiting threads
+func (c *SuCond) Broadcast() {
+	c.cond.Broadcast()
+}
+
+// Value interface --------------------------------------------------
+
+var _ Value = (*SuMutex)(nil)
+
+func (*SuMutex) Get(*Thread, Value) Value {
+	panic("Mutex does not support get")
+}
+
+func (*SuMutex) Put(*Thread, Value, Value) {
+	panic("Mutex does not support put")
+}
+
+func (*SuMutex) GetPut(*Thread, Value, Value, func(x, y Value) Value, bool) Value {
+	panic("Mutex does not support update")
+}
+
+func (*SuMutex) RangeTo(int, int) Value {
+	panic("Mutex does not support range")
+}
+
+func (*SuMutex) RangeLen(int, int) Value {
+	panic("Mutex does not support range")
+}
+
+func (*SuMutex) Hash() uint32 {
+	panic("Mutex hash not implemented")
+}
+
+func (*SuMutex) Hash2() uint32 {
+	panic("Mutex hash not implemented")
+}
+
+func (*SuMutex) Compare(Value) int {
+	panic("Mutex compare not implemented")
+}
+
+func (*SuMutex) Call(*Thread, Value, *ArgSpec) Value {
+	panic("can't call Mutex")
+}
+
+func (*SuMutex) String() string {
+	return "aMutex"
+}
+
+func (*SuMutex) Type() types.Type {
+	return types.BuiltinInstance
+}
+
+func (m *SuMutex) Equal(other interface{}) bool {
+	m2, ok := other.(*SuMutex)
+	return ok && m == m2
+}
+
+// SetConcurrent - a mutex is already thread safe
+func (*SuMutex) SetConcurrent() {
+}
+
+// MutexMethods is initialized by the builtin package
+var MutexMethods Methods
+
+var gnMutexes = Global.Num("Mutexes")
+
+func (*SuMutex) Lookup(t *Thread, method string) Callable {
+	return Lookup(t, MutexMethods, gnMutexes, method)
+}
+
+var _ Value = (*SuCond)(nil)
+
+func (*SuCond) Get(*Thread, Value) Value {
+	panic("Condition does not support get")
+}
+
+func (*SuCond) Put(*Thread, Value, Value) {
+	panic("Condition does not support put")
+}
+
+func (*SuCond) GetPut(*Thread, Value, Value, func(x, y Value) Value, bool) Value {
+	panic("Condition does not support update")
+}
+
+func (*SuCond) RangeTo(int, int) Value {
+	panic("Condition does not support range")
+}
+
+func (*SuCond) RangeLen(int, int) Value {
+	panic("Condition does not support range")
+}
+
+func (*SuCond) Hash() uint32 {
+	panic("Condition hash not implemented")
+}
+
+func (*SuCond) Hash2() uint32 {
+	panic("Condition hash not implemented")
+}
+
+func (*SuCond) Compare(Value) int {
+	panic("Condition compare not implemented")
+}
+
+func (*SuCond) Call(*Thread, Value, *ArgSpec) Value {
+	panic("can't call Condition")
+}
+
+func (*SuCond) String() string {
+	return "aCondition"
+}
+
+func (*SuCond) Type() types.Type {
+	return types.BuiltinInstance
+}
+
+func (c *SuCond) Equal(other interface{}) bool {
+	c2, ok := other.(*SuCond)
+	return ok && c == c2
+}
+
+// SetConcurrent - a condition is already thread safe
+func (*SuCond) SetConcurrent() {
+}
+
+// CondMethods is initialized by the builtin package
+var CondMethods Methods
+
+var gnConditions = Global.Num("Conditions")
+
+func (*SuCond) Lookup(t *Thread, method string) Callable {
+	return Lookup(t, CondMethods, gnConditions, method)
+}
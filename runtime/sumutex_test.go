@@ -0,0 +1,51 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestSuMutex(t *testing.T) {
+	assert := assert.T(t)
+	th, th2 := &Thread{}, &Thread{}
+	m := NewSuMutex()
+	m.Lock(th)
+	assert.This(func() { m.Lock(th) }).Panics("already locked")
+	assert.This(func() { m.Unlock(th2) }).Panics("not locked")
+	m.Unlock(th)
+	assert.This(func() { m.Unlock(th) }).Panics("not locked")
+
+	fail := &SuBuiltin0{Fn: func() Value { panic("fail") }}
+	assert.This(func() { m.With(th, fail) }).Panics("fail")
+	m.Lock(th) // With unlocked
+	m.Unlock(th)
+}
+
+func TestSuCond(t *testing.T) {
+	assert := assert.T(t)
+	m := NewSuMutex()
+	c := NewSuCond(m)
+	th := &Thread{}
+	assert.This(func() { c.Wait(th) }).Panics("requires the mutex")
+
+	ready := false
+	done := make(chan bool)
+	go func() {
+		th2 := &Thread{}
+		m.Lock(th2)
+		for !ready {
+			c.Wait(th2)
+		}
+		m.Unlock(th2)
+		done <- true
+	}()
+	m.Lock(th)
+	ready = true
+	c.Signal()
+	m.Unlock(th)
+	assert.That(<-done)
+}
@@ -45,6 +45,9 @@ type SuObject struct {
 	// It is used to detect modification during packing.
 	clock    int32
 	readonly bool
+	// shared is set by Snapshot when the snapshot shares list and named.
+	// They are copied before the next modification (copy on write).
+	shared bool
 }
 
 // NewSuObject creates an SuObject from a slice of Value's
@@ -158,7 +161,8 @@ func (ob *SuObject) namedGet(key Value) Value {
 
 // Put adds or updates the given key and value
 // The value will be added to the list if the key is the "next"
-func (ob *SuObject) Put(_ *Thread, key, val Value) {
+func (ob *SuObject) Put(t *Thread, key, val Value) {
+	t.Alloc(MemberSize)
 	if ob.Lock() {
 		defer ob.Unlock()
 	}
@@ -182,6 +186,50 @@ func (ob *SuObject) GetPut(_ *Thread, m, v Value,
 	return v
 }
 
+// GetInit returns the value of key if present,
+// otherwise it sets key to val and returns val
+func (ob *SuObject) GetInit(_ *Thread, key, val Value) Value {
+	if ob.Lock() {
+		defer ob.Unlock()
+	}
+	if x := ob.getIfPresent(key); x != nil {
+		return x
+	}
+	ob.set(key, val)
+	return val
+}
+
+// Increment adds by to the value of key and returns the new value.
+// Unlike GetPut (+=) a missing member is treated as 0.
+func (ob *SuObject) Increment(_ *Thread, key, by Value) Value {
+	if ob.Lock() {
+		defer ob.Unlock()
+	}
+	x := ob.get(key)
+	if x == nil {
+		x = Zero
+	}
+	x = OpAdd(x, by)
+	ob.set(key, x)
+	return x
+}
+
+// AddUnique adds val to the list unless it is already in the list.
+// It returns whether val was added.
+func (ob *SuObject) AddUnique(val Value) bool {
+	if ob.Lock() {
+		defer ob.Unlock()
+		val.SetConcurrent()
+	}
+	for _, v := range ob.list {
+		if v.Equal(val) {
+			return false
+		}
+	}
+	ob.add(val)
+	return true
+}
+
 // Set implements Put, doesn't require thread.
 // The value will be added to the list if the key is the "next"
 func (ob *SuObject) Set(key, val Value) {
@@ -408,6 +456,16 @@ func (ob *SuObject) mustBeMutable() {
 	if ob.readonly {
 		panic("can't modify readonly objects")
 	}
+	if ob.shared {
+		ob.unshare()
+	}
+}
+
+// unshare copies list and named so modifications don't affect a Snapshot
+func (ob *SuObject) unshare() {
+	ob.list = append(ob.list[:0:0], ob.list...)
+	ob.named = *ob.named.Copy()
+	ob.shared = false
 }
 
 func (ob *SuObject) migrate() {
@@ -836,6 +894,9 @@ func (ob *SuObject) ToRecord(t *Thread, hdr *Header) Record {
 	return rb.Trim().Build()
 }
 
+// Sort sorts the list values. The sort is stable.
+// lt is False to use Compare, a Lesser (e.g. SortBy) which is used directly,
+// or else a callable (e.g. a block) which is called for each comparison.
 func (ob *SuObject) Sort(t *Thread, lt Value) {
 	if ob.Lock() {
 		defer ob.Unlock()
@@ -847,6 +908,10 @@ func (ob *SuObject) Sort(t *Thread, lt Value) {
 		sort.SliceStable(ob.list, func(i, j int) bool {
 			return ob.list[i].Compare(ob.list[j]) < 0
 		})
+	} else if lsr, ok := lt.(Lesser); ok {
+		sort.SliceStable(ob.list, func(i, j int) bool {
+			return lsr.Less(t, ob.list[i], ob.list[j])
+		})
 	} else {
 		sort.SliceStable(ob.list, func(i, j int) bool {
 			return True == t.Call(lt, ob.list[i], ob.list[j])
@@ -879,6 +944,91 @@ func (ob *SuObject) Unique() {
 	ob.list = ob.list[:dst]
 }
 
+// set operations ---------------------------------------------------
+
+// These only use the list values.
+// They use a hash set for membership so they are O(n) rather than O(n^2).
+
+// Union returns a new list with the values from ob
+// followed by the values from ob2 that are not in ob, without duplicates.
+func (ob *SuObject) Union(ob2 *SuObject) *SuObject {
+	list1, list2 := ob.listCopy(), ob2.listCopy()
+	seen := Hmap{}
+	result := make([]Value, 0, len(list1)+len(list2))
+	for _, list := range [2][]Value{list1, list2} {
+		for _, v := range list {
+			if seen.Get(v) == nil {
+				seen.Put(v, True)
+				result = append(result, v)
+			}
+		}
+	}
+	return NewSuObject(result)
+}
+
+// Intersect returns a new list with the values from ob that are also in ob2
+func (ob *SuObject) Intersect(ob2 *SuObject) *SuObject {
+	return ob.filterIn(ob2, true)
+}
+
+// Difference returns a new list with the values from ob that are not in ob2
+func (ob *SuObject) Difference(ob2 *SuObject) *SuObject {
+	return ob.filterIn(ob2, false)
+}
+
+func (ob *SuObject) filterIn(ob2 *SuObject, in bool) *SuObject {
+	list1, list2 := ob.listCopy(), ob2.listCopy()
+	set := Hmap{}
+	for _, v := range list2 {
+		set.Put(v, True)
+	}
+	result := make([]Value, 0, len(list1))
+	for _, v := range list1 {
+		if (set.Get(v) != nil) == in {
+			result = append(result, v)
+		}
+	}
+	return NewSuObject(result)
+}
+
+// MergeUnion returns a new sorted list of the values from ob and ob2
+// without duplicates. Both lists must be sorted.
+func (ob *SuObject) MergeUnion(ob2 *SuObject) *SuObject {
+	list1, list2 := ob.listCopy(), ob2.listCopy()
+	result := make([]Value, 0, len(list1)+len(list2))
+	add := func(v Value) {
+		if n := len(result); n == 0 || !result[n-1].Equal(v) {
+			result = append(result, v)
+		}
+	}
+	i, j := 0, 0
+	for i < len(list1) && j < len(list2) {
+		if list1[i].Compare(list2[j]) <= 0 {
+			add(list1[i])
+			i++
+		} else {
+			add(list2[j])
+			j++
+		}
+	}
+	for ; i < len(list1); i++ {
+		add(list1[i])
+	}
+	for ; j < len(list2); j++ {
+		add(list2[j])
+	}
+	return NewSuObject(result)
+}
+
+// listCopy returns a copy of the list values.
+// This avoids holding two object locks at once.
+func (ob *SuObject) listCopy() []Value {
+	if ob.Lock() {
+		defer ob.Unlock()
+	}
+	return append(ob.list[:0:0], ob.list...)
+}
+
 func (ob *SuObject) SetConcurrent() {
 	if ob.concurrent ||
 		ob.readonly { // don't need concurrent if readonly
@@ -933,6 +1083,85 @@ func (ob *SuObject) setReadOnly() bool {
 	return true
 }
 
+// Snapshot returns a readonly version of the object
+// that can be shared between threads without copying or locking.
+// A readonly object is returned as is.
+// Otherwise the snapshot shares the list and named members with the object
+// and the object copies them the next time it is modified (copy on write).
+// Nested mutable containers are snapshot recursively (deep).
+func (ob *SuObject) Snapshot() *SuObject {
+	return ob.snapshot(map[*SuObject]*SuObject{})
+}
+
+func (ob *SuObject) snapshot(inProgress map[*SuObject]*SuObject) *SuObject {
+	if snap, ok := inProgress[ob]; ok {
+		return snap // recursive reference
+	}
+	snap, nested := ob.snapshot1()
+	if snap == ob || !nested {
+		return snap
+	}
+	inProgress[ob] = snap
+	// can't hold lock while accessing other objects
+	// snap is not visible to anyone else yet so we can update it
+	for i, v := range snap.list {
+		snap.list[i] = snapshotValue(v, inProgress)
+	}
+	iter := snap.named.Iter()
+	var keys []Value
+	for k, _ := iter(); k != nil; k, _ = iter() {
+		keys = append(keys, k)
+	}
+	for _, k := range keys {
+		snap.named.Put(k, snapshotValue(snap.named.Get(k), inProgress))
+	}
+	snap.readonly = true
+	return snap
+}
+
+// snapshot1 returns a shared snapshot if all the values are immutable,
+// otherwise it returns a mutable copy for snapshot to update
+func (ob *SuObject) snapshot1() (snap *SuObject, nested bool) {
+	if ob.Lock() {
+		defer ob.Unlock()
+	}
+	if ob.readonly {
+		return ob, false
+	}
+	for _, v := range ob.list {
+		if isMutableContainer(v) {
+			return ob.slice(0), true
+		}
+	}
+	iter := ob.named.Iter()
+	for _, v := iter(); v != nil; _, v = iter() {
+		if isMutableContainer(v) {
+			return ob.slice(0), true
+		}
+	}
+	ob.shared = true
+	return &SuObject{list: ob.list[:len(ob.list):len(ob.list)],
+		named: ob.named, defval: ob.defval, readonly: true}, false
+}
+
+func isMutableContainer(v Value) bool {
+	c, ok := v.ToContainer()
+	return ok && !c.IsReadOnly()
+}
+
+func snapshotValue(v Value, inProgress map[*SuObject]*SuObject) Value {
+	c, ok := v.ToContainer()
+	if !ok || c.IsReadOnly() {
+		return v
+	}
+	if x, ok := c.(*SuObject); ok {
+		return x.snapshot(inProgress)
+	}
+	x := c.Copy()
+	x.SetReadOnly()
+	return x
+}
+
 func (ob *SuObject) IsReadOnly() bool {
 	if ob.Lock() {
 		defer ob.Unlock()
@@ -1001,8 +1230,15 @@ func (ob *SuObject) PackSize2(clock int32, stack packStack) int {
 	if ob.Lock() {
 		defer ob.Unlock()
 	}
+	return ob.packSize2(clock, stack, nil)
+}
+
+// packSize2, packSize3, and pack take extra named members
+// that are already packed, used by SuRecord for row fields
+func (ob *SuObject) packSize2(clock int32, stack packStack,
+	extra []packedMember) int {
 	ob.clock = clock
-	if ob.size() == 0 {
+	if ob.size() == 0 && len(extra) == 0 {
 		return 1 // just tag
 	}
 	ps := 1 // tag
@@ -1010,12 +1246,12 @@ func (ob *SuObject) PackSize2(clock int32, stack packStack) int {
 	for _, v := range ob.list {
 		ps += packSize(v, clock, stack)
 	}
-	ps += varint.Len(uint64(ob.named.Size()))
+	ps += varint.Len(uint64(ob.named.Size() + len(extra)))
 	iter := ob.named.Iter()
 	for k, v := iter(); k != nil; k, v = iter() {
 		ps += packSize(k, clock, stack) + packSize(v, clock, stack)
 	}
-	return ps
+	return ps + extraSize(extra)
 }
 
 func packSize(x Value, clock int32, stack packStack) int {
@@ -1030,7 +1266,11 @@ func (ob *SuObject) PackSize3() int {
 	if ob.Lock() {
 		defer ob.Unlock()
 	}
-	if ob.size() == 0 {
+	return ob.packSize3(nil)
+}
+
+func (ob *SuObject) packSize3(extra []packedMember) int {
+	if ob.size() == 0 && len(extra) == 0 {
 		return 1 // just tag
 	}
 	ps := 1 // tag
@@ -1038,11 +1278,26 @@ func (ob *SuObject) PackSize3() int {
 	for _, v := range ob.list {
 		ps += packSize3(v)
 	}
-	ps += varint.Len(uint64(ob.named.Size()))
+	ps += varint.Len(uint64(ob.named.Size() + len(extra)))
 	iter := ob.named.Iter()
 	for k, v := iter(); k != nil; k, v = iter() {
 		ps += packSize3(k) + packSize3(v)
 	}
+	return ps + extraSize(extra)
+}
+
+// packedMember is a named member whose value is already packed
+type packedMember struct {
+	key    SuStr
+	packed string
+}
+
+func extraSize(extra []packedMember) int {
+	ps := 0
+	for _, x := range extra {
+		ps += packSize3(x.key) +
+			varint.Len(uint64(len(x.packed))) + len(x.packed)
+	}
 	return ps
 }
 
@@ -1058,27 +1313,33 @@ func (ob *SuObject) Pack(clock int32, buf *pack.Encoder) {
 	if ob.Lock() {
 		defer ob.Unlock()
 	}
-	ob.pack(clock, buf, PackObject)
+	ob.pack(clock, buf, PackObject, nil)
 }
 
-func (ob *SuObject) pack(clock int32, buf *pack.Encoder, tag byte) {
+func (ob *SuObject) pack(clock int32, buf *pack.Encoder, tag byte,
+	extra []packedMember) {
 	if ob.clock != clock {
 		panic("object modified during packing")
 	}
 	buf.Put1(tag)
-	if ob.size() == 0 {
+	if ob.size() == 0 && len(extra) == 0 {
 		return
 	}
 	buf.VarUint(uint64(len(ob.list)))
 	for _, v := range ob.list {
 		packValue(v, clock, buf)
 	}
-	buf.VarUint(uint64(ob.named.Size()))
+	buf.VarUint(uint64(ob.named.Size() + len(extra)))
 	iter := ob.named.Iter()
 	for k, v := iter(); k != nil; k, v = iter() {
 		packValue(k, clock, buf)
 		packValue(v, clock, buf)
 	}
+	for _, x := range extra {
+		packValue(x.key, clock, buf)
+		buf.VarUint(uint64(len(x.packed)))
+		buf.PutStr(x.packed)
+	}
 }
 
 func packValue(x Value, clock int32, buf *pack.Encoder) {
@@ -5,6 +5,7 @@ package runtime
 
 import (
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/apmckinlay/gsuneido/util/assert"
@@ -249,3 +250,130 @@ func TestSuObjectCompare(t *testing.T) {
 		y.SetConcurrent()
 	}
 }
+
+func TestSuObjectSort(t *testing.T) {
+	assert := assert.T(t)
+	th := NewThread()
+	defer th.Close()
+	mk := func(k int, id string) Value {
+		ob := &SuObject{}
+		ob.Set(SuStr("k"), IntVal(k))
+		ob.Set(SuStr("id"), SuStr(id))
+		return ob
+	}
+	ids := func(ob *SuObject) string {
+		s := ""
+		for i := 0; i < ob.ListSize(); i++ {
+			s += ToStr(ob.ListGet(i).Get(th, SuStr("id")))
+		}
+		return s
+	}
+	list := func() *SuObject {
+		return NewSuObject([]Value{mk(2, "a"), mk(1, "b"), mk(2, "c"),
+			mk(1, "d"), mk(0, "e"), mk(2, "f")})
+	}
+	block := &SuBuiltin{Fn: func(t *Thread, args []Value) Value {
+		return SuBool(args[0].Get(t, SuStr("k")).Compare(
+			args[1].Get(t, SuStr("k"))) < 0)
+	}, BuiltinParams: BuiltinParams{ParamSpec: ParamSpec2}}
+	ob := list()
+	ob.Sort(th, block)
+	assert.This(ids(ob)).Is("ebdacf") // stable
+
+	sb := NewSortBy([]Value{SuStr("k")})
+	ob = list()
+	ob.Sort(th, sb)
+	assert.This(ids(ob)).Is("ebdacf")
+	assert.This(th.Call(sb, mk(1, "x"), mk(2, "y"))).Is(True)
+
+	ob = list()
+	ob.Sort(th, NewSortBy([]Value{SuStr("k"), SuStr("id")}))
+	ob.Reverse()
+	assert.This(ids(ob)).Is("fcadbe")
+
+	// missing members are ""
+	ob = NewSuObject([]Value{mk(1, "a"), &SuObject{}})
+	ob.Sort(th, NewSortBy([]Value{SuStr("id")}))
+	assert.This(ob.ListGet(0).Get(th, SuStr("id"))).Is(nil)
+
+	assert.This(func() { NewSortBy(nil) }).Panics("usage")
+}
+
+func TestSuObjectCompound(t *testing.T) {
+	assert := assert.T(t)
+	ob := &SuObject{}
+	assert.This(ob.GetInit(nil, SuStr("a"), One)).Is(One)
+	assert.This(ob.GetInit(nil, SuStr("a"), Zero)).Is(One)
+	assert.This(ob.Increment(nil, SuStr("a"), One)).Is(SuInt(2))
+	assert.This(ob.Increment(nil, SuStr("b"), SuInt(5))).Is(SuInt(5))
+	assert.That(ob.AddUnique(SuStr("x")))
+	assert.That(!ob.AddUnique(SuStr("x")))
+	assert.This(ob.String()).Is("#('x', a: 2, b: 5)")
+
+	ob = &SuObject{}
+	ob.SetConcurrent()
+	const nthreads = 8
+	const n = 1000
+	var wg sync.WaitGroup
+	for i := 0; i < nthreads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				ob.Increment(nil, SuStr("count"), One)
+				ob.AddUnique(IntVal(j % 10))
+				ob.GetInit(nil, SuStr("list"), &SuObject{})
+			}
+		}()
+	}
+	wg.Wait()
+	assert.This(ob.Get(nil, SuStr("count"))).Is(IntVal(nthreads * n))
+	assert.This(ob.ListSize()).Is(10)
+	assert.That(ob.Get(nil, SuStr("list")).(*SuObject).IsConcurrent() == True)
+}
+
+func TestSuObjectSetOps(t *testing.T) {
+	ob := func(args ...int) *SuObject {
+		list := make([]Value, len(args))
+		for i, n := range args {
+			list[i] = SuInt(n)
+		}
+		return NewSuObject(list)
+	}
+	test := func(result, expected *SuObject) {
+		t.Helper()
+		assert.T(t).This(result).Is(expected)
+	}
+	test(ob(1, 2, 3, 2).Union(ob(4, 3, 5, 4)), ob(1, 2, 3, 4, 5))
+	test(ob(1, 2, 3, 2).Intersect(ob(2, 4)), ob(2, 2))
+	test(ob(1, 2, 3, 2).Difference(ob(2, 4)), ob(1, 3))
+	test(ob(1, 3, 3, 5).MergeUnion(ob(2, 3, 6)), ob(1, 2, 3, 5, 6))
+	test(ob().Union(ob()), ob())
+	test(ob().MergeUnion(ob(1)), ob(1))
+}
+
+func TestSuObjectSnapshot(t *testing.T) {
+	assert := assert.T(t).This
+	ob := SuObjectOf(One, SuInt(2))
+	ob.Set(SuStr("a"), SuInt(3))
+	snap := ob.Snapshot()
+	assert(snap.IsReadOnly()).Is(true)
+	assert(snap).Is(ob)
+	assert(&snap.list[0]).Is(&ob.list[0]) // shared
+	ob.Add(SuInt(4))
+	ob.Set(SuStr("b"), SuInt(5))
+	ob.Put(nil, Zero, SuInt(6))
+	assert(snap.String()).Is("#(1, 2, a: 3)")
+	assert(ob.String()).Is("#(6, 2, 4, a: 3, b: 5)")
+	assert(snap.Snapshot() == snap).Is(true)
+
+	// nested
+	ob = SuObjectOf(SuObjectOf(One))
+	ob.Add(ob) // recursive
+	snap = ob.Snapshot()
+	nested := snap.ListGet(0).(*SuObject)
+	assert(nested.IsReadOnly()).Is(true)
+	assert(snap.ListGet(1) == snap).Is(true)
+	ob.ListGet(0).(*SuObject).Add(SuInt(2))
+	assert(nested.String()).Is("#(1)")
+}
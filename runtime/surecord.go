@@ -5,8 +5,11 @@ package runtime
 
 import (
 	"fmt"
+	"log"
 	"strings"
+	"sync/atomic"
 
+	"github.com/apmckinlay/gsuneido/options"
 	"github.com/apmckinlay/gsuneido/runtime/trace"
 	"github.com/apmckinlay/gsuneido/runtime/types"
 	"github.com/apmckinlay/gsuneido/util/assert"
@@ -20,8 +23,8 @@ import (
 type SuRecord struct {
 	ob SuObject
 	CantConvert
-	// observers is from record.Observer(fn)
-	observers ValueList
+	// observers is from record.Observer(fn), ordered by priority
+	observers []observer
 	// invalidated accumulates keys needing observers called
 	invalidated str.Queue
 	// invalid is the fields that need to be recalculated
@@ -30,13 +33,26 @@ type SuRecord struct {
 	dependents map[string][]string
 	// activeObservers is used to prevent infinite recursion
 	activeObservers ActiveObserverList
+	// suspended is the nesting level of WithoutObservers.
+	// While it is non-zero, observers are deferred (queued in invalidated)
+	suspended int
 	// attachedRules is from record.AttachRule(key,fn)
 	attachedRules map[string]Value
+	// asyncRules is the attached rules from record.AttachAsyncRule(key,fn)
+	asyncRules map[string]bool
+	// pending is the async rules that are being calculated.
+	// The value is used to ignore superseded results.
+	pending map[string]int
+	// asyncGen is incremented for each async rule call
+	asyncGen int
 
 	// row is used when it is from the database
 	row Row
 	// header is the Header for row
 	hdr *Header
+	// orig is the row as originally read, for GetOriginal and ModifiedFields.
+	// Unlike row, it is kept when the record is unpacked or deleted from.
+	orig Row
 	// tran is the database transaction used to read the record
 	tran *SuTran
 	// recoff is the record offset in the database
@@ -58,7 +74,6 @@ const (
 )
 
 //go:generate genny -in ../genny/list/list.go -out alist.go -pkg runtime gen "V=activeObserver"
-//go:generate genny -in ../genny/list/list.go -out vlist.go -pkg runtime gen "V=Value"
 
 func NewSuRecord() *SuRecord {
 	return &SuRecord{ob: SuObject{defval: EmptyStr}}
@@ -72,7 +87,7 @@ func SuRecordFromObject(ob *SuObject) *SuRecord {
 }
 
 func SuRecordFromRow(row Row, hdr *Header, table string, tran *SuTran) *SuRecord {
-	rec := SuRecord{row: row, hdr: hdr, tran: tran,
+	rec := SuRecord{row: row, orig: row, hdr: hdr, tran: tran,
 		ob: SuObject{defval: EmptyStr}, userow: true, status: OLD}
 	if table != "" {
 		rec.table = table
@@ -119,6 +134,7 @@ func (r *SuRecord) slice(n int) *SuRecord {
 	return &SuRecord{
 		ob:         *r.ob.slice(n),
 		row:        r.row,
+		orig:       r.orig,
 		hdr:        r.hdr,
 		userow:     r.userow,
 		status:     r.status,
@@ -174,8 +190,13 @@ func (r *SuRecord) Compare(other Value) int {
 	return r.ToObject().Compare(other)
 }
 
+// Equal compares the members (like SuObject) so it is symmetric
+// i.e. record.Equal(object) is the same as object.Equal(record).
+// Row based records compare as if unpacked (empty fields are absent).
+// Default values and rules are not applied.
 func (r *SuRecord) Equal(other interface{}) bool {
-	return r.ToObject().Equal(other) //FIXME not symmetrical with SuObject.Equal
+	val, ok := other.(Value)
+	return ok && deepEqual(r, val)
 }
 
 func (r *SuRecord) Hash() uint32 {
@@ -226,7 +247,7 @@ func (r *SuRecord) toObject() *SuObject {
 		for ri, rf := range r.hdr.Fields {
 			for fi, f := range rf {
 				if f != "-" && !strings.HasSuffix(f, "_deps") {
-					key := SuStr(f)
+					key := Intern(f)
 					if !r.ob.hasKey(key) {
 						if val := r.row[ri].GetRaw(fi); val != "" {
 							r.ob.set(key, Unpack(val))
@@ -245,6 +266,10 @@ func (r *SuRecord) Add(val Value) {
 	r.ob.Add(val)
 }
 
+func (r *SuRecord) AddUnique(val Value) bool {
+	return r.ob.AddUnique(val)
+}
+
 func (r *SuRecord) Insert(at int, val Value) {
 	r.ob.Insert(at, val)
 }
@@ -357,7 +382,7 @@ func (r *SuRecord) rowSize() int {
 	for ri, rf := range r.hdr.Fields {
 		for fi, f := range rf {
 			if f != "-" && !strings.HasSuffix(f, "_deps") {
-				key := SuStr(f)
+				key := Intern(f)
 				if !r.ob.hasKey(key) {
 					if val := r.row[ri].GetRaw(fi); val != "" {
 						n++
@@ -373,8 +398,95 @@ func (r *SuRecord) ArgsIter() func() (Value, Value) {
 	return r.ToObject().ArgsIter()
 }
 
+// Iter2 iterates through list and named elements.
+// If the record has a row, its fields are unpacked as they are iterated
+// rather than all at the start (like ToObject)
 func (r *SuRecord) Iter2(list bool, named bool) func() (Value, Value) {
-	return r.ToObject().Iter2(list, named)
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	return r.iter2(list, named)
+}
+
+func (r *SuRecord) iter2(list bool, named bool) func() (Value, Value) {
+	if !named || !r.userow {
+		return r.ob.iter2(list, named)
+	}
+	it := &recIter{r: r, list: list, seen: make(map[string]bool)}
+	return it.next
+}
+
+// recIter iterates the list, then the row fields, and then the named members.
+// Row fields are cached in ob as they are unpacked (like getFromRow)
+// so modifications are only checked for the named members.
+type recIter struct {
+	r    *SuRecord
+	list bool
+	// i is the next list index
+	i int
+	// ri and fi are the next row field
+	ri, fi int
+	// seen is the row fields that have been returned
+	seen    map[string]bool
+	named   func() (Value, Value)
+	version int32
+}
+
+func (it *recIter) next() (Value, Value) {
+	r := it.r
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	if it.list && it.i < len(r.ob.list) {
+		it.i++
+		return IntVal(it.i - 1), r.ob.list[it.i-1]
+	}
+	for it.named == nil {
+		if it.ri >= len(r.hdr.Fields) {
+			if !r.ob.readonly {
+				r.userow = false // everything is now in ob
+			}
+			it.version = r.ob.version
+			it.named = r.ob.named.Iter()
+			break
+		}
+		if it.fi >= len(r.hdr.Fields[it.ri]) {
+			it.ri++
+			it.fi = 0
+			continue
+		}
+		ri, fi := it.ri, it.fi
+		it.fi++
+		f := r.hdr.Fields[ri][fi]
+		if f == "-" || strings.HasSuffix(f, "_deps") || it.seen[f] {
+			continue
+		}
+		key := Intern(f)
+		if x := r.ob.namedGet(key); x != nil {
+			it.seen[f] = true
+			return key, x
+		}
+		if r.userow {
+			if raw := r.row[ri].GetRaw(fi); raw != "" {
+				val := Unpack(raw)
+				if !r.ob.readonly {
+					r.ob.set(key, val) // cache unpacked value
+				}
+				it.seen[f] = true
+				return key, val
+			}
+		}
+	}
+	r.ob.modificationCheck(it.version)
+	for {
+		key, val := it.named()
+		if key == nil {
+			return nil, nil
+		}
+		if s, ok := key.ToStr(); !ok || !it.seen[s] {
+			return key, val
+		}
+	}
 }
 
 func (r *SuRecord) Slice(n int) Container {
@@ -385,11 +497,10 @@ func (r *SuRecord) Slice(n int) Container {
 }
 
 func (r *SuRecord) Iter() Iter {
-	if r.ob.Lock() {
-		defer r.ob.Unlock()
+	if r.Lock() {
+		defer r.Unlock()
 	}
-	r.toObject()
-	return &obIter{ob: &r.ob, iter: r.ob.iter2(true, true),
+	return &obIter{ob: r, list: true, named: true, iter: r.iter2(true, true),
 		result: func(k, v Value) Value { return v }}
 }
 
@@ -472,6 +583,9 @@ func (r *SuRecord) invalidate(key string) {
 	}
 	r.trace("invalidate", key)
 	r.invalid[key] = true
+	if _, ok := r.pending[key]; ok {
+		r.pending[key] = stalePending // recalculate on the next Get
+	}
 	r.invalidateDependents(key)
 }
 
@@ -479,32 +593,115 @@ func (r *SuRecord) PreSet(key, val Value) {
 	r.ob.Set(key, val)
 }
 
-func (r *SuRecord) Observer(ofn Value) {
+type observer struct {
+	fn       Value
+	priority int
+}
+
+// Observer adds an observer.
+// Observers are called in order of priority (highest first)
+// and then in the order they were added.
+func (r *SuRecord) Observer(ofn Value, priority int) {
 	if r.Lock() {
 		defer r.Unlock()
 	}
-	r.observers.Push(ofn)
+	i := 0
+	for i < len(r.observers) && r.observers[i].priority >= priority {
+		i++
+	}
+	// copy on write so callObservers2 isn't affected
+	obs := make([]observer, 0, len(r.observers)+1)
+	obs = append(obs, r.observers[:i]...)
+	obs = append(obs, observer{fn: ofn, priority: priority})
+	r.observers = append(obs, r.observers[i:]...)
 }
 
 func (r *SuRecord) RemoveObserver(ofn Value) bool {
 	if r.Lock() {
 		defer r.Unlock()
 	}
-	return r.observers.Remove(ofn)
+	for i, o := range r.observers {
+		if o.fn.Equal(ofn) {
+			// copy on write so callObservers2 isn't affected
+			obs := make([]observer, 0, len(r.observers)-1)
+			obs = append(obs, r.observers[:i]...)
+			r.observers = append(obs, r.observers[i+1:]...)
+			return true
+		}
+	}
+	return false
 }
 
+// callObservers calls the observers for key
+// and then for any keys that were invalidated,
+// in the order they were invalidated (i.e. depth first through dependents).
+// Each key is only called once per callObservers,
+// even if it is invalidated again by an observer.
+// Changes made by observers call their observers immediately (nested).
 func (r *SuRecord) callObservers(t *Thread, key string) {
+	if r.suspended > 0 {
+		if !r.invalidated.Has(key) {
+			r.invalidated.Add(key)
+		}
+		return
+	}
 	r.callObservers2(t, key)
+	done := map[string]bool{key: true}
 	for !r.invalidated.Empty() {
-		if k := r.invalidated.Take(); k != key {
+		if k := r.invalidated.Take(); !done[k] {
+			done[k] = true
+			r.callObservers2(t, k)
+		}
+	}
+}
+
+// WithoutObservers calls block with observers suspended.
+// When it returns, the observers are called once
+// for each field that was changed or invalidated.
+// Calls may be nested, observers are only called by the outermost.
+func (r *SuRecord) WithoutObservers(t *Thread, block Value) Value {
+	r.suspend(1)
+	ok := false
+	defer func() {
+		r.suspend(-1)
+		if ok {
+			r.resumeObservers(t)
+		}
+	}()
+	result := t.CallThis(block, r)
+	ok = true
+	return result
+}
+
+func (r *SuRecord) suspend(n int) {
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	r.suspended += n
+}
+
+// resumeObservers calls the observers for the keys that were queued
+// while observers were suspended.
+// Duplicate keys (e.g. from invalidate) are only called once.
+func (r *SuRecord) resumeObservers(t *Thread) {
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	if r.suspended > 0 {
+		return
+	}
+	done := make(map[string]bool)
+	for !r.invalidated.Empty() {
+		if k := r.invalidated.Take(); !done[k] {
+			done[k] = true
 			r.callObservers2(t, k)
 		}
 	}
 }
 
 func (r *SuRecord) callObservers2(t *Thread, key string) {
-	for _, x := range r.observers.list {
-		ofn := x.(Value)
+	for _, o := range r.observers {
+		ofn := o.fn
 		if !r.activeObservers.Has(activeObserver{ofn, key}) {
 			func(ofn Value, key string) {
 				r.activeObservers.Push(activeObserver{ofn, key})
@@ -521,7 +718,7 @@ func (r *SuRecord) callObservers2(t *Thread, key string) {
 }
 
 var argSpecMember = &ArgSpec{Nargs: 1,
-	Spec: []byte{0}, Names: []Value{SuStr("member")}}
+	Spec: []uint16{0}, Names: []Value{SuStr("member")}}
 
 type activeObserver struct {
 	obs Value
@@ -551,6 +748,34 @@ func (r *SuRecord) get(t *Thread, key Value) Value {
 
 // GetIfPresent is the same as Get
 // except it returns nil instead of defval for missing members
+// GetInit returns the value of key if present (including from the row),
+// otherwise it sets key to val and returns val
+func (r *SuRecord) GetInit(t *Thread, key, val Value) Value {
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	if x := r.getIfPresent(t, key); x != nil {
+		return x
+	}
+	r.put(t, key, val)
+	return val
+}
+
+// Increment adds by to the value of key and returns the new value.
+// Unlike GetPut (+=) a missing member is treated as 0.
+func (r *SuRecord) Increment(t *Thread, key, by Value) Value {
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	x := r.get(t, key)
+	if x == nil {
+		x = Zero
+	}
+	x = OpAdd(x, by)
+	r.put(t, key, x)
+	return x
+}
+
 func (r *SuRecord) GetIfPresent(t *Thread, keyval Value) Value {
 	if r.Lock() {
 		defer r.Unlock()
@@ -586,7 +811,7 @@ func (r *SuRecord) getFromRow(key string) Value {
 	if raw := r.row.GetRaw(r.hdr, key); raw != "" {
 		val := Unpack(raw)
 		if !r.ob.readonly {
-			r.ob.set(SuStr(key), val) // cache unpacked value
+			r.ob.set(Intern(key), val) // cache unpacked value
 		}
 		return val
 	}
@@ -596,7 +821,7 @@ func (r *SuRecord) getFromRow(key string) Value {
 // deps is used by ToRecord to create the dependencies for a field
 // but without unpacking if it's in the row
 func (r *SuRecord) deps(t *Thread, key string) {
-	result := r.ob.getIfPresent(SuStr(key))
+	result := r.ob.getIfPresent(Intern(key))
 	if t != nil {
 		if ar := t.rules.top(); ar.rec == r { // identity (not Equal)
 			r.addDependent(ar.key, key)
@@ -619,7 +844,7 @@ func (r *SuRecord) deps(t *Thread, key string) {
 // using the already packed value from the row when possible.
 // It does not add dependencies or handle special fields (e.g. _lower!)
 func (r *SuRecord) getPacked(t *Thread, key string) string {
-	result := r.ob.getIfPresent(SuStr(key)) // NOTE: ob.getIfPresent
+	result := r.ob.getIfPresent(Intern(key)) // NOTE: ob.getIfPresent
 	packed := ""
 	if result == nil && r.row != nil { // even if !r.userow
 		if s := r.row.GetRaw(r.hdr, key); s != "" {
@@ -675,10 +900,21 @@ func (r *SuRecord) callRule(t *Thread, key string) Value {
 	r.ensureDeps()
 	delete(r.invalid, key)
 	if rule := r.getRule(t, key); rule != nil && !t.rules.has(r, key) {
+		if r.asyncRules[key] && !options.ThreadDisabled {
+			if gen, ok := r.pending[key]; !ok || gen == stalePending {
+				r.callAsyncRule(rule, key)
+			}
+			return nil // use the current value (if any) until it's done
+		}
 		r.trace("call rule", key)
-		val := r.catchRule(t, rule, key)
+		var val Value
+		if m := r.ruleMemo(key); m != nil {
+			val = r.callMemoRule(t, rule, key, m)
+		} else {
+			val = r.catchRule(t, rule, key)
+		}
 		if val != nil && !r.ob.readonly {
-			r.ob.set(SuStr(key), val)
+			r.ob.set(Intern(key), val)
 		}
 		return val
 	}
@@ -743,6 +979,54 @@ func (ar *activeRules) has(r *SuRecord, key string) bool {
 	return false
 }
 
+// Validate gets the field__valid rule values for the given fields
+// (or the header columns if fields is nil, or the named members if no header)
+// and returns an object of field: message for the ones that are not valid.
+// A rule value of true or "" is valid, false is "invalid".
+// The rule values are cached like any other rule,
+// so they are only recalculated when their dependencies are invalidated.
+func (r *SuRecord) Validate(t *Thread, fields []string) *SuObject {
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	if fields == nil {
+		fields = r.validFields()
+	}
+	result := &SuObject{}
+	for _, f := range fields {
+		val := r.getIfPresent(t, SuStr(f+"__valid"))
+		if val == nil || val == True || val == EmptyStr {
+			continue
+		}
+		msg := "invalid"
+		if val != False {
+			msg = ToStrOrString(val)
+		}
+		result.Set(SuStr(f), SuStr(msg))
+	}
+	return result
+}
+
+func (r *SuRecord) validFields() []string {
+	var fields []string
+	if r.hdr != nil {
+		for _, col := range r.hdr.Columns {
+			if col != "-" && !strings.HasSuffix(col, "_deps") &&
+				!strings.HasSuffix(col, "__valid") {
+				fields = append(fields, col)
+			}
+		}
+		return fields
+	}
+	iter := r.ob.named.Iter()
+	for k, _ := iter(); k != nil; k, _ = iter() {
+		if s, ok := k.ToStr(); ok && !strings.HasSuffix(s, "__valid") {
+			fields = append(fields, s)
+		}
+	}
+	return fields
+}
+
 func (r *SuRecord) getRule(t *Thread, key string) Value {
 	if rule, ok := r.attachedRules[key]; ok {
 		assert.That(rule != nil)
@@ -750,11 +1034,27 @@ func (r *SuRecord) getRule(t *Thread, key string) Value {
 	}
 	//TODO key should be identifier but we have foobar?__protect
 	if r.ob.defval != nil && t != nil && key != "" {
+		if r.hdr != nil {
+			return r.hdr.rules.get(t, key)
+		}
 		return Global.FindName(t, "Rule_"+key)
 	}
 	return nil
 }
 
+// ruleMemo returns the memo for a rule if it is memoized, otherwise nil.
+// Only rules from globals (not attached) for records from queries
+// are memoized.
+func (r *SuRecord) ruleMemo(key string) *ruleMemo {
+	if r.hdr == nil {
+		return nil
+	}
+	if _, ok := r.attachedRules[key]; ok {
+		return nil
+	}
+	return r.hdr.rules.memo(key)
+}
+
 func (r *SuRecord) AttachRule(key, callable Value) {
 	if r.Lock() {
 		defer r.Unlock()
@@ -765,6 +1065,84 @@ func (r *SuRecord) AttachRule(key, callable Value) {
 	r.attachedRules[AsStr(key)] = callable
 }
 
+// AttachAsyncRule attaches a rule that is calculated in another thread.
+// Until the value arrives, Get returns the previous value (or "")
+// and Pending?(key) is true.
+// When the value arrives, it is saved and the observers are called
+// (from the other thread).
+func (r *SuRecord) AttachAsyncRule(key, callable Value) {
+	// the record must be concurrent before the rule is called
+	// to keep the locking consistent
+	r.SetConcurrent()
+	callable.SetConcurrent()
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	k := AsStr(key)
+	if r.attachedRules == nil {
+		r.attachedRules = make(map[string]Value)
+	}
+	r.attachedRules[k] = callable
+	if r.asyncRules == nil {
+		r.asyncRules = make(map[string]bool)
+	}
+	r.asyncRules[k] = true
+}
+
+// stalePending marks a pending async rule whose inputs were invalidated
+// while it was being calculated
+const stalePending = 0
+
+// callAsyncRule starts a thread to call the rule.
+// It is not called again while the rule is pending (see callRule)
+// unless the rule has been invalidated.
+// It is called with the record locked.
+func (r *SuRecord) callAsyncRule(rule Value, key string) {
+	r.trace("call async rule", key)
+	if r.pending == nil {
+		r.pending = make(map[string]int)
+	}
+	r.asyncGen++
+	gen := r.asyncGen
+	r.pending[key] = gen
+	go func() {
+		t := NewThread()
+		defer t.Close()
+		r.Lock()
+		defer r.Unlock()
+		val := func() (val Value) {
+			defer func() {
+				if e := recover(); e != nil {
+					log.Println("ERROR in async rule:", e)
+				}
+			}()
+			return r.catchRule(t, rule, key)
+		}()
+		if g := r.pending[key]; g != gen {
+			if g == stalePending {
+				delete(r.pending, key) // still invalid so it will be recalculated
+			}
+			return // stale or superseded by a later call
+		}
+		delete(r.pending, key)
+		if val == nil || r.ob.readonly {
+			return
+		}
+		r.ob.set(Intern(key), val)
+		r.invalidateDependents(key)
+		r.callObservers(t, key)
+	}()
+}
+
+// IsPending returns whether an async rule for the key is being calculated
+func (r *SuRecord) IsPending(key string) bool {
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	_, ok := r.pending[key]
+	return ok
+}
+
 func (r *SuRecord) GetDeps(key string) Value {
 	if r.Lock() {
 		defer r.Unlock()
@@ -875,25 +1253,68 @@ func (*SuRecord) Lookup(t *Thread, method string) Callable {
 }
 
 // Packable ---------------------------------------------------------
-
-//TODO use row
+// Fields that are only in the row are packed directly from the row
+// without unpacking them.
 
 var _ Packable = (*SuRecord)(nil)
 
 func (r *SuRecord) PackSize(clock *int32) int {
-	return r.ToObject().PackSize(clock)
+	*clock = atomic.AddInt32(&packClock, 1)
+	return r.PackSize2(*clock, newPackStack())
 }
 
 func (r *SuRecord) PackSize2(clock int32, stack packStack) int {
-	return r.ToObject().PackSize2(clock, stack)
+	// must check stack before locking to avoid recursive deadlock
+	stack.push(r)
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	return r.ob.packSize2(clock, stack, r.rowOnly())
 }
 
 func (r *SuRecord) PackSize3() int {
-	return r.ToObject().PackSize3()
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	return r.ob.packSize3(r.rowOnly())
 }
 
 func (r *SuRecord) Pack(clock int32, buf *pack.Encoder) {
-	r.ToObject().pack(clock, buf, PackRecord)
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	r.ob.pack(clock, buf, PackRecord, r.rowOnly())
+}
+
+// rowOnly returns the fields that are in the row but not (yet) in ob,
+// with their packed values (the same fields as toObject would add)
+func (r *SuRecord) rowOnly() []packedMember {
+	if !r.userow {
+		return nil
+	}
+	var fields []packedMember
+	for ri, rf := range r.hdr.Fields {
+		for fi, f := range rf {
+			if f != "-" && !strings.HasSuffix(f, "_deps") {
+				key := SuStr(f)
+				if !r.ob.hasKey(key) && !hasPacked(fields, key) {
+					if val := r.row[ri].GetRaw(fi); val != "" {
+						fields = append(fields, packedMember{key: key, packed: val})
+					}
+				}
+			}
+		}
+	}
+	return fields
+}
+
+func hasPacked(fields []packedMember, key SuStr) bool {
+	for _, f := range fields {
+		if f.key == key {
+			return true
+		}
+	}
+	return false
 }
 
 func UnpackRecord(s string) *SuRecord {
@@ -927,6 +1348,84 @@ func (r *SuRecord) DbUpdate(t *Thread, ob Value) {
 	r.recoff = r.tran.Update(r.table, r.recoff, rec) // ??? ok while locked ???
 }
 
+// GetOriginal returns the value of a field as it was read from the database,
+// ignoring any changes. It returns "" if the record is not from the database.
+func (r *SuRecord) GetOriginal(key string) Value {
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	if r.orig != nil {
+		if raw := r.orig.GetRaw(r.hdr, key); raw != "" {
+			return Unpack(raw)
+		}
+	}
+	return EmptyStr
+}
+
+// IsModified returns whether any field has changed
+// since the record was read from the database.
+func (r *SuRecord) IsModified() bool {
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	modified := false
+	r.modified(func(string) bool {
+		modified = true
+		return false
+	})
+	return modified
+}
+
+// ModifiedFields returns the fields that have changed
+// since the record was read from the database.
+// If the record is not from the database, it returns all the named members.
+func (r *SuRecord) ModifiedFields() []string {
+	if r.Lock() {
+		defer r.Unlock()
+	}
+	var fields []string
+	r.modified(func(f string) bool {
+		fields = append(fields, f)
+		return true
+	})
+	return fields
+}
+
+// modified calls fn for each modified field until fn returns false.
+// For records from the database, only the stored fields (in hdr.Fields)
+// are checked, by comparing the packed current value to the original.
+func (r *SuRecord) modified(fn func(string) bool) {
+	if r.orig == nil {
+		iter := r.ob.named.Iter()
+		for k, _ := iter(); k != nil; k, _ = iter() {
+			if s, ok := k.ToStr(); ok && !fn(s) {
+				return
+			}
+		}
+		return
+	}
+	seen := make(map[string]bool)
+	for ri, flds := range r.hdr.Fields {
+		for fi, f := range flds {
+			if f == "-" || strings.HasSuffix(f, "_deps") || seen[f] {
+				continue
+			}
+			seen[f] = true
+			cur := r.ob.getIfPresent(Intern(f))
+			if cur == nil {
+				if r.userow {
+					continue // not changed
+				}
+				cur = EmptyStr // deleted
+			}
+			p, ok := cur.(Packable)
+			if (!ok || Pack(p) != r.orig[ri].GetRaw(fi)) && !fn(f) {
+				return
+			}
+		}
+	}
+}
+
 func (r *SuRecord) ckModify(op string) {
 	if r.tran == nil {
 		panic("record." + op + ": no Transaction")
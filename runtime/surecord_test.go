@@ -4,6 +4,7 @@
 package runtime
 
 import (
+	"sync/atomic"
 	"testing"
 
 	"github.com/apmckinlay/gsuneido/runtime/types"
@@ -33,3 +34,279 @@ func TestSuRecord_ReadonlyUnpack(t *testing.T) {
 	surec.SetReadOnly()
 	assert.T(t).This(surec.Get(nil, SuStr("num"))).Is(SuInt(123))
 }
+
+func TestSuRecord_PackRow(t *testing.T) {
+	b := RecordBuilder{}
+	b.Add(SuInt(123))
+	b.Add(SuStr("foobar"))
+	b.Add(SuStr(""))
+	rec := b.Build()
+	row := Row{DbRec{Record: rec}}
+	hdr := NewHeader([][]string{{"num", "str", "empty"}},
+		[]string{"num", "str", "empty"})
+
+	surec := SuRecordFromRow(row, hdr, "", nil)
+	surec.Set(SuStr("str"), SuStr("changed"))
+	surec.Add(SuInt(456))
+	packed := Pack(surec)
+	assert.T(t).That(surec.userow) // didn't unpack
+
+	x := Unpack(packed).(*SuRecord)
+	assert.T(t).This(x.Get(nil, SuStr("num"))).Is(SuInt(123))
+	assert.T(t).This(x.Get(nil, SuStr("str"))).Is(SuStr("changed"))
+	assert.T(t).This(x.ListGet(0)).Is(SuInt(456))
+	assert.T(t).This(x.ToObject().Size()).Is(3)
+}
+
+func TestSuRecord_IterRow(t *testing.T) {
+	b := RecordBuilder{}
+	b.Add(SuInt(123))
+	b.Add(SuStr("foobar"))
+	b.Add(SuStr(""))
+	rec := b.Build()
+	row := Row{DbRec{Record: rec}}
+	hdr := NewHeader([][]string{{"num", "str", "empty"}},
+		[]string{"num", "str", "empty"})
+
+	surec := SuRecordFromRow(row, hdr, "", nil)
+	surec.Set(SuStr("str"), SuStr("changed"))
+	surec.Set(SuStr("extra"), SuInt(789))
+	surec.Add(SuInt(456))
+	iter := surec.Iter2(true, true)
+	k, v := iter()
+	assert.T(t).This(k).Is(SuInt(0))
+	assert.T(t).This(v).Is(SuInt(456))
+	k, v = iter()
+	assert.T(t).This(k).Is(SuStr("num"))
+	assert.T(t).This(v).Is(SuInt(123))
+	assert.T(t).That(surec.userow) // not unpacked yet
+	k, v = iter()
+	assert.T(t).This(k).Is(SuStr("str"))
+	assert.T(t).This(v).Is(SuStr("changed"))
+	k, v = iter()
+	assert.T(t).This(k).Is(SuStr("extra"))
+	assert.T(t).This(v).Is(SuInt(789))
+	assert.T(t).That(!surec.userow)
+	k, _ = iter()
+	assert.T(t).This(k).Is(nil)
+}
+
+func TestSuRecord_WithoutObservers(t *testing.T) {
+	th := &Thread{}
+	r := NewSuRecord()
+	var calls []string
+	r.Observer(&SuBuiltin1{Fn: func(m Value) Value {
+		calls = append(calls, ToStr(m))
+		return nil
+	}, BuiltinParams: BuiltinParams{ParamSpec: ParamSpec{Nparams: 1, Flags: []Flag{0},
+		Names: []string{"member"}}}}, 0)
+	r.Put(th, SuStr("a"), One)
+	assert.T(t).This(calls).Is([]string{"a"})
+	calls = nil
+	r.WithoutObservers(th, &SuBuiltin0{Fn: func() Value {
+		r.Put(th, SuStr("a"), SuInt(2))
+		r.Put(th, SuStr("b"), SuInt(2))
+		r.Put(th, SuStr("a"), SuInt(3))
+		assert.T(t).This(len(calls)).Is(0)
+		return nil
+	}})
+	assert.T(t).This(calls).Is([]string{"a", "b"})
+}
+
+func TestSuRecord_Modified(t *testing.T) {
+	b := RecordBuilder{}
+	b.Add(SuInt(123))
+	b.Add(SuStr("foobar"))
+	b.Add(SuStr(""))
+	rec := b.Build()
+	row := Row{DbRec{Record: rec}}
+	hdr := NewHeader([][]string{{"num", "str", "empty"}},
+		[]string{"num", "str", "empty"})
+
+	th := &Thread{}
+	surec := SuRecordFromRow(row, hdr, "", nil)
+	assert.T(t).That(!surec.IsModified())
+	surec.Get(th, SuStr("num")) // unpack and cache
+	assert.T(t).That(!surec.IsModified())
+	surec.Put(th, SuStr("str"), SuStr("changed"))
+	surec.Put(th, SuStr("empty"), SuStr(""))
+	surec.Put(th, SuStr("other"), SuInt(456))
+	assert.T(t).That(surec.IsModified())
+	assert.T(t).This(surec.ModifiedFields()).Is([]string{"str"})
+	assert.T(t).This(surec.GetOriginal("str")).Is(SuStr("foobar"))
+	assert.T(t).This(surec.GetOriginal("empty")).Is(EmptyStr)
+
+	surec.Delete(th, SuStr("num"))
+	assert.T(t).This(surec.ModifiedFields()).Is([]string{"num", "str"})
+	assert.T(t).This(surec.GetOriginal("num")).Is(SuInt(123))
+
+	surec = NewSuRecord()
+	surec.Put(th, SuStr("str"), SuStr("new"))
+	assert.T(t).This(surec.ModifiedFields()).Is([]string{"str"})
+}
+
+func TestSuRecord_Validate(t *testing.T) {
+	th := &Thread{}
+	r := NewSuRecord()
+	calls := 0
+	r.AttachRule(SuStr("a__valid"), &SuBuiltin0{Fn: func() Value {
+		calls++
+		if r.Get(th, SuStr("a")) == EmptyStr {
+			return SuStr("required")
+		}
+		return True
+	}})
+	r.AttachRule(SuStr("b__valid"), &SuBuiltin0{Fn: func() Value {
+		return EmptyStr
+	}})
+	fields := []string{"a", "b"}
+	assert.T(t).This(r.Validate(th, fields).String()).Is(`#(a: "required")`)
+	assert.T(t).This(r.Validate(th, fields).String()).Is(`#(a: "required")`)
+	assert.T(t).This(calls).Is(1) // cached
+	r.Put(th, SuStr("a"), One)
+	assert.T(t).This(r.Validate(th, fields).Size()).Is(0)
+	assert.T(t).This(calls).Is(2)
+}
+
+func TestSuRecord_AsyncRule(t *testing.T) {
+	th := &Thread{}
+	r := NewSuRecord()
+	r.Put(th, SuStr("a"), SuInt(2))
+	start := make(chan bool)
+	done := make(chan string, 1)
+	r.AttachAsyncRule(SuStr("b"), &SuBuiltin0{Fn: func() Value {
+		<-start
+		return OpMul(r.Get(th, SuStr("a")), SuInt(10))
+	}})
+	r.Observer(&SuBuiltin1{Fn: func(m Value) Value {
+		done <- ToStr(m)
+		return nil
+	}, BuiltinParams: BuiltinParams{ParamSpec: ParamSpec{Nparams: 1,
+		Flags: []Flag{0}, Names: []string{"member"}}}}, 0)
+	assert.T(t).This(r.Get(th, SuStr("b"))).Is(EmptyStr)
+	assert.T(t).That(r.IsPending("b"))
+	start <- true
+	assert.T(t).This(<-done).Is("b")
+	assert.T(t).That(!r.IsPending("b"))
+	assert.T(t).This(r.Get(th, SuStr("b"))).Is(SuInt(20))
+}
+
+func TestSuRecord_AsyncRulePending(t *testing.T) {
+	th := &Thread{}
+	r := NewSuRecord()
+	r.Put(th, SuStr("a"), SuInt(2))
+	var calls int32
+	done := make(chan string, 1)
+	r.AttachAsyncRule(SuStr("b"), &SuBuiltin0{Fn: func() Value {
+		atomic.AddInt32(&calls, 1)
+		return OpMul(r.Get(th, SuStr("a")), SuInt(10))
+	}})
+	r.Observer(&SuBuiltin1{Fn: func(m Value) Value {
+		done <- ToStr(m)
+		return nil
+	}, BuiltinParams: BuiltinParams{ParamSpec: ParamSpec{Nparams: 1,
+		Flags: []Flag{0}, Names: []string{"member"}}}}, 0)
+	r.pending = map[string]int{"b": 99} // as if already started
+	for i := 0; i < 5; i++ {
+		assert.T(t).This(r.Get(th, SuStr("b"))).Is(EmptyStr)
+	}
+	assert.T(t).This(atomic.LoadInt32(&calls)).Is(int32(0))
+	r.invalidate("b")
+	assert.T(t).This(r.pending["b"]).Is(stalePending)
+	r.Get(th, SuStr("b")) // restarts since the pending one is stale
+	assert.T(t).This(<-done).Is("b")
+	assert.T(t).This(atomic.LoadInt32(&calls)).Is(int32(1))
+	assert.T(t).That(!r.IsPending("b"))
+	assert.T(t).This(r.Get(th, SuStr("b"))).Is(SuInt(20))
+}
+
+func TestSuRecord_ObserverOrder(t *testing.T) {
+	th := &Thread{}
+	r := NewSuRecord()
+	var calls []string
+	observer := func(name string) Value {
+		return &SuBuiltin1{Fn: func(m Value) Value {
+			calls = append(calls, name+":"+ToStr(m))
+			return nil
+		}, BuiltinParams: BuiltinParams{ParamSpec: ParamSpec{Nparams: 1,
+			Flags: []Flag{0}, Names: []string{"member"}}}}
+	}
+	r.Observer(observer("lo"), -1)
+	r.Observer(observer("x"), 0)
+	r.Observer(observer("hi"), 1)
+	r.Observer(observer("y"), 0)
+	// b and d depend on a, c depends on b
+	r.addDependent("b", "a")
+	r.addDependent("d", "a")
+	r.addDependent("c", "b")
+	r.Put(th, SuStr("a"), One)
+	assert.T(t).This(calls).Is([]string{
+		"hi:a", "x:a", "y:a", "lo:a",
+		"hi:b", "x:b", "y:b", "lo:b",
+		"hi:c", "x:c", "y:c", "lo:c",
+		"hi:d", "x:d", "y:d", "lo:d"})
+}
+
+func TestSuRecord_EqualSymmetric(t *testing.T) {
+	fromRow := func() *SuRecord {
+		b := RecordBuilder{}
+		b.Add(SuInt(123))
+		b.Add(SuStr(""))
+		row := Row{DbRec{Record: b.Build()}}
+		hdr := NewHeader([][]string{{"num", "empty"}},
+			[]string{"num", "empty"})
+		return SuRecordFromRow(row, hdr, "", nil)
+	}
+	symmetric := func(x, y Value, expected bool) {
+		t.Helper()
+		assert.T(t).This(x.Equal(y)).Is(expected)
+		assert.T(t).This(y.Equal(x)).Is(expected)
+	}
+	ob := &SuObject{}
+	ob.Set(SuStr("num"), SuInt(123))
+	rec := NewSuRecord()
+	rec.Set(SuStr("num"), SuInt(123))
+	symmetric(ob, rec, true)
+	symmetric(ob, fromRow(), true)
+	symmetric(rec, fromRow(), true)
+	symmetric(fromRow(), fromRow(), true)
+
+	ro := fromRow()
+	ro.SetReadOnly()
+	symmetric(ob, ro, true)
+
+	// empty row fields are absent
+	ob2 := ob.Copy().(*SuObject)
+	ob2.Set(SuStr("empty"), EmptyStr)
+	symmetric(ob2, fromRow(), false)
+	rec.Set(SuStr("str"), SuStr("x"))
+	symmetric(ob, rec, false)
+	symmetric(rec, fromRow(), false)
+
+	// nested
+	symmetric(NewSuObject([]Value{fromRow()}), NewSuObject([]Value{ob}), true)
+
+	// default values are not compared
+	ob3 := &SuObject{}
+	ob3.SetDefault(EmptyStr)
+	symmetric(ob3, NewSuRecord(), true)
+
+	symmetric(SuInt(123), fromRow(), false)
+}
+
+func TestSuRecord_Compound(t *testing.T) {
+	assert := assert.T(t)
+	b := RecordBuilder{}
+	b.Add(SuInt(123))
+	row := Row{DbRec{Record: b.Build()}}
+	hdr := NewHeader([][]string{{"num"}}, []string{"num"})
+	rec := SuRecordFromRow(row, hdr, "", nil)
+	th := &Thread{}
+	assert.This(rec.GetInit(th, SuStr("num"), Zero)).Is(SuInt(123))
+	assert.This(rec.GetInit(th, SuStr("str"), SuStr("s"))).Is(SuStr("s"))
+	assert.This(rec.Get(th, SuStr("str"))).Is(SuStr("s"))
+	assert.This(rec.Increment(th, SuStr("num"), One)).Is(SuInt(124))
+	assert.This(rec.Increment(th, SuStr("new"), One)).Is(One)
+	assert.That(rec.AddUnique(One))
+	assert.That(!rec.AddUnique(One))
+}
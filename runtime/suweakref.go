@@ -0,0 +1,200 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"log"
+	"runtime"
+	"sync"
+
+	"github.com/apmckinlay/gsuneido/runtime/types"
+)
+
+// SuWeakRef is a reference to a value that may be released
+// by the garbage collector e.g. for caches.
+//
+// Go (at our language version) does not have weak pointers
+// so these are "soft" references.
+// A value that has not been accessed (with Target) since the previous
+// garbage collection is released after the next one.
+// Once released, the value can be garbage collected
+// if there are no other references to it.
+type SuWeakRef struct {
+	CantConvert
+	lock sync.Mutex
+	val  Value
+	// used is set by Target and cleared after each garbage collection
+	used bool
+}
+
+func NewSuWeakRef(x Value) *SuWeakRef {
+	r := &SuWeakRef{val: x, used: true}
+	weakRefs.add(r)
+	return r
+}
+
+// Target returns the value, or nil if it has been released
+func (r *SuWeakRef) Target() Value {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.val != nil {
+		r.used = true
+	}
+	return r.val
+}
+
+// release is called after each garbage collection.
+// It returns true if the value was released.
+func (r *SuWeakRef) release() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.used {
+		r.used = false
+		return false
+	}
+	r.val = nil
+	return true
+}
+
+// weakRefs is the set of weak references that have not been released
+
+type weakRegistry struct {
+	once sync.Once
+	lock sync.Mutex
+	refs map[*SuWeakRef]struct{}
+}
+
+var weakRefs weakRegistry
+
+func (wr *weakRegistry) add(r *SuWeakRef) {
+	wr.once.Do(gcSentinel)
+	wr.lock.Lock()
+	defer wr.lock.Unlock()
+	if wr.refs == nil {
+		wr.refs = make(map[*SuWeakRef]struct{})
+	}
+	wr.refs[r] = struct{}{}
+}
+
+func (wr *weakRegistry) afterGC() {
+	wr.lock.Lock()
+	defer wr.lock.Unlock()
+	for r := range wr.refs {
+		if r.release() {
+			delete(wr.refs, r)
+		}
+	}
+}
+
+// sentinel is used to detect garbage collections.
+// It must contain a pointer so it is not combined with other tiny objects.
+type sentinel struct {
+	_ *int
+}
+
+// gcSentinel creates garbage with a finalizer
+// that calls afterGC and then creates another sentinel
+func gcSentinel() {
+	runtime.SetFinalizer(&sentinel{}, func(*sentinel) {
+		weakRefs.afterGC()
+		gcSentinel()
+	})
+}
+
+// Finalize registers fn to be called (with no arguments, on a worker thread)
+// after x has been garbage collected, replacing any previous fn.
+// If fn is False, it removes the finalizer.
+//
+// WARNING: fn must not reference x or x will never be garbage collected.
+func Finalize(x Container, fn Value) {
+	// SetFinalizer panics if there is already a finalizer
+	runtime.SetFinalizer(x, nil)
+	if fn == False {
+		return
+	}
+	fn.SetConcurrent()
+	runtime.SetFinalizer(x, func(interface{}) {
+		// don't block the finalizer goroutine if the workers are busy
+		go workers.run(func(t *Thread) {
+			state := t.GetState()
+			defer func() {
+				if e := recover(); e != nil {
+					t.RestoreState(state)
+					log.Println("ERROR: in Finalize:", e)
+				}
+			}()
+			t.Call(fn)
+		})
+	})
+}
+
+// Value interface --------------------------------------------------
+
+var _ Value = (*SuWeakRef)(nil)
+
+func (*SuWeakRef) Get(*Thread, Value) Value {
+	panic("WeakRef does not support get")
+}
+
+func (*SuWeakRef) Put(*Thread, Value, Value) {
+	panic("WeakRef does not support put")
+}
+
+func (*SuWeakRef) GetPut(*Thread, Value, Value, func(x, y Value) Value, bool) Value {
+	panic("WeakRef does not support update")
+}
+
+func (*SuWeakRef) RangeTo(int, int) Value {
+	panic("WeakRef does not support range")
+}
+
+func (*SuWeakRef) RangeLen(int, int) Value {
+	panic("WeakRef does not support range")
+}
+
+func (*SuWeakRef) Hash() uint32 {
+	panic("WeakRef hash not implemented")
+}
+
+func (*SuWeakRef) Hash2() uint32 {
+	panic("WeakRef hash not implemented")
+}
+
+func (*SuWeakRef) Compare(Value) int {
+	panic("WeakRef compare not implemented")
+}
+
+func (*SuWeakRef) Call(*Thread, Value, *ArgSpec) Value {
+	panic("can't call WeakRef")
+}
+
+func (*SuWeakRef) String() string {
+	return "aWeakRef"
+}
+
+func (*SuWeakRef) Type() types.Type {
+	return types.BuiltinInstance
+}
+
+func (r *SuWeakRef) Equal(other interface{}) bool {
+	r2, ok := other.(*SuWeakRef)
+	return ok && r == r2
+}
+
+func (r *SuWeakRef) SetConcurrent() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.val != nil {
+		r.val.SetConcurrent()
+	}
+}
+
+// WeakRefMethods is initialized by the builtin package
+var WeakRefMethods Methods
+
+var gnWeakRefs = Global.Num("WeakRefs")
+
+func (*SuWeakRef) Lookup(t *Thread, method string) Callable {
+	return Lookup(t, WeakRefMethods, gnWeakRefs, method)
+}
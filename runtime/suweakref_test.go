@@ -0,0 +1,86 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+// gcWait runs garbage collections until done returns true
+func gcWait(done func() bool) bool {
+	for i := 0; i < 100; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+		if done() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSuWeakRef(t *testing.T) {
+	assert := assert.T(t)
+	ob := &SuObject{}
+	used := NewSuWeakRef(ob)
+	unused := NewSuWeakRef(&SuObject{})
+	assert.This(used.Target()).Is(ob)
+	released := gcWait(func() bool {
+		used.Target()      // keep it in use
+		unused.lock.Lock() // not Target because that would mark it used
+		defer unused.lock.Unlock()
+		return unused.val == nil
+	})
+	assert.That(released)
+	assert.This(used.Target()).Is(ob)
+}
+
+func TestFinalize(t *testing.T) {
+	done := make(chan struct{})
+	func() {
+		ob := &SuObject{}
+		Finalize(ob, &SuBuiltin0{Fn: func() Value {
+			close(done)
+			return nil
+		}})
+	}()
+	finalized := gcWait(func() bool {
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	})
+	assert.T(t).That(finalized)
+}
+
+func TestFinalizeReplace(t *testing.T) {
+	first := make(chan struct{}, 1)
+	second := make(chan struct{}, 1)
+	func() {
+		ob := &SuObject{}
+		Finalize(ob, &SuBuiltin0{Fn: func() Value {
+			first <- struct{}{}
+			return nil
+		}})
+		Finalize(ob, &SuBuiltin0{Fn: func() Value {
+			second <- struct{}{}
+			return nil
+		}})
+	}()
+	finalized := gcWait(func() bool {
+		select {
+		case <-second:
+			return true
+		default:
+			return false
+		}
+	})
+	assert.T(t).That(finalized)
+	assert.T(t).That(len(first) == 0)
+}
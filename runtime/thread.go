@@ -7,6 +7,7 @@ import (
 	"log"
 	"strconv"
 	"sync/atomic"
+	"time"
 
 	"github.com/apmckinlay/gsuneido/options"
 	"github.com/apmckinlay/gsuneido/util/regex"
@@ -75,6 +76,27 @@ type Thread struct {
 
 	// Profile is used to track heavily executed functions
 	Profile map[*SuFunc]int
+
+	// CallProfile accumulates call counts and times when it is not nil
+	CallProfile map[*SuFunc]*CallStats
+
+	// deadline is when execution should be stopped, zero for none.
+	// It is checked by interp when it polls. See SetDeadline
+	deadline time.Time
+
+	// cancelled is set by Cancel, possibly from another thread,
+	// so it must be accessed atomically
+	cancelled int32
+
+	// allocated is the approximate number of bytes this thread has allocated
+	// for strings and objects. It only increases, it is not reduced by GC.
+	// See Alloc
+	allocated int64
+
+	// MemLimit is the limit on allocated, zero means no limit.
+	// Since allocated only increases, it is set relative to allocated
+	// by LimitMemory or Thread.MemoryLimit. Threads have no limit by default.
+	MemLimit int64
 }
 
 var nThread int32
@@ -87,6 +109,80 @@ func NewThread() *Thread {
 		Name: "Thread-" + strconv.Itoa(int(n))}
 }
 
+// Cancel requests that the thread stop what it is doing.
+// It is safe to call from other threads.
+// The thread throws "thread cancelled" the next time interp polls.
+func (t *Thread) Cancel() {
+	atomic.StoreInt32(&t.cancelled, 1)
+}
+
+// SetDeadline sets when execution should be stopped
+// and returns the previous deadline.
+// Once it has passed the thread throws "thread deadline exceeded"
+// the next time interp polls.
+// A zero time removes the deadline.
+func (t *Thread) SetDeadline(d time.Time) time.Time {
+	prev := t.deadline
+	t.deadline = d
+	return prev
+}
+
+// Deadline returns the current deadline, zero if there is none
+func (t *Thread) Deadline() time.Time {
+	return t.deadline
+}
+
+// checkCancel is called periodically by interp.
+// Cancellation and deadlines only throw once
+// so they can be caught and handled.
+func (t *Thread) checkCancel() {
+	if atomic.LoadInt32(&t.cancelled) != 0 {
+		atomic.StoreInt32(&t.cancelled, 0)
+		panic("thread cancelled")
+	}
+	if !t.deadline.IsZero() && time.Now().After(t.deadline) {
+		t.deadline = time.Time{}
+		panic("thread deadline exceeded")
+	}
+}
+
+// MemberSize is the approximate memory for an object member,
+// used for Alloc
+const MemberSize = 32
+
+// Alloc records an approximate allocation of n bytes by the thread.
+// If this exceeds MemLimit it throws "thread memory limit exceeded".
+// Like deadlines, the limit is removed when it throws
+// so the exception can be caught and handled.
+// t may be nil, in which case it does nothing.
+func (t *Thread) Alloc(n int) {
+	if t == nil {
+		return
+	}
+	t.allocated += int64(n)
+	if t.MemLimit > 0 && t.allocated > t.MemLimit {
+		t.MemLimit = 0
+		panic("thread memory limit exceeded")
+	}
+}
+
+// LimitMemory limits the thread to allocating n more bytes from now on
+// and returns the previous MemLimit. n <= 0 removes the limit.
+// It is used to apply options.ThreadMemLimit to each request.
+func (t *Thread) LimitMemory(n int64) int64 {
+	prev := t.MemLimit
+	t.MemLimit = 0
+	if n > 0 {
+		t.MemLimit = t.allocated + n
+	}
+	return prev
+}
+
+// Allocated returns the approximate number of bytes allocated by the thread
+func (t *Thread) Allocated() int64 {
+	return t.allocated
+}
+
 // Push pushes a value onto the value stack
 func (t *Thread) Push(x Value) {
 	if t.sp >= maxStack {
@@ -145,6 +241,9 @@ func (t *Thread) Callstack() *SuObject {
 		call := &SuObject{}
 		call.Set(SuStr("fn"), fr.fn)
 		call.Set(SuStr("srcpos"), IntVal(fr.fn.CodeToSrcPos(fr.ip-1)))
+		if line := fr.fn.CodeToLine(fr.ip - 1); line > 0 {
+			call.Set(SuStr("line"), IntVal(line))
+		}
 		call.Set(SuStr("locals"), t.locals(i))
 		cs.Add(call)
 	}
@@ -193,7 +292,11 @@ func PrintStack(cs *SuObject) {
 	for i := 0; i < cs.ListSize(); i++ {
 		frame := cs.ListGet(i)
 		fn := frame.Get(nil, SuStr("fn"))
-		log.Println(fn)
+		if line := frame.Get(nil, SuStr("line")); line != nil {
+			log.Println(fn, "line", line)
+		} else {
+			log.Println(fn)
+		}
 		// locals := frame.Get(nil, SuStr("locals"))
 		// log.Println("   " + toStr(locals))
 	}
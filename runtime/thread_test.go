@@ -0,0 +1,83 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apmckinlay/gsuneido/options"
+	op "github.com/apmckinlay/gsuneido/runtime/opcodes"
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestThreadCancel(t *testing.T) {
+	assert := assert.T(t)
+	th := &Thread{}
+	th.checkCancel()
+	done := make(chan bool)
+	go func() {
+		th.Cancel()
+		done <- true
+	}()
+	<-done
+	assert.This(th.checkCancel).Panics("thread cancelled")
+	th.checkCancel() // only throws once
+
+	prev := th.SetDeadline(time.Now().Add(time.Hour))
+	assert.That(prev.IsZero())
+	th.checkCancel()
+	th.SetDeadline(time.Now().Add(-time.Millisecond))
+	assert.This(th.checkCancel).Panics("deadline exceeded")
+	assert.That(th.Deadline().IsZero())
+	th.checkCancel()
+}
+
+func TestThreadDeadlineInterp(t *testing.T) {
+	loop := &SuFunc{Code: string([]byte{byte(op.Jump), 0xff, 0xfd})} // forever
+	th := NewThread()
+	th.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	assert.T(t).This(func() { th.Invoke(loop, nil) }).
+		Panics("thread deadline exceeded")
+}
+
+func TestThreadAlloc(t *testing.T) {
+	assert := assert.T(t)
+	var nilThread *Thread
+	nilThread.Alloc(1000)
+	th := &Thread{MemLimit: 200}
+	s := SuStr(strings.Repeat("x", 50))
+	OpCat(th, s, s)
+	assert.This(th.Allocated()).Is(int64(100))
+	ob := &SuObject{}
+	ob.Put(th, SuStr("a"), s)
+	assert.This(th.Allocated()).Is(int64(100 + MemberSize))
+	assert.This(func() { OpCat(th, s, s) }).
+		Panics("thread memory limit exceeded")
+	OpCat(th, s, s) // limit removed after throwing
+}
+
+func TestThreadLimitMemory(t *testing.T) {
+	assert := assert.T(t)
+	options.ThreadMemLimit = 100
+	defer func() { options.ThreadMemLimit = 0 }()
+	th := NewThread()
+	assert.This(th.MemLimit).Is(int64(0)) // not applied by default
+	s := SuStr(strings.Repeat("x", 50))
+	for i := 0; i < 10; i++ {
+		OpCat(th, s, s)
+	}
+	// the limit is relative to what has already been allocated
+	assert.This(th.LimitMemory(200)).Is(int64(0))
+	OpCat(th, s, s)
+	OpCat(th, s, s)
+	assert.This(func() { OpCat(th, s, s) }).
+		Panics("thread memory limit exceeded")
+	// each request gets a new baseline
+	th.LimitMemory(200)
+	OpCat(th, s, s)
+	assert.This(th.LimitMemory(0)).Is(th.Allocated() + 100)
+	assert.This(th.MemLimit).Is(int64(0))
+}
@@ -6,7 +6,9 @@ package trace
 import (
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"strings"
 	"sync"
 )
 
@@ -20,6 +22,21 @@ func Set(w int) int {
 	return int(prev)
 }
 
+// Enable turns on the given flags and returns the previous flags
+func Enable(w int) int {
+	return Set(int(cur) | w)
+}
+
+// Disable turns off the given flags and returns the previous flags
+func Disable(w int) int {
+	return Set(int(cur) &^ w)
+}
+
+// Flags returns the current flags
+func Flags() int {
+	return int(cur)
+}
+
 const (
 	Functions what = 1 << iota
 	Statements
@@ -44,8 +61,15 @@ const (
 
 	JoinOpt
 	Dbms
+
+	RingBuffer
+	Socket
 )
 
+// sinks are the flags that control where output goes.
+// If none are set, output goes to both the log file and the console.
+const sinks = Console | LogFile | RingBuffer | Socket
+
 func (w what) String() string {
 	return map[what]string{
 		Functions:    "FUNC ",
@@ -68,9 +92,42 @@ func (w what) String() string {
 		Globals:      "GLOBAL ",
 		JoinOpt:      "JOINOPT ",
 		Dbms:         "DBMS ",
+		RingBuffer:   "RING ",
+		Socket:       "SOCKET ",
 	}[w]
 }
 
+var names = map[string]what{
+	"functions":    Functions,
+	"statements":   Statements,
+	"opcodes":      Opcodes,
+	"records":      Records,
+	"libraries":    Libraries,
+	"slowquery":    SlowQuery,
+	"query":        Query,
+	"symbol":       Symbol,
+	"allindex":     AllIndex,
+	"table":        Table,
+	"select":       Select,
+	"tempindex":    TempIndex,
+	"queryopt":     QueryOpt,
+	"console":      Console,
+	"logfile":      LogFile,
+	"clientserver": ClientServer,
+	"exceptions":   Exceptions,
+	"globals":      Globals,
+	"joinopt":      JoinOpt,
+	"dbms":         Dbms,
+	"ringbuffer":   RingBuffer,
+	"socket":       Socket,
+}
+
+// Flag returns the flag for a name like "Records" (case insensitive)
+func Flag(name string) (int, bool) {
+	w, ok := names[strings.ToLower(name)]
+	return int(w), ok
+}
+
 func (w what) Println(first interface{}, rest ...interface{}) {
 	// kept short in hopes it will be inlined
 	if cur&w != 0 {
@@ -84,27 +141,131 @@ func (w what) println(first interface{}, rest []interface{}) {
 }
 
 func Print(s string) {
-	if cur&LogFile != 0 || cur&(LogFile|Console) == 0 {
+	sink := cur & sinks
+	if sink&LogFile != 0 || sink == 0 {
 		logPrintln(s)
 	}
-	if cur&Console != 0 || cur&(LogFile|Console) == 0 {
+	if sink&Console != 0 || sink == 0 {
 		consolePrintln(s)
 	}
+	if sink&RingBuffer != 0 {
+		ring.add(s)
+	}
+	if sink&Socket != 0 {
+		socketPrintln(s)
+	}
 }
 
+// log file ---------------------------------------------------------
+
+var logLock sync.Mutex
+var logName = "trace.log"
 var traceLog *os.File
-var traceLogOnce sync.Once
+
+// SetLogFile changes the file used by LogFile. It is opened on next use.
+func SetLogFile(filename string) {
+	logLock.Lock()
+	defer logLock.Unlock()
+	if traceLog != nil {
+		traceLog.Close()
+		traceLog = nil
+	}
+	logName = filename
+}
 
 func logPrintln(s string) {
-	traceLogOnce.Do(func() {
+	logLock.Lock()
+	defer logLock.Unlock()
+	if traceLog == nil && logName != "" {
 		var err error
-		traceLog, err = os.OpenFile("trace.log",
+		traceLog, err = os.OpenFile(logName,
 			os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
 		if err != nil {
 			log.Println("ERROR", err)
+			logName = "" // don't keep trying
+			return
 		}
-	})
+	}
 	if traceLog != nil {
 		traceLog.WriteString(s)
 	}
 }
+
+// ring buffer ------------------------------------------------------
+
+// ringSize is the number of recent lines kept by RingBuffer
+const ringSize = 1000
+
+type ringBuffer struct {
+	lock  sync.Mutex
+	lines [ringSize]string
+	next  int
+	full  bool
+}
+
+var ring ringBuffer
+
+func (rb *ringBuffer) add(s string) {
+	rb.lock.Lock()
+	defer rb.lock.Unlock()
+	rb.lines[rb.next] = s
+	rb.next++
+	if rb.next >= ringSize {
+		rb.next = 0
+		rb.full = true
+	}
+}
+
+// Recent returns the lines in the ring buffer, oldest first,
+// and empties it
+func Recent() []string {
+	ring.lock.Lock()
+	defer ring.lock.Unlock()
+	var list []string
+	if ring.full {
+		list = append(list, ring.lines[ring.next:]...)
+	}
+	list = append(list, ring.lines[:ring.next]...)
+	ring.lines = [ringSize]string{}
+	ring.next = 0
+	ring.full = false
+	return list
+}
+
+// socket -----------------------------------------------------------
+
+var sockLock sync.Mutex
+var traceSock net.Conn
+
+// SetSocket connects Socket output to a TCP address (e.g. "localhost:9999")
+// closing any previous connection. An empty address just closes.
+func SetSocket(addr string) error {
+	sockLock.Lock()
+	defer sockLock.Unlock()
+	if traceSock != nil {
+		traceSock.Close()
+		traceSock = nil
+	}
+	if addr == "" {
+		return nil
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	traceSock = conn
+	return nil
+}
+
+func socketPrintln(s string) {
+	sockLock.Lock()
+	defer sockLock.Unlock()
+	if traceSock == nil {
+		return
+	}
+	if _, err := traceSock.Write([]byte(s)); err != nil {
+		log.Println("ERROR trace socket", err)
+		traceSock.Close()
+		traceSock = nil
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package trace
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestRingBuffer(t *testing.T) {
+	assert := assert.T(t)
+	prev := Set(int(Records | RingBuffer))
+	defer Set(prev)
+	Recent()
+	Records.Println("one")
+	Dbms.Println("not enabled")
+	Records.Println("two", 2)
+	assert.This(Recent()).Is([]string{"REC one \n", "REC two 2\n"})
+	assert.This(len(Recent())).Is(0)
+
+	for i := 0; i < ringSize+5; i++ {
+		Print("x")
+	}
+	assert.This(len(Recent())).Is(ringSize)
+}
+
+func TestFlag(t *testing.T) {
+	assert := assert.T(t)
+	f, ok := Flag("Records")
+	assert.That(ok)
+	assert.This(f).Is(int(Records))
+	_, ok = Flag("nonexistent")
+	assert.That(!ok)
+
+	prev := Set(0)
+	defer Set(prev)
+	Enable(int(Dbms | Records))
+	Disable(int(Records))
+	assert.This(Flags()).Is(int(Dbms))
+}
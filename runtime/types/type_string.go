@@ -29,12 +29,13 @@ func _() {
 	_ = x[AstNode-18]
 	_ = x[BuiltinClass-19]
 	_ = x[BuiltinInstance-20]
-	_ = x[N-21]
+	_ = x[Buffer-21]
+	_ = x[N-22]
 }
 
-const _Type_name = "BooleanNumberStringDateObjectRecordFunctionBlockBuiltinFunctionClassMethodExceptInstanceIteratorTransactionQueryCursorFileAstNodeBuiltinClassBuiltinInstanceN"
+const _Type_name = "BooleanNumberStringDateObjectRecordFunctionBlockBuiltinFunctionClassMethodExceptInstanceIteratorTransactionQueryCursorFileAstNodeBuiltinClassBuiltinInstanceBufferN"
 
-var _Type_index = [...]uint8{0, 7, 13, 19, 23, 29, 35, 43, 48, 63, 68, 74, 80, 88, 96, 107, 112, 118, 122, 129, 141, 156, 157}
+var _Type_index = [...]uint8{0, 7, 13, 19, 23, 29, 35, 43, 48, 63, 68, 74, 80, 88, 96, 107, 112, 118, 122, 129, 141, 156, 162, 163}
 
 func (i Type) String() string {
 	if i < 0 || i >= Type(len(_Type_index)-1) {
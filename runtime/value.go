@@ -21,6 +21,7 @@ import (
 // - SuInt, SuDnum - numbers
 // - SuStr, SuConcat, SuExcept - strings
 // - SuDate
+// - SuBuffer - binary data
 // - SuObject, SuRecord, SuSequence - objects
 // - SuBuiltin*, SuBuiltinMethod*
 // - SuFunc
@@ -108,6 +109,7 @@ const (
 	ordStr      // SuStr, SuConcat, SuExcept
 	ordDate
 	ordObject
+	ordBuffer
 	OrdOther
 )
 
@@ -119,6 +121,8 @@ func Order(x Value) Ord {
 		return ordStr
 	} else if t == types.Record {
 		return ordObject
+	} else if t == types.Buffer {
+		return ordBuffer
 	}
 	return OrdOther
 }
@@ -0,0 +1,107 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package runtime
+
+// WrapIter adapts a Suneido iterator (a class with Next,Dup,Infinite)
+// to the runtime.Iter interface. For the reverse see SuIter.
+// No locking since not mutable.
+type WrapIter struct {
+	it Value
+	// t is nil when concurrent.
+	// When not concurrent we use the creating thread.
+	t *Thread
+}
+
+func NewWrapIter(t *Thread, it Value) *WrapIter {
+	return &WrapIter{it: it, t: t}
+}
+
+// Unwrap returns the Suneido iterator
+func (wi *WrapIter) Unwrap() Value {
+	return wi.it
+}
+
+func (wi *WrapIter) Next() Value {
+	x := wi.call("Next")
+	if x == wi.it {
+		return nil
+	}
+	return x
+}
+
+func (wi *WrapIter) Infinite() (result bool) {
+	return wi.call("Infinite?") == True
+}
+
+func (wi *WrapIter) Dup() Iter {
+	it := wi.call("Dup")
+	return &WrapIter{it: it, t: wi.t}
+}
+
+func (wi *WrapIter) SetConcurrent() {
+	wi.t = nil
+	wi.it.SetConcurrent()
+}
+
+func (wi *WrapIter) IsConcurrent() Value {
+	return SuBool(wi.t == nil)
+}
+
+func (wi *WrapIter) call(method string) Value {
+	t := wi.t
+	if t == nil {
+		t = &Thread{}
+		t.Name = "*internal*"
+		defer t.Close()
+	}
+	return t.CallLookup(wi.it, method)
+}
+
+func (wi *WrapIter) Instantiate() *SuObject {
+	return InstantiateIter(wi)
+}
+
+var _ Iter = (*WrapIter)(nil)
+
+// ToIter returns an Iter for a value.
+// Builtin types like objects, strings, and sequences implement Iter directly.
+// Instances of user defined classes can participate by defining
+// an Iter method that returns an iterator,
+// or by being an iterator themselves i.e. defining a Next method
+// that returns the iterator itself when there are no more values.
+func ToIter(t *Thread, x Value) Iter {
+	if it := toIter(x); it != nil {
+		return it
+	}
+	if inst, ok := x.(*SuInstance); ok {
+		if inst.hasMethod(t, "Iter") {
+			it := t.CallLookup(inst, "Iter")
+			if iter := toIter(it); iter != nil {
+				return iter
+			}
+			return NewWrapIter(t, it)
+		}
+		if inst.hasMethod(t, "Next") {
+			return NewWrapIter(t, inst)
+		}
+	}
+	panic("can't iterate " + x.Type().String())
+}
+
+func toIter(x Value) Iter {
+	switch x := x.(type) {
+	case interface{ Iter() Iter }:
+		return x.Iter()
+	case SuIter:
+		return x.Iter
+	}
+	return nil
+}
+
+// hasMethod returns whether the class chain defines a method.
+// Unlike Lookup it does not consider builtin methods or Default.
+func (ob *SuInstance) hasMethod(t *Thread, method string) bool {
+	_, ok := ob.class.get2(t, method, ob.parents).(*SuFunc)
+	return ok
+}
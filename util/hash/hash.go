@@ -10,10 +10,18 @@ Currently the hash function is FNV-1a
 based on the standard Go fnv package
 
 Only the first 64 bytes are included if longer.
+
+SeededString and SeededBytes use a random per-process seed
+and include all the bytes, so collisions can not be crafted in advance.
+They must only be used for values that are not persisted.
 */
 package hash
 
-import "github.com/apmckinlay/gsuneido/util/ints"
+import (
+	"hash/maphash"
+
+	"github.com/apmckinlay/gsuneido/util/ints"
+)
 
 const (
 	offset32 = 2166136261
@@ -40,3 +48,25 @@ func HashBytes(bytes []byte) uint32 {
 	}
 	return hash
 }
+
+var seed = maphash.MakeSeed()
+
+// SeededString returns a hash that varies between processes
+func SeededString(s string) uint32 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	h.WriteString(s)
+	return fold(h.Sum64())
+}
+
+// SeededBytes returns the same hash as SeededString(string(b))
+func SeededBytes(b []byte) uint32 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	h.Write(b)
+	return fold(h.Sum64())
+}
+
+func fold(h uint64) uint32 {
+	return uint32(h) ^ uint32(h>>32)
+}
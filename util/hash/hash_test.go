@@ -6,6 +6,7 @@ package hash
 import (
 	"hash/fnv"
 	"hash/maphash"
+	"strings"
 	"testing"
 
 	"github.com/apmckinlay/gsuneido/util/assert"
@@ -21,6 +22,21 @@ func TestHash(t *testing.T) {
 	test("foobar", 0xbf9cf968)
 }
 
+func TestSeeded(t *testing.T) {
+	assert := assert.T(t)
+	for _, s := range []string{"", "foobar", strings.Repeat("x", 100)} {
+		assert.This(SeededBytes([]byte(s))).Is(SeededString(s))
+	}
+	assert.That(SeededString("foobar") != SeededString("foobaz"))
+	// unlike HashString, all the bytes are included
+	long := strings.Repeat("x", maxlen)
+	assert.That(SeededString(long+"a") != SeededString(long+"b"))
+	assert.This(HashString(long + "a")).Is(HashString(long + "b"))
+	assert.This(testing.AllocsPerRun(10, func() {
+		Sum += SeededString(S)
+	})).Is(0.0)
+}
+
 var Sum = uint32(0)
 var S = "now is the time for all good"
 
@@ -30,6 +46,12 @@ func BenchmarkHashString(b *testing.B) {
 	}
 }
 
+func BenchmarkSeededString(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Sum += SeededString(S)
+	}
+}
+
 func BenchmarkMaphash(b *testing.B) {
 	h := maphash.Hash{}
 	for i := 0; i < b.N; i++ {
@@ -0,0 +1,153 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+// Package locale provides the conventions for formatting and parsing
+// numbers and dates for some common locales.
+//
+// Numbers are formatted in the standard way (e.g. by dnum.Format)
+// and then converted with FormatNumber.
+package locale
+
+import "strings"
+
+type Locale struct {
+	Name string
+	// Decimal is the decimal separator e.g. "." or ","
+	Decimal string
+	// Thousands is the grouping separator e.g. "," or "."
+	Thousands string
+	// DateOrder is the order of the date parts for ParseDate e.g. "dmy"
+	DateOrder string
+	// ShortDate is the default date format
+	ShortDate  string
+	Months     [12]string
+	MonthAbbrs [12]string
+	Days       [7]string // starting with Sunday
+	DayAbbrs   [7]string
+}
+
+var english = Locale{
+	Decimal: ".", Thousands: ",",
+	Months: [12]string{"January", "February", "March", "April", "May",
+		"June", "July", "August", "September", "October", "November",
+		"December"},
+	MonthAbbrs: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun",
+		"Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	Days: [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday",
+		"Friday", "Saturday"},
+	DayAbbrs: [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+}
+
+var french = Locale{
+	Decimal: ",", Thousands: " ", // non-breaking space
+	Months: [12]string{"janvier", "février", "mars", "avril", "mai", "juin",
+		"juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+	MonthAbbrs: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin",
+		"juil.", "août", "sept.", "oct.", "nov.", "déc."},
+	Days: [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi",
+		"vendredi", "samedi"},
+	DayAbbrs: [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.",
+		"sam."},
+}
+
+var german = Locale{
+	Decimal: ",", Thousands: ".",
+	Months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni",
+		"Juli", "August", "September", "Oktober", "November", "Dezember"},
+	MonthAbbrs: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni",
+		"Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+	Days: [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch",
+		"Donnerstag", "Freitag", "Samstag"},
+	DayAbbrs: [7]string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+}
+
+var spanish = Locale{
+	Decimal: ",", Thousands: ".",
+	Months: [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio",
+		"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+	MonthAbbrs: [12]string{"ene.", "feb.", "mar.", "abr.", "may.", "jun.",
+		"jul.", "ago.", "sept.", "oct.", "nov.", "dic."},
+	Days: [7]string{"domingo", "lunes", "martes", "miércoles", "jueves",
+		"viernes", "sábado"},
+	DayAbbrs: [7]string{"dom.", "lun.", "mar.", "mié.", "jue.", "vie.",
+		"sáb."},
+}
+
+func derive(base Locale, name, dateOrder, shortDate string) *Locale {
+	loc := base
+	loc.Name = name
+	loc.DateOrder = dateOrder
+	loc.ShortDate = shortDate
+	return &loc
+}
+
+// Default is the locale used if none is specified (North American)
+var Default = derive(english, "en-US", "mdy", "M/d/yyyy")
+
+var locales = map[string]*Locale{
+	"en-us": Default,
+	"en-ca": derive(english, "en-CA", "ymd", "yyyy-MM-dd"),
+	"en-gb": derive(english, "en-GB", "dmy", "dd/MM/yyyy"),
+	"fr-fr": derive(french, "fr-FR", "dmy", "dd/MM/yyyy"),
+	"fr-ca": derive(french, "fr-CA", "ymd", "yyyy-MM-dd"),
+	"de-de": derive(german, "de-DE", "dmy", "dd.MM.yyyy"),
+	"es-es": derive(spanish, "es-ES", "dmy", "dd/MM/yyyy"),
+}
+
+// languages is the default locale for just a language e.g. "fr"
+var languages = map[string]string{
+	"en": "en-us", "fr": "fr-fr", "de": "de-de", "es": "es-es",
+}
+
+// Get returns the Locale for a name like "fr-CA" or "fr_CA" or "fr".
+// A language with an unknown region (e.g. "de-AT") gets the language default.
+func Get(name string) (*Locale, bool) {
+	name = strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	if loc, ok := locales[name]; ok {
+		return loc, true
+	}
+	lang := name
+	if i := strings.IndexByte(name, '-'); i >= 0 {
+		lang = name[:i]
+	}
+	if name, ok := languages[lang]; ok {
+		return locales[name], true
+	}
+	return nil, false
+}
+
+// FormatNumber converts a number formatted in the standard way
+// (with "," for thousands and "." for decimal) to this locale
+func (loc *Locale) FormatNumber(s string) string {
+	if loc.Decimal == "." && loc.Thousands == "," {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '.':
+			sb.WriteString(loc.Decimal)
+		case ',':
+			sb.WriteString(loc.Thousands)
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// ParseNumber converts a number formatted for this locale
+// to the standard form (without thousands separators)
+// suitable for e.g. dnum.FromStr.
+// Spaces are also accepted as thousands separators.
+func (loc *Locale) ParseNumber(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.ReplaceAll(s, loc.Thousands, "")
+	s = strings.ReplaceAll(s, " ", "")
+	s = strings.ReplaceAll(s, " ", "")
+	s = strings.ReplaceAll(s, " ", "")
+	if loc.Decimal != "." {
+		s = strings.ReplaceAll(s, loc.Decimal, ".")
+	}
+	return s
+}
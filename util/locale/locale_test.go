@@ -0,0 +1,38 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package locale
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestGet(t *testing.T) {
+	assert := assert.T(t)
+	test := func(name, expected string) {
+		t.Helper()
+		loc, ok := Get(name)
+		assert.That(ok)
+		assert.This(loc.Name).Is(expected)
+	}
+	test("en-US", "en-US")
+	test("fr_CA", "fr-CA")
+	test("FR", "fr-FR")
+	test("de-AT", "de-DE")
+	_, ok := Get("xx")
+	assert.That(!ok)
+}
+
+func TestNumber(t *testing.T) {
+	assert := assert.T(t)
+	de, _ := Get("de")
+	assert.This(de.FormatNumber("-1,234,567.89")).Is("-1.234.567,89")
+	assert.This(de.ParseNumber("-1.234.567,89")).Is("-1234567.89")
+	fr, _ := Get("fr")
+	assert.This(fr.FormatNumber("1,234.5")).Is("1 234,5")
+	assert.This(fr.ParseNumber(" 1 234,5 ")).Is("1234.5")
+	assert.This(Default.FormatNumber("1,234.5")).Is("1,234.5")
+	assert.This(Default.ParseNumber("1,234.5")).Is("1234.5")
+}
@@ -0,0 +1,259 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package regex
+
+import (
+	"strings"
+
+	"github.com/apmckinlay/gsuneido/util/ascii"
+)
+
+// pike is a non-backtracking matcher (a Pike VM)
+// that runs the same compiled instructions as the backtracking matcher.
+// It simulates all the alternatives in lock step,
+// so matching is linear in the length of the string
+// regardless of the pattern.
+// Threads are kept in priority order so the results are the same as
+// the leftmost first results from backtracking.
+// It can not handle backreferences.
+//
+// chars instructions match a byte at a time,
+// a thread's off is its offset within the chars.
+type pike struct {
+	pat  Pattern
+	s    string
+	base []int // the state number for each pat index
+	// clist is the threads for the current position,
+	// nlist is the threads for the next position
+	clist, nlist threadList
+}
+
+type thread struct {
+	pi   int
+	off  int
+	caps caps
+}
+
+// caps is the per thread match state
+type caps struct {
+	pos int // the position where this thread started
+	tmp [maxResult]int
+	res Result
+}
+
+// threadList is a sparse set of states
+// plus the threads for the consuming (and final) states
+type threadList struct {
+	sparse  []int
+	dense   []int
+	threads []thread
+}
+
+func newThreadList(n int) threadList {
+	return threadList{sparse: make([]int, n), dense: make([]int, 0, n)}
+}
+
+func (tl *threadList) contains(id int) bool {
+	i := tl.sparse[id]
+	return i < len(tl.dense) && tl.dense[i] == id
+}
+
+func (tl *threadList) insert(id int) {
+	tl.sparse[id] = len(tl.dense)
+	tl.dense = append(tl.dense, id)
+}
+
+func (tl *threadList) clear() {
+	tl.dense = tl.dense[:0]
+	tl.threads = tl.threads[:0]
+}
+
+func newPike(pat Pattern, s string) *pike {
+	base := make([]int, len(pat)+1)
+	n := 0
+	for i, in := range pat {
+		base[i] = n
+		if in.op == chars || in.op == charsIgnore {
+			n += len(in.data)
+		} else {
+			n++
+		}
+	}
+	base[len(pat)] = n // the final (matched) state
+	n++
+	return &pike{pat: pat, s: s, base: base,
+		clist: newThreadList(n), nlist: newThreadList(n)}
+}
+
+// hasBackref returns whether the pattern contains backreferences
+// which require the backtracking matcher
+func (pat Pattern) hasBackref() bool {
+	for i := range pat {
+		if pat[i].op == backref || pat[i].op == backrefIgnore {
+			return true
+		}
+	}
+	return false
+}
+
+// match is the equivalent of Pattern.backtrack
+func (p *pike) match(pos, incdec int, result *Result) int {
+	*result = Result{}
+	if incdec == +1 {
+		return p.search(pos, true, result)
+	}
+	for ; 0 <= pos && pos <= len(p.s); pos += incdec {
+		if i := p.search(pos, false, result); i != -1 || incdec == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// search runs the threads forward from pos.
+// If unanchored, it starts a new (lowest priority) thread at each position
+// until a match is found.
+func (p *pike) search(pos int, unanchored bool, result *Result) int {
+	if pos < 0 || pos > len(p.s) {
+		return -1
+	}
+	prefix := ""
+	if unanchored {
+		prefix = p.literalPrefix()
+	}
+	p.clist.clear()
+	matched := -1
+	for si := pos; ; si++ {
+		if matched == -1 && (unanchored || si == pos) {
+			if prefix != "" && len(p.clist.threads) == 0 {
+				// skip ahead to where the prefix is
+				j := strings.Index(p.s[si:], prefix)
+				if j == -1 {
+					break
+				}
+				si += j
+			}
+			p.add(&p.clist, 0, 0, si, caps{pos: si})
+		}
+		if len(p.clist.threads) == 0 && (matched != -1 || !unanchored) {
+			break
+		}
+		p.nlist.clear()
+		for i := range p.clist.threads {
+			t := &p.clist.threads[i]
+			if t.pi == len(p.pat) {
+				// matched, cut off the lower priority threads
+				matched = t.caps.pos
+				*result = t.caps.res
+				break
+			}
+			if si < len(p.s) && p.step(t, p.s[si]) {
+				if t.off+1 < p.length(t.pi) {
+					p.add(&p.nlist, t.pi, t.off+1, si+1, t.caps)
+				} else {
+					p.add(&p.nlist, t.pi+1, 0, si+1, t.caps)
+				}
+			}
+		}
+		if si >= len(p.s) {
+			break
+		}
+		p.clist, p.nlist = p.nlist, p.clist
+	}
+	return matched
+}
+
+// literalPrefix returns the chars every match must start with, if any
+func (p *pike) literalPrefix() string {
+	for _, in := range p.pat {
+		if in.op == chars {
+			return in.data
+		}
+		if in.op != left {
+			break
+		}
+	}
+	return ""
+}
+
+func (p *pike) length(pi int) int {
+	if in := &p.pat[pi]; in.op == chars || in.op == charsIgnore {
+		return len(in.data)
+	}
+	return 1
+}
+
+// step returns whether the thread's instruction matches the character
+func (p *pike) step(t *thread, c byte) bool {
+	in := &p.pat[t.pi]
+	switch in.op {
+	case dot:
+		return c != '\r' && c != '\n'
+	case chars:
+		return c == in.data[t.off]
+	case charsIgnore:
+		return ascii.ToLower(c) == ascii.ToLower(in.data[t.off])
+	case listSet:
+		return -1 != strings.IndexByte(in.data, c)
+	case bitSet:
+		return in.data[c>>3]&(1<<(c&7)) != 0
+	}
+	panic("bad regex pattern op code")
+}
+
+// add adds a thread to the list, following jumps, branches,
+// captures, and assertions (which do not consume characters)
+func (p *pike) add(tl *threadList, pi, off, si int, c caps) {
+	id := p.base[pi] + off
+	if tl.contains(id) {
+		return
+	}
+	tl.insert(id)
+	if pi == len(p.pat) {
+		tl.threads = append(tl.threads, thread{pi: pi, caps: c})
+		return
+	}
+	s := p.s
+	in := &p.pat[pi]
+	m := true
+	switch in.op {
+	case dot, chars, charsIgnore, listSet, bitSet:
+		tl.threads = append(tl.threads, thread{pi: pi, off: off, caps: c})
+		return
+	case branch:
+		p.add(tl, pi+int(in.jump), 0, si, c)
+		p.add(tl, pi+int(in.alt), 0, si, c)
+		return
+	case jump:
+		p.add(tl, pi+int(in.jump), 0, si, c)
+		return
+	case left:
+		if in.i < maxResult {
+			c.tmp[in.i] = si
+		}
+	case right:
+		if in.i < maxResult {
+			c.res[in.i].pos1 = c.tmp[in.i] + 1
+			c.res[in.i].end = si
+		}
+	case startOfLine:
+		m = si == 0 || s[si-1] == '\n'
+	case endOfLine:
+		m = si >= len(s) || s[si] == '\r' ||
+			(s[si] == '\n' && (si < 1 || s[si-1] != '\r'))
+	case startOfString:
+		m = si == 0
+	case endOfString:
+		m = si >= len(s)
+	case startOfWord:
+		m = si == 0 || !matchSet(word.data, s[si-1])
+	case endOfWord:
+		m = si >= len(s) || !matchSet(word.data, s[si])
+	default:
+		panic("bad regex pattern op code")
+	}
+	if m {
+		p.add(tl, pi+1, 0, si, c)
+	}
+}
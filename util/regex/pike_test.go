@@ -0,0 +1,125 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package regex
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+// TestPikeSameAsBacktrack compares the two matchers
+func TestPikeSameAsBacktrack(t *testing.T) {
+	pats := []string{"foo", ".+foo", "^ *", `\Afoo`, `foo\Z`, "a|b|c",
+		"(a|ab)(c|bcd)(d*)", "x*", "x+?y", "(?i)FoO", `\<\w+\>`, "[a-c]+$",
+		"(a+)+b", `\d+(\.\d*)?`, "^Date: .*", "(?q).*", "a??b", "(x)(y)?z",
+		"(ab|a)+b", ".+$", "^(a|b)??\\>"}
+	strs := []string{"", "foo", "xfoo", "hifoobar", "one\n  two", "abcd",
+		"FOO foo", "now is the time", "aaab", "3.14 and 42", "xyz xz",
+		"foo\nDate: Fri, 12 Jul\r\nbar", "ccba\r\nabc", "xxy"}
+	for _, rx := range pats {
+		pat := Compile(rx)
+		for _, s := range strs {
+			for _, incdec := range []int{+1, -1, 0} {
+				pos := 0
+				if incdec == -1 {
+					pos = len(s)
+				}
+				var r1, r2 Result
+				i1, ok := pat.backtrack(s, pos, incdec, &r1, BacktrackLimit)
+				assert.T(t).That(ok)
+				i2 := newPike(pat, s).match(pos, incdec, &r2)
+				assert.T(t).Msg(rx, s, incdec).This(i2).Is(i1)
+				assert.T(t).Msg(rx, s, incdec).This(r2).Is(r1)
+			}
+		}
+	}
+}
+
+// TestPikeRandom compares the two matchers on random patterns and strings
+// since which one is used depends on the length of the string
+func TestPikeRandom(t *testing.T) {
+	atoms := []string{"a", "b", ".", "[ab]", "[^a]", `\w`, "(?i)A", "(a|b)",
+		"(a|ab)", "(ab|a)", "(a*)", "(b?)", "(a+)+", "(.*)", "(a|)", "()",
+		"^", "$", `\<`, `\>`}
+	quants := []string{"", "", "*", "+", "?", "*?", "+?", "??"}
+	r := rand.New(rand.NewSource(1))
+	n := 20000
+	if testing.Short() {
+		n = 2000
+	}
+	for i := 0; i < n; i++ {
+		rx := ""
+		for k := r.Intn(5) + 1; k > 0; k-- {
+			rx += atoms[r.Intn(len(atoms))] + quants[r.Intn(len(quants))]
+		}
+		pat := Compile(rx)
+		b := make([]byte, r.Intn(20))
+		for j := range b {
+			b[j] = "ab \n"[r.Intn(4)]
+		}
+		s := string(b)
+		for _, incdec := range []int{+1, -1, 0} {
+			pos := 0
+			if incdec == -1 {
+				pos = len(s)
+			}
+			var r1, r2 Result
+			i1, ok := pat.backtrack(s, pos, incdec, &r1, 10000)
+			if !ok {
+				continue // too slow to backtrack, would use pike
+			}
+			i2 := newPike(pat, s).match(pos, incdec, &r2)
+			assert.T(t).Msg(rx, s, incdec).This(i2).Is(i1)
+			assert.T(t).Msg(rx, s, incdec).This(r2).Is(r1)
+		}
+	}
+}
+
+func TestPikeCaptures(t *testing.T) {
+	test := func(rx, s string, expected ...string) {
+		t.Helper()
+		var r Result
+		assert.T(t).That(Compile(rx).FirstMatch(s, 0, &r) != -1)
+		for i, e := range expected {
+			assert.T(t).This(r[i].Part(s)).Is(e)
+		}
+	}
+	test("(a|ab)(c|bcd)(d*)", "abcd", "abcd", "a", "bcd", "")
+	test("(\\w+)@(\\w+)", "to joe@acme now", "joe@acme", "joe", "acme")
+	test("(a)*", "aaa", "aaa", "a")
+	test("(x)(y)?z", "xz", "xz", "x", "")
+}
+
+func TestPikePathological(t *testing.T) {
+	s := strings.Repeat("a", 5000)
+	var r Result
+	assert.T(t).This(Compile("(a|aa)+c").FirstMatch(s, 0, &r)).Is(-1)
+	assert.T(t).This(Compile("(a*)*b").FirstMatch(s, 0, &r)).Is(-1)
+	assert.T(t).This(Compile("(a|aa)+$").FirstMatch(s, 0, &r)).Is(0)
+	assert.T(t).This(r[0].end).Is(len(s))
+}
+
+func TestBacktrackLimit(t *testing.T) {
+	defer func(n int) { BacktrackLimit = n }(BacktrackLimit)
+	BacktrackLimit = 100
+	pat := Compile(`(a)x*\1y`)
+	assert.T(t).That(pat.hasBackref())
+	var r Result
+	s := strings.Repeat("ax", 100)
+	assert.T(t).This(func() { pat.FirstMatch(s, 0, &r) }).
+		Panics("backtrack limit")
+	assert.T(t).This(pat.FirstMatch("axxay", 0, &r)).Is(0)
+}
+
+func BenchmarkPike(b *testing.B) {
+	pat := Compile("(a|aa)+c")
+	var r Result
+	s := strings.Repeat("a", 100)
+	for n := 0; n < b.N; n++ {
+		pat.FirstMatch(s, 0, &r)
+	}
+}
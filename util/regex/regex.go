@@ -13,7 +13,13 @@ import (
 	"github.com/apmckinlay/gsuneido/util/ints"
 )
 
-// Pattern is a compiled regular expression
+// Pattern is a compiled regular expression.
+//
+// Matching normally backtracks but switches to a linear matcher
+// when that takes too long, so which one is used depends on the input.
+// Both give the same leftmost first results, including the captures.
+// Patterns with backreferences always backtrack
+// and panic if they exceed BacktrackLimit.
 type Pattern []inst
 
 // inst is a single compiled pattern instruction
@@ -105,10 +111,21 @@ func (in inst) String() string {
 	}
 }
 
+// alternate is a point to backtrack to.
+// int32 keeps the alts array small since it is zeroed on every call.
 type alternate struct {
-	pi  int
-	si  int
-	si2 int
+	pi  int32
+	si  int32
+	si2 int32
+	// trail is the length of the undo trail when the alternate was added
+	trail int32
+}
+
+// undo is a capture to restore when backtracking.
+// i less than maxResult is tmp[i], otherwise it is result[i - maxResult]
+type undo struct {
+	i int
+	p part
 }
 
 // Matches returns whether or not a pattern matches a string
@@ -151,11 +168,57 @@ var repeat = inst{op: branch, jump: -1, alt: 1}
 // incdec should be +1 to search forward, -1 to search backward,
 // or 0 to only try at the given position.
 // It returns the position of the first match, or -1 if no match found.
+//
+// Backtracking is normally fastest, but some patterns can go exponential.
+// So patterns without backreferences are only given a budget proportional
+// to the length of the string, if that is exceeded they are matched
+// with the non-backtracking (pike) matcher which is linear
+// and gives the same results (see TestPikeRandom).
+// Patterns with backreferences require backtracking,
+// they are limited by BacktrackLimit.
 func (pat Pattern) match(s string, pos, incdec int, result *Result) int {
+	if pat.hasBackref() {
+		i, ok := pat.backtrack(s, pos, incdec, result, BacktrackLimit)
+		if !ok {
+			panic("regex: match too complex (backtrack limit exceeded)")
+		}
+		return i
+	}
+	budget := 1000 + 10*len(s)
+	if i, ok := pat.backtrack(s, pos, incdec, result, budget); ok {
+		return i
+	}
+	return newPike(pat, s).match(pos, incdec, result)
+}
+
+// BacktrackLimit is the maximum number of times a single match
+// of a pattern with backreferences may backtrack.
+// This prevents pathological patterns from running (practically) forever.
+var BacktrackLimit = 1_000_000
+
+// backtrack is the backtracking matcher.
+// It returns false if it exceeds the budget (number of backtracks)
+// or the maximum number of outstanding alternatives.
+func (pat Pattern) backtrack(s string, pos, incdec int, result *Result,
+	budget int) (int, bool) {
 	var alts [maxAlt]alternate
 	var tmp [maxResult]int
+	// trail is the captures to restore when backtracking
+	// so failed alternatives do not leave partial results.
+	// It is only needed if the pattern has groups (other than 0).
+	var trail []undo
+	groups := pat.hasGroups()
+	if groups {
+		var buf [16]undo
+		trail = buf[:0]
+	}
+	dirty := groups
 outer:
 	for ; 0 <= pos && pos <= len(s); pos += incdec {
+		if dirty {
+			*result = Result{}
+			dirty = false
+		}
 		ai := 0
 		si := pos
 		first := 0 // used to identify first non-left pattern element
@@ -166,26 +229,31 @@ outer:
 			case dot:
 				if pi+1 < len(pat) && pat[pi+1] == repeat {
 					// for .* or .+ shortcut looping to end of line
-					alts[ai].pi = pi + 2
-					alts[ai].si = si + 1
 					j := strings.IndexAny(s[si:], "\r\n")
 					if j == -1 {
-						si = len(s)
-					} else {
+						j = len(s) - si
+					}
+					if m = j > 0; m {
+						if ai >= maxAlt {
+							return -1, false
+						}
+						alts[ai].pi = int32(pi + 2)
+						alts[ai].si = int32(si + 1)
+						alts[ai].trail = int32(len(trail))
 						si += j
+						alts[ai].si2 = int32(si)
+						ai++
+						pi++
 					}
-					alts[ai].si2 = si
-					ai++
-					pi++
 				} else {
 					m = si < len(s) && s[si] != '\r' && s[si] != '\n'
 					si++
 				}
 			case chars:
-				if pi == first && incdec == +1 && ai == 0 {
+				if pi == first && incdec == +1 && ai == 0 && si == pos {
 					j := strings.Index(s[si:], in.data)
 					if j == -1 {
-						return -1 // chars don't exist, give up
+						return -1, true // chars don't exist, give up
 					}
 					if j > 0 {
 						// skip ahead and restart match where chars are
@@ -214,12 +282,18 @@ outer:
 				}
 				si++
 			case branch:
-				if ai > 0 && alts[ai-1].pi == pi+int(in.alt) && si == alts[ai-1].si2+1 {
+				if ai > 0 && int(alts[ai-1].pi) == pi+int(in.alt) &&
+					si == int(alts[ai-1].si2)+1 &&
+					int(alts[ai-1].trail) == len(trail) {
 					alts[ai-1].si2++ // expand existing entry, avoid stack growth
 				} else {
-					alts[ai].pi = pi + int(in.alt)
-					alts[ai].si = si
-					alts[ai].si2 = si
+					if ai >= maxAlt {
+						return -1, false
+					}
+					alts[ai].pi = int32(pi + int(in.alt))
+					alts[ai].si = int32(si)
+					alts[ai].si2 = int32(si)
+					alts[ai].trail = int32(len(trail))
 					ai++
 				}
 				fallthrough
@@ -230,12 +304,21 @@ outer:
 					first++
 				}
 				if in.i < maxResult {
+					if groups && ai > 0 {
+						trail = append(trail,
+							undo{i: int(in.i), p: part{pos1: tmp[in.i]}})
+					}
 					tmp[in.i] = si
 				}
 			case right:
 				if in.i < maxResult {
+					if groups && ai > 0 {
+						trail = append(trail,
+							undo{i: maxResult + int(in.i), p: result[in.i]})
+					}
 					result[in.i].pos1 = tmp[in.i] + 1
 					result[in.i].end = si
+					dirty = true
 				}
 			case backref:
 				m, si = backrefMatch(s, si, result[in.i], strings.HasPrefix)
@@ -243,10 +326,10 @@ outer:
 				m, si = backrefMatch(s, si, result[in.i], hasPrefixIgnore)
 			case startOfLine:
 				m = si == 0 || s[si-1] == '\n'
-				if !m && pi == first && incdec == +1 && ai == 0 {
+				if !m && pi == first && incdec == +1 && ai == 0 && si == pos {
 					j := strings.IndexByte(s[si:], '\n')
 					if j == -1 {
-						return -1
+						return -1, true
 					}
 					pos = si + j // skip ahead
 				}
@@ -255,8 +338,8 @@ outer:
 					(s[si] == '\n' && (si < 1 || s[si-1] != '\r'))
 			case startOfString:
 				m = si == 0
-				if !m && pi == first && incdec == +1 && ai == 0 {
-					return -1
+				if !m && pi == first && incdec == +1 && ai == 0 && si == pos {
+					return -1, true
 				}
 			case endOfString:
 				m = si >= len(s)
@@ -270,13 +353,17 @@ outer:
 			if !m {
 				if ai > 0 {
 					// backtrack
-					pi = alts[ai-1].pi - 1 // -1 because loop increments
+					if budget--; budget < 0 {
+						return -1, false
+					}
+					pi = int(alts[ai-1].pi) - 1 // -1 because loop increments
+					trail = unwind(trail, int(alts[ai-1].trail), &tmp, result)
 					if alts[ai-1].si2 > alts[ai-1].si {
-						si = alts[ai-1].si2
+						si = int(alts[ai-1].si2)
 						alts[ai-1].si2--
 					} else {
 						ai--
-						si = alts[ai].si
+						si = int(alts[ai].si)
 					}
 				} else if incdec != 0 {
 					continue outer
@@ -285,9 +372,34 @@ outer:
 				}
 			}
 		}
-		return pos // matched to end of pattern
+		return pos, true // matched to end of pattern
+	}
+	if dirty {
+		*result = Result{}
+	}
+	return -1, true // didn't match at any position
+}
+
+// hasGroups returns whether the pattern has groups other than the whole match
+func (pat Pattern) hasGroups() bool {
+	for i := range pat {
+		if pat[i].op == left && pat[i].i > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// unwind restores the captures recorded in the trail after n
+func unwind(trail []undo, n int, tmp *[maxResult]int, result *Result) []undo {
+	for i := len(trail) - 1; i >= n; i-- {
+		if u := trail[i]; u.i < maxResult {
+			tmp[u.i] = u.p.pos1
+		} else {
+			result[u.i-maxResult] = u.p
+		}
 	}
-	return -1 // didn't match at any position
+	return trail[:n]
 }
 
 // hasPrefixIgnore returns whether s has pre as a prefix
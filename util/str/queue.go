@@ -23,6 +23,16 @@ func (q *Queue) Take() string {
 	return s
 }
 
+// Has returns whether the queue contains the string
+func (q *Queue) Has(s string) bool {
+	for _, x := range q.list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
 // Empty returns true is the queue is empty, otherwise false.
 func (q *Queue) Empty() bool {
 	return len(q.list) == 0
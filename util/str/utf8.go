@@ -0,0 +1,73 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package str
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The Utf8 functions work with codepoints rather than bytes.
+// Invalid UTF-8 bytes are treated as one codepoint each
+// and are left unchanged so they do not corrupt the data.
+
+// Utf8Len returns the number of codepoints in s
+func Utf8Len(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// Utf8Subn returns the substring of s starting at codepoint i
+// for n codepoints. A negative i is relative to the end of the string.
+// Like Subn, the index and length may exceed the string.
+func Utf8Subn(s string, i, n int) string {
+	if i < 0 {
+		i += Utf8Len(s)
+		if i < 0 {
+			i = 0
+		}
+	}
+	if n <= 0 {
+		return ""
+	}
+	from := utf8Offset(s, 0, i)
+	return s[from:utf8Offset(s, from, n)]
+}
+
+// utf8Offset returns the byte offset of n codepoints after pos
+// (or len(s) if there are not that many)
+func utf8Offset(s string, pos, n int) int {
+	for ; n > 0 && pos < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[pos:])
+		pos += size
+	}
+	return pos
+}
+
+// Utf8ToUpper is a Unicode version of ToUpper
+func Utf8ToUpper(s string) string {
+	return utf8Map(s, unicode.ToUpper)
+}
+
+// Utf8ToLower is a Unicode version of ToLower
+func Utf8ToLower(s string) string {
+	return utf8Map(s, unicode.ToLower)
+}
+
+// utf8Map is similar to strings.Map but it leaves invalid bytes unchanged
+// rather than replacing them with utf8.RuneError
+func utf8Map(s string, fn func(rune) rune) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size <= 1 {
+			sb.WriteByte(s[i]) // invalid
+		} else {
+			sb.WriteRune(fn(r))
+		}
+		i += size
+	}
+	return sb.String()
+}
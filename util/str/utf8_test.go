@@ -0,0 +1,41 @@
+// Copyright Suneido Software Corp. All rights reserved.
+// Governed by the MIT license found in the LICENSE file.
+
+package str
+
+import (
+	"testing"
+
+	"github.com/apmckinlay/gsuneido/util/assert"
+)
+
+func TestUtf8Len(t *testing.T) {
+	assert := assert.T(t)
+	assert.This(Utf8Len("")).Is(0)
+	assert.This(Utf8Len("abc")).Is(3)
+	assert.This(Utf8Len("héllo")).Is(5)
+	assert.This(Utf8Len("日本語")).Is(3)
+	assert.This(Utf8Len("a\xffb")).Is(3) // invalid byte counts as one
+}
+
+func TestUtf8Subn(t *testing.T) {
+	assert := assert.T(t)
+	s := "naïve café"
+	assert.This(Utf8Subn(s, 0, 5)).Is("naïve")
+	assert.This(Utf8Subn(s, 2, 1)).Is("ï")
+	assert.This(Utf8Subn(s, -4, 99)).Is("café")
+	assert.This(Utf8Subn(s, -1, 1)).Is("é")
+	assert.This(Utf8Subn(s, 20, 5)).Is("")
+	assert.This(Utf8Subn(s, -20, 2)).Is("na")
+	assert.This(Utf8Subn(s, 0, 0)).Is("")
+	assert.This(Utf8Subn("日本語", 1, 1)).Is("本")
+}
+
+func TestUtf8Case(t *testing.T) {
+	assert := assert.T(t)
+	assert.This(Utf8ToUpper("héllo wörld")).Is("HÉLLO WÖRLD")
+	assert.This(Utf8ToLower("ÀÉÎ")).Is("àéî")
+	assert.This(Utf8ToUpper("a\xffb")).Is("A\xffB") // invalid unchanged
+	// the ascii versions don't change non-ascii
+	assert.This(ToUpper("é")).Is("é")
+}